@@ -0,0 +1,145 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nikogura/podboard/pkg/podboard"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var cleanupCluster string
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var cleanupNamespace string
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var cleanupOlderThan time.Duration
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var cleanupPhases []string
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var cleanupReasons []string
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var cleanupDryRun bool
+
+// cleanupCmd deletes finished pods older than a retention window.
+//
+//nolint:gochecknoglobals // Cobra boilerplate
+var cleanupCmd = &cobra.Command{
+	Use:   "cleanup",
+	Short: "Delete Succeeded and Failed pods older than a retention window",
+	Long: `Find the Succeeded and Failed pods that finished longer ago than --older-than
+and delete them, such as the pods completed Jobs leave behind.
+
+A pod's finish time is when its last container terminated, or when it started
+for pods that never ran a container, such as evicted ones.
+
+Use --reason to limit the cleanup to pods with given status reasons, such as
+--phase Failed --reason Evicted,Shutdown,NodeShutdown,Terminated for the pods
+left behind by node-pressure evictions and node shutdowns.
+
+Use --dry-run to list the pods without deleting them. The same cleanup is
+available from the API as POST /api/pods/cleanup.
+
+Exits non-zero if any pod couldn't be deleted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger, err := zap.NewProduction()
+		if err != nil {
+			log.Fatalf("failed to create logger: %s", err)
+		}
+		defer func() {
+			_ = logger.Sync() // Ignore error on logger sync in defer
+		}()
+
+		cleanup := podboard.PodCleanup{Namespace: cleanupNamespace, Retention: cleanupOlderThan, Phases: cleanupPhases, Reasons: cleanupReasons, DryRun: cleanupDryRun}
+		err = cleanup.Validate()
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+
+		config := serverConfig()
+		_, err = podboard.NewNamespacePolicy(config.AllowedNamespaces, config.DeniedNamespaces)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+
+		derivedStatuses, err := podboard.NewDerivedStatuses(nil, logger)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		kubeConfigService := podboard.NewKubeConfigService(config, logger)
+		podService := podboard.NewPodService(config, derivedStatuses, nil, kubeConfigService, logger)
+
+		result, err := podService.CleanupPods(context.Background(), cleanupCluster, cleanup)
+		if err != nil {
+			log.Fatalf("cleanup failed: %s", err)
+		}
+		printCleanup(result)
+
+		if len(result.Failed) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// printCleanup prints the pods a cleanup matched and what became of them.
+func printCleanup(result podboard.PodCleanupResult) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "NAMESPACE\tNAME\tSTATUS\tAGE")
+	for _, pod := range result.Pods {
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", pod.Namespace, pod.Name, pod.Status, pod.Age)
+	}
+	_ = writer.Flush()
+
+	if result.DryRun {
+		fmt.Printf("\n%d finished pods older than %s would be deleted (dry run)\n", len(result.Pods), result.Retention)
+		return
+	}
+	fmt.Printf("\n%d deleted, %d failed\n", len(result.Deleted), len(result.Failed))
+	for pod, failure := range result.Failed {
+		fmt.Printf("  %s: %s\n", pod, strings.TrimSpace(failure))
+	}
+}
+
+//nolint:gochecknoinits // Cobra boilerplate
+func init() {
+	rootCmd.AddCommand(cleanupCmd)
+	cleanupCmd.Flags().StringVar(&cleanupCluster, "cluster", "", "Kubeconfig cluster to clean up, the current one by default")
+	cleanupCmd.Flags().StringVarP(&cleanupNamespace, "namespace", "n", "all", "Namespace to clean up, all for every namespace")
+	cleanupCmd.Flags().DurationVar(&cleanupOlderThan, "older-than", podboard.DefaultPodCleanupRetention, "Retention window: only pods that finished longer ago are deleted")
+	cleanupCmd.Flags().StringSliceVar(&cleanupPhases, "phase", []string{"Succeeded", "Failed"}, "Pod phases to clean up, Succeeded and/or Failed")
+	cleanupCmd.Flags().StringSliceVar(&cleanupReasons, "reason", nil, "Pod status reasons to clean up, such as Evicted, any reason by default")
+	cleanupCmd.Flags().BoolVar(&cleanupDryRun, "dry-run", false, "List the pods that would be deleted without deleting them")
+}
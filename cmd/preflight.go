@@ -0,0 +1,78 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/nikogura/podboard/pkg/podboard"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// preflightCmd validates the environment podboard will run in.
+//
+//nolint:gochecknoglobals // Cobra boilerplate
+var preflightCmd = &cobra.Command{
+	Use:   "preflight",
+	Short: "Check that podboard can run in this environment",
+	Long: `Validate the environment podboard will run in and print a pass/fail report.
+
+Checks performed:
+- Kubernetes configuration (in-cluster service account or kubeconfig)
+- Reachability of every cluster in the kubeconfig
+- RBAC permissions needed for pod listing, namespace discovery, events, deployments and pod deletion
+- Availability of the listen address
+- With --offline, kubeconfig credentials that may make outbound calls
+
+The same checks (except the listen address) run automatically when the server
+starts, and any problems are logged as warnings.
+
+Exits non-zero if any check fails. Warnings do not fail the command.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger, err := zap.NewProduction()
+		if err != nil {
+			log.Fatalf("failed to create logger: %s", err)
+		}
+		defer func() {
+			_ = logger.Sync() // Ignore error on logger sync in defer
+		}()
+
+		kubeConfigService := podboard.NewKubeConfigService(serverConfig(), logger)
+		report := podboard.RunPreflight(context.Background(), serverConfig(), kubeConfigService)
+		report.Print(os.Stdout)
+
+		if !report.Passed() {
+			os.Exit(1)
+		}
+	},
+}
+
+//nolint:gochecknoinits // Cobra boilerplate
+func init() {
+	rootCmd.AddCommand(preflightCmd)
+	preflightCmd.Flags().StringVarP(&address, "bind-address", "b", "0.0.0.0:9999", "Address (host and port) on which the server will listen")
+	preflightCmd.Flags().BoolVar(&impersonate, "impersonate", os.Getenv("PODBOARD_IMPERSONATE") == "true", "Also check the permission to impersonate users and groups (env PODBOARD_IMPERSONATE)")
+}
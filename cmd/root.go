@@ -25,6 +25,9 @@ package cmd
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/nikogura/podboard/pkg/podboard"
 	"github.com/spf13/cobra"
@@ -43,12 +46,121 @@ var address string
 //nolint:gochecknoglobals // Cobra boilerplate
 var domain string
 
+//nolint:gochecknoglobals // Cobra boilerplate
+var offline bool
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var impersonate bool
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var trustIdentityHeaders bool
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var userHeader string
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var groupsHeader string
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var uiDir string
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var configFile string
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var runbookAnnotation string
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var extraKubeconfigDir string
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var inClusterName string
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var streamHeartbeat time.Duration
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var streamRetry time.Duration
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var writeTimeout time.Duration
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var idleTimeout time.Duration
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var k8sRequestTimeout time.Duration
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var k8sQPS float32
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var k8sBurst int
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var k8sRetries int
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var primeCache bool
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var readyAfterPriming bool
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var warmClusters int
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var compressionMinSize int
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var timeZone string
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var deleteUndoWindow time.Duration
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var maxConcurrentRequests int
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var maxHeapMB int
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var corsAllowedOrigins []string
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var corsAllowedMethods []string
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var corsAllowedHeaders []string
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var corsAllowCredentials bool
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var csrfProtection bool
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var confirmDestructive bool
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var namespaces []string
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var clusters []string
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var allowedNamespaces []string
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var deniedNamespaces []string
+
 // rootCmd represents the base command when called without any subcommands.
 //
 //nolint:gochecknoglobals // Cobra boilerplate
 var rootCmd = &cobra.Command{
-	Use:   "podboard",
-	Short: "Kubernetes Pod Dashboard",
+	Use:     "podboard",
+	Version: podboard.Version,
+	Short:   "Kubernetes Pod Dashboard",
 	Long: `podboard is a web-based dashboard for monitoring Kubernetes pods.
 
 It provides a real-time view of pod status across namespaces, similar to running
@@ -64,6 +176,7 @@ The server provides:
 
 Kubernetes configuration:
 - Uses in-cluster config when running in a pod
+- --extra-kubeconfig-dir adds the clusters of mounted kubeconfigs when running in a pod
 - Falls back to ~/.kube/config for local development
 - NAMESPACE: Default namespace to monitor (default: default)
 
@@ -84,7 +197,7 @@ Example (production deployment):
 		}()
 
 		// Run the server
-		err = podboard.RunServer(address, domain, logger)
+		err = podboard.RunServer(serverConfig(), logger)
 		if err != nil {
 			logger.Fatal("Failed to start server", zap.Error(err))
 		}
@@ -106,4 +219,120 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&logLevel, "log-level", "l", "Info", "Log Level (Trace, Debug, Info, Warn, Error)")
 	rootCmd.Flags().StringVarP(&address, "bind-address", "b", "0.0.0.0:9999", "Address (host and port) on which to listen")
 	rootCmd.Flags().StringVarP(&domain, "domain", "d", "", "server domain name")
+	rootCmd.PersistentFlags().BoolVar(&offline, "offline", os.Getenv("PODBOARD_OFFLINE") == "true", "Air-gapped mode: never make outbound calls other than to Kubernetes API servers (env PODBOARD_OFFLINE)")
+	rootCmd.Flags().BoolVar(&impersonate, "impersonate", os.Getenv("PODBOARD_IMPERSONATE") == "true", "Call Kubernetes as the user identified by the authenticating proxy's headers (env PODBOARD_IMPERSONATE)")
+	rootCmd.Flags().BoolVar(&trustIdentityHeaders, "trust-identity-headers", os.Getenv("PODBOARD_TRUST_IDENTITY_HEADERS") == "true", "Authenticate users by the proxy's identity headers, such as oauth2-proxy's, to apply access bindings without impersonating them (env PODBOARD_TRUST_IDENTITY_HEADERS)")
+	rootCmd.Flags().StringVar(&userHeader, "user-header", podboard.DefaultUserHeader, "Header carrying the authenticated user name when impersonating or trusting identity headers")
+	rootCmd.Flags().StringVar(&groupsHeader, "groups-header", podboard.DefaultGroupsHeader, "Header carrying the authenticated user's comma separated groups when impersonating or trusting identity headers")
+	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", os.Getenv("PODBOARD_CONFIG"), "YAML config file with custom actions (env PODBOARD_CONFIG)")
+	rootCmd.Flags().StringVar(&runbookAnnotation, "runbook-annotation", podboard.DefaultRunbookAnnotation, "Pod and deployment annotation holding a runbook URL, empty to disable")
+	rootCmd.PersistentFlags().StringVar(&extraKubeconfigDir, "extra-kubeconfig-dir", os.Getenv("PODBOARD_EXTRA_KUBECONFIG_DIR"), "In cluster, also serve the clusters of every kubeconfig file in this directory (env PODBOARD_EXTRA_KUBECONFIG_DIR)")
+	rootCmd.PersistentFlags().StringVar(&inClusterName, "in-cluster-name", podboard.DefaultInClusterName, "Cluster name of the in-cluster connection when --extra-kubeconfig-dir is set")
+	rootCmd.Flags().StringVar(&uiDir, "ui-dir", os.Getenv("PODBOARD_UI_DIR"), "Serve the UI from this directory of built files instead of the embedded UI, reloading browsers on change (env PODBOARD_UI_DIR)")
+	rootCmd.Flags().DurationVar(&streamHeartbeat, "stream-heartbeat", podboard.DefaultStreamHeartbeat, "Interval between heartbeats on event streams so proxies don't close them as idle, 0 to disable")
+	rootCmd.Flags().DurationVar(&streamRetry, "stream-retry", podboard.DefaultStreamRetry, "Reconnect delay event streams advertise to clients, stretched after a stream fails")
+	rootCmd.Flags().DurationVar(&writeTimeout, "write-timeout", 0, "Maximum time to write a response, 0 for no limit; event streams are exempt")
+	rootCmd.PersistentFlags().DurationVar(&k8sRequestTimeout, "k8s-request-timeout", podboard.DefaultKubernetesRequestTimeout, "Maximum time for each Kubernetes API request, so a slow or unreachable cluster can't hang requests; 0 for no limit, watches are exempt")
+	rootCmd.PersistentFlags().Float32Var(&k8sQPS, "k8s-qps", envFloat32("PODBOARD_K8S_QPS", podboard.DefaultKubernetesQPS), "Sustained Kubernetes API requests per second to each cluster, shared by all users; 0 for client-go's default of 5, negative for no limit (env PODBOARD_K8S_QPS)")
+	rootCmd.PersistentFlags().IntVar(&k8sBurst, "k8s-burst", envInt("PODBOARD_K8S_BURST", podboard.DefaultKubernetesBurst), "Kubernetes API requests to each cluster that may go above --k8s-qps at once (env PODBOARD_K8S_BURST)")
+	rootCmd.PersistentFlags().IntVar(&k8sRetries, "k8s-retries", podboard.DefaultKubernetesRetries, "How many times a Kubernetes API read that failed transiently, such as with a 429 or a dropped connection, is retried with backoff; 0 to disable")
+	rootCmd.Flags().BoolVar(&primeCache, "prime-cache", os.Getenv("PODBOARD_PRIME_CACHE") != "false", "On startup, load the pods of every namespace of the cluster the UI opens on, so the first page loads are fast (env PODBOARD_PRIME_CACHE)")
+	rootCmd.Flags().BoolVar(&readyAfterPriming, "ready-after-priming", os.Getenv("PODBOARD_READY_AFTER_PRIMING") == "true", "Fail /ready until startup priming is done, for readiness probes (env PODBOARD_READY_AFTER_PRIMING)")
+	rootCmd.Flags().IntVar(&warmClusters, "warm-clusters", 0, "When clusters are listed, warm up the clients of this many recently used clusters in the background so switching to them is fast; 0 to disable")
+	rootCmd.Flags().DurationVar(&idleTimeout, "idle-timeout", podboard.DefaultIdleTimeout, "How long idle keep-alive connections stay open; keep it above the proxy's idle timeout (60s for AWS ALB and NGINX)")
+	rootCmd.Flags().IntVar(&compressionMinSize, "compression-min-size", podboard.DefaultCompressionMinSize, "Smallest API response in bytes compressed for clients accepting gzip or deflate, -1 to disable")
+	rootCmd.Flags().StringVar(&timeZone, "time-zone", podboard.DefaultTimeZone, "IANA time zone timestamps are rendered in, e.g. Europe/Berlin; requests can override it with the tz parameter")
+	rootCmd.Flags().DurationVar(&deleteUndoWindow, "delete-undo-window", 0, "Hold pod deletions back this long so they can be undone, 0 to delete immediately")
+	rootCmd.Flags().IntVar(&maxConcurrentRequests, "max-concurrent-requests", envInt("PODBOARD_MAX_CONCURRENT_REQUESTS", 0), "Shed load while more API requests than this are in flight: serve cached lists and reject non-essential requests with 503; 0 for no limit (env PODBOARD_MAX_CONCURRENT_REQUESTS)")
+	rootCmd.Flags().IntVar(&maxHeapMB, "max-heap-mb", envInt("PODBOARD_MAX_HEAP_MB", 0), "Shed load while the heap is larger than this many MiB; 0 for no limit (env PODBOARD_MAX_HEAP_MB)")
+	rootCmd.Flags().StringSliceVar(&corsAllowedOrigins, "cors-allowed-origins", envList("PODBOARD_CORS_ALLOWED_ORIGINS"), "Origins allowed to call the API from the browser, exact like https://tools.example.com, globs like https://*.example.com, or * for any (env PODBOARD_CORS_ALLOWED_ORIGINS, comma separated)")
+	rootCmd.Flags().StringSliceVar(&corsAllowedMethods, "cors-allowed-methods", envList("PODBOARD_CORS_ALLOWED_METHODS"), "Methods cross-origin calls may use (default GET, POST, PUT, PATCH and DELETE; env PODBOARD_CORS_ALLOWED_METHODS, comma separated)")
+	rootCmd.Flags().StringSliceVar(&corsAllowedHeaders, "cors-allowed-headers", envList("PODBOARD_CORS_ALLOWED_HEADERS"), "Request headers cross-origin calls may send (default Content-Type and podboard's own headers; env PODBOARD_CORS_ALLOWED_HEADERS, comma separated)")
+	rootCmd.Flags().BoolVar(&corsAllowCredentials, "cors-allow-credentials", os.Getenv("PODBOARD_CORS_ALLOW_CREDENTIALS") == "true", "Let cross-origin calls from the listed origins send cookies, such as an authenticating proxy's session (env PODBOARD_CORS_ALLOW_CREDENTIALS)")
+	rootCmd.Flags().BoolVar(&csrfProtection, "csrf", os.Getenv("PODBOARD_CSRF") != "false", "Require requests that change something to echo the podboard_csrf cookie in the X-CSRF-Token header (env PODBOARD_CSRF)")
+	rootCmd.Flags().BoolVar(&confirmDestructive, "confirm-destructive", os.Getenv("PODBOARD_CONFIRM_DESTRUCTIVE") == "true", "Make deletes, evictions, scaling, restarts, drains, migrations, pending actions, reverts and canary decisions take two steps, confirmed with a token from the first (env PODBOARD_CONFIRM_DESTRUCTIVE)")
+	rootCmd.PersistentFlags().StringSliceVar(&namespaces, "namespaces", envList("PODBOARD_NAMESPACES"), "Namespaces to offer when podboard may not list namespaces, kept if it may list pods in them (env PODBOARD_NAMESPACES, comma separated)")
+	rootCmd.PersistentFlags().StringSliceVar(&clusters, "clusters", envList("PODBOARD_CLUSTERS"), "Only these kubeconfig clusters, by name or glob, are exposed and connected to; contexts of other clusters are hidden too (env PODBOARD_CLUSTERS, comma separated)")
+	rootCmd.PersistentFlags().StringSliceVar(&allowedNamespaces, "allowed-namespaces", envList("PODBOARD_ALLOWED_NAMESPACES"), "Only namespaces matching one of these globs, or regular expressions between slashes, may be seen or acted on (env PODBOARD_ALLOWED_NAMESPACES, comma separated)")
+	rootCmd.PersistentFlags().StringSliceVar(&deniedNamespaces, "denied-namespaces", envList("PODBOARD_DENIED_NAMESPACES"), "Namespaces matching one of these globs, or regular expressions between slashes, may never be seen or acted on, e.g. kube-* (env PODBOARD_DENIED_NAMESPACES, comma separated)")
+}
+
+// serverConfig builds the server configuration from command line flags.
+func serverConfig() (config podboard.ServerConfig) {
+	config = podboard.ServerConfig{
+		Address:                  address,
+		Domain:                   domain,
+		Offline:                  offline,
+		Impersonate:              impersonate,
+		TrustIdentityHeaders:     trustIdentityHeaders,
+		UserHeader:               userHeader,
+		GroupsHeader:             groupsHeader,
+		UIDir:                    uiDir,
+		ConfigFile:               configFile,
+		RunbookAnnotation:        runbookAnnotation,
+		ExtraKubeconfigDir:       extraKubeconfigDir,
+		InClusterName:            inClusterName,
+		StreamHeartbeat:          streamHeartbeat,
+		StreamRetry:              streamRetry,
+		WriteTimeout:             writeTimeout,
+		IdleTimeout:              idleTimeout,
+		KubernetesRequestTimeout: k8sRequestTimeout,
+		KubernetesQPS:            k8sQPS,
+		KubernetesBurst:          k8sBurst,
+		KubernetesRetries:        k8sRetries,
+		PrimeCache:               primeCache,
+		ReadyAfterPriming:        readyAfterPriming,
+		WarmClusters:             warmClusters,
+		CompressionMinSize:       compressionMinSize,
+		TimeZone:                 timeZone,
+		DeleteUndoWindow:         deleteUndoWindow,
+		MaxConcurrentRequests:    maxConcurrentRequests,
+		MaxHeapBytes:             uint64(max(maxHeapMB, 0)) << 20, //nolint:gosec // Clamped to zero or more
+		CORSAllowedOrigins:       corsAllowedOrigins,
+		CORSAllowedMethods:       corsAllowedMethods,
+		CORSAllowedHeaders:       corsAllowedHeaders,
+		CORSAllowCredentials:     corsAllowCredentials,
+		CSRFProtection:           csrfProtection,
+		ConfirmDestructive:       confirmDestructive,
+		Namespaces:               namespaces,
+		Clusters:                 clusters,
+		AllowedNamespaces:        allowedNamespaces,
+		DeniedNamespaces:         deniedNamespaces,
+	}
+	return config
+}
+
+// envFloat32 parses a numeric environment variable, returning fallback when it is unset or not a number.
+func envFloat32(name string, fallback float32) (value float32) {
+	value = fallback
+	parsed, err := strconv.ParseFloat(os.Getenv(name), 32)
+	if err == nil {
+		value = float32(parsed)
+	}
+	return value
+}
+
+// envInt parses an integer environment variable, returning fallback when it is unset or not an integer.
+func envInt(name string, fallback int) (value int) {
+	value = fallback
+	parsed, err := strconv.Atoi(os.Getenv(name))
+	if err == nil {
+		value = parsed
+	}
+	return value
+}
+
+// envList splits a comma separated environment variable, returning nil when it is unset.
+func envList(name string) (values []string) {
+	value := os.Getenv(name)
+	if value == "" {
+		return values
+	}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
 }
@@ -0,0 +1,114 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/nikogura/podboard/pkg/podboard"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var checkOnly bool
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var insecureSkipSignature bool
+
+// updateCmd replaces the running binary with the latest release.
+//
+//nolint:gochecknoglobals // Cobra boilerplate
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update podboard to the latest release",
+	Long: `Check GitHub releases for a newer podboard and replace the running binary.
+
+The release binary for this platform is downloaded, verified against the
+release's checksums.txt and minisign signature, and swapped in place of the
+running executable. FIPS builds update to the matching -fips release binary.
+
+Signatures are checked against the release public key built into podboard, or
+--public-key (env PODBOARD_RELEASE_PUBLIC_KEY). Without a key, or when the
+release has no signature, the update is refused: the checksums come from the
+same release and don't show who published it. --insecure-skip-signature
+installs such releases anyway.
+
+Use --check-only to report whether an update is available without installing it.
+Updating is disabled in --offline mode.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger, err := zap.NewProduction()
+		if err != nil {
+			log.Fatalf("failed to create logger: %s", err)
+		}
+		defer func() {
+			_ = logger.Sync() // Ignore error on logger sync in defer
+		}()
+
+		ctx := context.Background()
+		updater := podboard.NewUpdater(serverConfig(), resolvePublicKey(publicKey), insecureSkipSignature, logger)
+
+		release, err := updater.LatestRelease(ctx)
+		if err != nil {
+			log.Fatalf("update check failed: %s", err)
+		}
+
+		if !podboard.NewerVersion(podboard.Version, release.Version) {
+			fmt.Printf("podboard %s is up to date\n", podboard.Version)
+			return
+		}
+
+		fmt.Printf("podboard %s is available (current: %s)\n", release.Version, podboard.Version)
+		if checkOnly {
+			return
+		}
+
+		executable, err := os.Executable()
+		if err != nil {
+			log.Fatalf("failed to locate running binary: %s", err)
+		}
+		executable, err = filepath.EvalSymlinks(executable)
+		if err != nil {
+			log.Fatalf("failed to resolve running binary: %s", err)
+		}
+
+		err = updater.Apply(ctx, release, executable)
+		if err != nil {
+			log.Fatalf("update failed: %s", err)
+		}
+
+		fmt.Printf("Updated %s to podboard %s\n", executable, release.Version)
+	},
+}
+
+//nolint:gochecknoinits // Cobra boilerplate
+func init() {
+	rootCmd.AddCommand(updateCmd)
+	updateCmd.Flags().StringVar(&publicKey, "public-key", defaultPublicKey(), "Minisign public key release binaries must be signed with (env PODBOARD_RELEASE_PUBLIC_KEY)")
+	updateCmd.Flags().BoolVar(&checkOnly, "check-only", false, "Only report whether an update is available")
+	updateCmd.Flags().BoolVar(&insecureSkipSignature, "insecure-skip-signature", false, "Install releases whose signature can't be verified, for want of a public key or signature")
+}
@@ -0,0 +1,163 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nikogura/podboard/pkg/podboard"
+	"github.com/spf13/cobra"
+)
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var publicKey string
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var signatureFile string
+
+//nolint:gochecknoglobals // Cobra boilerplate
+var checksumsFile string
+
+// verifyCmd verifies a downloaded release artifact.
+//
+//nolint:gochecknoglobals // Cobra boilerplate
+var verifyCmd = &cobra.Command{
+	Use:   "verify <file>",
+	Short: "Verify the signature of a downloaded podboard release artifact",
+	Long: `Verify the minisign signature of a release artifact, and optionally its checksum.
+
+By default the signature is read from <file>.minisig and verified against the
+release public key built into podboard. Use --public-key (or
+PODBOARD_RELEASE_PUBLIC_KEY) to supply a key, either the base64 key or a
+minisign .pub file.
+
+With --checksums, the file's sha256 must also match its entry in checksums.txt.
+
+Exits non-zero if verification fails.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := verifyArtifact(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "verification failed: %s\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+//nolint:gochecknoinits // Cobra boilerplate
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+	verifyCmd.Flags().StringVar(&publicKey, "public-key", defaultPublicKey(), "Minisign public key, or path to a minisign .pub file (env PODBOARD_RELEASE_PUBLIC_KEY)")
+	verifyCmd.Flags().StringVar(&signatureFile, "signature", "", "Signature file (default: <file>.minisig)")
+	verifyCmd.Flags().StringVar(&checksumsFile, "checksums", "", "Also verify the file against this checksums.txt")
+}
+
+// defaultPublicKey returns the release public key from the environment or the one built into podboard.
+func defaultPublicKey() (key string) {
+	key = os.Getenv("PODBOARD_RELEASE_PUBLIC_KEY")
+	if key == "" {
+		key = podboard.ReleasePublicKey
+	}
+	return key
+}
+
+// resolvePublicKey returns the public key flag value, reading it from a file when it names one.
+func resolvePublicKey(value string) (key string) {
+	key = value
+	if value == "" {
+		return key
+	}
+
+	content, err := os.ReadFile(value)
+	if err == nil {
+		key = string(content)
+	}
+	return key
+}
+
+// loadPublicKey parses the public key flag.
+func loadPublicKey(value string) (key podboard.MinisignPublicKey, err error) {
+	if value == "" {
+		err = errors.New("no public key: use --public-key or PODBOARD_RELEASE_PUBLIC_KEY")
+		return key, err
+	}
+
+	key, err = podboard.ParseMinisignPublicKey(resolvePublicKey(value))
+	return key, err
+}
+
+// verifyArtifact verifies the signature and, if requested, the checksum of the file at path.
+func verifyArtifact(path string) (err error) {
+	var key podboard.MinisignPublicKey
+	key, err = loadPublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+
+	var content []byte
+	content, err = os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if signatureFile == "" {
+		signatureFile = path + podboard.SignatureSuffix
+	}
+
+	var signature []byte
+	signature, err = os.ReadFile(signatureFile)
+	if err != nil {
+		return err
+	}
+
+	var trustedComment string
+	trustedComment, err = podboard.VerifyMinisign(key, content, signature)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Signature verified: %s\n", trustedComment)
+
+	if checksumsFile == "" {
+		return err
+	}
+
+	var checksums []byte
+	checksums, err = os.ReadFile(checksumsFile)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(content)
+	err = podboard.VerifyChecksum(checksums, filepath.Base(path), hex.EncodeToString(sum[:]))
+	if err != nil {
+		return err
+	}
+	fmt.Println("Checksum verified")
+
+	return err
+}
@@ -0,0 +1,304 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessAnyUser in a binding's users matches every authenticated user, for a catch-all last binding.
+const AccessAnyUser = "*"
+
+// ErrInvalidAccess is returned for access bindings that can't be applied.
+var ErrInvalidAccess = errors.New("invalid access config")
+
+// AccessConfig binds users authenticated by the proxy in front of podboard to a default cluster and the
+// namespaces they may use.
+type AccessConfig struct {
+	// Bindings are tried in order, and the first one naming the user or one of their groups applies. Users
+	// matching no binding are unrestricted.
+	Bindings []AccessBinding `yaml:"bindings"`
+}
+
+// AccessBinding scopes the users and groups it names.
+type AccessBinding struct {
+	Users  []string `yaml:"users"`
+	Groups []string `yaml:"groups"`
+	// DefaultCluster is the cluster requests naming none are sent to, instead of the kubeconfig's current one.
+	DefaultCluster string `yaml:"defaultCluster"`
+	// Namespaces are the only namespaces the users may use, empty for any.
+	Namespaces []string `yaml:"namespaces"`
+}
+
+// AccessPolicy resolves authenticated users to their access binding.
+type AccessPolicy struct {
+	bindings []AccessBinding
+}
+
+// Access is the authenticated user of a request and the binding scoping them, if any.
+type Access struct {
+	Identity Identity
+	Binding  AccessBinding
+	Bound    bool
+}
+
+type accessContextKey struct{}
+
+// NewAccessPolicy validates the access bindings. Bindings need authenticated users, from trusted identity
+// headers, impersonation or an embedding application's authenticator. Every binding must name users or groups,
+// and its namespaces must be plain namespace names.
+func NewAccessPolicy(access AccessConfig, authenticated bool) (policy *AccessPolicy, err error) {
+	if len(access.Bindings) > 0 && !authenticated {
+		err = fmt.Errorf("%w: bindings need --trust-identity-headers or --impersonate", ErrInvalidAccess)
+		return policy, err
+	}
+	for i, binding := range access.Bindings {
+		if len(binding.Users) == 0 && len(binding.Groups) == 0 {
+			err = fmt.Errorf("%w: binding %d names no users or groups", ErrInvalidAccess, i)
+			return policy, err
+		}
+		for _, namespace := range binding.Namespaces {
+			if namespace == "" || namespace == "all" || strings.Contains(namespace, ",") {
+				err = fmt.Errorf("%w: binding %d: invalid namespace %q", ErrInvalidAccess, i, namespace)
+				return policy, err
+			}
+		}
+	}
+
+	policy = &AccessPolicy{bindings: access.Bindings}
+	return policy, err
+}
+
+// Configured reports whether there are any bindings.
+func (policy *AccessPolicy) Configured() (configured bool) {
+	configured = policy != nil && len(policy.bindings) > 0
+	return configured
+}
+
+// Resolve returns the access of an authenticated user: the first binding naming the user or one of their groups.
+func (policy *AccessPolicy) Resolve(identity Identity) (access Access) {
+	access = Access{Identity: identity}
+	if policy == nil {
+		return access
+	}
+
+	for _, binding := range policy.bindings {
+		matches := slices.Contains(binding.Users, identity.User) || slices.Contains(binding.Users, AccessAnyUser)
+		for _, group := range identity.Groups {
+			matches = matches || slices.Contains(binding.Groups, group)
+		}
+		if matches {
+			access.Binding = binding
+			access.Bound = true
+			return access
+		}
+	}
+	return access
+}
+
+// Scoped reports whether the user is limited to some namespaces.
+func (access Access) Scoped() (scoped bool) {
+	scoped = access.Bound && len(access.Binding.Namespaces) > 0
+	return scoped
+}
+
+// AllowsNamespace reports whether the user may use a namespace.
+func (access Access) AllowsNamespace(namespace string) (allowed bool) {
+	allowed = !access.Scoped() || slices.Contains(access.Binding.Namespaces, namespace)
+	return allowed
+}
+
+// FilterNamespaces returns the namespaces the user may use, in their original order.
+func (access Access) FilterNamespaces(namespaces []string) (allowed []string) {
+	if !access.Scoped() {
+		allowed = namespaces
+		return allowed
+	}
+
+	allowed = make([]string, 0, len(access.Binding.Namespaces))
+	for _, namespace := range namespaces {
+		if access.AllowsNamespace(namespace) {
+			allowed = append(allowed, namespace)
+		}
+	}
+	return allowed
+}
+
+// withAccess returns a copy of ctx carrying the given access.
+func withAccess(ctx context.Context, access Access) (accessCtx context.Context) {
+	accessCtx = context.WithValue(ctx, accessContextKey{}, access)
+	return accessCtx
+}
+
+// AccessFromContext returns the access of the authenticated user of ctx, unrestricted when identity headers
+// aren't trusted.
+func AccessFromContext(ctx context.Context) (access Access) {
+	if ctx != nil {
+		access, _ = ctx.Value(accessContextKey{}).(Access)
+	}
+	return access
+}
+
+// clusterWideRoutes act on whole nodes, across every namespace, so users scoped to namespaces may not use them.
+//
+//nolint:gochecknoglobals // Read-only lookup table
+var clusterWideRoutes = map[string]bool{
+	"/api/nodes/:name/cordon":   true,
+	"/api/nodes/:name/uncordon": true,
+	"/api/nodes/:name/drain":    true,
+	"/api/migrations":           true,
+	"/api/migrations/:id":       true,
+}
+
+// singleNamespaceRoutes are the routes, by method and path, whose namespace query parameter names one
+// namespace. Comma-separated lists are rejected there, so scoped users bound to several namespaces name one.
+//
+//nolint:gochecknoglobals // Read-only lookup table
+var singleNamespaceRoutes = map[string]bool{
+	"GET /api/canary":                true,
+	"POST /api/canary/:decision":     true,
+	"DELETE /api/pods":               true,
+	"POST /api/pods/cleanup":         true,
+	"GET /api/pods/stream":           true,
+	"GET /api/pods/delta":            true,
+	"GET /api/preemptions":           true,
+	"GET /api/events":                true,
+	"GET /api/infrastructure-errors": true,
+	"GET /api/certificates":          true,
+	"GET /api/deployments":           true,
+}
+
+// AllowsRecord reports whether the user may see and act on a job, pending action, revert or quick scale
+// started by user in namespace, which may be a comma-separated list. Users scoped to namespaces only get
+// their own records in their namespaces; other users get every record.
+func (access Access) AllowsRecord(user, namespace string) (allowed bool) {
+	if !access.Scoped() {
+		allowed = true
+		return allowed
+	}
+	if user != access.Identity.User {
+		return allowed
+	}
+	for _, recordNamespace := range splitNamespaces(namespace) {
+		if !access.AllowsNamespace(recordNamespace) {
+			return allowed
+		}
+	}
+	allowed = true
+	return allowed
+}
+
+// filterRecords keeps the records the requesting user may see.
+func filterRecords[T any](ctx context.Context, records []T, ownerOf func(record T) (user, namespace string)) (allowed []T) {
+	access := AccessFromContext(ctx)
+	if !access.Scoped() {
+		allowed = records
+		return allowed
+	}
+
+	allowed = make([]T, 0, len(records))
+	for _, record := range records {
+		if access.AllowsRecord(ownerOf(record)) {
+			allowed = append(allowed, record)
+		}
+	}
+	return allowed
+}
+
+// recordAccessible looks up the record named by the id path parameter and reports whether the requesting user
+// may use it. Records the user may not use get the same 404 as unknown ones, so their existence doesn't leak.
+func recordAccessible[T any](c *gin.Context, get func(id string) (record T, err error), ownerOf func(record T) (user, namespace string)) (accessible bool) {
+	access := AccessFromContext(c.Request.Context())
+	if !access.Scoped() {
+		accessible = true
+		return accessible
+	}
+
+	record, err := get(c.Param("id"))
+	if err != nil || !access.AllowsRecord(ownerOf(record)) {
+		respondErrorCode(c, http.StatusNotFound, fmt.Sprintf("%s not found", c.Param("id")))
+		return accessible
+	}
+	accessible = true
+	return accessible
+}
+
+// accessMiddleware keeps users scoped to namespaces within them. Namespace path parameters and namespace query
+// parameters, including each of a comma-separated list, outside the binding get 403, as do routes acting on
+// whole nodes. A missing or all namespace query parameter is replaced with the binding's namespaces, so
+// listings cover exactly those. Routes taking a single namespace reject lists, rewritten or not, with 400. It
+// rewrites the query, so it must run before anything reads it.
+func accessMiddleware() (handler gin.HandlerFunc) {
+	handler = func(c *gin.Context) {
+		access := AccessFromContext(c.Request.Context())
+		if access.Scoped() && !scopeRequest(c, access) {
+			return
+		}
+
+		namespaces := splitNamespaces(c.Query("namespace"))
+		if len(namespaces) > 1 && singleNamespaceRoutes[c.Request.Method+" "+c.FullPath()] {
+			respondErrorCode(c, http.StatusBadRequest, fmt.Sprintf("%s takes a single namespace, one of %s", c.FullPath(), strings.Join(namespaces, ", ")))
+			return
+		}
+		c.Next()
+	}
+	return handler
+}
+
+// scopeRequest applies a scoped user's binding to a request, responding with 403 and returning false if the
+// request leaves it.
+func scopeRequest(c *gin.Context, access Access) (allowed bool) {
+	if clusterWideRoutes[c.FullPath()] {
+		respondErrorCode(c, http.StatusForbidden, fmt.Sprintf("user %q is limited to namespaces and may not act on whole nodes", access.Identity.User))
+		return allowed
+	}
+
+	if namespace := c.Param("namespace"); namespace != "" && !access.AllowsNamespace(namespace) {
+		respondErrorCode(c, http.StatusForbidden, fmt.Sprintf("user %q may not use namespace %q", access.Identity.User, namespace))
+		return allowed
+	}
+
+	query := c.Request.URL.Query()
+	namespace := query.Get("namespace")
+	if namespace == "" || namespace == "all" {
+		query.Set("namespace", strings.Join(access.Binding.Namespaces, ","))
+		c.Request.URL.RawQuery = query.Encode()
+		allowed = true
+		return allowed
+	}
+	for _, requested := range splitNamespaces(namespace) {
+		if !access.AllowsNamespace(requested) {
+			respondErrorCode(c, http.StatusForbidden, fmt.Sprintf("user %q may not use namespace %q", access.Identity.User, requested))
+			return allowed
+		}
+	}
+	allowed = true
+	return allowed
+}
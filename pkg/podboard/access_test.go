@@ -0,0 +1,245 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newAccessTestRouter serves pods in team-a and team-b on one node, with alice and carol bound to team-a and dave
+// bound to both.
+func newAccessTestRouter(t *testing.T) (router http.Handler) {
+	t.Helper()
+	fileConfig := FileConfig{Access: AccessConfig{Bindings: []AccessBinding{
+		{Users: []string{"alice", "carol"}, Namespaces: []string{"team-a"}},
+		{Users: []string{"dave"}, Namespaces: []string{"team-a", "team-b"}},
+	}}}
+	router = newTestRouter(t, ServerConfig{TrustIdentityHeaders: true}, fileConfig,
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+		testPod("team-a", "web-a", "node-1"),
+		testPod("team-a", "worker-a", "node-1"),
+		testPod("team-b", "web-b", "node-1"),
+	)
+	return router
+}
+
+// asUser returns the identity headers of user.
+func asUser(user string) (headers map[string]string) {
+	headers = map[string]string{DefaultUserHeader: user}
+	return headers
+}
+
+// TestAccessMiddlewareNamespaces keeps users bound to namespaces within them.
+func TestAccessMiddlewareNamespaces(t *testing.T) {
+	router := newAccessTestRouter(t)
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		recorder := serve(router, http.MethodGet, "/api/pods?namespace=team-a", nil)
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	})
+
+	t.Run("listing defaults to bound namespaces", func(t *testing.T) {
+		recorder := serve(router, http.MethodGet, "/api/pods?namespace=all", asUser("alice"))
+		require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+		assert.ElementsMatch(t, []string{"web-a", "worker-a"}, podNames(t, decodeBody(t, recorder)))
+	})
+
+	t.Run("other namespaces are forbidden", func(t *testing.T) {
+		for _, target := range []string{
+			"/api/pods?namespace=team-b",
+			"/api/pods?namespace=team-a,team-b",
+			"/api/pods/team-b/web-b",
+		} {
+			recorder := serve(router, http.MethodGet, target, asUser("alice"))
+			assert.Equal(t, http.StatusForbidden, recorder.Code, target)
+		}
+
+		recorder := serve(router, http.MethodDelete, "/api/pods/team-b/web-b", asUser("alice"))
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+	})
+
+	t.Run("unbound users see every namespace", func(t *testing.T) {
+		recorder := serve(router, http.MethodGet, "/api/pods?namespace=all", asUser("bob"))
+		require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+		assert.ElementsMatch(t, []string{"web-a", "worker-a", "web-b"}, podNames(t, decodeBody(t, recorder)))
+	})
+}
+
+// TestAccessMiddlewareSingleNamespaceRoutes makes users bound to several namespaces name one on routes that take a
+// single namespace, instead of passing their binding's list on as one namespace.
+func TestAccessMiddlewareSingleNamespaceRoutes(t *testing.T) {
+	router := newAccessTestRouter(t)
+
+	recorder := serve(router, http.MethodGet, "/api/pods", asUser("dave"))
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.ElementsMatch(t, []string{"web-a", "worker-a", "web-b"}, podNames(t, decodeBody(t, recorder)))
+
+	tests := []struct {
+		name   string
+		user   string
+		target string
+		code   int
+	}{
+		{name: "delta of the binding", user: "dave", target: "/api/pods/delta", code: http.StatusBadRequest},
+		{name: "delta of one namespace", user: "dave", target: "/api/pods/delta?namespace=team-b", code: http.StatusOK},
+		{name: "events of the binding", user: "dave", target: "/api/events?namespace=all", code: http.StatusBadRequest},
+		{name: "events of one namespace", user: "dave", target: "/api/events?namespace=team-a", code: http.StatusOK},
+		{name: "preemptions of a list", user: "dave", target: "/api/preemptions?namespace=team-a,team-b", code: http.StatusBadRequest},
+		{name: "certificates of the binding", user: "dave", target: "/api/certificates", code: http.StatusBadRequest},
+		{name: "single namespace binding", user: "alice", target: "/api/events", code: http.StatusOK},
+		{name: "unbound list", user: "bob", target: "/api/events?namespace=team-a,team-b", code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := serve(router, http.MethodGet, tt.target, asUser(tt.user))
+			assert.Equal(t, tt.code, recorder.Code, recorder.Body.String())
+		})
+	}
+}
+
+// TestAccessMiddlewareNodes limits users bound to namespaces to their pods on nodes, and keeps them from acting on
+// whole nodes.
+func TestAccessMiddlewareNodes(t *testing.T) {
+	router := newAccessTestRouter(t)
+
+	recorder := serve(router, http.MethodGet, "/api/nodes/node-1/pods", asUser("alice"))
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.ElementsMatch(t, []string{"web-a", "worker-a"}, podNames(t, decodeBody(t, recorder)))
+
+	recorder = serve(router, http.MethodGet, "/api/nodes/node-1/pods", asUser("bob"))
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.ElementsMatch(t, []string{"web-a", "worker-a", "web-b"}, podNames(t, decodeBody(t, recorder)))
+
+	for _, route := range []struct {
+		method string
+		target string
+	}{
+		{http.MethodPut, "/api/nodes/node-1/cordon"},
+		{http.MethodPut, "/api/nodes/node-1/uncordon"},
+		{http.MethodPost, "/api/nodes/node-1/drain"},
+		{http.MethodPost, "/api/migrations"},
+		{http.MethodGet, "/api/migrations/some-migration"},
+	} {
+		recorder = serve(router, route.method, route.target, asUser("alice"))
+		assert.Equal(t, http.StatusForbidden, recorder.Code, "%s %s", route.method, route.target)
+	}
+
+	recorder = serve(router, http.MethodPut, "/api/nodes/node-1/cordon", asUser("bob"))
+	assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+}
+
+// TestAccessMiddlewareRecords hides the jobs and pending actions of other users from users bound to namespaces.
+func TestAccessMiddlewareRecords(t *testing.T) {
+	router := newAccessTestRouter(t)
+
+	recorder := serve(router, http.MethodDelete, "/api/pods/team-a/web-a?undoWindow=10m", asUser("alice"))
+	require.Equal(t, http.StatusAccepted, recorder.Code, recorder.Body.String())
+	action, ok := decodeBody(t, recorder)["pendingAction"].(map[string]interface{})
+	require.True(t, ok)
+	actionID, _ := action["id"].(string)
+	require.NotEmpty(t, actionID)
+
+	recorder = serve(router, http.MethodDelete, "/api/pods?namespace=team-a&labelSelector=app%3Dworker-a&async=true", asUser("alice"))
+	require.Equal(t, http.StatusAccepted, recorder.Code, recorder.Body.String())
+	job, ok := decodeBody(t, recorder)["job"].(map[string]interface{})
+	require.True(t, ok)
+	jobID, _ := job["id"].(string)
+	require.NotEmpty(t, jobID)
+
+	t.Run("owner sees their records", func(t *testing.T) {
+		recorder := serve(router, http.MethodGet, "/api/pending-actions", asUser("alice"))
+		assert.Len(t, decodeBody(t, recorder)["pendingActions"], 1)
+
+		recorder = serve(router, http.MethodGet, "/api/jobs", asUser("alice"))
+		assert.Len(t, decodeBody(t, recorder)["jobs"], 1)
+
+		recorder = serve(router, http.MethodGet, "/api/jobs/"+jobID, asUser("alice"))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	})
+
+	t.Run("other users bound to the namespace don't", func(t *testing.T) {
+		recorder := serve(router, http.MethodGet, "/api/pending-actions", asUser("carol"))
+		assert.Empty(t, decodeBody(t, recorder)["pendingActions"])
+
+		recorder = serve(router, http.MethodGet, "/api/jobs", asUser("carol"))
+		assert.Empty(t, decodeBody(t, recorder)["jobs"])
+
+		for _, route := range []struct {
+			method string
+			target string
+		}{
+			{http.MethodPost, "/api/pending-actions/" + actionID + "/run"},
+			{http.MethodDelete, "/api/pending-actions/" + actionID},
+			{http.MethodGet, "/api/jobs/" + jobID},
+			{http.MethodDelete, "/api/jobs/" + jobID},
+		} {
+			recorder = serve(router, route.method, route.target, asUser("carol"))
+			assert.Equal(t, http.StatusNotFound, recorder.Code, "%s %s", route.method, route.target)
+		}
+	})
+
+	t.Run("unbound users see every record", func(t *testing.T) {
+		recorder := serve(router, http.MethodGet, "/api/pending-actions", asUser("bob"))
+		assert.Len(t, decodeBody(t, recorder)["pendingActions"], 1)
+	})
+
+	t.Run("owner can still cancel", func(t *testing.T) {
+		recorder := serve(router, http.MethodDelete, "/api/pending-actions/"+actionID, asUser("alice"))
+		assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	})
+}
+
+// TestAccessPolicy validates bindings and resolves users to the first binding naming them or their groups.
+func TestAccessPolicy(t *testing.T) {
+	_, err := NewAccessPolicy(AccessConfig{Bindings: []AccessBinding{{Users: []string{"alice"}}}}, false)
+	require.ErrorIs(t, err, ErrInvalidAccess, "bindings need authenticated users")
+
+	_, err = NewAccessPolicy(AccessConfig{Bindings: []AccessBinding{{Namespaces: []string{"team-a"}}}}, true)
+	require.ErrorIs(t, err, ErrInvalidAccess, "bindings must name users or groups")
+
+	_, err = NewAccessPolicy(AccessConfig{Bindings: []AccessBinding{{Users: []string{"alice"}, Namespaces: []string{"all"}}}}, true)
+	require.ErrorIs(t, err, ErrInvalidAccess, "namespaces must be plain names")
+
+	policy, err := NewAccessPolicy(AccessConfig{Bindings: []AccessBinding{
+		{Groups: []string{"team-a"}, Namespaces: []string{"team-a"}},
+		{Users: []string{AccessAnyUser}, Namespaces: []string{"sandbox"}},
+	}}, true)
+	require.NoError(t, err)
+
+	access := policy.Resolve(Identity{User: "alice", Groups: []string{"team-a"}})
+	assert.True(t, access.AllowsNamespace("team-a"))
+	assert.False(t, access.AllowsNamespace("sandbox"))
+	assert.True(t, access.AllowsRecord("alice", "team-a"))
+	assert.False(t, access.AllowsRecord("alice", "team-a,sandbox"))
+	assert.False(t, access.AllowsRecord("bob", "team-a"))
+
+	access = policy.Resolve(Identity{User: "bob"})
+	assert.Equal(t, []string{"sandbox"}, access.FilterNamespaces([]string{"team-a", "sandbox"}))
+}
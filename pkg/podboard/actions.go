@@ -0,0 +1,356 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ActionMethodLink opens the rendered URL in the browser instead of calling it from the server.
+	ActionMethodLink = "link"
+	// ActionKindPod targets pods.
+	ActionKindPod = "pod"
+	// ActionKindDeployment targets deployments.
+	ActionKindDeployment = "deployment"
+
+	actionHTTPTimeout     = 30 * time.Second
+	actionMaxResponseBody = 4096
+)
+
+// validActionMethods are the HTTP methods server-side actions may use.
+//
+//nolint:gochecknoglobals // Read-only lookup table
+var validActionMethods = map[string]bool{
+	http.MethodGet:    true,
+	http.MethodPost:   true,
+	http.MethodPut:    true,
+	http.MethodPatch:  true,
+	http.MethodDelete: true,
+}
+
+// ErrActionNotFound is returned when an action is not configured for the requested kind.
+var ErrActionNotFound = errors.New("action not found")
+
+// ActionConfig is a custom action shown on pod or deployment rows.
+// The URL, body and header values are Go templates rendered with an ActionTarget.
+type ActionConfig struct {
+	Name    string            `json:"name" yaml:"name"`
+	Kind    string            `json:"kind" yaml:"kind"`
+	Method  string            `json:"method" yaml:"method"`
+	URL     string            `json:"-" yaml:"url"`
+	Body    string            `json:"-" yaml:"body"`
+	Headers map[string]string `json:"-" yaml:"headers"`
+	Confirm string            `json:"confirm,omitempty" yaml:"confirm"`
+}
+
+// ActionTarget is the data available to action templates.
+type ActionTarget struct {
+	Cluster     string
+	Namespace   string
+	Name        string
+	Kind        string
+	Labels      map[string]string
+	Annotations map[string]string
+	Node        string
+	Images      []string
+	User        string
+	RequestID   string
+}
+
+// ActionResult is the outcome of running an action. Link actions only return the URL to open.
+type ActionResult struct {
+	Action string `json:"action"`
+	URL    string `json:"url,omitempty"`
+	Status int    `json:"status,omitempty"`
+	Body   string `json:"body,omitempty"`
+}
+
+// action is a validated ActionConfig with its templates parsed.
+type action struct {
+	config  ActionConfig
+	url     *template.Template
+	body    *template.Template
+	headers map[string]*template.Template
+}
+
+// ActionService runs the custom actions from the config file.
+type ActionService struct {
+	config            ServerConfig
+	actions           []action
+	kubeConfigService ClientFactory
+	httpClient        *http.Client
+	logger            *zap.Logger
+}
+
+// NewActionService validates the configured actions and creates a new action service.
+func NewActionService(config ServerConfig, actions []ActionConfig, kubeConfigService ClientFactory, logger *zap.Logger) (service *ActionService, err error) {
+	service = &ActionService{
+		config:            config,
+		kubeConfigService: kubeConfigService,
+		httpClient:        &http.Client{Timeout: actionHTTPTimeout},
+		logger:            logger,
+	}
+
+	seen := make(map[string]bool)
+	for _, actionConfig := range actions {
+		var parsed action
+		parsed, err = parseAction(actionConfig)
+		if err != nil {
+			return service, err
+		}
+
+		key := parsed.config.Kind + "/" + parsed.config.Name
+		if seen[key] {
+			err = fmt.Errorf("action %q: duplicate name for kind %s", parsed.config.Name, parsed.config.Kind)
+			return service, err
+		}
+		seen[key] = true
+
+		service.actions = append(service.actions, parsed)
+	}
+
+	return service, err
+}
+
+// parseAction applies defaults to an action and parses its templates.
+func parseAction(config ActionConfig) (parsed action, err error) {
+	if config.Name == "" {
+		err = errors.New("action with empty name")
+		return parsed, err
+	}
+	if config.Kind == "" {
+		config.Kind = ActionKindPod
+	}
+	if config.Kind != ActionKindPod && config.Kind != ActionKindDeployment {
+		err = fmt.Errorf("action %q: kind must be %q or %q", config.Name, ActionKindPod, ActionKindDeployment)
+		return parsed, err
+	}
+	config.Method = strings.ToUpper(config.Method)
+	if config.Method == "" || config.Method == strings.ToUpper(ActionMethodLink) {
+		config.Method = ActionMethodLink
+	}
+	if config.Method != ActionMethodLink && !validActionMethods[config.Method] {
+		err = fmt.Errorf("action %q: unsupported method %q", config.Name, config.Method)
+		return parsed, err
+	}
+	if config.URL == "" {
+		err = fmt.Errorf("action %q: url is required", config.Name)
+		return parsed, err
+	}
+
+	parsed = action{config: config, headers: make(map[string]*template.Template)}
+	parsed.url, err = template.New("url").Option("missingkey=zero").Parse(config.URL)
+	if err != nil {
+		err = fmt.Errorf("action %q: invalid url template: %w", config.Name, err)
+		return parsed, err
+	}
+	parsed.body, err = template.New("body").Option("missingkey=zero").Parse(config.Body)
+	if err != nil {
+		err = fmt.Errorf("action %q: invalid body template: %w", config.Name, err)
+		return parsed, err
+	}
+	for name, value := range config.Headers {
+		parsed.headers[name], err = template.New(name).Option("missingkey=zero").Parse(value)
+		if err != nil {
+			err = fmt.Errorf("action %q: invalid header %s template: %w", config.Name, name, err)
+			return parsed, err
+		}
+	}
+
+	return parsed, err
+}
+
+// Actions returns the configured actions.
+func (as *ActionService) Actions() (actions []ActionConfig) {
+	actions = make([]ActionConfig, 0, len(as.actions))
+	for _, configured := range as.actions {
+		actions = append(actions, configured.config)
+	}
+	return actions
+}
+
+// find returns the named action for a kind, or nil if it isn't configured.
+func (as *ActionService) find(kind, actionName string) (selected *action) {
+	for i := range as.actions {
+		if as.actions[i].config.Kind == kind && as.actions[i].config.Name == actionName {
+			selected = &as.actions[i]
+			return selected
+		}
+	}
+	return selected
+}
+
+// hasAction reports whether the named action is configured for a kind.
+func (as *ActionService) hasAction(kind, actionName string) (exists bool) {
+	exists = as.find(kind, actionName) != nil
+	return exists
+}
+
+// Run renders the named action for a pod or deployment and either returns its link or performs the request.
+// The target is read with the caller's Kubernetes identity, so users can only run actions on objects they can see.
+func (as *ActionService) Run(ctx context.Context, clusterName, kind, actionName, namespace, name string) (result ActionResult, err error) {
+	selected := as.find(kind, actionName)
+	if selected == nil {
+		err = fmt.Errorf("%w: %s action %q", ErrActionNotFound, kind, actionName)
+		return result, err
+	}
+
+	var target ActionTarget
+	target, err = as.getTarget(ctx, clusterName, kind, namespace, name)
+	if err != nil {
+		return result, err
+	}
+
+	result = ActionResult{Action: actionName}
+	result.URL, err = renderTemplate(selected.url, target)
+	if err != nil {
+		return result, err
+	}
+
+	as.logger.Info("Running action", zap.String("action", actionName), zap.String("user", target.User), zap.String("requestId", target.RequestID), zap.String("cluster", clusterName), zap.String("kind", kind), zap.String("namespace", namespace), zap.String("name", name))
+
+	if selected.config.Method == ActionMethodLink {
+		return result, err
+	}
+
+	err = as.config.CheckOutbound("action " + actionName)
+	if err != nil {
+		return result, err
+	}
+
+	result.Status, result.Body, err = as.call(ctx, selected, target, result.URL)
+	return result, err
+}
+
+// getTarget reads the object an action runs against.
+func (as *ActionService) getTarget(ctx context.Context, clusterName, kind, namespace, name string) (target ActionTarget, err error) {
+	var client kubernetes.Interface
+	client, err = as.kubeConfigService.GetClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return target, err
+	}
+
+	target = ActionTarget{Cluster: clusterName, Kind: kind, User: identityUser(ctx), RequestID: requestID(ctx)}
+
+	var meta metav1.ObjectMeta
+	var containers []corev1.Container
+	switch kind {
+	case ActionKindDeployment:
+		var deployment *appsv1.Deployment
+		deployment, err = client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			err = fmt.Errorf("failed to get deployment %s/%s: %w", namespace, name, err)
+			return target, err
+		}
+		meta = deployment.ObjectMeta
+		containers = deployment.Spec.Template.Spec.Containers
+	default:
+		var pod *corev1.Pod
+		pod, err = client.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			err = fmt.Errorf("failed to get pod %s/%s: %w", namespace, name, err)
+			return target, err
+		}
+		meta = pod.ObjectMeta
+		containers = pod.Spec.Containers
+		target.Node = pod.Spec.NodeName
+	}
+
+	target.Namespace = meta.Namespace
+	target.Name = meta.Name
+	target.Labels = meta.Labels
+	target.Annotations = meta.Annotations
+	for _, container := range containers {
+		target.Images = append(target.Images, container.Image)
+	}
+
+	return target, err
+}
+
+// call performs the HTTP request for a server-side action and returns the status and a truncated body.
+func (as *ActionService) call(ctx context.Context, selected *action, target ActionTarget, url string) (status int, body string, err error) {
+	var requestBody string
+	requestBody, err = renderTemplate(selected.body, target)
+	if err != nil {
+		return status, body, err
+	}
+
+	var request *http.Request
+	request, err = http.NewRequestWithContext(ctx, selected.config.Method, url, bytes.NewBufferString(requestBody))
+	if err != nil {
+		err = fmt.Errorf("action %q: %w", selected.config.Name, err)
+		return status, body, err
+	}
+	for name, headerTemplate := range selected.headers {
+		var value string
+		value, err = renderTemplate(headerTemplate, target)
+		if err != nil {
+			return status, body, err
+		}
+		request.Header.Set(name, value)
+	}
+	if request.Header.Get(RequestIDHeader) == "" {
+		setRequestIDHeader(request)
+	}
+
+	var response *http.Response
+	response, err = as.httpClient.Do(request)
+	if err != nil {
+		err = fmt.Errorf("action %q: %w", selected.config.Name, err)
+		return status, body, err
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	content, _ := io.ReadAll(io.LimitReader(response.Body, actionMaxResponseBody))
+	status = response.StatusCode
+	body = string(content)
+	return status, body, err
+}
+
+// renderTemplate executes a template with the action target.
+func renderTemplate(tmpl *template.Template, target ActionTarget) (rendered string, err error) {
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, target)
+	if err != nil {
+		err = fmt.Errorf("failed to render %s template: %w", tmpl.Name(), err)
+		return rendered, err
+	}
+	rendered = buf.String()
+	return rendered, err
+}
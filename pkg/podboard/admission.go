@@ -0,0 +1,133 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+)
+
+// eventReasonFailedCreate is the reason of the event a ReplicaSet records when it can't create a pod.
+const eventReasonFailedCreate = "FailedCreate"
+
+// Admission webhook failures as reported by the API server. A webhook either denies the request or can't be
+// called at all, which also rejects pods when the webhook's failure policy is Fail.
+//
+//nolint:gochecknoglobals // Compiled once
+var (
+	webhookDeniedPattern  = regexp.MustCompile(`admission webhook "([^"]+)" denied the request:?\s*(.*)`)
+	webhookCallingPattern = regexp.MustCompile(`failed calling webhook "([^"]+)":\s*(.*)`)
+)
+
+// CreateFailure explains why a workload can't create its pods, such as a rejection by an admission webhook or
+// an exceeded quota. Webhook and WebhookMessage are set when an admission webhook is the cause, and Quota when
+// a ResourceQuota is.
+type CreateFailure struct {
+	Source         string         `json:"source"`
+	Object         string         `json:"object"`
+	Message        string         `json:"message"`
+	Webhook        string         `json:"webhook,omitempty"`
+	WebhookMessage string         `json:"webhookMessage,omitempty"`
+	Quota          *QuotaExceeded `json:"quota,omitempty"`
+	LastSeen       string         `json:"lastSeen,omitempty"`
+}
+
+// newCreateFailure builds a CreateFailure, picking the webhook name and its message or the exceeded quota
+// out of the error.
+func newCreateFailure(source, object, message string) (failure *CreateFailure) {
+	failure = &CreateFailure{Source: source, Object: object, Message: message, Quota: parseQuotaExceeded(message)}
+	match := webhookDeniedPattern.FindStringSubmatch(message)
+	if match == nil {
+		match = webhookCallingPattern.FindStringSubmatch(message)
+	}
+	if match != nil {
+		failure.Webhook, failure.WebhookMessage = match[1], match[2]
+	}
+	return failure
+}
+
+// deploymentCreateFailure returns the pod creation failure the deployment controller copied from its
+// ReplicaSet into the ReplicaFailure condition, or nil.
+func deploymentCreateFailure(deployment *appsv1.Deployment, view timeView) (failure *CreateFailure) {
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentReplicaFailure && condition.Status == corev1.ConditionTrue {
+			failure = newCreateFailure("condition", KindDeployment+"/"+deployment.Name, condition.Message)
+			failure.LastSeen = view.format(condition.LastUpdateTime.Time)
+			return failure
+		}
+	}
+	return failure
+}
+
+// attachReplicaSetCreateFailures explains deployments that are short of replicas without a ReplicaFailure
+// condition using their ReplicaSets' FailedCreate events, which the condition may lag behind or miss.
+// Events are only listed when some deployment is short, and failing to list them is not an error.
+func (ds *DeploymentService) attachReplicaSetCreateFailures(ctx context.Context, client kubernetes.Interface, clusterName, namespace string, infos []DeploymentInfo) {
+	short := make(map[string]int)
+	for i := range infos {
+		if infos[i].CreateFailure == nil && infos[i].CurrentReplicas < infos[i].DesiredReplicas {
+			short[infos[i].Namespace+"/"+infos[i].Name] = i
+		}
+	}
+	if len(short) == 0 {
+		return
+	}
+
+	selector := fields.Set{"involvedObject.kind": KindReplicaSet, "reason": eventReasonFailedCreate}.AsSelector().String()
+	events, err := client.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		ds.logger.Debug("Failed to list replica set events", zap.Error(err), zap.String("cluster", clusterName), zap.String("namespace", namespace))
+		return
+	}
+
+	sort.SliceStable(events.Items, func(i, j int) (less bool) {
+		less = eventLastSeen(&events.Items[i]).After(eventLastSeen(&events.Items[j]))
+		return less
+	})
+
+	view := timeViewFromContext(ctx)
+	for i := range events.Items {
+		event := &events.Items[i]
+		// ReplicaSets are named after their deployment plus the pod template hash.
+		cut := strings.LastIndex(event.InvolvedObject.Name, "-")
+		if cut < 0 {
+			continue
+		}
+		index, exists := short[event.Namespace+"/"+event.InvolvedObject.Name[:cut]]
+		if !exists || infos[index].CreateFailure != nil {
+			continue
+		}
+		failure := newCreateFailure("event", KindReplicaSet+"/"+event.InvolvedObject.Name, event.Message)
+		failure.LastSeen = eventToEventInfo(event, view).LastSeen
+		infos[index].CreateFailure = failure
+	}
+}
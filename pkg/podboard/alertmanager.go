@@ -0,0 +1,434 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	alertmanagerHTTPTimeout      = 10 * time.Second
+	defaultAlertNamespaceLabel   = "namespace"
+	defaultSilenceMinutes        = 60
+	defaultMaxSilenceMinutes     = 24 * 60
+	alertmanagerSilenceActive    = "active"
+	alertmanagerSilenceCreatedBy = "podboard"
+)
+
+// ErrAlertmanagerDisabled is returned when no Alertmanager is configured.
+var ErrAlertmanagerDisabled = errors.New("alertmanager integration is not configured")
+
+// ErrInvalidSilence is returned for silence requests that can't be created.
+var ErrInvalidSilence = errors.New("invalid silence")
+
+// AlertmanagerConfig connects podboard to the Alertmanager receiving the cluster's alerts.
+type AlertmanagerConfig struct {
+	// URL is the base URL of Alertmanager. Empty disables the integration.
+	URL string `yaml:"url"`
+	// Username authenticates with basic auth together with the token. Without it the token is sent as a
+	// bearer token.
+	Username string `yaml:"username"`
+	// TokenEnv names the environment variable holding the password or token, keeping it out of the file.
+	TokenEnv string `yaml:"tokenEnv"`
+	// NamespaceLabel is the alert label naming the namespace (default namespace).
+	NamespaceLabel string `yaml:"namespaceLabel"`
+	// ClusterLabel is the alert label naming the cluster, for an Alertmanager shared by several clusters.
+	// Alerts and silences are then matched to the selected cluster too.
+	ClusterLabel string `yaml:"clusterLabel"`
+	// DefaultSilenceMinutes is used when a silence request doesn't say how long (default 60).
+	DefaultSilenceMinutes int `yaml:"defaultSilenceMinutes"`
+	// MaxSilenceMinutes is the longest silence podboard creates (default 1440).
+	MaxSilenceMinutes int `yaml:"maxSilenceMinutes"`
+}
+
+// Alert is an alert firing in Alertmanager. State is active, or suppressed while silenced or inhibited.
+type Alert struct {
+	Fingerprint string            `json:"fingerprint"`
+	Name        string            `json:"name"`
+	Severity    string            `json:"severity,omitempty"`
+	State       string            `json:"state"`
+	Summary     string            `json:"summary,omitempty"`
+	StartsAt    string            `json:"startsAt"`
+	Labels      map[string]string `json:"labels"`
+	SilencedBy  []string          `json:"silencedBy,omitempty"`
+}
+
+// Silence is an active Alertmanager silence.
+type Silence struct {
+	ID        string           `json:"id"`
+	Matchers  []SilenceMatcher `json:"matchers"`
+	StartsAt  string           `json:"startsAt"`
+	EndsAt    string           `json:"endsAt"`
+	CreatedBy string           `json:"createdBy"`
+	Comment   string           `json:"comment"`
+}
+
+// SilenceMatcher matches alerts by a label, as in Alertmanager's API.
+type SilenceMatcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"isRegex"`
+	IsEqual bool   `json:"isEqual"`
+}
+
+// NamespaceAlerts are the alerts firing in a namespace and the silences affecting it.
+type NamespaceAlerts struct {
+	Alerts   []Alert   `json:"alerts"`
+	Silences []Silence `json:"silences"`
+}
+
+// SilenceRequest silences a workload's alerts for a number of minutes. AlertName limits the silence to one
+// alert.
+type SilenceRequest struct {
+	Minutes   int    `json:"minutes,omitempty"`
+	Comment   string `json:"comment"`
+	AlertName string `json:"alertName,omitempty"`
+}
+
+// AlertmanagerService reads alerts and silences from Alertmanager and creates silences for workloads.
+type AlertmanagerService struct {
+	config       ServerConfig
+	alertmanager AlertmanagerConfig
+	httpClient   *http.Client
+	logger       *zap.Logger
+}
+
+// alertmanagerAlert is an alert as returned by Alertmanager's v2 API.
+type alertmanagerAlert struct {
+	Fingerprint string            `json:"fingerprint"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    time.Time         `json:"startsAt"`
+	Status      struct {
+		State      string   `json:"state"`
+		SilencedBy []string `json:"silencedBy"`
+	} `json:"status"`
+}
+
+// alertmanagerSilence is a silence as returned by Alertmanager's v2 API.
+type alertmanagerSilence struct {
+	ID        string           `json:"id"`
+	Matchers  []SilenceMatcher `json:"matchers"`
+	StartsAt  time.Time        `json:"startsAt"`
+	EndsAt    time.Time        `json:"endsAt"`
+	CreatedBy string           `json:"createdBy"`
+	Comment   string           `json:"comment"`
+	Status    struct {
+		State string `json:"state"`
+	} `json:"status"`
+}
+
+// NewAlertmanagerService validates the Alertmanager configuration and creates a new Alertmanager service.
+func NewAlertmanagerService(config ServerConfig, alertmanager AlertmanagerConfig, logger *zap.Logger) (service *AlertmanagerService, err error) {
+	if alertmanager.NamespaceLabel == "" {
+		alertmanager.NamespaceLabel = defaultAlertNamespaceLabel
+	}
+	if alertmanager.DefaultSilenceMinutes == 0 {
+		alertmanager.DefaultSilenceMinutes = defaultSilenceMinutes
+	}
+	if alertmanager.MaxSilenceMinutes == 0 {
+		alertmanager.MaxSilenceMinutes = defaultMaxSilenceMinutes
+	}
+	alertmanager.URL = strings.TrimSuffix(alertmanager.URL, "/")
+
+	service = &AlertmanagerService{
+		config:       config,
+		alertmanager: alertmanager,
+		httpClient:   &http.Client{Timeout: alertmanagerHTTPTimeout},
+		logger:       logger,
+	}
+
+	if alertmanager.URL == "" {
+		return service, err
+	}
+	_, err = url.ParseRequestURI(alertmanager.URL)
+	if err != nil {
+		err = fmt.Errorf("alertmanager: invalid url: %w", err)
+		return service, err
+	}
+	if alertmanager.DefaultSilenceMinutes < 1 || alertmanager.MaxSilenceMinutes < alertmanager.DefaultSilenceMinutes {
+		err = errors.New("alertmanager: defaultSilenceMinutes must be between 1 and maxSilenceMinutes")
+		return service, err
+	}
+	return service, err
+}
+
+// Enabled reports whether an Alertmanager is configured.
+func (as *AlertmanagerService) Enabled() (enabled bool) {
+	enabled = as.alertmanager.URL != ""
+	return enabled
+}
+
+// NamespaceAlerts returns the alerts firing in the namespace, newest first, including silenced and inhibited
+// ones, and the active silences naming the namespace or silencing one of its alerts. The namespace may be a
+// comma-separated list or all.
+func (as *AlertmanagerService) NamespaceAlerts(ctx context.Context, clusterName, namespace string) (result NamespaceAlerts, err error) {
+	err = as.check()
+	if err != nil {
+		return result, err
+	}
+
+	query := url.Values{}
+	query.Set("active", "true")
+	query.Set("silenced", "true")
+	query.Set("inhibited", "true")
+	for _, filter := range as.filters(clusterName, namespace) {
+		query.Add("filter", filter)
+	}
+
+	var alerts []alertmanagerAlert
+	err = as.do(ctx, http.MethodGet, "/api/v2/alerts?"+query.Encode(), nil, &alerts)
+	if err != nil {
+		return result, err
+	}
+
+	var silences []alertmanagerSilence
+	err = as.do(ctx, http.MethodGet, "/api/v2/silences", nil, &silences)
+	if err != nil {
+		return result, err
+	}
+
+	sort.SliceStable(alerts, func(i, j int) (less bool) {
+		less = alerts[i].StartsAt.After(alerts[j].StartsAt)
+		return less
+	})
+
+	view := timeViewFromContext(ctx)
+	result = NamespaceAlerts{Alerts: make([]Alert, 0, len(alerts)), Silences: make([]Silence, 0)}
+	silencing := make(map[string]bool)
+	for _, alert := range alerts {
+		result.Alerts = append(result.Alerts, Alert{
+			Fingerprint: alert.Fingerprint,
+			Name:        alert.Labels["alertname"],
+			Severity:    alert.Labels["severity"],
+			State:       alert.Status.State,
+			Summary:     alert.Annotations["summary"],
+			StartsAt:    view.format(alert.StartsAt),
+			Labels:      alert.Labels,
+			SilencedBy:  alert.Status.SilencedBy,
+		})
+		for _, id := range alert.Status.SilencedBy {
+			silencing[id] = true
+		}
+	}
+	for _, silence := range silences {
+		if silence.Status.State != alertmanagerSilenceActive {
+			continue
+		}
+		if !silencing[silence.ID] && !as.silenceNamesNamespace(silence, namespace) {
+			continue
+		}
+		result.Silences = append(result.Silences, Silence{
+			ID:        silence.ID,
+			Matchers:  silence.Matchers,
+			StartsAt:  view.format(silence.StartsAt),
+			EndsAt:    view.format(silence.EndsAt),
+			CreatedBy: silence.CreatedBy,
+			Comment:   silence.Comment,
+		})
+	}
+	return result, err
+}
+
+// SilenceWorkload silences the alerts of a Deployment, StatefulSet or DaemonSet. The silence matches the
+// namespace label and the workload label kube-state-metrics uses for the kind, such as deployment="api".
+func (as *AlertmanagerService) SilenceWorkload(ctx context.Context, clusterName, kind, namespace, name string, request SilenceRequest) (silence Silence, err error) {
+	err = as.check()
+	if err != nil {
+		return silence, err
+	}
+
+	minutes := request.Minutes
+	if minutes == 0 {
+		minutes = as.alertmanager.DefaultSilenceMinutes
+	}
+	if minutes < 1 || minutes > as.alertmanager.MaxSilenceMinutes {
+		err = fmt.Errorf("%w: minutes must be between 1 and %d", ErrInvalidSilence, as.alertmanager.MaxSilenceMinutes)
+		return silence, err
+	}
+	if strings.TrimSpace(request.Comment) == "" {
+		err = fmt.Errorf("%w: a comment is required", ErrInvalidSilence)
+		return silence, err
+	}
+
+	matchers := []SilenceMatcher{
+		{Name: as.alertmanager.NamespaceLabel, Value: namespace, IsEqual: true},
+		{Name: strings.ToLower(kind), Value: name, IsEqual: true},
+	}
+	if as.alertmanager.ClusterLabel != "" && clusterName != "" {
+		matchers = append(matchers, SilenceMatcher{Name: as.alertmanager.ClusterLabel, Value: clusterName, IsEqual: true})
+	}
+	if request.AlertName != "" {
+		matchers = append(matchers, SilenceMatcher{Name: "alertname", Value: request.AlertName, IsEqual: true})
+	}
+
+	createdBy := identityUser(ctx)
+	if createdBy == "" {
+		createdBy = alertmanagerSilenceCreatedBy
+	}
+	view := timeViewFromContext(ctx)
+	startsAt := view.clock.Now()
+	endsAt := startsAt.Add(time.Duration(minutes) * time.Minute)
+	body := map[string]interface{}{
+		"matchers":  matchers,
+		"startsAt":  startsAt.UTC().Format(time.RFC3339),
+		"endsAt":    endsAt.UTC().Format(time.RFC3339),
+		"createdBy": createdBy,
+		"comment":   request.Comment,
+	}
+
+	var response struct {
+		SilenceID string `json:"silenceID"`
+	}
+	err = as.do(ctx, http.MethodPost, "/api/v2/silences", body, &response)
+	if err != nil {
+		return silence, err
+	}
+
+	silence = Silence{
+		ID:        response.SilenceID,
+		Matchers:  matchers,
+		StartsAt:  view.format(startsAt),
+		EndsAt:    view.format(endsAt),
+		CreatedBy: createdBy,
+		Comment:   request.Comment,
+	}
+	as.logger.Info("Created silence", zap.String("silence", silence.ID), zap.String("cluster", clusterName), zap.String("kind", kind), zap.String("namespace", namespace), zap.String("name", name), zap.Int("minutes", minutes), zap.String("user", createdBy), zap.String("requestId", requestID(ctx)))
+	return silence, err
+}
+
+// check returns an error when Alertmanager isn't configured or offline mode forbids calling it.
+func (as *AlertmanagerService) check() (err error) {
+	if !as.Enabled() {
+		err = ErrAlertmanagerDisabled
+		return err
+	}
+	err = as.config.CheckOutbound("alertmanager")
+	return err
+}
+
+// filters returns the Alertmanager label filters selecting alerts in the namespace and cluster.
+func (as *AlertmanagerService) filters(clusterName, namespace string) (filters []string) {
+	if as.alertmanager.ClusterLabel != "" && clusterName != "" {
+		filters = append(filters, fmt.Sprintf("%s=%q", as.alertmanager.ClusterLabel, clusterName))
+	}
+
+	switch {
+	case namespace == "" || namespace == "all":
+	case strings.Contains(namespace, ","):
+		namespaces := splitNamespaces(namespace)
+		for i := range namespaces {
+			namespaces[i] = regexp.QuoteMeta(namespaces[i])
+		}
+		filters = append(filters, fmt.Sprintf("%s=~%q", as.alertmanager.NamespaceLabel, strings.Join(namespaces, "|")))
+	default:
+		filters = append(filters, fmt.Sprintf("%s=%q", as.alertmanager.NamespaceLabel, namespace))
+	}
+	return filters
+}
+
+// silenceNamesNamespace reports whether a silence has an equality matcher on one of the namespaces. For all
+// namespaces every silence counts.
+func (as *AlertmanagerService) silenceNamesNamespace(silence alertmanagerSilence, namespace string) (names bool) {
+	if namespace == "" || namespace == "all" {
+		names = true
+		return names
+	}
+	for _, matcher := range silence.Matchers {
+		if matcher.Name == as.alertmanager.NamespaceLabel && matcher.IsEqual && !matcher.IsRegex && slices.Contains(splitNamespaces(namespace), matcher.Value) {
+			names = true
+			return names
+		}
+	}
+	return names
+}
+
+// do sends a request to Alertmanager's API and decodes the JSON response.
+func (as *AlertmanagerService) do(ctx context.Context, method, path string, body, result interface{}) (err error) {
+	var payload io.Reader
+	if body != nil {
+		var encoded []byte
+		encoded, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(encoded)
+	}
+
+	var request *http.Request
+	request, err = http.NewRequestWithContext(ctx, method, as.alertmanager.URL+path, payload)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	setRequestIDHeader(request)
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	token := ""
+	if as.alertmanager.TokenEnv != "" {
+		token = os.Getenv(as.alertmanager.TokenEnv)
+	}
+	switch {
+	case as.alertmanager.Username != "":
+		request.SetBasicAuth(as.alertmanager.Username, token)
+	case token != "":
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	var response *http.Response
+	response, err = as.httpClient.Do(request)
+	if err != nil {
+		err = fmt.Errorf("alertmanager request failed: %w", err)
+		return err
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(response.Body, actionMaxResponseBody))
+		err = fmt.Errorf("alertmanager request failed: %s: %s", response.Status, strings.TrimSpace(string(detail)))
+		return err
+	}
+
+	err = json.NewDecoder(response.Body).Decode(result)
+	if err != nil {
+		err = fmt.Errorf("failed to parse alertmanager response: %w", err)
+		return err
+	}
+	return err
+}
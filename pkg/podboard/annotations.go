@@ -0,0 +1,140 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultAnnotationMaxValueBytes is the default limit on each annotation value in pod details.
+	DefaultAnnotationMaxValueBytes = 1024
+	// DefaultAnnotationMaxTotalBytes is the default limit on all of a pod's annotation values together.
+	DefaultAnnotationMaxTotalBytes = 16384
+	// lastAppliedAnnotation holds the whole manifest kubectl apply last applied, which is always redacted:
+	// it is big, repeats the spec, and can carry secrets.
+	lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+	// truncationMarker ends annotation values that were cut short.
+	truncationMarker = "…"
+)
+
+// AnnotationConfig limits the pod annotations shown in pod details.
+type AnnotationConfig struct {
+	// MaxValueBytes cuts each value short at this many bytes (default 1024), negative for no limit.
+	MaxValueBytes int `yaml:"maxValueBytes"`
+	// MaxTotalBytes cuts the values short once they add up to this many bytes (default 16384), negative for
+	// no limit. Annotations are counted in key order.
+	MaxTotalBytes int `yaml:"maxTotalBytes"`
+	// Redact are regular expressions matched against annotation keys; matching annotations are left out.
+	// kubectl.kubernetes.io/last-applied-configuration is always left out.
+	Redact []string `yaml:"redact"`
+}
+
+// AnnotationFilter redacts and truncates pod annotations.
+type AnnotationFilter struct {
+	maxValueBytes int
+	maxTotalBytes int
+	redact        []*regexp.Regexp
+}
+
+// NewAnnotationFilter compiles the annotation limits and redaction patterns.
+func NewAnnotationFilter(config AnnotationConfig) (filter *AnnotationFilter, err error) {
+	filter = &AnnotationFilter{maxValueBytes: config.MaxValueBytes, maxTotalBytes: config.MaxTotalBytes}
+	if filter.maxValueBytes == 0 {
+		filter.maxValueBytes = DefaultAnnotationMaxValueBytes
+	}
+	if filter.maxTotalBytes == 0 {
+		filter.maxTotalBytes = DefaultAnnotationMaxTotalBytes
+	}
+
+	patterns := append([]string{"^" + regexp.QuoteMeta(lastAppliedAnnotation) + "$"}, config.Redact...)
+	for _, pattern := range patterns {
+		var compiled *regexp.Regexp
+		compiled, err = regexp.Compile(pattern)
+		if err != nil {
+			err = fmt.Errorf("annotations: invalid redact pattern %q: %w", pattern, err)
+			return filter, err
+		}
+		filter.redact = append(filter.redact, compiled)
+	}
+	return filter, err
+}
+
+// Apply returns the annotations without the redacted ones, with values cut short to the limits, along with
+// the keys that were redacted and the keys whose values were truncated.
+func (filter *AnnotationFilter) Apply(annotations map[string]string) (filtered map[string]string, redacted []string, truncated []string) {
+	if len(annotations) == 0 {
+		return filtered, redacted, truncated
+	}
+
+	keys := make([]string, 0, len(annotations))
+	for key := range annotations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	filtered = make(map[string]string, len(annotations))
+	remaining := filter.maxTotalBytes
+	for _, key := range keys {
+		if filter.redacts(key) {
+			redacted = append(redacted, key)
+			continue
+		}
+
+		value := annotations[key]
+		limit := filter.maxValueBytes
+		if filter.maxTotalBytes >= 0 && (limit < 0 || remaining < limit) {
+			limit = remaining
+		}
+		if limit >= 0 && len(value) > limit {
+			value = truncateUTF8(value, limit) + truncationMarker
+			truncated = append(truncated, key)
+		}
+		remaining -= min(len(value), remaining)
+		filtered[key] = value
+	}
+	return filtered, redacted, truncated
+}
+
+// redacts reports whether an annotation is left out.
+func (filter *AnnotationFilter) redacts(key string) (redacts bool) {
+	for _, pattern := range filter.redact {
+		if pattern.MatchString(key) {
+			redacts = true
+			return redacts
+		}
+	}
+	return redacts
+}
+
+// truncateUTF8 cuts s to at most limit bytes without splitting a UTF-8 character.
+func truncateUTF8(s string, limit int) (truncated string) {
+	for limit > 0 && !utf8.RuneStart(s[limit]) {
+		limit--
+	}
+	truncated = s[:limit]
+	return truncated
+}
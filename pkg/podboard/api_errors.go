@@ -0,0 +1,130 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// errorStatus maps an error to the HTTP status and Kubernetes status reason it's reported with, so clients can
+// tell a missing object from an RBAC denial or an unreachable cluster. Errors that aren't from the Kubernetes
+// API, or that it doesn't classify, are internal errors.
+func errorStatus(err error) (code int, reason metav1.StatusReason) {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, ErrClusterUnreachable):
+		code = http.StatusServiceUnavailable
+		reason = metav1.StatusReasonServiceUnavailable
+		return code, reason
+	case errors.Is(err, ErrNamespaceDenied):
+		code = http.StatusForbidden
+		reason = metav1.StatusReasonForbidden
+		return code, reason
+	case apierrors.IsNotFound(err):
+		code = http.StatusNotFound
+	case apierrors.IsForbidden(err):
+		code = http.StatusForbidden
+	case apierrors.IsUnauthorized(err):
+		code = http.StatusUnauthorized
+	case apierrors.IsTimeout(err), apierrors.IsServerTimeout(err), errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		code = http.StatusGatewayTimeout
+		reason = metav1.StatusReasonTimeout
+		return code, reason
+	case apierrors.IsConflict(err), apierrors.IsAlreadyExists(err):
+		code = http.StatusConflict
+	case apierrors.IsInvalid(err):
+		code = http.StatusUnprocessableEntity
+	case apierrors.IsBadRequest(err):
+		code = http.StatusBadRequest
+	case apierrors.IsTooManyRequests(err):
+		code = http.StatusTooManyRequests
+	case apierrors.IsServiceUnavailable(err):
+		code = http.StatusServiceUnavailable
+	default:
+		code = http.StatusInternalServerError
+		reason = metav1.StatusReasonInternalError
+		return code, reason
+	}
+
+	reason = apierrors.ReasonForError(err)
+	return code, reason
+}
+
+// statusReason is the Kubernetes status reason matching an HTTP status, for errors podboard raises itself.
+func statusReason(code int) (reason metav1.StatusReason) {
+	switch code {
+	case http.StatusBadRequest:
+		reason = metav1.StatusReasonBadRequest
+	case http.StatusUnauthorized:
+		reason = metav1.StatusReasonUnauthorized
+	case http.StatusForbidden:
+		reason = metav1.StatusReasonForbidden
+	case http.StatusNotFound:
+		reason = metav1.StatusReasonNotFound
+	case http.StatusConflict:
+		reason = metav1.StatusReasonConflict
+	case http.StatusGone:
+		reason = metav1.StatusReasonExpired
+	case http.StatusUnprocessableEntity:
+		reason = metav1.StatusReasonInvalid
+	case http.StatusTooManyRequests:
+		reason = metav1.StatusReasonTooManyRequests
+	case http.StatusServiceUnavailable:
+		reason = metav1.StatusReasonServiceUnavailable
+	case http.StatusGatewayTimeout:
+		reason = metav1.StatusReasonTimeout
+	default:
+		reason = metav1.StatusReasonInternalError
+	}
+	return reason
+}
+
+// errorBody is the envelope every error response uses: the message, the HTTP status, the Kubernetes status
+// reason and, when the request named one, the cluster.
+func errorBody(c *gin.Context, code int, reason metav1.StatusReason, message string) (body gin.H) {
+	body = gin.H{"error": message, "code": code, "reason": reason}
+	clusterName := c.GetString(clusterContextKey)
+	if clusterName != "" {
+		body["cluster"] = clusterName
+	}
+	return body
+}
+
+// respondError aborts the request with err, using the status and reason errorStatus maps it to.
+func respondError(c *gin.Context, err error) {
+	code, reason := errorStatus(err)
+	c.AbortWithStatusJSON(code, errorBody(c, code, reason, err.Error()))
+}
+
+// respondErrorCode aborts the request with a message and an HTTP status chosen by the caller.
+func respondErrorCode(c *gin.Context, code int, message string) {
+	c.AbortWithStatusJSON(code, errorBody(c, code, statusReason(code), message))
+}
@@ -0,0 +1,275 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/client-go/rest"
+)
+
+// DefaultKubernetesRetries is how many times the podboard command retries a read that failed transiently.
+const DefaultKubernetesRetries = 2
+
+const (
+	// retryBaseDelay is the delay before the first retry, doubled for each retry after it.
+	retryBaseDelay = 100 * time.Millisecond
+	// retryMaxDelay caps the delay between retries. A Retry-After asking for longer isn't waited for.
+	retryMaxDelay = 2 * time.Second
+	// retryBudgetRatio is the share of a cluster's requests that may be retried, so retries can't pile onto a
+	// cluster that is failing outright.
+	retryBudgetRatio = 0.2
+	// retryBudgetBurst is how many retries a cluster's budget holds when full.
+	retryBudgetBurst = 10
+)
+
+// RetryStats counts the retries of reads from a cluster. Recovered reads succeeded after a retry, Exhausted
+// reads still failed after the last one, and Throttled reads weren't retried because the budget was spent.
+type RetryStats struct {
+	Retried   int64 `json:"retried"`
+	Retries   int64 `json:"retries"`
+	Recovered int64 `json:"recovered"`
+	Exhausted int64 `json:"exhausted"`
+	Throttled int64 `json:"throttled"`
+}
+
+// RetryTracker holds the retry budget and counts of each cluster.
+type RetryTracker struct {
+	mu         sync.Mutex
+	maxRetries int
+	clusters   map[string]*clusterRetries
+}
+
+// clusterRetries is the retry budget and counts of one cluster.
+type clusterRetries struct {
+	budget float64
+	stats  RetryStats
+}
+
+// NewRetryTracker creates a tracker retrying each read up to maxRetries times, or returns nil when maxRetries
+// isn't positive.
+func NewRetryTracker(maxRetries int) (tracker *RetryTracker) {
+	if maxRetries <= 0 {
+		return tracker
+	}
+	tracker = &RetryTracker{maxRetries: maxRetries, clusters: make(map[string]*clusterRetries)}
+	return tracker
+}
+
+// Stats returns the retry counts of a cluster, or nil without a tracker.
+func (tracker *RetryTracker) Stats(clusterName string) (stats *RetryStats) {
+	if tracker == nil {
+		return stats
+	}
+
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	stats = &RetryStats{}
+	if retries, exists := tracker.clusters[clusterName]; exists {
+		*stats = retries.stats
+	}
+	return stats
+}
+
+// cluster returns the budget and counts of a cluster. The caller holds mu.
+func (tracker *RetryTracker) cluster(clusterName string) (retries *clusterRetries) {
+	retries, exists := tracker.clusters[clusterName]
+	if !exists {
+		retries = &clusterRetries{budget: retryBudgetBurst}
+		tracker.clusters[clusterName] = retries
+	}
+	return retries
+}
+
+// earn adds a request's share to the cluster's retry budget.
+func (tracker *RetryTracker) earn(clusterName string) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	retries := tracker.cluster(clusterName)
+	retries.budget = min(retries.budget+retryBudgetRatio, retryBudgetBurst)
+}
+
+// spend takes a retry from the cluster's budget, returning false when it is spent. first marks the first retry
+// of a read.
+func (tracker *RetryTracker) spend(clusterName string, first bool) (allowed bool) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	retries := tracker.cluster(clusterName)
+	if retries.budget < 1 {
+		retries.stats.Throttled++
+		return allowed
+	}
+	retries.budget--
+	retries.stats.Retries++
+	if first {
+		retries.stats.Retried++
+	}
+	allowed = true
+	return allowed
+}
+
+// done records the outcome of a read that was retried.
+func (tracker *RetryTracker) done(clusterName string, recovered bool) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	retries := tracker.cluster(clusterName)
+	if recovered {
+		retries.stats.Recovered++
+		return
+	}
+	retries.stats.Exhausted++
+}
+
+// retryTransport retries reads that failed transiently, with exponential backoff and jitter, within the
+// cluster's retry budget.
+type retryTransport struct {
+	next        http.RoundTripper
+	tracker     *RetryTracker
+	clusterName string
+}
+
+// withRetries makes the clients created from restConfig retry transient failures of their reads from
+// clusterName. Without a tracker they are left as they are.
+func withRetries(restConfig *rest.Config, tracker *RetryTracker, clusterName string) {
+	if tracker == nil {
+		return
+	}
+	restConfig.Wrap(func(next http.RoundTripper) (wrapped http.RoundTripper) {
+		wrapped = &retryTransport{next: next, tracker: tracker, clusterName: clusterName}
+		return wrapped
+	})
+}
+
+// RoundTrip sends the request, retrying gets and lists that failed transiently. Writes, long-running requests
+// and requests whose body can't be replayed are sent once, since retrying them isn't safe or isn't possible.
+func (transport *retryTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	if (req.Method != http.MethodGet && req.Method != http.MethodHead) || longRunningRequest(req) || !replayable {
+		resp, err = transport.next.RoundTrip(req)
+		return resp, err
+	}
+
+	transport.tracker.earn(transport.clusterName)
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if attempt > 0 && req.GetBody != nil {
+			attemptReq, err = replayRequest(req)
+			if err != nil {
+				transport.tracker.done(transport.clusterName, false)
+				return resp, err
+			}
+		}
+		resp, err = transport.next.RoundTrip(attemptReq)
+		delay, retry := retryDelay(req.Context(), resp, err, attempt)
+		if !retry {
+			if attempt > 0 {
+				transport.tracker.done(transport.clusterName, err == nil)
+			}
+			return resp, err
+		}
+		if attempt == transport.tracker.maxRetries || !transport.tracker.spend(transport.clusterName, attempt == 0) {
+			if attempt > 0 {
+				transport.tracker.done(transport.clusterName, false)
+			}
+			return resp, err
+		}
+
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			resp = nil
+			err = req.Context().Err()
+			return resp, err
+		case <-timer.C:
+		}
+	}
+}
+
+// replayRequest returns a copy of req with a fresh body for a retry, since the first attempt consumed its own.
+func replayRequest(req *http.Request) (replay *http.Request, err error) {
+	var body io.ReadCloser
+	body, err = req.GetBody()
+	if err != nil {
+		return replay, err
+	}
+	replay = req.Clone(req.Context())
+	replay.Body = body
+	return replay, err
+}
+
+// retryDelay returns whether a read that ended with resp and err is worth retrying, and how long to wait
+// before the given retry. Throttling, gateway errors and dropped connections are transient; timeouts aren't
+// retried, since the request already waited out its timeout.
+func retryDelay(ctx context.Context, resp *http.Response, err error, attempt int) (delay time.Duration, retry bool) {
+	if ctx.Err() != nil {
+		return delay, retry
+	}
+
+	switch {
+	case err != nil:
+		retry = !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, ErrClusterUnreachable) &&
+			(utilnet.IsProbableEOF(err) || utilnet.IsConnectionReset(err) || utilnet.IsConnectionRefused(err))
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusBadGateway,
+		resp.StatusCode == http.StatusServiceUnavailable, resp.StatusCode == http.StatusGatewayTimeout:
+		retry = true
+	}
+	if !retry {
+		return delay, retry
+	}
+
+	delay = min(retryBaseDelay<<attempt, retryMaxDelay)
+	// Full jitter on the upper half keeps clients that failed together from retrying together.
+	delay = delay/2 + rand.N(delay/2+1) //nolint:gosec // Jitter doesn't need a secure source
+	if resp == nil {
+		return delay, retry
+	}
+
+	seconds, parseErr := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if parseErr == nil && seconds > 0 {
+		requested := time.Duration(seconds) * time.Second
+		if requested > retryMaxDelay {
+			retry = false
+			return delay, retry
+		}
+		delay = max(delay, requested)
+	}
+	return delay, retry
+}
@@ -0,0 +1,223 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package podboard
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryDelay(t *testing.T) {
+	response := func(code int, retryAfter string) (resp *http.Response) {
+		resp = &http.Response{StatusCode: code, Header: http.Header{}}
+		if retryAfter != "" {
+			resp.Header.Set("Retry-After", retryAfter)
+		}
+		return resp
+	}
+
+	tests := []struct {
+		name     string
+		resp     *http.Response
+		err      error
+		attempt  int
+		retry    bool
+		minDelay time.Duration
+		maxDelay time.Duration
+	}{
+		{name: "success", resp: response(http.StatusOK, "")},
+		{name: "server error", resp: response(http.StatusInternalServerError, "")},
+		{name: "not found", resp: response(http.StatusNotFound, "")},
+		{name: "throttled", resp: response(http.StatusTooManyRequests, ""), retry: true, minDelay: 50 * time.Millisecond, maxDelay: 100 * time.Millisecond},
+		{name: "unavailable", resp: response(http.StatusServiceUnavailable, ""), retry: true, minDelay: 50 * time.Millisecond, maxDelay: 100 * time.Millisecond},
+		{name: "bad gateway backs off", resp: response(http.StatusBadGateway, ""), attempt: 2, retry: true, minDelay: 200 * time.Millisecond, maxDelay: 400 * time.Millisecond},
+		{name: "backoff is capped", resp: response(http.StatusGatewayTimeout, ""), attempt: 10, retry: true, minDelay: time.Second, maxDelay: retryMaxDelay},
+		{name: "retry after", resp: response(http.StatusTooManyRequests, "1"), retry: true, minDelay: time.Second, maxDelay: time.Second},
+		{name: "retry after beyond the cap", resp: response(http.StatusTooManyRequests, "5")},
+		{name: "retry after as a date", resp: response(http.StatusServiceUnavailable, "Wed, 21 Oct 2026 07:28:00 GMT"), retry: true, minDelay: 50 * time.Millisecond, maxDelay: 100 * time.Millisecond},
+		{name: "dropped connection", err: io.ErrUnexpectedEOF, retry: true, minDelay: 50 * time.Millisecond, maxDelay: 100 * time.Millisecond},
+		{name: "timeout", err: context.DeadlineExceeded},
+		{name: "unreachable cluster", err: ErrClusterUnreachable},
+		{name: "other error", err: errors.New("certificate signed by unknown authority")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay, retry := retryDelay(t.Context(), tt.resp, tt.err, tt.attempt)
+			require.Equal(t, tt.retry, retry)
+			if retry {
+				assert.GreaterOrEqual(t, delay, tt.minDelay)
+				assert.LessOrEqual(t, delay, tt.maxDelay)
+			}
+		})
+	}
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		_, retry := retryDelay(ctx, response(http.StatusServiceUnavailable, ""), nil, 0)
+		assert.False(t, retry)
+	})
+}
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		statuses []int
+		code     int
+		requests int
+		stats    RetryStats
+	}{
+		{
+			name:     "recovered read",
+			method:   http.MethodGet,
+			target:   "/api/v1/pods",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK},
+			code:     http.StatusOK,
+			requests: 3,
+			stats:    RetryStats{Retried: 1, Retries: 2, Recovered: 1},
+		},
+		{
+			name:     "exhausted read",
+			method:   http.MethodGet,
+			target:   "/api/v1/pods",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK},
+			code:     http.StatusServiceUnavailable,
+			requests: 3,
+			stats:    RetryStats{Retried: 1, Retries: 2, Exhausted: 1},
+		},
+		{
+			name:     "permanent failure",
+			method:   http.MethodGet,
+			target:   "/api/v1/pods",
+			statuses: []int{http.StatusForbidden, http.StatusOK},
+			code:     http.StatusForbidden,
+			requests: 1,
+		},
+		{
+			name:     "write",
+			method:   http.MethodPost,
+			target:   "/api/v1/namespaces/default/pods",
+			body:     `{"kind":"Pod"}`,
+			statuses: []int{http.StatusServiceUnavailable, http.StatusOK},
+			code:     http.StatusServiceUnavailable,
+			requests: 1,
+		},
+		{
+			name:     "delete",
+			method:   http.MethodDelete,
+			target:   "/api/v1/namespaces/default/pods/web",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusOK},
+			code:     http.StatusServiceUnavailable,
+			requests: 1,
+		},
+		{
+			name:     "watch",
+			method:   http.MethodGet,
+			target:   "/api/v1/pods?watch=true",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusOK},
+			code:     http.StatusServiceUnavailable,
+			requests: 1,
+		},
+		{
+			name:     "body replayed",
+			method:   http.MethodGet,
+			target:   "/api/v1/pods",
+			body:     "query",
+			statuses: []int{http.StatusBadGateway, http.StatusOK},
+			code:     http.StatusOK,
+			requests: 2,
+			stats:    RetryStats{Retried: 1, Retries: 1, Recovered: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var bodies []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				defer mu.Unlock()
+				bodies = append(bodies, string(body))
+				w.WriteHeader(tt.statuses[len(bodies)-1])
+			}))
+			defer server.Close()
+
+			tracker := NewRetryTracker(2)
+			transport := &retryTransport{next: http.DefaultTransport, tracker: tracker, clusterName: testCluster}
+
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			req, err := http.NewRequestWithContext(t.Context(), tt.method, server.URL+tt.target, body)
+			require.NoError(t, err)
+
+			resp, err := transport.RoundTrip(req)
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+
+			assert.Equal(t, tt.code, resp.StatusCode)
+			mu.Lock()
+			defer mu.Unlock()
+			require.Len(t, bodies, tt.requests)
+			for _, received := range bodies {
+				assert.Equal(t, tt.body, received)
+			}
+			assert.Equal(t, tt.stats, *tracker.Stats(testCluster))
+		})
+	}
+}
+
+func TestRetryTransportUnreplayableBody(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	transport := &retryTransport{next: http.DefaultTransport, tracker: NewRetryTracker(2), clusterName: testCluster}
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL, io.NopCloser(strings.NewReader("query")))
+	require.NoError(t, err)
+	require.Nil(t, req.GetBody)
+
+	resp, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, 1, requests)
+}
@@ -0,0 +1,240 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Annotations a pod sets to have one of its own metrics shown in pod rows.
+const (
+	// AppMetricAnnotation names the metric to show, e.g. queue_depth.
+	AppMetricAnnotation = "podboard.io/metric"
+	// AppMetricPortAnnotation is the port name or number serving metrics (default: the first container port).
+	AppMetricPortAnnotation = "podboard.io/metric-port"
+	// AppMetricPathAnnotation is the path serving metrics in the Prometheus text format (default /metrics).
+	AppMetricPathAnnotation = "podboard.io/metric-path"
+)
+
+const (
+	defaultAppMetricPath = "/metrics"
+	// appMetricCacheTTL is how long a scraped value is reused, so polling pod listings don't scrape every pod each time.
+	appMetricCacheTTL = 15 * time.Second
+	// appMetricTimeout bounds each scrape so a slow pod can't hold up the pod listing.
+	appMetricTimeout = 2 * time.Second
+	// appMetricConcurrency is the number of pods scraped at once per listing.
+	appMetricConcurrency = 8
+)
+
+// ErrAppMetricNotFound is returned when a pod's metrics endpoint doesn't expose the declared metric.
+var ErrAppMetricNotFound = errors.New("metric not found")
+
+// AppMetric is the value of the metric a pod declared with the podboard.io/metric annotation. Series with the
+// same name but different labels are summed. Error is set instead of Value when the scrape failed.
+type AppMetric struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Error string  `json:"error,omitempty"`
+}
+
+// AppMetricService scrapes the metrics pods declare through annotations. Scrapes go through the API server's
+// pod proxy, so they need no network path to the pods and are allowed in offline mode.
+type AppMetricService struct {
+	logger  *zap.Logger
+	metrics *ttlCache[string, AppMetric]
+}
+
+// NewAppMetricService creates a new app metric service.
+func NewAppMetricService(logger *zap.Logger) (service *AppMetricService) {
+	service = &AppMetricService{
+		logger:  logger,
+		metrics: newTTLCache[string, AppMetric](appMetricCacheTTL, 0),
+	}
+	return service
+}
+
+// Attach scrapes the given pods, keyed by their index in podInfos, and sets the AppMetric of each.
+func (ams *AppMetricService) Attach(ctx context.Context, client kubernetes.Interface, clusterName string, podInfos []PodInfo, pods map[int]*corev1.Pod) {
+	semaphore := make(chan struct{}, appMetricConcurrency)
+	var wg sync.WaitGroup
+	for index, pod := range pods {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			metric := ams.scrape(ctx, client, clusterName, pod)
+			podInfos[index].AppMetric = &metric
+		}()
+	}
+	wg.Wait()
+}
+
+// scrape returns the pod's declared metric, reusing a recent scrape of the same pod.
+func (ams *AppMetricService) scrape(ctx context.Context, client kubernetes.Interface, clusterName string, pod *corev1.Pod) (metric AppMetric) {
+	identity, _ := IdentityFromContext(ctx)
+	key := clientCacheKey(clusterName, KubeContextFromContext(ctx), identity) + "|" + pod.Namespace + "/" + pod.Name + "|" + string(pod.UID)
+
+	metric, ok := ams.metrics.Get(key)
+	if ok {
+		return metric
+	}
+
+	metric.Name = strings.TrimSpace(pod.Annotations[AppMetricAnnotation])
+	value, err := scrapeAppMetric(ctx, client, pod, metric.Name)
+	if err != nil {
+		ams.logger.Debug("Failed to scrape pod metric", zap.Error(err), zap.String("cluster", clusterName), zap.String("namespace", pod.Namespace), zap.String("pod", pod.Name))
+		metric.Error = err.Error()
+	} else {
+		metric.Value = value
+	}
+
+	ams.metrics.Store(key, metric)
+	return metric
+}
+
+// declaresAppMetric reports whether a pod asks for a metric to be shown and is running, so it can be scraped.
+func declaresAppMetric(pod *corev1.Pod) (declared bool) {
+	declared = strings.TrimSpace(pod.Annotations[AppMetricAnnotation]) != "" && pod.Status.Phase == corev1.PodRunning && pod.DeletionTimestamp == nil
+	return declared
+}
+
+// scrapeAppMetric fetches the pod's metrics endpoint through the API server proxy and returns the named metric.
+func scrapeAppMetric(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod, name string) (value float64, err error) {
+	port := strings.TrimSpace(pod.Annotations[AppMetricPortAnnotation])
+	if port == "" {
+		port = firstContainerPort(pod)
+	}
+	path := strings.TrimSpace(pod.Annotations[AppMetricPathAnnotation])
+	if path == "" {
+		path = defaultAppMetricPath
+	}
+
+	scrapeCtx, cancel := context.WithTimeout(ctx, appMetricTimeout)
+	defer cancel()
+
+	var body []byte
+	body, err = client.CoreV1().Pods(pod.Namespace).ProxyGet("http", pod.Name, port, path, nil).DoRaw(scrapeCtx)
+	if err != nil {
+		err = fmt.Errorf("failed to scrape %s%s: %w", port, path, err)
+		return value, err
+	}
+
+	value, err = parseAppMetric(body, name)
+	return value, err
+}
+
+// firstContainerPort returns the first port declared by the pod's containers, or an empty string, which the
+// proxy treats as port 80.
+func firstContainerPort(pod *corev1.Pod) (port string) {
+	for _, container := range pod.Spec.Containers {
+		if len(container.Ports) > 0 {
+			port = strconv.Itoa(int(container.Ports[0].ContainerPort))
+			return port
+		}
+	}
+	return port
+}
+
+// parseAppMetric returns the sum of every series of the named metric in a Prometheus text exposition.
+func parseAppMetric(body []byte, name string) (value float64, err error) {
+	found := false
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		sampleValue, matched := metricSampleValue(scanner.Text(), name)
+		if !matched {
+			continue
+		}
+		var sample float64
+		sample, err = strconv.ParseFloat(sampleValue, 64)
+		if err != nil {
+			err = fmt.Errorf("failed to parse %s value %q: %w", name, sampleValue, err)
+			return value, err
+		}
+		value += sample
+		found = true
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		err = fmt.Errorf("failed to read metrics: %w", err)
+		return value, err
+	}
+	if !found {
+		err = fmt.Errorf("%s: %w", name, ErrAppMetricNotFound)
+		return value, err
+	}
+	// NaN and infinities can't be encoded as JSON.
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		err = fmt.Errorf("%s is %v", name, value)
+		return value, err
+	}
+	return value, err
+}
+
+// metricSampleValue returns the value of an exposition line if it is a sample of the named metric.
+func metricSampleValue(line, name string) (sampleValue string, matched bool) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "#") {
+		return sampleValue, matched
+	}
+
+	rest, hasName := strings.CutPrefix(line, name)
+	if !hasName || rest == "" {
+		return sampleValue, matched
+	}
+	switch rest[0] {
+	case '{':
+		end := strings.LastIndex(rest, "}")
+		if end < 0 {
+			return sampleValue, matched
+		}
+		rest = rest[end+1:]
+	case ' ', '\t':
+	default:
+		// A longer metric name sharing the prefix, such as queue_depth_max for queue_depth.
+		return sampleValue, matched
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return sampleValue, matched
+	}
+	sampleValue, matched = fields[0], true
+	return sampleValue, matched
+}
@@ -0,0 +1,127 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ErrInvalidBatchDelete is returned when a batch delete doesn't name a single namespace and a label selector.
+var ErrInvalidBatchDelete = errors.New("batch delete requires a namespace other than all and a labelSelector")
+
+// BatchDeleteResult lists the pods a batch delete matched and, unless it was a dry run, the outcome for each.
+// Failed maps pod names to the error deleting them.
+type BatchDeleteResult struct {
+	DryRun  bool              `json:"dryRun"`
+	Pods    []PodInfo         `json:"pods"`
+	Deleted []string          `json:"deleted,omitempty"`
+	Failed  map[string]string `json:"failed,omitempty"`
+}
+
+// DeletePods deletes every pod in a namespace matching a label selector, which may use the =~ and !~ regex operators.
+// With dryRun the matching pods are only listed, so the selection can be checked first. Pods are deleted one
+// at a time and a failure doesn't stop the others. It returns ErrInvalidBatchDelete without a namespace or
+// selector, so a mistake can't delete every pod in a namespace or the cluster.
+func (ps *PodService) DeletePods(ctx context.Context, clusterName, namespace, labelSelector string, dryRun bool) (result BatchDeleteResult, err error) {
+	result, err = ps.deletePods(ctx, clusterName, namespace, labelSelector, dryRun, func(JobProgress) {})
+	return result, err
+}
+
+// ValidateBatchDelete returns ErrInvalidBatchDelete or ErrInvalidLabelSelector for batch deletes that can't
+// be run, so a batch delete run as a job is refused up front.
+func ValidateBatchDelete(namespace, labelSelector string) (err error) {
+	if namespace == "" || namespace == "all" || labelSelector == "" {
+		err = ErrInvalidBatchDelete
+		return err
+	}
+	_, err = parsePodSelector(labelSelector)
+	return err
+}
+
+// DeletePodsJob returns a job function performing a batch delete, reporting each pod deleted. Cancelling the
+// job stops it before the next pod.
+func (ps *PodService) DeletePodsJob(clusterName, namespace, labelSelector string) (fn jobFunc) {
+	fn = func(ctx context.Context, report func(progress JobProgress)) (result interface{}, err error) {
+		result, err = ps.deletePods(ctx, clusterName, namespace, labelSelector, false, report)
+		return result, err
+	}
+	return fn
+}
+
+// deletePods performs a batch delete, calling report once the pods are matched and after each deletion.
+func (ps *PodService) deletePods(ctx context.Context, clusterName, namespace, labelSelector string, dryRun bool, report func(progress JobProgress)) (result BatchDeleteResult, err error) {
+	result.DryRun = dryRun
+	err = ValidateBatchDelete(namespace, labelSelector)
+	if err != nil {
+		return result, err
+	}
+
+	var client kubernetes.Interface
+	client, err = ps.getClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return result, err
+	}
+
+	var pods []corev1.Pod
+	pods, err = ps.listPods(ctx, client, clusterName, namespace, labelSelector, "")
+	if err != nil {
+		return result, err
+	}
+
+	view := timeViewFromContext(ctx)
+	result.Pods = make([]PodInfo, 0, len(pods))
+	for i := range pods {
+		result.Pods = append(result.Pods, ps.podToPodInfo(&pods[i], view))
+	}
+	if dryRun {
+		return result, err
+	}
+
+	report(JobProgress{Total: len(pods)})
+	for i := range pods {
+		if ctx.Err() != nil {
+			break
+		}
+		deleteErr := client.CoreV1().Pods(namespace).Delete(ctx, pods[i].Name, metav1.DeleteOptions{})
+		if deleteErr != nil {
+			if result.Failed == nil {
+				result.Failed = make(map[string]string)
+			}
+			result.Failed[pods[i].Name] = deleteErr.Error()
+		} else {
+			result.Deleted = append(result.Deleted, pods[i].Name)
+		}
+		report(JobProgress{Done: i + 1, Total: len(pods), Message: fmt.Sprintf("%d deleted, %d failed", len(result.Deleted), len(result.Failed))})
+	}
+
+	ps.logger.Info("Pods deleted by selector", zap.String("cluster", clusterName), zap.String("namespace", namespace), zap.String("labelSelector", labelSelector), zap.Int("deleted", len(result.Deleted)), zap.Int("failed", len(result.Failed)), zap.String("user", identityUser(ctx)), zap.String("requestId", requestID(ctx)))
+	return result, err
+}
@@ -0,0 +1,241 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Cache priming states reported by /api/cache/status.
+const (
+	// PrimingDisabled is reported when podboard doesn't prime on startup.
+	PrimingDisabled = "disabled"
+	// PrimingPending is a cluster whose priming hasn't started yet.
+	PrimingPending = "pending"
+	// PrimingRunning is priming that is still loading namespaces.
+	PrimingRunning = "priming"
+	// PrimingDone is priming that has loaded every namespace it could.
+	PrimingDone = "done"
+	// PrimingFailed is a cluster whose namespaces couldn't be listed.
+	PrimingFailed = "failed"
+)
+
+// cachePrimingConcurrency is how many namespaces of a cluster are loaded at once while priming.
+const cachePrimingConcurrency = 4
+
+// CachePrimingStatus is the progress of priming after startup. Ready turns true once every cluster is done,
+// whether or not all of its namespaces could be loaded.
+type CachePrimingStatus struct {
+	State      string                 `json:"state"`
+	Ready      bool                   `json:"ready"`
+	StartedAt  string                 `json:"startedAt,omitempty"`
+	FinishedAt string                 `json:"finishedAt,omitempty"`
+	Clusters   []ClusterPrimingStatus `json:"clusters"`
+}
+
+// ClusterPrimingStatus is the priming progress of one cluster: Primed of its Namespaces are loaded, and Failed
+// lists those that couldn't be.
+type ClusterPrimingStatus struct {
+	Name       string   `json:"name"`
+	State      string   `json:"state"`
+	Namespaces int      `json:"namespaces"`
+	Primed     int      `json:"primed"`
+	Failed     []string `json:"failed,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// CachePrimer loads the pods of every namespace of the clusters the UI opens on right after startup, so the
+// clients, connections, namespace lookups and metrics probes behind the first page loads are ready, and
+// reports its progress so the UI and readiness probes can wait for it.
+type CachePrimer struct {
+	podService *PodService
+	clients    ClientFactory
+	clock      Clock
+	logger     *zap.Logger
+
+	mu         sync.Mutex
+	enabled    bool
+	startedAt  time.Time
+	finishedAt time.Time
+	clusters   []*ClusterPrimingStatus
+}
+
+// NewCachePrimer creates a primer loading pods through podService. A disabled primer reports itself ready.
+func NewCachePrimer(enabled bool, podService *PodService, clients ClientFactory, clock Clock, logger *zap.Logger) (primer *CachePrimer) {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	primer = &CachePrimer{
+		podService: podService,
+		clients:    clients,
+		clock:      clock,
+		logger:     logger,
+		enabled:    enabled,
+	}
+	return primer
+}
+
+// Run primes the clusters the UI opens on: the in-cluster connection, or the current kubeconfig cluster. It
+// returns once every cluster is done or ctx is.
+func (primer *CachePrimer) Run(ctx context.Context) {
+	if !primer.enabled {
+		return
+	}
+
+	clusterNames := primer.clusterNames()
+	primer.mu.Lock()
+	primer.startedAt = primer.clock.Now()
+	for _, clusterName := range clusterNames {
+		primer.clusters = append(primer.clusters, &ClusterPrimingStatus{Name: clusterName, State: PrimingPending})
+	}
+	primer.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, cluster := range primer.clusters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			primer.primeCluster(ctx, cluster)
+		}()
+	}
+	wg.Wait()
+
+	primer.mu.Lock()
+	primer.finishedAt = primer.clock.Now()
+	primer.mu.Unlock()
+	primer.logger.Info("Primed caches", zap.Duration("duration", primer.finishedAt.Sub(primer.startedAt)))
+}
+
+// clusterNames returns the clusters to prime. In cluster, that is the in-cluster connection under the empty
+// name.
+func (primer *CachePrimer) clusterNames() (clusterNames []string) {
+	if primer.clients.IsInCluster() {
+		clusterNames = []string{""}
+		return clusterNames
+	}
+
+	clusters, err := primer.clients.GetClusters()
+	if err != nil {
+		primer.logger.Warn("Not priming caches: failed to list clusters", zap.Error(err))
+		return clusterNames
+	}
+	for _, cluster := range clusters {
+		if cluster.Current {
+			clusterNames = append(clusterNames, cluster.Name)
+		}
+	}
+	return clusterNames
+}
+
+// primeCluster loads the pods of each of the cluster's namespaces, a few at a time.
+func (primer *CachePrimer) primeCluster(ctx context.Context, cluster *ClusterPrimingStatus) {
+	primer.mu.Lock()
+	cluster.State = PrimingRunning
+	primer.mu.Unlock()
+
+	namespaces, err := primer.podService.GetNamespaces(ctx, cluster.Name)
+	if err != nil {
+		primer.mu.Lock()
+		cluster.State = PrimingFailed
+		cluster.Error = err.Error()
+		primer.mu.Unlock()
+		primer.logger.Warn("Failed to prime cluster", zap.String("cluster", cluster.Name), zap.Error(err))
+		return
+	}
+
+	primer.mu.Lock()
+	cluster.Namespaces = len(namespaces)
+	primer.mu.Unlock()
+
+	slots := make(chan struct{}, cachePrimingConcurrency)
+	var wg sync.WaitGroup
+	for _, namespace := range namespaces {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			_, podsErr := primer.podService.GetPods(ctx, cluster.Name, namespace, "", PodFilter{})
+
+			primer.mu.Lock()
+			defer primer.mu.Unlock()
+			if podsErr != nil {
+				cluster.Failed = append(cluster.Failed, namespace)
+				return
+			}
+			cluster.Primed++
+		}()
+	}
+	wg.Wait()
+
+	primer.mu.Lock()
+	cluster.State = PrimingDone
+	primer.mu.Unlock()
+}
+
+// Ready returns true once priming is done, or when it is disabled.
+func (primer *CachePrimer) Ready() (ready bool) {
+	primer.mu.Lock()
+	defer primer.mu.Unlock()
+
+	ready = !primer.enabled || !primer.finishedAt.IsZero()
+	return ready
+}
+
+// Status returns the priming progress, with times rendered in ctx's time view.
+func (primer *CachePrimer) Status(ctx context.Context) (status CachePrimingStatus) {
+	primer.mu.Lock()
+	defer primer.mu.Unlock()
+
+	status.Clusters = []ClusterPrimingStatus{}
+	switch {
+	case !primer.enabled:
+		status.State = PrimingDisabled
+		status.Ready = true
+		return status
+	case primer.startedAt.IsZero():
+		status.State = PrimingPending
+		return status
+	case primer.finishedAt.IsZero():
+		status.State = PrimingRunning
+	default:
+		status.State = PrimingDone
+		status.Ready = true
+	}
+
+	view := timeViewFromContext(ctx)
+	status.StartedAt = view.format(primer.startedAt)
+	status.FinishedAt = view.format(primer.finishedAt)
+	for _, cluster := range primer.clusters {
+		clusterStatus := *cluster
+		clusterStatus.Failed = append([]string(nil), cluster.Failed...)
+		status.Clusters = append(status.Clusters, clusterStatus)
+	}
+	return status
+}
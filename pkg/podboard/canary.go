@@ -0,0 +1,249 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Canary decisions, each run as the deployment action configured for it.
+const (
+	CanaryPromote = "promote"
+	CanaryAbort   = "abort"
+)
+
+const (
+	defaultCanaryTrackLabel  = "track"
+	defaultCanaryStableValue = "stable"
+	defaultCanaryCanaryValue = "canary"
+)
+
+var (
+	// ErrCanaryActionNotConfigured is returned for a promote or abort without an action configured for it.
+	ErrCanaryActionNotConfigured = errors.New("canary action not configured")
+	// ErrCanaryTarget is returned when the canary pods don't belong to exactly one Deployment.
+	ErrCanaryTarget = errors.New("canary pods must belong to exactly one deployment")
+)
+
+// CanaryConfig describes how stable and canary pods are told apart and what promoting or aborting a canary does.
+type CanaryConfig struct {
+	// TrackLabel is the pod label holding the track (default track).
+	TrackLabel string `yaml:"trackLabel"`
+	// StableValue is the track of stable pods (default stable).
+	StableValue string `yaml:"stableValue"`
+	// CanaryValue is the track of canary pods (default canary).
+	CanaryValue string `yaml:"canaryValue"`
+	// PromoteAction names the deployment action run against the canary Deployment to promote it.
+	PromoteAction string `yaml:"promoteAction"`
+	// AbortAction names the deployment action run against the canary Deployment to abort it.
+	AbortAction string `yaml:"abortAction"`
+}
+
+// CanaryTrack summarizes the pods of one track. Usage is averaged over the pods reporting it, so tracks of
+// different sizes compare directly.
+type CanaryTrack struct {
+	Pods             int      `json:"pods"`
+	ReadyPods        int      `json:"readyPods"`
+	Restarts         int32    `json:"restarts"`
+	WarningEvents    int32    `json:"warningEvents"`
+	AvgCPUMillicores int64    `json:"avgCpuMillicores,omitempty"`
+	AvgMemoryBytes   int64    `json:"avgMemoryBytes,omitempty"`
+	Deployments      []string `json:"deployments"`
+	podsWithUsage    int64
+	cpuMillicores    int64
+	memoryBytes      int64
+}
+
+// CanaryAnalysis compares the canary pods of a workload with its stable pods.
+type CanaryAnalysis struct {
+	Namespace     string      `json:"namespace"`
+	LabelSelector string      `json:"labelSelector"`
+	TrackLabel    string      `json:"trackLabel"`
+	Stable        CanaryTrack `json:"stable"`
+	Canary        CanaryTrack `json:"canary"`
+	// Actions are the decisions with an action configured, promote and abort.
+	Actions []string `json:"actions"`
+}
+
+// CanaryService compares canary and stable pods and runs the promote and abort hooks.
+type CanaryService struct {
+	canary        CanaryConfig
+	podService    *PodService
+	actionService *ActionService
+	logger        *zap.Logger
+}
+
+// NewCanaryService validates the canary configuration and creates a new canary service. The promote and
+// abort actions must be configured deployment actions.
+func NewCanaryService(canary CanaryConfig, podService *PodService, actionService *ActionService, logger *zap.Logger) (service *CanaryService, err error) {
+	if canary.TrackLabel == "" {
+		canary.TrackLabel = defaultCanaryTrackLabel
+	}
+	if canary.StableValue == "" {
+		canary.StableValue = defaultCanaryStableValue
+	}
+	if canary.CanaryValue == "" {
+		canary.CanaryValue = defaultCanaryCanaryValue
+	}
+	if canary.StableValue == canary.CanaryValue {
+		err = errors.New("canary: stableValue and canaryValue must differ")
+		return service, err
+	}
+
+	for _, name := range []string{canary.PromoteAction, canary.AbortAction} {
+		if name != "" && !actionService.hasAction(ActionKindDeployment, name) {
+			err = fmt.Errorf("canary: %q is not a deployment action", name)
+			return service, err
+		}
+	}
+
+	service = &CanaryService{
+		canary:        canary,
+		podService:    podService,
+		actionService: actionService,
+		logger:        logger,
+	}
+	return service, err
+}
+
+// Analyze compares the canary and stable pods matching the label selector: readiness, restarts, Warning
+// events and average resource usage, with the Deployments each track's pods belong to.
+func (cs *CanaryService) Analyze(ctx context.Context, clusterName, namespace, labelSelector string) (analysis CanaryAnalysis, err error) {
+	var pods []PodInfo
+	pods, err = cs.podService.GetPods(ctx, clusterName, namespace, labelSelector, PodFilter{})
+	if err != nil {
+		return analysis, err
+	}
+
+	var events []EventInfo
+	events, err = cs.podService.GetEvents(ctx, clusterName, namespace, corev1.EventTypeWarning, 0)
+	if err != nil {
+		return analysis, err
+	}
+	warnings := make(map[string]int32)
+	for _, event := range events {
+		warnings[event.Object] += event.Count
+	}
+
+	analysis = CanaryAnalysis{
+		Namespace:     namespace,
+		LabelSelector: labelSelector,
+		TrackLabel:    cs.canary.TrackLabel,
+		Stable:        CanaryTrack{Deployments: []string{}},
+		Canary:        CanaryTrack{Deployments: []string{}},
+		Actions:       cs.actions(),
+	}
+	for _, pod := range pods {
+		switch pod.Labels[cs.canary.TrackLabel] {
+		case cs.canary.StableValue:
+			analysis.Stable.add(pod, warnings[KindPod+"/"+pod.Name])
+		case cs.canary.CanaryValue:
+			analysis.Canary.add(pod, warnings[KindPod+"/"+pod.Name])
+		}
+	}
+	analysis.Stable.summarize()
+	analysis.Canary.summarize()
+
+	return analysis, err
+}
+
+// Decide promotes or aborts the canary by running the configured action against the Deployment of the
+// canary pods matching the label selector.
+func (cs *CanaryService) Decide(ctx context.Context, clusterName, namespace, labelSelector, decision string) (result ActionResult, err error) {
+	actionName := cs.canary.PromoteAction
+	if decision == CanaryAbort {
+		actionName = cs.canary.AbortAction
+	}
+	if actionName == "" {
+		err = fmt.Errorf("%w: %s", ErrCanaryActionNotConfigured, decision)
+		return result, err
+	}
+
+	var analysis CanaryAnalysis
+	analysis, err = cs.Analyze(ctx, clusterName, namespace, labelSelector)
+	if err != nil {
+		return result, err
+	}
+	if len(analysis.Canary.Deployments) != 1 {
+		err = fmt.Errorf("%w: found %d", ErrCanaryTarget, len(analysis.Canary.Deployments))
+		return result, err
+	}
+	deployment := analysis.Canary.Deployments[0]
+
+	result, err = cs.actionService.Run(ctx, clusterName, ActionKindDeployment, actionName, namespace, deployment)
+	if err != nil {
+		return result, err
+	}
+
+	cs.logger.Info("Canary decided", zap.String("decision", decision), zap.String("action", actionName), zap.String("cluster", clusterName), zap.String("namespace", namespace), zap.String("deployment", deployment), zap.String("user", identityUser(ctx)), zap.String("requestId", requestID(ctx)))
+	return result, err
+}
+
+// actions returns the decisions with an action configured.
+func (cs *CanaryService) actions() (actions []string) {
+	actions = make([]string, 0, 2)
+	if cs.canary.PromoteAction != "" {
+		actions = append(actions, CanaryPromote)
+	}
+	if cs.canary.AbortAction != "" {
+		actions = append(actions, CanaryAbort)
+	}
+	return actions
+}
+
+// add counts a pod and its Warning events in the track.
+func (track *CanaryTrack) add(pod PodInfo, warningEvents int32) {
+	track.Pods++
+	if podIsReady(pod) {
+		track.ReadyPods++
+	}
+	track.Restarts += pod.Restarts
+	track.WarningEvents += warningEvents
+
+	if pod.Usage != nil {
+		track.podsWithUsage++
+		track.cpuMillicores += pod.Usage.CPUMillicores
+		track.memoryBytes += pod.Usage.MemoryBytes
+	}
+
+	if pod.OwnerKind == KindDeployment && !slices.Contains(track.Deployments, pod.OwnerName) {
+		track.Deployments = append(track.Deployments, pod.OwnerName)
+	}
+}
+
+// summarize sorts the track's Deployments and averages the usage of the pods that reported usage.
+func (track *CanaryTrack) summarize() {
+	sort.Strings(track.Deployments)
+	if track.podsWithUsage == 0 {
+		return
+	}
+	track.AvgCPUMillicores = track.cpuMillicores / track.podsWithUsage
+	track.AvgMemoryBytes = track.memoryBytes / track.podsWithUsage
+}
@@ -0,0 +1,317 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultCertificateWarnDays is how many days before expiry a certificate is reported as expiring.
+const DefaultCertificateWarnDays = 30
+
+const hoursPerDay = 24
+
+// CertificateInfo is the expiry of the leaf certificate in a TLS Secret, with the objects referencing it. Error
+// is set for referenced Secrets that are missing, unreadable or hold no parsable certificate.
+type CertificateInfo struct {
+	Namespace       string   `json:"namespace"`
+	Secret          string   `json:"secret"`
+	Subject         string   `json:"subject,omitempty"`
+	DNSNames        []string `json:"dnsNames,omitempty"`
+	NotAfter        string   `json:"notAfter,omitempty"`
+	DaysUntilExpiry int      `json:"daysUntilExpiry"`
+	Expiring        bool     `json:"expiring"`
+	Expired         bool     `json:"expired"`
+	ReferencedBy    []string `json:"referencedBy"`
+	Error           string   `json:"error,omitempty"`
+}
+
+// NamespaceCertificates summarizes the certificates of a namespace. SoonestDaysUntilExpiry is the days left
+// on the first certificate to expire.
+type NamespaceCertificates struct {
+	Namespace              string `json:"namespace"`
+	Certificates           int    `json:"certificates"`
+	Expiring               int    `json:"expiring"`
+	Expired                int    `json:"expired"`
+	SoonestDaysUntilExpiry *int   `json:"soonestDaysUntilExpiry,omitempty"`
+}
+
+// CertificateService finds the TLS Secrets in use and reports when their certificates expire.
+type CertificateService struct {
+	kubeConfigService ClientFactory
+	logger            *zap.Logger
+}
+
+// NewCertificateService creates a new certificate service.
+func NewCertificateService(kubeConfigService ClientFactory, logger *zap.Logger) (service *CertificateService) {
+	service = &CertificateService{
+		kubeConfigService: kubeConfigService,
+		logger:            logger,
+	}
+	return service
+}
+
+// GetCertificates returns the certificates of the TLS Secrets referenced by Ingresses and pod volumes, soonest
+// to expire first. Only referenced Secrets are read, and Secrets that aren't of the kubernetes.io/tls type are
+// skipped. Certificates expire within warnDays are marked as expiring. Use namespace="all" for every namespace.
+func (cs *CertificateService) GetCertificates(ctx context.Context, clusterName, namespace string, warnDays int) (certificates []CertificateInfo, err error) {
+	var client kubernetes.Interface
+	client, err = cs.kubeConfigService.GetClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return certificates, err
+	}
+
+	queryNamespace := namespace
+	if namespace == "all" {
+		queryNamespace = ""
+	}
+
+	var references map[string][]string
+	references, err = tlsSecretReferences(ctx, client, queryNamespace)
+	if err != nil {
+		cs.logger.Error("Failed to find TLS secret references", zap.Error(err), zap.String("cluster", clusterName), zap.String("namespace", namespace))
+		return certificates, err
+	}
+
+	view := timeViewFromContext(ctx)
+	now := view.clock.Now()
+	certificates = make([]CertificateInfo, 0, len(references))
+	for key, referencedBy := range references {
+		secretNamespace, secretName, _ := strings.Cut(key, "/")
+		fromIngress := slices.ContainsFunc(referencedBy, func(referrer string) (ingress bool) {
+			ingress = strings.HasPrefix(referrer, "Ingress/")
+			return ingress
+		})
+		certificate, isTLS := cs.readCertificate(ctx, client, secretNamespace, secretName, fromIngress)
+		if !isTLS {
+			continue
+		}
+		sort.Strings(referencedBy)
+		certificate.ReferencedBy = referencedBy
+
+		if certificate.Error == "" {
+			certificate.DaysUntilExpiry = int(certificate.notAfter.Sub(now).Hours() / hoursPerDay)
+			certificate.Expired = !now.Before(certificate.notAfter)
+			certificate.Expiring = certificate.Expired || certificate.DaysUntilExpiry < warnDays
+			certificate.NotAfter = view.format(certificate.notAfter)
+		}
+		certificates = append(certificates, certificate.CertificateInfo)
+	}
+
+	// Broken references first, then the soonest to expire, so the certificates needing attention lead.
+	sort.SliceStable(certificates, func(i, j int) (less bool) {
+		a, b := certificates[i], certificates[j]
+		if (a.Error != "") != (b.Error != "") {
+			less = a.Error != ""
+			return less
+		}
+		if a.DaysUntilExpiry != b.DaysUntilExpiry {
+			less = a.DaysUntilExpiry < b.DaysUntilExpiry
+			return less
+		}
+		less = a.Namespace+"/"+a.Secret < b.Namespace+"/"+b.Secret
+		return less
+	})
+
+	return certificates, err
+}
+
+// SummarizeCertificates counts certificates per namespace, ordered by namespace.
+func SummarizeCertificates(certificates []CertificateInfo) (summaries []NamespaceCertificates) {
+	summaries = make([]NamespaceCertificates, 0)
+	index := make(map[string]int)
+	for _, certificate := range certificates {
+		i, exists := index[certificate.Namespace]
+		if !exists {
+			i = len(summaries)
+			index[certificate.Namespace] = i
+			summaries = append(summaries, NamespaceCertificates{Namespace: certificate.Namespace})
+		}
+
+		summary := &summaries[i]
+		summary.Certificates++
+		if certificate.Error != "" {
+			continue
+		}
+		if certificate.Expiring {
+			summary.Expiring++
+		}
+		if certificate.Expired {
+			summary.Expired++
+		}
+		if summary.SoonestDaysUntilExpiry == nil || certificate.DaysUntilExpiry < *summary.SoonestDaysUntilExpiry {
+			days := certificate.DaysUntilExpiry
+			summary.SoonestDaysUntilExpiry = &days
+		}
+	}
+
+	sort.Slice(summaries, func(i, j int) (less bool) {
+		less = summaries[i].Namespace < summaries[j].Namespace
+		return less
+	})
+	return summaries
+}
+
+// parsedCertificate is a certificate with its expiry as a time, before it is rendered for the response.
+type parsedCertificate struct {
+	CertificateInfo
+	notAfter time.Time
+}
+
+// readCertificate reads a referenced Secret and parses the leaf certificate in its tls.crt. isTLS is false for
+// Secrets of another type, such as pod volumes holding credentials, which aren't reported. Secrets that can't
+// be read are reported when an Ingress references them, since Ingresses only reference TLS Secrets.
+func (cs *CertificateService) readCertificate(ctx context.Context, client kubernetes.Interface, namespace, name string, fromIngress bool) (certificate parsedCertificate, isTLS bool) {
+	certificate.Namespace = namespace
+	certificate.Secret = name
+
+	secret, err := client.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		isTLS = fromIngress
+		certificate.Error = fmt.Sprintf("failed to read secret: %s", err)
+		if apierrors.IsNotFound(err) {
+			certificate.Error = "secret not found"
+		}
+		return certificate, isTLS
+	}
+	if secret.Type != corev1.SecretTypeTLS {
+		return certificate, isTLS
+	}
+	isTLS = true
+
+	var leaf *x509.Certificate
+	leaf, err = parseLeafCertificate(secret.Data[corev1.TLSCertKey])
+	if err != nil {
+		certificate.Error = err.Error()
+		return certificate, isTLS
+	}
+
+	certificate.Subject = leaf.Subject.CommonName
+	certificate.DNSNames = leaf.DNSNames
+	certificate.notAfter = leaf.NotAfter
+	return certificate, isTLS
+}
+
+// parseLeafCertificate parses the first certificate of a PEM bundle, which is the server's own certificate.
+func parseLeafCertificate(data []byte) (leaf *x509.Certificate, err error) {
+	for len(data) > 0 {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		leaf, err = x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			err = fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		return leaf, err
+	}
+
+	err = errors.New("no certificate in tls.crt")
+	return leaf, err
+}
+
+// tlsSecretReferences returns the Secrets referenced by Ingress TLS sections and pod volumes, keyed by
+// namespace/name, with the objects referencing each as Kind/name. Pods are included so certificates mounted
+// by applications terminating TLS themselves are covered; their Secrets are filtered by type when read.
+func tlsSecretReferences(ctx context.Context, client kubernetes.Interface, namespace string) (references map[string][]string, err error) {
+	references = make(map[string][]string)
+	add := func(secretNamespace, secretName, referrer string) {
+		key := secretNamespace + "/" + secretName
+		for _, existing := range references[key] {
+			if existing == referrer {
+				return
+			}
+		}
+		references[key] = append(references[key], referrer)
+	}
+
+	var ingresses *networkingv1.IngressList
+	ingresses, err = client.NetworkingV1().Ingresses(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		err = fmt.Errorf("failed to list ingresses: %w", err)
+		return references, err
+	}
+	for _, ingress := range ingresses.Items {
+		for _, tls := range ingress.Spec.TLS {
+			if tls.SecretName != "" {
+				add(ingress.Namespace, tls.SecretName, "Ingress/"+ingress.Name)
+			}
+		}
+	}
+
+	var pods *corev1.PodList
+	pods, err = client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{ResourceVersion: "0"})
+	if err != nil {
+		err = fmt.Errorf("failed to list pods: %w", err)
+		return references, err
+	}
+	for _, pod := range pods.Items {
+		for _, secretName := range podVolumeSecrets(&pod) {
+			// Replicas share their Secrets, so pods are named by their workload where they have one.
+			kind, name := podOwner(&pod)
+			if kind == "" {
+				kind, name = KindPod, pod.Name
+			}
+			add(pod.Namespace, secretName, kind+"/"+name)
+		}
+	}
+
+	return references, err
+}
+
+// podVolumeSecrets returns the Secrets a pod mounts, directly or through projected volumes.
+func podVolumeSecrets(pod *corev1.Pod) (secretNames []string) {
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Secret != nil {
+			secretNames = append(secretNames, volume.Secret.SecretName)
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.Secret != nil {
+					secretNames = append(secretNames, source.Secret.Name)
+				}
+			}
+		}
+	}
+	return secretNames
+}
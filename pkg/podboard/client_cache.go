@@ -0,0 +1,71 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"strings"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	defaultClientCacheTTL        = 10 * time.Minute
+	defaultClientCacheMaxEntries = 256
+)
+
+// ClientCache holds Kubernetes clients per cluster and impersonated identity so requests can reuse
+// connections and credentials instead of building a clientset each time. Entries expire after the cache's
+// TTL so credentials obtained from exec plugins and auth providers are eventually refreshed.
+type ClientCache struct {
+	clients *ttlCache[string, kubernetes.Interface]
+}
+
+// NewClientCache creates a cache whose clients are reused for at most ttl, holding at most maxEntries clients.
+func NewClientCache(ttl time.Duration, maxEntries int) (cache *ClientCache) {
+	cache = &ClientCache{clients: newTTLCache[string, kubernetes.Interface](ttl, maxEntries)}
+	return cache
+}
+
+// clientCacheKey identifies a client by cluster, the kubeconfig context selecting its credentials and the
+// identity it impersonates, if any.
+func clientCacheKey(clusterName, kubeContext string, identity Identity) (key string) {
+	key = clusterName + "\x00" + kubeContext + "\x00" + identity.User + "\x00" + strings.Join(identity.Groups, ",")
+	return key
+}
+
+// Store caches a client.
+func (cc *ClientCache) Store(key string, client kubernetes.Interface) {
+	cc.clients.Store(key, client)
+}
+
+// Load returns a cached client. Clients older than the cache's TTL are not returned.
+func (cc *ClientCache) Load(key string) (client kubernetes.Interface, ok bool) {
+	client, ok = cc.clients.Get(key)
+	return client, ok
+}
+
+// Flush drops every cached client.
+func (cc *ClientCache) Flush() {
+	cc.clients.Flush()
+}
@@ -0,0 +1,134 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// ErrUnknownCluster is returned by a StaticClientFactory for clusters it has no client for.
+var ErrUnknownCluster = errors.New("unknown cluster")
+
+// ClientFactory creates the Kubernetes clients services make API calls with, and lists the clusters and
+// contexts they can be made against. KubeConfigService is the implementation reading the in-cluster
+// configuration or kubeconfig; StaticClientFactory serves fixed clients, such as fake clientsets in tests.
+type ClientFactory interface {
+	// GetClient returns a client for the named cluster. An empty name selects the current cluster.
+	GetClient(ctx context.Context, clusterName string) (client kubernetes.Interface, err error)
+	// IsInCluster reports whether there is a single in-cluster connection and no clusters to choose from.
+	IsInCluster() (inCluster bool)
+	// GetClusters returns the clusters to choose from, current cluster first.
+	GetClusters() (clusters []ClusterInfo, err error)
+	// GetContexts returns the contexts to choose from, current context first.
+	GetContexts() (contexts []ContextInfo, err error)
+	// ContextCluster returns the cluster a context points at.
+	ContextCluster(contextName string) (clusterName string, err error)
+	// HasCluster returns true if the named cluster can be chosen.
+	HasCluster(clusterName string) (exists bool, err error)
+}
+
+// StaticClientFactory is a ClientFactory serving a fixed client per cluster, each cluster also being a
+// context of the same name. Clients are shared by every caller: it does not impersonate request identities.
+type StaticClientFactory struct {
+	clients        map[string]kubernetes.Interface
+	currentCluster string
+}
+
+// NewStaticClientFactory creates a ClientFactory serving clients by cluster name, with currentCluster
+// selected when no cluster is named.
+func NewStaticClientFactory(clients map[string]kubernetes.Interface, currentCluster string) (factory *StaticClientFactory) {
+	factory = &StaticClientFactory{clients: clients, currentCluster: currentCluster}
+	return factory
+}
+
+// GetClient returns the client for the named cluster, or for the current cluster when the name is empty.
+func (factory *StaticClientFactory) GetClient(_ context.Context, clusterName string) (client kubernetes.Interface, err error) {
+	if clusterName == "" {
+		clusterName = factory.currentCluster
+	}
+
+	client, exists := factory.clients[clusterName]
+	if !exists {
+		err = fmt.Errorf("%w %q", ErrUnknownCluster, clusterName)
+		return client, err
+	}
+	return client, err
+}
+
+// IsInCluster returns false: the clusters are named and chosen between like kubeconfig clusters.
+func (factory *StaticClientFactory) IsInCluster() (inCluster bool) {
+	return inCluster
+}
+
+// GetClusters returns the clusters, current cluster first and the rest alphabetically.
+func (factory *StaticClientFactory) GetClusters() (clusters []ClusterInfo, err error) {
+	for _, name := range factory.clusterNames() {
+		clusters = append(clusters, ClusterInfo{Name: name, Current: name == factory.currentCluster})
+	}
+	return clusters, err
+}
+
+// GetContexts returns a context per cluster, named after it.
+func (factory *StaticClientFactory) GetContexts() (contexts []ContextInfo, err error) {
+	for _, name := range factory.clusterNames() {
+		contexts = append(contexts, ContextInfo{Name: name, Cluster: name, Current: name == factory.currentCluster})
+	}
+	return contexts, err
+}
+
+// ContextCluster returns the cluster of the same name as the context.
+func (factory *StaticClientFactory) ContextCluster(contextName string) (clusterName string, err error) {
+	if _, exists := factory.clients[contextName]; !exists {
+		err = fmt.Errorf("context %q not found", contextName)
+		return clusterName, err
+	}
+	clusterName = contextName
+	return clusterName, err
+}
+
+// HasCluster returns true if there is a client for the named cluster.
+func (factory *StaticClientFactory) HasCluster(clusterName string) (exists bool, err error) {
+	_, exists = factory.clients[clusterName]
+	return exists, err
+}
+
+// clusterNames returns the cluster names, current cluster first and the rest alphabetically.
+func (factory *StaticClientFactory) clusterNames() (names []string) {
+	for name := range factory.clients {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) (less bool) {
+		if (names[i] == factory.currentCluster) != (names[j] == factory.currentCluster) {
+			less = names[i] == factory.currentCluster
+			return less
+		}
+		less = names[i] < names[j]
+		return less
+	})
+	return names
+}
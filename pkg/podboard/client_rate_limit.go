@@ -0,0 +1,82 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"sync"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+const (
+	// DefaultKubernetesQPS is the sustained rate of Kubernetes API requests per cluster when running the podboard
+	// command. client-go's default of 5 is made for controllers, not a dashboard polling several views at once.
+	DefaultKubernetesQPS = 50
+	// DefaultKubernetesBurst is how many Kubernetes API requests per cluster may go above DefaultKubernetesQPS
+	// at once when running the podboard command.
+	DefaultKubernetesBurst = 100
+)
+
+// clientRateLimits hands out one rate limiter per cluster, shared by all of the cluster's clients, so users
+// with their own impersonating clients don't each add to podboard's load on the API server.
+type clientRateLimits struct {
+	qps      float32
+	burst    int
+	mu       sync.Mutex
+	limiters map[string]flowcontrol.RateLimiter
+}
+
+// newClientRateLimits limits the requests to each cluster to qps per second, with bursts of up to burst
+// requests, twice qps when burst isn't positive. Zero qps leaves client-go's defaults in place, and negative
+// qps disables rate limiting.
+func newClientRateLimits(qps float32, burst int) (limits *clientRateLimits) {
+	if burst <= 0 {
+		burst = max(int(2*qps), 1)
+	}
+	limits = &clientRateLimits{qps: qps, burst: burst, limiters: make(map[string]flowcontrol.RateLimiter)}
+	return limits
+}
+
+// apply sets restConfig's rate limit to the one shared by the clients of clusterName.
+func (limits *clientRateLimits) apply(restConfig *rest.Config, clusterName string) {
+	switch {
+	case limits == nil, limits.qps == 0:
+		return
+	case limits.qps < 0:
+		restConfig.QPS = -1
+		return
+	}
+
+	limits.mu.Lock()
+	defer limits.mu.Unlock()
+
+	limiter, exists := limits.limiters[clusterName]
+	if !exists {
+		limiter = flowcontrol.NewTokenBucketRateLimiter(limits.qps, limits.burst)
+		limits.limiters[clusterName] = limiter
+	}
+	restConfig.QPS = limits.qps
+	restConfig.Burst = limits.burst
+	restConfig.RateLimiter = limiter
+}
@@ -0,0 +1,157 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultTimeZone is the time zone timestamps are rendered in when none is configured.
+const DefaultTimeZone = "UTC"
+
+// TimeZoneHeader selects the time zone for a single request, overriding the server's time zone.
+// The tz query parameter does the same for links and downloads.
+const TimeZoneHeader = "X-Podboard-Timezone"
+
+// ErrInvalidTimeZone is returned for time zone names that aren't in the IANA time zone database.
+var ErrInvalidTimeZone = errors.New("invalid time zone")
+
+// Clock reports the current time. Ages and time windows read it instead of calling time.Now so they can be
+// computed against a fixed time in tests.
+type Clock interface {
+	Now() (now time.Time)
+}
+
+// SystemClock is the Clock backed by the system's wall clock.
+type SystemClock struct{}
+
+// Now returns the current local time.
+func (SystemClock) Now() (now time.Time) {
+	now = time.Now()
+	return now
+}
+
+// LoadTimeZone resolves an IANA time zone name such as Europe/Berlin. An empty name is UTC.
+func LoadTimeZone(name string) (location *time.Location, err error) {
+	if name == "" {
+		name = DefaultTimeZone
+	}
+
+	location, err = time.LoadLocation(name)
+	if err != nil {
+		err = fmt.Errorf("%w %q: %w", ErrInvalidTimeZone, name, err)
+		return location, err
+	}
+
+	return location, err
+}
+
+// timeView renders times for a response: ages against its clock and timestamps in its location.
+type timeView struct {
+	clock    Clock
+	location *time.Location
+}
+
+type timeViewKey struct{}
+
+// withTimeView returns a copy of ctx rendering times with the given view.
+func withTimeView(ctx context.Context, view timeView) (viewCtx context.Context) {
+	viewCtx = context.WithValue(ctx, timeViewKey{}, view)
+	return viewCtx
+}
+
+// timeViewFromContext returns the time view selected in ctx, defaulting to the system clock and UTC.
+func timeViewFromContext(ctx context.Context) (view timeView) {
+	if ctx != nil {
+		view, _ = ctx.Value(timeViewKey{}).(timeView)
+	}
+	if view.clock == nil {
+		view.clock = SystemClock{}
+	}
+	if view.location == nil {
+		view.location = time.UTC
+	}
+	return view
+}
+
+// format formats a timestamp as RFC 3339 in the view's location, or returns an empty string for the zero time.
+func (view timeView) format(t time.Time) (formatted string) {
+	if t.IsZero() {
+		return formatted
+	}
+	formatted = t.In(view.location).Format(time.RFC3339)
+	return formatted
+}
+
+// age formats the time elapsed since t by the view's clock.
+func (view timeView) age(t time.Time) (formatted string) {
+	formatted = formatDuration(view.clock.Now().Sub(t))
+	return formatted
+}
+
+// ageSeconds returns the whole seconds elapsed since t by the view's clock, the machine-readable form of age.
+func (view timeView) ageSeconds(t time.Time) (seconds int64) {
+	seconds = int64(view.clock.Now().Sub(t) / time.Second)
+	return seconds
+}
+
+// timeMiddleware attaches the time view to the request context: the configured clock, and the time zone
+// requested by the tz query parameter or TimeZoneHeader, falling back to the server's time zone.
+func timeMiddleware(config ServerConfig) (handler gin.HandlerFunc) {
+	clock := config.Clock
+	if clock == nil {
+		clock = SystemClock{}
+	}
+
+	// RunServer rejects an invalid server time zone at startup.
+	defaultLocation, err := LoadTimeZone(config.TimeZone)
+	if err != nil {
+		defaultLocation = time.UTC
+	}
+
+	handler = func(c *gin.Context) {
+		location := defaultLocation
+
+		name := c.Query("tz")
+		if name == "" {
+			name = c.GetHeader(TimeZoneHeader)
+		}
+		if name != "" {
+			requested, loadErr := LoadTimeZone(name)
+			if loadErr != nil {
+				respondErrorCode(c, 400, loadErr.Error())
+				return
+			}
+			location = requested
+		}
+
+		c.Request = c.Request.WithContext(withTimeView(c.Request.Context(), timeView{clock: clock, location: location}))
+		c.Next()
+	}
+	return handler
+}
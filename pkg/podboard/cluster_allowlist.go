@@ -0,0 +1,100 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// ErrInvalidClusterPattern is returned for cluster allowlist entries that aren't valid globs.
+var ErrInvalidClusterPattern = errors.New("invalid cluster pattern")
+
+// checkClusterPatterns returns ErrInvalidClusterPattern for patterns that aren't valid globs.
+func checkClusterPatterns(patterns []string) (err error) {
+	for _, pattern := range patterns {
+		_, err = path.Match(pattern, "")
+		if err != nil {
+			err = fmt.Errorf("%w %q: %w", ErrInvalidClusterPattern, pattern, err)
+			return err
+		}
+	}
+	return err
+}
+
+// clusterAllowed reports whether a cluster matches one of the allowlist's names or globs. An empty allowlist
+// allows every cluster. Invalid globs match nothing.
+func clusterAllowed(allowlist []string, clusterName string) (allowed bool) {
+	if len(allowlist) == 0 {
+		allowed = true
+		return allowed
+	}
+	for _, pattern := range allowlist {
+		matched, err := path.Match(pattern, clusterName)
+		if err == nil && matched {
+			allowed = true
+			return allowed
+		}
+	}
+	return allowed
+}
+
+// restrictKubeConfig removes the clusters outside the allowlist from config, along with the contexts pointing
+// at them, so they can neither be listed nor connected to. When the current context goes, the first remaining
+// context by name takes its place. It returns the names of the removed clusters.
+func restrictKubeConfig(config *clientcmdapi.Config, allowlist []string) (removed []string) {
+	if len(allowlist) == 0 {
+		return removed
+	}
+
+	for clusterName := range config.Clusters {
+		if !clusterAllowed(allowlist, clusterName) {
+			delete(config.Clusters, clusterName)
+			removed = append(removed, clusterName)
+		}
+	}
+	for contextName, kubeContext := range config.Contexts {
+		if _, exists := config.Clusters[kubeContext.Cluster]; !exists {
+			delete(config.Contexts, contextName)
+		}
+	}
+
+	if _, exists := config.Contexts[config.CurrentContext]; !exists {
+		config.CurrentContext = ""
+		contextNames := make([]string, 0, len(config.Contexts))
+		for contextName := range config.Contexts {
+			contextNames = append(contextNames, contextName)
+		}
+		sort.Strings(contextNames)
+		if len(contextNames) > 0 {
+			config.CurrentContext = contextNames[0]
+		}
+	}
+
+	sort.Strings(removed)
+	return removed
+}
@@ -0,0 +1,252 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+// Cluster health states reported by /api/clusters.
+const (
+	// ClusterStateUnknown is a cluster podboard hasn't made a request to yet.
+	ClusterStateUnknown = "unknown"
+	// ClusterStateHealthy is a cluster whose last request succeeded in good time.
+	ClusterStateHealthy = "healthy"
+	// ClusterStateDegraded is a cluster that is slow or whose latest requests failed.
+	ClusterStateDegraded = "degraded"
+	// ClusterStateUnreachable is a cluster whose circuit breaker is open: requests fail right away.
+	ClusterStateUnreachable = "unreachable"
+)
+
+const (
+	// clusterFailureThreshold is how many requests in a row have to fail to open a cluster's circuit breaker.
+	clusterFailureThreshold = 3
+	// clusterBreakerCooldown is how long an open breaker fails requests right away before letting one through
+	// to probe the cluster.
+	clusterBreakerCooldown = 30 * time.Second
+	// slowClusterLatency marks a cluster degraded when its requests take longer on average.
+	slowClusterLatency = 2 * time.Second
+	// latencySmoothing is the weight of each request's latency in a cluster's moving average.
+	latencySmoothing = 0.3
+)
+
+// ErrClusterUnreachable is returned without contacting a cluster while its circuit breaker is open.
+var ErrClusterUnreachable = errors.New("cluster unreachable")
+
+// ClusterHealth is the health of a cluster as seen by podboard's requests to it. LatencyMs is a moving average
+// of the time to a response. RetryAt is when an unreachable cluster is next probed.
+type ClusterHealth struct {
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutiveFailures,omitempty"`
+	LastError           string `json:"lastError,omitempty"`
+	LastErrorAt         string `json:"lastErrorAt,omitempty"`
+	LastSuccessAt       string `json:"lastSuccessAt,omitempty"`
+	LatencyMs           int64  `json:"latencyMs,omitempty"`
+	RetryAt             string `json:"retryAt,omitempty"`
+}
+
+// ClusterHealthTracker tracks the requests to each cluster and trips a circuit breaker on clusters that keep
+// failing, so a cluster that is down, such as one only reachable over a VPN, fails fast instead of holding up
+// every request, and the all-clusters views in particular.
+type ClusterHealthTracker struct {
+	mu       sync.Mutex
+	clock    Clock
+	clusters map[string]*clusterBreaker
+}
+
+// clusterBreaker is the request history and breaker state of one cluster.
+type clusterBreaker struct {
+	failures      int
+	lastError     string
+	lastErrorAt   time.Time
+	lastSuccessAt time.Time
+	openedAt      time.Time
+	latency       time.Duration
+	// probing is set while the one request let through an open breaker is in flight.
+	probing bool
+}
+
+// NewClusterHealthTracker creates a tracker timing requests by clock, the system clock when nil.
+func NewClusterHealthTracker(clock Clock) (tracker *ClusterHealthTracker) {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	tracker = &ClusterHealthTracker{clock: clock, clusters: make(map[string]*clusterBreaker)}
+	return tracker
+}
+
+// Health returns the health of a cluster, or nil without a tracker.
+func (tracker *ClusterHealthTracker) Health(ctx context.Context, clusterName string) (health *ClusterHealth) {
+	if tracker == nil {
+		return health
+	}
+
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	health = &ClusterHealth{State: ClusterStateUnknown}
+	breaker, exists := tracker.clusters[clusterName]
+	if !exists {
+		return health
+	}
+
+	view := timeViewFromContext(ctx)
+	health.ConsecutiveFailures = breaker.failures
+	health.LastError = breaker.lastError
+	health.LastErrorAt = view.format(breaker.lastErrorAt)
+	health.LastSuccessAt = view.format(breaker.lastSuccessAt)
+	health.LatencyMs = breaker.latency.Milliseconds()
+
+	switch {
+	case breaker.failures >= clusterFailureThreshold:
+		health.State = ClusterStateUnreachable
+		health.RetryAt = view.format(breaker.openedAt.Add(clusterBreakerCooldown))
+	case breaker.failures > 0, breaker.latency > slowClusterLatency:
+		health.State = ClusterStateDegraded
+	case !breaker.lastSuccessAt.IsZero():
+		health.State = ClusterStateHealthy
+	}
+	return health
+}
+
+// allow returns ErrClusterUnreachable while the cluster's breaker is open. Once the cooldown has passed, one
+// request is let through to probe the cluster.
+func (tracker *ClusterHealthTracker) allow(clusterName string) (err error) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	breaker, exists := tracker.clusters[clusterName]
+	if !exists || breaker.failures < clusterFailureThreshold {
+		return err
+	}
+
+	if !breaker.probing && !tracker.clock.Now().Before(breaker.openedAt.Add(clusterBreakerCooldown)) {
+		breaker.probing = true
+		return err
+	}
+
+	err = fmt.Errorf("%w: %q failed %d requests in a row, last with: %s", ErrClusterUnreachable, clusterName, breaker.failures, breaker.lastError)
+	return err
+}
+
+// record records the outcome of a request to a cluster. A failure opens the breaker once the threshold is
+// reached, or again after a failed probe; a success closes it.
+func (tracker *ClusterHealthTracker) record(clusterName string, latency time.Duration, failure error) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	breaker, exists := tracker.clusters[clusterName]
+	if !exists {
+		breaker = &clusterBreaker{}
+		tracker.clusters[clusterName] = breaker
+	}
+	breaker.probing = false
+	now := tracker.clock.Now()
+
+	if failure != nil {
+		breaker.failures++
+		breaker.lastError = failure.Error()
+		breaker.lastErrorAt = now
+		if breaker.failures >= clusterFailureThreshold {
+			breaker.openedAt = now
+		}
+		return
+	}
+
+	breaker.failures = 0
+	breaker.lastSuccessAt = now
+	if latency <= 0 {
+		return
+	}
+	if breaker.latency == 0 {
+		breaker.latency = latency
+		return
+	}
+	breaker.latency = time.Duration(latencySmoothing*float64(latency) + (1-latencySmoothing)*float64(breaker.latency))
+}
+
+// release ends a request without an outcome, such as one its caller cancelled, freeing the probe slot.
+func (tracker *ClusterHealthTracker) release(clusterName string) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	if breaker, exists := tracker.clusters[clusterName]; exists {
+		breaker.probing = false
+	}
+}
+
+// healthTransport records the outcome of each request to a cluster and fails requests while the cluster's
+// breaker is open.
+type healthTransport struct {
+	next        http.RoundTripper
+	tracker     *ClusterHealthTracker
+	clusterName string
+}
+
+// withClusterHealth makes the clients created from restConfig report to tracker under clusterName. Without a
+// tracker they are left as they are.
+func withClusterHealth(restConfig *rest.Config, tracker *ClusterHealthTracker, clusterName string) {
+	if tracker == nil {
+		return
+	}
+	restConfig.Wrap(func(next http.RoundTripper) (wrapped http.RoundTripper) {
+		wrapped = &healthTransport{next: next, tracker: tracker, clusterName: clusterName}
+		return wrapped
+	})
+}
+
+// RoundTrip sends the request unless the breaker is open. Transport errors, timeouts and gateway errors count
+// as failures; any other response, even a denial, shows the cluster is up. Long-running requests such as
+// watches count, but not towards latency.
+func (transport *healthTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	err = transport.tracker.allow(transport.clusterName)
+	if err != nil {
+		return resp, err
+	}
+
+	start := transport.tracker.clock.Now()
+	resp, err = transport.next.RoundTrip(req)
+	latency := transport.tracker.clock.Now().Sub(start)
+	if longRunningRequest(req) {
+		latency = 0
+	}
+
+	switch {
+	case err != nil && errors.Is(req.Context().Err(), context.Canceled):
+		transport.tracker.release(transport.clusterName)
+	case err != nil:
+		transport.tracker.record(transport.clusterName, latency, err)
+	case resp.StatusCode == http.StatusBadGateway, resp.StatusCode == http.StatusServiceUnavailable, resp.StatusCode == http.StatusGatewayTimeout:
+		transport.tracker.record(transport.clusterName, latency, errors.New(resp.Status))
+	default:
+		transport.tracker.record(transport.clusterName, latency, nil)
+	}
+	return resp, err
+}
@@ -0,0 +1,145 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxRecentClusters is how many recently used clusters are remembered for warm-up.
+	maxRecentClusters = 16
+	// clusterWarmInterval is the least time between two warm-ups of a cluster for the same user. Clients stay
+	// cached for 10 minutes, so warming more often only keeps their connections open.
+	clusterWarmInterval = time.Minute
+)
+
+// ClusterWarmer warms up the clients of recently used clusters in the background, so switching to one of them
+// doesn't wait on exec credential plugins, TLS handshakes and a first connection.
+type ClusterWarmer struct {
+	clients ClientFactory
+	limit   int
+	logger  *zap.Logger
+
+	mu     sync.Mutex
+	recent []string
+	warmed map[string]time.Time
+}
+
+// NewClusterWarmer creates a warmer of the limit most recently used clusters, or nil when limit isn't positive.
+func NewClusterWarmer(clients ClientFactory, limit int, logger *zap.Logger) (warmer *ClusterWarmer) {
+	if limit <= 0 {
+		return warmer
+	}
+	warmer = &ClusterWarmer{clients: clients, limit: limit, logger: logger, warmed: make(map[string]time.Time)}
+	return warmer
+}
+
+// Use records a request to a cluster, moving it to the front of the recently used clusters.
+func (warmer *ClusterWarmer) Use(clusterName string) {
+	if warmer == nil || clusterName == "" || clusterName == ClusterAll {
+		return
+	}
+
+	warmer.mu.Lock()
+	defer warmer.mu.Unlock()
+
+	warmer.recent = slices.DeleteFunc(warmer.recent, func(name string) (match bool) {
+		match = name == clusterName
+		return match
+	})
+	warmer.recent = slices.Insert(warmer.recent, 0, clusterName)
+	if len(warmer.recent) > maxRecentClusters {
+		warmer.recent = warmer.recent[:maxRecentClusters]
+	}
+}
+
+// Warm starts warming up the most recently used clusters other than current for the user of ctx, skipping those
+// warmed for the user within the last minute, and returns the clusters it warms. Each cluster's client is
+// created and its API server asked for its version, under the per-cluster query timeout.
+func (warmer *ClusterWarmer) Warm(ctx context.Context, current string) (warming []string) {
+	if warmer == nil {
+		return warming
+	}
+
+	warmer.mu.Lock()
+	now := time.Now()
+	for _, clusterName := range warmer.recent {
+		if len(warming) >= warmer.limit {
+			break
+		}
+		if clusterName == current {
+			continue
+		}
+		key := clusterCacheKeyFor(ctx, clusterName)
+		if now.Sub(warmer.warmed[key]) < clusterWarmInterval {
+			continue
+		}
+		warmer.warmed[key] = now
+		warming = append(warming, clusterName)
+	}
+	warmer.mu.Unlock()
+
+	// The warm-up outlives the request but keeps its identity and kubeconfig context.
+	warmCtx := context.WithoutCancel(ctx)
+	for _, clusterName := range warming {
+		go warmer.warm(warmCtx, clusterName)
+	}
+	return warming
+}
+
+// warm creates the cluster's client and makes a first request with it.
+func (warmer *ClusterWarmer) warm(ctx context.Context, clusterName string) {
+	ctx, cancel := context.WithTimeout(ctx, clusterQueryTimeout)
+	defer cancel()
+
+	client, err := warmer.clients.GetClient(ctx, clusterName)
+	if err == nil && client.Discovery().RESTClient() != nil {
+		_, err = client.Discovery().RESTClient().Get().AbsPath("/version").DoRaw(ctx)
+	}
+	if err != nil {
+		warmer.logger.Debug("Failed to warm up cluster", zap.Error(err), zap.String("cluster", clusterName))
+	}
+}
+
+// clusterCacheKeyFor identifies a cluster's client for the user and kubeconfig context of ctx.
+func clusterCacheKeyFor(ctx context.Context, clusterName string) (key string) {
+	identity, _ := IdentityFromContext(ctx)
+	key = clientCacheKey(clusterName, KubeContextFromContext(ctx), identity)
+	return key
+}
+
+// clusterUsageMiddleware records the cluster of each request as recently used.
+func clusterUsageMiddleware(warmer *ClusterWarmer) (handler gin.HandlerFunc) {
+	handler = func(c *gin.Context) {
+		warmer.Use(c.GetString(clusterContextKey))
+		c.Next()
+	}
+	return handler
+}
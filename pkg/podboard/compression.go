@@ -0,0 +1,196 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultCompressionMinSize is the smallest response body compressed by default; smaller bodies gain too
+// little to be worth the CPU.
+const DefaultCompressionMinSize = 1024
+
+// Content codings the compression middleware can apply, in order of preference.
+const (
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+)
+
+// compressionMiddleware compresses responses with gzip or deflate when the client accepts it and the body
+// reaches minSize bytes. Bodies are buffered until they reach minSize, so small responses go out as they are.
+// Event and NDJSON streams, and anything flushed before it reaches minSize, are passed through uncompressed
+// so their events aren't held back. A negative minSize disables compression.
+func compressionMiddleware(minSize int) (handler gin.HandlerFunc) {
+	if minSize == 0 {
+		minSize = DefaultCompressionMinSize
+	}
+	handler = func(c *gin.Context) {
+		encoding := acceptedEncoding(c.GetHeader("Accept-Encoding"))
+		if minSize < 0 || encoding == "" || c.Request.Method == "HEAD" {
+			c.Next()
+			return
+		}
+
+		writer := &compressionWriter{ResponseWriter: c.Writer, encoding: encoding, minSize: minSize}
+		c.Writer = writer
+		c.Header("Vary", "Accept-Encoding")
+		defer func() {
+			writer.finish()
+			c.Writer = writer.ResponseWriter
+		}()
+		c.Next()
+	}
+	return handler
+}
+
+// acceptedEncoding picks gzip or deflate from an Accept-Encoding header, or "" when the client accepts
+// neither. Codings refused with q=0 don't count.
+func acceptedEncoding(header string) (encoding string) {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if value, found := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q="); found {
+			q, qErr := strconv.ParseFloat(value, 64)
+			if qErr == nil && q == 0 {
+				continue
+			}
+		}
+		accepted[strings.ToLower(strings.TrimSpace(coding))] = true
+	}
+
+	switch {
+	case accepted[encodingGzip], accepted["*"]:
+		encoding = encodingGzip
+	case accepted[encodingDeflate]:
+		encoding = encodingDeflate
+	}
+	return encoding
+}
+
+// compressionWriter buffers a response until it is big enough to be worth compressing, then compresses the
+// rest of it on the fly.
+type compressionWriter struct {
+	gin.ResponseWriter
+	encoding   string
+	minSize    int
+	buffer     bytes.Buffer
+	compressor io.WriteCloser
+	// passthrough is set once the response is known to go out uncompressed.
+	passthrough bool
+}
+
+// Write buffers or compresses a chunk of the body.
+func (w *compressionWriter) Write(data []byte) (n int, err error) {
+	switch {
+	case w.passthrough:
+		n, err = w.ResponseWriter.Write(data)
+		return n, err
+	case w.compressor != nil:
+		n, err = w.compressor.Write(data)
+		return n, err
+	}
+
+	n, _ = w.buffer.Write(data)
+	if w.buffer.Len() < w.minSize {
+		return n, err
+	}
+	if w.compressible() {
+		err = w.startCompression()
+	} else {
+		err = w.startPassthrough()
+	}
+	return n, err
+}
+
+// WriteString writes a string like Write.
+func (w *compressionWriter) WriteString(s string) (n int, err error) {
+	n, err = w.Write([]byte(s))
+	return n, err
+}
+
+// Flush sends what was written so far. A response flushed before it was compressed is a stream, whose chunks
+// go out as they are.
+func (w *compressionWriter) Flush() {
+	if w.compressor == nil && !w.passthrough {
+		_ = w.startPassthrough()
+	}
+	if flusher, ok := w.compressor.(interface{ Flush() error }); ok {
+		_ = flusher.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// compressible reports whether the response may be compressed: not a stream, and not already encoded.
+func (w *compressionWriter) compressible() (compressible bool) {
+	header := w.Header()
+	contentType := header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "text/event-stream") || strings.HasPrefix(contentType, NDJSONContentType) {
+		return compressible
+	}
+	compressible = header.Get("Content-Encoding") == ""
+	return compressible
+}
+
+// startCompression switches to compressing, writing the buffered body through the compressor.
+func (w *compressionWriter) startCompression() (err error) {
+	header := w.Header()
+	header.Set("Content-Encoding", w.encoding)
+	header.Del("Content-Length")
+
+	if w.encoding == encodingDeflate {
+		// The deflate content coding is the zlib format, not a raw deflate stream.
+		w.compressor = zlib.NewWriter(w.ResponseWriter)
+	} else {
+		w.compressor = gzip.NewWriter(w.ResponseWriter)
+	}
+	_, err = w.compressor.Write(w.buffer.Bytes())
+	w.buffer.Reset()
+	return err
+}
+
+// startPassthrough switches to writing the body as it is, beginning with what was buffered.
+func (w *compressionWriter) startPassthrough() (err error) {
+	w.passthrough = true
+	if w.buffer.Len() > 0 {
+		_, err = w.ResponseWriter.Write(w.buffer.Bytes())
+		w.buffer.Reset()
+	}
+	return err
+}
+
+// finish completes the response once the handler has returned: the compressed stream is closed, or a body too
+// small to compress is sent as it is.
+func (w *compressionWriter) finish() {
+	if w.compressor != nil {
+		_ = w.compressor.Close()
+		return
+	}
+	_ = w.startPassthrough()
+}
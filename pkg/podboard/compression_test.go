@@ -0,0 +1,103 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package podboard
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressionMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const minSize = 64
+	large := strings.Repeat("podboard-", 32)
+	small := "ok"
+
+	router := gin.New()
+	router.Use(compressionMiddleware(minSize))
+	router.GET("/body", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", []byte(c.Query("body")))
+	})
+	router.GET("/stream", func(c *gin.Context) {
+		c.Data(http.StatusOK, c.Query("type"), []byte(large))
+	})
+	router.GET("/flushed", func(c *gin.Context) {
+		c.Header("Content-Type", "application/json")
+		_, _ = c.Writer.WriteString(small)
+		c.Writer.Flush()
+		_, _ = c.Writer.WriteString(large)
+	})
+
+	tests := []struct {
+		name           string
+		target         string
+		acceptEncoding string
+		encoding       string
+		vary           bool
+		body           string
+	}{
+		{name: "gzip", target: "/body?body=" + large, acceptEncoding: "gzip, deflate", encoding: "gzip", vary: true, body: large},
+		{name: "deflate", target: "/body?body=" + large, acceptEncoding: "deflate", encoding: "deflate", vary: true, body: large},
+		{name: "gzip refused", target: "/body?body=" + large, acceptEncoding: "gzip;q=0, deflate", encoding: "deflate", vary: true, body: large},
+		{name: "below minimum size", target: "/body?body=" + small, acceptEncoding: "gzip", vary: true, body: small},
+		{name: "not accepted", target: "/body?body=" + large, acceptEncoding: "br", body: large},
+		{name: "event stream", target: "/stream?type=text/event-stream", acceptEncoding: "gzip", vary: true, body: large},
+		{name: "ndjson", target: "/stream?type=" + NDJSONContentType, acceptEncoding: "gzip", vary: true, body: large},
+		{name: "flushed before minimum size", target: "/flushed", acceptEncoding: "gzip", vary: true, body: small + large},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := serve(router, http.MethodGet, tt.target, map[string]string{"Accept-Encoding": tt.acceptEncoding})
+			require.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, tt.encoding, recorder.Header().Get("Content-Encoding"))
+			if tt.vary {
+				assert.Equal(t, "Accept-Encoding", recorder.Header().Get("Vary"))
+			} else {
+				assert.Empty(t, recorder.Header().Get("Vary"))
+			}
+
+			var reader io.Reader = recorder.Body
+			switch tt.encoding {
+			case encodingGzip:
+				gzipReader, err := gzip.NewReader(reader)
+				require.NoError(t, err)
+				reader = gzipReader
+			case encodingDeflate:
+				zlibReader, err := zlib.NewReader(reader)
+				require.NoError(t, err, "deflate responses are zlib streams")
+				reader = zlibReader
+			}
+			body, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, tt.body, string(body))
+		})
+	}
+}
@@ -0,0 +1,226 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// ErrOffline is returned by features that would make outbound calls while offline mode is enabled.
+var ErrOffline = errors.New("disabled in offline mode: podboard makes no outbound calls")
+
+// ServerConfig holds the settings for the podboard server.
+type ServerConfig struct {
+	// Address is the host and port to listen on.
+	Address string
+	// Domain is the server domain name.
+	Domain string
+	// Offline guarantees podboard performs no outbound calls other than to the configured Kubernetes API servers.
+	Offline bool
+	// Impersonate makes Kubernetes API calls as the end user identified by UserHeader and GroupsHeader
+	// rather than as podboard's own credentials, so cluster RBAC decides what each user can see and do.
+	Impersonate bool
+	// TrustIdentityHeaders authenticates users by UserHeader and GroupsHeader without impersonating them, to
+	// apply their access bindings behind a proxy such as oauth2-proxy. Impersonate implies it.
+	TrustIdentityHeaders bool
+	// UserHeader is the header an authenticating proxy sets to the user name (default X-Forwarded-User).
+	UserHeader string
+	// GroupsHeader is the header an authenticating proxy sets to the user's groups (default X-Forwarded-Groups).
+	GroupsHeader string
+	// UIDir serves the frontend from a directory of built UI files instead of the embedded UI, with live reload.
+	UIDir string
+	// RunbookAnnotation is the pod and deployment annotation holding a runbook URL (default podboard.io/runbook).
+	RunbookAnnotation string
+	// ConfigFile is the path of an optional YAML file with settings that don't fit on the command line.
+	ConfigFile string
+	// ExtraKubeconfigDir is a directory of kubeconfig files, typically a mounted Secret, whose clusters are
+	// served alongside the in-cluster connection when running in cluster.
+	ExtraKubeconfigDir string
+	// InClusterName is the cluster name of the in-cluster connection when ExtraKubeconfigDir is set (default in-cluster).
+	InClusterName string
+	// StreamHeartbeat is the interval between heartbeats on Server-Sent Event streams, zero to disable.
+	StreamHeartbeat time.Duration
+	// StreamRetry is the reconnect delay streams advertise to clients (default 3s).
+	StreamRetry time.Duration
+	// WriteTimeout limits the time to write a response, zero for no limit. Streams are exempt.
+	WriteTimeout time.Duration
+	// IdleTimeout is how long idle keep-alive connections are kept open (default 75s).
+	IdleTimeout time.Duration
+	// KubernetesRequestTimeout bounds each Kubernetes API request, zero for no limit. Watches are exempt.
+	KubernetesRequestTimeout time.Duration
+	// KubernetesQPS is the sustained rate of requests to each Kubernetes API server, shared by all of a
+	// cluster's clients. Zero keeps client-go's default of 5, negative disables rate limiting.
+	KubernetesQPS float32
+	// KubernetesBurst is how many requests may go above KubernetesQPS at once (default twice KubernetesQPS).
+	KubernetesBurst int
+	// KubernetesRetries is how many times a read that failed transiently, such as with a 429 or a dropped
+	// connection, is retried, zero to disable.
+	KubernetesRetries int
+	// PrimeCache loads the pods of every namespace of the cluster the UI opens on right after startup, so the
+	// first page loads don't wait on cold clients. Progress is reported by /api/cache/status.
+	PrimeCache bool
+	// ReadyAfterPriming makes /ready fail until PrimeCache's priming is done, for readiness probes.
+	ReadyAfterPriming bool
+	// WarmClusters is how many of the most recently used clusters get their clients warmed up in the background
+	// when clusters are listed, zero to disable.
+	WarmClusters int
+	// CompressionMinSize is the smallest API response body compressed with gzip or deflate (default 1KiB),
+	// negative to disable compression.
+	CompressionMinSize int
+	// TimeZone is the IANA time zone timestamps in responses are rendered in (default UTC). Requests can
+	// override it with the tz query parameter or the X-Podboard-Timezone header.
+	TimeZone string
+	// DeleteUndoWindow holds pod deletions back for this long, during which they can be cancelled from the
+	// pending actions, zero to delete immediately. Requests can choose their own window with undoWindow.
+	DeleteUndoWindow time.Duration
+	// MaxConcurrentRequests is how many API requests may be in flight before podboard sheds load, zero for no
+	// limit. Open event streams don't count.
+	MaxConcurrentRequests int
+	// MaxHeapBytes is the heap size above which podboard sheds load, zero for no limit.
+	MaxHeapBytes uint64
+	// CORSAllowedOrigins may call the API from the browser, as exact origins, globs such as
+	// https://*.example.com, or * for any origin. Unset, cross-origin calls stay blocked.
+	CORSAllowedOrigins []string
+	// CORSAllowedMethods are the methods cross-origin calls may use, DefaultCORSMethods when unset.
+	CORSAllowedMethods []string
+	// CORSAllowedHeaders are the headers cross-origin calls may send, DefaultCORSHeaders when unset.
+	CORSAllowedHeaders []string
+	// CORSAllowCredentials lets cross-origin calls send cookies, such as an authenticating proxy's session.
+	CORSAllowCredentials bool
+	// CSRFProtection requires requests that change something to echo the podboard_csrf cookie in the
+	// X-CSRF-Token header, so other sites can't make a signed-in browser act through podboard.
+	CSRFProtection bool
+	// ConfirmDestructive makes deletes, evictions, scaling, restarts, drains, migrations, pending actions,
+	// reverts and canary decisions take two steps: the first request is answered with a 428 and a
+	// confirmation token, and the repeat carrying it goes ahead.
+	ConfirmDestructive bool
+	// Namespaces are offered by /api/namespaces when podboard may not list namespaces, as with namespace-scoped
+	// RBAC, keeping those it may list pods in.
+	Namespaces []string
+	// Clusters, when set, are the only kubeconfig clusters podboard exposes and connects to, as names or globs.
+	// Contexts of other clusters are hidden too.
+	Clusters []string
+	// AllowedNamespaces, when set, are the only namespaces anyone may see or act on through podboard, as globs
+	// such as team-* or regular expressions between slashes.
+	AllowedNamespaces []string
+	// DeniedNamespaces may never be seen or acted on through podboard, even when allowed, such as kube-*.
+	DeniedNamespaces []string
+	// Clock supplies the current time for ages and time windows, the system clock when nil.
+	Clock Clock
+}
+
+// FileConfig holds the settings read from the config file.
+type FileConfig struct {
+	// Actions are custom buttons shown on pod and deployment rows.
+	Actions []ActionConfig `yaml:"actions"`
+	// Tickets configures incident ticket creation from pod rows.
+	Tickets TicketConfig `yaml:"tickets"`
+	// Status configures the workloads summarized on the status page.
+	Status StatusConfig `yaml:"status"`
+	// Statuses are named statuses derived from pod and deployment fields.
+	Statuses []DerivedStatusConfig `yaml:"statuses"`
+	// QuickScale holds the guardrails for temporary scale-ups during incidents.
+	QuickScale QuickScaleConfig `yaml:"quickScale"`
+	// GitHub configures reporting rollout outcomes to GitHub deployments.
+	GitHub GitHubConfig `yaml:"github"`
+	// Canary configures the canary track labels and the promote and abort actions.
+	Canary CanaryConfig `yaml:"canary"`
+	// PodWebhooks are outbound webhooks sent on pod transitions.
+	PodWebhooks []PodWebhookConfig `yaml:"podWebhooks"`
+	// EventBus configures publishing pod changes to a NATS or MQTT broker.
+	EventBus EventBusConfig `yaml:"eventBus"`
+	// Annotations limits the pod annotations shown in pod details.
+	Annotations AnnotationConfig `yaml:"annotations"`
+	// Alertmanager shows the alerts and silences of a namespace and silences workloads.
+	Alertmanager AlertmanagerConfig `yaml:"alertmanager"`
+	// Access binds users authenticated by identity headers to a default cluster and namespaces.
+	Access AccessConfig `yaml:"access"`
+}
+
+// LoadFileConfig reads the YAML config file. An empty path returns an empty configuration.
+func LoadFileConfig(path string) (fileConfig FileConfig, err error) {
+	if path == "" {
+		return fileConfig, err
+	}
+
+	var content []byte
+	content, err = os.ReadFile(path)
+	if err != nil {
+		err = fmt.Errorf("failed to read config file: %w", err)
+		return fileConfig, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	err = decoder.Decode(&fileConfig)
+	if err != nil && !errors.Is(err, io.EOF) {
+		err = fmt.Errorf("failed to parse config file %s: %w", path, err)
+		return fileConfig, err
+	}
+
+	err = nil
+	return fileConfig, err
+}
+
+// CheckOutbound returns ErrOffline if offline mode forbids an outbound call for the given feature.
+func (cfg *ServerConfig) CheckOutbound(feature string) (err error) {
+	if cfg.Offline {
+		err = fmt.Errorf("%s: %w", feature, ErrOffline)
+		return err
+	}
+	return err
+}
+
+// OutboundAuthUsers returns the kubeconfig users whose credentials come from exec plugins or auth providers.
+// These typically contact external identity services (cloud IAM, OIDC issuers) and so are not offline safe.
+func (kcs *KubeConfigService) OutboundAuthUsers() (users []string, err error) {
+	if kcs.inCluster {
+		return users, err
+	}
+
+	var config *clientcmdapi.Config
+	config, err = kcs.loadKubeConfig()
+	if err != nil {
+		err = fmt.Errorf("failed to load kubeconfig: %w", err)
+		return users, err
+	}
+
+	for name, authInfo := range config.AuthInfos {
+		if authInfo.Exec != nil || authInfo.AuthProvider != nil {
+			users = append(users, name)
+		}
+	}
+
+	sort.Strings(users)
+	return users, err
+}
@@ -0,0 +1,182 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConfirmHeader carries the token confirming the second step of a destructive request.
+const ConfirmHeader = "X-Podboard-Confirm"
+
+// StatusReasonConfirmationRequired is the reason of the 428 asking for a destructive request to be confirmed.
+const StatusReasonConfirmationRequired metav1.StatusReason = "ConfirmationRequired"
+
+const (
+	// confirmationTTL is how long a confirmation token can be redeemed.
+	confirmationTTL = time.Minute
+	// maxPendingConfirmations bounds the tokens waiting to be redeemed.
+	maxPendingConfirmations = 1000
+)
+
+// ConfirmationStore holds the confirmation tokens of destructive requests waiting for their second step.
+// Each token confirms one request, by the same user, to the same URL with the same body, once.
+type ConfirmationStore struct {
+	mu      sync.Mutex
+	clock   Clock
+	pending map[string]pendingConfirmation
+}
+
+// pendingConfirmation is the request a token confirms and when it expires.
+type pendingConfirmation struct {
+	request   string
+	expiresAt time.Time
+}
+
+// NewConfirmationStore creates a store timing tokens by clock, the system clock when nil.
+func NewConfirmationStore(clock Clock) (store *ConfirmationStore) {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	store = &ConfirmationStore{clock: clock, pending: make(map[string]pendingConfirmation)}
+	return store
+}
+
+// issue returns a token confirming request until it expires. When the store is full, the token closest to
+// expiring makes room.
+func (store *ConfirmationStore) issue(request string) (token string, expiresAt time.Time, err error) {
+	token, err = newCSRFToken()
+	if err != nil {
+		return token, expiresAt, err
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := store.clock.Now()
+	oldest := ""
+	for pendingToken, confirmation := range store.pending {
+		if !now.Before(confirmation.expiresAt) {
+			delete(store.pending, pendingToken)
+			continue
+		}
+		if oldest == "" || confirmation.expiresAt.Before(store.pending[oldest].expiresAt) {
+			oldest = pendingToken
+		}
+	}
+	if len(store.pending) >= maxPendingConfirmations {
+		delete(store.pending, oldest)
+	}
+
+	expiresAt = now.Add(confirmationTTL)
+	store.pending[token] = pendingConfirmation{request: request, expiresAt: expiresAt}
+	return token, expiresAt, err
+}
+
+// redeem uses up a token, returning true if it confirms request and hasn't expired.
+func (store *ConfirmationStore) redeem(token, request string) (confirmed bool) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	confirmation, exists := store.pending[token]
+	if !exists {
+		return confirmed
+	}
+	delete(store.pending, token)
+	confirmed = confirmation.request == request && store.clock.Now().Before(confirmation.expiresAt)
+	return confirmed
+}
+
+// confirmMiddleware makes a destructive request take two steps. The first is answered with a 428 carrying a
+// confirmation token, and the request goes ahead once it is repeated with the token in ConfirmHeader. On
+// routes whose handler honors dryRun, dry runs go ahead right away. Without a store every request does.
+func confirmMiddleware(store *ConfirmationStore, dryRunnable bool) (handler gin.HandlerFunc) {
+	handler = func(c *gin.Context) {
+		if store == nil || dryRunnable && c.Query("dryRun") == "true" {
+			c.Next()
+			return
+		}
+
+		request, err := confirmationRequest(c)
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			respondErrorCode(c, http.StatusRequestEntityTooLarge, err.Error())
+			return
+		case err != nil:
+			respondErrorCode(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		token := c.GetHeader(ConfirmHeader)
+		if token != "" {
+			if !store.redeem(token, request) {
+				respondErrorCode(c, http.StatusForbidden, "confirmation token is invalid, expired or for another request")
+				return
+			}
+			c.Next()
+			return
+		}
+
+		token, expiresAt, err := store.issue(request)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		message := fmt.Sprintf("confirm %s %s by repeating it with the %s header within %s", c.Request.Method, c.Request.URL.Path, ConfirmHeader, confirmationTTL)
+		body := errorBody(c, http.StatusPreconditionRequired, StatusReasonConfirmationRequired, message)
+		body["confirmationToken"] = token
+		body["expiresAt"] = timeViewFromContext(c.Request.Context()).format(expiresAt)
+		c.AbortWithStatusJSON(http.StatusPreconditionRequired, body)
+	}
+	return handler
+}
+
+// confirmationRequest identifies a request for its confirmation: the user, method, URL and a hash of the body,
+// which is put back for the handler. Bodies over maxRequestBody are refused.
+func confirmationRequest(c *gin.Context) (request string, err error) {
+	var body []byte
+	if c.Request.Body != nil {
+		body, err = io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxRequestBody))
+		if err != nil {
+			err = fmt.Errorf("failed to read request body: %w", err)
+			return request, err
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	sum := sha256.Sum256(body)
+	request = identityUser(c.Request.Context()) + "|" + c.Request.Method + "|" + c.Request.URL.Path + "?" + c.Request.URL.Query().Encode() + "|" + hex.EncodeToString(sum[:])
+	return request, err
+}
@@ -0,0 +1,150 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConfirmMiddleware makes destructive requests take two steps, with single use tokens bound to the request.
+func TestConfirmMiddleware(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	router := newTestRouter(t, ServerConfig{ConfirmDestructive: true, Clock: clock}, FileConfig{},
+		testPod("default", "web-1", "node-1"),
+		testPod("default", "web-2", "node-1"),
+		testPod("default", "web-3", "node-1"),
+	)
+
+	// confirmationToken sends the first step of a request and returns the token it is answered with.
+	confirmationToken := func(t *testing.T, method, target string) (token string) {
+		t.Helper()
+		recorder := serve(router, method, target, nil)
+		require.Equal(t, http.StatusPreconditionRequired, recorder.Code, recorder.Body.String())
+		body := decodeBody(t, recorder)
+		assert.Equal(t, string(StatusReasonConfirmationRequired), body["reason"])
+		token, _ = body["confirmationToken"].(string)
+		require.NotEmpty(t, token)
+		return token
+	}
+
+	t.Run("confirmed request goes ahead once", func(t *testing.T) {
+		token := confirmationToken(t, http.MethodDelete, "/api/pods/default/web-1")
+
+		recorder := serve(router, http.MethodDelete, "/api/pods/default/web-1", map[string]string{ConfirmHeader: token})
+		assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+		recorder = serve(router, http.MethodDelete, "/api/pods/default/web-1", map[string]string{ConfirmHeader: token})
+		assert.Equal(t, http.StatusForbidden, recorder.Code, "a token must not be redeemed twice")
+	})
+
+	t.Run("token for another request is rejected", func(t *testing.T) {
+		token := confirmationToken(t, http.MethodDelete, "/api/pods/default/web-2")
+
+		recorder := serve(router, http.MethodDelete, "/api/pods/default/web-3", map[string]string{ConfirmHeader: token})
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+		recorder = serve(router, http.MethodDelete, "/api/pods/default/web-2", map[string]string{ConfirmHeader: token})
+		assert.Equal(t, http.StatusForbidden, recorder.Code, "a rejected token must be used up")
+	})
+
+	t.Run("expired token is rejected", func(t *testing.T) {
+		token := confirmationToken(t, http.MethodDelete, "/api/pods/default/web-2")
+		clock.Advance(confirmationTTL)
+
+		recorder := serve(router, http.MethodDelete, "/api/pods/default/web-2", map[string]string{ConfirmHeader: token})
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+	})
+
+	t.Run("made up token is rejected", func(t *testing.T) {
+		recorder := serve(router, http.MethodDelete, "/api/pods/default/web-2", map[string]string{ConfirmHeader: "not-a-token"})
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+	})
+
+	t.Run("dry runs skip confirmation on dry-runnable routes", func(t *testing.T) {
+		recorder := serve(router, http.MethodDelete, "/api/pods?namespace=default&labelSelector=app%3Dweb-2&dryRun=true", nil)
+		assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	})
+
+	t.Run("dryRun doesn't bypass confirmation elsewhere", func(t *testing.T) {
+		recorder := serve(router, http.MethodDelete, "/api/pods/default/web-2?dryRun=true", nil)
+		assert.Equal(t, http.StatusPreconditionRequired, recorder.Code)
+
+		recorder = serve(router, http.MethodPost, "/api/nodes/node-1/drain?dryRun=true", nil)
+		assert.Equal(t, http.StatusPreconditionRequired, recorder.Code)
+	})
+
+	t.Run("cordons and label changes need confirmation", func(t *testing.T) {
+		for _, route := range []struct {
+			method string
+			target string
+		}{
+			{http.MethodPut, "/api/nodes/node-1/cordon"},
+			{http.MethodPut, "/api/nodes/node-1/uncordon"},
+			{http.MethodPatch, "/api/pods/default/web-3/labels"},
+			{http.MethodPatch, "/api/deployments/default/web/labels"},
+		} {
+			recorder := serve(router, route.method, route.target, nil)
+			assert.Equal(t, http.StatusPreconditionRequired, recorder.Code, "%s %s", route.method, route.target)
+		}
+	})
+
+	t.Run("oversized bodies are refused", func(t *testing.T) {
+		body := `{"labels":{"note":"` + strings.Repeat("x", maxRequestBody) + `"}}`
+		request := httptest.NewRequest(http.MethodPatch, "/api/pods/default/web-3/labels", strings.NewReader(body))
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code, recorder.Body.String())
+	})
+}
+
+// TestConfirmMiddlewareDisabled lets destructive requests through in one step without ConfirmDestructive.
+func TestConfirmMiddlewareDisabled(t *testing.T) {
+	router := newTestRouter(t, ServerConfig{}, FileConfig{}, testPod("default", "web-1", "node-1"))
+
+	recorder := serve(router, http.MethodDelete, "/api/pods/default/web-1", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+}
+
+// TestConfirmationStoreEviction makes room for new tokens by dropping the one closest to expiring.
+func TestConfirmationStoreEviction(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store := NewConfirmationStore(clock)
+
+	first, _, err := store.issue("first")
+	require.NoError(t, err)
+	for range maxPendingConfirmations {
+		clock.Advance(time.Millisecond)
+		_, _, err = store.issue("later")
+		require.NoError(t, err)
+	}
+
+	assert.Len(t, store.pending, maxPendingConfirmations)
+	assert.False(t, store.redeem(first, "first"), "the oldest token should have been dropped")
+}
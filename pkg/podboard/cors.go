@@ -0,0 +1,174 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSAnyOrigin allows cross-origin calls from every origin.
+const CORSAnyOrigin = "*"
+
+// corsMaxAge is how long browsers may cache the answer to a preflight request.
+const corsMaxAge = 10 * time.Minute
+
+// ErrInvalidCORSConfig is returned for CORS origins that aren't valid globs, and for credentials with any origin.
+var ErrInvalidCORSConfig = errors.New("invalid CORS configuration")
+
+// DefaultCORSMethods are the methods cross-origin callers may use unless configured otherwise.
+func DefaultCORSMethods() (methods []string) {
+	methods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	return methods
+}
+
+// DefaultCORSHeaders are the request headers cross-origin callers may send unless configured otherwise.
+func DefaultCORSHeaders() (headers []string) {
+	headers = []string{"Content-Type", CSRFHeader, ConfirmHeader, RequestIDHeader, clusterHeader, contextHeader, TimeZoneHeader, "If-None-Match"}
+	return headers
+}
+
+// corsExposedHeaders are the response headers cross-origin callers may read.
+const corsExposedHeaders = RequestIDHeader + ", Retry-After, ETag"
+
+// CORSPolicy lets the configured origins call podboard's API from the browser. Origins are exact, such as
+// https://tools.example.com, globs such as https://*.example.com, or * for any origin.
+type CORSPolicy struct {
+	origins     []string
+	anyOrigin   bool
+	methods     string
+	headers     string
+	credentials bool
+}
+
+// NewCORSPolicy validates the CORS settings, returning nil when no origins are allowed. Empty methods and
+// headers fall back to the defaults. Credentials can't be combined with any origin, which browsers refuse.
+func NewCORSPolicy(origins, methods, headers []string, credentials bool) (policy *CORSPolicy, err error) {
+	if len(origins) == 0 {
+		return policy, err
+	}
+	if len(methods) == 0 {
+		methods = DefaultCORSMethods()
+	}
+	if len(headers) == 0 {
+		headers = DefaultCORSHeaders()
+	}
+
+	policy = &CORSPolicy{
+		methods:     strings.ToUpper(strings.Join(methods, ", ")),
+		headers:     strings.Join(headers, ", "),
+		credentials: credentials,
+	}
+	for _, origin := range origins {
+		if origin == CORSAnyOrigin {
+			policy.anyOrigin = true
+			continue
+		}
+		_, err = path.Match(origin, "")
+		if err != nil {
+			err = fmt.Errorf("%w: origin %q: %w", ErrInvalidCORSConfig, origin, err)
+			policy = nil
+			return policy, err
+		}
+		policy.origins = append(policy.origins, strings.TrimSuffix(origin, "/"))
+	}
+	if policy.anyOrigin && credentials {
+		err = fmt.Errorf("%w: credentials can't be allowed for any origin, list the origins instead", ErrInvalidCORSConfig)
+		policy = nil
+		return policy, err
+	}
+
+	return policy, err
+}
+
+// listed reports whether an origin matches one of the configured origins, not counting any origin.
+func (policy *CORSPolicy) listed(origin string) (matched bool) {
+	if policy == nil || origin == "" {
+		return matched
+	}
+	for _, pattern := range policy.origins {
+		matched, _ = path.Match(pattern, origin)
+		if matched {
+			return matched
+		}
+	}
+	return matched
+}
+
+// allows reports whether an origin may call the API.
+func (policy *CORSPolicy) allows(origin string) (allowed bool) {
+	allowed = policy != nil && origin != "" && (policy.anyOrigin || policy.listed(origin))
+	return allowed
+}
+
+// corsMiddleware answers preflight requests and adds the CORS headers to API responses for allowed origins.
+// Without a policy, or for other origins, no CORS headers are sent and browsers keep blocking the calls.
+// It runs on the router so preflight requests, which match no route, reach it.
+func corsMiddleware(policy *CORSPolicy) (handler gin.HandlerFunc) {
+	handler = func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if policy == nil || origin == "" || !strings.HasPrefix(c.Request.URL.Path, "/api/") {
+			c.Next()
+			return
+		}
+
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		c.Writer.Header().Add("Vary", "Origin")
+		if !policy.allows(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if policy.anyOrigin && !policy.listed(origin) {
+			c.Header("Access-Control-Allow-Origin", CORSAnyOrigin)
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+		if policy.credentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			c.Header("Access-Control-Allow-Methods", policy.methods)
+			c.Header("Access-Control-Allow-Headers", policy.headers)
+			c.Header("Access-Control-Max-Age", strconv.Itoa(int(corsMaxAge.Seconds())))
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Header("Access-Control-Expose-Headers", corsExposedHeaders)
+		c.Next()
+	}
+	return handler
+}
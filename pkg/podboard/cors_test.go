@@ -0,0 +1,107 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNewCORSPolicy validates origins and refuses credentials for any origin.
+func TestNewCORSPolicy(t *testing.T) {
+	policy, err := NewCORSPolicy(nil, nil, nil, true)
+	require.NoError(t, err)
+	assert.Nil(t, policy, "no origins means no policy")
+
+	_, err = NewCORSPolicy([]string{"https://[example.com"}, nil, nil, false)
+	require.ErrorIs(t, err, ErrInvalidCORSConfig)
+
+	_, err = NewCORSPolicy([]string{CORSAnyOrigin}, nil, nil, true)
+	require.ErrorIs(t, err, ErrInvalidCORSConfig)
+
+	policy, err = NewCORSPolicy([]string{"https://*.example.com/"}, nil, nil, false)
+	require.NoError(t, err)
+	assert.True(t, policy.allows("https://tools.example.com"))
+	assert.False(t, policy.allows("https://example.com.evil.test"))
+	assert.False(t, policy.allows(""))
+}
+
+// TestCORSMiddleware answers preflight requests and adds CORS headers for allowed origins only.
+func TestCORSMiddleware(t *testing.T) {
+	router := newTestRouter(t, ServerConfig{CORSAllowedOrigins: []string{"https://*.example.com"}, CORSAllowCredentials: true}, FileConfig{},
+		testPod("default", "web-1", "node-1"),
+	)
+
+	preflight := func(origin string) (headers map[string]string) {
+		headers = map[string]string{"Origin": origin, "Access-Control-Request-Method": http.MethodDelete}
+		return headers
+	}
+
+	t.Run("preflight from allowed origin", func(t *testing.T) {
+		recorder := serve(router, http.MethodOptions, "/api/pods/default/web-1", preflight("https://tools.example.com"))
+		assert.Equal(t, http.StatusNoContent, recorder.Code)
+		assert.Equal(t, "https://tools.example.com", recorder.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", recorder.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Contains(t, recorder.Header().Get("Access-Control-Allow-Methods"), http.MethodDelete)
+		assert.Contains(t, recorder.Header().Get("Access-Control-Allow-Headers"), CSRFHeader)
+		assert.Equal(t, "600", recorder.Header().Get("Access-Control-Max-Age"))
+	})
+
+	t.Run("preflight from other origin", func(t *testing.T) {
+		recorder := serve(router, http.MethodOptions, "/api/pods/default/web-1", preflight("https://evil.test"))
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+		assert.Empty(t, recorder.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("request from allowed origin", func(t *testing.T) {
+		recorder := serve(router, http.MethodGet, "/api/pods?namespace=default", map[string]string{"Origin": "https://tools.example.com"})
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "https://tools.example.com", recorder.Header().Get("Access-Control-Allow-Origin"))
+		assert.Contains(t, recorder.Header().Get("Access-Control-Expose-Headers"), RequestIDHeader)
+		assert.Equal(t, "Origin", recorder.Header().Get("Vary"))
+	})
+
+	t.Run("request from other origin", func(t *testing.T) {
+		recorder := serve(router, http.MethodGet, "/api/pods?namespace=default", map[string]string{"Origin": "https://evil.test"})
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Empty(t, recorder.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("outside the API", func(t *testing.T) {
+		recorder := serve(router, http.MethodGet, "/health", map[string]string{"Origin": "https://tools.example.com"})
+		assert.Empty(t, recorder.Header().Get("Access-Control-Allow-Origin"))
+	})
+}
+
+// TestCORSMiddlewareAnyOrigin answers any origin with a wildcard rather than echoing it.
+func TestCORSMiddlewareAnyOrigin(t *testing.T) {
+	router := newTestRouter(t, ServerConfig{CORSAllowedOrigins: []string{CORSAnyOrigin}}, FileConfig{})
+
+	recorder := serve(router, http.MethodGet, "/api/pods?namespace=default", map[string]string{"Origin": "https://anywhere.test"})
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, CORSAnyOrigin, recorder.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, recorder.Header().Get("Access-Control-Allow-Credentials"))
+}
@@ -0,0 +1,108 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// CSRFCookie holds the token browsers echo in CSRFHeader on requests that change something.
+	CSRFCookie = "podboard_csrf"
+	// CSRFHeader carries the CSRF token on POST, PUT, PATCH and DELETE requests.
+	CSRFHeader = "X-CSRF-Token"
+
+	csrfTokenBytes    = 32
+	csrfContextKey    = "podboard.csrf"
+	forwardedProtoKey = "X-Forwarded-Proto"
+)
+
+// csrfMiddleware protects the requests that change something against cross-site request forgery with a
+// double-submit cookie. Reads hand out a random token in CSRFCookie, and POST, PUT, PATCH and DELETE requests
+// must send the same token in CSRFHeader. Other sites can make a browser send the cookie but can't read it,
+// so they can't set the header. Origins listed in the CORS policy are trusted to call the API and are exempt,
+// since they can't read the cookie either.
+func csrfMiddleware(enabled bool, cors *CORSPolicy) (handler gin.HandlerFunc) {
+	handler = func(c *gin.Context) {
+		if !enabled || cors.listed(c.GetHeader("Origin")) {
+			c.Next()
+			return
+		}
+
+		token, _ := c.Cookie(CSRFCookie)
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			if token == "" {
+				var err error
+				token, err = newCSRFToken()
+				if err != nil {
+					respondError(c, err)
+					return
+				}
+				setCSRFCookie(c, token)
+			}
+			c.Set(csrfContextKey, token)
+			c.Next()
+			return
+		}
+
+		sent := c.GetHeader(CSRFHeader)
+		if token == "" || sent == "" || subtle.ConstantTimeCompare([]byte(token), []byte(sent)) != 1 {
+			respondErrorCode(c, http.StatusForbidden, fmt.Sprintf("missing or invalid CSRF token: send the %s cookie's value in the %s header", CSRFCookie, CSRFHeader))
+			return
+		}
+		c.Next()
+	}
+	return handler
+}
+
+// newCSRFToken returns a random CSRF token.
+func newCSRFToken() (token string, err error) {
+	buf := make([]byte, csrfTokenBytes)
+	_, err = rand.Read(buf)
+	if err != nil {
+		err = fmt.Errorf("failed to generate CSRF token: %w", err)
+		return token, err
+	}
+	token = hex.EncodeToString(buf)
+	return token, err
+}
+
+// setCSRFCookie sends the CSRF token cookie. Scripts have to read it to echo it, so it isn't HttpOnly, and it
+// is only sent on same-site requests.
+func setCSRFCookie(c *gin.Context, token string) {
+	//nolint:gosec // The page's scripts have to read the token to send it back
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     CSRFCookie,
+		Value:    token,
+		Path:     "/",
+		Secure:   c.Request.TLS != nil || c.GetHeader(forwardedProtoKey) == "https",
+		SameSite: http.SameSiteStrictMode,
+	})
+}
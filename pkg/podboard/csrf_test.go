@@ -0,0 +1,122 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// TestCSRFMiddleware requires requests that change something to echo the CSRF cookie in the CSRF header.
+func TestCSRFMiddleware(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers func(token string) map[string]string
+		code    int
+	}{
+		{
+			name:    "no cookie or header",
+			headers: func(string) map[string]string { return nil },
+			code:    http.StatusForbidden,
+		},
+		{
+			name:    "header without cookie",
+			headers: func(token string) map[string]string { return map[string]string{CSRFHeader: token} },
+			code:    http.StatusForbidden,
+		},
+		{
+			name:    "cookie without header",
+			headers: func(token string) map[string]string { return map[string]string{"Cookie": CSRFCookie + "=" + token} },
+			code:    http.StatusForbidden,
+		},
+		{
+			name: "header not matching cookie",
+			headers: func(token string) map[string]string {
+				return map[string]string{"Cookie": CSRFCookie + "=" + token, CSRFHeader: "forged"}
+			},
+			code: http.StatusForbidden,
+		},
+		{
+			name:    "unlisted origin",
+			headers: func(string) map[string]string { return map[string]string{"Origin": "https://evil.example.com"} },
+			code:    http.StatusForbidden,
+		},
+		{
+			name: "header matching cookie",
+			headers: func(token string) map[string]string {
+				return map[string]string{"Cookie": CSRFCookie + "=" + token, CSRFHeader: token}
+			},
+			code: http.StatusOK,
+		},
+		{
+			name:    "listed CORS origin",
+			headers: func(string) map[string]string { return map[string]string{"Origin": "https://tools.example.com"} },
+			code:    http.StatusOK,
+		},
+	}
+
+	// Each case deletes its own pod, so accepted requests don't depend on each other.
+	pods := make([]runtime.Object, 0, len(tests))
+	for i := range tests {
+		pods = append(pods, testPod("default", fmt.Sprintf("web-%d", i), "node-1"))
+	}
+	router := newTestRouter(t, ServerConfig{CSRFProtection: true, CORSAllowedOrigins: []string{"https://tools.example.com"}}, FileConfig{}, pods...)
+
+	recorder := serve(router, http.MethodGet, "/api/csrf", nil)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var cookie *http.Cookie
+	for _, candidate := range recorder.Result().Cookies() {
+		if candidate.Name == CSRFCookie {
+			cookie = candidate
+		}
+	}
+	require.NotNil(t, cookie, "reads should hand out the CSRF cookie")
+	assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+	body := decodeBody(t, recorder)
+	assert.Equal(t, cookie.Value, body["token"])
+	assert.Equal(t, true, body["enabled"])
+
+	recorder = serve(router, http.MethodGet, "/api/csrf", map[string]string{"Cookie": CSRFCookie + "=" + cookie.Value})
+	assert.Empty(t, recorder.Result().Cookies(), "an existing token should be kept")
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := serve(router, http.MethodDelete, fmt.Sprintf("/api/pods/default/web-%d", i), tt.headers(cookie.Value))
+			assert.Equal(t, tt.code, recorder.Code, recorder.Body.String())
+		})
+	}
+}
+
+// TestCSRFMiddlewareDisabled lets requests through without a token when CSRF protection is off.
+func TestCSRFMiddlewareDisabled(t *testing.T) {
+	router := newTestRouter(t, ServerConfig{}, FileConfig{}, testPod("default", "web-1", "node-1"))
+
+	recorder := serve(router, http.MethodDelete, "/api/pods/default/web-1", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.Empty(t, recorder.Result().Cookies())
+}
@@ -0,0 +1,328 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Rollout states reported for deployments.
+const (
+	RolloutComplete    = "Complete"
+	RolloutProgressing = "Progressing"
+	RolloutPaused      = "Paused"
+	RolloutFailed      = "Failed"
+)
+
+// DeploymentInfo represents deployment information for the dashboard.
+type DeploymentInfo struct {
+	Name              string            `json:"name"`
+	Namespace         string            `json:"namespace"`
+	DesiredReplicas   int32             `json:"desiredReplicas"`
+	CurrentReplicas   int32             `json:"currentReplicas"`
+	UpdatedReplicas   int32             `json:"updatedReplicas"`
+	ReadyReplicas     int32             `json:"readyReplicas"`
+	AvailableReplicas int32             `json:"availableReplicas"`
+	RolloutStatus     string            `json:"rolloutStatus"`
+	RolloutMessage    string            `json:"rolloutMessage,omitempty"`
+	Images            []string          `json:"images"`
+	ImageTags         []string          `json:"imageTags"`
+	Strategy          string            `json:"strategy"`
+	MaxSurge          string            `json:"maxSurge,omitempty"`
+	MaxUnavailable    string            `json:"maxUnavailable,omitempty"`
+	CreatedAt         string            `json:"createdAt"`
+	Age               string            `json:"age"`
+	AgeSeconds        int64             `json:"ageSeconds"`
+	Labels            map[string]string `json:"labels,omitempty"`
+	RunbookURL        string            `json:"runbookUrl,omitempty"`
+	DerivedStatuses   []string          `json:"derivedStatuses,omitempty"`
+	CreateFailure     *CreateFailure    `json:"createFailure,omitempty"`
+}
+
+// Workload kinds that can be scaled.
+const (
+	KindDeployment  = "Deployment"
+	KindStatefulSet = "StatefulSet"
+)
+
+// ScaleInfo describes the result of a scale operation.
+type ScaleInfo struct {
+	Kind             string `json:"kind"`
+	Name             string `json:"name"`
+	Namespace        string `json:"namespace"`
+	PreviousReplicas int32  `json:"previousReplicas"`
+	Replicas         int32  `json:"replicas"`
+}
+
+// DeploymentService handles deployment-related operations.
+type DeploymentService struct {
+	config            ServerConfig
+	derivedStatuses   *DerivedStatuses
+	kubeConfigService ClientFactory
+	namespacePolicy   *NamespacePolicy
+	logger            *zap.Logger
+}
+
+// NewDeploymentService creates a new deployment service.
+func NewDeploymentService(config ServerConfig, derivedStatuses *DerivedStatuses, kubeConfigService ClientFactory, logger *zap.Logger) (service *DeploymentService) {
+	service = &DeploymentService{
+		config:            config,
+		derivedStatuses:   derivedStatuses,
+		kubeConfigService: kubeConfigService,
+		namespacePolicy:   serverNamespacePolicy(config),
+		logger:            logger,
+	}
+	return service
+}
+
+// GetDeployments retrieves deployments from the specified namespace with an optional label selector and cluster.
+// Use namespace="all" to retrieve deployments from all namespaces.
+func (ds *DeploymentService) GetDeployments(ctx context.Context, clusterName, namespace, labelSelector string) (deploymentInfos []DeploymentInfo, err error) {
+	err = ds.namespacePolicy.Check(namespace)
+	if err != nil {
+		return deploymentInfos, err
+	}
+
+	var client kubernetes.Interface
+	client, err = ds.kubeConfigService.GetClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return deploymentInfos, err
+	}
+
+	queryNamespace := namespace
+	if namespace == "all" {
+		queryNamespace = ""
+	}
+
+	var deployments *appsv1.DeploymentList
+	deployments, err = client.AppsV1().Deployments(queryNamespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		ds.logger.Error("Failed to list deployments", zap.Error(err), zap.String("cluster", clusterName), zap.String("namespace", namespace), zap.String("labelSelector", labelSelector))
+		err = fmt.Errorf("failed to list deployments: %w", err)
+		return deploymentInfos, err
+	}
+
+	view := timeViewFromContext(ctx)
+	deploymentInfos = make([]DeploymentInfo, 0, len(deployments.Items))
+	for i := range deployments.Items {
+		if !ds.namespacePolicy.Allows(deployments.Items[i].Namespace) {
+			continue
+		}
+		info := deploymentToDeploymentInfo(&deployments.Items[i], ds.config.RunbookAnnotation, view)
+		info.DerivedStatuses = ds.derivedStatuses.ForDeployment(info)
+		deploymentInfos = append(deploymentInfos, info)
+	}
+	ds.attachReplicaSetCreateFailures(ctx, client, clusterName, queryNamespace, deploymentInfos)
+
+	return deploymentInfos, err
+}
+
+func deploymentToDeploymentInfo(deployment *appsv1.Deployment, runbookAnnotation string, view timeView) (info DeploymentInfo) {
+	// A nil replica count defaults to 1 in the API server.
+	desired := int32(1)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+
+	images := make([]string, 0, len(deployment.Spec.Template.Spec.Containers))
+	imageTags := make([]string, 0, len(deployment.Spec.Template.Spec.Containers))
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		images = append(images, container.Image)
+		imageTags = append(imageTags, imageTagFromImage(container.Image))
+	}
+
+	rolloutStatus, rolloutMessage := deploymentRolloutStatus(deployment, desired)
+
+	info = DeploymentInfo{
+		Name:              deployment.Name,
+		Namespace:         deployment.Namespace,
+		DesiredReplicas:   desired,
+		CurrentReplicas:   deployment.Status.Replicas,
+		UpdatedReplicas:   deployment.Status.UpdatedReplicas,
+		ReadyReplicas:     deployment.Status.ReadyReplicas,
+		AvailableReplicas: deployment.Status.AvailableReplicas,
+		RolloutStatus:     rolloutStatus,
+		RolloutMessage:    rolloutMessage,
+		Images:            images,
+		ImageTags:         imageTags,
+		Strategy:          string(deployment.Spec.Strategy.Type),
+		CreatedAt:         view.format(deployment.CreationTimestamp.Time),
+		Age:               view.age(deployment.CreationTimestamp.Time),
+		AgeSeconds:        view.ageSeconds(deployment.CreationTimestamp.Time),
+		Labels:            deployment.Labels,
+		RunbookURL:        runbookURL(deployment.Annotations, runbookAnnotation),
+		CreateFailure:     deploymentCreateFailure(deployment, view),
+	}
+
+	if rollingUpdate := deployment.Spec.Strategy.RollingUpdate; rollingUpdate != nil {
+		if rollingUpdate.MaxSurge != nil {
+			info.MaxSurge = rollingUpdate.MaxSurge.String()
+		}
+		if rollingUpdate.MaxUnavailable != nil {
+			info.MaxUnavailable = rollingUpdate.MaxUnavailable.String()
+		}
+	}
+
+	return info
+}
+
+// deploymentRolloutStatus mirrors the checks performed by 'kubectl rollout status'.
+func deploymentRolloutStatus(deployment *appsv1.Deployment, desired int32) (status, message string) {
+	if deployment.Spec.Paused {
+		status = RolloutPaused
+		message = "rollout is paused"
+		return status, message
+	}
+
+	if deployment.Generation > deployment.Status.ObservedGeneration {
+		status = RolloutProgressing
+		message = "waiting for deployment spec update to be observed"
+		return status, message
+	}
+
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing && condition.Status == corev1.ConditionFalse && condition.Reason == "ProgressDeadlineExceeded" {
+			status = RolloutFailed
+			message = condition.Message
+			return status, message
+		}
+	}
+
+	updated := deployment.Status.UpdatedReplicas
+	switch {
+	case updated < desired:
+		status = RolloutProgressing
+		message = fmt.Sprintf("%d of %d updated replicas are available", deployment.Status.AvailableReplicas, desired)
+	case deployment.Status.Replicas > updated:
+		status = RolloutProgressing
+		message = fmt.Sprintf("%d old replicas are pending termination", deployment.Status.Replicas-updated)
+	case deployment.Status.AvailableReplicas < updated:
+		status = RolloutProgressing
+		message = fmt.Sprintf("%d of %d updated replicas are available", deployment.Status.AvailableReplicas, updated)
+	default:
+		status = RolloutComplete
+	}
+
+	return status, message
+}
+
+// ScaleWorkload sets the replica count of a Deployment or StatefulSet using the scale subresource.
+func (ds *DeploymentService) ScaleWorkload(ctx context.Context, clusterName, kind, namespace, name string, replicas int32) (info ScaleInfo, err error) {
+	if replicas < 0 {
+		err = fmt.Errorf("replicas must not be negative, got %d", replicas)
+		return info, err
+	}
+
+	var client kubernetes.Interface
+	client, err = ds.kubeConfigService.GetClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return info, err
+	}
+
+	var scale *autoscalingv1.Scale
+	switch kind {
+	case KindDeployment:
+		scale, err = client.AppsV1().Deployments(namespace).GetScale(ctx, name, metav1.GetOptions{})
+	case KindStatefulSet:
+		scale, err = client.AppsV1().StatefulSets(namespace).GetScale(ctx, name, metav1.GetOptions{})
+	default:
+		err = fmt.Errorf("unsupported workload kind %q", kind)
+		return info, err
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to get scale for %s %s/%s: %w", kind, namespace, name, err)
+		return info, err
+	}
+
+	info = ScaleInfo{
+		Kind:             kind,
+		Name:             name,
+		Namespace:        namespace,
+		PreviousReplicas: scale.Spec.Replicas,
+		Replicas:         replicas,
+	}
+
+	scale.Spec.Replicas = replicas
+	if kind == KindStatefulSet {
+		_, err = client.AppsV1().StatefulSets(namespace).UpdateScale(ctx, name, scale, metav1.UpdateOptions{})
+	} else {
+		_, err = client.AppsV1().Deployments(namespace).UpdateScale(ctx, name, scale, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		ds.logger.Error("Failed to scale workload", zap.Error(err), zap.String("cluster", clusterName), zap.String("kind", kind), zap.String("namespace", namespace), zap.String("name", name))
+		err = fmt.Errorf("failed to scale %s %s/%s: %w", kind, namespace, name, err)
+		return info, err
+	}
+
+	ds.logger.Info("Workload scaled", zap.String("cluster", clusterName), zap.String("kind", kind), zap.String("namespace", namespace), zap.String("name", name), zap.Int32("previousReplicas", info.PreviousReplicas), zap.Int32("replicas", replicas), zap.String("user", identityUser(ctx)), zap.String("requestId", requestID(ctx)))
+	return info, err
+}
+
+// scaleReverter restores a workload's replica count, unless it was changed again since.
+func (ds *DeploymentService) scaleReverter(clusterName string, info ScaleInfo) (fn revertFunc) {
+	fn = func(ctx context.Context) (state, message string) {
+		client, err := ds.kubeConfigService.GetClient(ctx, clusterName)
+		if err != nil {
+			state = RevertFailed
+			message = err.Error()
+			return state, message
+		}
+
+		var scale *autoscalingv1.Scale
+		if info.Kind == KindStatefulSet {
+			scale, err = client.AppsV1().StatefulSets(info.Namespace).GetScale(ctx, info.Name, metav1.GetOptions{})
+		} else {
+			scale, err = client.AppsV1().Deployments(info.Namespace).GetScale(ctx, info.Name, metav1.GetOptions{})
+		}
+		if err != nil {
+			state = RevertFailed
+			message = err.Error()
+			return state, message
+		}
+		if scale.Spec.Replicas != info.Replicas {
+			state = RevertSkipped
+			message = "replicas were changed after the temporary change"
+			return state, message
+		}
+
+		_, err = ds.ScaleWorkload(ctx, clusterName, info.Kind, info.Namespace, info.Name, info.PreviousReplicas)
+		if err != nil {
+			state = RevertFailed
+			message = err.Error()
+			return state, message
+		}
+		state = RevertDone
+		return state, message
+	}
+	return fn
+}
@@ -0,0 +1,177 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"go.uber.org/zap"
+)
+
+// DerivedStatusConfig names a status computed from pod or deployment fields, so conventions such as
+// "Degraded means some but not all replicas are ready" are shared rather than kept in people's heads.
+// When is a Go template rendered with the PodInfo or DeploymentInfo; the status applies when it renders "true".
+type DerivedStatusConfig struct {
+	Name string `json:"name" yaml:"name"`
+	Kind string `json:"kind" yaml:"kind"`
+	When string `json:"when" yaml:"when"`
+}
+
+// derivedStatus is a validated DerivedStatusConfig with its condition parsed.
+type derivedStatus struct {
+	config DerivedStatusConfig
+	when   *template.Template
+}
+
+// DerivedStatuses evaluates the configured derived statuses against pods and deployments.
+type DerivedStatuses struct {
+	statuses []derivedStatus
+	logger   *zap.Logger
+}
+
+// derivedStatusFuncs are the helper functions available to derived status conditions.
+//
+//nolint:gochecknoglobals // Read-only lookup table
+var derivedStatusFuncs = template.FuncMap{
+	"readyContainers": func(ready string) (count int) {
+		count, _ = parseReady(ready)
+		return count
+	},
+	"totalContainers": func(ready string) (count int) {
+		_, count = parseReady(ready)
+		return count
+	},
+}
+
+// NewDerivedStatuses validates the configured derived statuses. Conditions are tried against empty pod and
+// deployment data so references to unknown fields are reported at startup rather than on every request.
+func NewDerivedStatuses(configs []DerivedStatusConfig, logger *zap.Logger) (statuses *DerivedStatuses, err error) {
+	statuses = &DerivedStatuses{logger: logger}
+
+	seen := make(map[string]bool)
+	for _, config := range configs {
+		if config.Name == "" {
+			err = errors.New("derived status with empty name")
+			return statuses, err
+		}
+		if config.Kind == "" {
+			config.Kind = ActionKindPod
+		}
+		if config.Kind != ActionKindPod && config.Kind != ActionKindDeployment {
+			err = fmt.Errorf("derived status %q: kind must be %q or %q", config.Name, ActionKindPod, ActionKindDeployment)
+			return statuses, err
+		}
+		if config.When == "" {
+			err = fmt.Errorf("derived status %q: when is required", config.Name)
+			return statuses, err
+		}
+
+		key := config.Kind + "/" + config.Name
+		if seen[key] {
+			err = fmt.Errorf("derived status %q: duplicate name for kind %s", config.Name, config.Kind)
+			return statuses, err
+		}
+		seen[key] = true
+
+		parsed := derivedStatus{config: config}
+		parsed.when, err = template.New(config.Name).Funcs(derivedStatusFuncs).Option("missingkey=error").Parse(config.When)
+		if err != nil {
+			err = fmt.Errorf("derived status %q: invalid when template: %w", config.Name, err)
+			return statuses, err
+		}
+
+		var sample interface{} = PodInfo{Usage: &PodUsage{}}
+		if config.Kind == ActionKindDeployment {
+			sample = DeploymentInfo{}
+		}
+		_, err = evaluateCondition(parsed.when, sample)
+		if err != nil {
+			err = fmt.Errorf("derived status %q: %w", config.Name, err)
+			return statuses, err
+		}
+
+		statuses.statuses = append(statuses.statuses, parsed)
+	}
+
+	return statuses, err
+}
+
+// ForPod returns the names of the derived statuses that apply to a pod.
+func (ds *DerivedStatuses) ForPod(info PodInfo) (names []string) {
+	names = ds.evaluate(ActionKindPod, info)
+	return names
+}
+
+// ForDeployment returns the names of the derived statuses that apply to a deployment.
+func (ds *DerivedStatuses) ForDeployment(info DeploymentInfo) (names []string) {
+	names = ds.evaluate(ActionKindDeployment, info)
+	return names
+}
+
+// evaluate returns the names of the derived statuses of the given kind whose condition holds for data.
+// A condition that fails to render is logged and treated as not matching.
+func (ds *DerivedStatuses) evaluate(kind string, data interface{}) (names []string) {
+	if ds == nil {
+		return names
+	}
+
+	for _, status := range ds.statuses {
+		if status.config.Kind != kind {
+			continue
+		}
+
+		matched, err := evaluateCondition(status.when, data)
+		if err != nil {
+			ds.logger.Debug("Failed to evaluate derived status", zap.String("status", status.config.Name), zap.Error(err))
+			continue
+		}
+		if matched {
+			names = append(names, status.config.Name)
+		}
+	}
+
+	return names
+}
+
+// evaluateCondition renders a condition template and reports whether it produced "true".
+func evaluateCondition(when *template.Template, data interface{}) (matched bool, err error) {
+	var buf bytes.Buffer
+	err = when.Execute(&buf, data)
+	if err != nil {
+		err = fmt.Errorf("failed to evaluate when template: %w", err)
+		return matched, err
+	}
+
+	matched = strings.TrimSpace(buf.String()) == "true"
+	return matched, err
+}
+
+// parseReady splits a pod's "ready/total" container count.
+func parseReady(ready string) (readyContainers, totalContainers int) {
+	_, _ = fmt.Sscanf(ready, "%d/%d", &readyContainers, &totalContainers)
+	return readyContainers, totalContainers
+}
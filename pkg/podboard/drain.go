@@ -0,0 +1,108 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DrainResult is the outcome of draining a node.
+type DrainResult struct {
+	Node    string         `json:"node"`
+	Pods    []MigrationPod `json:"pods"`
+	Evicted int            `json:"evicted"`
+	Failed  int            `json:"failed"`
+}
+
+// DrainJob returns a job function that drains a node like kubectl drain: it cordons the node and evicts its
+// pods one at a time, leaving DaemonSet, static and finished pods alone. Evictions respect
+// PodDisruptionBudgets and are retried like a migration's. Cancelling the job stops it before the next pod;
+// the node stays cordoned.
+func (ms *MigrationService) DrainJob(clusterName, nodeName string) (fn jobFunc) {
+	fn = func(ctx context.Context, report func(progress JobProgress)) (result interface{}, err error) {
+		var client kubernetes.Interface
+		client, err = ms.kubeConfigService.GetClient(ctx, clusterName)
+		if err != nil {
+			err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+			return result, err
+		}
+
+		err = ms.cordonNodes(ctx, client, clusterName, []string{nodeName})
+		if err != nil {
+			return result, err
+		}
+
+		var drain DrainResult
+		drain, err = drainPlan(ctx, client, nodeName)
+		if err != nil {
+			return result, err
+		}
+
+		report(JobProgress{Total: len(drain.Pods)})
+		for i := range drain.Pods {
+			if ctx.Err() != nil {
+				break
+			}
+			drain.Pods[i].State, drain.Pods[i].Message = ms.evict(ctx, client, drain.Pods[i], defaultMigrationEvictionTimeout)
+			switch drain.Pods[i].State {
+			case MigrationPodEvicted:
+				drain.Evicted++
+			case MigrationPodFailed:
+				drain.Failed++
+			}
+			report(JobProgress{Done: i + 1, Total: len(drain.Pods), Message: fmt.Sprintf("%d evicted, %d failed", drain.Evicted, drain.Failed)})
+		}
+
+		result = drain
+		if drain.Failed > 0 {
+			err = fmt.Errorf("failed to evict %d of %d pods from node %s", drain.Failed, len(drain.Pods), nodeName)
+		}
+		return result, err
+	}
+	return fn
+}
+
+// drainPlan lists the pods draining a node evicts.
+func drainPlan(ctx context.Context, client kubernetes.Interface, nodeName string) (drain DrainResult, err error) {
+	drain = DrainResult{Node: nodeName, Pods: []MigrationPod{}}
+
+	var pods *corev1.PodList
+	pods, err = client.CoreV1().Pods("").List(ctx, metav1.ListOptions{FieldSelector: fields.OneTermEqualSelector("spec.nodeName", nodeName).String()})
+	if err != nil {
+		err = fmt.Errorf("failed to list pods on node %s: %w", nodeName, err)
+		return drain, err
+	}
+
+	for i := range pods.Items {
+		if evictable(&pods.Items[i]) {
+			drain.Pods = append(drain.Pods, MigrationPod{Namespace: pods.Items[i].Namespace, Name: pods.Items[i].Name, Node: nodeName, State: MigrationPodPending})
+		}
+	}
+	return drain, err
+}
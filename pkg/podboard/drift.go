@@ -0,0 +1,339 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Sources of the declared configuration a workload is compared with.
+const (
+	DriftSourceLastApplied   = "lastApplied"
+	DriftSourceManagedFields = "managedFields"
+)
+
+// DriftReport compares a workload's declared configuration with the live object. Changes are the fields whose
+// live values differ from the kubectl.kubernetes.io/last-applied-configuration annotation. ManualEdits are the
+// fields managedFields records as last written by interactive kubectl commands such as edit, patch or set,
+// which catches manual changes to workloads deployed with server-side apply too.
+type DriftReport struct {
+	Kind        string        `json:"kind"`
+	Namespace   string        `json:"namespace"`
+	Name        string        `json:"name"`
+	Sources     []string      `json:"sources"`
+	Drifted     bool          `json:"drifted"`
+	Changes     []DriftChange `json:"changes"`
+	ManualEdits []ManualEdit  `json:"manualEdits"`
+}
+
+// DriftChange is a declared field whose live value differs. Live is null when the field is gone.
+type DriftChange struct {
+	Path     string      `json:"path"`
+	Declared interface{} `json:"declared"`
+	Live     interface{} `json:"live"`
+}
+
+// ManualEdit is a set of fields last written by an interactive kubectl command.
+type ManualEdit struct {
+	Manager string   `json:"manager"`
+	Time    string   `json:"time,omitempty"`
+	Fields  []string `json:"fields"`
+}
+
+// GetDrift compares a Deployment, StatefulSet or DaemonSet with its declared configuration.
+func (ds *DeploymentService) GetDrift(ctx context.Context, clusterName, kind, namespace, name string) (report DriftReport, err error) {
+	var client kubernetes.Interface
+	client, err = ds.kubeConfigService.GetClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return report, err
+	}
+
+	var object metav1.Object
+	switch kind {
+	case KindDeployment:
+		object, err = client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	case KindStatefulSet:
+		object, err = client.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	case KindDaemonSet:
+		object, err = client.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	default:
+		err = fmt.Errorf("unsupported workload kind %q", kind)
+		return report, err
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to get %s %s/%s: %w", kind, namespace, name, err)
+		return report, err
+	}
+
+	report, err = workloadDrift(kind, object, timeViewFromContext(ctx))
+	return report, err
+}
+
+// workloadDrift builds the drift report of a live workload.
+func workloadDrift(kind string, object metav1.Object, view timeView) (report DriftReport, err error) {
+	report = DriftReport{
+		Kind:        kind,
+		Namespace:   object.GetNamespace(),
+		Name:        object.GetName(),
+		Sources:     make([]string, 0),
+		Changes:     make([]DriftChange, 0),
+		ManualEdits: manualEdits(object.GetManagedFields(), view),
+	}
+	if len(object.GetManagedFields()) > 0 {
+		report.Sources = append(report.Sources, DriftSourceManagedFields)
+	}
+
+	lastApplied, found := object.GetAnnotations()[corev1.LastAppliedConfigAnnotation]
+	if found {
+		report.Sources = append(report.Sources, DriftSourceLastApplied)
+
+		var declared map[string]interface{}
+		var live interface{}
+		err = json.Unmarshal([]byte(lastApplied), &declared)
+		if err != nil {
+			err = fmt.Errorf("invalid %s annotation: %w", corev1.LastAppliedConfigAnnotation, err)
+			return report, err
+		}
+		// Typed clients drop the type meta, which can't drift anyway.
+		delete(declared, "apiVersion")
+		delete(declared, "kind")
+		// A JSON round trip gives the live object the same shape as the declared one.
+		var raw []byte
+		raw, err = json.Marshal(object)
+		if err != nil {
+			return report, err
+		}
+		err = json.Unmarshal(raw, &live)
+		if err != nil {
+			return report, err
+		}
+		report.Changes = diffDeclared("", declared, live, report.Changes)
+	}
+
+	report.Drifted = len(report.Changes) > 0 || len(report.ManualEdits) > 0
+	return report, err
+}
+
+// diffDeclared appends the fields of declared whose values differ in live. Only declared fields are compared,
+// since the live object also holds defaults and fields set by controllers. Lists of objects with a name, such
+// as containers and ports, are matched by name rather than position.
+func diffDeclared(path string, declared, live interface{}, changes []DriftChange) (diffs []DriftChange) {
+	diffs = changes
+	switch declaredValue := declared.(type) {
+	case map[string]interface{}:
+		liveMap, ok := live.(map[string]interface{})
+		if !ok {
+			diffs = append(diffs, DriftChange{Path: path, Declared: declared, Live: live})
+			return diffs
+		}
+		keys := make([]string, 0, len(declaredValue))
+		for key := range declaredValue {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			diffs = diffDeclared(joinDriftPath(path, key), declaredValue[key], liveMap[key], diffs)
+		}
+	case []interface{}:
+		diffs = diffDeclaredList(path, declaredValue, live, diffs)
+	case nil:
+		// Declared nulls, like metadata.creationTimestamp, declare nothing.
+	default:
+		if !driftEqual(declared, live) {
+			diffs = append(diffs, DriftChange{Path: path, Declared: declared, Live: live})
+		}
+	}
+	return diffs
+}
+
+// diffDeclaredList compares a declared list with the live one, by name when every element has one.
+func diffDeclaredList(path string, declared []interface{}, live interface{}, changes []DriftChange) (diffs []DriftChange) {
+	diffs = changes
+	liveList, ok := live.([]interface{})
+	if !ok {
+		diffs = append(diffs, DriftChange{Path: path, Declared: declared, Live: live})
+		return diffs
+	}
+
+	declaredNames, declaredNamed := listElementNames(declared)
+	liveNames, liveNamed := listElementNames(liveList)
+	if declaredNamed && liveNamed {
+		liveByName := make(map[string]interface{}, len(liveList))
+		for i, elementName := range liveNames {
+			liveByName[elementName] = liveList[i]
+		}
+		for i, elementName := range declaredNames {
+			diffs = diffDeclared(fmt.Sprintf("%s[name=%s]", path, elementName), declared[i], liveByName[elementName], diffs)
+		}
+		return diffs
+	}
+
+	if len(declared) != len(liveList) {
+		diffs = append(diffs, DriftChange{Path: path, Declared: declared, Live: live})
+		return diffs
+	}
+	for i := range declared {
+		diffs = diffDeclared(path+"["+strconv.Itoa(i)+"]", declared[i], liveList[i], diffs)
+	}
+	return diffs
+}
+
+// listElementNames returns the name of each element of a list of objects, and whether every element has one.
+func listElementNames(list []interface{}) (names []string, named bool) {
+	for _, element := range list {
+		object, isObject := element.(map[string]interface{})
+		if !isObject {
+			return names, named
+		}
+		name, isString := object["name"].(string)
+		if !isString {
+			return names, named
+		}
+		names = append(names, name)
+	}
+	named = len(names) > 0
+	return names, named
+}
+
+// driftEqual compares declared and live scalars, treating quantities like "0.5" and "500m" as equal.
+func driftEqual(declared, live interface{}) (equal bool) {
+	if reflect.DeepEqual(declared, live) {
+		equal = true
+		return equal
+	}
+	declaredString, declaredIsString := declared.(string)
+	liveString, liveIsString := live.(string)
+	if !declaredIsString || !liveIsString {
+		return equal
+	}
+	declaredQuantity, declaredErr := resource.ParseQuantity(declaredString)
+	liveQuantity, liveErr := resource.ParseQuantity(liveString)
+	equal = declaredErr == nil && liveErr == nil && declaredQuantity.Cmp(liveQuantity) == 0
+	return equal
+}
+
+// joinDriftPath appends a field to a dotted path.
+func joinDriftPath(path, field string) (joined string) {
+	if path == "" {
+		joined = field
+		return joined
+	}
+	joined = path + "." + field
+	return joined
+}
+
+// manualEdits returns the fields last written by interactive kubectl commands, newest first. Declarative
+// managers, such as kubectl apply, Helm, Argo CD or Flux, and controllers writing status aren't edits.
+func manualEdits(entries []metav1.ManagedFieldsEntry, view timeView) (edits []ManualEdit) {
+	edits = make([]ManualEdit, 0)
+	interactive := make([]metav1.ManagedFieldsEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Operation == metav1.ManagedFieldsOperationUpdate && entry.Subresource == "" && interactiveManager(entry.Manager) && entry.FieldsV1 != nil {
+			interactive = append(interactive, entry)
+		}
+	}
+	sort.SliceStable(interactive, func(i, j int) (less bool) {
+		less = interactive[i].Time != nil && (interactive[j].Time == nil || interactive[i].Time.After(interactive[j].Time.Time))
+		return less
+	})
+
+	for _, entry := range interactive {
+		var fields map[string]interface{}
+		if json.Unmarshal(entry.FieldsV1.Raw, &fields) != nil {
+			continue
+		}
+		edit := ManualEdit{Manager: entry.Manager, Fields: managedFieldPaths("", fields, nil)}
+		if entry.Time != nil {
+			edit.Time = view.format(entry.Time.Time)
+		}
+		edits = append(edits, edit)
+	}
+	return edits
+}
+
+// interactiveManager reports whether a field manager is an interactive kubectl command. kubectl apply
+// records itself as kubectl-client-side-apply, or as kubectl with the Apply operation.
+func interactiveManager(manager string) (interactive bool) {
+	interactive = strings.HasPrefix(manager, "kubectl-") && manager != "kubectl-client-side-apply"
+	return interactive
+}
+
+// managedFieldPaths flattens a managedFields field set into dotted paths. Keyed list elements like
+// k:{"name":"app"} become [name=app], and . marks a field owned as a whole.
+func managedFieldPaths(path string, fields map[string]interface{}, paths []string) (flattened []string) {
+	flattened = paths
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if key == "." {
+			continue
+		}
+		child := managedFieldPath(path, key)
+		children, _ := fields[key].(map[string]interface{})
+		if len(children) == 0 || children["."] != nil && len(children) == 1 {
+			flattened = append(flattened, child)
+			continue
+		}
+		flattened = managedFieldPaths(child, children, flattened)
+	}
+	return flattened
+}
+
+// managedFieldPath appends a managedFields key to a path.
+func managedFieldPath(path, key string) (joined string) {
+	prefix, value, _ := strings.Cut(key, ":")
+	switch prefix {
+	case "k":
+		var element map[string]interface{}
+		if json.Unmarshal([]byte(value), &element) == nil {
+			parts := make([]string, 0, len(element))
+			for field, fieldValue := range element {
+				parts = append(parts, fmt.Sprintf("%s=%v", field, fieldValue))
+			}
+			sort.Strings(parts)
+			joined = path + "[" + strings.Join(parts, ",") + "]"
+			return joined
+		}
+	case "v", "i":
+		joined = path + "[" + value + "]"
+		return joined
+	}
+	joined = joinDriftPath(path, value)
+	return joined
+}
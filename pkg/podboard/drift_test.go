@@ -0,0 +1,148 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package podboard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// driftLastApplied is the configuration the drift fixtures were applied with.
+const driftLastApplied = `{"apiVersion":"apps/v1","kind":"Deployment",` +
+	`"metadata":{"name":"web","namespace":"default","creationTimestamp":null},` +
+	`"spec":{"replicas":3,"template":{"spec":{"containers":[` +
+	`{"name":"web","image":"web:1.0","resources":{"requests":{"cpu":"0.5"}}}]}}}}`
+
+// driftDeployment returns a live Deployment applied with driftLastApplied, as the API server returns it.
+func driftDeployment() (deployment *appsv1.Deployment) {
+	replicas := int32(3)
+	deployment = &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "web",
+			Namespace:   "default",
+			Annotations: map[string]string{corev1.LastAppliedConfigAnnotation: driftLastApplied},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name:      "web",
+				Image:     "web:1.0",
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")}},
+			}}}},
+		},
+	}
+	return deployment
+}
+
+func TestGetDrift(t *testing.T) {
+	editedAt := metav1.NewTime(time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name        string
+		modify      func(deployment *appsv1.Deployment)
+		changes     []DriftChange
+		manualEdits []ManualEdit
+	}{
+		{
+			name:   "matching spec",
+			modify: func(*appsv1.Deployment) {},
+		},
+		{
+			name: "undeclared fields ignored",
+			modify: func(deployment *appsv1.Deployment) {
+				deployment.Labels = map[string]string{"team": "checkout"}
+				deployment.Spec.Strategy.Type = appsv1.RollingUpdateDeploymentStrategyType
+				deployment.Spec.Template.Spec.Containers[0].ImagePullPolicy = corev1.PullIfNotPresent
+				deployment.Spec.Template.Spec.Containers = append(deployment.Spec.Template.Spec.Containers, corev1.Container{Name: "proxy", Image: "envoy"})
+				deployment.Status.ReadyReplicas = 2
+			},
+		},
+		{
+			name: "changed image",
+			modify: func(deployment *appsv1.Deployment) {
+				deployment.Spec.Template.Spec.Containers[0].Image = "web:1.1"
+			},
+			changes: []DriftChange{{Path: "spec.template.spec.containers[name=web].image", Declared: "web:1.0", Live: "web:1.1"}},
+		},
+		{
+			name: "changed replicas",
+			modify: func(deployment *appsv1.Deployment) {
+				replicas := int32(5)
+				deployment.Spec.Replicas = &replicas
+			},
+			changes: []DriftChange{{Path: "spec.replicas", Declared: float64(3), Live: float64(5)}},
+		},
+		{
+			name: "manual edit",
+			modify: func(deployment *appsv1.Deployment) {
+				deployment.ManagedFields = []metav1.ManagedFieldsEntry{
+					{Manager: "kubectl-client-side-apply", Operation: metav1.ManagedFieldsOperationUpdate, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:replicas":{}}}`)}},
+					{Manager: "kubectl-edit", Operation: metav1.ManagedFieldsOperationUpdate, Time: &editedAt, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:paused":{}}}`)}},
+				}
+			},
+			manualEdits: []ManualEdit{{Manager: "kubectl-edit", Time: "2026-05-01T12:00:00Z", Fields: []string{"spec.paused"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := driftDeployment()
+			tt.modify(deployment)
+			factory := NewStaticClientFactory(map[string]kubernetes.Interface{testCluster: fake.NewClientset(deployment)}, testCluster)
+			service := NewDeploymentService(ServerConfig{}, nil, factory, zap.NewNop())
+
+			report, err := service.GetDrift(t.Context(), testCluster, KindDeployment, "default", "web")
+			require.NoError(t, err)
+
+			changes := tt.changes
+			if changes == nil {
+				changes = []DriftChange{}
+			}
+			manualEdits := tt.manualEdits
+			if manualEdits == nil {
+				manualEdits = []ManualEdit{}
+			}
+			assert.Equal(t, changes, report.Changes)
+			assert.Equal(t, manualEdits, report.ManualEdits)
+			assert.Equal(t, len(changes) > 0 || len(manualEdits) > 0, report.Drifted)
+			assert.Contains(t, report.Sources, DriftSourceLastApplied)
+		})
+	}
+}
+
+func TestGetDriftUnsupportedKind(t *testing.T) {
+	factory := NewStaticClientFactory(map[string]kubernetes.Interface{testCluster: fake.NewClientset()}, testCluster)
+	service := NewDeploymentService(ServerConfig{}, nil, factory, zap.NewNop())
+
+	_, err := service.GetDrift(t.Context(), testCluster, "Job", "default", "web")
+	require.Error(t, err)
+}
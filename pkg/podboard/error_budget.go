@@ -0,0 +1,126 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultErrorBudgetWindow     = 2 * time.Minute
+	defaultErrorBudgetThreshold  = 0.2
+	defaultErrorBudgetMinSamples = 5
+	maxErrorBudgetSamples        = 1000
+)
+
+// ErrorBudget tracks recent Kubernetes API outcomes per cluster over a sliding window.
+// A cluster is considered degraded when its error rate within the window reaches the threshold,
+// which lets clients show "data may be stale or partial" rather than alternating between data and errors.
+type ErrorBudget struct {
+	mu         sync.Mutex
+	window     time.Duration
+	threshold  float64
+	minSamples int
+	outcomes   map[string][]apiOutcome
+}
+
+type apiOutcome struct {
+	at     time.Time
+	failed bool
+}
+
+// NewErrorBudget creates an error budget with the given window, error rate threshold, and the minimum
+// number of samples required before a cluster can be reported as degraded.
+func NewErrorBudget(window time.Duration, threshold float64, minSamples int) (budget *ErrorBudget) {
+	budget = &ErrorBudget{
+		window:     window,
+		threshold:  threshold,
+		minSamples: minSamples,
+		outcomes:   make(map[string][]apiOutcome),
+	}
+	return budget
+}
+
+// Record stores the outcome of an API call against a cluster. A nil error counts as a success.
+func (eb *ErrorBudget) Record(clusterName string, err error) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	now := time.Now()
+	outcomes := eb.prune(clusterName, now)
+	outcomes = append(outcomes, apiOutcome{at: now, failed: err != nil})
+	if len(outcomes) > maxErrorBudgetSamples {
+		outcomes = outcomes[len(outcomes)-maxErrorBudgetSamples:]
+	}
+	eb.outcomes[clusterName] = outcomes
+}
+
+// ErrorRate returns the fraction of failed calls for a cluster within the window and the number of samples.
+func (eb *ErrorBudget) ErrorRate(clusterName string) (rate float64, samples int) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	outcomes := eb.prune(clusterName, time.Now())
+	samples = len(outcomes)
+	if samples == 0 {
+		return rate, samples
+	}
+
+	var failures int
+	for _, outcome := range outcomes {
+		if outcome.failed {
+			failures++
+		}
+	}
+
+	rate = float64(failures) / float64(samples)
+	return rate, samples
+}
+
+// Degraded returns true if the cluster's recent error rate has exhausted its budget.
+func (eb *ErrorBudget) Degraded(clusterName string) (degraded bool) {
+	rate, samples := eb.ErrorRate(clusterName)
+	degraded = samples >= eb.minSamples && rate >= eb.threshold
+	return degraded
+}
+
+// prune drops outcomes older than the window. The caller must hold the lock.
+func (eb *ErrorBudget) prune(clusterName string, now time.Time) (outcomes []apiOutcome) {
+	outcomes = eb.outcomes[clusterName]
+	cutoff := now.Add(-eb.window)
+
+	first := 0
+	for first < len(outcomes) && outcomes[first].at.Before(cutoff) {
+		first++
+	}
+
+	outcomes = outcomes[first:]
+	if len(outcomes) == 0 {
+		delete(eb.outcomes, clusterName)
+		return outcomes
+	}
+
+	eb.outcomes[clusterName] = outcomes
+	return outcomes
+}
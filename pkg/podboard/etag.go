@@ -0,0 +1,122 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// volatileListFields are the fields of list responses that change with every request, such as ages, which
+// are left out of ETags so a listing that didn't change matches on the next poll.
+//
+//nolint:gochecknoglobals // Read-only lookup table
+var volatileListFields = map[string]bool{"age": true, "ageSeconds": true, "durationMs": true}
+
+// userKeyedListFields are the maps of list responses keyed by users, such as labels, which are part of the ETag
+// whatever their keys are called.
+//
+//nolint:gochecknoglobals // Read-only lookup table
+var userKeyedListFields = map[string]bool{"labels": true, "annotations": true}
+
+// respondWithETag sends a JSON response with a weak ETag over its content, or 304 Not Modified when the client's
+// If-None-Match already holds it. Ages are left out of the ETag, so a 304 keeps the ages of the client's copy;
+// createdAt lets clients keep them current.
+func respondWithETag(c *gin.Context, body interface{}) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	content, err := etagContent(raw)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	sum := sha256.Sum256(content)
+	etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+	// Responses depend on who is asking, so only the browser may keep them, and must revalidate every time.
+	c.Header("Cache-Control", "private, no-cache")
+
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", raw)
+}
+
+// etagMatches reports whether an If-None-Match header holds an ETag, comparing weakly as RFC 9110 requires.
+func etagMatches(ifNoneMatch, etag string) (matches bool) {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			matches = true
+			return matches
+		}
+	}
+	return matches
+}
+
+// etagContent returns the JSON body an ETag is computed over: the response without its volatile fields.
+func etagContent(raw []byte) (content []byte, err error) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	// Numbers are kept as written, so large ones aren't rounded into matching each other.
+	decoder.UseNumber()
+	err = decoder.Decode(&value)
+	if err != nil {
+		return content, err
+	}
+
+	stripVolatileFields(value)
+	content, err = json.Marshal(value)
+	return content, err
+}
+
+// stripVolatileFields removes the volatile fields from decoded JSON, leaving user-keyed maps as they are.
+func stripVolatileFields(value interface{}) {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, field := range typed {
+			switch {
+			case volatileListFields[key]:
+				delete(typed, key)
+			case !userKeyedListFields[key]:
+				stripVolatileFields(field)
+			}
+		}
+	case []interface{}:
+		for _, item := range typed {
+			stripVolatileFields(item)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package podboard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// etagResponse sends body through respondWithETag with an If-None-Match header, if any.
+func etagResponse(t *testing.T, body interface{}, ifNoneMatch string) (recorder *httptest.ResponseRecorder) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	recorder = httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/pods", nil)
+	if ifNoneMatch != "" {
+		c.Request.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	respondWithETag(c, body)
+	// The router writes the status of handlers that send no body once they return.
+	c.Writer.WriteHeaderNow()
+	return recorder
+}
+
+func TestRespondWithETagVolatileFields(t *testing.T) {
+	listing := func(age string, ageSeconds int64, labels map[string]string) (body interface{}) {
+		body = map[string]interface{}{
+			"pods":     []PodInfo{{Name: "web", Namespace: "default", Age: age, AgeSeconds: ageSeconds, Labels: labels}},
+			"branches": []map[string]interface{}{{"namespace": "default", "durationMs": ageSeconds}},
+		}
+		return body
+	}
+	base := etagResponse(t, listing("1m", 60, map[string]string{"age": "old"}), "").Header().Get("ETag")
+	require.NotEmpty(t, base)
+
+	tests := []struct {
+		name string
+		body interface{}
+		same bool
+	}{
+		{name: "identical", body: listing("1m", 60, map[string]string{"age": "old"}), same: true},
+		{name: "only ages and durations changed", body: listing("5m", 300, map[string]string{"age": "old"}), same: true},
+		{name: "label keyed age changed", body: listing("1m", 60, map[string]string{"age": "new"})},
+		{name: "label added", body: listing("1m", 60, map[string]string{"age": "old", "app": "web"})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			etag := etagResponse(t, tt.body, "").Header().Get("ETag")
+			if tt.same {
+				assert.Equal(t, base, etag)
+			} else {
+				assert.NotEqual(t, base, etag)
+			}
+		})
+	}
+}
+
+func TestRespondWithETagNotModified(t *testing.T) {
+	body := map[string]interface{}{"pods": []PodInfo{{Name: "web", Namespace: "default"}}}
+	etag := etagResponse(t, body, "").Header().Get("ETag")
+	require.Regexp(t, `^W/"[0-9a-f]{32}"$`, etag)
+	strong := etag[len("W/"):]
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		code        int
+	}{
+		{name: "no validator", code: http.StatusOK},
+		{name: "weak match", ifNoneMatch: etag, code: http.StatusNotModified},
+		{name: "strong form matches weakly", ifNoneMatch: strong, code: http.StatusNotModified},
+		{name: "match in a list", ifNoneMatch: `"other", ` + etag, code: http.StatusNotModified},
+		{name: "any", ifNoneMatch: "*", code: http.StatusNotModified},
+		{name: "other etag", ifNoneMatch: `W/"other"`, code: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := etagResponse(t, body, tt.ifNoneMatch)
+			require.Equal(t, tt.code, recorder.Code)
+			assert.Equal(t, etag, recorder.Header().Get("ETag"))
+			assert.Equal(t, "private, no-cache", recorder.Header().Get("Cache-Control"))
+			if tt.code == http.StatusNotModified {
+				assert.Empty(t, recorder.Body.String())
+				return
+			}
+			assert.NotEmpty(t, recorder.Body.String())
+		})
+	}
+}
+
+func TestEtagMatches(t *testing.T) {
+	const etag = `W/"abc"`
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		matches     bool
+	}{
+		{name: "empty"},
+		{name: "weak", ifNoneMatch: `W/"abc"`, matches: true},
+		{name: "strong", ifNoneMatch: `"abc"`, matches: true},
+		{name: "list", ifNoneMatch: `"xyz" , W/"abc"`, matches: true},
+		{name: "any", ifNoneMatch: "*", matches: true},
+		{name: "different", ifNoneMatch: `W/"abd"`},
+		{name: "unquoted", ifNoneMatch: "abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.matches, etagMatches(tt.ifNoneMatch, etag))
+		})
+	}
+}
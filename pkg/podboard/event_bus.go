@@ -0,0 +1,273 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Event bus protocols.
+const (
+	EventBusNATS = "nats"
+	EventBusMQTT = "mqtt"
+)
+
+const (
+	defaultEventBusTopic    = "podboard"
+	defaultEventBusClientID = "podboard"
+	eventBusDialTimeout     = 10 * time.Second
+	eventBusWriteTimeout    = 10 * time.Second
+	// eventBusQueueSize is how many events may wait to be published before new ones are dropped.
+	eventBusQueueSize = 1000
+	// eventBusRewatchDelay is how long to wait before watching again after a watch fails.
+	eventBusRewatchDelay = 10 * time.Second
+)
+
+// EventBusConfig configures publishing the pod stream to a NATS or MQTT broker.
+type EventBusConfig struct {
+	// Protocol is nats or mqtt. Empty disables publishing.
+	Protocol string `yaml:"protocol"`
+	// Address is the broker's host:port.
+	Address string `yaml:"address"`
+	// TLS connects to the broker over TLS.
+	TLS bool `yaml:"tls"`
+	// UsernameEnv and PasswordEnv name the environment variables holding the broker credentials, if any.
+	UsernameEnv string `yaml:"usernameEnv"`
+	PasswordEnv string `yaml:"passwordEnv"`
+	// Topic prefixes the subjects or topics events are published on (default podboard).
+	Topic string `yaml:"topic"`
+	// ClientID identifies podboard to the broker: the MQTT client ID or the NATS connection name (default podboard).
+	ClientID string `yaml:"clientID"`
+	// Retain publishes MQTT messages as retained, so new subscribers get each pod's latest state. Deleted pods
+	// clear their retained message.
+	Retain bool `yaml:"retain"`
+	// Cluster is the cluster whose pods are published (default: current context).
+	Cluster string `yaml:"cluster"`
+	// Namespace limits publishing to one namespace (default: all).
+	Namespace string `yaml:"namespace"`
+	// LabelSelector limits publishing to matching pods. The =~ and !~ regex operators are supported.
+	LabelSelector string `yaml:"labelSelector"`
+}
+
+// eventPublisher sends messages to a broker, connecting on first use and again after a failure.
+type eventPublisher interface {
+	Publish(topic []string, payload []byte, retain bool) (err error)
+	Close()
+}
+
+// EventBusService mirrors the pod stream to a NATS or MQTT broker, so wallboards and other consumers can follow
+// pod changes without polling podboard over HTTP.
+type EventBusService struct {
+	config     ServerConfig
+	bus        EventBusConfig
+	podService *PodService
+	publisher  eventPublisher
+	logger     *zap.Logger
+}
+
+// NewEventBusService validates the event bus configuration and creates a new event bus service.
+func NewEventBusService(config ServerConfig, bus EventBusConfig, podService *PodService, logger *zap.Logger) (service *EventBusService, err error) {
+	service = &EventBusService{config: config, podService: podService, logger: logger}
+
+	if bus.Protocol == "" {
+		if bus.Address != "" {
+			err = errors.New("eventBus: protocol is required")
+		}
+		return service, err
+	}
+	if bus.Address == "" {
+		err = errors.New("eventBus: address is required")
+		return service, err
+	}
+	_, _, err = net.SplitHostPort(bus.Address)
+	if err != nil {
+		err = fmt.Errorf("eventBus: address must be host:port: %w", err)
+		return service, err
+	}
+	_, err = parsePodSelector(bus.LabelSelector)
+	if err != nil {
+		err = fmt.Errorf("eventBus: %w", err)
+		return service, err
+	}
+
+	if bus.Topic == "" {
+		bus.Topic = defaultEventBusTopic
+	}
+	if bus.ClientID == "" {
+		bus.ClientID = defaultEventBusClientID
+	}
+	if bus.Namespace == "" {
+		bus.Namespace = "all"
+	}
+	service.bus = bus
+
+	switch bus.Protocol {
+	case EventBusNATS:
+		if bus.Retain {
+			err = errors.New("eventBus: retain is only supported with mqtt")
+			return service, err
+		}
+		service.publisher = &natsPublisher{bus: bus, logger: logger}
+	case EventBusMQTT:
+		service.publisher = &mqttPublisher{bus: bus}
+	default:
+		err = fmt.Errorf("eventBus: protocol must be %q or %q, got %q", EventBusNATS, EventBusMQTT, bus.Protocol)
+		return service, err
+	}
+
+	return service, err
+}
+
+// Run watches pods and publishes their stream events until the context is cancelled. Each pod's events go to
+// <topic>.<cluster>.<namespace>.<pod> on NATS and <topic>/<cluster>/<namespace>/<pod> on MQTT; resets go to
+// the topic itself. In offline mode nothing is published.
+func (ebs *EventBusService) Run(ctx context.Context) {
+	if ebs.publisher == nil {
+		return
+	}
+	err := ebs.config.CheckOutbound("event bus")
+	if err != nil {
+		ebs.logger.Warn("Pod events are not published", zap.Error(err))
+		return
+	}
+
+	queue := make(chan PodStreamEvent, eventBusQueueSize)
+	go ebs.publish(ctx, queue)
+
+	handler := func(event PodStreamEvent) {
+		if event.Type == PodStreamBookmark {
+			return
+		}
+		select {
+		case queue <- event:
+		default:
+			ebs.logger.Warn("Dropped pod event, publish queue is full", zap.String("type", event.Type))
+		}
+	}
+
+	go func() {
+		for ctx.Err() == nil {
+			watchErr := ebs.podService.WatchPods(ctx, ebs.bus.Cluster, ebs.bus.Namespace, ebs.bus.LabelSelector, "", handler)
+			if watchErr != nil {
+				ebs.logger.Warn("Event bus pod watch failed, retrying", zap.Error(watchErr))
+			}
+			sleepContext(ctx, eventBusRewatchDelay)
+		}
+	}()
+}
+
+// publish sends queued events to the broker. Events that fail to publish are logged and dropped; the next
+// event reconnects.
+func (ebs *EventBusService) publish(ctx context.Context, queue <-chan PodStreamEvent) {
+	defer ebs.publisher.Close()
+
+	cluster := ebs.bus.Cluster
+	if cluster == "" {
+		cluster = "default"
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-queue:
+			topic := []string{ebs.bus.Topic}
+			if event.Pod != nil {
+				topic = append(topic, cluster, event.Pod.Namespace, event.Pod.Name)
+			}
+			payload, err := json.Marshal(event)
+			if err != nil {
+				ebs.logger.Error("Failed to encode pod event", zap.Error(err))
+				continue
+			}
+			retain := ebs.bus.Retain && event.Pod != nil
+			if retain && event.Type == PodStreamDeleted {
+				// An empty retained message clears the pod's retained state.
+				payload = nil
+			}
+			err = ebs.publisher.Publish(topic, payload, retain)
+			if err != nil {
+				ebs.logger.Warn("Failed to publish pod event", zap.Error(err), zap.String("protocol", ebs.bus.Protocol), zap.String("address", ebs.bus.Address))
+			}
+		}
+	}
+}
+
+// dialEventBus connects to the broker, over TLS when configured.
+func dialEventBus(bus EventBusConfig) (conn net.Conn, err error) {
+	dialer := &net.Dialer{Timeout: eventBusDialTimeout}
+	if bus.TLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", bus.Address, eventBusTLSConfig(bus))
+	} else {
+		conn, err = dialer.Dial("tcp", bus.Address)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to connect to %s: %w", bus.Address, err)
+		return conn, err
+	}
+	return conn, err
+}
+
+// eventBusTLSConfig is the TLS configuration for connecting to the broker.
+func eventBusTLSConfig(bus EventBusConfig) (config *tls.Config) {
+	host, _, _ := net.SplitHostPort(bus.Address)
+	config = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	return config
+}
+
+// eventBusCredentials reads the broker credentials from the configured environment variables.
+func eventBusCredentials(bus EventBusConfig) (username, password string) {
+	if bus.UsernameEnv != "" {
+		username = os.Getenv(bus.UsernameEnv)
+	}
+	if bus.PasswordEnv != "" {
+		password = os.Getenv(bus.PasswordEnv)
+	}
+	return username, password
+}
+
+// topicToken makes a name safe to use as one level of a subject or topic by replacing the characters the
+// protocol gives meaning to.
+func topicToken(name, reserved string) (token string) {
+	token = strings.Map(func(r rune) (mapped rune) {
+		mapped = r
+		if r <= ' ' || strings.ContainsRune(reserved, r) {
+			mapped = '_'
+		}
+		return mapped
+	}, name)
+	if token == "" {
+		token = "_"
+	}
+	return token
+}
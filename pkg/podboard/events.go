@@ -0,0 +1,258 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	eventTypeAll     = "all"
+	defaultEventType = corev1.EventTypeWarning
+)
+
+// EventInfo represents a Kubernetes event for the dashboard.
+type EventInfo struct {
+	Type       string `json:"type"`
+	Reason     string `json:"reason"`
+	Message    string `json:"message"`
+	Count      int32  `json:"count"`
+	LastSeen   string `json:"lastSeen"`
+	Age        string `json:"age"`
+	AgeSeconds int64  `json:"ageSeconds"`
+	Source     string `json:"source,omitempty"`
+	Namespace  string `json:"namespace"`
+	Object     string `json:"object"`
+}
+
+// GetPodEvents retrieves the events whose involvedObject is the given pod, newest first.
+func (ps *PodService) GetPodEvents(ctx context.Context, clusterName, namespace, podName string) (events []EventInfo, err error) {
+	err = ps.namespacePolicy.Check(namespace)
+	if err != nil {
+		return events, err
+	}
+
+	var client kubernetes.Interface
+	client, err = ps.getClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return events, err
+	}
+
+	selector := fields.Set{
+		"involvedObject.kind": "Pod",
+		"involvedObject.name": podName,
+	}.AsSelector().String()
+
+	var eventList *corev1.EventList
+	eventList, err = client.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		ps.logger.Error("Failed to list pod events", zap.Error(err), zap.String("cluster", clusterName), zap.String("namespace", namespace), zap.String("pod", podName))
+		err = fmt.Errorf("failed to list events for pod %s/%s: %w", namespace, podName, err)
+		return events, err
+	}
+
+	events = eventsToEventInfos(eventList.Items, timeViewFromContext(ctx))
+	return events, err
+}
+
+// GetEvents retrieves recent events across a namespace, newest first.
+// The eventType filters by event type (Warning, Normal); "all" returns every type and "" defaults to Warning.
+// Use namespace="all" to retrieve events from all namespaces. A limit of 0 returns every event.
+func (ps *PodService) GetEvents(ctx context.Context, clusterName, namespace, eventType string, limit int) (events []EventInfo, err error) {
+	err = ps.namespacePolicy.Check(namespace)
+	if err != nil {
+		return events, err
+	}
+
+	var client kubernetes.Interface
+	client, err = ps.getClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return events, err
+	}
+
+	var eventList *corev1.EventList
+	eventList, err = client.CoreV1().Events(eventQueryNamespace(namespace)).List(ctx, eventListOptions(eventType))
+	if err != nil {
+		ps.logger.Error("Failed to list events", zap.Error(err), zap.String("cluster", clusterName), zap.String("namespace", namespace))
+		err = fmt.Errorf("failed to list events: %w", err)
+		return events, err
+	}
+
+	events = filterNamespaced(ps.namespacePolicy, eventsToEventInfos(eventList.Items, timeViewFromContext(ctx)), eventNamespace)
+	if limit > 0 && len(events) > limit {
+		events = events[:limit]
+	}
+
+	return events, err
+}
+
+// WatchEvents streams events across a namespace to the handler until the context is cancelled
+// or the watch is closed by the API server. Filtering follows the same rules as GetEvents.
+func (ps *PodService) WatchEvents(ctx context.Context, clusterName, namespace, eventType string, handler func(EventInfo)) (err error) {
+	err = ps.namespacePolicy.Check(namespace)
+	if err != nil {
+		return err
+	}
+
+	var client kubernetes.Interface
+	client, err = ps.getClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return err
+	}
+
+	var watcher watch.Interface
+	watcher, err = client.CoreV1().Events(eventQueryNamespace(namespace)).Watch(ctx, eventListOptions(eventType))
+	if err != nil {
+		ps.logger.Error("Failed to watch events", zap.Error(err), zap.String("cluster", clusterName), zap.String("namespace", namespace))
+		err = fmt.Errorf("failed to watch events: %w", err)
+		return err
+	}
+	defer watcher.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return err
+		case watchEvent, ok := <-watcher.ResultChan():
+			if !ok {
+				return err
+			}
+			if watchEvent.Type != watch.Added && watchEvent.Type != watch.Modified {
+				continue
+			}
+			event, isEvent := watchEvent.Object.(*corev1.Event)
+			if !isEvent || !ps.namespacePolicy.Allows(event.Namespace) {
+				continue
+			}
+			handler(eventToEventInfo(event, timeViewFromContext(ctx)))
+		}
+	}
+}
+
+// eventNamespace returns the namespace of an event, for filtering.
+func eventNamespace(event EventInfo) (namespace string) {
+	namespace = event.Namespace
+	return namespace
+}
+
+// eventQueryNamespace maps the "all" namespace to the empty string the Kubernetes API expects.
+func eventQueryNamespace(namespace string) (queryNamespace string) {
+	queryNamespace = namespace
+	if namespace == "all" {
+		queryNamespace = ""
+	}
+	return queryNamespace
+}
+
+// eventListOptions builds list options filtering events by type.
+func eventListOptions(eventType string) (options metav1.ListOptions) {
+	if eventType == "" {
+		eventType = defaultEventType
+	}
+	if eventType != eventTypeAll {
+		options.FieldSelector = fields.OneTermEqualSelector("type", eventType).String()
+	}
+	return options
+}
+
+// eventsToEventInfos converts Kubernetes events to EventInfos sorted newest first.
+func eventsToEventInfos(items []corev1.Event, view timeView) (events []EventInfo) {
+	sort.SliceStable(items, func(i, j int) (less bool) {
+		less = eventLastSeen(&items[i]).After(eventLastSeen(&items[j]))
+		return less
+	})
+
+	events = make([]EventInfo, 0, len(items))
+	for i := range items {
+		events = append(events, eventToEventInfo(&items[i], view))
+	}
+
+	return events
+}
+
+func eventToEventInfo(event *corev1.Event, view timeView) (info EventInfo) {
+	lastSeen := eventLastSeen(event)
+
+	count := event.Count
+	if event.Series != nil && event.Series.Count > count {
+		count = event.Series.Count
+	}
+	if count == 0 {
+		count = 1
+	}
+
+	source := event.Source.Component
+	if source == "" {
+		source = event.ReportingController
+	}
+
+	info = EventInfo{
+		Type:      event.Type,
+		Reason:    event.Reason,
+		Message:   event.Message,
+		Count:     count,
+		Source:    source,
+		Namespace: event.Namespace,
+		Object:    fmt.Sprintf("%s/%s", event.InvolvedObject.Kind, event.InvolvedObject.Name),
+	}
+
+	if !lastSeen.IsZero() {
+		info.LastSeen = view.format(lastSeen)
+		info.Age = view.age(lastSeen)
+		info.AgeSeconds = view.ageSeconds(lastSeen)
+	}
+
+	return info
+}
+
+// eventLastSeen returns the most recent observation time of an event.
+// Older clients only populate LastTimestamp while newer ones use EventTime and Series.
+func eventLastSeen(event *corev1.Event) (lastSeen time.Time) {
+	if event.Series != nil && !event.Series.LastObservedTime.IsZero() {
+		lastSeen = event.Series.LastObservedTime.Time
+		return lastSeen
+	}
+	if !event.LastTimestamp.IsZero() {
+		lastSeen = event.LastTimestamp.Time
+		return lastSeen
+	}
+	if !event.EventTime.IsZero() {
+		lastSeen = event.EventTime.Time
+		return lastSeen
+	}
+	lastSeen = event.FirstTimestamp.Time
+	return lastSeen
+}
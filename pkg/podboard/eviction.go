@@ -0,0 +1,174 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ErrEvictionBlocked is returned when the API server refuses an eviction, typically because it would violate a
+// PodDisruptionBudget. Retrying later may succeed once replacement pods are ready.
+var ErrEvictionBlocked = errors.New("eviction blocked")
+
+// EvictionBlocked explains a refused eviction. Causes are the reasons the API server gave, and DisruptionBudgets
+// the budgets covering the pod with their current state.
+type EvictionBlocked struct {
+	Message           string             `json:"message"`
+	Causes            []string           `json:"causes,omitempty"`
+	RetryAfterSeconds int32              `json:"retryAfterSeconds,omitempty"`
+	DisruptionBudgets []DisruptionBudget `json:"disruptionBudgets,omitempty"`
+}
+
+// DisruptionBudget is the state of a PodDisruptionBudget. DisruptionsAllowed of 0 blocks evictions.
+type DisruptionBudget struct {
+	Name               string `json:"name"`
+	MinAvailable       string `json:"minAvailable,omitempty"`
+	MaxUnavailable     string `json:"maxUnavailable,omitempty"`
+	CurrentHealthy     int32  `json:"currentHealthy"`
+	DesiredHealthy     int32  `json:"desiredHealthy"`
+	ExpectedPods       int32  `json:"expectedPods"`
+	DisruptionsAllowed int32  `json:"disruptionsAllowed"`
+}
+
+// EvictPod evicts a pod through the Eviction subresource, which unlike a delete respects PodDisruptionBudgets.
+// It returns ErrPodNotFound if the pod doesn't exist, and ErrEvictionBlocked with the reasons in blocked when
+// the API server refuses the eviction.
+func (ps *PodService) EvictPod(ctx context.Context, clusterName, namespace, podName string) (blocked *EvictionBlocked, err error) {
+	err = ps.namespacePolicy.Check(namespace)
+	if err != nil {
+		return blocked, err
+	}
+
+	var client kubernetes.Interface
+	client, err = ps.getClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return blocked, err
+	}
+
+	eviction := &policyv1.Eviction{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: podName}}
+	err = client.CoreV1().Pods(namespace).EvictV1(ctx, eviction)
+	switch {
+	case err == nil:
+		ps.logger.Info("Pod evicted", zap.String("cluster", clusterName), zap.String("namespace", namespace), zap.String("pod", podName), zap.String("user", identityUser(ctx)), zap.String("requestId", requestID(ctx)))
+		return blocked, err
+	case apierrors.IsNotFound(err):
+		err = fmt.Errorf("%w: %s/%s", ErrPodNotFound, namespace, podName)
+		return blocked, err
+	case apierrors.IsTooManyRequests(err):
+		blocked = evictionBlocked(err)
+		blocked.DisruptionBudgets = ps.podDisruptionBudgets(ctx, client, clusterName, namespace, podName)
+		ps.logger.Info("Pod eviction blocked", zap.String("cluster", clusterName), zap.String("namespace", namespace), zap.String("pod", podName), zap.String("reason", blocked.Message))
+		err = fmt.Errorf("%w: %s/%s: %s", ErrEvictionBlocked, namespace, podName, blocked.Message)
+		return blocked, err
+	default:
+		ps.logger.Error("Failed to evict pod", zap.Error(err), zap.String("cluster", clusterName), zap.String("namespace", namespace), zap.String("pod", podName))
+		err = fmt.Errorf("failed to evict pod %s/%s: %w", namespace, podName, err)
+		return blocked, err
+	}
+}
+
+// evictionBlocked extracts the message, causes and retry delay from the API server's 429 response.
+func evictionBlocked(err error) (blocked *EvictionBlocked) {
+	blocked = &EvictionBlocked{Message: err.Error()}
+
+	var statusErr apierrors.APIStatus
+	if !errors.As(err, &statusErr) {
+		return blocked
+	}
+	status := statusErr.Status()
+	blocked.Message = status.Message
+	if status.Details == nil {
+		return blocked
+	}
+	blocked.RetryAfterSeconds = status.Details.RetryAfterSeconds
+	for _, cause := range status.Details.Causes {
+		blocked.Causes = append(blocked.Causes, cause.Message)
+	}
+	return blocked
+}
+
+// podDisruptionBudgets returns the budgets whose selector matches the pod. It is best effort: without
+// permission to read the pod or its budgets, the eviction is reported without them.
+func (ps *PodService) podDisruptionBudgets(ctx context.Context, client kubernetes.Interface, clusterName, namespace, podName string) (budgets []DisruptionBudget) {
+	pod, err := client.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		ps.logger.Debug("Failed to get evicted pod", zap.Error(err), zap.String("cluster", clusterName), zap.String("namespace", namespace), zap.String("pod", podName))
+		return budgets
+	}
+
+	var pdbs *policyv1.PodDisruptionBudgetList
+	pdbs, err = client.PolicyV1().PodDisruptionBudgets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		ps.logger.Debug("Failed to list disruption budgets", zap.Error(err), zap.String("cluster", clusterName), zap.String("namespace", namespace))
+		return budgets
+	}
+
+	for i := range pdbs.Items {
+		if podMatchesBudget(pod, &pdbs.Items[i]) {
+			budgets = append(budgets, disruptionBudget(&pdbs.Items[i]))
+		}
+	}
+	return budgets
+}
+
+// podMatchesBudget reports whether a budget's selector selects the pod. As in the API server, a nil selector
+// selects nothing and an empty one selects every pod in the namespace.
+func podMatchesBudget(pod *corev1.Pod, pdb *policyv1.PodDisruptionBudget) (matches bool) {
+	if pdb.Spec.Selector == nil {
+		return matches
+	}
+	selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+	if err != nil {
+		return matches
+	}
+	matches = selector.Matches(labels.Set(pod.Labels))
+	return matches
+}
+
+func disruptionBudget(pdb *policyv1.PodDisruptionBudget) (budget DisruptionBudget) {
+	budget = DisruptionBudget{
+		Name:               pdb.Name,
+		CurrentHealthy:     pdb.Status.CurrentHealthy,
+		DesiredHealthy:     pdb.Status.DesiredHealthy,
+		ExpectedPods:       pdb.Status.ExpectedPods,
+		DisruptionsAllowed: pdb.Status.DisruptionsAllowed,
+	}
+	if pdb.Spec.MinAvailable != nil {
+		budget.MinAvailable = pdb.Spec.MinAvailable.String()
+	}
+	if pdb.Spec.MaxUnavailable != nil {
+		budget.MaxUnavailable = pdb.Spec.MaxUnavailable.String()
+	}
+	return budget
+}
@@ -0,0 +1,115 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"regexp"
+	"slices"
+	"sort"
+)
+
+// Pod status reasons of pods the kubelet evicted under node pressure or killed for a node shutdown.
+const (
+	PodReasonEvicted      = "Evicted"
+	PodReasonShutdown     = "Shutdown"
+	PodReasonNodeShutdown = "NodeShutdown"
+	PodReasonTerminated   = "Terminated"
+)
+
+// EvictedGroupBy separates evicted pods from pod listings.
+const EvictedGroupBy = "group"
+
+// evictionResourcePattern picks the starved resource out of eviction messages like "The node was low on
+// resource: memory. Threshold quantity: 100Mi, available: 50Mi.".
+//
+//nolint:gochecknoglobals // Compiled once
+var evictionResourcePattern = regexp.MustCompile(`low on resource: ([\w-]+)`)
+
+// EvictionSummary is the evicted and shut down pods taken out of a listing, grouped by reason and starved
+// resource, so mass evictions under node pressure show as a few rows rather than flooding the table.
+type EvictionSummary struct {
+	Total  int             `json:"total"`
+	Groups []EvictionGroup `json:"groups"`
+	Pods   []PodInfo       `json:"pods"`
+}
+
+// EvictionGroup is the evicted pods sharing a reason and starved resource, with the nodes they ran on.
+type EvictionGroup struct {
+	Reason   string   `json:"reason"`
+	Resource string   `json:"resource,omitempty"`
+	Count    int      `json:"count"`
+	Nodes    []string `json:"nodes"`
+}
+
+// EvictionReasons are the pod status reasons counted as evictions, for cleaning the pods up.
+func EvictionReasons() (reasons []string) {
+	reasons = []string{PodReasonEvicted, PodReasonShutdown, PodReasonNodeShutdown, PodReasonTerminated}
+	return reasons
+}
+
+// podEvicted reports whether a pod failed because the kubelet evicted it or its node shut down.
+func podEvicted(pod PodInfo) (evicted bool) {
+	evicted = pod.Status == "Failed" && slices.Contains(EvictionReasons(), pod.Reason)
+	return evicted
+}
+
+// SeparateEvicted takes the evicted pods out of a listing and summarizes them.
+func SeparateEvicted(pods []PodInfo) (remaining []PodInfo, summary EvictionSummary) {
+	remaining = make([]PodInfo, 0, len(pods))
+	summary = EvictionSummary{Groups: make([]EvictionGroup, 0), Pods: make([]PodInfo, 0)}
+	index := make(map[string]int)
+
+	for _, pod := range pods {
+		if !podEvicted(pod) {
+			remaining = append(remaining, pod)
+			continue
+		}
+		summary.Total++
+		summary.Pods = append(summary.Pods, pod)
+
+		resource := ""
+		if match := evictionResourcePattern.FindStringSubmatch(pod.Message); match != nil {
+			resource = match[1]
+		}
+		key := pod.Reason + "|" + resource
+		i, exists := index[key]
+		if !exists {
+			i = len(summary.Groups)
+			index[key] = i
+			summary.Groups = append(summary.Groups, EvictionGroup{Reason: pod.Reason, Resource: resource, Nodes: make([]string, 0)})
+		}
+		summary.Groups[i].Count++
+		if pod.Node != "" && !slices.Contains(summary.Groups[i].Nodes, pod.Node) {
+			summary.Groups[i].Nodes = append(summary.Groups[i].Nodes, pod.Node)
+		}
+	}
+
+	sort.SliceStable(summary.Groups, func(i, j int) (less bool) {
+		less = summary.Groups[i].Count > summary.Groups[j].Count
+		return less
+	})
+	for i := range summary.Groups {
+		sort.Strings(summary.Groups[i].Nodes)
+	}
+	return remaining, summary
+}
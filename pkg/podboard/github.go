@@ -0,0 +1,445 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Annotations linking a Deployment to the GitHub repository it is deployed from.
+const (
+	// GitHubRepoAnnotation holds the owner/repo a Deployment is deployed from.
+	GitHubRepoAnnotation = "podboard.io/github-repo"
+	// GitHubEnvironmentAnnotation optionally restricts the link to one GitHub deployment environment.
+	GitHubEnvironmentAnnotation = "podboard.io/github-environment"
+)
+
+// GitHub deployment status states reported by podboard.
+const (
+	GitHubStateInProgress = "in_progress"
+	GitHubStateSuccess    = "success"
+	GitHubStateFailure    = "failure"
+	GitHubStateError      = "error"
+)
+
+// GitHubSignatureHeader carries the HMAC-SHA256 signature GitHub computes over webhook bodies.
+const GitHubSignatureHeader = "X-Hub-Signature-256"
+
+const (
+	defaultGitHubAPIURL         = "https://api.github.com"
+	defaultGitHubRolloutTimeout = 15 * time.Minute
+	gitHubHTTPTimeout           = 30 * time.Second
+	gitHubMaxDescription        = 140
+	gitHubShortSHALength        = 7
+	// gitHubMaxWebhookBody caps webhook bodies; deployment events are a few kilobytes.
+	gitHubMaxWebhookBody = 5 << 20
+)
+
+var (
+	// ErrGitHubDisabled is returned when GitHub deployment reporting is not configured.
+	ErrGitHubDisabled = errors.New("github deployment reporting is not configured")
+	// ErrInvalidWebhookSignature is returned for webhooks without a valid signature.
+	ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+	// ErrInvalidWebhookPayload is returned for deployment events podboard can't read.
+	ErrInvalidWebhookPayload = errors.New("invalid webhook payload")
+)
+
+// GitHubConfig configures reporting rollout outcomes to GitHub deployments.
+type GitHubConfig struct {
+	// WebhookSecretEnv names the environment variable holding the webhook secret. Empty disables reporting.
+	WebhookSecretEnv string `yaml:"webhookSecretEnv"`
+	// TokenEnv names the environment variable holding a token allowed to create deployment statuses.
+	TokenEnv string `yaml:"tokenEnv"`
+	// APIURL is the GitHub API URL (default https://api.github.com), e.g. https://github.example.com/api/v3.
+	APIURL string `yaml:"apiURL"`
+	// Cluster is the cluster linked Deployments are looked up in (default: current context).
+	Cluster string `yaml:"cluster"`
+	// RolloutTimeout is how long a rollout may take before it is reported as an error (default 15m).
+	RolloutTimeout time.Duration `yaml:"rolloutTimeout"`
+}
+
+// GitHubDeploymentResult is the outcome of following a GitHub deployment.
+type GitHubDeploymentResult struct {
+	Repository   string           `json:"repository"`
+	DeploymentID int64            `json:"deploymentId"`
+	Environment  string           `json:"environment"`
+	State        string           `json:"state"`
+	Workloads    []GitHubWorkload `json:"workloads"`
+}
+
+// GitHubWorkload is the rollout of one Deployment linked to a GitHub deployment.
+type GitHubWorkload struct {
+	Namespace     string `json:"namespace"`
+	Name          string `json:"name"`
+	RolloutStatus string `json:"rolloutStatus"`
+	Message       string `json:"message,omitempty"`
+}
+
+// gitHubDeploymentEvent holds the fields podboard uses from a deployment webhook.
+type gitHubDeploymentEvent struct {
+	Deployment struct {
+		ID          int64  `json:"id"`
+		SHA         string `json:"sha"`
+		Environment string `json:"environment"`
+	} `json:"deployment"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// GitHubService follows the rollouts behind GitHub deployments and reports their outcome as deployment
+// statuses, so merged pull requests show whether the resulting pods became healthy.
+type GitHubService struct {
+	config            ServerConfig
+	github            GitHubConfig
+	kubeConfigService ClientFactory
+	jobService        *JobService
+	httpClient        *http.Client
+	logger            *zap.Logger
+}
+
+// NewGitHubService validates the GitHub configuration and creates a new GitHub service.
+func NewGitHubService(config ServerConfig, github GitHubConfig, kubeConfigService ClientFactory, jobService *JobService, logger *zap.Logger) (service *GitHubService, err error) {
+	service = &GitHubService{
+		config:            config,
+		github:            github,
+		kubeConfigService: kubeConfigService,
+		jobService:        jobService,
+		httpClient:        &http.Client{Timeout: gitHubHTTPTimeout},
+		logger:            logger,
+	}
+
+	if github.WebhookSecretEnv == "" {
+		if github.TokenEnv != "" || github.APIURL != "" || github.Cluster != "" || github.RolloutTimeout != 0 {
+			err = errors.New("github: webhookSecretEnv is required")
+		}
+		return service, err
+	}
+	if github.TokenEnv == "" {
+		err = errors.New("github: tokenEnv is required")
+		return service, err
+	}
+	if github.RolloutTimeout < 0 {
+		err = errors.New("github: rolloutTimeout must not be negative")
+		return service, err
+	}
+
+	if service.github.APIURL == "" {
+		service.github.APIURL = defaultGitHubAPIURL
+	}
+	service.github.APIURL = strings.TrimSuffix(service.github.APIURL, "/")
+	if service.github.RolloutTimeout == 0 {
+		service.github.RolloutTimeout = defaultGitHubRolloutTimeout
+	}
+
+	return service, err
+}
+
+// Enabled reports whether GitHub deployment reporting is configured.
+func (gs *GitHubService) Enabled() (enabled bool) {
+	enabled = gs.github.WebhookSecretEnv != ""
+	return enabled
+}
+
+// VerifyWebhook checks a webhook body against its X-Hub-Signature-256 header. An unset secret verifies
+// nothing, so it is treated as an invalid signature rather than an open door.
+func (gs *GitHubService) VerifyWebhook(body []byte, signature string) (err error) {
+	secret := os.Getenv(gs.github.WebhookSecretEnv)
+	if secret == "" {
+		err = fmt.Errorf("%w: %s is not set", ErrInvalidWebhookSignature, gs.github.WebhookSecretEnv)
+		return err
+	}
+
+	digest, found := strings.CutPrefix(signature, "sha256=")
+	if !found {
+		err = fmt.Errorf("%w: missing sha256 signature", ErrInvalidWebhookSignature)
+		return err
+	}
+	var expected []byte
+	expected, err = hex.DecodeString(digest)
+	if err != nil {
+		err = fmt.Errorf("%w: malformed signature", ErrInvalidWebhookSignature)
+		return err
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		err = fmt.Errorf("%w: signature does not match", ErrInvalidWebhookSignature)
+		return err
+	}
+	return err
+}
+
+// HandleDeployment starts a job following the rollout of the Deployments linked to a GitHub deployment
+// event, reporting in_progress right away and success, failure or error when the rollout ends. Started is
+// false when no Deployment is linked to the repository and environment.
+func (gs *GitHubService) HandleDeployment(ctx context.Context, body []byte) (job Job, started bool, err error) {
+	err = gs.config.CheckOutbound("github")
+	if err != nil {
+		return job, started, err
+	}
+
+	var event gitHubDeploymentEvent
+	err = json.Unmarshal(body, &event)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ErrInvalidWebhookPayload, err)
+		return job, started, err
+	}
+	if event.Repository.FullName == "" || event.Deployment.ID == 0 {
+		err = fmt.Errorf("%w: missing repository or deployment", ErrInvalidWebhookPayload)
+		return job, started, err
+	}
+
+	var client kubernetes.Interface
+	client, err = gs.kubeConfigService.GetClient(ctx, gs.github.Cluster)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return job, started, err
+	}
+
+	var linked []appsv1.Deployment
+	linked, err = linkedDeployments(ctx, client, event.Repository.FullName, event.Deployment.Environment)
+	if err != nil || len(linked) == 0 {
+		return job, started, err
+	}
+
+	job, err = gs.jobService.Start(ctx, Job{Type: JobGitHubDeployment, Cluster: gs.github.Cluster, Name: event.Repository.FullName}, gs.deploymentJob(client, event, linked))
+	started = err == nil
+	return job, started, err
+}
+
+// deploymentJob returns a job function following the linked Deployments' rollouts and reporting the
+// outcome to GitHub.
+func (gs *GitHubService) deploymentJob(client kubernetes.Interface, event gitHubDeploymentEvent, linked []appsv1.Deployment) (fn jobFunc) {
+	fn = func(ctx context.Context, report func(progress JobProgress)) (result interface{}, err error) {
+		outcome := GitHubDeploymentResult{
+			Repository:   event.Repository.FullName,
+			DeploymentID: event.Deployment.ID,
+			Environment:  event.Deployment.Environment,
+		}
+
+		// The in-progress status is informational, so failing to post it doesn't stop the rollout being followed.
+		postErr := gs.postStatus(ctx, event, GitHubStateInProgress, "Waiting for the rollout of "+deploymentNames(linked))
+		if postErr != nil {
+			gs.logger.Warn("Failed to report GitHub deployment status", zap.Error(postErr), zap.String("repository", outcome.Repository), zap.Int64("deployment", outcome.DeploymentID))
+		}
+
+		rolloutCtx, cancel := context.WithTimeout(ctx, gs.github.RolloutTimeout)
+		outcome.Workloads, outcome.State = followRollouts(rolloutCtx, client, event.Deployment.SHA, linked, report)
+		cancel()
+
+		// A cancelled job still reports, so the GitHub deployment doesn't stay in progress forever.
+		err = gs.postStatus(context.WithoutCancel(ctx), event, outcome.State, describeRollouts(outcome.State, outcome.Workloads))
+		if err == nil && outcome.State != GitHubStateSuccess {
+			err = fmt.Errorf("rollout of %s deployment %d ended in %s", outcome.Repository, outcome.DeploymentID, outcome.State)
+		}
+
+		gs.logger.Info("Reported GitHub deployment status", zap.String("repository", outcome.Repository), zap.Int64("deployment", outcome.DeploymentID), zap.String("environment", outcome.Environment), zap.String("state", outcome.State))
+		result = outcome
+		return result, err
+	}
+	return fn
+}
+
+// linkedDeployments returns the Deployments annotated with the repository, skipping those linked to a
+// different environment.
+func linkedDeployments(ctx context.Context, client kubernetes.Interface, repository, environment string) (linked []appsv1.Deployment, err error) {
+	var deployments *appsv1.DeploymentList
+	deployments, err = client.AppsV1().Deployments("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		err = fmt.Errorf("failed to list deployments: %w", err)
+		return linked, err
+	}
+
+	for _, deployment := range deployments.Items {
+		if !strings.EqualFold(deployment.Annotations[GitHubRepoAnnotation], repository) {
+			continue
+		}
+		linkedEnvironment := deployment.Annotations[GitHubEnvironmentAnnotation]
+		if linkedEnvironment != "" && linkedEnvironment != environment {
+			continue
+		}
+		linked = append(linked, deployment)
+	}
+	return linked, err
+}
+
+// followRollouts waits for each Deployment's rollout of the commit to start and finish, returning the
+// GitHub state for all of them: success when every rollout completes, failure when one fails or is paused,
+// and error when one can't be followed or doesn't finish in time.
+func followRollouts(ctx context.Context, client kubernetes.Interface, sha string, linked []appsv1.Deployment, report func(progress JobProgress)) (workloads []GitHubWorkload, state string) {
+	state = GitHubStateSuccess
+	for i, deployment := range linked {
+		workload := GitHubWorkload{Namespace: deployment.Namespace, Name: deployment.Name}
+		report(JobProgress{Done: i, Total: len(linked), Message: fmt.Sprintf("waiting for %s/%s", workload.Namespace, workload.Name)})
+
+		var err error
+		workload.RolloutStatus, workload.Message, err = followRollout(ctx, client, deployment, sha)
+		switch {
+		case workload.RolloutStatus == RolloutFailed || workload.RolloutStatus == RolloutPaused:
+			state = GitHubStateFailure
+		case err != nil:
+			workload.Message = err.Error()
+			state = GitHubStateError
+		}
+
+		workloads = append(workloads, workload)
+		if state != GitHubStateSuccess {
+			return workloads, state
+		}
+	}
+
+	report(JobProgress{Done: len(linked), Total: len(linked)})
+	return workloads, state
+}
+
+// followRollout waits for a Deployment to start rolling out the commit, then for the rollout to finish. A
+// rollout has started once the Deployment's generation moves past the one seen when the webhook arrived, or
+// straight away if its pod template already references the commit.
+func followRollout(ctx context.Context, client kubernetes.Interface, linked appsv1.Deployment, sha string) (status, message string, err error) {
+	started := templateReferencesCommit(linked, sha)
+	for !started {
+		if !sleepContext(ctx, rolloutPollInterval) {
+			err = fmt.Errorf("rollout of %s/%s did not start: %w", linked.Namespace, linked.Name, ctx.Err())
+			return status, message, err
+		}
+
+		var deployment *appsv1.Deployment
+		deployment, err = client.AppsV1().Deployments(linked.Namespace).Get(ctx, linked.Name, metav1.GetOptions{})
+		if err != nil {
+			err = fmt.Errorf("failed to get deployment %s/%s: %w", linked.Namespace, linked.Name, err)
+			return status, message, err
+		}
+		started = deployment.Generation > linked.Generation || templateReferencesCommit(*deployment, sha)
+	}
+
+	status, message, err = waitForRollout(ctx, client, linked.Namespace, linked.Name, func(JobProgress) {})
+	if err == nil && ctx.Err() != nil {
+		err = fmt.Errorf("rollout of %s/%s did not finish: %w", linked.Namespace, linked.Name, ctx.Err())
+	}
+	return status, message, err
+}
+
+// templateReferencesCommit reports whether any container image of the Deployment is tagged with the
+// commit, by its short SHA as CI pipelines usually tag images.
+func templateReferencesCommit(deployment appsv1.Deployment, sha string) (references bool) {
+	if len(sha) < gitHubShortSHALength {
+		return references
+	}
+	short := sha[:gitHubShortSHALength]
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		if strings.Contains(imageTagFromImage(container.Image), short) {
+			references = true
+			return references
+		}
+	}
+	return references
+}
+
+// describeRollouts summarizes the rollouts as a deployment status description.
+func describeRollouts(state string, workloads []GitHubWorkload) (description string) {
+	switch state {
+	case GitHubStateSuccess:
+		names := make([]string, 0, len(workloads))
+		for _, workload := range workloads {
+			names = append(names, workload.Namespace+"/"+workload.Name)
+		}
+		description = "Rolled out and healthy: " + strings.Join(names, ", ")
+	default:
+		last := workloads[len(workloads)-1]
+		description = fmt.Sprintf("%s/%s: %s", last.Namespace, last.Name, last.Message)
+	}
+	return description
+}
+
+// deploymentNames lists Deployments as namespace/name for status descriptions.
+func deploymentNames(deployments []appsv1.Deployment) (names string) {
+	parts := make([]string, 0, len(deployments))
+	for _, deployment := range deployments {
+		parts = append(parts, deployment.Namespace+"/"+deployment.Name)
+	}
+	names = strings.Join(parts, ", ")
+	return names
+}
+
+// postStatus creates a deployment status through the GitHub API. Descriptions are cut to the 140 characters
+// GitHub accepts.
+func (gs *GitHubService) postStatus(ctx context.Context, event gitHubDeploymentEvent, state, description string) (err error) {
+	if len(description) > gitHubMaxDescription {
+		description = description[:gitHubMaxDescription-3] + "..."
+	}
+
+	var payload []byte
+	payload, err = json.Marshal(map[string]interface{}{
+		"state":         state,
+		"description":   description,
+		"environment":   event.Deployment.Environment,
+		"auto_inactive": state == GitHubStateSuccess,
+	})
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/repos/%s/deployments/%d/statuses", gs.github.APIURL, event.Repository.FullName, event.Deployment.ID)
+	var request *http.Request
+	request, err = http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/vnd.github+json")
+	request.Header.Set("Authorization", "Bearer "+os.Getenv(gs.github.TokenEnv))
+
+	var response *http.Response
+	response, err = gs.httpClient.Do(request)
+	if err != nil {
+		err = fmt.Errorf("failed to create github deployment status: %w", err)
+		return err
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(response.Body, actionMaxResponseBody))
+		err = fmt.Errorf("failed to create github deployment status: %s: %s", response.Status, strings.TrimSpace(string(detail)))
+		return err
+	}
+	return err
+}
@@ -0,0 +1,158 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DefaultUserHeader is the request header carrying the authenticated user name from the authenticating proxy.
+	DefaultUserHeader = "X-Forwarded-User"
+	// DefaultGroupsHeader is the request header carrying the authenticated user's groups, comma separated.
+	DefaultGroupsHeader = "X-Forwarded-Groups"
+)
+
+// Identity is the end user on whose behalf podboard calls the Kubernetes API.
+type Identity struct {
+	User   string
+	Groups []string
+}
+
+type identityContextKey struct{}
+
+// WithIdentity returns a copy of ctx carrying the given identity.
+func WithIdentity(ctx context.Context, identity Identity) (identityCtx context.Context) {
+	identityCtx = context.WithValue(ctx, identityContextKey{}, identity)
+	return identityCtx
+}
+
+// IdentityFromContext returns the identity carried by ctx, if any.
+func IdentityFromContext(ctx context.Context) (identity Identity, ok bool) {
+	if ctx == nil {
+		return identity, ok
+	}
+	identity, ok = ctx.Value(identityContextKey{}).(Identity)
+	if ok && identity.User == "" {
+		ok = false
+	}
+	return identity, ok
+}
+
+// ErrUnauthenticated is returned by authenticators for requests without an authenticated user.
+var ErrUnauthenticated = errors.New("authenticated user required")
+
+// Authenticator identifies the end user of a request, returning ErrUnauthenticated when there is none.
+type Authenticator func(request *http.Request) (identity Identity, err error)
+
+// HeaderAuthenticator identifies users by the headers an authenticating proxy in front of podboard sets, such
+// as oauth2-proxy's X-Forwarded-User and X-Forwarded-Groups. Empty header names are the defaults.
+func HeaderAuthenticator(userHeader, groupsHeader string) (authenticate Authenticator) {
+	if userHeader == "" {
+		userHeader = DefaultUserHeader
+	}
+	if groupsHeader == "" {
+		groupsHeader = DefaultGroupsHeader
+	}
+
+	authenticate = func(request *http.Request) (identity Identity, err error) {
+		user := strings.TrimSpace(request.Header.Get(userHeader))
+		if user == "" {
+			err = fmt.Errorf("%w: missing %s header", ErrUnauthenticated, userHeader)
+			return identity, err
+		}
+		identity = Identity{
+			User:   user,
+			Groups: parseGroups(request.Header.Values(groupsHeader)),
+		}
+		return identity, err
+	}
+	return authenticate
+}
+
+// configAuthenticator returns the HeaderAuthenticator for the server's identity headers when impersonation is
+// enabled or identity headers are trusted, and nil otherwise.
+func configAuthenticator(config ServerConfig) (authenticate Authenticator) {
+	if config.Impersonate || config.TrustIdentityHeaders {
+		authenticate = HeaderAuthenticator(config.UserHeader, config.GroupsHeader)
+	}
+	return authenticate
+}
+
+// identityMiddleware attaches the authenticated user to the request context, along with the access binding
+// scoping them. Requests authenticate fails for get 401, and a nil authenticate leaves requests anonymous.
+// Only impersonation makes Kubernetes API calls as the user. With identity headers podboard must only be
+// reachable through the proxy setting them: anyone able to set these headers directly can act as any user.
+func identityMiddleware(config ServerConfig, authenticate Authenticator, policy *AccessPolicy) (handler gin.HandlerFunc) {
+	handler = func(c *gin.Context) {
+		if authenticate == nil {
+			c.Next()
+			return
+		}
+
+		identity, err := authenticate(c.Request)
+		if err != nil {
+			respondErrorCode(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		ctx := withAccess(c.Request.Context(), policy.Resolve(identity))
+		if config.Impersonate {
+			ctx = WithIdentity(ctx, identity)
+		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+	return handler
+}
+
+// parseGroups splits comma separated group header values, dropping empty entries.
+func parseGroups(values []string) (groups []string) {
+	for _, value := range values {
+		for _, group := range strings.Split(value, ",") {
+			group = strings.TrimSpace(group)
+			if group != "" {
+				groups = append(groups, group)
+			}
+		}
+	}
+	return groups
+}
+
+// identityUser returns the impersonated or authenticated user name for ctx, or "" when identity headers aren't
+// trusted.
+func identityUser(ctx context.Context) (user string) {
+	identity, ok := IdentityFromContext(ctx)
+	if ok {
+		user = identity.User
+		return user
+	}
+	user = AccessFromContext(ctx).Identity.User
+	return user
+}
@@ -0,0 +1,275 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Infrastructure error categories, each usually owned by a different team than the application.
+const (
+	InfraCategorySandbox = "sandbox"
+	InfraCategoryNetwork = "network"
+	InfraCategoryRuntime = "runtime"
+	InfraCategoryStorage = "storage"
+	InfraCategoryNode    = "node"
+)
+
+// Sources of infrastructure errors.
+const (
+	InfraSourceEvent = "event"
+	InfraSourceNode  = "node"
+)
+
+// infraEventReasons maps the reasons of kubelet, volume and node events caused by the platform rather than the
+// application to their category. Events not listed here, such as BackOff or Unhealthy, are application symptoms.
+//
+//nolint:gochecknoglobals // Static lookup table
+var infraEventReasons = map[string]string{
+	"FailedCreatePodSandBox":           InfraCategorySandbox,
+	"FailedKillPodSandBox":             InfraCategorySandbox,
+	"SandboxChanged":                   InfraCategorySandbox,
+	"NetworkNotReady":                  InfraCategoryNetwork,
+	"FailedCreatePodContainer":         InfraCategoryRuntime,
+	"ContainerGCFailed":                InfraCategoryRuntime,
+	"ImageGCFailed":                    InfraCategoryRuntime,
+	"FailedMount":                      InfraCategoryStorage,
+	"FailedAttachVolume":               InfraCategoryStorage,
+	"FailedMapVolume":                  InfraCategoryStorage,
+	"VolumeResizeFailed":               InfraCategoryStorage,
+	"FailedNodeAllocatableEnforcement": InfraCategoryNode,
+	"SystemOOM":                        InfraCategoryNode,
+	"Rebooted":                         InfraCategoryNode,
+	"NodeNotReady":                     InfraCategoryNode,
+	"EvictionThresholdMet":             InfraCategoryNode,
+}
+
+// runtimeErrorMarkers identify container runtime failures reported under generic reasons such as Failed.
+//
+//nolint:gochecknoglobals // Static lookup table
+var runtimeErrorMarkers = []string{"oci runtime", "container runtime", "failed to create containerd task", "failed to create shim", "rpc error"}
+
+// networkErrorMarkers identify network plugin failures in event and condition messages.
+//
+//nolint:gochecknoglobals // Static lookup table
+var networkErrorMarkers = []string{"cni", "network plugin", "networkpluginnotready", "networkready=false", "network for sandbox", "plugin type="}
+
+// InfrastructureError is a kubelet, container runtime, network or storage error, as opposed to an application crash.
+type InfrastructureError struct {
+	Category   string `json:"category"`
+	Source     string `json:"source"`
+	Reason     string `json:"reason"`
+	Message    string `json:"message"`
+	Count      int32  `json:"count"`
+	LastSeen   string `json:"lastSeen,omitempty"`
+	Age        string `json:"age,omitempty"`
+	AgeSeconds int64  `json:"ageSeconds,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+	Object     string `json:"object"`
+	Node       string `json:"node,omitempty"`
+}
+
+// InfrastructureService collects infrastructure errors from events and node status.
+type InfrastructureService struct {
+	kubeConfigService ClientFactory
+	logger            *zap.Logger
+}
+
+// NewInfrastructureService creates a new infrastructure service.
+func NewInfrastructureService(kubeConfigService ClientFactory, logger *zap.Logger) (service *InfrastructureService) {
+	service = &InfrastructureService{
+		kubeConfigService: kubeConfigService,
+		logger:            logger,
+	}
+	return service
+}
+
+// GetErrors returns the infrastructure errors in a namespace ("all" for every namespace), newest first, optionally
+// limited to one category. Nodes are checked too; where podboard can't list nodes only events are used.
+func (is *InfrastructureService) GetErrors(ctx context.Context, clusterName, namespace, category string) (infraErrors []InfrastructureError, err error) {
+	var client kubernetes.Interface
+	client, err = is.kubeConfigService.GetClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return infraErrors, err
+	}
+
+	var eventList *corev1.EventList
+	eventList, err = client.CoreV1().Events(eventQueryNamespace(namespace)).List(ctx, eventListOptions(defaultEventType))
+	if err != nil {
+		is.logger.Error("Failed to list events", zap.Error(err), zap.String("cluster", clusterName), zap.String("namespace", namespace))
+		err = fmt.Errorf("failed to list events: %w", err)
+		return infraErrors, err
+	}
+
+	var timed []timedInfrastructureError
+	for i := range eventList.Items {
+		event := &eventList.Items[i]
+		eventCategory := infraEventCategory(event.Reason, event.Message)
+		if eventCategory == "" {
+			continue
+		}
+		info := eventToEventInfo(event, timeViewFromContext(ctx))
+		infraError := timedInfrastructureError{since: eventLastSeen(event), InfrastructureError: InfrastructureError{
+			Category:   eventCategory,
+			Source:     InfraSourceEvent,
+			Reason:     info.Reason,
+			Message:    info.Message,
+			Count:      info.Count,
+			LastSeen:   info.LastSeen,
+			Age:        info.Age,
+			AgeSeconds: info.AgeSeconds,
+			Namespace:  info.Namespace,
+			Object:     info.Object,
+			Node:       event.Source.Host,
+		}}
+		if event.InvolvedObject.Kind == KindNode {
+			infraError.Node = event.InvolvedObject.Name
+		}
+		timed = append(timed, infraError)
+	}
+
+	// Nodes are cluster scoped, so namespace-restricted deployments are left with events alone.
+	nodes, nodesErr := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{ResourceVersion: "0"})
+	if nodesErr != nil {
+		is.logger.Debug("Node status unavailable for infrastructure errors", zap.Error(nodesErr), zap.String("cluster", clusterName))
+	} else {
+		for i := range nodes.Items {
+			timed = append(timed, nodeInfrastructureErrors(&nodes.Items[i], timeViewFromContext(ctx))...)
+		}
+	}
+
+	sort.SliceStable(timed, func(i, j int) (less bool) {
+		less = timed[i].since.After(timed[j].since)
+		return less
+	})
+
+	infraErrors = make([]InfrastructureError, 0, len(timed))
+	for _, infraError := range timed {
+		if category == "" || infraError.Category == category {
+			infraErrors = append(infraErrors, infraError.InfrastructureError)
+		}
+	}
+
+	return infraErrors, err
+}
+
+// infraEventCategory returns the infrastructure category of an event, or an empty string for application events.
+func infraEventCategory(reason, message string) (category string) {
+	lowerMessage := strings.ToLower(message)
+
+	category = infraEventReasons[reason]
+	if category == InfraCategorySandbox && containsAny(lowerMessage, networkErrorMarkers) {
+		// Most sandbox failures are the CNI plugin failing to set up the pod network.
+		category = InfraCategoryNetwork
+		return category
+	}
+	if category != "" {
+		return category
+	}
+
+	// Generic reasons like Failed and FailedSync also carry runtime errors, recognizable by their message.
+	if reason == "Failed" || reason == "FailedSync" {
+		switch {
+		case containsAny(lowerMessage, networkErrorMarkers):
+			category = InfraCategoryNetwork
+		case containsAny(lowerMessage, runtimeErrorMarkers):
+			category = InfraCategoryRuntime
+		}
+	}
+	return category
+}
+
+// timedInfrastructureError is an infrastructure error with the time it was last seen, for sorting.
+type timedInfrastructureError struct {
+	InfrastructureError
+	since time.Time
+}
+
+// nodeInfrastructureErrors returns the infrastructure errors in a node's status: an unready node, which the
+// kubelet explains with e.g. a network plugin or container runtime that isn't ready, and an unavailable network.
+func nodeInfrastructureErrors(node *corev1.Node, view timeView) (nodeErrors []timedInfrastructureError) {
+	for _, condition := range node.Status.Conditions {
+		var category string
+		switch {
+		case condition.Type == corev1.NodeReady && condition.Status != corev1.ConditionTrue:
+			lowerMessage := strings.ToLower(condition.Message)
+			switch {
+			case containsAny(lowerMessage, networkErrorMarkers):
+				category = InfraCategoryNetwork
+			case strings.Contains(lowerMessage, "runtime") || strings.Contains(lowerMessage, "pleg"):
+				category = InfraCategoryRuntime
+			default:
+				category = InfraCategoryNode
+			}
+		case condition.Type == corev1.NodeNetworkUnavailable && condition.Status == corev1.ConditionTrue:
+			category = InfraCategoryNetwork
+		default:
+			continue
+		}
+
+		reason := condition.Reason
+		if condition.Type == corev1.NodeReady {
+			reason = NodeProblemNotReady
+		}
+		nodeError := timedInfrastructureError{
+			InfrastructureError: InfrastructureError{
+				Category: category,
+				Source:   InfraSourceNode,
+				Reason:   reason,
+				Message:  condition.Message,
+				Count:    1,
+				Object:   KindNode + "/" + node.Name,
+				Node:     node.Name,
+			},
+			since: condition.LastTransitionTime.Time,
+		}
+		if !nodeError.since.IsZero() {
+			nodeError.LastSeen = view.format(nodeError.since)
+			nodeError.Age = view.age(nodeError.since)
+			nodeError.AgeSeconds = view.ageSeconds(nodeError.since)
+		}
+		nodeErrors = append(nodeErrors, nodeError)
+	}
+	return nodeErrors
+}
+
+// containsAny returns true if s contains any of the substrings.
+func containsAny(s string, substrings []string) (contains bool) {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			contains = true
+			return contains
+		}
+	}
+	return contains
+}
@@ -0,0 +1,278 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Job states.
+const (
+	JobRunning   = "Running"
+	JobSucceeded = "Succeeded"
+	JobFailed    = "Failed"
+	JobCancelled = "Cancelled"
+)
+
+// Job types.
+const (
+	JobBatchDelete      = "batch-delete"
+	JobRollingRestart   = "rolling-restart"
+	JobDrain            = "drain"
+	JobGitHubDeployment = "github-deployment"
+	JobPodCleanup       = "pod-cleanup"
+)
+
+const maxRetainedJobs = 100
+
+// ErrJobNotFound is returned when a job ID is unknown.
+var ErrJobNotFound = errors.New("job not found")
+
+// JobProgress is how far a job has got, such as pods deleted out of those matched.
+type JobProgress struct {
+	Done    int    `json:"done"`
+	Total   int    `json:"total"`
+	Message string `json:"message,omitempty"`
+}
+
+// Job reports the progress of a long-running operation performed in the background. Result holds the
+// operation's outcome once it has finished, in the same form the operation returns when run synchronously.
+type Job struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	Cluster    string      `json:"cluster,omitempty"`
+	Namespace  string      `json:"namespace,omitempty"`
+	Name       string      `json:"name,omitempty"`
+	User       string      `json:"user,omitempty"`
+	RequestID  string      `json:"requestId,omitempty"`
+	State      string      `json:"state"`
+	Progress   JobProgress `json:"progress"`
+	Result     interface{} `json:"result,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	StartedAt  time.Time   `json:"startedAt"`
+	FinishedAt *time.Time  `json:"finishedAt,omitempty"`
+}
+
+// jobFunc performs a job, calling report as it makes progress. Its result is kept on the job.
+type jobFunc func(ctx context.Context, report func(progress JobProgress)) (result interface{}, err error)
+
+// jobRun is a job, the means to cancel it and a channel closed on its next change.
+type jobRun struct {
+	job     Job
+	cancel  context.CancelFunc
+	changed chan struct{}
+}
+
+// JobService runs long-running operations in the background, so requests return a job to follow instead of
+// blocking until the operation is done. Jobs are kept in memory and are lost when podboard restarts.
+type JobService struct {
+	logger *zap.Logger
+
+	mu    sync.Mutex
+	jobs  map[string]*jobRun
+	order []string
+}
+
+// NewJobService creates a new job service.
+func NewJobService(logger *zap.Logger) (service *JobService) {
+	service = &JobService{
+		logger: logger,
+		jobs:   make(map[string]*jobRun),
+	}
+	return service
+}
+
+// Start runs fn in the background. The job outlives the request but keeps its values, including the
+// requesting user's identity, so the operation is performed as that user. The returned job is a snapshot;
+// use Get or Watch to follow progress.
+func (js *JobService) Start(ctx context.Context, job Job, fn jobFunc) (started Job, err error) {
+	job.ID, err = newID()
+	if err != nil {
+		return started, err
+	}
+	job.User = identityUser(ctx)
+	job.RequestID = requestID(ctx)
+	job.State = JobRunning
+	job.StartedAt = time.Now().UTC()
+
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	js.store(&jobRun{job: job, cancel: cancel, changed: make(chan struct{})})
+
+	js.logger.Info("Job started", zap.String("id", job.ID), zap.String("type", job.Type), zap.String("user", job.User), zap.String("requestId", job.RequestID), zap.String("cluster", job.Cluster), zap.String("namespace", job.Namespace), zap.String("name", job.Name))
+
+	go js.run(runCtx, job.ID, fn)
+
+	started = job
+	return started, err
+}
+
+// run performs a job and records its outcome.
+func (js *JobService) run(ctx context.Context, id string, fn jobFunc) {
+	result, err := fn(ctx, func(progress JobProgress) {
+		js.update(id, func(job *Job) {
+			job.Progress = progress
+		})
+	})
+
+	var job Job
+	js.update(id, func(j *Job) {
+		finishedAt := time.Now().UTC()
+		j.FinishedAt = &finishedAt
+		j.Result = result
+		switch {
+		case j.State == JobCancelled:
+			// Keep the cancelled state; the result shows how far the job got.
+		case err != nil:
+			j.State = JobFailed
+			j.Error = err.Error()
+		default:
+			j.State = JobSucceeded
+		}
+		job = *j
+	})
+
+	js.logger.Info("Job finished", zap.String("id", id), zap.String("type", job.Type), zap.String("user", job.User), zap.String("requestId", job.RequestID), zap.String("state", job.State), zap.String("error", job.Error))
+}
+
+// owner returns who started the job and the namespaces it targets.
+func (job Job) owner() (user, namespace string) {
+	user, namespace = job.User, job.Namespace
+	return user, namespace
+}
+
+// Get returns a snapshot of a job.
+func (js *JobService) Get(id string) (job Job, err error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	run, exists := js.jobs[id]
+	if !exists {
+		err = fmt.Errorf("%w: %s", ErrJobNotFound, id)
+		return job, err
+	}
+
+	job = run.job
+	return job, err
+}
+
+// List returns snapshots of the retained jobs, newest first.
+func (js *JobService) List() (jobs []Job) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	jobs = make([]Job, 0, len(js.order))
+	for i := len(js.order) - 1; i >= 0; i-- {
+		jobs = append(jobs, js.jobs[js.order[i]].job)
+	}
+	return jobs
+}
+
+// Cancel stops a running job. Work it has already done, such as deleted pods, is not undone.
+func (js *JobService) Cancel(ctx context.Context, id string) (job Job, err error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	run, exists := js.jobs[id]
+	if !exists {
+		err = fmt.Errorf("%w: %s", ErrJobNotFound, id)
+		return job, err
+	}
+
+	if run.job.State == JobRunning {
+		run.cancel()
+		run.job.State = JobCancelled
+		js.notify(run)
+		js.logger.Info("Job cancelled", zap.String("id", id), zap.String("user", identityUser(ctx)), zap.String("requestId", requestID(ctx)))
+	}
+
+	job = run.job
+	return job, err
+}
+
+// Watch calls handler with the job now and after every change until it finishes or the context is cancelled.
+func (js *JobService) Watch(ctx context.Context, id string, handler func(job Job)) (err error) {
+	for {
+		js.mu.Lock()
+		run, exists := js.jobs[id]
+		if !exists {
+			js.mu.Unlock()
+			err = fmt.Errorf("%w: %s", ErrJobNotFound, id)
+			return err
+		}
+		job, changed := run.job, run.changed
+		js.mu.Unlock()
+
+		handler(job)
+		// Progress may still be recorded after a cancel, until the job notices it.
+		if job.FinishedAt != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-changed:
+		}
+	}
+}
+
+// store records a job, dropping the oldest finished jobs beyond the retention limit.
+func (js *JobService) store(run *jobRun) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	js.jobs[run.job.ID] = run
+	js.order = append(js.order, run.job.ID)
+
+	for len(js.order) > maxRetainedJobs {
+		oldest := js.jobs[js.order[0]]
+		if oldest.job.FinishedAt == nil {
+			break
+		}
+		delete(js.jobs, js.order[0])
+		js.order = js.order[1:]
+	}
+}
+
+// update applies a change to a stored job and wakes its watchers.
+func (js *JobService) update(id string, change func(job *Job)) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	if run, exists := js.jobs[id]; exists {
+		change(&run.job)
+		js.notify(run)
+	}
+}
+
+// notify wakes a job's watchers. The caller must hold the lock.
+func (js *JobService) notify(run *jobRun) {
+	close(run.changed)
+	run.changed = make(chan struct{})
+}
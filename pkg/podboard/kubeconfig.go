@@ -23,12 +23,16 @@ SOFTWARE.
 package podboard
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
+	"sync"
+	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"go.uber.org/zap"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -36,10 +40,46 @@ import (
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
+const (
+	// kubeconfigReloadDelay is how long the kubeconfig is left to settle after a change before it is reloaded, so
+	// a file written in several steps is read once.
+	kubeconfigReloadDelay = 250 * time.Millisecond
+	// kubeconfigPollInterval is how often the kubeconfig is checked for changes when it can't be watched.
+	kubeconfigPollInterval = 2 * time.Second
+)
+
 // ClusterInfo represents a kubectl cluster.
 type ClusterInfo struct {
-	Name    string `json:"name"`
-	Current bool   `json:"current"`
+	Name    string         `json:"name"`
+	Current bool           `json:"current"`
+	Health  *ClusterHealth `json:"health,omitempty"`
+	Retries *RetryStats    `json:"retries,omitempty"`
+}
+
+// ContextInfo represents a kubeconfig context: a cluster paired with the credentials to use for it.
+type ContextInfo struct {
+	Name      string `json:"name"`
+	Cluster   string `json:"cluster"`
+	User      string `json:"user"`
+	Namespace string `json:"namespace,omitempty"`
+	Current   bool   `json:"current"`
+}
+
+type kubeContextKey struct{}
+
+// WithKubeContext returns a copy of ctx selecting the named kubeconfig context for Kubernetes clients.
+func WithKubeContext(ctx context.Context, contextName string) (kubeCtx context.Context) {
+	kubeCtx = context.WithValue(ctx, kubeContextKey{}, contextName)
+	return kubeCtx
+}
+
+// KubeContextFromContext returns the kubeconfig context selected in ctx, or an empty string if there is none.
+func KubeContextFromContext(ctx context.Context) (contextName string) {
+	if ctx == nil {
+		return contextName
+	}
+	contextName, _ = ctx.Value(kubeContextKey{}).(string)
+	return contextName
 }
 
 // KubeConfigService handles kubeconfig operations.
@@ -47,18 +87,66 @@ type KubeConfigService struct {
 	logger         *zap.Logger
 	inCluster      bool
 	kubeconfigPath string
+	// kubeconfigDir, when set, replaces kubeconfigPath: every file in it is merged with the in-cluster
+	// connection, registered as the cluster inClusterName.
+	kubeconfigDir string
+	inClusterName string
+	// allowedClusters, when set, are the only clusters of the kubeconfig that are exposed and connected to.
+	allowedClusters []string
+	clients         *ClientCache
+	// requestTimeout bounds each request of the clients created, zero for no limit.
+	requestTimeout time.Duration
+	health         *ClusterHealthTracker
+	retries        *RetryTracker
+	rateLimits     *clientRateLimits
+
+	mu          sync.Mutex
+	config      *clientcmdapi.Config
+	configStamp kubeconfigStamp
+	failedStamp kubeconfigStamp
+}
+
+// kubeconfigStamp identifies a version of the kubeconfig file.
+type kubeconfigStamp struct {
+	modTime time.Time
+	size    int64
 }
 
 // NewKubeConfigService creates a new kubeconfig service.
-func NewKubeConfigService(logger *zap.Logger) (service *KubeConfigService) {
+// In cluster with config.ExtraKubeconfigDir set, the kubeconfigs in that directory are served alongside the
+// in-cluster connection as named clusters, instead of the in-cluster connection alone.
+func NewKubeConfigService(config ServerConfig, logger *zap.Logger) (service *KubeConfigService) {
 	service = &KubeConfigService{
-		logger: logger,
+		logger:          logger,
+		allowedClusters: config.Clusters,
+		clients:         NewClientCache(defaultClientCacheTTL, defaultClientCacheMaxEntries),
+		requestTimeout:  config.KubernetesRequestTimeout,
+		health:          NewClusterHealthTracker(config.Clock),
+		retries:         NewRetryTracker(config.KubernetesRetries),
+		rateLimits:      newClientRateLimits(config.KubernetesQPS, config.KubernetesBurst),
 	}
 
 	// Check if running in cluster
 	_, err := rest.InClusterConfig()
 	service.inCluster = err == nil
 
+	if config.ExtraKubeconfigDir != "" {
+		if service.inCluster {
+			service.inCluster = false
+			service.kubeconfigDir = config.ExtraKubeconfigDir
+			service.inClusterName = config.InClusterName
+			if service.inClusterName == "" {
+				service.inClusterName = DefaultInClusterName
+			}
+			return service
+		}
+		logger.Warn("Ignoring extra kubeconfig directory when not running in cluster", zap.String("dir", config.ExtraKubeconfigDir))
+	}
+
+	if service.inCluster && len(config.Clusters) > 0 {
+		logger.Warn("Ignoring cluster allowlist when running in cluster without an extra kubeconfig directory", zap.Strings("clusters", config.Clusters))
+	}
+
 	if !service.inCluster {
 		// Determine kubeconfig path
 		service.kubeconfigPath = os.Getenv("KUBECONFIG")
@@ -72,6 +160,19 @@ func NewKubeConfigService(logger *zap.Logger) (service *KubeConfigService) {
 	return service
 }
 
+// Health returns the tracker of the health of the clusters the service's clients make requests to. The
+// in-cluster connection is tracked under the empty name.
+func (kcs *KubeConfigService) Health() (tracker *ClusterHealthTracker) {
+	tracker = kcs.health
+	return tracker
+}
+
+// Retries returns the tracker of the retries of the service's clients, or nil when they don't retry.
+func (kcs *KubeConfigService) Retries() (tracker *RetryTracker) {
+	tracker = kcs.retries
+	return tracker
+}
+
 // IsInCluster returns true if running inside a Kubernetes cluster.
 func (kcs *KubeConfigService) IsInCluster() (inCluster bool) {
 	inCluster = kcs.inCluster
@@ -85,14 +186,14 @@ func (kcs *KubeConfigService) GetClusters() (clusters []ClusterInfo, err error)
 		return clusters, err
 	}
 
-	if kcs.kubeconfigPath == "" {
+	if kcs.kubeconfigPath == "" && kcs.kubeconfigDir == "" {
 		err = errors.New("kubeconfig path not found")
 		return clusters, err
 	}
 
 	// Load the kubeconfig file
 	var config *clientcmdapi.Config
-	config, err = clientcmd.LoadFromFile(kcs.kubeconfigPath)
+	config, err = kcs.loadKubeConfig()
 	if err != nil {
 		err = fmt.Errorf("failed to load kubeconfig: %w", err)
 		return clusters, err
@@ -137,6 +238,74 @@ func (kcs *KubeConfigService) GetClusters() (clusters []ClusterInfo, err error)
 	return clusters, err
 }
 
+// GetContexts returns the kubeconfig contexts, current context first.
+func (kcs *KubeConfigService) GetContexts() (contexts []ContextInfo, err error) {
+	if kcs.inCluster {
+		err = errors.New("contexts not available when running in cluster")
+		return contexts, err
+	}
+
+	var config *clientcmdapi.Config
+	config, err = kcs.loadKubeConfig()
+	if err != nil {
+		err = fmt.Errorf("failed to load kubeconfig: %w", err)
+		return contexts, err
+	}
+
+	for name, kubeContext := range config.Contexts {
+		contexts = append(contexts, ContextInfo{
+			Name:      name,
+			Cluster:   kubeContext.Cluster,
+			User:      kubeContext.AuthInfo,
+			Namespace: kubeContext.Namespace,
+			Current:   name == config.CurrentContext,
+		})
+	}
+
+	sort.Slice(contexts, func(i, j int) (less bool) {
+		if contexts[i].Current != contexts[j].Current {
+			less = contexts[i].Current
+			return less
+		}
+		less = contexts[i].Name < contexts[j].Name
+		return less
+	})
+
+	return contexts, err
+}
+
+// ContextCluster returns the cluster a kubeconfig context points at.
+func (kcs *KubeConfigService) ContextCluster(contextName string) (clusterName string, err error) {
+	var config *clientcmdapi.Config
+	config, err = kcs.loadKubeConfig()
+	if err != nil {
+		err = fmt.Errorf("failed to load kubeconfig: %w", err)
+		return clusterName, err
+	}
+
+	kubeContext, exists := config.Contexts[contextName]
+	if !exists {
+		err = fmt.Errorf("context %q not found in kubeconfig", contextName)
+		return clusterName, err
+	}
+
+	clusterName = kubeContext.Cluster
+	return clusterName, err
+}
+
+// HasCluster returns true if the named cluster exists in the kubeconfig.
+func (kcs *KubeConfigService) HasCluster(clusterName string) (exists bool, err error) {
+	var config *clientcmdapi.Config
+	config, err = kcs.loadKubeConfig()
+	if err != nil {
+		err = fmt.Errorf("failed to load kubeconfig: %w", err)
+		return exists, err
+	}
+
+	_, exists = config.Clusters[clusterName]
+	return exists, err
+}
+
 // GetCurrentContext returns the current context name.
 func (kcs *KubeConfigService) GetCurrentContext() (currentContext string, err error) {
 	if kcs.inCluster {
@@ -145,7 +314,7 @@ func (kcs *KubeConfigService) GetCurrentContext() (currentContext string, err er
 	}
 
 	var config *clientcmdapi.Config
-	config, err = clientcmd.LoadFromFile(kcs.kubeconfigPath)
+	config, err = kcs.loadKubeConfig()
 	if err != nil {
 		err = fmt.Errorf("failed to load kubeconfig: %w", err)
 		return currentContext, err
@@ -155,32 +324,338 @@ func (kcs *KubeConfigService) GetCurrentContext() (currentContext string, err er
 	return currentContext, err
 }
 
+// kubeconfigSource returns the kubeconfig file or directory configurations are read from.
+func (kcs *KubeConfigService) kubeconfigSource() (source string) {
+	source = kcs.kubeconfigPath
+	if kcs.kubeconfigDir != "" {
+		source = kcs.kubeconfigDir
+	}
+	return source
+}
+
+// loadKubeConfig returns the parsed kubeconfig. It is read on first use and then only reloaded by
+// WatchKubeConfig, so requests don't touch the file system.
+func (kcs *KubeConfigService) loadKubeConfig() (config *clientcmdapi.Config, err error) {
+	kcs.mu.Lock()
+	defer kcs.mu.Unlock()
+
+	if kcs.config != nil {
+		config = kcs.config
+		return config, err
+	}
+	config, err = kcs.reloadKubeConfigLocked()
+	return config, err
+}
+
+// reloadKubeConfig re-reads the kubeconfig if its modification time or size changed. A change also drops every
+// cached client, since endpoints or credentials may differ. If a changed file fails to parse, the previous
+// configuration is kept until the file changes again.
+func (kcs *KubeConfigService) reloadKubeConfig() (err error) {
+	kcs.mu.Lock()
+	defer kcs.mu.Unlock()
+
+	_, err = kcs.reloadKubeConfigLocked()
+	return err
+}
+
+// reloadKubeConfigLocked is reloadKubeConfig for callers holding kcs.mu.
+func (kcs *KubeConfigService) reloadKubeConfigLocked() (config *clientcmdapi.Config, err error) {
+	var stamp kubeconfigStamp
+	if kcs.kubeconfigDir != "" {
+		stamp, err = kubeconfigDirStamp(kcs.kubeconfigDir)
+	} else {
+		stamp, err = kubeconfigFileStamp(kcs.kubeconfigPath)
+	}
+	if err != nil {
+		return config, err
+	}
+
+	if kcs.config != nil && (stamp == kcs.configStamp || stamp == kcs.failedStamp) {
+		config = kcs.config
+		return config, err
+	}
+
+	if kcs.kubeconfigDir != "" {
+		config, err = kcs.loadKubeConfigDir()
+	} else {
+		config, err = clientcmd.LoadFromFile(kcs.kubeconfigPath)
+	}
+	if err != nil {
+		if kcs.config != nil {
+			// Tools like 'aws eks update-kubeconfig' rewrite the file in place; keep serving the last good
+			// configuration and retry on the next change rather than failing while the file is half written.
+			kcs.logger.Warn("Failed to reload kubeconfig, keeping previous configuration", zap.String("path", kcs.kubeconfigSource()), zap.Error(err))
+			kcs.failedStamp = stamp
+			config = kcs.config
+			err = nil
+		}
+		return config, err
+	}
+	if removed := restrictKubeConfig(config, kcs.allowedClusters); len(removed) > 0 {
+		kcs.logger.Info("Hiding clusters outside the cluster allowlist", zap.Strings("clusters", removed))
+	}
+
+	if kcs.config != nil {
+		kcs.logger.Info("Kubeconfig reloaded, dropping cached clients", zap.String("path", kcs.kubeconfigSource()), zap.Int("clusters", len(config.Clusters)), zap.Int("contexts", len(config.Contexts)))
+		kcs.clients.Flush()
+	}
+	kcs.config = config
+	kcs.configStamp = stamp
+	return config, err
+}
+
+// kubeconfigFileStamp returns the current version of a kubeconfig file.
+func kubeconfigFileStamp(path string) (stamp kubeconfigStamp, err error) {
+	var info os.FileInfo
+	info, err = os.Stat(path)
+	if err != nil {
+		return stamp, err
+	}
+	stamp = kubeconfigStamp{modTime: info.ModTime(), size: info.Size()}
+	return stamp, err
+}
+
+// WatchKubeConfig reloads the kubeconfig file, or kubeconfig directory, whenever it changes until the context is
+// cancelled, so new clusters and rotated credentials are picked up without a restart. The directories holding it
+// are watched rather than the file, which sees editors and tools that replace the file instead of writing to it,
+// and the symlink swaps of Secret and ConfigMap volumes. If they can't be watched, the kubeconfig is polled.
+func (kcs *KubeConfigService) WatchKubeConfig(ctx context.Context) {
+	if kcs.inCluster || kcs.kubeconfigSource() == "" {
+		return
+	}
+
+	watcher, err := kcs.newKubeConfigWatcher()
+	if err != nil {
+		kcs.logger.Warn("Failed to watch kubeconfig, polling it instead", zap.String("path", kcs.kubeconfigSource()), zap.Duration("interval", kubeconfigPollInterval), zap.Error(err))
+		kcs.pollKubeConfig(ctx, kubeconfigPollInterval)
+		return
+	}
+	defer func() { _ = watcher.Close() }()
+
+	kcs.watchKubeConfig(ctx, watcher)
+}
+
+// newKubeConfigWatcher watches the directory holding the kubeconfig, and the one its symlink points into if it
+// is one, or the kubeconfig directory.
+func (kcs *KubeConfigService) newKubeConfigWatcher() (watcher *fsnotify.Watcher, err error) {
+	dirs := []string{kcs.kubeconfigDir}
+	if kcs.kubeconfigDir == "" {
+		dirs = []string{filepath.Dir(kcs.kubeconfigPath)}
+		target, linkErr := filepath.EvalSymlinks(kcs.kubeconfigPath)
+		if linkErr == nil && filepath.Dir(target) != dirs[0] {
+			dirs = append(dirs, filepath.Dir(target))
+		}
+	}
+
+	watcher, err = fsnotify.NewWatcher()
+	if err != nil {
+		return watcher, err
+	}
+	for _, dir := range dirs {
+		err = watcher.Add(dir)
+		if err != nil {
+			_ = watcher.Close()
+			watcher = nil
+			err = fmt.Errorf("failed to watch %s: %w", dir, err)
+			return watcher, err
+		}
+	}
+	return watcher, err
+}
+
+// watchKubeConfig reloads the kubeconfig once the watched directories have been quiet for kubeconfigReloadDelay
+// after a change, until the context is cancelled or the watcher is closed.
+func (kcs *KubeConfigService) watchKubeConfig(ctx context.Context, watcher *fsnotify.Watcher) {
+	settle := time.NewTimer(kubeconfigReloadDelay)
+	settle.Stop()
+	defer settle.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			settle.Reset(kubeconfigReloadDelay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			kcs.logger.Warn("Kubeconfig watch error", zap.String("path", kcs.kubeconfigSource()), zap.Error(err))
+		case <-settle.C:
+			err := kcs.reloadKubeConfig()
+			if err != nil {
+				kcs.logger.Debug("Kubeconfig not readable", zap.String("path", kcs.kubeconfigSource()), zap.Error(err))
+			}
+		}
+	}
+}
+
+// pollKubeConfig checks the kubeconfig for changes every interval until the context is cancelled.
+func (kcs *KubeConfigService) pollKubeConfig(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := kcs.reloadKubeConfig()
+			if err != nil {
+				kcs.logger.Debug("Kubeconfig not readable", zap.String("path", kcs.kubeconfigSource()), zap.Error(err))
+			}
+		}
+	}
+}
+
+// GetClient returns a Kubernetes client for the given cluster.
+// In cluster the cluster name is ignored; locally an empty name selects the kubeconfig's current cluster.
+// If the context carries a user Identity, requests are made impersonating that user.
+// Clients are cached per cluster and identity until they expire or the kubeconfig changes.
+func (kcs *KubeConfigService) GetClient(ctx context.Context, clusterName string) (client kubernetes.Interface, err error) {
+	if !kcs.inCluster {
+		// Picks up kubeconfig changes, flushing stale clients before the cache is consulted.
+		_, err = kcs.loadKubeConfig()
+		if err != nil {
+			err = fmt.Errorf("failed to load kubeconfig: %w", err)
+			return client, err
+		}
+	}
+
+	if !kcs.inCluster && clusterName == "" {
+		// If no cluster specified, try to get current cluster
+		currentCluster, clusterErr := kcs.GetCurrentCluster()
+		if clusterErr != nil {
+			err = fmt.Errorf("no cluster specified and failed to get current cluster: %w", clusterErr)
+			return client, err
+		}
+		clusterName = currentCluster
+	}
+
+	identity, impersonate := IdentityFromContext(ctx)
+	if !impersonate {
+		identity = Identity{}
+	}
+
+	// A selected context decides the credentials; otherwise they are inferred from the cluster.
+	kubeContext := ""
+	if !kcs.inCluster {
+		kubeContext = KubeContextFromContext(ctx)
+	}
+
+	cacheKey := clientCacheKey(clusterName, kubeContext, identity)
+	if cached, ok := kcs.clients.Load(cacheKey); ok {
+		client = cached
+		return client, err
+	}
+
+	var restConfig *rest.Config
+	if kubeContext != "" {
+		restConfig, err = kcs.RestConfigForContext(kubeContext)
+	} else {
+		restConfig, err = kcs.RestConfigForCluster(clusterName)
+	}
+	if err != nil {
+		return client, err
+	}
+
+	if impersonate {
+		restConfig.Impersonate = rest.ImpersonationConfig{
+			UserName: identity.User,
+			Groups:   identity.Groups,
+		}
+	}
+	kcs.rateLimits.apply(restConfig, clusterName)
+	withRequestTimeout(restConfig, kcs.requestTimeout)
+	withRetries(restConfig, kcs.retries, clusterName)
+	withClusterHealth(restConfig, kcs.health, clusterName)
+
+	client, err = kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		err = fmt.Errorf("failed to create client for cluster %q: %w", clusterName, err)
+		return client, err
+	}
+
+	kcs.clients.Store(cacheKey, client)
+	return client, err
+}
+
 // CreateClientForCluster creates a Kubernetes client for the specified cluster.
 func (kcs *KubeConfigService) CreateClientForCluster(clusterName string) (client kubernetes.Interface, err error) {
+	var restConfig *rest.Config
+	restConfig, err = kcs.RestConfigForCluster(clusterName)
+	if err != nil {
+		return client, err
+	}
+	kcs.rateLimits.apply(restConfig, clusterName)
+	withRequestTimeout(restConfig, kcs.requestTimeout)
+	withRetries(restConfig, kcs.retries, clusterName)
+	withClusterHealth(restConfig, kcs.health, clusterName)
+
+	client, err = kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		err = fmt.Errorf("failed to create client for cluster %q: %w", clusterName, err)
+		return client, err
+	}
+
+	return client, err
+}
+
+// RestConfigForContext builds the REST client configuration for a kubeconfig context, using exactly the
+// cluster and user the context names.
+func (kcs *KubeConfigService) RestConfigForContext(contextName string) (restConfig *rest.Config, err error) {
+	var config *clientcmdapi.Config
+	config, err = kcs.loadKubeConfig()
+	if err != nil {
+		err = fmt.Errorf("failed to load kubeconfig: %w", err)
+		return restConfig, err
+	}
+
+	if _, exists := config.Contexts[contextName]; !exists {
+		err = fmt.Errorf("context %q not found in kubeconfig", contextName)
+		return restConfig, err
+	}
+
+	clientConfig := clientcmd.NewDefaultClientConfig(*config, &clientcmd.ConfigOverrides{CurrentContext: contextName})
+	restConfig, err = clientConfig.ClientConfig()
+	if err != nil {
+		err = fmt.Errorf("failed to create client config for context %q: %w", contextName, err)
+		return restConfig, err
+	}
+
+	kcs.logger.Debug("Created client config for context", zap.String("context", contextName))
+	return restConfig, err
+}
+
+// RestConfigForCluster builds the REST client configuration for the specified cluster.
+// When in cluster the cluster name is ignored and the in-cluster configuration is returned.
+func (kcs *KubeConfigService) RestConfigForCluster(clusterName string) (restConfig *rest.Config, err error) {
 	if kcs.inCluster {
 		// When in cluster, ignore cluster name and use in-cluster config
-		inClusterConfig, configErr := rest.InClusterConfig()
-		if configErr != nil {
-			err = fmt.Errorf("failed to get in-cluster config: %w", configErr)
-			return client, err
+		restConfig, err = rest.InClusterConfig()
+		if err != nil {
+			err = fmt.Errorf("failed to get in-cluster config: %w", err)
+			return restConfig, err
 		}
-		client, err = kubernetes.NewForConfig(inClusterConfig)
-		return client, err
+		return restConfig, err
 	}
 
 	// Load kubeconfig
 	var config *clientcmdapi.Config
-	config, err = clientcmd.LoadFromFile(kcs.kubeconfigPath)
+	config, err = kcs.loadKubeConfig()
 	if err != nil {
 		err = fmt.Errorf("failed to load kubeconfig: %w", err)
-		return client, err
+		return restConfig, err
 	}
 
 	// Verify cluster exists
 	cluster, exists := config.Clusters[clusterName]
 	if !exists {
 		err = fmt.Errorf("cluster %q not found in kubeconfig", clusterName)
-		return client, err
+		return restConfig, err
 	}
 
 	// Find the most commonly used user for this cluster
@@ -188,7 +663,7 @@ func (kcs *KubeConfigService) CreateClientForCluster(clusterName string) (client
 	userName, err = kcs.findBestUserForCluster(config, clusterName)
 	if err != nil {
 		err = fmt.Errorf("failed to find suitable user for cluster %q: %w", clusterName, err)
-		return client, err
+		return restConfig, err
 	}
 
 	// Create a virtual context combining the cluster and user
@@ -206,21 +681,14 @@ func (kcs *KubeConfigService) CreateClientForCluster(clusterName string) (client
 		CurrentContext: "virtual",
 	})
 
-	var restConfig *rest.Config
 	restConfig, err = clientConfig.ClientConfig()
 	if err != nil {
 		err = fmt.Errorf("failed to create client config for cluster %q (user %q): %w", clusterName, userName, err)
-		return client, err
-	}
-
-	client, err = kubernetes.NewForConfig(restConfig)
-	if err != nil {
-		err = fmt.Errorf("failed to create client for cluster %q (user %q): %w", clusterName, userName, err)
-		return client, err
+		return restConfig, err
 	}
 
-	kcs.logger.Info("Created Kubernetes client for cluster", zap.String("cluster", clusterName), zap.String("user", userName))
-	return client, err
+	kcs.logger.Debug("Created client config for cluster", zap.String("cluster", clusterName), zap.String("user", userName))
+	return restConfig, err
 }
 
 // GetCurrentCluster returns the current cluster name.
@@ -231,7 +699,7 @@ func (kcs *KubeConfigService) GetCurrentCluster() (clusterName string, err error
 	}
 
 	var config *clientcmdapi.Config
-	config, err = clientcmd.LoadFromFile(kcs.kubeconfigPath)
+	config, err = kcs.loadKubeConfig()
 	if err != nil {
 		err = fmt.Errorf("failed to load kubeconfig: %w", err)
 		return clusterName, err
@@ -247,7 +715,7 @@ func (kcs *KubeConfigService) GetCurrentCluster() (clusterName string, err error
 	return clusterName, err
 }
 
-// findBestUserForCluster finds the most commonly used user for a given cluster.
+// findBestUserForCluster finds the most commonly used user for a given cluster, breaking ties deterministically.
 func (kcs *KubeConfigService) findBestUserForCluster(config *clientcmdapi.Config, clusterName string) (bestUser string, err error) {
 	// Count how many contexts use each user for this cluster
 	userCounts := make(map[string]int)
@@ -263,11 +731,23 @@ func (kcs *KubeConfigService) findBestUserForCluster(config *clientcmdapi.Config
 		return bestUser, err
 	}
 
-	// Find the most commonly used user
-	var maxCount int
+	// Find the most commonly used user. Ties go to the current context's user, then to the first user by name,
+	// so the choice doesn't depend on map order.
+	currentUser := ""
+	if current, exists := config.Contexts[config.CurrentContext]; exists && current.Cluster == clusterName {
+		currentUser = current.AuthInfo
+	}
 
-	for user, count := range userCounts {
-		if count > maxCount {
+	users := make([]string, 0, len(userCounts))
+	for user := range userCounts {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+
+	var maxCount int
+	for _, user := range users {
+		count := userCounts[user]
+		if count > maxCount || count == maxCount && user == currentUser {
 			maxCount = count
 			bestUser = user
 		}
@@ -0,0 +1,182 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// DefaultInClusterName is the cluster name of the in-cluster connection when extra kubeconfigs are mounted.
+const DefaultInClusterName = "in-cluster"
+
+// serviceAccountDir is where Kubernetes mounts the pod's service account token and cluster CA.
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// kubeconfigDirStamp returns the current version of a kubeconfig directory: the latest modification time of
+// the directory or any file in it and the total size of the files. Secret and ConfigMap volumes swap a
+// symlink on update, which changes the modification time of every file seen through it.
+func kubeconfigDirStamp(dir string) (stamp kubeconfigStamp, err error) {
+	var info os.FileInfo
+	info, err = os.Stat(dir)
+	if err != nil {
+		return stamp, err
+	}
+	stamp.modTime = info.ModTime()
+
+	var paths []string
+	paths, err = kubeconfigDirFiles(dir)
+	if err != nil {
+		return stamp, err
+	}
+
+	for _, path := range paths {
+		info, err = os.Stat(path)
+		if err != nil {
+			return stamp, err
+		}
+		if info.ModTime().After(stamp.modTime) {
+			stamp.modTime = info.ModTime()
+		}
+		stamp.size += info.Size()
+	}
+
+	return stamp, err
+}
+
+// kubeconfigDirFiles returns the kubeconfig files in dir in name order. Hidden entries are skipped, which
+// leaves out the ..data bookkeeping links of Secret and ConfigMap volumes.
+func kubeconfigDirFiles(dir string) (paths []string, err error) {
+	var entries []os.DirEntry
+	entries, err = os.ReadDir(dir)
+	if err != nil {
+		return paths, err
+	}
+
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		info, statErr := os.Stat(path)
+		if statErr != nil || info.IsDir() {
+			continue
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, err
+}
+
+// loadKubeConfigDir builds one kubeconfig from the in-cluster connection and every kubeconfig file in the
+// directory. The in-cluster connection is the current context, so requests without a cluster stay local.
+// Files that fail to parse are logged and skipped so one bad Secret key doesn't hide the other clusters.
+// Clusters, users and contexts keep their names unless an earlier file already used the name, in which case
+// they are prefixed with the file name, e.g. "prod-kubernetes" for a second "kubernetes" cluster from "prod".
+func (kcs *KubeConfigService) loadKubeConfigDir() (config *clientcmdapi.Config, err error) {
+	config, err = inClusterKubeConfig(kcs.inClusterName)
+	if err != nil {
+		return config, err
+	}
+
+	var paths []string
+	paths, err = kubeconfigDirFiles(kcs.kubeconfigDir)
+	if err != nil {
+		return config, err
+	}
+
+	for _, path := range paths {
+		fileConfig, loadErr := clientcmd.LoadFromFile(path)
+		if loadErr == nil {
+			loadErr = clientcmd.ResolveLocalPaths(fileConfig)
+		}
+		if loadErr != nil {
+			kcs.logger.Warn("Skipping unreadable kubeconfig", zap.String("path", path), zap.Error(loadErr))
+			continue
+		}
+		mergeKubeConfig(config, fileConfig, filepath.Base(path))
+	}
+
+	return config, err
+}
+
+// inClusterKubeConfig describes the in-cluster connection as a kubeconfig with a single context. The token
+// is referenced by path rather than copied, so rotated service account tokens are picked up.
+func inClusterKubeConfig(name string) (config *clientcmdapi.Config, err error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		err = errors.New("in-cluster connection unavailable: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be set")
+		return config, err
+	}
+
+	config = clientcmdapi.NewConfig()
+	config.Clusters[name] = &clientcmdapi.Cluster{
+		Server:               "https://" + net.JoinHostPort(host, port),
+		CertificateAuthority: filepath.Join(serviceAccountDir, "ca.crt"),
+	}
+	config.AuthInfos[name] = &clientcmdapi.AuthInfo{TokenFile: filepath.Join(serviceAccountDir, "token")}
+	config.Contexts[name] = &clientcmdapi.Context{Cluster: name, AuthInfo: name}
+	config.CurrentContext = name
+	return config, err
+}
+
+// mergeKubeConfig adds the clusters, users and contexts of src to dst, prefixing names dst already has
+// with prefix and rewriting src's contexts to match.
+func mergeKubeConfig(dst, src *clientcmdapi.Config, prefix string) {
+	clusterNames := mergeNamed(dst.Clusters, src.Clusters, prefix)
+	userNames := mergeNamed(dst.AuthInfos, src.AuthInfos, prefix)
+
+	contexts := make(map[string]*clientcmdapi.Context, len(src.Contexts))
+	for name, kubeContext := range src.Contexts {
+		renamed := *kubeContext
+		if clusterName, ok := clusterNames[kubeContext.Cluster]; ok {
+			renamed.Cluster = clusterName
+		}
+		if userName, ok := userNames[kubeContext.AuthInfo]; ok {
+			renamed.AuthInfo = userName
+		}
+		contexts[name] = &renamed
+	}
+	mergeNamed(dst.Contexts, contexts, prefix)
+}
+
+// mergeNamed copies src into dst, prefixing any name dst already has, and returns the name each entry got.
+func mergeNamed[T any](dst, src map[string]T, prefix string) (names map[string]string) {
+	names = make(map[string]string, len(src))
+	for name, value := range src {
+		newName := name
+		if _, taken := dst[newName]; taken {
+			newName = prefix + "-" + name
+		}
+		dst[newName] = value
+		names[name] = newName
+	}
+	return names
+}
@@ -0,0 +1,123 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// writeTestKubeConfig writes a kubeconfig with a cluster and context of each name to path, replacing the file
+// the way editors and kubectl do.
+func writeTestKubeConfig(t *testing.T, path string, clusters ...string) {
+	t.Helper()
+	config := clientcmdapi.NewConfig()
+	for _, name := range clusters {
+		config.Clusters[name] = &clientcmdapi.Cluster{Server: "https://" + name + ".example.com"}
+		config.AuthInfos[name] = &clientcmdapi.AuthInfo{Token: name}
+		config.Contexts[name] = &clientcmdapi.Context{Cluster: name, AuthInfo: name}
+	}
+	config.CurrentContext = clusters[0]
+
+	tmp := path + ".tmp"
+	require.NoError(t, clientcmd.WriteToFile(*config, tmp))
+	require.NoError(t, os.Rename(tmp, path))
+}
+
+// clusterNames returns the names of the service's clusters.
+func clusterNames(t *testing.T, service *KubeConfigService) (names []string) {
+	t.Helper()
+	clusters, err := service.GetClusters()
+	require.NoError(t, err)
+	for _, cluster := range clusters {
+		names = append(names, cluster.Name)
+	}
+	return names
+}
+
+// watchTestKubeConfig serves the kubeconfig at path, watching it until the test ends.
+func watchTestKubeConfig(t *testing.T, path string) (service *KubeConfigService) {
+	t.Helper()
+	t.Setenv("KUBECONFIG", path)
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	service = NewKubeConfigService(ServerConfig{}, zap.NewNop())
+
+	watcher, err := service.newKubeConfigWatcher()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = watcher.Close() })
+	go service.watchKubeConfig(t.Context(), watcher)
+	return service
+}
+
+// TestWatchKubeConfig reloads the kubeconfig when it is replaced, keeping the last good one while it doesn't parse.
+func TestWatchKubeConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	writeTestKubeConfig(t, path, "staging")
+	service := watchTestKubeConfig(t, path)
+	assert.Equal(t, []string{"staging"}, clusterNames(t, service))
+
+	writeTestKubeConfig(t, path, "staging", "production")
+	assert.Eventually(t, func() bool {
+		return len(clusterNames(t, service)) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, os.WriteFile(path, []byte("clusters: [not a kubeconfig"), 0o600))
+	time.Sleep(3 * kubeconfigReloadDelay)
+	assert.ElementsMatch(t, []string{"staging", "production"}, clusterNames(t, service))
+
+	writeTestKubeConfig(t, path, "production")
+	assert.Eventually(t, func() bool {
+		names := clusterNames(t, service)
+		return len(names) == 1 && names[0] == "production"
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// TestWatchKubeConfigSymlinkSwap follows a kubeconfig mounted from a ConfigMap, which is updated by pointing
+// the ..data symlink at a new directory.
+func TestWatchKubeConfigSymlinkSwap(t *testing.T) {
+	mount := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(mount, "..v1"), 0o700))
+	writeTestKubeConfig(t, filepath.Join(mount, "..v1", "config"), "staging")
+	require.NoError(t, os.Symlink("..v1", filepath.Join(mount, "..data")))
+	require.NoError(t, os.Symlink(filepath.Join("..data", "config"), filepath.Join(mount, "config")))
+
+	service := watchTestKubeConfig(t, filepath.Join(mount, "config"))
+	assert.Equal(t, []string{"staging"}, clusterNames(t, service))
+
+	require.NoError(t, os.Mkdir(filepath.Join(mount, "..v2"), 0o700))
+	writeTestKubeConfig(t, filepath.Join(mount, "..v2", "config"), "staging", "production")
+	require.NoError(t, os.Symlink("..v2", filepath.Join(mount, "..data_tmp")))
+	require.NoError(t, os.Rename(filepath.Join(mount, "..data_tmp"), filepath.Join(mount, "..data")))
+
+	assert.Eventually(t, func() bool {
+		return len(clusterNames(t, service)) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+}
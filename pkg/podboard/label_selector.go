@@ -0,0 +1,171 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Regex selector operators are podboard's extension to Kubernetes label selectors: key=~pattern matches pods
+// whose label value matches the regular expression, and key!~pattern matches pods whose label value doesn't
+// or that don't have the label, like != does.
+const (
+	regexSelectorOperator        = "=~"
+	negatedRegexSelectorOperator = "!~"
+)
+
+// ErrInvalidLabelSelector is returned for label selectors that can't be parsed.
+var ErrInvalidLabelSelector = errors.New("invalid label selector")
+
+// podSelector is a label selector that may mix standard Kubernetes requirements, which the API server
+// evaluates, with =~ and !~ regex requirements, which only podboard can.
+type podSelector struct {
+	// standard is the selector without its regex requirements, in the form the API server accepts.
+	standard string
+	selector labels.Selector
+	regexes  []regexRequirement
+}
+
+// regexRequirement is a single key=~pattern or key!~pattern requirement.
+type regexRequirement struct {
+	key     string
+	pattern *regexp.Regexp
+	negated bool
+}
+
+// parsePodSelector parses a label selector. Requirements using =~ or !~ are split off and compiled, and the rest is
+// parsed by the Kubernetes label selector parser, so =, ==, !=, in, notin, exists and !key behave exactly as
+// they do with kubectl.
+func parsePodSelector(selector string) (parsed podSelector, err error) {
+	var standard []string
+	for _, requirement := range splitSelector(selector) {
+		key, pattern, negated, isRegex := cutRegexOperator(requirement)
+		if !isRegex {
+			standard = append(standard, requirement)
+			continue
+		}
+
+		key = strings.TrimSpace(key)
+		if key == "" {
+			err = fmt.Errorf("%w %q: missing label key", ErrInvalidLabelSelector, requirement)
+			return parsed, err
+		}
+		var compiled *regexp.Regexp
+		compiled, err = regexp.Compile(strings.TrimSpace(pattern))
+		if err != nil {
+			err = fmt.Errorf("%w %q: %w", ErrInvalidLabelSelector, requirement, err)
+			return parsed, err
+		}
+		parsed.regexes = append(parsed.regexes, regexRequirement{key: key, pattern: compiled, negated: negated})
+	}
+
+	parsed.standard = strings.Join(standard, ",")
+	parsed.selector, err = labels.Parse(parsed.standard)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ErrInvalidLabelSelector, err)
+		return parsed, err
+	}
+
+	return parsed, err
+}
+
+// hasRegex reports whether the selector has requirements the API server can't evaluate.
+func (sel podSelector) hasRegex() (regex bool) {
+	regex = len(sel.regexes) > 0
+	return regex
+}
+
+// Matches reports whether labels satisfy every requirement of the selector.
+func (sel podSelector) Matches(podLabels map[string]string) (matches bool) {
+	if !sel.selector.Matches(labels.Set(podLabels)) {
+		return matches
+	}
+
+	for _, requirement := range sel.regexes {
+		value, exists := podLabels[requirement.key]
+		if requirement.negated == (exists && requirement.pattern.MatchString(value)) {
+			return matches
+		}
+	}
+
+	matches = true
+	return matches
+}
+
+// cutRegexOperator splits a requirement around its first regex operator, reporting whether it is negated and
+// whether there is one at all.
+func cutRegexOperator(requirement string) (key, pattern string, negated, isRegex bool) {
+	operator := regexSelectorOperator
+	index := strings.Index(requirement, operator)
+	negatedIndex := strings.Index(requirement, negatedRegexSelectorOperator)
+	if negatedIndex >= 0 && (index < 0 || negatedIndex < index) {
+		operator, index, negated = negatedRegexSelectorOperator, negatedIndex, true
+	}
+	if index < 0 {
+		return key, pattern, negated, isRegex
+	}
+
+	key = requirement[:index]
+	pattern = requirement[index+len(operator):]
+	isRegex = true
+	return key, pattern, negated, isRegex
+}
+
+// splitSelector splits a selector into its requirements at the commas that separate them, leaving commas in
+// set-based value lists such as "env in (dev,staging)", in regex repetitions such as "a{1,3}" and in regex
+// character classes such as "[a,b]" alone.
+func splitSelector(selector string) (requirements []string) {
+	depth := 0
+	start := 0
+	for i, char := range selector {
+		switch char {
+		case '(', '{', '[':
+			depth++
+		case ')', '}', ']':
+			depth--
+		case ',':
+			if depth > 0 {
+				continue
+			}
+			requirements = appendRequirement(requirements, selector[start:i])
+			start = i + 1
+		}
+	}
+	requirements = appendRequirement(requirements, selector[start:])
+	return requirements
+}
+
+// appendRequirement appends a trimmed requirement, skipping empty ones.
+func appendRequirement(requirements []string, requirement string) (appended []string) {
+	appended = requirements
+	requirement = strings.TrimSpace(requirement)
+	if requirement != "" {
+		appended = append(appended, requirement)
+	}
+	return appended
+}
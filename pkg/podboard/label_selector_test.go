@@ -0,0 +1,156 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParsePodSelector splits regex requirements from the standard ones the API server evaluates, and matches
+// labels against both.
+func TestParsePodSelector(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector string
+		standard string
+		regexes  int
+		matches  []map[string]string
+		misses   []map[string]string
+	}{
+		{
+			name:     "empty selector",
+			selector: "",
+			matches:  []map[string]string{{}, {"app": "web"}},
+		},
+		{
+			name:     "standard only",
+			selector: "app=web,env in (dev,staging),!canary",
+			standard: "app=web,env in (dev,staging),!canary",
+			matches:  []map[string]string{{"app": "web", "env": "dev"}},
+			misses:   []map[string]string{{"app": "web", "env": "prod"}, {"app": "web", "env": "dev", "canary": "true"}},
+		},
+		{
+			name:     "mixed standard and regex",
+			selector: "env in (dev,staging), app=~^web-.*$ ,tier!=db",
+			standard: "env in (dev,staging),tier!=db",
+			regexes:  1,
+			matches:  []map[string]string{{"app": "web-1", "env": "dev"}, {"app": "web-2", "env": "staging", "tier": "frontend"}},
+			misses:   []map[string]string{{"app": "api-1", "env": "dev"}, {"app": "web-1", "env": "prod"}, {"app": "web-1", "env": "dev", "tier": "db"}},
+		},
+		{
+			name:     "regex requires the label",
+			selector: "app=~.*",
+			regexes:  1,
+			matches:  []map[string]string{{"app": ""}},
+			misses:   []map[string]string{{"tier": "web"}},
+		},
+		{
+			name:     "comma in repetition",
+			selector: "app=~^a{1,3}$,env=dev",
+			standard: "env=dev",
+			regexes:  1,
+			matches:  []map[string]string{{"app": "aaa", "env": "dev"}},
+			misses:   []map[string]string{{"app": "aaaa", "env": "dev"}},
+		},
+		{
+			name:     "comma in group",
+			selector: "app=~^(web,api|worker)$",
+			regexes:  1,
+			matches:  []map[string]string{{"app": "web,api"}, {"app": "worker"}},
+			misses:   []map[string]string{{"app": "web"}},
+		},
+		{
+			name:     "comma in character class",
+			selector: "version=~^v[0-9,]+$,app=web",
+			standard: "app=web",
+			regexes:  1,
+			matches:  []map[string]string{{"app": "web", "version": "v1,2"}},
+			misses:   []map[string]string{{"app": "web", "version": "v1.2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := parsePodSelector(tt.selector)
+			require.NoError(t, err)
+			assert.Equal(t, tt.standard, parsed.standard)
+			assert.Len(t, parsed.regexes, tt.regexes)
+			assert.Equal(t, tt.regexes > 0, parsed.hasRegex())
+			for _, podLabels := range tt.matches {
+				assert.True(t, parsed.Matches(podLabels), "%v should match", podLabels)
+			}
+			for _, podLabels := range tt.misses {
+				assert.False(t, parsed.Matches(podLabels), "%v should not match", podLabels)
+			}
+		})
+	}
+}
+
+// TestParsePodSelectorInvalid rejects selectors with a bad regex, a missing key or bad standard syntax.
+func TestParsePodSelectorInvalid(t *testing.T) {
+	for _, selector := range []string{
+		"app=~(web",
+		"app=~[a-",
+		"=~web",
+		"app=web,tier=~*",
+		"env in (dev",
+		"app in web",
+	} {
+		t.Run(selector, func(t *testing.T) {
+			_, err := parsePodSelector(selector)
+			require.ErrorIs(t, err, ErrInvalidLabelSelector)
+		})
+	}
+}
+
+// TestParsePodSelectorNegatedRegex matches pods whose label doesn't match the regex, or that don't have it.
+func TestParsePodSelectorNegatedRegex(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector string
+		labels   map[string]string
+		matches  bool
+	}{
+		{"value not matching", "env!~^(dev|sandbox)$", map[string]string{"env": "prod"}, true},
+		{"value matching", "env!~^(dev|sandbox)$", map[string]string{"env": "dev"}, false},
+		{"missing label", "env!~^(dev|sandbox)$", map[string]string{"app": "web"}, true},
+		{"no labels", "env!~.*", nil, true},
+		{"empty value matching", "env!~.*", map[string]string{"env": ""}, false},
+		{"with regex match", "app=~^web,env!~dev", map[string]string{"app": "web-1", "env": "prod"}, true},
+		{"with regex match on missing label", "app=~^web,env!~dev", map[string]string{"env": "prod"}, false},
+		{"with standard requirement", "tier=frontend,env!~dev", map[string]string{"tier": "frontend", "env": "dev-2"}, false},
+		{"operator inside pattern", "note!~x=~y", map[string]string{"note": "x=~y"}, false},
+		{"operator inside pattern not matching", "note!~x=~y", map[string]string{"note": "x"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := parsePodSelector(tt.selector)
+			require.NoError(t, err)
+			assert.Equal(t, tt.matches, parsed.Matches(tt.labels))
+		})
+	}
+}
@@ -0,0 +1,235 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+)
+
+// KindPod is the kind reported for pod changes.
+const KindPod = "Pod"
+
+// ErrInvalidLabels is returned when a label change is malformed.
+var ErrInvalidLabels = errors.New("invalid labels")
+
+// LabelChange describes a label update. A nil value means the label is, or was, absent.
+type LabelChange struct {
+	Kind      string             `json:"kind"`
+	Namespace string             `json:"namespace"`
+	Name      string             `json:"name"`
+	Previous  map[string]*string `json:"previous"`
+	Labels    map[string]*string `json:"labels"`
+}
+
+// LabelService changes labels on pods and deployments.
+type LabelService struct {
+	kubeConfigService ClientFactory
+	logger            *zap.Logger
+}
+
+// NewLabelService creates a new label service.
+func NewLabelService(kubeConfigService ClientFactory, logger *zap.Logger) (service *LabelService) {
+	service = &LabelService{
+		kubeConfigService: kubeConfigService,
+		logger:            logger,
+	}
+	return service
+}
+
+// SetLabels sets labels on a pod or deployment; labels with a nil value are removed.
+func (ls *LabelService) SetLabels(ctx context.Context, clusterName, kind, namespace, name string, labels map[string]*string) (change LabelChange, err error) {
+	err = validateLabels(labels)
+	if err != nil {
+		return change, err
+	}
+
+	var client kubernetes.Interface
+	client, err = ls.kubeConfigService.GetClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return change, err
+	}
+
+	var current map[string]string
+	current, err = currentLabels(ctx, client, kind, namespace, name)
+	if err != nil {
+		return change, err
+	}
+
+	change = LabelChange{Kind: kind, Namespace: namespace, Name: name, Previous: make(map[string]*string), Labels: labels}
+	for key := range labels {
+		if value, exists := current[key]; exists {
+			change.Previous[key] = &value
+		} else {
+			change.Previous[key] = nil
+		}
+	}
+
+	err = patchLabels(ctx, client, kind, namespace, name, labels)
+	if err != nil {
+		return change, err
+	}
+
+	ls.logger.Info("Labels changed", zap.String("cluster", clusterName), zap.String("kind", kind), zap.String("namespace", namespace), zap.String("name", name), zap.Strings("labels", labelKeys(labels)), zap.String("user", identityUser(ctx)), zap.String("requestId", requestID(ctx)))
+	return change, err
+}
+
+// labelReverter restores the previous values of the labels a change still holds. Labels changed again in
+// the meantime are left alone.
+func (ls *LabelService) labelReverter(clusterName string, change LabelChange) (fn revertFunc) {
+	fn = func(ctx context.Context) (state, message string) {
+		client, err := ls.kubeConfigService.GetClient(ctx, clusterName)
+		if err != nil {
+			state = RevertFailed
+			message = err.Error()
+			return state, message
+		}
+
+		current, err := currentLabels(ctx, client, change.Kind, change.Namespace, change.Name)
+		if err != nil {
+			state = RevertFailed
+			message = err.Error()
+			return state, message
+		}
+
+		restore := make(map[string]*string)
+		for key, value := range change.Labels {
+			currentValue, exists := current[key]
+			unchanged := (value == nil && !exists) || (value != nil && exists && currentValue == *value)
+			if unchanged {
+				restore[key] = change.Previous[key]
+			}
+		}
+		if len(restore) == 0 {
+			state = RevertSkipped
+			message = "labels were changed after the temporary change"
+			return state, message
+		}
+
+		err = patchLabels(ctx, client, change.Kind, change.Namespace, change.Name, restore)
+		if err != nil {
+			state = RevertFailed
+			message = err.Error()
+			return state, message
+		}
+
+		state = RevertDone
+		if len(restore) < len(change.Labels) {
+			message = fmt.Sprintf("left %d labels changed since alone", len(change.Labels)-len(restore))
+		}
+		return state, message
+	}
+	return fn
+}
+
+// validateLabels checks label keys and values follow the Kubernetes syntax.
+func validateLabels(labels map[string]*string) (err error) {
+	if len(labels) == 0 {
+		err = fmt.Errorf("%w: no labels given", ErrInvalidLabels)
+		return err
+	}
+
+	for key, value := range labels {
+		if problems := validation.IsQualifiedName(key); len(problems) > 0 {
+			err = fmt.Errorf("%w: key %q: %s", ErrInvalidLabels, key, strings.Join(problems, "; "))
+			return err
+		}
+		if value == nil {
+			continue
+		}
+		if problems := validation.IsValidLabelValue(*value); len(problems) > 0 {
+			err = fmt.Errorf("%w: value of %q: %s", ErrInvalidLabels, key, strings.Join(problems, "; "))
+			return err
+		}
+	}
+
+	return err
+}
+
+// currentLabels reads the labels of a pod or deployment.
+func currentLabels(ctx context.Context, client kubernetes.Interface, kind, namespace, name string) (labels map[string]string, err error) {
+	switch kind {
+	case KindPod:
+		pod, getErr := client.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+		if getErr != nil {
+			err = fmt.Errorf("failed to get pod %s/%s: %w", namespace, name, getErr)
+			return labels, err
+		}
+		labels = pod.Labels
+	case KindDeployment:
+		deployment, getErr := client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if getErr != nil {
+			err = fmt.Errorf("failed to get deployment %s/%s: %w", namespace, name, getErr)
+			return labels, err
+		}
+		labels = deployment.Labels
+	default:
+		err = fmt.Errorf("unsupported kind %q", kind)
+	}
+	return labels, err
+}
+
+// patchLabels applies a JSON merge patch to the labels of a pod or deployment. Nil values remove labels.
+func patchLabels(ctx context.Context, client kubernetes.Interface, kind, namespace, name string, labels map[string]*string) (err error) {
+	var patch []byte
+	patch, err = json.Marshal(map[string]interface{}{"metadata": map[string]interface{}{"labels": labels}})
+	if err != nil {
+		err = fmt.Errorf("failed to encode label patch: %w", err)
+		return err
+	}
+
+	switch kind {
+	case KindPod:
+		_, err = client.CoreV1().Pods(namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	case KindDeployment:
+		_, err = client.AppsV1().Deployments(namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	default:
+		err = fmt.Errorf("unsupported kind %q", kind)
+		return err
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to patch labels of %s %s/%s: %w", strings.ToLower(kind), namespace, name, err)
+		return err
+	}
+	return err
+}
+
+// labelKeys returns the sorted keys of a label change, for logging.
+func labelKeys(labels map[string]*string) (keys []string) {
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,274 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"net/http"
+	"runtime/metrics"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// loadSheddingRetryAfter is how long shed requests are told to wait before trying again.
+	loadSheddingRetryAfter = 5 * time.Second
+	// heapSampleInterval is how often the heap size is read while requests come in.
+	heapSampleInterval = 250 * time.Millisecond
+	// heapMetric is the runtime metric compared with the heap limit: memory occupied by live and not yet
+	// swept heap objects.
+	heapMetric = "/memory/classes/heap/objects:bytes"
+	// loadSlotContextKey holds the function releasing a request's load slot.
+	loadSlotContextKey = "podboard.loadSlot"
+	// overloadedContextKey marks requests that arrived while podboard was overloaded.
+	overloadedContextKey = "podboard.overloaded"
+)
+
+// essentialRoutes are never shed: what the board needs to show pods and the status of actions. Requests that
+// change something aren't shed either, since they are what incidents need.
+//
+//nolint:gochecknoglobals // Read-only lookup table
+var essentialRoutes = map[string]bool{
+	"/api/config":                true,
+	"/api/clusters":              true,
+	"/api/csrf":                  true,
+	"/api/cache/status":          true,
+	"/api/namespaces":            true,
+	"/api/pods":                  true,
+	"/api/pods/stream":           true,
+	"/api/pods/delta":            true,
+	"/api/pods/:namespace/:name": true,
+	"/api/deployments":           true,
+	"/api/events":                true,
+	"/api/jobs":                  true,
+	"/api/jobs/:id":              true,
+	"/api/pending-actions":       true,
+	"/api/reverts":               true,
+	"/api/quickscales":           true,
+}
+
+// staleRoutes are the list routes whose last good result is served under load, by the field respondList
+// caches it under.
+//
+//nolint:gochecknoglobals // Read-only lookup table
+var staleRoutes = map[string]string{
+	"/api/pods":                  "pods",
+	"/api/namespaces":            "namespaces",
+	"/api/deployments":           "deployments",
+	"/api/events":                "events",
+	"/api/nodes":                 "nodes",
+	"/api/preemptions":           "preemptions",
+	"/api/infrastructure-errors": "infrastructureErrors",
+	"/api/certificates":          "certificates",
+	"/api/pod-security":          "podSecurity",
+}
+
+// LoadShedder keeps podboard responsive when incident traffic overloads it. While more requests are in flight
+// than allowed, or the heap is above its limit, list requests are answered from the stale cache when it
+// holds their last result, and requests that aren't essential are rejected with a 503 and Retry-After.
+// Event streams don't count as in flight once started, since they stay open.
+type LoadShedder struct {
+	maxRequests  int64
+	maxHeapBytes uint64
+	logger       *zap.Logger
+
+	inFlight atomic.Int64
+
+	mu          sync.Mutex
+	heapBytes   uint64
+	heapReadAt  time.Time
+	overloaded  bool
+	since       time.Time
+	rejected    int
+	servedStale int
+}
+
+// NewLoadShedder creates a load shedder, or returns nil when neither limit is set.
+func NewLoadShedder(maxRequests int, maxHeapBytes uint64, logger *zap.Logger) (shedder *LoadShedder) {
+	if maxRequests <= 0 && maxHeapBytes == 0 {
+		return shedder
+	}
+	shedder = &LoadShedder{maxRequests: int64(maxRequests), maxHeapBytes: maxHeapBytes, logger: logger}
+	return shedder
+}
+
+// acquire counts a request as in flight, returning the function that stops counting it and whether
+// podboard is overloaded.
+func (ls *LoadShedder) acquire() (release func(), overloaded bool) {
+	inFlight := ls.inFlight.Add(1)
+	var once sync.Once
+	release = func() {
+		once.Do(func() { ls.inFlight.Add(-1) })
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	now := time.Now()
+	if ls.maxHeapBytes > 0 && now.Sub(ls.heapReadAt) >= heapSampleInterval {
+		ls.heapBytes = readHeapBytes()
+		ls.heapReadAt = now
+	}
+
+	overRequests := ls.maxRequests > 0 && inFlight > ls.maxRequests
+	overHeap := ls.maxHeapBytes > 0 && ls.heapBytes > ls.maxHeapBytes
+	overloaded = overRequests || overHeap
+
+	switch {
+	case overloaded && !ls.overloaded:
+		ls.overloaded, ls.since, ls.rejected, ls.servedStale = true, now, 0, 0
+		ls.logger.Warn("Overloaded, shedding load", zap.String("event", "overload_started"), zap.Bool("overRequests", overRequests), zap.Bool("overHeap", overHeap), zap.Int64("inFlight", inFlight), zap.Int64("maxRequests", ls.maxRequests), zap.Uint64("heapBytes", ls.heapBytes), zap.Uint64("maxHeapBytes", ls.maxHeapBytes))
+	case !overloaded && ls.overloaded:
+		ls.overloaded = false
+		ls.logger.Info("Overload ended", zap.String("event", "overload_ended"), zap.Duration("duration", now.Sub(ls.since)), zap.Int("rejected", ls.rejected), zap.Int("servedStale", ls.servedStale), zap.Int64("inFlight", inFlight), zap.Uint64("heapBytes", ls.heapBytes))
+	}
+	return release, overloaded
+}
+
+// record counts a request rejected or answered from the stale cache during the current overload.
+func (ls *LoadShedder) record(stale bool) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if stale {
+		ls.servedStale++
+		return
+	}
+	ls.rejected++
+}
+
+// readHeapBytes returns the memory held by heap objects.
+func readHeapBytes() (heapBytes uint64) {
+	sample := []metrics.Sample{{Name: heapMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() == metrics.KindUint64 {
+		heapBytes = sample[0].Value.Uint64()
+	}
+	return heapBytes
+}
+
+// loadSheddingMiddleware counts API requests in flight and sheds load while the shedder reports podboard
+// overloaded. It runs first, so requests it rejects cost no authentication or cluster lookups: requests that
+// aren't essential and have no stale result to fall back on are rejected right away, and the rest are marked
+// for staleFallbackMiddleware. Without a shedder every request goes through.
+func loadSheddingMiddleware(shedder *LoadShedder) (handler gin.HandlerFunc) {
+	handler = func(c *gin.Context) {
+		if shedder == nil {
+			c.Next()
+			return
+		}
+
+		release, overloaded := shedder.acquire()
+		defer release()
+		c.Set(loadSlotContextKey, release)
+
+		if !overloaded || c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+
+		_, cacheable := staleRoutes[c.FullPath()]
+		if !cacheable && !essentialRoutes[c.FullPath()] {
+			rejectOverloaded(c, shedder)
+			return
+		}
+		c.Set(overloadedContextKey, true)
+		c.Next()
+	}
+	return handler
+}
+
+// staleFallbackMiddleware answers the list requests marked by loadSheddingMiddleware from the stale cache when
+// it holds their last result, and rejects those that aren't essential otherwise. It runs after
+// clusterMiddleware, which the stale cache keys depend on.
+func staleFallbackMiddleware(services *apiServices) (handler gin.HandlerFunc) {
+	handler = func(c *gin.Context) {
+		if !c.GetBool(overloadedContextKey) {
+			c.Next()
+			return
+		}
+
+		if respondStale(c, services) {
+			services.loadShedder.record(true)
+			c.Abort()
+			return
+		}
+		if essentialRoutes[c.FullPath()] {
+			c.Next()
+			return
+		}
+		rejectOverloaded(c, services.loadShedder)
+	}
+	return handler
+}
+
+// rejectOverloaded answers a shed request with a 503 telling the client when to retry.
+func rejectOverloaded(c *gin.Context, shedder *LoadShedder) {
+	shedder.record(false)
+	c.Header("Retry-After", strconv.Itoa(int(loadSheddingRetryAfter.Seconds())))
+	respondErrorCode(c, http.StatusServiceUnavailable, "podboard is overloaded, try again shortly")
+}
+
+// respondStale answers a list request with its last good result, if the stale cache holds it.
+func respondStale(c *gin.Context, services *apiServices) (served bool) {
+	field, cacheable := staleRoutes[c.FullPath()]
+	if !cacheable {
+		return served
+	}
+	if field == "pods" && c.Query("groupBy") == GroupByOwner {
+		field = "groups"
+	}
+
+	clusterName := c.GetString(clusterContextKey)
+	cached, storedAt, ok := services.staleCache.Load(listCacheKey(c, clusterName, field, c.Request.URL.RawQuery))
+	if !ok {
+		return served
+	}
+
+	c.JSON(200, gin.H{
+		field:             cached,
+		"clusterDegraded": services.errorBudget.Degraded(clusterName),
+		"stale":           true,
+		"overloaded":      true,
+		"cachedAt":        storedAt.UTC().Format(time.RFC3339),
+		"cacheAgeSeconds": int64(time.Since(storedAt).Seconds()),
+	})
+	served = true
+	return served
+}
+
+// releaseLoadSlot stops counting a request as in flight, for event streams that stay open.
+func releaseLoadSlot(c *gin.Context) {
+	value, exists := c.Get(loadSlotContextKey)
+	if !exists {
+		return
+	}
+	release, ok := value.(func())
+	if ok {
+		release()
+	}
+}
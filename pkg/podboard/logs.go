@@ -0,0 +1,81 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ContainerLogs holds the most recent log lines of a container.
+type ContainerLogs struct {
+	Container string `json:"container"`
+	Logs      string `json:"logs"`
+	Error     string `json:"error,omitempty"`
+}
+
+// GetPodLogs retrieves the last tailLines log lines of every container in a pod.
+// A container whose logs can't be read (e.g. still creating) reports the error instead of failing the call.
+func (ps *PodService) GetPodLogs(ctx context.Context, clusterName, namespace, podName string, tailLines int64) (logs []ContainerLogs, err error) {
+	err = ps.namespacePolicy.Check(namespace)
+	if err != nil {
+		return logs, err
+	}
+
+	var client kubernetes.Interface
+	client, err = ps.getClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return logs, err
+	}
+
+	var pod *corev1.Pod
+	pod, err = client.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		err = fmt.Errorf("failed to get pod %s/%s: %w", namespace, podName, err)
+		return logs, err
+	}
+
+	for _, container := range pod.Spec.Containers {
+		containerLogs := ContainerLogs{Container: container.Name}
+
+		options := &corev1.PodLogOptions{Container: container.Name, TailLines: &tailLines}
+		content, logErr := client.CoreV1().Pods(namespace).GetLogs(podName, options).DoRaw(ctx)
+		if logErr != nil {
+			ps.logger.Debug("Failed to get container logs", zap.Error(logErr), zap.String("cluster", clusterName), zap.String("namespace", namespace), zap.String("pod", podName), zap.String("container", container.Name))
+			containerLogs.Error = logErr.Error()
+			logs = append(logs, containerLogs)
+			continue
+		}
+
+		containerLogs.Logs = string(content)
+		logs = append(logs, containerLogs)
+	}
+
+	return logs, err
+}
@@ -0,0 +1,101 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// Manifest formats.
+const (
+	ManifestFormatYAML = "yaml"
+	ManifestFormatJSON = "json"
+)
+
+// ErrInvalidManifestFormat is returned when a manifest is requested in an unsupported format.
+var ErrInvalidManifestFormat = errors.New("format must be yaml or json")
+
+// GetPodManifest returns the live pod object as YAML or JSON, as kubectl get -o would show it.
+// Managed fields are stripped unless keepManagedFields is set, since they are rarely useful and often
+// longer than the rest of the object. It returns ErrPodNotFound if the pod doesn't exist.
+func (ps *PodService) GetPodManifest(ctx context.Context, clusterName, namespace, podName, format string, keepManagedFields bool) (manifest []byte, err error) {
+	if format != ManifestFormatYAML && format != ManifestFormatJSON {
+		err = fmt.Errorf("%w: %q", ErrInvalidManifestFormat, format)
+		return manifest, err
+	}
+
+	err = ps.namespacePolicy.Check(namespace)
+	if err != nil {
+		return manifest, err
+	}
+
+	var client kubernetes.Interface
+	client, err = ps.getClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return manifest, err
+	}
+
+	var pod *corev1.Pod
+	pod, err = client.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			err = fmt.Errorf("%w: %s/%s", ErrPodNotFound, namespace, podName)
+			return manifest, err
+		}
+		err = fmt.Errorf("failed to get pod %s/%s: %w", namespace, podName, err)
+		return manifest, err
+	}
+
+	// Typed clients drop the type meta, which a manifest should carry.
+	pod.APIVersion = "v1"
+	pod.Kind = KindPod
+	if !keepManagedFields {
+		pod.ManagedFields = nil
+	}
+
+	manifest, err = json.MarshalIndent(pod, "", "  ")
+	if err != nil {
+		err = fmt.Errorf("failed to encode pod %s/%s: %w", namespace, podName, err)
+		return manifest, err
+	}
+
+	if format == ManifestFormatYAML {
+		manifest, err = yaml.JSONToYAML(manifest)
+		if err != nil {
+			err = fmt.Errorf("failed to encode pod %s/%s as YAML: %w", namespace, podName, err)
+			return manifest, err
+		}
+	}
+
+	return manifest, err
+}
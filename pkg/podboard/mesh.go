@@ -0,0 +1,296 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Service meshes podboard recognizes sidecars of.
+const (
+	MeshIstio   = "istio"
+	MeshLinkerd = "linkerd"
+)
+
+// Mesh problems reported for pods.
+const (
+	// MeshProblemProxyNotReady is reported when the sidecar proxy isn't ready, whatever the application says.
+	MeshProblemProxyNotReady = "ProxyNotReady"
+	// MeshProblemVersionSkew is reported when the proxy's version matches no running control plane.
+	MeshProblemVersionSkew = "VersionSkew"
+	// MeshProblemInjectionMissing is reported when the namespace or pod asks for a sidecar the pod doesn't have.
+	MeshProblemInjectionMissing = "InjectionMissing"
+)
+
+// meshCacheTTL is how long control plane versions and namespace injection settings are reused for pod listings.
+const meshCacheTTL = 30 * time.Second
+
+// Istio sidecar, injection and control plane conventions.
+const (
+	istioProxyContainer        = "istio-proxy"
+	istioInjectionLabel        = "istio-injection"
+	istioRevisionLabel         = "istio.io/rev"
+	istioInjectKey             = "sidecar.istio.io/inject"
+	istioControlPlaneSelector  = "app=istiod"
+	istioControlPlaneContainer = "discovery"
+	istioInjectionEnabled      = "enabled"
+	istioInjectionDisabled     = "disabled"
+)
+
+// Linkerd sidecar, injection and control plane conventions.
+const (
+	linkerdProxyContainer         = "linkerd-proxy"
+	linkerdInjectAnnotation       = "linkerd.io/inject"
+	linkerdProxyVersionAnnotation = "linkerd.io/proxy-version"
+	linkerdControlPlaneSelector   = "linkerd.io/control-plane-component=destination"
+	linkerdControlPlaneContainer  = "destination"
+	linkerdInjectEnabled          = "enabled"
+	linkerdInjectIngress          = "ingress"
+	linkerdInjectDisabled         = "disabled"
+)
+
+// MeshStatus is the health of a pod's service mesh sidecar, which can break traffic to a pod whose
+// application is healthy. ControlPlaneVersions are the versions of the mesh's running control planes, when
+// podboard can list them.
+type MeshStatus struct {
+	Mesh                 string   `json:"mesh"`
+	Sidecar              bool     `json:"sidecar"`
+	ProxyReady           bool     `json:"proxyReady"`
+	ProxyVersion         string   `json:"proxyVersion,omitempty"`
+	ControlPlaneVersions []string `json:"controlPlaneVersions,omitempty"`
+	Problems             []string `json:"problems,omitempty"`
+}
+
+// meshState is what pods are checked against: the control plane versions of each mesh and the mesh each
+// namespace asks to inject sidecars for.
+type meshState struct {
+	controlPlanes map[string][]string
+	injection     map[string]string
+}
+
+// MeshCache remembers each cluster's mesh control planes and namespace injection settings for pod listings,
+// keyed like clients. Both are best effort: without permission to list deployments or namespaces, pods are
+// checked without version skew or missing injection.
+type MeshCache struct {
+	logger *zap.Logger
+	states *ttlCache[string, meshState]
+}
+
+// NewMeshCache creates a new mesh cache.
+func NewMeshCache(logger *zap.Logger) (cache *MeshCache) {
+	cache = &MeshCache{
+		logger: logger,
+		states: newTTLCache[string, meshState](meshCacheTTL, 0),
+	}
+	return cache
+}
+
+// Get returns the mesh state of the cluster.
+func (mc *MeshCache) Get(ctx context.Context, client kubernetes.Interface, clusterName string) (state meshState) {
+	identity, _ := IdentityFromContext(ctx)
+	key := clientCacheKey(clusterName, KubeContextFromContext(ctx), identity)
+
+	state, ok := mc.states.Get(key)
+	if ok {
+		return state
+	}
+
+	state = meshState{
+		controlPlanes: map[string][]string{
+			MeshIstio:   mc.controlPlaneVersions(ctx, client, clusterName, istioControlPlaneSelector, istioControlPlaneContainer),
+			MeshLinkerd: mc.controlPlaneVersions(ctx, client, clusterName, linkerdControlPlaneSelector, linkerdControlPlaneContainer),
+		},
+		injection: mc.namespaceInjection(ctx, client, clusterName),
+	}
+	mc.states.Store(key, state)
+	return state
+}
+
+// controlPlaneVersions returns the image tags of a control plane's deployments, one per revision.
+func (mc *MeshCache) controlPlaneVersions(ctx context.Context, client kubernetes.Interface, clusterName, selector, containerName string) (versions []string) {
+	deployments, err := client.AppsV1().Deployments("").List(ctx, metav1.ListOptions{LabelSelector: selector, ResourceVersion: "0"})
+	if err != nil {
+		mc.logger.Debug("Mesh control plane unavailable", zap.Error(err), zap.String("cluster", clusterName), zap.String("selector", selector))
+		return versions
+	}
+
+	for _, deployment := range deployments.Items {
+		for _, container := range deployment.Spec.Template.Spec.Containers {
+			version := imageTagFromImage(container.Image)
+			if container.Name == containerName && !slices.Contains(versions, version) {
+				versions = append(versions, version)
+			}
+		}
+	}
+	slices.Sort(versions)
+	return versions
+}
+
+// namespaceInjection returns the mesh each namespace injects sidecars for.
+func (mc *MeshCache) namespaceInjection(ctx context.Context, client kubernetes.Interface, clusterName string) (injection map[string]string) {
+	injection = make(map[string]string)
+	namespaces, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{ResourceVersion: "0"})
+	if err != nil {
+		mc.logger.Debug("Namespace injection settings unavailable", zap.Error(err), zap.String("cluster", clusterName))
+		return injection
+	}
+
+	for _, namespace := range namespaces.Items {
+		istioInjection := namespace.Labels[istioInjectionLabel]
+		_, istioRevision := namespace.Labels[istioRevisionLabel]
+		linkerdInjection := namespace.Annotations[linkerdInjectAnnotation]
+		switch {
+		case istioInjection == istioInjectionEnabled || (istioRevision && istioInjection != istioInjectionDisabled):
+			injection[namespace.Name] = MeshIstio
+		case linkerdInjection == linkerdInjectEnabled || linkerdInjection == linkerdInjectIngress:
+			injection[namespace.Name] = MeshLinkerd
+		}
+	}
+	return injection
+}
+
+// podMeshStatus returns the mesh health of a pod, or nil for pods outside any mesh.
+func podMeshStatus(pod *corev1.Pod, state meshState) (status *MeshStatus) {
+	mesh, proxy, found := meshSidecar(pod)
+	if !found {
+		// Finished pods no longer need a proxy.
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			return status
+		}
+		mesh = expectedMesh(pod, state.injection[pod.Namespace])
+		if mesh == "" {
+			return status
+		}
+		status = &MeshStatus{Mesh: mesh, Problems: []string{MeshProblemInjectionMissing}}
+		return status
+	}
+
+	status = &MeshStatus{
+		Mesh:                 mesh,
+		Sidecar:              true,
+		ProxyReady:           containerReady(pod, proxy.Name),
+		ProxyVersion:         imageTagFromImage(proxy.Image),
+		ControlPlaneVersions: state.controlPlanes[mesh],
+	}
+	if version := pod.Annotations[linkerdProxyVersionAnnotation]; mesh == MeshLinkerd && version != "" {
+		status.ProxyVersion = version
+	}
+
+	// Pods still starting have no ready proxy yet; only running pods report it as a problem.
+	if !status.ProxyReady && pod.Status.Phase == corev1.PodRunning {
+		status.Problems = append(status.Problems, MeshProblemProxyNotReady)
+	}
+	if len(status.ControlPlaneVersions) > 0 && !meshVersionMatches(mesh, status.ProxyVersion, status.ControlPlaneVersions) {
+		status.Problems = append(status.Problems, MeshProblemVersionSkew)
+	}
+	return status
+}
+
+// meshSidecar finds a pod's mesh proxy, either a regular container or a native sidecar init container.
+func meshSidecar(pod *corev1.Pod) (mesh string, proxy corev1.Container, found bool) {
+	containers := slices.Concat(pod.Spec.Containers, pod.Spec.InitContainers)
+	for _, container := range containers {
+		switch container.Name {
+		case istioProxyContainer:
+			mesh, proxy, found = MeshIstio, container, true
+			return mesh, proxy, found
+		case linkerdProxyContainer:
+			mesh, proxy, found = MeshLinkerd, container, true
+			return mesh, proxy, found
+		}
+	}
+	return mesh, proxy, found
+}
+
+// expectedMesh returns the mesh a pod without a sidecar should have been injected into, considering the
+// namespace's setting and the pod's own opt in or out. Host network pods are never injected.
+func expectedMesh(pod *corev1.Pod, namespaceMesh string) (mesh string) {
+	if pod.Spec.HostNetwork {
+		return mesh
+	}
+
+	istioInject := pod.Labels[istioInjectKey]
+	if istioInject == "" {
+		istioInject = pod.Annotations[istioInjectKey]
+	}
+	linkerdInject := pod.Annotations[linkerdInjectAnnotation]
+
+	switch {
+	case istioInject == "true":
+		mesh = MeshIstio
+	case linkerdInject == linkerdInjectEnabled || linkerdInject == linkerdInjectIngress:
+		mesh = MeshLinkerd
+	case namespaceMesh == MeshIstio && istioInject != "false":
+		mesh = MeshIstio
+	case namespaceMesh == MeshLinkerd && linkerdInject != linkerdInjectDisabled:
+		mesh = MeshLinkerd
+	}
+	return mesh
+}
+
+// containerReady reports whether a container, regular or init, is ready.
+func containerReady(pod *corev1.Pod, name string) (ready bool) {
+	for _, status := range slices.Concat(pod.Status.ContainerStatuses, pod.Status.InitContainerStatuses) {
+		if status.Name == name {
+			ready = status.Ready
+			return ready
+		}
+	}
+	return ready
+}
+
+// meshVersionMatches reports whether a proxy version matches one of the control plane versions. Istio proxies
+// match a control plane of the same minor version, since patch releases interoperate; Linkerd proxies are
+// versioned with their control plane release and match it exactly.
+func meshVersionMatches(mesh, proxyVersion string, controlPlaneVersions []string) (matches bool) {
+	for _, controlPlaneVersion := range controlPlaneVersions {
+		if mesh == MeshIstio {
+			matches = minorVersion(proxyVersion) == minorVersion(controlPlaneVersion)
+		} else {
+			matches = proxyVersion == controlPlaneVersion
+		}
+		if matches {
+			return matches
+		}
+	}
+	return matches
+}
+
+// minorVersion returns the major.minor part of a version such as 1.20.3 or 1.20.3-distroless.
+func minorVersion(version string) (minor string) {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		minor = version
+		return minor
+	}
+	minor = parts[0] + "." + parts[1]
+	return minor
+}
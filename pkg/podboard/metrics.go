@@ -0,0 +1,200 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	metricsAPIPath = "/apis/metrics.k8s.io/v1beta1"
+	// metricsRetryInterval is how long to wait before retrying a cluster whose metrics API was unavailable.
+	metricsRetryInterval = 5 * time.Minute
+)
+
+// PodUsage represents current resource usage of a pod as reported by metrics-server.
+type PodUsage struct {
+	CPU           string           `json:"cpu"`
+	Memory        string           `json:"memory"`
+	CPUMillicores int64            `json:"cpuMillicores"`
+	MemoryBytes   int64            `json:"memoryBytes"`
+	Containers    []ContainerUsage `json:"containers"`
+}
+
+// ContainerUsage represents current resource usage of a single container.
+type ContainerUsage struct {
+	Name          string `json:"name"`
+	CPU           string `json:"cpu"`
+	Memory        string `json:"memory"`
+	CPUMillicores int64  `json:"cpuMillicores"`
+	MemoryBytes   int64  `json:"memoryBytes"`
+}
+
+// podMetricsList mirrors the subset of metrics.k8s.io/v1beta1 PodMetricsList used by podboard.
+type podMetricsList struct {
+	Items []podMetrics `json:"items"`
+}
+
+type podMetrics struct {
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	Containers []containerMetrics `json:"containers"`
+}
+
+type containerMetrics struct {
+	Name  string              `json:"name"`
+	Usage corev1.ResourceList `json:"usage"`
+}
+
+// MetricsService fetches pod resource usage from the metrics.k8s.io API.
+// Clusters without metrics-server are remembered for a while so pod listing isn't slowed by repeated failures.
+type MetricsService struct {
+	logger      *zap.Logger
+	mu          sync.Mutex
+	unavailable map[string]time.Time
+}
+
+// NewMetricsService creates a new metrics service.
+func NewMetricsService(logger *zap.Logger) (service *MetricsService) {
+	service = &MetricsService{
+		logger:      logger,
+		unavailable: make(map[string]time.Time),
+	}
+	return service
+}
+
+// GetPodUsage returns resource usage keyed by "namespace/name" for pods in the namespace.
+// An empty namespace returns usage for all namespaces. When the metrics API is absent or failing,
+// an empty map is returned with ok=false rather than an error, so callers can degrade gracefully.
+func (ms *MetricsService) GetPodUsage(ctx context.Context, client kubernetes.Interface, clusterName, namespace string) (usage map[string]PodUsage, ok bool) {
+	usage = make(map[string]PodUsage)
+
+	if ms.isUnavailable(clusterName) {
+		return usage, ok
+	}
+
+	restClient := client.Discovery().RESTClient()
+	if restClient == nil {
+		return usage, ok
+	}
+
+	path := metricsAPIPath + "/pods"
+	if namespace != "" {
+		path = fmt.Sprintf("%s/namespaces/%s/pods", metricsAPIPath, namespace)
+	}
+
+	raw, err := restClient.Get().AbsPath(path).DoRaw(ctx)
+	if err != nil {
+		ms.markUnavailable(clusterName, err)
+		return usage, ok
+	}
+
+	var list podMetricsList
+	err = json.Unmarshal(raw, &list)
+	if err != nil {
+		ms.logger.Warn("Failed to decode pod metrics", zap.Error(err), zap.String("cluster", clusterName))
+		return usage, ok
+	}
+
+	for _, item := range list.Items {
+		usage[item.Metadata.Namespace+"/"+item.Metadata.Name] = podMetricsToUsage(&item)
+	}
+
+	ok = true
+	return usage, ok
+}
+
+func (ms *MetricsService) isUnavailable(clusterName string) (unavailable bool) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	since, exists := ms.unavailable[clusterName]
+	if !exists {
+		return unavailable
+	}
+
+	if time.Since(since) > metricsRetryInterval {
+		delete(ms.unavailable, clusterName)
+		return unavailable
+	}
+
+	unavailable = true
+	return unavailable
+}
+
+func (ms *MetricsService) markUnavailable(clusterName string, err error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.unavailable[clusterName] = time.Now()
+	ms.logger.Info("Pod metrics unavailable, is metrics-server installed?", zap.String("cluster", clusterName), zap.Error(err), zap.Duration("retryAfter", metricsRetryInterval))
+}
+
+func podMetricsToUsage(item *podMetrics) (usage PodUsage) {
+	var cpuTotal, memoryTotal resource.Quantity
+
+	usage.Containers = make([]ContainerUsage, 0, len(item.Containers))
+	for _, container := range item.Containers {
+		cpu := container.Usage[corev1.ResourceCPU]
+		memory := container.Usage[corev1.ResourceMemory]
+		cpuTotal.Add(cpu)
+		memoryTotal.Add(memory)
+
+		usage.Containers = append(usage.Containers, ContainerUsage{
+			Name:          container.Name,
+			CPU:           formatCPU(cpu.MilliValue()),
+			Memory:        formatMemory(memory.Value()),
+			CPUMillicores: cpu.MilliValue(),
+			MemoryBytes:   memory.Value(),
+		})
+	}
+
+	usage.CPUMillicores = cpuTotal.MilliValue()
+	usage.MemoryBytes = memoryTotal.Value()
+	usage.CPU = formatCPU(usage.CPUMillicores)
+	usage.Memory = formatMemory(usage.MemoryBytes)
+	return usage
+}
+
+// formatCPU renders millicores the way 'kubectl top' does.
+func formatCPU(millicores int64) (formatted string) {
+	formatted = fmt.Sprintf("%dm", millicores)
+	return formatted
+}
+
+// formatMemory renders bytes in Mi the way 'kubectl top' does.
+func formatMemory(bytes int64) (formatted string) {
+	formatted = fmt.Sprintf("%dMi", bytes/(1024*1024))
+	return formatted
+}
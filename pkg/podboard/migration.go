@@ -0,0 +1,482 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Migration states.
+const (
+	MigrationRunning   = "Running"
+	MigrationCompleted = "Completed"
+	MigrationFailed    = "Failed"
+	MigrationCancelled = "Cancelled"
+	MigrationPlanned   = "Planned"
+)
+
+// Migration pod states.
+const (
+	MigrationPodPending = "Pending"
+	MigrationPodEvicted = "Evicted"
+	MigrationPodFailed  = "Failed"
+)
+
+const (
+	defaultMigrationInterval        = 10 * time.Second
+	defaultMigrationEvictionTimeout = 5 * time.Minute
+	migrationEvictionRetry          = 5 * time.Second
+	maxRetainedMigrations           = 50
+
+	// mirrorPodAnnotation marks static pods, which the API server cannot evict.
+	mirrorPodAnnotation = "kubernetes.io/config.mirror"
+)
+
+var (
+	// ErrMigrationNotFound is returned when a migration ID is unknown.
+	ErrMigrationNotFound = errors.New("migration not found")
+	// ErrInvalidMigration is returned when a migration request cannot be started.
+	ErrInvalidMigration = errors.New("invalid migration")
+)
+
+// MigrationRequest describes a bulk move of pods off a set of nodes.
+type MigrationRequest struct {
+	// NodeSelector selects the nodes to cordon and drain. It is required so a typo can't drain the whole cluster.
+	NodeSelector string `json:"nodeSelector"`
+	// PodSelector optionally limits the pods evicted from those nodes.
+	PodSelector string `json:"podSelector,omitempty"`
+	// Namespace optionally limits the pods evicted to one namespace.
+	Namespace string `json:"namespace,omitempty"`
+	// IntervalSeconds is the pause between evictions (default 10).
+	IntervalSeconds int `json:"intervalSeconds,omitempty"`
+	// EvictionTimeoutSeconds is how long to retry an eviction blocked by a PodDisruptionBudget (default 300).
+	EvictionTimeoutSeconds int `json:"evictionTimeoutSeconds,omitempty"`
+	// DryRun reports the nodes and pods that would be affected without cordoning or evicting anything.
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// MigrationPod is the progress of a single pod in a migration.
+type MigrationPod struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Node      string `json:"node"`
+	State     string `json:"state"`
+	Message   string `json:"message,omitempty"`
+}
+
+// Migration reports the progress of a migration.
+type Migration struct {
+	ID         string           `json:"id"`
+	Cluster    string           `json:"cluster,omitempty"`
+	User       string           `json:"user,omitempty"`
+	RequestID  string           `json:"requestId,omitempty"`
+	Request    MigrationRequest `json:"request"`
+	State      string           `json:"state"`
+	Nodes      []string         `json:"nodes"`
+	Pods       []MigrationPod   `json:"pods"`
+	Evicted    int              `json:"evicted"`
+	Failed     int              `json:"failed"`
+	StartedAt  time.Time        `json:"startedAt"`
+	FinishedAt *time.Time       `json:"finishedAt,omitempty"`
+}
+
+// migrationRun is a migration and the means to cancel it.
+type migrationRun struct {
+	migration Migration
+	cancel    context.CancelFunc
+}
+
+// MigrationService cordons nodes and evicts their pods at a controlled pace.
+type MigrationService struct {
+	kubeConfigService ClientFactory
+	logger            *zap.Logger
+
+	mu         sync.Mutex
+	migrations map[string]*migrationRun
+	order      []string
+}
+
+// NewMigrationService creates a new migration service.
+func NewMigrationService(kubeConfigService ClientFactory, logger *zap.Logger) (service *MigrationService) {
+	service = &MigrationService{
+		kubeConfigService: kubeConfigService,
+		logger:            logger,
+		migrations:        make(map[string]*migrationRun),
+	}
+	return service
+}
+
+// Start cordons the selected nodes and evicts their pods in the background, one at a time.
+// Evictions respect PodDisruptionBudgets; blocked evictions are retried until the eviction timeout.
+// The returned migration reflects the plan; use Get to follow progress.
+func (ms *MigrationService) Start(ctx context.Context, clusterName string, request MigrationRequest) (migration Migration, err error) {
+	if clusterName == ClusterAll {
+		err = fmt.Errorf("%w: migrations run against a single cluster", ErrInvalidMigration)
+		return migration, err
+	}
+
+	var client kubernetes.Interface
+	client, err = ms.kubeConfigService.GetClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return migration, err
+	}
+
+	migration, err = planMigration(ctx, client, request)
+	if err != nil {
+		return migration, err
+	}
+	migration.ID, err = newID()
+	if err != nil {
+		return migration, err
+	}
+	migration.Cluster = clusterName
+	migration.User = identityUser(ctx)
+	migration.RequestID = requestID(ctx)
+	migration.StartedAt = time.Now().UTC()
+
+	ms.logger.Info("Migration requested", zap.String("id", migration.ID), zap.String("user", migration.User), zap.String("requestId", migration.RequestID), zap.String("cluster", clusterName), zap.String("nodeSelector", request.NodeSelector), zap.String("podSelector", request.PodSelector), zap.Strings("nodes", migration.Nodes), zap.Int("pods", len(migration.Pods)), zap.Bool("dryRun", request.DryRun))
+
+	if request.DryRun {
+		migration.State = MigrationPlanned
+		migration.FinishedAt = &migration.StartedAt
+		ms.store(&migrationRun{migration: migration})
+		return migration, err
+	}
+
+	err = ms.cordonNodes(ctx, client, clusterName, migration.Nodes)
+	if err != nil {
+		return migration, err
+	}
+
+	// The migration outlives the request, but keeps its identity so evictions are made as the requesting user.
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	migration.State = MigrationRunning
+	ms.store(&migrationRun{migration: migration, cancel: cancel})
+
+	go ms.run(runCtx, client, migration.ID, request)
+
+	return migration, err
+}
+
+// Get returns a snapshot of a migration.
+func (ms *MigrationService) Get(id string) (migration Migration, err error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	run, exists := ms.migrations[id]
+	if !exists {
+		err = fmt.Errorf("%w: %s", ErrMigrationNotFound, id)
+		return migration, err
+	}
+
+	migration = snapshotMigration(run.migration)
+	return migration, err
+}
+
+// List returns snapshots of the retained migrations, newest first.
+func (ms *MigrationService) List() (migrations []Migration) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	migrations = make([]Migration, 0, len(ms.order))
+	for i := len(ms.order) - 1; i >= 0; i-- {
+		migrations = append(migrations, snapshotMigration(ms.migrations[ms.order[i]].migration))
+	}
+	return migrations
+}
+
+// Cancel stops a running migration after the current eviction. Cordoned nodes stay cordoned.
+func (ms *MigrationService) Cancel(ctx context.Context, id string) (migration Migration, err error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	run, exists := ms.migrations[id]
+	if !exists {
+		err = fmt.Errorf("%w: %s", ErrMigrationNotFound, id)
+		return migration, err
+	}
+
+	if run.migration.State == MigrationRunning && run.cancel != nil {
+		run.cancel()
+		run.migration.State = MigrationCancelled
+		ms.logger.Info("Migration cancelled", zap.String("id", id), zap.String("user", identityUser(ctx)), zap.String("requestId", requestID(ctx)))
+	}
+
+	migration = snapshotMigration(run.migration)
+	return migration, err
+}
+
+// planMigration resolves the nodes and pods a migration request affects.
+func planMigration(ctx context.Context, client kubernetes.Interface, request MigrationRequest) (migration Migration, err error) {
+	if request.NodeSelector == "" {
+		err = fmt.Errorf("%w: nodeSelector is required", ErrInvalidMigration)
+		return migration, err
+	}
+	_, err = labels.Parse(request.NodeSelector)
+	if err != nil {
+		err = fmt.Errorf("%w: nodeSelector: %w", ErrInvalidMigration, err)
+		return migration, err
+	}
+	_, err = labels.Parse(request.PodSelector)
+	if err != nil {
+		err = fmt.Errorf("%w: podSelector: %w", ErrInvalidMigration, err)
+		return migration, err
+	}
+
+	migration = Migration{Request: request, Nodes: []string{}, Pods: []MigrationPod{}}
+
+	var nodes *corev1.NodeList
+	nodes, err = client.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: request.NodeSelector})
+	if err != nil {
+		err = fmt.Errorf("failed to list nodes: %w", err)
+		return migration, err
+	}
+	if len(nodes.Items) == 0 {
+		err = fmt.Errorf("%w: no nodes match %q", ErrInvalidMigration, request.NodeSelector)
+		return migration, err
+	}
+
+	namespace := request.Namespace
+	if namespace == "all" {
+		namespace = ""
+	}
+
+	for _, node := range nodes.Items {
+		migration.Nodes = append(migration.Nodes, node.Name)
+
+		var pods *corev1.PodList
+		pods, err = client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: request.PodSelector,
+			FieldSelector: "spec.nodeName=" + node.Name,
+		})
+		if err != nil {
+			err = fmt.Errorf("failed to list pods on node %s: %w", node.Name, err)
+			return migration, err
+		}
+
+		for i := range pods.Items {
+			if !evictable(&pods.Items[i]) {
+				continue
+			}
+			migration.Pods = append(migration.Pods, MigrationPod{
+				Namespace: pods.Items[i].Namespace,
+				Name:      pods.Items[i].Name,
+				Node:      node.Name,
+				State:     MigrationPodPending,
+			})
+		}
+	}
+
+	sort.Strings(migration.Nodes)
+	return migration, err
+}
+
+// evictable returns false for pods that draining a node leaves alone: finished pods, static pods and
+// DaemonSet pods, which would be recreated on the same node.
+func evictable(pod *corev1.Pod) (ok bool) {
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return ok
+	}
+	if _, mirror := pod.Annotations[mirrorPodAnnotation]; mirror {
+		return ok
+	}
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == "DaemonSet" {
+			return ok
+		}
+	}
+	ok = true
+	return ok
+}
+
+// cordonNodes marks nodes unschedulable so evicted pods are rescheduled elsewhere.
+func (ms *MigrationService) cordonNodes(ctx context.Context, client kubernetes.Interface, clusterName string, nodes []string) (err error) {
+	for _, node := range nodes {
+		err = setUnschedulable(ctx, client, node, true)
+		if err != nil {
+			return err
+		}
+		ms.logger.Info("Node cordoned", zap.String("cluster", clusterName), zap.String("node", node), zap.String("user", identityUser(ctx)), zap.String("requestId", requestID(ctx)))
+	}
+	return err
+}
+
+// run evicts a migration's pods one at a time, pausing between evictions.
+func (ms *MigrationService) run(ctx context.Context, client kubernetes.Interface, id string, request MigrationRequest) {
+	interval := defaultMigrationInterval
+	if request.IntervalSeconds > 0 {
+		interval = time.Duration(request.IntervalSeconds) * time.Second
+	}
+	timeout := defaultMigrationEvictionTimeout
+	if request.EvictionTimeoutSeconds > 0 {
+		timeout = time.Duration(request.EvictionTimeoutSeconds) * time.Second
+	}
+
+	migration, _ := ms.Get(id)
+	for i, pod := range migration.Pods {
+		if i > 0 && !sleepContext(ctx, interval) {
+			break
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		state, message := ms.evict(ctx, client, pod, timeout)
+		ms.update(id, func(m *Migration) {
+			m.Pods[i].State = state
+			m.Pods[i].Message = message
+			switch state {
+			case MigrationPodEvicted:
+				m.Evicted++
+			case MigrationPodFailed:
+				m.Failed++
+			}
+		})
+	}
+
+	ms.update(id, func(m *Migration) {
+		finishedAt := time.Now().UTC()
+		m.FinishedAt = &finishedAt
+		switch {
+		case m.State == MigrationCancelled:
+			// Keep the cancelled state; the remaining pods stay pending.
+		case m.Failed > 0:
+			m.State = MigrationFailed
+		default:
+			m.State = MigrationCompleted
+		}
+	})
+
+	migration, _ = ms.Get(id)
+	ms.logger.Info("Migration finished", zap.String("id", id), zap.String("user", migration.User), zap.String("requestId", migration.RequestID), zap.String("state", migration.State), zap.Int("evicted", migration.Evicted), zap.Int("failed", migration.Failed))
+}
+
+// evict evicts a pod, retrying while a PodDisruptionBudget blocks the eviction.
+func (ms *MigrationService) evict(ctx context.Context, client kubernetes.Interface, pod MigrationPod, timeout time.Duration) (state, message string) {
+	eviction := &policyv1.Eviction{ObjectMeta: metav1.ObjectMeta{Namespace: pod.Namespace, Name: pod.Name}}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		err := client.CoreV1().Pods(pod.Namespace).EvictV1(ctx, eviction)
+		switch {
+		case err == nil:
+			state = MigrationPodEvicted
+			ms.logger.Info("Pod evicted", zap.String("namespace", pod.Namespace), zap.String("pod", pod.Name), zap.String("node", pod.Node), zap.String("user", identityUser(ctx)), zap.String("requestId", requestID(ctx)))
+			return state, message
+		case apierrors.IsNotFound(err):
+			state = MigrationPodEvicted
+			message = "pod was already gone"
+			return state, message
+		case apierrors.IsTooManyRequests(err) && time.Now().Before(deadline):
+			// Blocked by a PodDisruptionBudget; wait for replacements to become ready.
+			if !sleepContext(ctx, migrationEvictionRetry) {
+				state = MigrationPodPending
+				message = "cancelled while waiting for disruption budget"
+				return state, message
+			}
+		default:
+			state = MigrationPodFailed
+			message = err.Error()
+			ms.logger.Warn("Pod eviction failed", zap.String("namespace", pod.Namespace), zap.String("pod", pod.Name), zap.Error(err))
+			return state, message
+		}
+	}
+}
+
+// store records a migration, dropping the oldest finished migrations beyond the retention limit.
+func (ms *MigrationService) store(run *migrationRun) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.migrations[run.migration.ID] = run
+	ms.order = append(ms.order, run.migration.ID)
+
+	for len(ms.order) > maxRetainedMigrations {
+		oldest := ms.migrations[ms.order[0]]
+		if oldest.migration.State == MigrationRunning {
+			break
+		}
+		delete(ms.migrations, ms.order[0])
+		ms.order = ms.order[1:]
+	}
+}
+
+// update applies a change to a stored migration.
+func (ms *MigrationService) update(id string, change func(m *Migration)) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if run, exists := ms.migrations[id]; exists {
+		change(&run.migration)
+	}
+}
+
+// snapshotMigration copies a migration so callers can read it without holding the lock.
+func snapshotMigration(migration Migration) (snapshot Migration) {
+	snapshot = migration
+	snapshot.Nodes = append([]string{}, migration.Nodes...)
+	snapshot.Pods = append([]MigrationPod{}, migration.Pods...)
+	return snapshot
+}
+
+// sleepContext waits for d, returning false if the context is cancelled first.
+func sleepContext(ctx context.Context, d time.Duration) (completed bool) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return completed
+	case <-timer.C:
+		completed = true
+		return completed
+	}
+}
+
+// newMigrationID returns a random identifier for a migration.
+func newID() (id string, err error) {
+	buf := make([]byte, 8)
+	_, err = rand.Read(buf)
+	if err != nil {
+		err = fmt.Errorf("failed to generate id: %w", err)
+		return id, err
+	}
+	id = hex.EncodeToString(buf)
+	return id, err
+}
@@ -0,0 +1,223 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MQTT 3.1.1 packet types and flags used by mqttPublisher.
+const (
+	mqttConnect    = 0x10
+	mqttConnAck    = 0x20
+	mqttPublish    = 0x30
+	mqttDisconnect = 0xe0
+	// mqttRetain marks a PUBLISH as retained.
+	mqttRetain = 0x01
+	// mqttProtocolLevel is MQTT 3.1.1.
+	mqttProtocolLevel   = 4
+	mqttCleanSession    = 0x02
+	mqttPasswordFlag    = 0x40
+	mqttUsernameFlag    = 0x80
+	mqttMaxRemainingLen = 268435455
+)
+
+// mqttPublisher publishes to an MQTT 3.1.1 broker at QoS 0. Keep alive is disabled, since podboard only
+// publishes and a lost connection shows up as a failed write.
+type mqttPublisher struct {
+	bus  EventBusConfig
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// Publish sends a message to the topic made from the topic's levels, reconnecting once if the connection was
+// lost.
+func (mp *mqttPublisher) Publish(topic []string, payload []byte, retain bool) (err error) {
+	name := topic[0]
+	for _, level := range topic[1:] {
+		name += "/" + topicToken(level, "/+#")
+	}
+
+	var flags byte
+	if retain {
+		flags = mqttRetain
+	}
+	var packet []byte
+	packet, err = mqttPacket(mqttPublish|flags, mqttString(name), payload)
+	if err != nil {
+		return err
+	}
+
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if mp.conn == nil {
+			err = mp.connect()
+			if err != nil {
+				return err
+			}
+		}
+		err = writeEventBus(mp.conn, packet)
+		if err == nil {
+			return err
+		}
+		mp.conn.Close()
+		mp.conn = nil
+	}
+	return err
+}
+
+// Close disconnects from the broker.
+func (mp *mqttPublisher) Close() {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	if mp.conn != nil {
+		_ = writeEventBus(mp.conn, []byte{mqttDisconnect, 0})
+		mp.conn.Close()
+		mp.conn = nil
+	}
+}
+
+// connect dials the broker and sends CONNECT with a clean session, waiting for the CONNACK. The caller holds
+// the lock.
+func (mp *mqttPublisher) connect() (err error) {
+	var conn net.Conn
+	conn, err = dialEventBus(mp.bus)
+	if err != nil {
+		return err
+	}
+
+	flags := byte(mqttCleanSession)
+	payload := mqttString(mp.bus.ClientID)
+	username, password := eventBusCredentials(mp.bus)
+	if username != "" {
+		flags |= mqttUsernameFlag
+		payload = append(payload, mqttString(username)...)
+		if password != "" {
+			flags |= mqttPasswordFlag
+			payload = append(payload, mqttString(password)...)
+		}
+	}
+	// Protocol name, level, connect flags and a keep alive of 0, which disables it.
+	header := append(mqttString("MQTT"), mqttProtocolLevel, flags, 0, 0)
+
+	var packet []byte
+	packet, err = mqttPacket(mqttConnect, header, payload)
+	if err == nil {
+		err = writeEventBus(conn, packet)
+	}
+	if err == nil {
+		err = mp.readConnAck(conn)
+	}
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	mp.conn = conn
+	return err
+}
+
+// readConnAck reads the broker's CONNACK and returns an error if it refused the connection.
+func (mp *mqttPublisher) readConnAck(conn net.Conn) (err error) {
+	_ = conn.SetReadDeadline(time.Now().Add(eventBusDialTimeout))
+	defer func() { _ = conn.SetReadDeadline(time.Time{}) }()
+
+	ack := make([]byte, 4)
+	_, err = io.ReadFull(conn, ack)
+	if err != nil {
+		err = fmt.Errorf("failed to read CONNACK from mqtt broker at %s: %w", mp.bus.Address, err)
+		return err
+	}
+	if ack[0] != mqttConnAck || ack[1] != 2 {
+		err = fmt.Errorf("%s is not an mqtt broker: unexpected reply to CONNECT", mp.bus.Address)
+		return err
+	}
+	if ack[3] != 0 {
+		err = fmt.Errorf("mqtt broker at %s refused the connection: %s", mp.bus.Address, mqttConnectRefused(ack[3]))
+		return err
+	}
+	return err
+}
+
+// mqttConnectRefused explains the return code of a refused CONNACK.
+func mqttConnectRefused(code byte) (reason string) {
+	switch code {
+	case 1:
+		reason = "unacceptable protocol version"
+	case 2:
+		reason = "client identifier rejected"
+	case 3:
+		reason = "server unavailable"
+	case 4:
+		reason = "bad user name or password"
+	case 5:
+		reason = "not authorized"
+	default:
+		reason = fmt.Sprintf("return code %d", code)
+	}
+	return reason
+}
+
+// mqttPacket assembles a packet from its first byte, variable header and payload.
+func mqttPacket(first byte, header, payload []byte) (packet []byte, err error) {
+	length := len(header) + len(payload)
+	if length > mqttMaxRemainingLen {
+		err = fmt.Errorf("mqtt packet of %d bytes is too large", length)
+		return packet, err
+	}
+
+	packet = append(make([]byte, 0, length+5), first)
+	// The remaining length is encoded 7 bits at a time, least significant first.
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		packet = append(packet, digit)
+		if length == 0 {
+			break
+		}
+	}
+	packet = append(packet, header...)
+	packet = append(packet, payload...)
+	return packet, err
+}
+
+// mqttString encodes a string with its two byte length prefix. Longer strings are truncated, which only
+// affects topics no broker would accept anyway.
+func mqttString(s string) (encoded []byte) {
+	s = strings.ToValidUTF8(s, "_")
+	if len(s) > 0xffff {
+		s = s[:0xffff]
+	}
+	encoded = append([]byte{byte(len(s) >> 8), byte(len(s))}, s...)
+	return encoded
+}
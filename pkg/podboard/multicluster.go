@@ -0,0 +1,63 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// ClusterAll selects every cluster in the kubeconfig.
+	ClusterAll = "all"
+	// clusterQueryTimeout bounds each cluster's query so one slow cluster doesn't hold up the merged view.
+	clusterQueryTimeout = 10 * time.Second
+)
+
+// GetPodsAllClusters lists pods in every kubeconfig cluster concurrently and merges them, sorted by cluster,
+// namespace and name, with the Cluster field set. Clusters that fail or time out are reported in clusterErrors;
+// err is only set when no cluster could be listed. In cluster the single in-cluster connection is used.
+func (ps *PodService) GetPodsAllClusters(ctx context.Context, namespace, labelSelector string, filter PodFilter) (podInfos []PodInfo, clusterErrors map[string]string, err error) {
+	var result PodQueryResult
+	result, err = ps.QueryPods(ctx, ClusterAll, namespace, labelSelector, filter)
+	clusterErrors = result.ClusterErrors()
+	podInfos = result.Pods
+	return podInfos, clusterErrors, err
+}
+
+// ClusterErrors maps each cluster with a failed branch to its error, joining the errors of several failed
+// namespaces in one cluster.
+func (result PodQueryResult) ClusterErrors() (clusterErrors map[string]string) {
+	clusterErrors = make(map[string]string)
+	for _, branch := range result.Failed() {
+		if branch.Cluster == "" {
+			continue
+		}
+		if previous, exists := clusterErrors[branch.Cluster]; exists {
+			clusterErrors[branch.Cluster] = previous + "; " + branch.Error
+			continue
+		}
+		clusterErrors[branch.Cluster] = branch.Error
+	}
+	return clusterErrors
+}
@@ -0,0 +1,205 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrNamespaceDenied is returned for namespaces the server's namespace allowlist or denylist excludes.
+var ErrNamespaceDenied = errors.New("namespace not permitted")
+
+// ErrInvalidNamespacePattern is returned for allowlist and denylist patterns that don't compile.
+var ErrInvalidNamespacePattern = errors.New("invalid namespace pattern")
+
+// NamespacePolicy limits every user of the server to some namespaces: those matching an allowed pattern, when
+// there are any, and no denied pattern. The denylist wins. Patterns are globs such as team-*, or regular
+// expressions between slashes such as /^team-(a|b)$/.
+type NamespacePolicy struct {
+	allowed []namespacePattern
+	denied  []namespacePattern
+}
+
+// namespacePattern matches namespace names with either a glob or a regular expression.
+type namespacePattern struct {
+	glob   string
+	regexp *regexp.Regexp
+}
+
+// NewNamespacePolicy compiles the allowed and denied patterns, returning nil when there are none.
+func NewNamespacePolicy(allowed, denied []string) (policy *NamespacePolicy, err error) {
+	if len(allowed) == 0 && len(denied) == 0 {
+		return policy, err
+	}
+
+	policy = &NamespacePolicy{}
+	policy.allowed, err = compileNamespacePatterns(allowed)
+	if err != nil {
+		policy = nil
+		return policy, err
+	}
+	policy.denied, err = compileNamespacePatterns(denied)
+	if err != nil {
+		policy = nil
+		return policy, err
+	}
+	return policy, err
+}
+
+// serverNamespacePolicy returns the policy of the server's allowed and denied namespaces. validateServerConfig
+// rejects invalid patterns at startup; should one get through, every namespace is denied rather than none.
+func serverNamespacePolicy(config ServerConfig) (policy *NamespacePolicy) {
+	policy, err := NewNamespacePolicy(config.AllowedNamespaces, config.DeniedNamespaces)
+	if err != nil {
+		policy = &NamespacePolicy{denied: []namespacePattern{{glob: "*"}}}
+	}
+	return policy
+}
+
+// compileNamespacePatterns compiles each pattern, checking globs for syntax errors up front.
+func compileNamespacePatterns(patterns []string) (compiled []namespacePattern, err error) {
+	for _, pattern := range patterns {
+		switch {
+		case pattern == "":
+			continue
+		case len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/"):
+			var re *regexp.Regexp
+			re, err = regexp.Compile(pattern[1 : len(pattern)-1])
+			if err != nil {
+				err = fmt.Errorf("%w %q: %w", ErrInvalidNamespacePattern, pattern, err)
+				return compiled, err
+			}
+			compiled = append(compiled, namespacePattern{regexp: re})
+		default:
+			_, err = path.Match(pattern, "")
+			if err != nil {
+				err = fmt.Errorf("%w %q: %w", ErrInvalidNamespacePattern, pattern, err)
+				return compiled, err
+			}
+			compiled = append(compiled, namespacePattern{glob: pattern})
+		}
+	}
+	return compiled, err
+}
+
+// matches reports whether the pattern matches a namespace.
+func (pattern namespacePattern) matches(namespace string) (matched bool) {
+	if pattern.regexp != nil {
+		matched = pattern.regexp.MatchString(namespace)
+		return matched
+	}
+	matched, _ = path.Match(pattern.glob, namespace)
+	return matched
+}
+
+// Allows reports whether a namespace is permitted. Without a policy every namespace is.
+func (policy *NamespacePolicy) Allows(namespace string) (allowed bool) {
+	if policy == nil {
+		allowed = true
+		return allowed
+	}
+
+	for _, pattern := range policy.denied {
+		if pattern.matches(namespace) {
+			return allowed
+		}
+	}
+	if len(policy.allowed) == 0 {
+		allowed = true
+		return allowed
+	}
+	for _, pattern := range policy.allowed {
+		if pattern.matches(namespace) {
+			allowed = true
+			return allowed
+		}
+	}
+	return allowed
+}
+
+// Check returns ErrNamespaceDenied for a namespace that isn't permitted. An empty or all namespace passes;
+// results spanning namespaces are filtered instead.
+func (policy *NamespacePolicy) Check(namespace string) (err error) {
+	for _, requested := range splitNamespaces(namespace) {
+		if requested == "" || requested == "all" || policy.Allows(requested) {
+			continue
+		}
+		err = fmt.Errorf("%w: %q", ErrNamespaceDenied, requested)
+		return err
+	}
+	return err
+}
+
+// Filter returns the permitted namespaces, in their original order.
+func (policy *NamespacePolicy) Filter(namespaces []string) (allowed []string) {
+	allowed = filterNamespaced(policy, namespaces, func(namespace string) (name string) {
+		name = namespace
+		return name
+	})
+	return allowed
+}
+
+// filterNamespaced returns the items in permitted namespaces, in their original order.
+func filterNamespaced[T any](policy *NamespacePolicy, items []T, namespaceOf func(T) string) (allowed []T) {
+	if policy == nil {
+		allowed = items
+		return allowed
+	}
+
+	allowed = make([]T, 0, len(items))
+	for _, item := range items {
+		if policy.Allows(namespaceOf(item)) {
+			allowed = append(allowed, item)
+		}
+	}
+	return allowed
+}
+
+// namespacePolicyMiddleware rejects requests naming a namespace the policy excludes with a 403, in a path
+// parameter or the namespace query parameter, including each of a comma-separated list.
+func namespacePolicyMiddleware(policy *NamespacePolicy) (handler gin.HandlerFunc) {
+	handler = func(c *gin.Context) {
+		if policy == nil {
+			c.Next()
+			return
+		}
+
+		err := policy.Check(c.Param("namespace"))
+		if err == nil {
+			err = policy.Check(c.Query("namespace"))
+		}
+		if err != nil {
+			respondErrorCode(c, http.StatusForbidden, err.Error())
+			return
+		}
+		c.Next()
+	}
+	return handler
+}
@@ -0,0 +1,123 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestNamespacePolicy matches namespaces against glob and regular expression patterns, the denylist winning.
+func TestNamespacePolicy(t *testing.T) {
+	policy, err := NewNamespacePolicy([]string{"team-*", "/^ops-(a|b)$/"}, []string{"team-secret"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		namespace string
+		allowed   bool
+	}{
+		{"team-a", true},
+		{"team-secret", false},
+		{"ops-a", true},
+		{"ops-c", false},
+		{"ops-ab", false},
+		{"default", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.namespace, func(t *testing.T) {
+			assert.Equal(t, tt.allowed, policy.Allows(tt.namespace))
+		})
+	}
+
+	assert.Equal(t, []string{"team-a", "ops-b"}, policy.Filter([]string{"default", "team-a", "team-secret", "ops-b"}))
+	require.ErrorIs(t, policy.Check("team-a,default"), ErrNamespaceDenied)
+	require.NoError(t, policy.Check("all"))
+}
+
+// TestNamespacePolicyDenyOnly allows every namespace the denylist doesn't match.
+func TestNamespacePolicyDenyOnly(t *testing.T) {
+	policy, err := NewNamespacePolicy(nil, []string{"kube-*"})
+	require.NoError(t, err)
+	assert.True(t, policy.Allows("default"))
+	assert.False(t, policy.Allows("kube-system"))
+
+	policy, err = NewNamespacePolicy(nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, policy)
+	assert.True(t, policy.Allows("kube-system"), "no policy allows every namespace")
+}
+
+// TestNamespacePolicyInvalid rejects patterns that don't compile, at startup too.
+func TestNamespacePolicyInvalid(t *testing.T) {
+	_, err := NewNamespacePolicy([]string{"team-["}, nil)
+	require.ErrorIs(t, err, ErrInvalidNamespacePattern)
+
+	_, err = NewNamespacePolicy(nil, []string{"/team-(/"})
+	require.ErrorIs(t, err, ErrInvalidNamespacePattern)
+
+	err = validateServerConfig(ServerConfig{DeniedNamespaces: []string{"team-["}})
+	require.ErrorIs(t, err, ErrInvalidNamespacePattern)
+}
+
+// TestNamespacePolicyMiddleware refuses requests naming excluded namespaces and filters listings.
+func TestNamespacePolicyMiddleware(t *testing.T) {
+	router := newTestRouter(t, ServerConfig{AllowedNamespaces: []string{"team-*"}, DeniedNamespaces: []string{"team-secret"}}, FileConfig{},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-secret"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+		testPod("team-a", "web-a", "node-1"),
+		testPod("team-secret", "vault", "node-1"),
+		testPod("kube-system", "coredns", "node-1"),
+	)
+
+	for _, route := range []struct {
+		method string
+		target string
+	}{
+		{http.MethodGet, "/api/pods?namespace=kube-system"},
+		{http.MethodGet, "/api/pods?namespace=team-a,team-secret"},
+		{http.MethodGet, "/api/pods/team-secret/vault"},
+		{http.MethodDelete, "/api/pods/kube-system/coredns"},
+	} {
+		recorder := serve(router, route.method, route.target, nil)
+		assert.Equal(t, http.StatusForbidden, recorder.Code, "%s %s", route.method, route.target)
+	}
+
+	recorder := serve(router, http.MethodGet, "/api/pods?namespace=all", nil)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.Equal(t, []string{"web-a"}, podNames(t, decodeBody(t, recorder)))
+
+	recorder = serve(router, http.MethodGet, "/api/nodes/node-1/pods", nil)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.Equal(t, []string{"web-a"}, podNames(t, decodeBody(t, recorder)))
+
+	recorder = serve(router, http.MethodGet, "/api/namespaces", nil)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.Equal(t, []interface{}{"team-a"}, decodeBody(t, recorder)["namespaces"])
+}
@@ -0,0 +1,96 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// serviceAccountNamespaceFile holds the namespace podboard runs in when it runs in a pod.
+const serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// namespaceAccessTTL is how long the namespaces found by an access sweep are reused.
+const namespaceAccessTTL = 5 * time.Minute
+
+// namespaceCandidates are the namespaces an access sweep checks: the configured namespaces and, when running
+// in a pod, podboard's own namespace.
+func namespaceCandidates(configured []string) (candidates []string) {
+	candidates = slices.Clone(configured)
+	content, err := os.ReadFile(serviceAccountNamespaceFile)
+	if err == nil {
+		own := strings.TrimSpace(string(content))
+		if own != "" {
+			candidates = append(candidates, own)
+		}
+	}
+	slices.Sort(candidates)
+	candidates = slices.Compact(candidates)
+	return candidates
+}
+
+// accessibleNamespaces sweeps the candidate namespaces with SelfSubjectAccessReviews, returning those podboard,
+// or the impersonated user, may list pods in. It is the fallback for namespace-scoped RBAC, where listing
+// namespaces is forbidden.
+func (ps *PodService) accessibleNamespaces(ctx context.Context, client kubernetes.Interface, clusterName string) (names []string, err error) {
+	identity, impersonate := IdentityFromContext(ctx)
+	if !impersonate {
+		identity = Identity{}
+	}
+	key := clientCacheKey(clusterName, KubeContextFromContext(ctx), identity)
+	names, cached := ps.namespaceAccess.Get(key)
+	if cached {
+		return names, err
+	}
+
+	names = []string{}
+	for _, namespace := range namespaceCandidates(ps.config.Namespaces) {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{Namespace: namespace, Verb: "list", Resource: "pods"},
+			},
+		}
+		var result *authorizationv1.SelfSubjectAccessReview
+		result, err = client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			err = fmt.Errorf("failed to review access to namespace %s: %w", namespace, err)
+			return names, err
+		}
+		if result.Status.Allowed {
+			names = append(names, namespace)
+		}
+	}
+
+	ps.logger.Debug("Discovered accessible namespaces", zap.String("cluster", clusterName), zap.Strings("namespaces", names))
+	ps.namespaceAccess.Store(key, names)
+	return names, err
+}
@@ -0,0 +1,213 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// natsConnect is the CONNECT message podboard sends to a NATS server.
+type natsConnect struct {
+	Verbose     bool   `json:"verbose"`
+	Pedantic    bool   `json:"pedantic"`
+	TLSRequired bool   `json:"tls_required"`
+	Name        string `json:"name"`
+	Lang        string `json:"lang"`
+	Version     string `json:"version"`
+	Protocol    int    `json:"protocol"`
+	User        string `json:"user,omitempty"`
+	Pass        string `json:"pass,omitempty"`
+}
+
+// natsPublisher publishes to a NATS server with the core NATS protocol. It only publishes, so the only
+// messages it handles from the server are PINGs, which it answers to keep the connection open.
+type natsPublisher struct {
+	bus    EventBusConfig
+	logger *zap.Logger
+	mu     sync.Mutex
+	conn   net.Conn
+}
+
+// Publish sends a message to the subject made from the topic's levels, reconnecting once if the connection
+// was lost. NATS has no retained messages, so retain is ignored.
+func (np *natsPublisher) Publish(topic []string, payload []byte, _ bool) (err error) {
+	subject := topic[0]
+	for _, level := range topic[1:] {
+		subject += "." + topicToken(level, ".*>")
+	}
+	message := make([]byte, 0, len(subject)+len(payload)+32)
+	message = fmt.Appendf(message, "PUB %s %d\r\n", subject, len(payload))
+	message = append(message, payload...)
+	message = append(message, "\r\n"...)
+
+	np.mu.Lock()
+	defer np.mu.Unlock()
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if np.conn == nil {
+			err = np.connect()
+			if err != nil {
+				return err
+			}
+		}
+		err = writeEventBus(np.conn, message)
+		if err == nil {
+			return err
+		}
+		np.conn.Close()
+		np.conn = nil
+	}
+	return err
+}
+
+// Close closes the connection to the server.
+func (np *natsPublisher) Close() {
+	np.mu.Lock()
+	defer np.mu.Unlock()
+	if np.conn != nil {
+		np.conn.Close()
+		np.conn = nil
+	}
+}
+
+// connect dials the server, upgrades to TLS after its INFO when configured, and sends CONNECT, waiting for
+// the PONG that confirms the server accepted it. The caller holds the lock.
+func (np *natsPublisher) connect() (err error) {
+	var conn net.Conn
+	conn, err = dialEventBus(EventBusConfig{Address: np.bus.Address})
+	if err != nil {
+		return err
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(eventBusDialTimeout))
+	reader := bufio.NewReader(conn)
+	var line string
+	line, err = reader.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		err = fmt.Errorf("failed to read INFO from nats server at %s: %w", np.bus.Address, err)
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		err = fmt.Errorf("%s is not a nats server: got %q", np.bus.Address, strings.TrimSpace(line))
+		return err
+	}
+
+	if np.bus.TLS {
+		tlsConn := tls.Client(conn, eventBusTLSConfig(np.bus))
+		err = tlsConn.Handshake()
+		if err != nil {
+			conn.Close()
+			err = fmt.Errorf("failed TLS handshake with %s: %w", np.bus.Address, err)
+			return err
+		}
+		conn = tlsConn
+		reader = bufio.NewReader(conn)
+	}
+
+	err = np.handshake(conn, reader)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	_ = conn.SetReadDeadline(time.Time{})
+	np.conn = conn
+	go np.readLoop(conn, reader)
+	return err
+}
+
+// handshake sends CONNECT and a PING, and waits for the PONG.
+func (np *natsPublisher) handshake(conn net.Conn, reader *bufio.Reader) (err error) {
+	username, password := eventBusCredentials(np.bus)
+	connect := natsConnect{TLSRequired: np.bus.TLS, Name: np.bus.ClientID, Lang: "go", Version: Version, Protocol: 1, User: username, Pass: password}
+
+	var options []byte
+	options, err = json.Marshal(connect)
+	if err != nil {
+		err = fmt.Errorf("failed to encode nats CONNECT: %w", err)
+		return err
+	}
+	message := fmt.Appendf(nil, "CONNECT %s\r\nPING\r\n", options)
+	err = writeEventBus(conn, message)
+	if err != nil {
+		return err
+	}
+
+	for {
+		var line string
+		line, err = reader.ReadString('\n')
+		if err != nil {
+			err = fmt.Errorf("nats server at %s closed the connection: %w", np.bus.Address, err)
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return err
+		case strings.HasPrefix(line, "-ERR"):
+			err = fmt.Errorf("nats server at %s refused the connection: %s", np.bus.Address, strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+			return err
+		}
+	}
+}
+
+// readLoop answers the server's PINGs and logs its errors until the connection closes.
+func (np *natsPublisher) readLoop(conn net.Conn, reader *bufio.Reader) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PING":
+			np.mu.Lock()
+			_ = writeEventBus(conn, []byte("PONG\r\n"))
+			np.mu.Unlock()
+		case strings.HasPrefix(line, "-ERR"):
+			np.logger.Warn("NATS server reported an error", zap.String("address", np.bus.Address), zap.String("error", strings.TrimSpace(strings.TrimPrefix(line, "-ERR"))))
+		}
+	}
+}
+
+// writeEventBus writes a message to a broker connection within the write timeout.
+func writeEventBus(conn net.Conn, message []byte) (err error) {
+	_ = conn.SetWriteDeadline(time.Now().Add(eventBusWriteTimeout))
+	_, err = conn.Write(message)
+	if err != nil {
+		err = fmt.Errorf("failed to write to %s: %w", conn.RemoteAddr(), err)
+		return err
+	}
+	return err
+}
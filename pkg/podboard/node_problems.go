@@ -0,0 +1,115 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// nodeProblemCacheTTL is how long node problems are reused for pod listings, which are polled every few seconds.
+const nodeProblemCacheTTL = 30 * time.Second
+
+// NodeProblemNotReady is the problem reported for a node whose Ready condition isn't True.
+const NodeProblemNotReady = "NotReady"
+
+// NodeProblem is a node condition signalling trouble: the node isn't ready, the kubelet reports pressure, or an
+// extension such as node-problem-detector set a condition like KernelDeadlock or FilesystemCorruption.
+type NodeProblem struct {
+	Type    string `json:"type"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+	Since   string `json:"since,omitempty"`
+}
+
+// nodeProblems returns the problems of a node. Every condition other than Ready signals a problem when it is
+// True, which covers the kubelet's pressure conditions as well as conditions added by node-problem-detector.
+func nodeProblems(node *corev1.Node, view timeView) (problems []NodeProblem) {
+	for _, condition := range node.Status.Conditions {
+		problemType := string(condition.Type)
+		if condition.Type == corev1.NodeReady {
+			if condition.Status == corev1.ConditionTrue {
+				continue
+			}
+			problemType = NodeProblemNotReady
+		} else if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+
+		problem := NodeProblem{Type: problemType, Reason: condition.Reason, Message: condition.Message}
+		if !condition.LastTransitionTime.IsZero() {
+			problem.Since = view.age(condition.LastTransitionTime.Time)
+		}
+		problems = append(problems, problem)
+	}
+	return problems
+}
+
+// NodeProblemCache remembers the problems of each cluster's nodes for pod listings, keyed like clients so a
+// user never sees nodes their credentials can't list. Clusters where nodes can't be listed, such as
+// namespace-restricted deployments, are remembered too, so pods are listed without node problems.
+type NodeProblemCache struct {
+	logger   *zap.Logger
+	problems *ttlCache[string, map[string][]string]
+}
+
+// NewNodeProblemCache creates a new node problem cache.
+func NewNodeProblemCache(logger *zap.Logger) (cache *NodeProblemCache) {
+	cache = &NodeProblemCache{
+		logger:   logger,
+		problems: newTTLCache[string, map[string][]string](nodeProblemCacheTTL, 0),
+	}
+	return cache
+}
+
+// Get returns the problem types of every node with problems in the cluster, keyed by node name.
+func (npc *NodeProblemCache) Get(ctx context.Context, client kubernetes.Interface, clusterName string) (problems map[string][]string) {
+	identity, _ := IdentityFromContext(ctx)
+	key := clientCacheKey(clusterName, KubeContextFromContext(ctx), identity)
+
+	problems, ok := npc.problems.Get(key)
+	if ok {
+		return problems
+	}
+
+	problems = make(map[string][]string)
+	// ResourceVersion 0 lets the API server answer from its watch cache.
+	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{ResourceVersion: "0"})
+	if err != nil {
+		npc.logger.Debug("Node problems unavailable", zap.Error(err), zap.String("cluster", clusterName))
+	} else {
+		for i := range nodes.Items {
+			for _, problem := range nodeProblems(&nodes.Items[i], timeViewFromContext(ctx)) {
+				problems[nodes.Items[i].Name] = append(problems[nodes.Items[i].Name], problem.Type)
+			}
+		}
+	}
+
+	npc.problems.Store(key, problems)
+	return problems
+}
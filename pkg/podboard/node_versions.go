@@ -0,0 +1,335 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/kubernetes"
+)
+
+// maxKubeletSkew is the number of minor versions kubelets may trail the control plane by.
+const maxKubeletSkew = 3
+
+// nodePoolLabels name the node pool or group a node belongs to on EKS, Karpenter, GKE and AKS, in order of
+// preference.
+//
+//nolint:gochecknoglobals // Read-only lookup table
+var nodePoolLabels = []string{
+	"eks.amazonaws.com/nodegroup",
+	"karpenter.sh/nodepool",
+	"cloud.google.com/gke-nodepool",
+	"kubernetes.azure.com/agentpool",
+	"agentpool",
+}
+
+// nodeImageLabels name the machine image a node was built from, such as the AMI of an EKS managed node group.
+//
+//nolint:gochecknoglobals // Read-only lookup table
+var nodeImageLabels = []string{
+	"eks.amazonaws.com/nodegroup-image",
+	"kubernetes.azure.com/node-image-version",
+}
+
+// NodeVersionReport is the software versions of nodes across clusters, for coordinating node pool upgrades.
+// Clusters that couldn't be queried are in ClusterErrors.
+type NodeVersionReport struct {
+	Clusters      []ClusterNodeVersions `json:"clusters"`
+	ClusterErrors map[string]string     `json:"clusterErrors,omitempty"`
+}
+
+// ClusterNodeVersions groups a cluster's nodes by pool and versions. Skewed is set when kubelets run more than
+// one minor version.
+type ClusterNodeVersions struct {
+	Cluster             string             `json:"cluster,omitempty"`
+	ControlPlaneVersion string             `json:"controlPlaneVersion,omitempty"`
+	KubeletVersions     []string           `json:"kubeletVersions"`
+	Skewed              bool               `json:"skewed"`
+	Nodes               int                `json:"nodes"`
+	OutdatedNodes       int                `json:"outdatedNodes"`
+	Groups              []NodeVersionGroup `json:"groups"`
+}
+
+// NodeVersionGroup is the nodes of a pool running the same versions. Outdated groups trail the control plane
+// or the other nodes of their pool, for the given reasons.
+type NodeVersionGroup struct {
+	Pool             string   `json:"pool,omitempty"`
+	KubeletVersion   string   `json:"kubeletVersion"`
+	OSImage          string   `json:"osImage"`
+	KernelVersion    string   `json:"kernelVersion"`
+	ContainerRuntime string   `json:"containerRuntime"`
+	Image            string   `json:"image,omitempty"`
+	Nodes            []string `json:"nodes"`
+	Outdated         bool     `json:"outdated"`
+	Reasons          []string `json:"reasons,omitempty"`
+	// newest is the creation time of the group's newest node, which carries the pool's current image.
+	newest metav1.Time
+}
+
+// GetNodeVersions reports the node versions of a cluster, or of every kubeconfig cluster for ClusterAll.
+func (ns *NodeService) GetNodeVersions(ctx context.Context, clusterName string) (report NodeVersionReport, err error) {
+	report = NodeVersionReport{Clusters: make([]ClusterNodeVersions, 0)}
+	if clusterName != ClusterAll {
+		var versions ClusterNodeVersions
+		versions, err = ns.clusterNodeVersions(ctx, clusterName)
+		if err != nil {
+			return report, err
+		}
+		report.Clusters = append(report.Clusters, versions)
+		return report, err
+	}
+
+	var names []string
+	names, err = ns.podService.kubeConfigClusterNames()
+	if err != nil {
+		return report, err
+	}
+
+	results := make([]ClusterNodeVersions, len(names))
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			clusterCtx, cancel := context.WithTimeout(ctx, clusterQueryTimeout)
+			defer cancel()
+			results[i], errs[i] = ns.clusterNodeVersions(clusterCtx, name)
+		}()
+	}
+	wg.Wait()
+
+	for i, name := range names {
+		if errs[i] != nil {
+			if report.ClusterErrors == nil {
+				report.ClusterErrors = make(map[string]string)
+			}
+			report.ClusterErrors[name] = errs[i].Error()
+			continue
+		}
+		report.Clusters = append(report.Clusters, results[i])
+	}
+	if len(report.Clusters) == 0 {
+		err = fmt.Errorf("failed to get node versions from any cluster: %w", errs[0])
+		return report, err
+	}
+	return report, err
+}
+
+// clusterNodeVersions lists a cluster's nodes and its control plane version and groups the nodes.
+func (ns *NodeService) clusterNodeVersions(ctx context.Context, clusterName string) (versions ClusterNodeVersions, err error) {
+	var client kubernetes.Interface
+	client, err = ns.kubeConfigService.GetClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return versions, err
+	}
+
+	var nodes *corev1.NodeList
+	nodes, err = client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		err = fmt.Errorf("failed to list nodes: %w", err)
+		return versions, err
+	}
+
+	// The control plane version only adds a comparison, so the report is still useful without it.
+	controlPlane := ""
+	serverVersion, versionErr := client.Discovery().ServerVersion()
+	if versionErr == nil {
+		controlPlane = serverVersion.GitVersion
+	}
+
+	versions = groupNodeVersions(nodes.Items, controlPlane)
+	versions.Cluster = clusterName
+	return versions, err
+}
+
+// groupNodeVersions groups nodes by pool and versions, and marks the groups trailing the control plane by a
+// minor version, or the other nodes of their pool: an older kubelet or kernel, or another OS or machine image
+// than the pool's newest node.
+func groupNodeVersions(nodes []corev1.Node, controlPlane string) (versions ClusterNodeVersions) {
+	versions = ClusterNodeVersions{ControlPlaneVersion: controlPlane, KubeletVersions: make([]string, 0), Groups: make([]NodeVersionGroup, 0), Nodes: len(nodes)}
+
+	index := make(map[string]int)
+	kubelets := make(map[string]bool)
+	for i := range nodes {
+		group := nodeVersionGroup(&nodes[i])
+		kubelets[group.KubeletVersion] = true
+		key := fmt.Sprintf("%s|%s|%s|%s|%s|%s", group.Pool, group.KubeletVersion, group.OSImage, group.KernelVersion, group.ContainerRuntime, group.Image)
+		j, exists := index[key]
+		if !exists {
+			j = len(versions.Groups)
+			index[key] = j
+			versions.Groups = append(versions.Groups, group)
+		} else {
+			versions.Groups[j].Nodes = append(versions.Groups[j].Nodes, nodes[i].Name)
+		}
+		if nodes[i].CreationTimestamp.After(versions.Groups[j].newest.Time) {
+			versions.Groups[j].newest = nodes[i].CreationTimestamp
+		}
+	}
+
+	for kubelet := range kubelets {
+		versions.KubeletVersions = append(versions.KubeletVersions, kubelet)
+	}
+	sort.Slice(versions.KubeletVersions, func(i, j int) (less bool) {
+		less = versionNewer(versions.KubeletVersions[i], versions.KubeletVersions[j])
+		return less
+	})
+	minors := make(map[uint]bool)
+	for _, kubelet := range versions.KubeletVersions {
+		if parsed, parseErr := version.ParseGeneric(kubelet); parseErr == nil {
+			minors[parsed.Minor()] = true
+		}
+	}
+	versions.Skewed = len(minors) > 1
+
+	for i := range versions.Groups {
+		group := &versions.Groups[i]
+		group.Reasons = append(group.Reasons, controlPlaneSkew(group.KubeletVersion, controlPlane)...)
+		group.Reasons = append(group.Reasons, poolStraggling(*group, versions.Groups)...)
+		group.Outdated = len(group.Reasons) > 0
+		sort.Strings(group.Nodes)
+		if group.Outdated {
+			versions.OutdatedNodes += len(group.Nodes)
+		}
+	}
+
+	sort.SliceStable(versions.Groups, func(i, j int) (less bool) {
+		a, b := versions.Groups[i], versions.Groups[j]
+		if a.Pool != b.Pool {
+			less = a.Pool < b.Pool
+			return less
+		}
+		less = versionNewer(a.KubeletVersion, b.KubeletVersion)
+		return less
+	})
+	return versions
+}
+
+// nodeVersionGroup returns the group of a single node.
+func nodeVersionGroup(node *corev1.Node) (group NodeVersionGroup) {
+	info := node.Status.NodeInfo
+	group = NodeVersionGroup{
+		Pool:             firstLabel(node.Labels, nodePoolLabels),
+		KubeletVersion:   info.KubeletVersion,
+		OSImage:          info.OSImage,
+		KernelVersion:    info.KernelVersion,
+		ContainerRuntime: info.ContainerRuntimeVersion,
+		Image:            firstLabel(node.Labels, nodeImageLabels),
+		Nodes:            []string{node.Name},
+	}
+	return group
+}
+
+// controlPlaneSkew explains how far a kubelet trails the control plane by minor version, if at all.
+func controlPlaneSkew(kubelet, controlPlane string) (reasons []string) {
+	kubeletVersion, kubeletErr := version.ParseGeneric(kubelet)
+	controlPlaneVersion, controlPlaneErr := version.ParseGeneric(controlPlane)
+	if kubeletErr != nil || controlPlaneErr != nil || kubeletVersion.Major() != controlPlaneVersion.Major() || kubeletVersion.Minor() >= controlPlaneVersion.Minor() {
+		return reasons
+	}
+
+	behind := controlPlaneVersion.Minor() - kubeletVersion.Minor()
+	minorVersions := "minor versions"
+	if behind == 1 {
+		minorVersions = "minor version"
+	}
+	reason := fmt.Sprintf("kubelet %s is %d %s behind the control plane %s", kubelet, behind, minorVersions, controlPlane)
+	if behind > maxKubeletSkew {
+		reason += fmt.Sprintf(", beyond the supported skew of %d", maxKubeletSkew)
+	}
+	reasons = append(reasons, reason)
+	return reasons
+}
+
+// poolStraggling explains how a group trails the other groups of its pool: an older kubelet or kernel than any
+// of them, or another OS or machine image than the pool's newest node.
+func poolStraggling(group NodeVersionGroup, groups []NodeVersionGroup) (reasons []string) {
+	newestKubelet, newestKernel := group.KubeletVersion, group.KernelVersion
+	newestNode := group
+	for _, other := range groups {
+		if other.Pool != group.Pool {
+			continue
+		}
+		if versionNewer(other.KubeletVersion, newestKubelet) {
+			newestKubelet = other.KubeletVersion
+		}
+		if versionNewer(other.KernelVersion, newestKernel) {
+			newestKernel = other.KernelVersion
+		}
+		if other.newest.After(newestNode.newest.Time) {
+			newestNode = other
+		}
+	}
+
+	pool := "unpooled nodes"
+	if group.Pool != "" {
+		pool = "pool " + group.Pool
+	}
+	if newestKubelet != group.KubeletVersion {
+		reasons = append(reasons, fmt.Sprintf("kubelet %s is older than %s elsewhere in %s", group.KubeletVersion, newestKubelet, pool))
+	}
+	if newestKernel != group.KernelVersion {
+		reasons = append(reasons, fmt.Sprintf("kernel %s is older than %s elsewhere in %s", group.KernelVersion, newestKernel, pool))
+	}
+	if newestNode.OSImage != group.OSImage {
+		reasons = append(reasons, fmt.Sprintf("OS image %q differs from %q on the newest node in %s", group.OSImage, newestNode.OSImage, pool))
+	}
+	if newestNode.Image != group.Image {
+		reasons = append(reasons, fmt.Sprintf("machine image %q differs from %q on the newest node in %s", group.Image, newestNode.Image, pool))
+	}
+	return reasons
+}
+
+// versionNewer reports whether version a is newer than b. Versions that don't parse are never newer.
+func versionNewer(a, b string) (newer bool) {
+	parsedA, errA := version.ParseGeneric(a)
+	parsedB, errB := version.ParseGeneric(b)
+	if errA != nil {
+		return newer
+	}
+	if errB != nil {
+		newer = true
+		return newer
+	}
+	newer = parsedB.LessThan(parsedA)
+	return newer
+}
+
+// firstLabel returns the value of the first of the labels a node has.
+func firstLabel(labels map[string]string, names []string) (value string) {
+	for _, name := range names {
+		if labels[name] != "" {
+			value = labels[name]
+			return value
+		}
+	}
+	return value
+}
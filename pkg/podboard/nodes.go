@@ -0,0 +1,366 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// KindNode is the kind reported for node changes.
+const KindNode = "Node"
+
+// CordonInfo describes the result of a cordon or uncordon.
+type CordonInfo struct {
+	Node                  string `json:"node"`
+	PreviousUnschedulable bool   `json:"previousUnschedulable"`
+	Unschedulable         bool   `json:"unschedulable"`
+}
+
+// nodeRoleLabelPrefix prefixes the labels naming a node's roles, e.g. node-role.kubernetes.io/control-plane.
+const nodeRoleLabelPrefix = "node-role.kubernetes.io/"
+
+// NodeInfo represents node information for the dashboard.
+type NodeInfo struct {
+	Name             string            `json:"name"`
+	Status           string            `json:"status"`
+	Unschedulable    bool              `json:"unschedulable"`
+	Roles            []string          `json:"roles,omitempty"`
+	KubeletVersion   string            `json:"kubeletVersion"`
+	ContainerRuntime string            `json:"containerRuntime,omitempty"`
+	OSImage          string            `json:"osImage,omitempty"`
+	KernelVersion    string            `json:"kernelVersion,omitempty"`
+	Pool             string            `json:"pool,omitempty"`
+	InternalIP       string            `json:"internalIP,omitempty"`
+	CreatedAt        string            `json:"createdAt"`
+	Age              string            `json:"age"`
+	AgeSeconds       int64             `json:"ageSeconds"`
+	Capacity         map[string]string `json:"capacity,omitempty"`
+	Allocatable      map[string]string `json:"allocatable,omitempty"`
+	Taints           []NodeTaint       `json:"taints,omitempty"`
+	Conditions       []NodeCondition   `json:"conditions"`
+	Problems         []NodeProblem     `json:"problems,omitempty"`
+}
+
+// NodeTaint is a taint repelling pods that don't tolerate it.
+type NodeTaint struct {
+	Key    string `json:"key"`
+	Value  string `json:"value,omitempty"`
+	Effect string `json:"effect"`
+}
+
+// NodeCondition is a node condition as shown in the node view.
+type NodeCondition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	Reason             string `json:"reason,omitempty"`
+	Message            string `json:"message,omitempty"`
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+}
+
+// NodeDetail is a node with its recent events (including those recorded by node-problem-detector, such as
+// KernelOops or TaskHung) and the pods running on it.
+type NodeDetail struct {
+	NodeInfo
+	Events []EventInfo `json:"events"`
+	Pods   []PodInfo   `json:"pods"`
+}
+
+// NodeService handles node operations.
+type NodeService struct {
+	podService        *PodService
+	kubeConfigService ClientFactory
+	logger            *zap.Logger
+}
+
+// NewNodeService creates a new node service.
+func NewNodeService(podService *PodService, kubeConfigService ClientFactory, logger *zap.Logger) (service *NodeService) {
+	service = &NodeService{
+		podService:        podService,
+		kubeConfigService: kubeConfigService,
+		logger:            logger,
+	}
+	return service
+}
+
+// GetNodes retrieves the cluster's nodes, sorted by name.
+func (ns *NodeService) GetNodes(ctx context.Context, clusterName string) (nodeInfos []NodeInfo, err error) {
+	var client kubernetes.Interface
+	client, err = ns.kubeConfigService.GetClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return nodeInfos, err
+	}
+
+	var nodes *corev1.NodeList
+	nodes, err = client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		ns.logger.Error("Failed to list nodes", zap.Error(err), zap.String("cluster", clusterName))
+		err = fmt.Errorf("failed to list nodes: %w", err)
+		return nodeInfos, err
+	}
+
+	nodeInfos = make([]NodeInfo, 0, len(nodes.Items))
+	for i := range nodes.Items {
+		nodeInfos = append(nodeInfos, nodeToNodeInfo(&nodes.Items[i], timeViewFromContext(ctx)))
+	}
+
+	sort.Slice(nodeInfos, func(i, j int) (less bool) {
+		less = nodeInfos[i].Name < nodeInfos[j].Name
+		return less
+	})
+
+	return nodeInfos, err
+}
+
+// GetNode retrieves a node with its conditions, events and pods, so a node-level root cause can be
+// correlated with the pods it affects.
+func (ns *NodeService) GetNode(ctx context.Context, clusterName, nodeName string) (detail NodeDetail, err error) {
+	var client kubernetes.Interface
+	client, err = ns.kubeConfigService.GetClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return detail, err
+	}
+
+	var node *corev1.Node
+	node, err = client.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		err = fmt.Errorf("failed to get node %s: %w", nodeName, err)
+		return detail, err
+	}
+
+	view := timeViewFromContext(ctx)
+	detail = NodeDetail{NodeInfo: nodeToNodeInfo(node, view)}
+
+	// Node events are recorded in the default namespace by the kubelet but not necessarily by other
+	// reporters, so they are looked up in every namespace.
+	eventSelector := fields.Set{"involvedObject.kind": KindNode, "involvedObject.name": nodeName}.AsSelector().String()
+	var eventList *corev1.EventList
+	eventList, err = client.CoreV1().Events("").List(ctx, metav1.ListOptions{FieldSelector: eventSelector})
+	if err != nil {
+		err = fmt.Errorf("failed to list events for node %s: %w", nodeName, err)
+		return detail, err
+	}
+	detail.Events = eventsToEventInfos(eventList.Items, view)
+
+	detail.Pods, err = ns.nodePods(ctx, client, nodeName, detail.Problems)
+	return detail, err
+}
+
+// GetNodePods retrieves the pods running on a node, sorted by namespace and name, with the node's problems.
+func (ns *NodeService) GetNodePods(ctx context.Context, clusterName, nodeName string) (podInfos []PodInfo, err error) {
+	var client kubernetes.Interface
+	client, err = ns.kubeConfigService.GetClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return podInfos, err
+	}
+
+	var node *corev1.Node
+	node, err = client.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		err = fmt.Errorf("failed to get node %s: %w", nodeName, err)
+		return podInfos, err
+	}
+
+	podInfos, err = ns.nodePods(ctx, client, nodeName, nodeProblems(node, timeViewFromContext(ctx)))
+	return podInfos, err
+}
+
+// nodePods lists the pods on a node in every namespace, marking them with the node's problems.
+func (ns *NodeService) nodePods(ctx context.Context, client kubernetes.Interface, nodeName string, problems []NodeProblem) (podInfos []PodInfo, err error) {
+	podSelector := fields.OneTermEqualSelector("spec.nodeName", nodeName).String()
+	var pods *corev1.PodList
+	pods, err = client.CoreV1().Pods("").List(ctx, metav1.ListOptions{FieldSelector: podSelector})
+	if err != nil {
+		err = fmt.Errorf("failed to list pods on node %s: %w", nodeName, err)
+		return podInfos, err
+	}
+
+	var problemTypes []string
+	for _, problem := range problems {
+		problemTypes = append(problemTypes, problem.Type)
+	}
+
+	view := timeViewFromContext(ctx)
+	access := AccessFromContext(ctx)
+	podInfos = make([]PodInfo, 0, len(pods.Items))
+	for i := range pods.Items {
+		if !ns.podService.namespacePolicy.Allows(pods.Items[i].Namespace) || !access.AllowsNamespace(pods.Items[i].Namespace) {
+			continue
+		}
+		podInfo := ns.podService.podToPodInfo(&pods.Items[i], view)
+		podInfo.NodeProblems = problemTypes
+		podInfo.DerivedStatuses = ns.podService.derivedStatuses.ForPod(podInfo)
+		podInfos = append(podInfos, podInfo)
+	}
+	sort.Slice(podInfos, func(i, j int) (less bool) {
+		if podInfos[i].Namespace != podInfos[j].Namespace {
+			less = podInfos[i].Namespace < podInfos[j].Namespace
+			return less
+		}
+		less = podInfos[i].Name < podInfos[j].Name
+		return less
+	})
+
+	return podInfos, err
+}
+
+// nodeToNodeInfo summarizes a node.
+func nodeToNodeInfo(node *corev1.Node, view timeView) (info NodeInfo) {
+	info = NodeInfo{
+		Name:             node.Name,
+		Status:           "Unknown",
+		Unschedulable:    node.Spec.Unschedulable,
+		KubeletVersion:   node.Status.NodeInfo.KubeletVersion,
+		ContainerRuntime: node.Status.NodeInfo.ContainerRuntimeVersion,
+		OSImage:          node.Status.NodeInfo.OSImage,
+		KernelVersion:    node.Status.NodeInfo.KernelVersion,
+		Pool:             firstLabel(node.Labels, nodePoolLabels),
+		CreatedAt:        view.format(node.CreationTimestamp.Time),
+		Age:              view.age(node.CreationTimestamp.Time),
+		AgeSeconds:       view.ageSeconds(node.CreationTimestamp.Time),
+		Capacity:         resourceListStrings(node.Status.Capacity),
+		Allocatable:      resourceListStrings(node.Status.Allocatable),
+		Conditions:       make([]NodeCondition, 0, len(node.Status.Conditions)),
+		Problems:         nodeProblems(node, view),
+	}
+
+	for _, condition := range node.Status.Conditions {
+		info.Conditions = append(info.Conditions, NodeCondition{
+			Type:               string(condition.Type),
+			Status:             string(condition.Status),
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+			LastTransitionTime: view.format(condition.LastTransitionTime.Time),
+		})
+		if condition.Type != corev1.NodeReady {
+			continue
+		}
+		info.Status = "NotReady"
+		if condition.Status == corev1.ConditionTrue {
+			info.Status = "Ready"
+		}
+	}
+
+	for _, taint := range node.Spec.Taints {
+		info.Taints = append(info.Taints, NodeTaint{Key: taint.Key, Value: taint.Value, Effect: string(taint.Effect)})
+	}
+
+	for _, address := range node.Status.Addresses {
+		if address.Type == corev1.NodeInternalIP && info.InternalIP == "" {
+			info.InternalIP = address.Address
+		}
+	}
+
+	for label := range node.Labels {
+		if role, found := strings.CutPrefix(label, nodeRoleLabelPrefix); found && role != "" {
+			info.Roles = append(info.Roles, role)
+		}
+	}
+	sort.Strings(info.Roles)
+
+	return info
+}
+
+// SetUnschedulable cordons or uncordons a node.
+func (ns *NodeService) SetUnschedulable(ctx context.Context, clusterName, nodeName string, unschedulable bool) (info CordonInfo, err error) {
+	var client kubernetes.Interface
+	client, err = ns.kubeConfigService.GetClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return info, err
+	}
+
+	var node *corev1.Node
+	node, err = client.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		err = fmt.Errorf("failed to get node %s: %w", nodeName, err)
+		return info, err
+	}
+
+	info = CordonInfo{Node: nodeName, PreviousUnschedulable: node.Spec.Unschedulable, Unschedulable: unschedulable}
+
+	err = setUnschedulable(ctx, client, nodeName, unschedulable)
+	if err != nil {
+		return info, err
+	}
+
+	ns.logger.Info("Node schedulability changed", zap.String("cluster", clusterName), zap.String("node", nodeName), zap.Bool("unschedulable", unschedulable), zap.String("user", identityUser(ctx)), zap.String("requestId", requestID(ctx)))
+	return info, err
+}
+
+// cordonReverter restores a node's schedulability, unless it was changed again since.
+func (ns *NodeService) cordonReverter(clusterName string, info CordonInfo) (fn revertFunc) {
+	fn = func(ctx context.Context) (state, message string) {
+		client, err := ns.kubeConfigService.GetClient(ctx, clusterName)
+		if err != nil {
+			state = RevertFailed
+			message = err.Error()
+			return state, message
+		}
+
+		node, err := client.CoreV1().Nodes().Get(ctx, info.Node, metav1.GetOptions{})
+		if err != nil {
+			state = RevertFailed
+			message = err.Error()
+			return state, message
+		}
+		if node.Spec.Unschedulable != info.Unschedulable {
+			state = RevertSkipped
+			message = "node schedulability was changed after the temporary change"
+			return state, message
+		}
+
+		err = setUnschedulable(ctx, client, info.Node, info.PreviousUnschedulable)
+		if err != nil {
+			state = RevertFailed
+			message = err.Error()
+			return state, message
+		}
+		state = RevertDone
+		return state, message
+	}
+	return fn
+}
+
+// setUnschedulable patches a node's unschedulable flag, which is what kubectl cordon and uncordon do.
+func setUnschedulable(ctx context.Context, client kubernetes.Interface, nodeName string, unschedulable bool) (err error) {
+	patch := []byte(fmt.Sprintf(`{"spec":{"unschedulable":%t}}`, unschedulable))
+	_, err = client.CoreV1().Nodes().Patch(ctx, nodeName, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		err = fmt.Errorf("failed to set node %s unschedulable=%t: %w", nodeName, unschedulable, err)
+		return err
+	}
+	return err
+}
@@ -0,0 +1,397 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// Names of the objects that onboard a namespace.
+const (
+	onboardingRoleName          = "podboard-namespace"
+	onboardingNetworkPolicyName = "podboard-metrics"
+	onboardingConfigMapName     = "podboard-board"
+	// OnboardingBoardKey is the ConfigMap key holding the namespace's status page entries.
+	OnboardingBoardKey = "board.yaml"
+	// OnboardingTeamLabel records the team a namespace was onboarded for.
+	OnboardingTeamLabel = "podboard.io/team"
+)
+
+// Onboarding apply actions.
+const (
+	OnboardingCreated = "created"
+	OnboardingUpdated = "updated"
+)
+
+// ErrInvalidOnboarding is returned for onboarding requests with invalid parameters.
+var ErrInvalidOnboarding = errors.New("invalid onboarding request")
+
+// OnboardingRequest describes a team namespace to onboard into podboard.
+type OnboardingRequest struct {
+	Namespace string
+	// Team labels the generated objects.
+	Team string
+	// ServiceAccount and ServiceAccountNamespace identify the service account podboard runs as.
+	ServiceAccount          string
+	ServiceAccountNamespace string
+	// APIServerCIDRs are the addresses the API server reaches pods from. When set, a NetworkPolicy lets it scrape
+	// the podboard.io/metric endpoints of pods in namespaces that deny ingress by default.
+	APIServerCIDRs []string
+	// MetricPorts limits that NetworkPolicy to these ports. Empty allows every port.
+	MetricPorts []int32
+}
+
+// OnboardingObject is an object applied to onboard a namespace, and whether it was created or updated.
+type OnboardingObject struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Action string `json:"action"`
+}
+
+// OnboardingService generates, and optionally applies, the namespace-scoped objects that onboard a team
+// namespace into podboard: a Role and RoleBinding granting podboard's service account what it uses in the
+// namespace, a NetworkPolicy for metric scrapes, and a ConfigMap with the namespace's status page entries.
+type OnboardingService struct {
+	kubeConfigService ClientFactory
+	logger            *zap.Logger
+}
+
+// NewOnboardingService creates a new onboarding service.
+func NewOnboardingService(kubeConfigService ClientFactory, logger *zap.Logger) (service *OnboardingService) {
+	service = &OnboardingService{kubeConfigService: kubeConfigService, logger: logger}
+	return service
+}
+
+// onboardingObjects are the objects that onboard a namespace. NetworkPolicy is nil without API server CIDRs.
+type onboardingObjects struct {
+	role          *rbacv1.Role
+	roleBinding   *rbacv1.RoleBinding
+	networkPolicy *networkingv1.NetworkPolicy
+	configMap     *corev1.ConfigMap
+}
+
+// Validate checks the request, filling in the podboard service account defaults of the k8s/ manifests.
+func (request *OnboardingRequest) Validate() (err error) {
+	if request.ServiceAccount == "" {
+		request.ServiceAccount = "podboard"
+	}
+	if request.ServiceAccountNamespace == "" {
+		request.ServiceAccountNamespace = "default"
+	}
+
+	for _, name := range []string{request.Namespace, request.ServiceAccountNamespace} {
+		if problems := validation.IsDNS1123Label(name); len(problems) > 0 {
+			err = fmt.Errorf("%w: namespace %q: %s", ErrInvalidOnboarding, name, problems[0])
+			return err
+		}
+	}
+	if request.Team != "" {
+		if problems := validation.IsValidLabelValue(request.Team); len(problems) > 0 {
+			err = fmt.Errorf("%w: team %q: %s", ErrInvalidOnboarding, request.Team, problems[0])
+			return err
+		}
+	}
+	for _, cidr := range request.APIServerCIDRs {
+		_, _, parseErr := net.ParseCIDR(cidr)
+		if parseErr != nil {
+			err = fmt.Errorf("%w: apiServerCIDR %q is not a CIDR", ErrInvalidOnboarding, cidr)
+			return err
+		}
+	}
+	for _, port := range request.MetricPorts {
+		if port < 1 || port > 65535 {
+			err = fmt.Errorf("%w: metricPort %d is out of range", ErrInvalidOnboarding, port)
+			return err
+		}
+	}
+	return err
+}
+
+// Generate renders the onboarding objects as a multi-document YAML manifest or, for json, a v1 List.
+func (obs *OnboardingService) Generate(ctx context.Context, clusterName string, request OnboardingRequest, format string) (manifest []byte, err error) {
+	if format != ManifestFormatYAML && format != ManifestFormatJSON {
+		err = fmt.Errorf("%w: %q", ErrInvalidManifestFormat, format)
+		return manifest, err
+	}
+
+	var objects onboardingObjects
+	objects, err = obs.build(ctx, clusterName, request)
+	if err != nil {
+		return manifest, err
+	}
+
+	items := []interface{}{objects.role, objects.roleBinding}
+	if objects.networkPolicy != nil {
+		items = append(items, objects.networkPolicy)
+	}
+	items = append(items, objects.configMap)
+
+	if format == ManifestFormatJSON {
+		manifest, err = json.MarshalIndent(map[string]interface{}{"apiVersion": "v1", "kind": "List", "items": items}, "", "  ")
+		if err != nil {
+			err = fmt.Errorf("failed to encode onboarding manifest: %w", err)
+		}
+		return manifest, err
+	}
+
+	for i, item := range items {
+		var document []byte
+		document, err = yaml.Marshal(item)
+		if err != nil {
+			err = fmt.Errorf("failed to encode onboarding manifest: %w", err)
+			return manifest, err
+		}
+		if i > 0 {
+			manifest = append(manifest, "---\n"...)
+		}
+		manifest = append(manifest, document...)
+	}
+	return manifest, err
+}
+
+// Apply creates the onboarding objects, or updates them if they already exist. The namespace must exist, and
+// podboard needs to hold the permissions it grants, or be allowed to escalate and bind, to create the Role.
+func (obs *OnboardingService) Apply(ctx context.Context, clusterName string, request OnboardingRequest) (applied []OnboardingObject, err error) {
+	var objects onboardingObjects
+	objects, err = obs.build(ctx, clusterName, request)
+	if err != nil {
+		return applied, err
+	}
+
+	var client kubernetes.Interface
+	client, err = obs.kubeConfigService.GetClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return applied, err
+	}
+
+	namespace := request.Namespace
+	type step struct {
+		kind, name string
+		apply      func() (created bool, err error)
+	}
+	steps := []step{
+		{"Role", objects.role.Name, func() (created bool, err error) {
+			created, err = applyObject(ctx, client.RbacV1().Roles(namespace), objects.role)
+			return created, err
+		}},
+		{"RoleBinding", objects.roleBinding.Name, func() (created bool, err error) {
+			created, err = applyObject(ctx, client.RbacV1().RoleBindings(namespace), objects.roleBinding)
+			return created, err
+		}},
+	}
+	if objects.networkPolicy != nil {
+		steps = append(steps, step{"NetworkPolicy", objects.networkPolicy.Name, func() (created bool, err error) {
+			created, err = applyObject(ctx, client.NetworkingV1().NetworkPolicies(namespace), objects.networkPolicy)
+			return created, err
+		}})
+	}
+	steps = append(steps, step{"ConfigMap", objects.configMap.Name, func() (created bool, err error) {
+		created, err = applyObject(ctx, client.CoreV1().ConfigMaps(namespace), objects.configMap)
+		return created, err
+	}})
+
+	for _, step := range steps {
+		created, applyErr := step.apply()
+		if applyErr != nil {
+			err = fmt.Errorf("failed to apply %s %s/%s: %w", step.kind, namespace, step.name, applyErr)
+			return applied, err
+		}
+		action := OnboardingUpdated
+		if created {
+			action = OnboardingCreated
+		}
+		applied = append(applied, OnboardingObject{Kind: step.kind, Name: step.name, Action: action})
+	}
+
+	obs.logger.Info("Onboarded namespace", zap.String("cluster", clusterName), zap.String("namespace", namespace), zap.String("team", request.Team), zap.String("user", identityUser(ctx)), zap.String("requestId", requestID(ctx)), zap.Int("objects", len(applied)))
+	return applied, err
+}
+
+// objectClient is the part of a typed client applyObject uses.
+type objectClient[T metav1.Object] interface {
+	Get(ctx context.Context, name string, options metav1.GetOptions) (object T, err error)
+	Create(ctx context.Context, object T, options metav1.CreateOptions) (created T, err error)
+	Update(ctx context.Context, object T, options metav1.UpdateOptions) (updated T, err error)
+}
+
+// applyObject creates an object, or replaces the existing one with it.
+func applyObject[T metav1.Object](ctx context.Context, client objectClient[T], object T) (created bool, err error) {
+	var existing T
+	existing, err = client.Get(ctx, object.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.Create(ctx, object, metav1.CreateOptions{})
+		created = err == nil
+		return created, err
+	}
+	if err != nil {
+		return created, err
+	}
+
+	object.SetResourceVersion(existing.GetResourceVersion())
+	_, err = client.Update(ctx, object, metav1.UpdateOptions{})
+	return created, err
+}
+
+// build validates the request and creates the onboarding objects. The ConfigMap lists the namespace's current
+// Deployments, so the namespace is read from the cluster.
+func (obs *OnboardingService) build(ctx context.Context, clusterName string, request OnboardingRequest) (objects onboardingObjects, err error) {
+	err = request.Validate()
+	if err != nil {
+		return objects, err
+	}
+
+	var client kubernetes.Interface
+	client, err = obs.kubeConfigService.GetClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return objects, err
+	}
+
+	var workloads []StatusWorkload
+	workloads, err = onboardingWorkloads(ctx, client, clusterName, request.Namespace)
+	if err != nil {
+		return objects, err
+	}
+
+	meta := func(name string) (objectMeta metav1.ObjectMeta) {
+		objectMeta = metav1.ObjectMeta{Name: name, Namespace: request.Namespace, Labels: map[string]string{"app.kubernetes.io/managed-by": "podboard"}}
+		if request.Team != "" {
+			objectMeta.Labels[OnboardingTeamLabel] = request.Team
+		}
+		return objectMeta
+	}
+
+	objects.role = &rbacv1.Role{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role"},
+		ObjectMeta: meta(onboardingRoleName),
+		Rules:      onboardingRules(),
+	}
+	objects.roleBinding = &rbacv1.RoleBinding{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"},
+		ObjectMeta: meta(onboardingRoleName),
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: request.ServiceAccount, Namespace: request.ServiceAccountNamespace}},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: onboardingRoleName},
+	}
+	if len(request.APIServerCIDRs) > 0 {
+		objects.networkPolicy = onboardingNetworkPolicy(meta(onboardingNetworkPolicyName), request)
+	}
+
+	// The board is the status section of a config file, ready to merge into podboard's --config.
+	var board []byte
+	board, err = yaml.Marshal(map[string]interface{}{"status": map[string]interface{}{"workloads": workloads}})
+	if err != nil {
+		err = fmt.Errorf("failed to encode status page entries: %w", err)
+		return objects, err
+	}
+	objects.configMap = &corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: meta(onboardingConfigMapName),
+		Data:       map[string]string{OnboardingBoardKey: string(board)},
+	}
+	return objects, err
+}
+
+// onboardingWorkloads returns a status page entry for each Deployment in the namespace, selecting its pods
+// with its selector's match labels.
+func onboardingWorkloads(ctx context.Context, client kubernetes.Interface, clusterName, namespace string) (workloads []StatusWorkload, err error) {
+	deployments, err := client.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		err = fmt.Errorf("failed to list deployments in %s: %w", namespace, err)
+		return workloads, err
+	}
+
+	workloads = []StatusWorkload{}
+	for _, deployment := range deployments.Items {
+		if deployment.Spec.Selector == nil || len(deployment.Spec.Selector.MatchLabels) == 0 {
+			continue
+		}
+		selector := metav1.FormatLabelSelector(&metav1.LabelSelector{MatchLabels: deployment.Spec.Selector.MatchLabels})
+		workloads = append(workloads, StatusWorkload{Name: deployment.Name, Cluster: clusterName, Namespace: namespace, LabelSelector: selector})
+	}
+	sort.Slice(workloads, func(i, j int) (less bool) {
+		less = workloads[i].Name < workloads[j].Name
+		return less
+	})
+	return workloads, err
+}
+
+// onboardingRules are the namespaced permissions podboard uses, as in k8s/rbac-namespace-restricted.yaml,
+// with the read access that file grants cluster-wide.
+func onboardingRules() (rules []rbacv1.PolicyRule) {
+	rules = []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list", "watch", "delete", "patch"}},
+		{APIGroups: []string{""}, Resources: []string{"pods/log", "pods/proxy"}, Verbs: []string{"get"}},
+		{APIGroups: []string{""}, Resources: []string{"pods/eviction"}, Verbs: []string{"create"}},
+		{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"get", "list", "watch"}},
+		{APIGroups: []string{"policy"}, Resources: []string{"poddisruptionbudgets"}, Verbs: []string{"list"}},
+		{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get", "list", "watch", "patch"}},
+		{APIGroups: []string{"apps"}, Resources: []string{"deployments/scale", "statefulsets/scale"}, Verbs: []string{"get", "update"}},
+		{APIGroups: []string{"apps"}, Resources: []string{"statefulsets", "daemonsets"}, Verbs: []string{"get"}},
+		{APIGroups: []string{"batch"}, Resources: []string{"jobs"}, Verbs: []string{"list"}},
+		{APIGroups: []string{"autoscaling"}, Resources: []string{"horizontalpodautoscalers"}, Verbs: []string{"get", "list", "update"}},
+		{APIGroups: []string{"metrics.k8s.io"}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}},
+	}
+	return rules
+}
+
+// onboardingNetworkPolicy lets the API server reach the namespace's pods, on the metric ports if given, so
+// podboard can scrape podboard.io/metric endpoints through the API server proxy.
+func onboardingNetworkPolicy(objectMeta metav1.ObjectMeta, request OnboardingRequest) (policy *networkingv1.NetworkPolicy) {
+	rule := networkingv1.NetworkPolicyIngressRule{}
+	for _, cidr := range request.APIServerCIDRs {
+		rule.From = append(rule.From, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+	}
+	for _, port := range request.MetricPorts {
+		protocol := corev1.ProtocolTCP
+		portValue := intstr.FromInt32(port)
+		rule.Ports = append(rule.Ports, networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &portValue})
+	}
+
+	policy = &networkingv1.NetworkPolicy{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+		ObjectMeta: objectMeta,
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress:     []networkingv1.NetworkPolicyIngressRule{rule},
+		},
+	}
+	return policy
+}
@@ -0,0 +1,188 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Owner kinds resolved for pods. KindDeployment and KindStatefulSet are defined with the workload operations.
+const (
+	KindReplicaSet = "ReplicaSet"
+	KindDaemonSet  = "DaemonSet"
+	KindJob        = "Job"
+	KindCronJob    = "CronJob"
+)
+
+// GroupByOwner groups pod listings by the workload that owns them.
+const GroupByOwner = "owner"
+
+// PodGroup is the pods of one workload, summarized so many replicas can be shown as one row.
+type PodGroup struct {
+	Cluster   string `json:"cluster,omitempty"`
+	Namespace string `json:"namespace"`
+	OwnerKind string `json:"ownerKind"`
+	OwnerName string `json:"ownerName"`
+	Total     int    `json:"total"`
+	Ready     int    `json:"ready"`
+	Restarts  int32  `json:"restarts"`
+	// SidecarRestarts counts native sidecar restarts apart from the app containers' Restarts.
+	SidecarRestarts int32          `json:"sidecarRestarts,omitempty"`
+	Statuses        map[string]int `json:"statuses"`
+	Pods            []PodInfo      `json:"pods"`
+}
+
+// podOwner returns the workload controlling a pod. A ReplicaSet created by a Deployment is named after the
+// Deployment plus the pod-template-hash label the pods carry, so it resolves to the Deployment without a lookup.
+// Pods without a controller are their own owner and return empty strings.
+func podOwner(pod *corev1.Pod) (kind, name string) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return kind, name
+	}
+	kind, name = owner.Kind, owner.Name
+
+	if kind == KindReplicaSet {
+		if hash := pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey]; hash != "" {
+			if deployment, found := strings.CutSuffix(name, "-"+hash); found {
+				kind, name = KindDeployment, deployment
+			}
+		}
+	}
+
+	return kind, name
+}
+
+// cronJobOwnerCacheTTL is how long the Jobs created by CronJobs are reused, so polling pod listings don't list
+// Jobs every time.
+const cronJobOwnerCacheTTL = 15 * time.Second
+
+// resolveCronJobOwners replaces Job owners with the CronJob that created the Job, if any. Jobs are only listed
+// when there are Job pods, and a failed listing leaves the Job owners in place.
+func (ps *PodService) resolveCronJobOwners(ctx context.Context, client kubernetes.Interface, clusterName, namespace string, podInfos []PodInfo) {
+	hasJobPods := false
+	for _, podInfo := range podInfos {
+		if podInfo.OwnerKind == KindJob {
+			hasJobPods = true
+			break
+		}
+	}
+	if !hasJobPods {
+		return
+	}
+
+	cronJobs := ps.cronJobOwners(ctx, client, clusterName, namespace)
+	for i := range podInfos {
+		if podInfos[i].OwnerKind != KindJob {
+			continue
+		}
+		if cronJob, exists := cronJobs[podInfos[i].Namespace+"/"+podInfos[i].OwnerName]; exists {
+			podInfos[i].OwnerKind, podInfos[i].OwnerName = KindCronJob, cronJob
+		}
+	}
+}
+
+// cronJobOwners returns the CronJob that created each Job in the namespace, keyed by namespace/name of the Job.
+// The Jobs are cached per cluster, kube context, identity and namespace for cronJobOwnerCacheTTL.
+func (ps *PodService) cronJobOwners(ctx context.Context, client kubernetes.Interface, clusterName, namespace string) (cronJobs map[string]string) {
+	identity, _ := IdentityFromContext(ctx)
+	key := clientCacheKey(clusterName, KubeContextFromContext(ctx), identity) + "|" + namespace
+	cronJobs, ok := ps.cronJobCache.Get(key)
+	if ok {
+		return cronJobs
+	}
+
+	cronJobs = make(map[string]string)
+
+	jobs, err := client.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{ResourceVersion: "0"})
+	if err != nil {
+		ps.logger.Debug("Failed to list jobs to resolve cron job owners", zap.Error(err), zap.String("cluster", clusterName), zap.String("namespace", namespace))
+		// Without permission the next poll would fail the same way, so the empty owners are cached too.
+		ps.cronJobCache.Store(key, cronJobs)
+		return cronJobs
+	}
+
+	for i := range jobs.Items {
+		if owner := metav1.GetControllerOf(&jobs.Items[i]); owner != nil && owner.Kind == KindCronJob {
+			cronJobs[jobs.Items[i].Namespace+"/"+jobs.Items[i].Name] = owner.Name
+		}
+	}
+	ps.cronJobCache.Store(key, cronJobs)
+	return cronJobs
+}
+
+// GroupPodsByOwner groups pods by cluster, namespace and owning workload, in the order of their first pod.
+// Pods without an owner form groups of their own, with the Pod kind.
+func GroupPodsByOwner(pods []PodInfo) (groups []PodGroup) {
+	groups = make([]PodGroup, 0)
+	index := make(map[string]int)
+
+	for _, pod := range pods {
+		kind, name := pod.OwnerKind, pod.OwnerName
+		if kind == "" {
+			kind, name = KindPod, pod.Name
+		}
+
+		key := pod.Cluster + "/" + pod.Namespace + "/" + kind + "/" + name
+		i, exists := index[key]
+		if !exists {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, PodGroup{
+				Cluster:   pod.Cluster,
+				Namespace: pod.Namespace,
+				OwnerKind: kind,
+				OwnerName: name,
+				Statuses:  make(map[string]int),
+			})
+		}
+
+		group := &groups[i]
+		group.Total++
+		if podIsReady(pod) {
+			group.Ready++
+		}
+		group.Restarts += pod.Restarts
+		group.SidecarRestarts += pod.SidecarRestarts
+		group.Statuses[pod.Status]++
+		group.Pods = append(group.Pods, pod)
+	}
+
+	for i := range groups {
+		sort.SliceStable(groups[i].Pods, func(a, b int) (less bool) {
+			less = groups[i].Pods[a].Name < groups[i].Pods[b].Name
+			return less
+		})
+	}
+
+	return groups
+}
@@ -0,0 +1,288 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Pending action states.
+const (
+	PendingActionPending   = "Pending"
+	PendingActionRunning   = "Running"
+	PendingActionDone      = "Done"
+	PendingActionFailed    = "Failed"
+	PendingActionCancelled = "Cancelled"
+)
+
+// PendingActionDelete is the action of a queued pod deletion.
+const PendingActionDelete = "delete"
+
+const (
+	pendingActionCheckInterval = time.Second
+	maxUndoWindow              = 10 * time.Minute
+	maxRetainedPendingActions  = 200
+)
+
+var (
+	// ErrPendingActionNotFound is returned when a pending action ID is unknown.
+	ErrPendingActionNotFound = errors.New("pending action not found")
+	// ErrInvalidUndoWindow is returned when an undoWindow value can't be used.
+	ErrInvalidUndoWindow = errors.New("invalid undoWindow")
+)
+
+// PendingAction is a destructive action held back for an undo window, during which it can be cancelled.
+type PendingAction struct {
+	ID         string     `json:"id"`
+	Cluster    string     `json:"cluster,omitempty"`
+	Kind       string     `json:"kind"`
+	Namespace  string     `json:"namespace,omitempty"`
+	Name       string     `json:"name"`
+	Action     string     `json:"action"`
+	User       string     `json:"user,omitempty"`
+	RequestID  string     `json:"requestId,omitempty"`
+	State      string     `json:"state"`
+	Message    string     `json:"message,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	RunAt      time.Time  `json:"runAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// pendingActionFunc performs a pending action once its undo window has passed.
+type pendingActionFunc func(ctx context.Context) (err error)
+
+// queuedAction is a pending action with the context and function used to perform it.
+type queuedAction struct {
+	action PendingAction
+	ctx    context.Context
+	fn     pendingActionFunc
+}
+
+// PendingActionService performs queued actions once their undo window has passed, unless they are cancelled
+// first. Queued actions are kept in memory, so actions still pending when podboard restarts are dropped,
+// which errs on the side of not deleting.
+type PendingActionService struct {
+	logger *zap.Logger
+
+	mu      sync.Mutex
+	actions map[string]*queuedAction
+	order   []string
+}
+
+// NewPendingActionService creates a new pending action service. Call Run to start performing due actions.
+func NewPendingActionService(logger *zap.Logger) (service *PendingActionService) {
+	service = &PendingActionService{
+		logger:  logger,
+		actions: make(map[string]*queuedAction),
+	}
+	return service
+}
+
+// ParseUndoWindow parses an undoWindow duration such as "30s", falling back to the default when the value is
+// empty. Zero means the action is performed immediately.
+func ParseUndoWindow(value string, defaultWindow time.Duration) (window time.Duration, err error) {
+	if value == "" {
+		window = defaultWindow
+		return window, err
+	}
+
+	window, err = time.ParseDuration(value)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ErrInvalidUndoWindow, err)
+		return window, err
+	}
+	if window < 0 || window > maxUndoWindow {
+		err = fmt.Errorf("%w: must be between 0s and %s", ErrInvalidUndoWindow, maxUndoWindow)
+		return window, err
+	}
+
+	return window, err
+}
+
+// Queue holds fn back for the undo window. The action runs with ctx's values, including the requesting user's
+// identity, but is not cancelled with it.
+func (pas *PendingActionService) Queue(ctx context.Context, action PendingAction, window time.Duration, fn pendingActionFunc) (queued PendingAction, err error) {
+	action.ID, err = newID()
+	if err != nil {
+		return queued, err
+	}
+	action.User = identityUser(ctx)
+	action.RequestID = requestID(ctx)
+	action.State = PendingActionPending
+	action.CreatedAt = time.Now().UTC()
+	action.RunAt = action.CreatedAt.Add(window)
+
+	pas.mu.Lock()
+	pas.actions[action.ID] = &queuedAction{action: action, ctx: context.WithoutCancel(ctx), fn: fn}
+	pas.order = append(pas.order, action.ID)
+	pas.prune()
+	pas.mu.Unlock()
+
+	pas.logger.Info("Action queued", zap.String("id", action.ID), zap.String("user", action.User), zap.String("requestId", action.RequestID), zap.String("cluster", action.Cluster), zap.String("kind", action.Kind), zap.String("namespace", action.Namespace), zap.String("name", action.Name), zap.String("action", action.Action), zap.Time("runAt", action.RunAt))
+
+	queued = action
+	return queued, err
+}
+
+// Run performs due actions every interval until the context is cancelled.
+func (pas *PendingActionService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pas.runDue()
+		}
+	}
+}
+
+// runDue performs every pending action whose undo window has passed.
+func (pas *PendingActionService) runDue() {
+	now := time.Now()
+
+	pas.mu.Lock()
+	var due []string
+	for _, id := range pas.order {
+		if queued := pas.actions[id]; queued.action.State == PendingActionPending && !now.Before(queued.action.RunAt) {
+			due = append(due, id)
+		}
+	}
+	pas.mu.Unlock()
+
+	for _, id := range due {
+		_, _ = pas.RunNow(id)
+	}
+}
+
+// RunNow performs a pending action immediately, skipping the rest of its undo window. Actions that already
+// ran or were cancelled are returned unchanged.
+func (pas *PendingActionService) RunNow(id string) (action PendingAction, err error) {
+	pas.mu.Lock()
+	queued, exists := pas.actions[id]
+	if !exists {
+		pas.mu.Unlock()
+		err = fmt.Errorf("%w: %s", ErrPendingActionNotFound, id)
+		return action, err
+	}
+	if queued.action.State != PendingActionPending {
+		action = queued.action
+		pas.mu.Unlock()
+		return action, err
+	}
+	queued.action.State = PendingActionRunning
+	pas.mu.Unlock()
+
+	runErr := queued.fn(queued.ctx)
+
+	pas.mu.Lock()
+	finishedAt := time.Now().UTC()
+	queued.action.State = PendingActionDone
+	if runErr != nil {
+		queued.action.State = PendingActionFailed
+		queued.action.Message = runErr.Error()
+	}
+	queued.action.FinishedAt = &finishedAt
+	action = queued.action
+	pas.mu.Unlock()
+
+	pas.logger.Info("Queued action finished", zap.String("id", id), zap.String("user", action.User), zap.String("requestId", action.RequestID), zap.String("kind", action.Kind), zap.String("namespace", action.Namespace), zap.String("name", action.Name), zap.String("action", action.Action), zap.String("state", action.State), zap.String("message", action.Message))
+	return action, err
+}
+
+// Cancel drops a pending action within its undo window, so it is never performed.
+func (pas *PendingActionService) Cancel(ctx context.Context, id string) (action PendingAction, err error) {
+	pas.mu.Lock()
+	defer pas.mu.Unlock()
+
+	queued, exists := pas.actions[id]
+	if !exists {
+		err = fmt.Errorf("%w: %s", ErrPendingActionNotFound, id)
+		return action, err
+	}
+
+	if queued.action.State == PendingActionPending {
+		queued.action.State = PendingActionCancelled
+		pas.logger.Info("Queued action cancelled", zap.String("id", id), zap.String("user", identityUser(ctx)), zap.String("requestId", requestID(ctx)))
+	}
+
+	action = queued.action
+	return action, err
+}
+
+// Get returns a pending action.
+func (pas *PendingActionService) Get(id string) (action PendingAction, err error) {
+	pas.mu.Lock()
+	defer pas.mu.Unlock()
+
+	queued, exists := pas.actions[id]
+	if !exists {
+		err = fmt.Errorf("%w: %s", ErrPendingActionNotFound, id)
+		return action, err
+	}
+
+	action = queued.action
+	return action, err
+}
+
+// owner returns who queued the action and its namespace.
+func (action PendingAction) owner() (user, namespace string) {
+	user, namespace = action.User, action.Namespace
+	return user, namespace
+}
+
+// List returns the retained actions, newest first. With pendingOnly, only actions still to run are returned.
+func (pas *PendingActionService) List(pendingOnly bool) (actions []PendingAction) {
+	pas.mu.Lock()
+	defer pas.mu.Unlock()
+
+	actions = make([]PendingAction, 0, len(pas.order))
+	for i := len(pas.order) - 1; i >= 0; i-- {
+		action := pas.actions[pas.order[i]].action
+		if pendingOnly && action.State != PendingActionPending {
+			continue
+		}
+		actions = append(actions, action)
+	}
+	return actions
+}
+
+// prune drops the oldest finished actions beyond the retention limit. The caller must hold the lock.
+func (pas *PendingActionService) prune() {
+	for len(pas.order) > maxRetainedPendingActions {
+		oldest := pas.actions[pas.order[0]]
+		if oldest.action.State == PendingActionPending || oldest.action.State == PendingActionRunning {
+			return
+		}
+		delete(pas.actions, pas.order[0])
+		pas.order = pas.order[1:]
+	}
+}
@@ -0,0 +1,233 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// DefaultPodCleanupRetention keeps finished pods for a day unless told otherwise.
+	DefaultPodCleanupRetention = 24 * time.Hour
+	// minPodCleanupRetention stops a cleanup from deleting pods that just finished, whose logs are still
+	// being looked at.
+	minPodCleanupRetention = time.Minute
+)
+
+// ErrInvalidPodCleanup is returned for cleanups with a bad retention or phase.
+var ErrInvalidPodCleanup = errors.New("invalid pod cleanup")
+
+// PodCleanup selects the finished pods to clean up: pods in one of the phases, Succeeded and Failed by default,
+// that finished longer than the retention ago. Namespace all, or empty, cleans up every namespace.
+type PodCleanup struct {
+	Namespace string
+	Retention time.Duration
+	Phases    []string
+	// Reasons limits the cleanup to pods with one of these status reasons, such as the EvictionReasons.
+	Reasons []string
+	DryRun  bool
+}
+
+// PodCleanupResult lists the pods a cleanup matched and, unless it was a dry run, the outcome for each. Deleted
+// and Failed are keyed by namespace/name.
+type PodCleanupResult struct {
+	DryRun    bool              `json:"dryRun"`
+	Retention string            `json:"retention"`
+	Pods      []PodInfo         `json:"pods"`
+	Deleted   []string          `json:"deleted,omitempty"`
+	Failed    map[string]string `json:"failed,omitempty"`
+}
+
+// ParsePodCleanup validates the olderThan, phase and reason parameters of a cleanup. An empty retention is the
+// default day, empty phases are Succeeded and Failed, and empty reasons match any pod.
+func ParsePodCleanup(namespace, olderThan, phases, reasons string, dryRun bool) (cleanup PodCleanup, err error) {
+	cleanup = PodCleanup{Namespace: namespace, Retention: DefaultPodCleanupRetention, DryRun: dryRun}
+	if olderThan != "" {
+		cleanup.Retention, err = time.ParseDuration(olderThan)
+		if err != nil {
+			err = fmt.Errorf("%w: olderThan: %w", ErrInvalidPodCleanup, err)
+			return cleanup, err
+		}
+	}
+	cleanup.Phases = splitCleanupList(phases)
+	cleanup.Reasons = splitCleanupList(reasons)
+	err = cleanup.Validate()
+	return cleanup, err
+}
+
+// Validate returns ErrInvalidPodCleanup for a retention under a minute or phases other than Succeeded and
+// Failed, so running pods are never cleaned up.
+func (cleanup PodCleanup) Validate() (err error) {
+	if cleanup.Retention < minPodCleanupRetention {
+		err = fmt.Errorf("%w: olderThan must be at least %s", ErrInvalidPodCleanup, minPodCleanupRetention)
+		return err
+	}
+	for _, phase := range cleanup.Phases {
+		if phase != string(corev1.PodSucceeded) && phase != string(corev1.PodFailed) {
+			err = fmt.Errorf("%w: phase must be %s or %s, got %q", ErrInvalidPodCleanup, corev1.PodSucceeded, corev1.PodFailed, phase)
+			return err
+		}
+	}
+	return err
+}
+
+// splitCleanupList splits a comma separated parameter, dropping empty items.
+func splitCleanupList(list string) (items []string) {
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// CleanupPods deletes finished pods older than the cleanup's retention, such as the pods completed Jobs leave
+// behind. With DryRun the pods are only listed. Pods are deleted one at a time and a failure doesn't stop the
+// others.
+func (ps *PodService) CleanupPods(ctx context.Context, clusterName string, cleanup PodCleanup) (result PodCleanupResult, err error) {
+	result, err = ps.cleanupPods(ctx, clusterName, cleanup, func(JobProgress) {})
+	return result, err
+}
+
+// CleanupPodsJob returns a job function performing a cleanup, reporting each pod deleted. Cancelling the job
+// stops it before the next pod.
+func (ps *PodService) CleanupPodsJob(clusterName string, cleanup PodCleanup) (fn jobFunc) {
+	fn = func(ctx context.Context, report func(progress JobProgress)) (result interface{}, err error) {
+		result, err = ps.cleanupPods(ctx, clusterName, cleanup, report)
+		return result, err
+	}
+	return fn
+}
+
+// cleanupPods performs a cleanup, calling report once the pods are matched and after each deletion.
+func (ps *PodService) cleanupPods(ctx context.Context, clusterName string, cleanup PodCleanup, report func(progress JobProgress)) (result PodCleanupResult, err error) {
+	result = PodCleanupResult{DryRun: cleanup.DryRun, Retention: cleanup.Retention.String(), Pods: make([]PodInfo, 0)}
+	err = cleanup.Validate()
+	if err != nil {
+		return result, err
+	}
+
+	var client kubernetes.Interface
+	client, err = ps.getClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return result, err
+	}
+
+	var pods []corev1.Pod
+	pods, err = ps.finishedPods(ctx, client, clusterName, cleanup)
+	if err != nil {
+		return result, err
+	}
+
+	view := timeViewFromContext(ctx)
+	for i := range pods {
+		result.Pods = append(result.Pods, ps.podToPodInfo(&pods[i], view))
+	}
+	if cleanup.DryRun {
+		return result, err
+	}
+
+	report(JobProgress{Total: len(pods)})
+	for i := range pods {
+		if ctx.Err() != nil {
+			break
+		}
+		key := pods[i].Namespace + "/" + pods[i].Name
+		deleteErr := client.CoreV1().Pods(pods[i].Namespace).Delete(ctx, pods[i].Name, metav1.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &pods[i].UID}})
+		if deleteErr != nil {
+			if result.Failed == nil {
+				result.Failed = make(map[string]string)
+			}
+			result.Failed[key] = deleteErr.Error()
+		} else {
+			result.Deleted = append(result.Deleted, key)
+		}
+		report(JobProgress{Done: i + 1, Total: len(pods), Message: fmt.Sprintf("%d deleted, %d failed", len(result.Deleted), len(result.Failed))})
+	}
+
+	ps.logger.Info("Finished pods cleaned up", zap.String("cluster", clusterName), zap.String("namespace", cleanup.Namespace), zap.Duration("retention", cleanup.Retention), zap.Int("deleted", len(result.Deleted)), zap.Int("failed", len(result.Failed)), zap.String("user", identityUser(ctx)), zap.String("requestId", requestID(ctx)))
+	return result, err
+}
+
+// finishedPods lists the pods in the cleanup's phases and with one of its reasons that finished longer than its
+// retention ago. The API server selects the phases, so only finished pods are transferred.
+func (ps *PodService) finishedPods(ctx context.Context, client kubernetes.Interface, clusterName string, cleanup PodCleanup) (pods []corev1.Pod, err error) {
+	namespace := cleanup.Namespace
+	if namespace == "all" {
+		namespace = ""
+	}
+	phases := cleanup.Phases
+	if len(phases) == 0 {
+		phases = []string{string(corev1.PodSucceeded), string(corev1.PodFailed)}
+	}
+
+	cutoff := timeViewFromContext(ctx).clock.Now().Add(-cleanup.Retention)
+	for _, phase := range slices.Compact(slices.Sorted(slices.Values(phases))) {
+		var phasePods []corev1.Pod
+		phasePods, err = ps.listPods(ctx, client, clusterName, namespace, "", "status.phase="+phase)
+		if err != nil {
+			return pods, err
+		}
+		for i := range phasePods {
+			// The phase is checked again in case a pod moved on between the selection and the list.
+			if string(phasePods[i].Status.Phase) != phase || !podFinishedAt(&phasePods[i]).Before(cutoff) {
+				continue
+			}
+			if len(cleanup.Reasons) == 0 || slices.Contains(cleanup.Reasons, phasePods[i].Status.Reason) {
+				pods = append(pods, phasePods[i])
+			}
+		}
+	}
+	return pods, err
+}
+
+// podFinishedAt returns when a finished pod's last container terminated, falling back to when it started or
+// was created for pods that never ran a container, such as evicted ones.
+func podFinishedAt(pod *corev1.Pod) (finished time.Time) {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Terminated != nil && status.State.Terminated.FinishedAt.After(finished) {
+			finished = status.State.Terminated.FinishedAt.Time
+		}
+	}
+	if !finished.IsZero() {
+		return finished
+	}
+	if pod.Status.StartTime != nil {
+		finished = pod.Status.StartTime.Time
+		return finished
+	}
+	finished = pod.CreationTimestamp.Time
+	return finished
+}
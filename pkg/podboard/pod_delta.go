@@ -0,0 +1,157 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// podDeltaMaxAge is how long a listing's snapshot can be used as the base of a delta.
+	podDeltaMaxAge = 10 * time.Minute
+	// podDeltaMaxSnapshots bounds the snapshots kept across all queries and resource versions.
+	podDeltaMaxSnapshots = 500
+)
+
+// PodDelta is how a pod listing changed since a resource version. When the listing the client last saw is
+// unknown, as on the first request or once its snapshot expired, Reset is set and every current pod is in Added.
+type PodDelta struct {
+	ResourceVersion string    `json:"resourceVersion"`
+	Reset           bool      `json:"reset"`
+	Added           []PodInfo `json:"added"`
+	Modified        []PodInfo `json:"modified"`
+	Deleted         []PodRef  `json:"deleted"`
+}
+
+// PodRef identifies a deleted pod.
+type PodRef struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	UID       string `json:"uid"`
+}
+
+// podSnapshot is what a delta needs to remember of a listing: each pod's resource version and name.
+type podSnapshot map[types.UID]podSnapshotEntry
+
+type podSnapshotEntry struct {
+	resourceVersion string
+	ref             PodRef
+}
+
+// GetPodDelta lists the pods in a namespace matching a label selector, which may use the =~ and !~ regex
+// operators, and returns the pods added, modified or deleted since the listing at resource version since.
+// Pods are modified when their own resource version changed. Like pod streams, delta pods aren't enriched
+// with usage or metrics. The returned resource version is the since of the next request.
+func (ps *PodService) GetPodDelta(ctx context.Context, clusterName, namespace, labelSelector, since string) (delta PodDelta, err error) {
+	var selector podSelector
+	selector, err = parsePodSelector(labelSelector)
+	if err != nil {
+		return delta, err
+	}
+
+	err = ps.namespacePolicy.Check(namespace)
+	if err != nil {
+		return delta, err
+	}
+
+	var client kubernetes.Interface
+	client, err = ps.getClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return delta, err
+	}
+
+	queryNamespace := namespace
+	if namespace == "all" {
+		queryNamespace = ""
+	}
+
+	var pods *corev1.PodList
+	pods, err = client.CoreV1().Pods(queryNamespace).List(ctx, metav1.ListOptions{LabelSelector: selector.standard})
+	if err != nil {
+		err = fmt.Errorf("failed to list pods: %w", err)
+		return delta, err
+	}
+
+	current := make(podSnapshot, len(pods.Items))
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if selector.Matches(pod.Labels) && ps.namespacePolicy.Allows(pod.Namespace) {
+			current[pod.UID] = podSnapshotEntry{resourceVersion: pod.ResourceVersion, ref: PodRef{Namespace: pod.Namespace, Name: pod.Name, UID: string(pod.UID)}}
+		}
+	}
+
+	key := clusterName + "|" + KubeContextFromContext(ctx) + "|" + identityUser(ctx) + "|" + namespace + "|" + labelSelector + "|"
+	ps.podSnapshots.Store(key+pods.ResourceVersion, current)
+
+	var previous podSnapshot
+	if since != "" {
+		if cached, _, found := ps.podSnapshots.Load(key + since); found {
+			previous, _ = cached.(podSnapshot)
+		}
+	}
+
+	delta = ps.podDelta(previous, current, pods.Items, timeViewFromContext(ctx), extendedPodDetailFromContext(ctx))
+	delta.ResourceVersion = pods.ResourceVersion
+	return delta, err
+}
+
+// podDelta compares two snapshots of a listing, converting the added and modified pods. Without a previous
+// snapshot the delta is a reset holding every current pod. Extended deltas carry the PodDetailExtended fields.
+func (ps *PodService) podDelta(previous, current podSnapshot, pods []corev1.Pod, view timeView, extended bool) (delta PodDelta) {
+	delta = PodDelta{Reset: previous == nil, Added: make([]PodInfo, 0), Modified: make([]PodInfo, 0), Deleted: make([]PodRef, 0)}
+
+	for i := range pods {
+		entry, listed := current[pods[i].UID]
+		if !listed {
+			continue
+		}
+		before, existed := previous[pods[i].UID]
+		if existed && before.resourceVersion == entry.resourceVersion {
+			continue
+		}
+		podInfo := ps.podToPodInfo(&pods[i], view)
+		if extended {
+			setExtendedPodDetail(&podInfo, &pods[i])
+		}
+		if existed {
+			delta.Modified = append(delta.Modified, podInfo)
+		} else {
+			delta.Added = append(delta.Added, podInfo)
+		}
+	}
+
+	for uid, before := range previous {
+		if _, exists := current[uid]; !exists {
+			delta.Deleted = append(delta.Deleted, before.ref)
+		}
+	}
+	return delta
+}
@@ -0,0 +1,398 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ErrPodNotFound is returned when a described pod doesn't exist.
+var ErrPodNotFound = errors.New("pod not found")
+
+// PodDetail is a pod with the details needed for debugging, similar to kubectl describe pod.
+type PodDetail struct {
+	PodInfo
+	Phase       string            `json:"phase"`
+	HostIP      string            `json:"hostIP,omitempty"`
+	StartTime   string            `json:"startTime,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// RedactedAnnotations and TruncatedAnnotations list the annotations left out or cut short.
+	RedactedAnnotations  []string           `json:"redactedAnnotations,omitempty"`
+	TruncatedAnnotations []string           `json:"truncatedAnnotations,omitempty"`
+	OwnerReferences      []OwnerReference   `json:"ownerReferences"`
+	Conditions           []PodCondition     `json:"conditions"`
+	InitContainers       []ContainerDetail  `json:"initContainers"`
+	Containers           []ContainerDetail  `json:"containers"`
+	Volumes              []VolumeInfo       `json:"volumes"`
+	NodeSelector         map[string]string  `json:"nodeSelector,omitempty"`
+	Tolerations          []TolerationInfo   `json:"tolerations"`
+	Affinity             *corev1.Affinity   `json:"affinity,omitempty"`
+	Resources            *ContainerResource `json:"resources,omitempty"`
+}
+
+// OwnerReference names an object that owns the pod.
+type OwnerReference struct {
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Controller bool   `json:"controller"`
+}
+
+// PodCondition is a pod condition such as Ready or PodScheduled.
+type PodCondition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	Reason             string `json:"reason,omitempty"`
+	Message            string `json:"message,omitempty"`
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+}
+
+// ContainerDetail describes a container and its current state.
+// Only the names of environment variables are included, since their values may hold secrets.
+type ContainerDetail struct {
+	Name            string             `json:"name"`
+	Type            string             `json:"type"`
+	RestartPolicy   string             `json:"restartPolicy"`
+	Image           string             `json:"image"`
+	ImageID         string             `json:"imageID,omitempty"`
+	Ports           []ContainerPort    `json:"ports,omitempty"`
+	Env             []string           `json:"env,omitempty"`
+	EnvFrom         []string           `json:"envFrom,omitempty"`
+	VolumeMounts    []VolumeMount      `json:"volumeMounts,omitempty"`
+	Resources       *ContainerResource `json:"resources,omitempty"`
+	Ready           bool               `json:"ready"`
+	Started         bool               `json:"started"`
+	RestartCount    int32              `json:"restartCount"`
+	State           string             `json:"state"`
+	StateReason     string             `json:"stateReason,omitempty"`
+	StateMessage    string             `json:"stateMessage,omitempty"`
+	LastTermination *TerminationInfo   `json:"lastTermination,omitempty"`
+}
+
+// ContainerPort is a port exposed by a container.
+type ContainerPort struct {
+	Name          string `json:"name,omitempty"`
+	ContainerPort int32  `json:"containerPort"`
+	Protocol      string `json:"protocol"`
+}
+
+// VolumeMount is a volume mounted into a container.
+type VolumeMount struct {
+	Name      string `json:"name"`
+	MountPath string `json:"mountPath"`
+	ReadOnly  bool   `json:"readOnly,omitempty"`
+}
+
+// ContainerResource holds resource requests and limits, e.g. {"cpu": "100m"}.
+type ContainerResource struct {
+	Requests map[string]string `json:"requests,omitempty"`
+	Limits   map[string]string `json:"limits,omitempty"`
+}
+
+// TerminationInfo describes how a container last terminated.
+type TerminationInfo struct {
+	Reason     string `json:"reason,omitempty"`
+	Message    string `json:"message,omitempty"`
+	ExitCode   int32  `json:"exitCode"`
+	Signal     int32  `json:"signal,omitempty"`
+	StartedAt  string `json:"startedAt,omitempty"`
+	FinishedAt string `json:"finishedAt,omitempty"`
+}
+
+// VolumeInfo describes a pod volume by type and the object or path it comes from.
+type VolumeInfo struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Source string `json:"source,omitempty"`
+}
+
+// TolerationInfo is a toleration of node taints.
+type TolerationInfo struct {
+	Key               string `json:"key,omitempty"`
+	Operator          string `json:"operator,omitempty"`
+	Value             string `json:"value,omitempty"`
+	Effect            string `json:"effect,omitempty"`
+	TolerationSeconds *int64 `json:"tolerationSeconds,omitempty"`
+}
+
+// DescribePod retrieves a pod with full details. It returns ErrPodNotFound if the pod doesn't exist.
+func (ps *PodService) DescribePod(ctx context.Context, clusterName, namespace, podName string) (detail PodDetail, err error) {
+	err = ps.namespacePolicy.Check(namespace)
+	if err != nil {
+		return detail, err
+	}
+
+	var client kubernetes.Interface
+	client, err = ps.getClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return detail, err
+	}
+
+	var pod *corev1.Pod
+	pod, err = client.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			err = fmt.Errorf("%w: %s/%s", ErrPodNotFound, namespace, podName)
+			return detail, err
+		}
+		err = fmt.Errorf("failed to get pod %s/%s: %w", namespace, podName, err)
+		return detail, err
+	}
+
+	view := timeViewFromContext(ctx)
+	podInfo := ps.podToPodInfo(pod, view)
+	setExtendedPodDetail(&podInfo, pod)
+	podInfo.NodeProblems = ps.nodeProblems.Get(ctx, client, clusterName)[podInfo.Node]
+	podInfo.SandboxRestarts = ps.sandboxRestarts(ctx, client, clusterName, namespace)[pod.UID]
+	podInfo.DerivedStatuses = ps.derivedStatuses.ForPod(podInfo)
+
+	detail = PodDetail{
+		PodInfo:         podInfo,
+		Phase:           string(pod.Status.Phase),
+		HostIP:          pod.Status.HostIP,
+		NodeSelector:    pod.Spec.NodeSelector,
+		Affinity:        pod.Spec.Affinity,
+		Resources:       containerResource(pod.Spec.Resources),
+		OwnerReferences: make([]OwnerReference, 0, len(pod.OwnerReferences)),
+		Conditions:      make([]PodCondition, 0, len(pod.Status.Conditions)),
+		InitContainers:  containerDetails(pod, true, view),
+		Containers:      containerDetails(pod, false, view),
+		Volumes:         make([]VolumeInfo, 0, len(pod.Spec.Volumes)),
+		Tolerations:     make([]TolerationInfo, 0, len(pod.Spec.Tolerations)),
+	}
+	if pod.Status.StartTime != nil {
+		detail.StartTime = view.format(pod.Status.StartTime.Time)
+	}
+	detail.Annotations, detail.RedactedAnnotations, detail.TruncatedAnnotations = ps.annotations.Apply(pod.Annotations)
+
+	for _, owner := range pod.OwnerReferences {
+		detail.OwnerReferences = append(detail.OwnerReferences, OwnerReference{
+			Kind:       owner.Kind,
+			Name:       owner.Name,
+			Controller: owner.Controller != nil && *owner.Controller,
+		})
+	}
+
+	for _, condition := range pod.Status.Conditions {
+		detail.Conditions = append(detail.Conditions, PodCondition{
+			Type:               string(condition.Type),
+			Status:             string(condition.Status),
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+			LastTransitionTime: view.format(condition.LastTransitionTime.Time),
+		})
+	}
+
+	for _, volume := range pod.Spec.Volumes {
+		detail.Volumes = append(detail.Volumes, volumeInfo(volume))
+	}
+
+	for _, toleration := range pod.Spec.Tolerations {
+		detail.Tolerations = append(detail.Tolerations, TolerationInfo{
+			Key:               toleration.Key,
+			Operator:          string(toleration.Operator),
+			Value:             toleration.Value,
+			Effect:            string(toleration.Effect),
+			TolerationSeconds: toleration.TolerationSeconds,
+		})
+	}
+
+	return detail, err
+}
+
+// containerDetails describes a pod's init or app containers, merging each container's spec with its status.
+func containerDetails(pod *corev1.Pod, init bool, view timeView) (details []ContainerDetail) {
+	containers, statuses := pod.Spec.Containers, pod.Status.ContainerStatuses
+	if init {
+		containers, statuses = pod.Spec.InitContainers, pod.Status.InitContainerStatuses
+	}
+
+	statusByName := make(map[string]corev1.ContainerStatus, len(statuses))
+	for _, status := range statuses {
+		statusByName[status.Name] = status
+	}
+
+	details = make([]ContainerDetail, 0, len(containers))
+	for _, container := range containers {
+		detail := ContainerDetail{
+			Name:      container.Name,
+			Image:     container.Image,
+			Resources: containerResource(&container.Resources),
+			State:     "Waiting",
+		}
+		detail.Type, detail.RestartPolicy = containerRestartPolicy(pod, container, init)
+
+		for _, port := range container.Ports {
+			detail.Ports = append(detail.Ports, ContainerPort{Name: port.Name, ContainerPort: port.ContainerPort, Protocol: string(port.Protocol)})
+		}
+		for _, env := range container.Env {
+			detail.Env = append(detail.Env, env.Name)
+		}
+		for _, envFrom := range container.EnvFrom {
+			detail.EnvFrom = append(detail.EnvFrom, envFromSource(envFrom))
+		}
+		for _, mount := range container.VolumeMounts {
+			detail.VolumeMounts = append(detail.VolumeMounts, VolumeMount{Name: mount.Name, MountPath: mount.MountPath, ReadOnly: mount.ReadOnly})
+		}
+
+		if status, exists := statusByName[container.Name]; exists {
+			applyContainerStatus(&detail, status, view)
+		}
+		details = append(details, detail)
+	}
+
+	return details
+}
+
+// applyContainerStatus fills in a container's state, readiness and last termination.
+func applyContainerStatus(detail *ContainerDetail, status corev1.ContainerStatus, view timeView) {
+	detail.ImageID = status.ImageID
+	detail.Ready = status.Ready
+	detail.Started = status.Started != nil && *status.Started
+	detail.RestartCount = status.RestartCount
+
+	switch {
+	case status.State.Running != nil:
+		detail.State = "Running"
+	case status.State.Terminated != nil:
+		detail.State = "Terminated"
+		detail.StateReason = status.State.Terminated.Reason
+		detail.StateMessage = status.State.Terminated.Message
+	case status.State.Waiting != nil:
+		detail.StateReason = status.State.Waiting.Reason
+		detail.StateMessage = status.State.Waiting.Message
+	}
+
+	if terminated := status.LastTerminationState.Terminated; terminated != nil {
+		detail.LastTermination = &TerminationInfo{
+			Reason:     terminated.Reason,
+			Message:    terminated.Message,
+			ExitCode:   terminated.ExitCode,
+			Signal:     terminated.Signal,
+			StartedAt:  view.format(terminated.StartedAt.Time),
+			FinishedAt: view.format(terminated.FinishedAt.Time),
+		}
+	}
+}
+
+// containerResource converts resource requirements, returning nil if none are set.
+func containerResource(requirements *corev1.ResourceRequirements) (resource *ContainerResource) {
+	if requirements == nil || (len(requirements.Requests) == 0 && len(requirements.Limits) == 0) {
+		return resource
+	}
+
+	resource = &ContainerResource{
+		Requests: resourceListStrings(requirements.Requests),
+		Limits:   resourceListStrings(requirements.Limits),
+	}
+	return resource
+}
+
+// resourceListStrings formats a resource list, e.g. {"cpu": "100m", "memory": "128Mi"}.
+func resourceListStrings(list corev1.ResourceList) (values map[string]string) {
+	if len(list) == 0 {
+		return values
+	}
+
+	values = make(map[string]string, len(list))
+	for name, quantity := range list {
+		values[string(name)] = quantity.String()
+	}
+	return values
+}
+
+// envFromSource names the ConfigMap or Secret a container takes environment variables from.
+func envFromSource(envFrom corev1.EnvFromSource) (source string) {
+	switch {
+	case envFrom.ConfigMapRef != nil:
+		source = "ConfigMap/" + envFrom.ConfigMapRef.Name
+	case envFrom.SecretRef != nil:
+		source = "Secret/" + envFrom.SecretRef.Name
+	}
+	if envFrom.Prefix != "" {
+		source += " (prefix " + envFrom.Prefix + ")"
+	}
+	return source
+}
+
+// volumeInfo describes a volume by its type and the object or path it comes from.
+func volumeInfo(volume corev1.Volume) (info VolumeInfo) {
+	info = VolumeInfo{Name: volume.Name, Type: "Other"}
+	source := volume.VolumeSource
+
+	switch {
+	case source.ConfigMap != nil:
+		info.Type, info.Source = "ConfigMap", source.ConfigMap.Name
+	case source.Secret != nil:
+		info.Type, info.Source = "Secret", source.Secret.SecretName
+	case source.PersistentVolumeClaim != nil:
+		info.Type, info.Source = "PersistentVolumeClaim", source.PersistentVolumeClaim.ClaimName
+	case source.EmptyDir != nil:
+		info.Type = "EmptyDir"
+	case source.HostPath != nil:
+		info.Type, info.Source = "HostPath", source.HostPath.Path
+	case source.Projected != nil:
+		info.Type = "Projected"
+		info.Source = projectedSources(source.Projected)
+	case source.DownwardAPI != nil:
+		info.Type = "DownwardAPI"
+	case source.CSI != nil:
+		info.Type, info.Source = "CSI", source.CSI.Driver
+	case source.Ephemeral != nil:
+		info.Type = "Ephemeral"
+	case source.NFS != nil:
+		info.Type, info.Source = "NFS", source.NFS.Server+":"+source.NFS.Path
+	}
+
+	return info
+}
+
+// projectedSources lists the objects a projected volume combines, e.g. "ServiceAccountToken, ConfigMap/kube-root-ca.crt".
+func projectedSources(projected *corev1.ProjectedVolumeSource) (sources string) {
+	var names []string
+	for _, source := range projected.Sources {
+		switch {
+		case source.ConfigMap != nil:
+			names = append(names, "ConfigMap/"+source.ConfigMap.Name)
+		case source.Secret != nil:
+			names = append(names, "Secret/"+source.Secret.Name)
+		case source.ServiceAccountToken != nil:
+			names = append(names, "ServiceAccountToken")
+		case source.DownwardAPI != nil:
+			names = append(names, "DownwardAPI")
+		}
+	}
+	sort.Strings(names)
+
+	sources = strings.Join(names, ", ")
+	return sources
+}
@@ -0,0 +1,188 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// PodDetailExtended is the detail query parameter value adding each pod's priority class, QoS class and service
+// account to pod listings, streams and deltas. Pod details always include them.
+const PodDetailExtended = "extended"
+
+// ErrInvalidPodFields is returned for field masks naming fields pods don't have.
+var ErrInvalidPodFields = errors.New("invalid pod fields")
+
+// podField is a PodInfo field selectable by its JSON name.
+type podField struct {
+	name      string
+	index     int
+	omitEmpty bool
+}
+
+// PodFields is a field mask selecting which PodInfo fields a listing returns, by their JSON names. The zero
+// mask selects every field.
+type PodFields struct {
+	fields []podField
+}
+
+// ParsePodFields parses the fields query parameter, a comma-separated list of PodInfo JSON field names such as
+// "name,namespace,status,ready".
+func ParsePodFields(fields string) (mask PodFields, err error) {
+	if strings.TrimSpace(fields) == "" {
+		return mask, err
+	}
+
+	requested := make(map[string]bool)
+	for _, name := range strings.Split(fields, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			requested[name] = true
+		}
+	}
+
+	podType := reflect.TypeFor[PodInfo]()
+	for i := range podType.NumField() {
+		field := podType.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "" || name == "-" || !requested[name] {
+			continue
+		}
+		mask.fields = append(mask.fields, podField{name: name, index: i, omitEmpty: options == "omitempty"})
+		delete(requested, name)
+	}
+
+	if len(requested) > 0 {
+		unknown := make([]string, 0, len(requested))
+		for name := range requested {
+			unknown = append(unknown, name)
+		}
+		sort.Strings(unknown)
+		err = fmt.Errorf("%w: unknown fields %s", ErrInvalidPodFields, strings.Join(unknown, ", "))
+		return mask, err
+	}
+	return mask, err
+}
+
+// Selects reports whether the mask narrows the fields at all.
+func (mask PodFields) Selects() (selects bool) {
+	selects = len(mask.fields) > 0
+	return selects
+}
+
+// Apply returns the pods with only the selected fields, keyed by their JSON names. Empty fields tagged omitempty
+// are left out as they would be from a full PodInfo. Without a selection the pods are returned as they are.
+func (mask PodFields) Apply(pods []PodInfo) (masked interface{}) {
+	if !mask.Selects() {
+		masked = pods
+		return masked
+	}
+
+	selected := make([]interface{}, 0, len(pods))
+	for i := range pods {
+		selected = append(selected, mask.Select(pods[i]))
+	}
+	masked = selected
+	return masked
+}
+
+// Select returns one pod with only the selected fields, like Apply.
+func (mask PodFields) Select(pod PodInfo) (masked interface{}) {
+	if !mask.Selects() {
+		masked = pod
+		return masked
+	}
+
+	value := reflect.ValueOf(pod)
+	selected := make(map[string]interface{}, len(mask.fields))
+	for _, field := range mask.fields {
+		fieldValue := value.Field(field.index)
+		if field.omitEmpty && emptyJSONValue(fieldValue) {
+			continue
+		}
+		selected[field.name] = fieldValue.Interface()
+	}
+	masked = selected
+	return masked
+}
+
+// emptyJSONValue reports whether omitempty leaves a value out of JSON: empty strings, slices and maps, and
+// zero numbers, booleans and pointers.
+func emptyJSONValue(value reflect.Value) (empty bool) {
+	switch value.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		empty = value.Len() == 0
+	default:
+		empty = value.IsZero()
+	}
+	return empty
+}
+
+type extendedPodDetailKey struct{}
+
+// withExtendedPodDetail returns a copy of ctx whose pod listings include the PodDetailExtended fields.
+func withExtendedPodDetail(ctx context.Context) (detailCtx context.Context) {
+	detailCtx = context.WithValue(ctx, extendedPodDetailKey{}, true)
+	return detailCtx
+}
+
+// extendedPodDetailFromContext reports whether pod listings in ctx include the PodDetailExtended fields.
+func extendedPodDetailFromContext(ctx context.Context) (extended bool) {
+	if ctx != nil {
+		extended, _ = ctx.Value(extendedPodDetailKey{}).(bool)
+	}
+	return extended
+}
+
+// podDetailMiddleware validates the detail query parameter of pod listings, marking the request context when
+// PodDetailExtended is requested.
+func podDetailMiddleware() (handler gin.HandlerFunc) {
+	handler = func(c *gin.Context) {
+		switch c.Query("detail") {
+		case "":
+		case PodDetailExtended:
+			c.Request = c.Request.WithContext(withExtendedPodDetail(c.Request.Context()))
+		default:
+			respondErrorCode(c, 400, fmt.Sprintf("detail must be %q", PodDetailExtended))
+			return
+		}
+		c.Next()
+	}
+	return handler
+}
+
+// setExtendedPodDetail sets the fields only returned with PodDetailExtended, which most listings don't need
+// on every poll.
+func setExtendedPodDetail(info *PodInfo, pod *corev1.Pod) {
+	info.PriorityClass = pod.Spec.PriorityClassName
+	info.QOSClass = string(pod.Status.QOSClass)
+	info.ServiceAccount = pod.Spec.ServiceAccountName
+}
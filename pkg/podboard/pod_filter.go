@@ -0,0 +1,142 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+)
+
+// ErrInvalidPodFilter is returned for name or namespace patterns that aren't valid regular expressions, and for
+// field selectors the API server can't evaluate on pods.
+var ErrInvalidPodFilter = errors.New("invalid pod filter")
+
+// PodFilter narrows a pod listing beyond its label selector. FieldSelector is passed to the API server, so pods
+// on another node or in another phase never cross the wire. Name and Namespace are RE2 patterns on pod names and
+// namespaces, for workloads whose names are the only consistent handle. A nil pattern matches everything.
+// Patterns are unanchored, like =~ selectors.
+type PodFilter struct {
+	FieldSelector string
+	Name          *regexp.Regexp
+	Namespace     *regexp.Regexp
+}
+
+// ParsePodFilter compiles the nameFilter, namespacePattern and fieldSelector query parameters; empty values match
+// everything.
+func ParsePodFilter(nameFilter, namespacePattern, fieldSelector string) (filter PodFilter, err error) {
+	filter.Name, err = compileFilterPattern("nameFilter", nameFilter)
+	if err != nil {
+		return filter, err
+	}
+	filter.Namespace, err = compileFilterPattern("namespacePattern", namespacePattern)
+	if err != nil {
+		return filter, err
+	}
+	filter.FieldSelector, err = parsePodFieldSelector(fieldSelector)
+	return filter, err
+}
+
+// parsePodFieldSelector validates a field selector against the fields the API server can select pods by, so a
+// typo is reported as a bad request instead of a failed listing.
+func parsePodFieldSelector(fieldSelector string) (normalized string, err error) {
+	if fieldSelector == "" {
+		return normalized, err
+	}
+
+	var selector fields.Selector
+	selector, err = fields.ParseSelector(fieldSelector)
+	if err != nil {
+		err = fmt.Errorf("%w: fieldSelector: %w", ErrInvalidPodFilter, err)
+		return normalized, err
+	}
+	for _, requirement := range selector.Requirements() {
+		if !podSelectableField(requirement.Field) {
+			err = fmt.Errorf("%w: fieldSelector: pods can't be selected by %q", ErrInvalidPodFilter, requirement.Field)
+			return normalized, err
+		}
+	}
+
+	normalized = selector.String()
+	return normalized, err
+}
+
+// podSelectableField reports whether the API server supports a field in pod field selectors.
+func podSelectableField(field string) (selectable bool) {
+	switch field {
+	case "metadata.name", "metadata.namespace", "spec.nodeName", "spec.restartPolicy", "spec.schedulerName",
+		"spec.serviceAccountName", "spec.hostNetwork", "status.phase", "status.podIP", "status.nominatedNodeName":
+		selectable = true
+	}
+	return selectable
+}
+
+// compileFilterPattern compiles a filter pattern, returning nil for an empty one.
+func compileFilterPattern(param, pattern string) (compiled *regexp.Regexp, err error) {
+	if pattern == "" {
+		return compiled, err
+	}
+	compiled, err = regexp.Compile(pattern)
+	if err != nil {
+		err = fmt.Errorf("%w: %s: %w", ErrInvalidPodFilter, param, err)
+		return compiled, err
+	}
+	return compiled, err
+}
+
+// Empty reports whether the filter matches every pod.
+func (filter PodFilter) Empty() (empty bool) {
+	empty = filter.FieldSelector == "" && filter.Name == nil && filter.Namespace == nil
+	return empty
+}
+
+// Matches reports whether a pod's name and namespace match the filter.
+func (filter PodFilter) Matches(name, namespace string) (matches bool) {
+	if filter.Name != nil && !filter.Name.MatchString(name) {
+		return matches
+	}
+	if filter.Namespace != nil && !filter.Namespace.MatchString(namespace) {
+		return matches
+	}
+	matches = true
+	return matches
+}
+
+// filterPods returns the pods matching the filter's patterns. The field selector has already been applied by the
+// API server.
+func (filter PodFilter) filterPods(pods []corev1.Pod) (matched []corev1.Pod) {
+	if filter.Name == nil && filter.Namespace == nil {
+		matched = pods
+		return matched
+	}
+
+	for i := range pods {
+		if filter.Matches(pods[i].Name, pods[i].Namespace) {
+			matched = append(matched, pods[i])
+		}
+	}
+	return matched
+}
@@ -0,0 +1,126 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// NDJSONContentType is the media type of newline-delimited JSON, one pod per line.
+	NDJSONContentType = "application/x-ndjson"
+	// ndjsonPageSize is how many pods are listed, converted and written at a time when streaming a listing.
+	ndjsonPageSize = 500
+)
+
+// wantsNDJSON reports whether the client asked for a listing as newline-delimited JSON.
+func wantsNDJSON(c *gin.Context) (wants bool) {
+	wants = strings.Contains(c.GetHeader("Accept"), NDJSONContentType)
+	return wants
+}
+
+// ndjsonStream writes newline-delimited JSON, flushing after each batch so clients can render progressively.
+type ndjsonStream struct {
+	c       *gin.Context
+	encoder *json.Encoder
+	started bool
+}
+
+// start sends the response headers. Streams are exempt from the server's write timeout, since a huge listing
+// can take longer to write than an ordinary response.
+func (stream *ndjsonStream) start() {
+	if stream.started {
+		return
+	}
+	stream.started = true
+
+	// Fails only if the connection doesn't support deadlines, in which case there is no timeout to lift.
+	_ = http.NewResponseController(stream.c.Writer).SetWriteDeadline(time.Time{})
+
+	stream.c.Header("Content-Type", NDJSONContentType)
+	stream.c.Header("Cache-Control", "no-cache")
+	// Stops NGINX from buffering the stream.
+	stream.c.Header("X-Accel-Buffering", "no")
+	stream.c.Status(http.StatusOK)
+	stream.encoder = json.NewEncoder(stream.c.Writer)
+}
+
+// write writes a batch of pods, one per line, with only the fields the mask selects.
+func (stream *ndjsonStream) write(pods []PodInfo, fields PodFields) {
+	stream.start()
+	for i := range pods {
+		// Encoding only fails once the client has gone, which the request context reports.
+		_ = stream.encoder.Encode(fields.Select(pods[i]))
+	}
+	stream.c.Writer.Flush()
+}
+
+// fail reports an error. Before anything was written it is an ordinary error response; after that the status
+// is already sent, so the error is the stream's last line, an object with error, code and reason.
+func (stream *ndjsonStream) fail(err error) {
+	if !stream.started {
+		respondError(stream.c, err)
+		return
+	}
+	code, reason := errorStatus(err)
+	_ = stream.encoder.Encode(errorBody(stream.c, code, reason, err.Error()))
+	stream.c.Writer.Flush()
+}
+
+// streamPodsNDJSON writes a pod listing as newline-delimited JSON. Unsorted listings of a single cluster and namespace
+// are listed from Kubernetes a page at a time, and each page is written as soon as it is converted; sorted and
+// merged listings have to be assembled first and are written in one go.
+func streamPodsNDJSON(c *gin.Context, services *apiServices, clusterName, namespace, labelSelector string, filter PodFilter, page PodPage, fields PodFields) {
+	stream := &ndjsonStream{c: c}
+
+	if page.SortBy != "" || clusterName == ClusterAll || strings.Contains(namespace, ",") {
+		pods, _, err := listPodPage(c, services, clusterName, namespace, labelSelector, filter, page, gin.H{})
+		services.errorBudget.Record(clusterName, err)
+		if err != nil {
+			stream.fail(err)
+			return
+		}
+		stream.write(pods, fields)
+		return
+	}
+
+	page.Limit = ndjsonPageSize
+	for {
+		pods, next, err := services.podService.GetPodPage(c.Request.Context(), clusterName, namespace, labelSelector, filter, page)
+		services.errorBudget.Record(clusterName, err)
+		if err != nil {
+			stream.fail(err)
+			return
+		}
+		stream.write(pods, fields)
+		if next == "" || c.Request.Context().Err() != nil {
+			return
+		}
+		page.Continue = next
+	}
+}
@@ -0,0 +1,200 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Pod list sort keys.
+const (
+	PodSortName     = "name"
+	PodSortAge      = "age"
+	PodSortRestarts = "restarts"
+	PodSortStatus   = "status"
+	PodSortNode     = "node"
+)
+
+// Sort orders.
+const (
+	SortAscending  = "asc"
+	SortDescending = "desc"
+)
+
+// offsetContinuePrefix marks continue tokens of pages cut from a sorted list in memory, as opposed to the
+// opaque tokens of Kubernetes list pagination.
+const offsetContinuePrefix = "offset:"
+
+var (
+	// ErrInvalidPodPage is returned for unknown sort keys or orders, bad limits and continue tokens that
+	// don't belong to the requested listing.
+	ErrInvalidPodPage = errors.New("invalid pod page")
+	// ErrPodPageExpired is returned when the API server no longer holds the listing a continue token refers
+	// to; the listing has to be started over.
+	ErrPodPageExpired = errors.New("pod page expired")
+)
+
+// PodPage selects the order and page of a pod listing. Without SortBy, pods are listed in API order and
+// pages use Kubernetes list pagination, so only the page is fetched and enriched. Sorted listings are sorted
+// in memory with a stable sort, falling back to namespace and name, and paged by offset. A zero Limit
+// returns every pod.
+type PodPage struct {
+	SortBy   string
+	Order    string
+	Limit    int64
+	Continue string
+}
+
+// ParsePodPage validates the sortBy, order, limit and continue query parameters.
+func ParsePodPage(sortBy, order, limit, continueToken string) (page PodPage, err error) {
+	page = PodPage{SortBy: sortBy, Order: order, Continue: continueToken}
+
+	switch sortBy {
+	case "", PodSortName, PodSortAge, PodSortRestarts, PodSortStatus, PodSortNode:
+	default:
+		err = fmt.Errorf("%w: sortBy must be one of name, age, restarts, status or node", ErrInvalidPodPage)
+		return page, err
+	}
+
+	switch order {
+	case "":
+		page.Order = SortAscending
+	case SortAscending, SortDescending:
+	default:
+		err = fmt.Errorf("%w: order must be asc or desc", ErrInvalidPodPage)
+		return page, err
+	}
+
+	if limit != "" {
+		page.Limit, err = strconv.ParseInt(limit, 10, 64)
+		if err != nil || page.Limit < 0 {
+			err = fmt.Errorf("%w: limit must be a non-negative integer", ErrInvalidPodPage)
+			return page, err
+		}
+	}
+
+	if continueToken != "" && page.Limit == 0 {
+		err = fmt.Errorf("%w: continue requires a limit", ErrInvalidPodPage)
+		return page, err
+	}
+	return page, err
+}
+
+// Paged reports whether the listing is cut into pages.
+func (page PodPage) Paged() (paged bool) {
+	paged = page.Limit > 0
+	return paged
+}
+
+// listsPages reports whether the page is fetched with Kubernetes list pagination rather than cut from the
+// full listing.
+func (page PodPage) listsPages() (lists bool) {
+	lists = page.SortBy == "" && page.Paged()
+	return lists
+}
+
+// listOptions returns the limit and continue token to list pods with. They are empty unless the API server
+// does the paging.
+func (page PodPage) listOptions() (limit int64, continueToken string, err error) {
+	if !page.listsPages() {
+		return limit, continueToken, err
+	}
+	if strings.HasPrefix(page.Continue, offsetContinuePrefix) {
+		err = fmt.Errorf("%w: continue token belongs to a sorted listing", ErrInvalidPodPage)
+		return limit, continueToken, err
+	}
+	limit, continueToken = page.Limit, page.Continue
+	return limit, continueToken, err
+}
+
+// apply returns the page of pods, leaving pods already paged by the API server as they are.
+func (page PodPage) apply(pods []PodInfo, listContinue string) (paged []PodInfo, next string, err error) {
+	if page.listsPages() {
+		paged, next = pods, listContinue
+		return paged, next, err
+	}
+	paged, next, err = page.Cut(pods)
+	return paged, next, err
+}
+
+// Cut sorts pods and cuts the requested page from them by offset, returning the continue token of the next
+// page, empty on the last one. It pages listings the API server can't, such as pods merged from every cluster.
+func (page PodPage) Cut(pods []PodInfo) (paged []PodInfo, next string, err error) {
+	page.sort(pods)
+	if !page.Paged() {
+		paged = pods
+		return paged, next, err
+	}
+
+	var offset int64
+	if page.Continue != "" {
+		offsetText, found := strings.CutPrefix(page.Continue, offsetContinuePrefix)
+		offset, err = strconv.ParseInt(offsetText, 10, 64)
+		if !found || err != nil || offset < 0 {
+			err = fmt.Errorf("%w: continue token doesn't belong to a sorted listing", ErrInvalidPodPage)
+			return paged, next, err
+		}
+	}
+
+	total := int64(len(pods))
+	start := min(offset, total)
+	end := min(start+page.Limit, total)
+	paged = pods[start:end]
+	if end < total {
+		next = offsetContinuePrefix + strconv.FormatInt(end, 10)
+	}
+	return paged, next, err
+}
+
+// sort orders pods by the sort key, keeping the current order of pods that compare equal.
+func (page PodPage) sort(pods []PodInfo) {
+	if page.SortBy == "" {
+		return
+	}
+
+	sort.SliceStable(pods, func(i, j int) (less bool) {
+		a, b := pods[i], pods[j]
+		if page.Order == SortDescending {
+			a, b = b, a
+		}
+
+		switch page.SortBy {
+		case PodSortAge:
+			// Ascending age puts the newest pods first.
+			less = a.created.After(b.created)
+		case PodSortRestarts:
+			less = a.Restarts < b.Restarts
+		case PodSortStatus:
+			less = a.Status < b.Status
+		case PodSortNode:
+			less = a.Node < b.Node
+		default:
+			less = a.Name < b.Name
+		}
+		return less
+	})
+}
@@ -0,0 +1,254 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// maxQueryWorkers bounds how many branches of a pod query run concurrently.
+const maxQueryWorkers = 8
+
+// podBranch is one cluster and namespace a pod query lists.
+type podBranch struct {
+	cluster   string
+	namespace string
+}
+
+// podQueryPlan is a pod listing split into branches. Pods are labelled with their cluster when the plan spans
+// clusters.
+type podQueryPlan struct {
+	branches     []podBranch
+	multiCluster bool
+}
+
+// PodBranchResult reports how one branch of a pod query went.
+type PodBranchResult struct {
+	Cluster    string `json:"cluster,omitempty"`
+	Namespace  string `json:"namespace"`
+	Pods       int    `json:"pods"`
+	DurationMs int64  `json:"durationMs"`
+	Error      string `json:"error,omitempty"`
+	err        error
+}
+
+// PodQueryResult is the assembled outcome of a pod query: the pods every successful branch returned, sorted by
+// cluster, namespace and name, and a report per branch.
+type PodQueryResult struct {
+	Pods     []PodInfo
+	Branches []PodBranchResult
+}
+
+// Failed lists the branches that failed.
+func (result PodQueryResult) Failed() (failed []PodBranchResult) {
+	for _, branch := range result.Branches {
+		if branch.err != nil {
+			failed = append(failed, branch)
+		}
+	}
+	return failed
+}
+
+// QueryPods lists pods across clusters and namespaces: cluster may be ClusterAll, and namespace a comma-separated
+// list of namespaces. Each cluster and namespace is queried as its own branch through a bounded pool of workers,
+// each under its own timeout, and the branches that succeed are merged. err is only set when every branch failed.
+func (ps *PodService) QueryPods(ctx context.Context, clusterName, namespace, labelSelector string, filter PodFilter) (result PodQueryResult, err error) {
+	var plan podQueryPlan
+	plan, err = ps.planPodQuery(clusterName, namespace)
+	if err != nil {
+		return result, err
+	}
+
+	result = ps.runPodQuery(ctx, plan, labelSelector, filter)
+
+	failed := result.Failed()
+	switch {
+	case len(failed) == 1 && len(plan.branches) == 1:
+		err = failed[0].err
+	case len(failed) == len(plan.branches):
+		errs := make([]error, 0, len(failed))
+		for _, branch := range failed {
+			errs = append(errs, branch.err)
+		}
+		err = fmt.Errorf("failed to list pods in all %d queries: %w", len(plan.branches), errors.Join(errs...))
+	}
+	return result, err
+}
+
+// planPodQuery splits a pod listing into one branch per cluster and namespace. ClusterAll expands to every
+// kubeconfig cluster, or the single in-cluster connection.
+func (ps *PodService) planPodQuery(clusterName, namespace string) (plan podQueryPlan, err error) {
+	clusters := []string{clusterName}
+	if clusterName == ClusterAll {
+		clusters = []string{""}
+		if !ps.kubeConfigService.IsInCluster() {
+			clusters, err = ps.kubeConfigClusterNames()
+			if err != nil {
+				return plan, err
+			}
+			plan.multiCluster = true
+		}
+	}
+
+	namespaces := splitNamespaces(namespace)
+	for _, cluster := range clusters {
+		for _, ns := range namespaces {
+			plan.branches = append(plan.branches, podBranch{cluster: cluster, namespace: ns})
+		}
+	}
+	return plan, err
+}
+
+// kubeConfigClusterNames returns the names of the kubeconfig's clusters.
+func (ps *PodService) kubeConfigClusterNames() (names []string, err error) {
+	var clusters []ClusterInfo
+	clusters, err = ps.kubeConfigService.GetClusters()
+	if err != nil {
+		return names, err
+	}
+	if len(clusters) == 0 {
+		err = errors.New("no clusters defined in kubeconfig")
+		return names, err
+	}
+	for _, cluster := range clusters {
+		names = append(names, cluster.Name)
+	}
+	return names, err
+}
+
+// splitNamespaces splits a comma-separated namespace list, dropping blanks and duplicates. A single namespace,
+// including "all", is its own list.
+func splitNamespaces(namespace string) (namespaces []string) {
+	seen := make(map[string]bool)
+	for _, ns := range strings.Split(namespace, ",") {
+		ns = strings.TrimSpace(ns)
+		if ns == "" || seen[ns] {
+			continue
+		}
+		seen[ns] = true
+		namespaces = append(namespaces, ns)
+	}
+	if len(namespaces) == 0 {
+		namespaces = []string{namespace}
+	}
+	return namespaces
+}
+
+// runPodQuery runs a plan's branches through a bounded pool of workers, each branch under its own timeout, and
+// assembles what they return.
+func (ps *PodService) runPodQuery(ctx context.Context, plan podQueryPlan, labelSelector string, filter PodFilter) (result PodQueryResult) {
+	jobs := make(chan podBranch)
+	reports := make(chan PodQueryResult, len(plan.branches))
+
+	var wg sync.WaitGroup
+	for range min(maxQueryWorkers, len(plan.branches)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for branch := range jobs {
+				reports <- ps.runPodBranch(ctx, branch, labelSelector, filter)
+			}
+		}()
+	}
+
+	go func() {
+		for _, branch := range plan.branches {
+			jobs <- branch
+		}
+		close(jobs)
+		wg.Wait()
+		close(reports)
+	}()
+
+	for report := range reports {
+		branch := report.Branches[0]
+		if branch.err != nil {
+			ps.logger.Warn("Failed to list pods", zap.Error(branch.err), zap.String("cluster", branch.Cluster), zap.String("namespace", branch.Namespace))
+		}
+		if plan.multiCluster {
+			for i := range report.Pods {
+				report.Pods[i].Cluster = branch.Cluster
+			}
+		}
+		result.Pods = append(result.Pods, report.Pods...)
+		result.Branches = append(result.Branches, branch)
+	}
+
+	sortPodsByLocation(result.Pods)
+	sort.Slice(result.Branches, func(i, j int) (less bool) {
+		if result.Branches[i].Cluster != result.Branches[j].Cluster {
+			less = result.Branches[i].Cluster < result.Branches[j].Cluster
+			return less
+		}
+		less = result.Branches[i].Namespace < result.Branches[j].Namespace
+		return less
+	})
+	return result
+}
+
+// runPodBranch lists the pods of one branch under clusterQueryTimeout, timing the query.
+func (ps *PodService) runPodBranch(ctx context.Context, branch podBranch, labelSelector string, filter PodFilter) (report PodQueryResult) {
+	branchCtx, cancel := context.WithTimeout(ctx, clusterQueryTimeout)
+	defer cancel()
+
+	start := time.Now()
+	pods, err := ps.GetPods(branchCtx, branch.cluster, branch.namespace, labelSelector, filter)
+	outcome := PodBranchResult{
+		Cluster:    branch.cluster,
+		Namespace:  branch.namespace,
+		DurationMs: time.Since(start).Milliseconds(),
+		err:        err,
+	}
+	if err != nil {
+		outcome.Error = err.Error()
+		pods = nil
+	}
+	outcome.Pods = len(pods)
+
+	report = PodQueryResult{Pods: pods, Branches: []PodBranchResult{outcome}}
+	return report
+}
+
+// sortPodsByLocation sorts pods by cluster, namespace and name.
+func sortPodsByLocation(pods []PodInfo) {
+	sort.SliceStable(pods, func(i, j int) (less bool) {
+		if pods[i].Cluster != pods[j].Cluster {
+			less = pods[i].Cluster < pods[j].Cluster
+			return less
+		}
+		if pods[i].Namespace != pods[j].Namespace {
+			less = pods[i].Namespace < pods[j].Namespace
+			return less
+		}
+		less = pods[i].Name < pods[j].Name
+		return less
+	})
+}
@@ -0,0 +1,472 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Pod Security Standards levels, from the least to the most restrictive.
+const (
+	PodSecurityPrivileged = "privileged"
+	PodSecurityBaseline   = "baseline"
+	PodSecurityRestricted = "restricted"
+)
+
+// Namespace labels setting the Pod Security Standard that pod security admission enforces.
+const (
+	podSecurityEnforceLabel        = "pod-security.kubernetes.io/enforce"
+	podSecurityEnforceVersionLabel = "pod-security.kubernetes.io/enforce-version"
+)
+
+// ErrInvalidPodSecurityLevel is returned for levels other than privileged, baseline and restricted.
+var ErrInvalidPodSecurityLevel = errors.New("invalid pod security level")
+
+// baselineCapabilities are the capabilities the baseline level allows containers to add.
+//
+//nolint:gochecknoglobals // Read-only lookup table
+var baselineCapabilities = []corev1.Capability{
+	"AUDIT_WRITE", "CHOWN", "DAC_OVERRIDE", "FOWNER", "FSETID", "KILL", "MKNOD", "NET_BIND_SERVICE",
+	"SETFCAP", "SETGID", "SETPCAP", "SETUID", "SYS_CHROOT",
+}
+
+// safeSysctls are the sysctls the baseline level allows.
+//
+//nolint:gochecknoglobals // Read-only lookup table
+var safeSysctls = []string{
+	"kernel.shm_rmid_forced", "net.ipv4.ip_local_port_range", "net.ipv4.ip_unprivileged_port_start",
+	"net.ipv4.tcp_syncookies", "net.ipv4.ping_group_range", "net.ipv4.ip_local_reserved_ports",
+	"net.ipv4.tcp_keepalive_time", "net.ipv4.tcp_fin_timeout", "net.ipv4.tcp_keepalive_intvl",
+	"net.ipv4.tcp_keepalive_probes",
+}
+
+// baselineSELinuxTypes are the SELinux types the baseline level allows.
+//
+//nolint:gochecknoglobals // Read-only lookup table
+var baselineSELinuxTypes = []string{"", "container_t", "container_init_t", "container_kvm_t", "container_engine_t"}
+
+// NamespacePodSecurity reports the pods of a namespace that violate the Pod Security Standard it enforces, or
+// the level asked for to preview tightening it. Those pods keep running, but pod security admission rejects
+// their replacements: the next rollout, reschedule or restart of their workload fails.
+type NamespacePodSecurity struct {
+	Namespace string `json:"namespace"`
+	// Enforce and EnforceVersion are the namespace's labels, empty when it enforces nothing.
+	Enforce        string `json:"enforce,omitempty"`
+	EnforceVersion string `json:"enforceVersion,omitempty"`
+	// Level is the level the pods were checked against.
+	Level    string               `json:"level"`
+	Pods     int                  `json:"pods"`
+	Rejected []PodSecurityFinding `json:"rejected"`
+}
+
+// PodSecurityFinding is a pod that the level rejects, with its workload and the checks it fails.
+type PodSecurityFinding struct {
+	Name       string                 `json:"name"`
+	OwnerKind  string                 `json:"ownerKind,omitempty"`
+	OwnerName  string                 `json:"ownerName,omitempty"`
+	Violations []PodSecurityViolation `json:"violations"`
+}
+
+// PodSecurityViolation is a failed check, named as in pod security admission, e.g. runAsNonRoot.
+type PodSecurityViolation struct {
+	Check  string `json:"check"`
+	Level  string `json:"level"`
+	Detail string `json:"detail"`
+}
+
+// securedContainer is the part of an init, app or ephemeral container the checks look at.
+type securedContainer struct {
+	name            string
+	securityContext *corev1.SecurityContext
+	ports           []corev1.ContainerPort
+}
+
+// GetPodSecurity checks the running pods of a namespace, a comma-separated list of namespaces or all namespaces
+// against the level each namespace enforces, or against level for all of them when it is set. Namespaces
+// without an enforce label are only checked with level. An invalid enforce label is treated as restricted, as
+// pod security admission does. Exemptions in the admission configuration aren't known and aren't applied.
+func (ps *PodService) GetPodSecurity(ctx context.Context, clusterName, namespace, level string) (reports []NamespacePodSecurity, err error) {
+	if level != "" && !validPodSecurityLevel(level) {
+		err = fmt.Errorf("%w %q: use privileged, baseline or restricted", ErrInvalidPodSecurityLevel, level)
+		return reports, err
+	}
+
+	err = ps.namespacePolicy.Check(namespace)
+	if err != nil {
+		return reports, err
+	}
+
+	var client kubernetes.Interface
+	client, err = ps.getClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return reports, err
+	}
+
+	var namespaces []corev1.Namespace
+	namespaces, err = podSecurityNamespaces(ctx, client, namespace)
+	if err != nil {
+		ps.logger.Error("Failed to read namespaces", zap.Error(err), zap.String("cluster", clusterName), zap.String("namespace", namespace))
+		return reports, err
+	}
+
+	reports = make([]NamespacePodSecurity, 0, len(namespaces))
+	for _, ns := range namespaces {
+		if !ps.namespacePolicy.Allows(ns.Name) {
+			continue
+		}
+		report := NamespacePodSecurity{
+			Namespace:      ns.Name,
+			Enforce:        ns.Labels[podSecurityEnforceLabel],
+			EnforceVersion: ns.Labels[podSecurityEnforceVersionLabel],
+			Level:          level,
+			Rejected:       make([]PodSecurityFinding, 0),
+		}
+		if report.Level == "" {
+			report.Level = enforcedPodSecurityLevel(report.Enforce)
+		}
+
+		err = ps.checkNamespacePodSecurity(ctx, client, &report)
+		if err != nil {
+			ps.logger.Error("Failed to list pods", zap.Error(err), zap.String("cluster", clusterName), zap.String("namespace", ns.Name))
+			return reports, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, err
+}
+
+// podSecurityNamespaces reads the namespaces to check, sorted by name.
+func podSecurityNamespaces(ctx context.Context, client kubernetes.Interface, namespace string) (namespaces []corev1.Namespace, err error) {
+	if namespace == "all" {
+		var list *corev1.NamespaceList
+		list, err = client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			err = fmt.Errorf("failed to list namespaces: %w", err)
+			return namespaces, err
+		}
+		namespaces = list.Items
+	} else {
+		for _, name := range splitNamespaces(namespace) {
+			var ns *corev1.Namespace
+			ns, err = client.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				err = fmt.Errorf("failed to get namespace %q: %w", name, err)
+				return namespaces, err
+			}
+			namespaces = append(namespaces, *ns)
+		}
+	}
+
+	sort.Slice(namespaces, func(i, j int) (less bool) {
+		less = namespaces[i].Name < namespaces[j].Name
+		return less
+	})
+	return namespaces, err
+}
+
+// checkNamespacePodSecurity checks the namespace's running pods against the report's level.
+func (ps *PodService) checkNamespacePodSecurity(ctx context.Context, client kubernetes.Interface, report *NamespacePodSecurity) (err error) {
+	if report.Level == PodSecurityPrivileged {
+		return err
+	}
+
+	var podList *corev1.PodList
+	podList, err = client.CoreV1().Pods(report.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		err = fmt.Errorf("failed to list pods: %w", err)
+		return err
+	}
+
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		report.Pods++
+
+		violations := podSecurityViolations(pod, report.Level)
+		if len(violations) == 0 {
+			continue
+		}
+		finding := PodSecurityFinding{Name: pod.Name, Violations: violations}
+		finding.OwnerKind, finding.OwnerName = podOwner(pod)
+		report.Rejected = append(report.Rejected, finding)
+	}
+
+	sort.Slice(report.Rejected, func(i, j int) (less bool) {
+		less = report.Rejected[i].Name < report.Rejected[j].Name
+		return less
+	})
+	return err
+}
+
+// validPodSecurityLevel returns true for the three Pod Security Standards levels.
+func validPodSecurityLevel(level string) (valid bool) {
+	valid = level == PodSecurityPrivileged || level == PodSecurityBaseline || level == PodSecurityRestricted
+	return valid
+}
+
+// enforcedPodSecurityLevel returns the level an enforce label sets: privileged when there is none, and
+// restricted for values pod security admission doesn't recognize.
+func enforcedPodSecurityLevel(label string) (level string) {
+	switch {
+	case label == "":
+		level = PodSecurityPrivileged
+	case validPodSecurityLevel(label):
+		level = label
+	default:
+		level = PodSecurityRestricted
+	}
+	return level
+}
+
+// podSecurityViolations returns the checks of the level, and the levels below it, that the pod fails.
+func podSecurityViolations(pod *corev1.Pod, level string) (violations []PodSecurityViolation) {
+	if level == PodSecurityPrivileged {
+		return violations
+	}
+
+	violations = baselineViolations(pod)
+	if level == PodSecurityRestricted {
+		violations = append(violations, restrictedViolations(pod)...)
+	}
+	return violations
+}
+
+// podSecuredContainers returns the pod's init, app and ephemeral containers.
+func podSecuredContainers(pod *corev1.Pod) (containers []securedContainer) {
+	for _, container := range slices.Concat(pod.Spec.InitContainers, pod.Spec.Containers) {
+		containers = append(containers, securedContainer{container.Name, container.SecurityContext, container.Ports})
+	}
+	for _, container := range pod.Spec.EphemeralContainers {
+		containers = append(containers, securedContainer{container.Name, container.SecurityContext, container.Ports})
+	}
+	return containers
+}
+
+// baselineViolations returns the baseline checks the pod fails: those preventing known privilege escalations.
+func baselineViolations(pod *corev1.Pod) (violations []PodSecurityViolation) {
+	violation := func(check, detail string, args ...interface{}) {
+		violations = append(violations, PodSecurityViolation{Check: check, Level: PodSecurityBaseline, Detail: fmt.Sprintf(detail, args...)})
+	}
+	spec, podContext := pod.Spec, pod.Spec.SecurityContext
+	if podContext == nil {
+		podContext = &corev1.PodSecurityContext{}
+	}
+
+	if spec.HostNetwork {
+		violation("hostNamespaces", "pod sets hostNetwork=true")
+	}
+	if spec.HostPID {
+		violation("hostNamespaces", "pod sets hostPID=true")
+	}
+	if spec.HostIPC {
+		violation("hostNamespaces", "pod sets hostIPC=true")
+	}
+	if windows := podContext.WindowsOptions; windows != nil && windows.HostProcess != nil && *windows.HostProcess {
+		violation("hostProcess", "pod sets windowsOptions.hostProcess=true")
+	}
+	for _, volume := range spec.Volumes {
+		if volume.HostPath != nil {
+			violation("hostPathVolumes", "volume %s mounts host path %s", volume.Name, volume.HostPath.Path)
+		}
+	}
+	for _, sysctl := range podContext.Sysctls {
+		if !slices.Contains(safeSysctls, sysctl.Name) {
+			violation("sysctls", "pod sets the unsafe sysctl %s", sysctl.Name)
+		}
+	}
+	if podContext.SeccompProfile != nil && podContext.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+		violation("seccompProfile_baseline", "pod sets seccompProfile.type=Unconfined")
+	}
+	if podContext.AppArmorProfile != nil && podContext.AppArmorProfile.Type == corev1.AppArmorProfileTypeUnconfined {
+		violation("appArmorProfile", "pod sets appArmorProfile.type=Unconfined")
+	}
+	if detail := seLinuxViolation(podContext.SELinuxOptions); detail != "" {
+		violation("seLinuxOptions", "pod %s", detail)
+	}
+	for key, value := range pod.Annotations {
+		if container, found := strings.CutPrefix(key, corev1.DeprecatedAppArmorBetaContainerAnnotationKeyPrefix); found &&
+			value != corev1.DeprecatedAppArmorBetaProfileRuntimeDefault && !strings.HasPrefix(value, corev1.DeprecatedAppArmorBetaProfileNamePrefix) {
+			violation("appArmorProfile", "container %s has the AppArmor profile %s", container, value)
+		}
+	}
+
+	for _, container := range podSecuredContainers(pod) {
+		violations = append(violations, containerBaselineViolations(container)...)
+	}
+	sortViolations(violations)
+	return violations
+}
+
+// containerBaselineViolations returns the baseline checks a container fails.
+func containerBaselineViolations(container securedContainer) (violations []PodSecurityViolation) {
+	violation := func(check, detail string, args ...interface{}) {
+		detail = "container " + container.name + " " + fmt.Sprintf(detail, args...)
+		violations = append(violations, PodSecurityViolation{Check: check, Level: PodSecurityBaseline, Detail: detail})
+	}
+
+	for _, port := range container.ports {
+		if port.HostPort != 0 {
+			violation("hostPorts", "uses host port %d", port.HostPort)
+		}
+	}
+
+	sc := container.securityContext
+	if sc == nil {
+		return violations
+	}
+	if sc.Privileged != nil && *sc.Privileged {
+		violation("privileged", "sets privileged=true")
+	}
+	if sc.WindowsOptions != nil && sc.WindowsOptions.HostProcess != nil && *sc.WindowsOptions.HostProcess {
+		violation("hostProcess", "sets windowsOptions.hostProcess=true")
+	}
+	if sc.Capabilities != nil {
+		for _, capability := range sc.Capabilities.Add {
+			if !slices.Contains(baselineCapabilities, capability) {
+				violation("capabilities_baseline", "adds the %s capability", capability)
+			}
+		}
+	}
+	if sc.ProcMount != nil && *sc.ProcMount != corev1.DefaultProcMount {
+		violation("procMount", "sets procMount=%s", *sc.ProcMount)
+	}
+	if sc.SeccompProfile != nil && sc.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+		violation("seccompProfile_baseline", "sets seccompProfile.type=Unconfined")
+	}
+	if sc.AppArmorProfile != nil && sc.AppArmorProfile.Type == corev1.AppArmorProfileTypeUnconfined {
+		violation("appArmorProfile", "sets appArmorProfile.type=Unconfined")
+	}
+	if detail := seLinuxViolation(sc.SELinuxOptions); detail != "" {
+		violation("seLinuxOptions", "%s", detail)
+	}
+	return violations
+}
+
+// seLinuxViolation describes SELinux options the baseline level forbids, or returns an empty string.
+func seLinuxViolation(options *corev1.SELinuxOptions) (detail string) {
+	switch {
+	case options == nil:
+	case !slices.Contains(baselineSELinuxTypes, options.Type):
+		detail = "sets the SELinux type " + options.Type
+	case options.User != "" || options.Role != "":
+		detail = "sets an SELinux user or role"
+	}
+	return detail
+}
+
+// restrictedViolations returns the restricted checks the pod fails: the pod hardening best practices. Windows
+// pods are exempt from the Linux-only checks, as in pod security admission.
+func restrictedViolations(pod *corev1.Pod) (violations []PodSecurityViolation) {
+	violation := func(check, detail string, args ...interface{}) {
+		violations = append(violations, PodSecurityViolation{Check: check, Level: PodSecurityRestricted, Detail: fmt.Sprintf(detail, args...)})
+	}
+	podContext := pod.Spec.SecurityContext
+	if podContext == nil {
+		podContext = &corev1.PodSecurityContext{}
+	}
+	linux := pod.Spec.OS == nil || pod.Spec.OS.Name != corev1.Windows
+
+	for _, volume := range pod.Spec.Volumes {
+		if !restrictedVolume(volume.VolumeSource) {
+			violation("restrictedVolumes", "volume %s is of a type other than configMap, csi, downwardAPI, emptyDir, ephemeral, persistentVolumeClaim, projected or secret", volume.Name)
+		}
+	}
+	if podContext.RunAsUser != nil && *podContext.RunAsUser == 0 {
+		violation("runAsUser", "pod sets runAsUser=0")
+	}
+
+	podNonRoot := podContext.RunAsNonRoot != nil && *podContext.RunAsNonRoot
+	podSeccomp := podContext.SeccompProfile != nil && restrictedSeccomp(podContext.SeccompProfile.Type)
+	for _, container := range podSecuredContainers(pod) {
+		sc := container.securityContext
+		if sc == nil {
+			sc = &corev1.SecurityContext{}
+		}
+		if (sc.RunAsNonRoot != nil && !*sc.RunAsNonRoot) || (sc.RunAsNonRoot == nil && !podNonRoot) {
+			violation("runAsNonRoot", "container %s must set runAsNonRoot=true, or the pod must", container.name)
+		}
+		if sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+			violation("runAsUser", "container %s sets runAsUser=0", container.name)
+		}
+		if !linux {
+			continue
+		}
+		if sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+			violation("allowPrivilegeEscalation", "container %s must set allowPrivilegeEscalation=false", container.name)
+		}
+		if (sc.SeccompProfile != nil && !restrictedSeccomp(sc.SeccompProfile.Type)) || (sc.SeccompProfile == nil && !podSeccomp) {
+			violation("seccompProfile_restricted", "container %s must set seccompProfile.type to RuntimeDefault or Localhost, or the pod must", container.name)
+		}
+		if sc.Capabilities == nil || !slices.Contains(sc.Capabilities.Drop, "ALL") {
+			violation("capabilities_restricted", "container %s must drop ALL capabilities", container.name)
+		}
+		if sc.Capabilities != nil {
+			for _, capability := range sc.Capabilities.Add {
+				if capability != "NET_BIND_SERVICE" {
+					violation("capabilities_restricted", "container %s adds the %s capability", container.name, capability)
+				}
+			}
+		}
+	}
+	sortViolations(violations)
+	return violations
+}
+
+// restrictedVolume returns true for the volume types the restricted level allows.
+func restrictedVolume(source corev1.VolumeSource) (allowed bool) {
+	allowed = source.ConfigMap != nil || source.CSI != nil || source.DownwardAPI != nil || source.EmptyDir != nil ||
+		source.Ephemeral != nil || source.PersistentVolumeClaim != nil || source.Projected != nil || source.Secret != nil
+	return allowed
+}
+
+// restrictedSeccomp returns true for the seccomp profile types the restricted level allows.
+func restrictedSeccomp(profileType corev1.SeccompProfileType) (allowed bool) {
+	allowed = profileType == corev1.SeccompProfileTypeRuntimeDefault || profileType == corev1.SeccompProfileTypeLocalhost
+	return allowed
+}
+
+// sortViolations orders violations by check and detail.
+func sortViolations(violations []PodSecurityViolation) {
+	sort.Slice(violations, func(i, j int) (less bool) {
+		a, b := violations[i], violations[j]
+		if a.Check != b.Check {
+			less = a.Check < b.Check
+			return less
+		}
+		less = a.Detail < b.Detail
+		return less
+	})
+}
@@ -0,0 +1,209 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Pod stream event types.
+const (
+	PodStreamAdded    = "added"
+	PodStreamModified = "modified"
+	PodStreamDeleted  = "deleted"
+	// PodStreamBookmark carries only a resource version to resume from.
+	PodStreamBookmark = "bookmark"
+	// PodStreamReset tells clients to drop the pods they have; added events for the current pods follow.
+	PodStreamReset = "reset"
+)
+
+// PodStreamEvent is a change to the pods of a stream. Pod is nil for bookmarks and resets.
+type PodStreamEvent struct {
+	Type            string   `json:"type"`
+	ResourceVersion string   `json:"resourceVersion"`
+	Pod             *PodInfo `json:"pod,omitempty"`
+}
+
+// WatchPods streams the pods in a namespace matching a label selector, which may use the =~ and !~ regex
+// operators, to the handler until the context is cancelled. Without a resource version it starts with an added event for
+// every current pod followed by a bookmark; with one it resumes from there. When the resource version is too
+// old to resume from, a reset is sent followed by the current pods. Bookmarks are passed on so clients can
+// resume from a recent resource version even when nothing changes.
+func (ps *PodService) WatchPods(ctx context.Context, clusterName, namespace, labelSelector, resourceVersion string, handler func(PodStreamEvent)) (err error) {
+	var selector podSelector
+	selector, err = parsePodSelector(labelSelector)
+	if err != nil {
+		return err
+	}
+
+	err = ps.namespacePolicy.Check(namespace)
+	if err != nil {
+		return err
+	}
+
+	var client kubernetes.Interface
+	client, err = ps.getClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return err
+	}
+
+	queryNamespace := namespace
+	if namespace == "all" {
+		queryNamespace = ""
+	}
+
+	for ctx.Err() == nil {
+		if resourceVersion == "" {
+			resourceVersion, err = ps.sendPodSnapshot(ctx, client, queryNamespace, selector, handler)
+			if err != nil {
+				return err
+			}
+		}
+
+		resourceVersion, err = ps.watchPodsFrom(ctx, client, queryNamespace, selector, resourceVersion, handler)
+		if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+			handler(PodStreamEvent{Type: PodStreamReset})
+			resourceVersion, err = "", nil
+			continue
+		}
+		if err != nil {
+			ps.logger.Error("Failed to watch pods", zap.Error(err), zap.String("cluster", clusterName), zap.String("namespace", namespace))
+			return err
+		}
+	}
+	return err
+}
+
+// sendPodSnapshot sends an added event for every matching pod and a bookmark with the list's resource version.
+func (ps *PodService) sendPodSnapshot(ctx context.Context, client kubernetes.Interface, namespace string, selector podSelector, handler func(PodStreamEvent)) (resourceVersion string, err error) {
+	var pods *corev1.PodList
+	pods, err = client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.standard})
+	if err != nil {
+		err = fmt.Errorf("failed to list pods: %w", err)
+		return resourceVersion, err
+	}
+
+	view := timeViewFromContext(ctx)
+	extended := extendedPodDetailFromContext(ctx)
+	for i := range pods.Items {
+		if selector.Matches(pods.Items[i].Labels) && ps.namespacePolicy.Allows(pods.Items[i].Namespace) {
+			podInfo := ps.podToPodInfo(&pods.Items[i], view)
+			if extended {
+				setExtendedPodDetail(&podInfo, &pods.Items[i])
+			}
+			handler(PodStreamEvent{Type: PodStreamAdded, ResourceVersion: pods.Items[i].ResourceVersion, Pod: &podInfo})
+		}
+	}
+
+	resourceVersion = pods.ResourceVersion
+	handler(PodStreamEvent{Type: PodStreamBookmark, ResourceVersion: resourceVersion})
+	return resourceVersion, err
+}
+
+// watchPodsFrom watches pods from a resource version until the API server closes the watch, returning the
+// resource version to resume from.
+func (ps *PodService) watchPodsFrom(ctx context.Context, client kubernetes.Interface, namespace string, selector podSelector, resourceVersion string, handler func(PodStreamEvent)) (lastVersion string, err error) {
+	lastVersion = resourceVersion
+	options := metav1.ListOptions{LabelSelector: selector.standard, ResourceVersion: resourceVersion, AllowWatchBookmarks: true}
+
+	var watcher watch.Interface
+	watcher, err = client.CoreV1().Pods(namespace).Watch(ctx, options)
+	if err != nil {
+		err = fmt.Errorf("failed to watch pods: %w", err)
+		return lastVersion, err
+	}
+	defer watcher.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return lastVersion, err
+		case watchEvent, ok := <-watcher.ResultChan():
+			if !ok {
+				return lastVersion, err
+			}
+			if watchEvent.Type == watch.Error {
+				err = apierrors.FromObject(watchEvent.Object)
+				return lastVersion, err
+			}
+			pod, isPod := watchEvent.Object.(*corev1.Pod)
+			if !isPod {
+				continue
+			}
+			lastVersion = pod.ResourceVersion
+			if watchEvent.Type != watch.Bookmark && !ps.namespacePolicy.Allows(pod.Namespace) {
+				continue
+			}
+			ps.sendPodWatchEvent(watchEvent.Type, pod, selector, timeViewFromContext(ctx), extendedPodDetailFromContext(ctx), handler)
+		}
+	}
+}
+
+// sendPodWatchEvent passes a watch event on to the handler as a stream event, with the PodDetailExtended fields
+// if extended.
+func (ps *PodService) sendPodWatchEvent(eventType watch.EventType, pod *corev1.Pod, selector podSelector, view timeView, extended bool, handler func(PodStreamEvent)) {
+	streamType := ""
+	switch eventType {
+	case watch.Added:
+		streamType = PodStreamAdded
+	case watch.Modified:
+		streamType = PodStreamModified
+	case watch.Deleted:
+		streamType = PodStreamDeleted
+	case watch.Bookmark:
+		handler(PodStreamEvent{Type: PodStreamBookmark, ResourceVersion: pod.ResourceVersion})
+		return
+	case watch.Error:
+		return
+	}
+
+	// The API server has already applied the standard requirements.
+	if !selector.Matches(pod.Labels) {
+		return
+	}
+	podInfo := ps.podToPodInfo(pod, view)
+	if extended {
+		setExtendedPodDetail(&podInfo, pod)
+	}
+	handler(PodStreamEvent{Type: streamType, ResourceVersion: pod.ResourceVersion, Pod: &podInfo})
+}
+
+// resumeResourceVersion returns the resource version a stream resumes from: the Last-Event-ID header browsers
+// send when an EventSource reconnects, or the resourceVersion query parameter.
+func resumeResourceVersion(request *http.Request) (resourceVersion string) {
+	resourceVersion = request.Header.Get("Last-Event-ID")
+	if resourceVersion == "" {
+		resourceVersion = request.URL.Query().Get("resourceVersion")
+	}
+	return resourceVersion
+}
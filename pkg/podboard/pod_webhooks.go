@@ -0,0 +1,416 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Pod transitions podboard sends webhooks for.
+const (
+	// PodWebhookReady is sent the first time a pod becomes ready.
+	PodWebhookReady = "ready"
+	// PodWebhookCrashLoop is sent when a pod enters CrashLoopBackOff.
+	PodWebhookCrashLoop = "crashLoopBackOff"
+	// PodWebhookDeleted is sent when a pod is deleted.
+	PodWebhookDeleted = "deleted"
+)
+
+// Headers sent with pod webhooks.
+const (
+	// PodWebhookEventHeader names the transition a webhook is sent for.
+	PodWebhookEventHeader = "X-Podboard-Event"
+	// PodWebhookSignatureHeader carries the HMAC-SHA256 of the body, as sha256=<hex>, keyed with the webhook's secret.
+	PodWebhookSignatureHeader = "X-Podboard-Signature-256"
+)
+
+const (
+	podWebhookHTTPTimeout = 10 * time.Second
+	podWebhookAttempts    = 3
+	podWebhookRetryDelay  = 2 * time.Second
+	// podWebhookQueueSize is how many webhooks may wait for delivery before new ones are dropped.
+	podWebhookQueueSize = 100
+	// podWebhookRewatchDelay is how long to wait before watching again after a watch fails.
+	podWebhookRewatchDelay = 10 * time.Second
+)
+
+// PodWebhookConfig is an outbound webhook sent when matching pods go through the configured transitions.
+type PodWebhookConfig struct {
+	// Name identifies the webhook in logs and in the X-Podboard-Webhook header.
+	Name string `yaml:"name"`
+	// URL receives a POST for every transition.
+	URL string `yaml:"url"`
+	// SecretEnv names the environment variable holding the key the body is signed with.
+	SecretEnv string `yaml:"secretEnv"`
+	// Events are the transitions to send: ready, crashLoopBackOff and deleted (default: all of them).
+	Events []string `yaml:"events"`
+	// Cluster is the cluster whose pods are watched (default: current context).
+	Cluster string `yaml:"cluster"`
+	// Namespace limits the webhook to one namespace (default: all).
+	Namespace string `yaml:"namespace"`
+	// LabelSelector limits the webhook to matching pods. The =~ and !~ regex operators are supported.
+	LabelSelector string `yaml:"labelSelector"`
+}
+
+// PodWebhookPayload is the JSON body of a pod webhook.
+type PodWebhookPayload struct {
+	Event     string  `json:"event"`
+	Webhook   string  `json:"webhook"`
+	Cluster   string  `json:"cluster,omitempty"`
+	Timestamp string  `json:"timestamp"`
+	Pod       PodInfo `json:"pod"`
+}
+
+// PodWebhookService watches pods and sends webhooks on their transitions, so external automation such as
+// cache warmers and DNS updaters can react to cluster changes.
+type PodWebhookService struct {
+	config     ServerConfig
+	webhooks   []PodWebhookConfig
+	podService *PodService
+	httpClient *http.Client
+	logger     *zap.Logger
+}
+
+// NewPodWebhookService validates the webhook configuration and creates a new pod webhook service.
+func NewPodWebhookService(config ServerConfig, webhooks []PodWebhookConfig, podService *PodService, logger *zap.Logger) (service *PodWebhookService, err error) {
+	service = &PodWebhookService{
+		config:     config,
+		podService: podService,
+		httpClient: &http.Client{Timeout: podWebhookHTTPTimeout},
+		logger:     logger,
+	}
+
+	names := make(map[string]bool, len(webhooks))
+	for _, webhook := range webhooks {
+		err = validatePodWebhook(webhook)
+		if err != nil {
+			return service, err
+		}
+		if names[webhook.Name] {
+			err = fmt.Errorf("pod webhook %q: duplicate name", webhook.Name)
+			return service, err
+		}
+		names[webhook.Name] = true
+
+		if len(webhook.Events) == 0 {
+			webhook.Events = []string{PodWebhookReady, PodWebhookCrashLoop, PodWebhookDeleted}
+		}
+		if webhook.Namespace == "" {
+			webhook.Namespace = "all"
+		}
+		service.webhooks = append(service.webhooks, webhook)
+	}
+
+	return service, err
+}
+
+// validatePodWebhook checks a webhook's URL, secret, events and label selector.
+func validatePodWebhook(webhook PodWebhookConfig) (err error) {
+	if webhook.Name == "" {
+		err = errors.New("pod webhook: name is required")
+		return err
+	}
+
+	target, parseErr := url.Parse(webhook.URL)
+	if parseErr != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		err = fmt.Errorf("pod webhook %q: url must be an http or https URL", webhook.Name)
+		return err
+	}
+	if webhook.SecretEnv == "" {
+		err = fmt.Errorf("pod webhook %q: secretEnv is required", webhook.Name)
+		return err
+	}
+
+	for _, event := range webhook.Events {
+		if event != PodWebhookReady && event != PodWebhookCrashLoop && event != PodWebhookDeleted {
+			err = fmt.Errorf("pod webhook %q: event must be %q, %q or %q, got %q", webhook.Name, PodWebhookReady, PodWebhookCrashLoop, PodWebhookDeleted, event)
+			return err
+		}
+	}
+
+	_, err = parsePodSelector(webhook.LabelSelector)
+	if err != nil {
+		err = fmt.Errorf("pod webhook %q: %w", webhook.Name, err)
+		return err
+	}
+	return err
+}
+
+// Run watches the pods of every webhook and sends their transitions until the context is cancelled. In
+// offline mode no webhooks are sent.
+func (pws *PodWebhookService) Run(ctx context.Context) {
+	if len(pws.webhooks) == 0 {
+		return
+	}
+	err := pws.config.CheckOutbound("pod webhooks")
+	if err != nil {
+		pws.logger.Warn("Pod webhooks are not sent", zap.Error(err))
+		return
+	}
+
+	for _, webhook := range pws.webhooks {
+		queue := make(chan PodWebhookPayload, podWebhookQueueSize)
+		go pws.deliver(ctx, webhook, queue)
+		go pws.watch(ctx, webhook, queue)
+	}
+}
+
+// watch follows a webhook's pods, queueing a payload for each transition. Failed watches are retried, and
+// transitions that happened in between are sent once the pods have been listed again.
+func (pws *PodWebhookService) watch(ctx context.Context, webhook PodWebhookConfig, queue chan<- PodWebhookPayload) {
+	transitions := newPodTransitions()
+	handler := func(event PodStreamEvent) {
+		for _, transition := range transitions.observe(event) {
+			if !slices.Contains(webhook.Events, transition.event) {
+				continue
+			}
+			payload := PodWebhookPayload{
+				Event:     transition.event,
+				Webhook:   webhook.Name,
+				Cluster:   webhook.Cluster,
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+				Pod:       transition.pod,
+			}
+			select {
+			case queue <- payload:
+			default:
+				pws.logger.Warn("Dropped pod webhook, delivery queue is full", zap.String("webhook", webhook.Name), zap.String("event", payload.Event), zap.String("namespace", payload.Pod.Namespace), zap.String("pod", payload.Pod.Name))
+			}
+		}
+	}
+
+	for ctx.Err() == nil {
+		transitions.resync()
+		err := pws.podService.WatchPods(ctx, webhook.Cluster, webhook.Namespace, webhook.LabelSelector, "", handler)
+		if err != nil {
+			pws.logger.Warn("Pod webhook watch failed, retrying", zap.Error(err), zap.String("webhook", webhook.Name))
+		}
+		sleepContext(ctx, podWebhookRewatchDelay)
+	}
+}
+
+// deliver sends a webhook's queued payloads in order, retrying failed deliveries a few times.
+func (pws *PodWebhookService) deliver(ctx context.Context, webhook PodWebhookConfig, queue <-chan PodWebhookPayload) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case payload := <-queue:
+			pws.send(ctx, webhook, payload)
+		}
+	}
+}
+
+// send posts a payload, retrying with a growing delay until it is accepted or the attempts run out.
+func (pws *PodWebhookService) send(ctx context.Context, webhook PodWebhookConfig, payload PodWebhookPayload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		pws.logger.Error("Failed to encode pod webhook", zap.Error(err), zap.String("webhook", webhook.Name))
+		return
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = pws.post(ctx, webhook, payload.Event, body)
+		if err == nil {
+			pws.logger.Debug("Sent pod webhook", zap.String("webhook", webhook.Name), zap.String("event", payload.Event), zap.String("namespace", payload.Pod.Namespace), zap.String("pod", payload.Pod.Name))
+			return
+		}
+		if attempt == podWebhookAttempts {
+			pws.logger.Error("Failed to send pod webhook", zap.Error(err), zap.String("webhook", webhook.Name), zap.String("event", payload.Event), zap.String("namespace", payload.Pod.Namespace), zap.String("pod", payload.Pod.Name))
+			return
+		}
+		if !sleepContext(ctx, time.Duration(attempt)*podWebhookRetryDelay) {
+			return
+		}
+	}
+}
+
+// post signs and sends one webhook body.
+func (pws *PodWebhookService) post(ctx context.Context, webhook PodWebhookConfig, event string, body []byte) (err error) {
+	secret := os.Getenv(webhook.SecretEnv)
+	if secret == "" {
+		err = fmt.Errorf("%s is not set", webhook.SecretEnv)
+		return err
+	}
+
+	var request *http.Request
+	request, err = http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		err = fmt.Errorf("failed to create webhook request: %w", err)
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(PodWebhookEventHeader, event)
+	request.Header.Set("X-Podboard-Webhook", webhook.Name)
+	request.Header.Set(PodWebhookSignatureHeader, "sha256="+signPodWebhook(secret, body))
+
+	var response *http.Response
+	response, err = pws.httpClient.Do(request)
+	if err != nil {
+		err = fmt.Errorf("failed to send webhook: %w", err)
+		return err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode >= http.StatusMultipleChoices {
+		err = fmt.Errorf("webhook returned %s", response.Status)
+		return err
+	}
+	return err
+}
+
+// signPodWebhook returns the hex HMAC-SHA256 of a webhook body.
+func signPodWebhook(secret string, body []byte) (signature string) {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	signature = hex.EncodeToString(mac.Sum(nil))
+	return signature
+}
+
+// podTransition is a transition a pod went through.
+type podTransition struct {
+	event string
+	pod   PodInfo
+}
+
+// trackedPod is what podTransitions remembers about a pod.
+type trackedPod struct {
+	pod          PodInfo
+	everReady    bool
+	crashLooping bool
+}
+
+// podTransitions turns a pod stream into transitions. Pods listed when a stream starts are recorded without
+// transitions, except for pods already known from before a resync, which are compared with what was known.
+type podTransitions struct {
+	pods map[string]*trackedPod
+	// listing is true until the bookmark ending a stream's initial pods.
+	listing bool
+	// listed holds the pods seen while listing, to find those deleted while not watching.
+	listed map[string]bool
+}
+
+func newPodTransitions() (transitions *podTransitions) {
+	transitions = &podTransitions{pods: map[string]*trackedPod{}}
+	return transitions
+}
+
+// resync prepares for a stream that starts by listing the current pods again.
+func (pt *podTransitions) resync() {
+	pt.listing = true
+	pt.listed = map[string]bool{}
+}
+
+// observe records a stream event and returns the transitions it shows.
+func (pt *podTransitions) observe(event PodStreamEvent) (transitions []podTransition) {
+	switch event.Type {
+	case PodStreamReset:
+		pt.resync()
+	case PodStreamBookmark:
+		if pt.listing {
+			transitions = pt.finishListing()
+		}
+	case PodStreamDeleted:
+		key := event.Pod.Namespace + "/" + event.Pod.Name
+		delete(pt.pods, key)
+		transitions = append(transitions, podTransition{event: PodWebhookDeleted, pod: *event.Pod})
+	case PodStreamAdded, PodStreamModified:
+		transitions = pt.update(*event.Pod)
+	}
+	return transitions
+}
+
+// finishListing ends a listing, returning deleted transitions for known pods that weren't listed.
+func (pt *podTransitions) finishListing() (transitions []podTransition) {
+	for key, tracked := range pt.pods {
+		if !pt.listed[key] {
+			delete(pt.pods, key)
+			transitions = append(transitions, podTransition{event: PodWebhookDeleted, pod: tracked.pod})
+		}
+	}
+	pt.listing = false
+	pt.listed = nil
+	return transitions
+}
+
+// update records a pod's latest state and returns the transitions since the last one.
+func (pt *podTransitions) update(pod PodInfo) (transitions []podTransition) {
+	key := pod.Namespace + "/" + pod.Name
+	ready := podIsReady(pod)
+	crashLooping := podCrashLooping(pod)
+
+	tracked, known := pt.pods[key]
+	if pt.listing {
+		pt.listed[key] = true
+	}
+	if !known {
+		tracked = &trackedPod{}
+		pt.pods[key] = tracked
+		if pt.listing {
+			tracked.everReady = ready
+			tracked.crashLooping = crashLooping
+		}
+	}
+
+	if ready && !tracked.everReady {
+		transitions = append(transitions, podTransition{event: PodWebhookReady, pod: pod})
+	}
+	if crashLooping && !tracked.crashLooping {
+		transitions = append(transitions, podTransition{event: PodWebhookCrashLoop, pod: pod})
+	}
+	tracked.pod = pod
+	tracked.everReady = tracked.everReady || ready
+	tracked.crashLooping = crashLooping
+	return transitions
+}
+
+// podCrashLooping reports whether a pod or any of its containers is in CrashLoopBackOff.
+func podCrashLooping(pod PodInfo) (crashLooping bool) {
+	if pod.Status == "CrashLoopBackOff" {
+		crashLooping = true
+		return crashLooping
+	}
+	for _, container := range pod.Containers {
+		if container.StateReason == "CrashLoopBackOff" {
+			crashLooping = true
+			return crashLooping
+		}
+	}
+	return crashLooping
+}
@@ -24,57 +24,173 @@ package podboard
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"regexp"
 	"strings"
 	"time"
 
 	"go.uber.org/zap"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 )
 
 const imageTagLatest = "latest"
 
+// ErrForceDeleteUnconfirmed is returned when a force delete lacks gracePeriod=0 or the confirmation naming the pod.
+var ErrForceDeleteUnconfirmed = errors.New("force delete requires gracePeriod=0 and confirm=<namespace>/<name>")
+
+// PodDeleteOptions adjust a pod deletion. GracePeriodSeconds overrides the pod's termination grace period.
+// Force removes the pod from the API without waiting for the kubelet to confirm its containers stopped, for
+// pods stuck in Terminating on unreachable nodes. Like kubectl, it needs a zero grace period, and Confirm
+// must name the pod as namespace/name so it can't be done by accident.
+type PodDeleteOptions struct {
+	GracePeriodSeconds *int64
+	Force              bool
+	Confirm            string
+}
+
+// Validate returns ErrForceDeleteUnconfirmed if a force delete of the pod isn't confirmed.
+func (options PodDeleteOptions) Validate(namespace, podName string) (err error) {
+	if options.Force && (options.GracePeriodSeconds == nil || *options.GracePeriodSeconds != 0 || options.Confirm != namespace+"/"+podName) {
+		err = fmt.Errorf("%w: %s/%s", ErrForceDeleteUnconfirmed, namespace, podName)
+		return err
+	}
+	return err
+}
+
 // PodInfo represents pod information for the dashboard.
 type PodInfo struct {
-	Name      string            `json:"name"`
-	Namespace string            `json:"namespace"`
-	ImageTag  string            `json:"imageTag"`
-	Status    string            `json:"status"`
-	Ready     string            `json:"ready"`
-	Restarts  int32             `json:"restarts"`
-	Age       string            `json:"age"`
-	Node      string            `json:"node"`
-	IP        string            `json:"ip"`
-	Labels    map[string]string `json:"labels,omitempty"`
+	Cluster   string `json:"cluster,omitempty"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	ImageTag  string `json:"imageTag"`
+	Status    string `json:"status"`
+	// Reason and Message are the pod-level status reason and message, set for pods the kubelet evicted or shut
+	// down.
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+	Ready   string `json:"ready"`
+	// Restarts counts the app containers' restarts. Native sidecars are counted apart in SidecarRestarts, so a
+	// flapping proxy isn't taken for a crashing app.
+	Restarts        int32             `json:"restarts"`
+	SidecarRestarts int32             `json:"sidecarRestarts,omitempty"`
+	SandboxRestarts int32             `json:"sandboxRestarts,omitempty"`
+	CreatedAt       string            `json:"createdAt"`
+	Age             string            `json:"age"`
+	AgeSeconds      int64             `json:"ageSeconds"`
+	Node            string            `json:"node"`
+	IP              string            `json:"ip"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Usage           *PodUsage         `json:"usage,omitempty"`
+	RunbookURL      string            `json:"runbookUrl,omitempty"`
+	DerivedStatuses []string          `json:"derivedStatuses,omitempty"`
+	NodeProblems    []string          `json:"nodeProblems,omitempty"`
+	OwnerKind       string            `json:"ownerKind,omitempty"`
+	OwnerName       string            `json:"ownerName,omitempty"`
+	Priority        int32             `json:"priority,omitempty"`
+	PriorityClass   string            `json:"priorityClassName,omitempty"`
+	QOSClass        string            `json:"qosClass,omitempty"`
+	ServiceAccount  string            `json:"serviceAccountName,omitempty"`
+	Preemption      string            `json:"preemption,omitempty"`
+	AppMetric       *AppMetric        `json:"appMetric,omitempty"`
+	Mesh            *MeshStatus       `json:"mesh,omitempty"`
+	Containers      []ContainerInfo   `json:"containers"`
+	// LastExit* describe the latest abnormal container exit, an OOM kill or non-zero exit code, even when the pod
+	// has recovered since.
+	LastExitReason    string `json:"lastExitReason,omitempty"`
+	LastExitCode      *int32 `json:"lastExitCode,omitempty"`
+	LastExitTime      string `json:"lastExitTime,omitempty"`
+	LastExitContainer string `json:"lastExitContainer,omitempty"`
+	// created orders pods by age without reparsing CreatedAt.
+	created time.Time
+}
+
+// ContainerInfo summarizes one of a pod's containers, so a failing sidecar isn't hidden behind the pod's
+// aggregate ready count. Type and RestartPolicy tell native sidecars from app containers.
+type ContainerInfo struct {
+	Name                    string `json:"name"`
+	Type                    string `json:"type"`
+	RestartPolicy           string `json:"restartPolicy"`
+	Image                   string `json:"image"`
+	ImageTag                string `json:"imageTag"`
+	Ready                   bool   `json:"ready"`
+	RestartCount            int32  `json:"restartCount"`
+	State                   string `json:"state"`
+	StateReason             string `json:"stateReason,omitempty"`
+	LastTerminationReason   string `json:"lastTerminationReason,omitempty"`
+	LastTerminationExitCode *int32 `json:"lastTerminationExitCode,omitempty"`
 }
 
 // PodService handles pod-related operations.
 type PodService struct {
-	kubeConfigService *KubeConfigService
+	config            ServerConfig
+	derivedStatuses   *DerivedStatuses
+	annotations       *AnnotationFilter
+	kubeConfigService ClientFactory
+	metricsService    *MetricsService
+	appMetrics        *AppMetricService
+	nodeProblems      *NodeProblemCache
+	mesh              *MeshCache
+	sandboxCache      *ttlCache[string, map[types.UID]int32]
+	cronJobCache      *ttlCache[string, map[string]string]
+	namespaceAccess   *ttlCache[string, []string]
+	podSnapshots      *StaleCache
+	namespacePolicy   *NamespacePolicy
 	logger            *zap.Logger
 }
 
-// NewPodService creates a new pod service.
-func NewPodService(kubeConfigService *KubeConfigService, logger *zap.Logger) (service *PodService) {
+// NewPodService creates a new pod service. Pod details use the default annotation limits when annotations is nil.
+func NewPodService(config ServerConfig, derivedStatuses *DerivedStatuses, annotations *AnnotationFilter, kubeConfigService ClientFactory, logger *zap.Logger) (service *PodService) {
+	if annotations == nil {
+		// The defaults always compile.
+		annotations, _ = NewAnnotationFilter(AnnotationConfig{})
+	}
 	service = &PodService{
+		config:            config,
+		derivedStatuses:   derivedStatuses,
+		annotations:       annotations,
 		kubeConfigService: kubeConfigService,
+		metricsService:    NewMetricsService(logger),
+		appMetrics:        NewAppMetricService(logger),
+		nodeProblems:      NewNodeProblemCache(logger),
+		mesh:              NewMeshCache(logger),
+		sandboxCache:      newTTLCache[string, map[types.UID]int32](sandboxCacheTTL, 0),
+		cronJobCache:      newTTLCache[string, map[string]string](cronJobOwnerCacheTTL, 0),
+		namespaceAccess:   newTTLCache[string, []string](namespaceAccessTTL, 0),
+		podSnapshots:      NewStaleCache(podDeltaMaxAge, podDeltaMaxSnapshots),
+		namespacePolicy:   serverNamespacePolicy(config),
 		logger:            logger,
 	}
 	return service
 }
 
 // GetPods retrieves pods from the specified namespace with optional label selector and cluster.
-// Supports regex patterns in label selectors using the =~ operator (e.g., "app=~nginx.*").
-// Use namespace="all" to retrieve pods from all namespaces.
-func (ps *PodService) GetPods(ctx context.Context, clusterName, namespace, labelSelector string) (podInfos []PodInfo, err error) {
+// Supports regex patterns in label selectors using the =~ and !~ operators (e.g., "app=~nginx.*", "env!~dev|sandbox").
+// Use namespace="all" to retrieve pods from all namespaces. The filter narrows the pods by field selector, name and
+// namespace before they are enriched with usage and metrics.
+func (ps *PodService) GetPods(ctx context.Context, clusterName, namespace, labelSelector string, filter PodFilter) (podInfos []PodInfo, err error) {
+	podInfos, _, err = ps.GetPodPage(ctx, clusterName, namespace, labelSelector, filter, PodPage{})
+	return podInfos, err
+}
+
+// GetPodPage retrieves one page of pods like GetPods, in the page's order, returning the continue token of
+// the next page. Only the pods on the page are scraped for app metrics.
+func (ps *PodService) GetPodPage(ctx context.Context, clusterName, namespace, labelSelector string, filter PodFilter, page PodPage) (podInfos []PodInfo, next string, err error) {
+	var limit int64
+	var continueToken string
+	limit, continueToken, err = page.listOptions()
+	if err != nil {
+		return podInfos, next, err
+	}
+
 	var client kubernetes.Interface
-	client, err = ps.getClient(clusterName)
+	client, err = ps.getClient(ctx, clusterName)
 	if err != nil {
 		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
-		return podInfos, err
+		return podInfos, next, err
 	}
 
 	// Handle "all" namespace by using empty string for Kubernetes API
@@ -83,51 +199,144 @@ func (ps *PodService) GetPods(ctx context.Context, clusterName, namespace, label
 		queryNamespace = ""
 	}
 
-	var pods *corev1.PodList
-
-	// Check if this is a regex selector
-	if strings.Contains(labelSelector, "=~") {
-		// For regex selectors, we need to fetch all pods and filter manually
-		pods, err = client.CoreV1().Pods(queryNamespace).List(ctx, metav1.ListOptions{})
-		if err != nil {
-			ps.logger.Error("Failed to list pods for regex filtering", zap.Error(err), zap.String("cluster", clusterName), zap.String("namespace", namespace))
-			err = fmt.Errorf("failed to list pods: %w", err)
-			return podInfos, err
+	var pods []corev1.Pod
+	var listContinue string
+	pods, listContinue, err = ps.listPodPage(ctx, client, clusterName, queryNamespace, labelSelector, filter.FieldSelector, limit, continueToken)
+	if err != nil {
+		return podInfos, next, err
+	}
+	pods = filter.filterPods(pods)
+
+	// Resource usage is best effort - pods are returned without it if metrics-server is absent.
+	usage, _ := ps.metricsService.GetPodUsage(ctx, client, clusterName, queryNamespace)
+	// Node problems are best effort too, so node-level root causes show next to their pod symptoms.
+	problems := ps.nodeProblems.Get(ctx, client, clusterName)
+	// Sandbox recreations tell node-level restarts apart from application crashes.
+	sandboxRestarts := ps.sandboxRestarts(ctx, client, clusterName, queryNamespace)
+	// Mesh sidecars can break traffic to pods whose applications are healthy.
+	mesh := ps.mesh.Get(ctx, client, clusterName)
+	// Pods declaring an app metric are scraped once the page is cut.
+	declaring := make(map[string]*corev1.Pod)
+	view := timeViewFromContext(ctx)
+	extended := extendedPodDetailFromContext(ctx)
+
+	for _, pod := range pods {
+		podInfo := ps.podToPodInfo(&pod, view)
+		if extended {
+			setExtendedPodDetail(&podInfo, &pod)
+		}
+		if podUsage, exists := usage[pod.Namespace+"/"+pod.Name]; exists {
+			podInfo.Usage = &podUsage
 		}
-	} else {
-		// Use standard Kubernetes label selector
-		listOptions := metav1.ListOptions{}
-		if labelSelector != "" {
-			listOptions.LabelSelector = labelSelector
+		podInfo.NodeProblems = problems[podInfo.Node]
+		podInfo.SandboxRestarts = sandboxRestarts[pod.UID]
+		podInfo.Mesh = podMeshStatus(&pod, mesh)
+		podInfo.DerivedStatuses = ps.derivedStatuses.ForPod(podInfo)
+		if declaresAppMetric(&pod) {
+			declaring[pod.Namespace+"/"+pod.Name] = &pod
 		}
+		podInfos = append(podInfos, podInfo)
+	}
 
-		pods, err = client.CoreV1().Pods(queryNamespace).List(ctx, listOptions)
-		if err != nil {
-			ps.logger.Error("Failed to list pods", zap.Error(err), zap.String("cluster", clusterName), zap.String("namespace", namespace), zap.String("labelSelector", labelSelector))
-			err = fmt.Errorf("failed to list pods: %w", err)
-			return podInfos, err
+	podInfos, next, err = page.apply(podInfos, listContinue)
+	if err != nil {
+		return podInfos, next, err
+	}
+
+	// Scraped pods are keyed by their index in podInfos.
+	scrape := make(map[int]*corev1.Pod)
+	for i := range podInfos {
+		if pod, exists := declaring[podInfos[i].Namespace+"/"+podInfos[i].Name]; exists {
+			scrape[i] = pod
 		}
 	}
+	ps.resolveCronJobOwners(ctx, client, clusterName, queryNamespace, podInfos)
+	ps.appMetrics.Attach(ctx, client, clusterName, podInfos, scrape)
 
-	for _, pod := range pods.Items {
-		// Apply regex filtering if needed
-		if strings.Contains(labelSelector, "=~") {
-			if !ps.matchesRegexSelector(pod.Labels, labelSelector) {
-				continue
-			}
+	return podInfos, next, err
+}
+
+// listPods lists the pods in a namespace matching a label selector, which may use the =~ and !~ regex operators.
+// An empty namespace lists pods in all namespaces.
+func (ps *PodService) listPods(ctx context.Context, client kubernetes.Interface, clusterName, namespace, labelSelector, fieldSelector string) (pods []corev1.Pod, err error) {
+	pods, _, err = ps.listPodPage(ctx, client, clusterName, namespace, labelSelector, fieldSelector, 0, "")
+	return pods, err
+}
+
+// listPodPage lists pods like listPods, one page of at most limit pods at a time when limit is set. Regex
+// requirements are applied to each page, so pages can come back with fewer pods than the limit.
+func (ps *PodService) listPodPage(ctx context.Context, client kubernetes.Interface, clusterName, namespace, labelSelector, fieldSelector string, limit int64, continueToken string) (pods []corev1.Pod, next string, err error) {
+	err = ps.namespacePolicy.Check(namespace)
+	if err != nil {
+		return pods, next, err
+	}
+
+	var selector podSelector
+	selector, err = parsePodSelector(labelSelector)
+	if err != nil {
+		return pods, next, err
+	}
+
+	// The API server evaluates the field selector and the standard requirements; regex requirements are applied to
+	// what it returns.
+	var podList *corev1.PodList
+	podList, err = client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.standard, FieldSelector: fieldSelector, Limit: limit, Continue: continueToken})
+	if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+		err = fmt.Errorf("%w: %w", ErrPodPageExpired, err)
+		return pods, next, err
+	}
+	if err != nil {
+		ps.logger.Error("Failed to list pods", zap.Error(err), zap.String("cluster", clusterName), zap.String("namespace", namespace), zap.String("labelSelector", labelSelector), zap.String("fieldSelector", fieldSelector))
+		err = fmt.Errorf("failed to list pods: %w", err)
+		return pods, next, err
+	}
+	next = podList.Continue
+
+	if !selector.hasRegex() && ps.namespacePolicy == nil {
+		pods = podList.Items
+		return pods, next, err
+	}
+	for _, pod := range podList.Items {
+		if selector.Matches(pod.Labels) && ps.namespacePolicy.Allows(pod.Namespace) {
+			pods = append(pods, pod)
 		}
+	}
+	return pods, next, err
+}
 
-		podInfo := ps.podToPodInfo(&pod)
-		podInfos = append(podInfos, podInfo)
+// GetPod retrieves a single pod.
+func (ps *PodService) GetPod(ctx context.Context, clusterName, namespace, podName string) (podInfo PodInfo, err error) {
+	err = ps.namespacePolicy.Check(namespace)
+	if err != nil {
+		return podInfo, err
 	}
 
-	return podInfos, err
+	var client kubernetes.Interface
+	client, err = ps.getClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return podInfo, err
+	}
+
+	var pod *corev1.Pod
+	pod, err = client.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		err = fmt.Errorf("failed to get pod %s/%s: %w", namespace, podName, err)
+		return podInfo, err
+	}
+
+	podInfo = ps.podToPodInfo(pod, timeViewFromContext(ctx))
+	podInfo.NodeProblems = ps.nodeProblems.Get(ctx, client, clusterName)[podInfo.Node]
+	podInfo.Mesh = podMeshStatus(pod, ps.mesh.Get(ctx, client, clusterName))
+	podInfo.DerivedStatuses = ps.derivedStatuses.ForPod(podInfo)
+	return podInfo, err
 }
 
-// GetNamespaces retrieves all namespaces for the given cluster.
+// GetNamespaces retrieves all namespaces for the given cluster. Where listing namespaces is forbidden, as with
+// namespace-scoped RBAC, it returns the configured and own namespaces that pods may be listed in instead.
 func (ps *PodService) GetNamespaces(ctx context.Context, clusterName string) (names []string, err error) {
 	var client kubernetes.Interface
-	client, err = ps.getClient(clusterName)
+	client, err = ps.getClient(ctx, clusterName)
 	if err != nil {
 		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
 		return names, err
@@ -135,6 +344,13 @@ func (ps *PodService) GetNamespaces(ctx context.Context, clusterName string) (na
 
 	var namespaces *corev1.NamespaceList
 	namespaces, err = client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if apierrors.IsForbidden(err) {
+		accessible, accessErr := ps.accessibleNamespaces(ctx, client, clusterName)
+		if accessErr == nil && len(accessible) > 0 {
+			names, err = ps.namespacePolicy.Filter(accessible), nil
+			return names, err
+		}
+	}
 	if err != nil {
 		ps.logger.Error("Failed to list namespaces", zap.Error(err), zap.String("cluster", clusterName))
 		err = fmt.Errorf("failed to list namespaces: %w", err)
@@ -142,72 +358,76 @@ func (ps *PodService) GetNamespaces(ctx context.Context, clusterName string) (na
 	}
 
 	for _, ns := range namespaces.Items {
-		names = append(names, ns.Name)
+		if ps.namespacePolicy.Allows(ns.Name) {
+			names = append(names, ns.Name)
+		}
 	}
 
 	return names, err
 }
 
 // getClient returns a Kubernetes client for the given cluster.
-func (ps *PodService) getClient(clusterName string) (client kubernetes.Interface, err error) {
-	if ps.kubeConfigService.IsInCluster() {
-		// When running in cluster, use in-cluster config regardless of cluster
-		client, err = ps.kubeConfigService.CreateClientForCluster("")
-		return client, err
-	}
-
-	if clusterName == "" {
-		// If no cluster specified, try to get current cluster
-		currentCluster, clusterErr := ps.kubeConfigService.GetCurrentCluster()
-		if clusterErr != nil {
-			err = fmt.Errorf("no cluster specified and failed to get current cluster: %w", clusterErr)
-			return client, err
-		}
-		clusterName = currentCluster
-	}
-
-	client, err = ps.kubeConfigService.CreateClientForCluster(clusterName)
+func (ps *PodService) getClient(ctx context.Context, clusterName string) (client kubernetes.Interface, err error) {
+	client, err = ps.kubeConfigService.GetClient(ctx, clusterName)
 	return client, err
 }
 
 // DeletePod deletes a pod by name in the specified namespace and cluster.
-func (ps *PodService) DeletePod(ctx context.Context, clusterName, namespace, podName string) (err error) {
-	client, clientErr := ps.getClient(clusterName)
+// It returns ErrForceDeleteUnconfirmed if a force delete isn't confirmed.
+func (ps *PodService) DeletePod(ctx context.Context, clusterName, namespace, podName string, options PodDeleteOptions) (err error) {
+	err = options.Validate(namespace, podName)
+	if err != nil {
+		return err
+	}
+	err = ps.namespacePolicy.Check(namespace)
+	if err != nil {
+		return err
+	}
+
+	client, clientErr := ps.getClient(ctx, clusterName)
 	if clientErr != nil {
 		err = fmt.Errorf("failed to get Kubernetes client: %w", clientErr)
 		return err
 	}
 
-	err = client.CoreV1().Pods(namespace).Delete(ctx, podName, metav1.DeleteOptions{})
+	err = client.CoreV1().Pods(namespace).Delete(ctx, podName, metav1.DeleteOptions{GracePeriodSeconds: options.GracePeriodSeconds})
 	if err != nil {
 		ps.logger.Error("Failed to delete pod", zap.Error(err), zap.String("cluster", clusterName), zap.String("namespace", namespace), zap.String("pod", podName))
 		err = fmt.Errorf("failed to delete pod %s/%s: %w", namespace, podName, err)
 		return err
 	}
 
-	ps.logger.Info("Pod deleted successfully", zap.String("cluster", clusterName), zap.String("namespace", namespace), zap.String("pod", podName))
+	if options.Force {
+		// The containers may still be running on the node, so record who removed the pod from the API.
+		ps.logger.Warn("Pod force deleted", zap.String("cluster", clusterName), zap.String("namespace", namespace), zap.String("pod", podName), zap.String("user", identityUser(ctx)), zap.String("requestId", requestID(ctx)))
+		return err
+	}
+	ps.logger.Info("Pod deleted successfully", zap.String("cluster", clusterName), zap.String("namespace", namespace), zap.String("pod", podName), zap.String("user", identityUser(ctx)), zap.String("requestId", requestID(ctx)))
 	return err
 }
 
-func (ps *PodService) podToPodInfo(pod *corev1.Pod) (info PodInfo) {
-	// Calculate ready containers
+func (ps *PodService) podToPodInfo(pod *corev1.Pod, view timeView) (info PodInfo) {
+	// Calculate ready containers, counting native sidecars like kubectl does
+	sidecars := sidecarStatuses(pod)
 	readyContainers := 0
-	totalContainers := len(pod.Spec.Containers)
-	for _, cs := range pod.Status.ContainerStatuses {
+	totalContainers := len(pod.Spec.Containers) + len(sidecars)
+	for _, cs := range append(sidecars, pod.Status.ContainerStatuses...) {
 		if cs.Ready {
 			readyContainers++
 		}
 	}
 
-	// Calculate restart count
-	var restarts int32
+	// Calculate restart counts, keeping sidecar restarts apart
+	var restarts, sidecarRestarts int32
 	for _, cs := range pod.Status.ContainerStatuses {
 		restarts += cs.RestartCount
 	}
+	for _, cs := range sidecars {
+		sidecarRestarts += cs.RestartCount
+	}
 
 	// Calculate age
-	age := time.Since(pod.CreationTimestamp.Time)
-	ageStr := formatDuration(age)
+	ageStr := view.age(pod.CreationTimestamp.Time)
 
 	// Get pod status - check container states for more accurate status
 	podStatus := ps.getPodStatus(pod)
@@ -215,21 +435,112 @@ func (ps *PodService) podToPodInfo(pod *corev1.Pod) (info PodInfo) {
 	// Extract image tag from first container
 	imageTag := extractImageTag(pod)
 
+	ownerKind, ownerName := podOwner(pod)
+
 	info = PodInfo{
-		Name:      pod.Name,
-		Namespace: pod.Namespace,
-		ImageTag:  imageTag,
-		Status:    podStatus,
-		Ready:     fmt.Sprintf("%d/%d", readyContainers, totalContainers),
-		Restarts:  restarts,
-		Age:       ageStr,
-		Node:      pod.Spec.NodeName,
-		IP:        pod.Status.PodIP,
-		Labels:    pod.Labels,
-	}
+		created:         pod.CreationTimestamp.Time,
+		Name:            pod.Name,
+		Namespace:       pod.Namespace,
+		ImageTag:        imageTag,
+		Status:          podStatus,
+		Reason:          pod.Status.Reason,
+		Message:         pod.Status.Message,
+		Ready:           fmt.Sprintf("%d/%d", readyContainers, totalContainers),
+		Restarts:        restarts,
+		SidecarRestarts: sidecarRestarts,
+		CreatedAt:       view.format(pod.CreationTimestamp.Time),
+		Age:             ageStr,
+		AgeSeconds:      view.ageSeconds(pod.CreationTimestamp.Time),
+		Node:            pod.Spec.NodeName,
+		IP:              pod.Status.PodIP,
+		Labels:          pod.Labels,
+		RunbookURL:      runbookURL(pod.Annotations, ps.config.RunbookAnnotation),
+		OwnerKind:       ownerKind,
+		OwnerName:       ownerName,
+		Preemption:      podPreemption(pod),
+		Containers:      podContainers(pod),
+	}
+	if pod.Spec.Priority != nil {
+		info.Priority = *pod.Spec.Priority
+	}
+	setLastExit(&info, pod, view)
 	return info
 }
 
+// setLastExit records the most recent last termination of the pod's app and sidecar containers that was an OOM
+// kill or a non-zero exit, which a running pod would otherwise hide.
+func setLastExit(info *PodInfo, pod *corev1.Pod, view timeView) {
+	var last *corev1.ContainerStateTerminated
+	for _, status := range append(sidecarStatuses(pod), pod.Status.ContainerStatuses...) {
+		terminated := status.LastTerminationState.Terminated
+		if terminated == nil || (terminated.Reason != "OOMKilled" && terminated.ExitCode == 0) {
+			continue
+		}
+		if last == nil || terminated.FinishedAt.After(last.FinishedAt.Time) {
+			last = terminated
+			info.LastExitContainer = status.Name
+		}
+	}
+	if last == nil {
+		return
+	}
+
+	exitCode := last.ExitCode
+	info.LastExitReason = last.Reason
+	if info.LastExitReason == "" {
+		info.LastExitReason = "Error"
+	}
+	info.LastExitCode = &exitCode
+	info.LastExitTime = view.format(last.FinishedAt.Time)
+}
+
+// podContainers summarizes a pod's native sidecars and app containers in spec order. Other init containers,
+// done once the pod runs, are left to the pod detail. Containers without a status yet are Waiting.
+func podContainers(pod *corev1.Pod) (containers []ContainerInfo) {
+	statusByName := make(map[string]corev1.ContainerStatus, len(pod.Status.ContainerStatuses))
+	for _, status := range append(sidecarStatuses(pod), pod.Status.ContainerStatuses...) {
+		statusByName[status.Name] = status
+	}
+
+	containers = make([]ContainerInfo, 0, len(pod.Spec.Containers))
+	for i, container := range append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...) {
+		init := i < len(pod.Spec.InitContainers)
+		if init && !isSidecar(container) {
+			continue
+		}
+
+		info := ContainerInfo{
+			Name:     container.Name,
+			Image:    container.Image,
+			ImageTag: imageTagFromImage(container.Image),
+			State:    "Waiting",
+		}
+		info.Type, info.RestartPolicy = containerRestartPolicy(pod, container, init)
+
+		if status, exists := statusByName[container.Name]; exists {
+			info.Ready = status.Ready
+			info.RestartCount = status.RestartCount
+			switch {
+			case status.State.Running != nil:
+				info.State = "Running"
+			case status.State.Terminated != nil:
+				info.State = "Terminated"
+				info.StateReason = status.State.Terminated.Reason
+			case status.State.Waiting != nil:
+				info.StateReason = status.State.Waiting.Reason
+			}
+			if terminated := status.LastTerminationState.Terminated; terminated != nil {
+				exitCode := terminated.ExitCode
+				info.LastTerminationReason = terminated.Reason
+				info.LastTerminationExitCode = &exitCode
+			}
+		}
+		containers = append(containers, info)
+	}
+
+	return containers
+}
+
 // getPodStatus returns the most accurate status for a pod by checking container states.
 // This provides more detailed status than just the pod phase (e.g., CrashLoopBackOff, ImagePullBackOff).
 func (ps *PodService) getPodStatus(pod *corev1.Pod) (status string) {
@@ -312,8 +623,13 @@ func extractImageTag(pod *corev1.Pod) (tag string) {
 		return tag
 	}
 
-	image := pod.Spec.Containers[0].Image
+	tag = imageTagFromImage(pod.Spec.Containers[0].Image)
+	return tag
+}
 
+// imageTagFromImage extracts the tag from a container image reference.
+// If there's no tag specified, it returns "latest".
+func imageTagFromImage(image string) (tag string) {
 	// Handle different image formats:
 	// - registry/image:tag
 	// - image:tag
@@ -360,90 +676,3 @@ func formatDuration(d time.Duration) (formatted string) {
 	formatted = fmt.Sprintf("%dd", int(d.Hours()/24))
 	return formatted
 }
-
-// matchesRegexSelector checks if pod labels match a regex selector.
-// Supports patterns like "app=~nginx.*", "environment=~dev|staging", etc.
-// Can handle multiple regex selectors separated by commas.
-func (ps *PodService) matchesRegexSelector(labels map[string]string, selector string) (matches bool) {
-	if labels == nil {
-		return matches
-	}
-
-	// Split by commas to handle multiple selectors.
-	selectors := strings.Split(selector, ",")
-
-	for _, sel := range selectors {
-		sel = strings.TrimSpace(sel)
-		if sel == "" {
-			continue
-		}
-
-		if !ps.matchesSingleSelector(labels, sel) {
-			return matches
-		}
-	}
-
-	matches = true
-	return matches
-}
-
-// matchesSingleSelector evaluates a single label selector against pod labels.
-func (ps *PodService) matchesSingleSelector(labels map[string]string, sel string) (matched bool) {
-	if strings.Contains(sel, "=~") {
-		matched = ps.matchesRegexPart(labels, sel)
-		return matched
-	}
-
-	matched = ps.matchesEqualityPart(labels, sel)
-	return matched
-}
-
-// matchesRegexPart evaluates a single regex selector (key=~pattern) against labels.
-func (ps *PodService) matchesRegexPart(labels map[string]string, sel string) (matched bool) {
-	parts := strings.SplitN(sel, "=~", 2)
-	if len(parts) != 2 {
-		ps.logger.Warn("Invalid regex selector format", zap.String("selector", sel))
-		return matched
-	}
-
-	labelKey := strings.TrimSpace(parts[0])
-	regexPattern := strings.TrimSpace(parts[1])
-
-	labelValue, exists := labels[labelKey]
-	if !exists {
-		return matched
-	}
-
-	regex, err := regexp.Compile(regexPattern)
-	if err != nil {
-		ps.logger.Warn("Invalid regex pattern", zap.String("pattern", regexPattern), zap.Error(err))
-		return matched
-	}
-
-	matched = regex.MatchString(labelValue)
-	return matched
-}
-
-// matchesEqualityPart evaluates a single equality selector (key=value) against labels.
-func (ps *PodService) matchesEqualityPart(labels map[string]string, sel string) (matched bool) {
-	if !strings.Contains(sel, "=") || strings.Contains(sel, "!=") {
-		ps.logger.Warn("Unsupported selector format", zap.String("selector", sel))
-		return matched
-	}
-
-	parts := strings.SplitN(sel, "=", 2)
-	if len(parts) != 2 {
-		return matched
-	}
-
-	labelKey := strings.TrimSpace(parts[0])
-	expectedValue := strings.TrimSpace(parts[1])
-
-	labelValue, exists := labels[labelKey]
-	if !exists || labelValue != expectedValue {
-		return matched
-	}
-
-	matched = true
-	return matched
-}
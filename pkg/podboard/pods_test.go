@@ -0,0 +1,169 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package podboard
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPodDetailExtended(t *testing.T) {
+	pod := testPod("default", "web", "node-1")
+	pod.Spec.PriorityClassName = "high"
+	pod.Spec.ServiceAccountName = "web"
+	pod.Status.QOSClass = corev1.PodQOSBurstable
+	router := newTestRouter(t, ServerConfig{}, FileConfig{}, pod)
+
+	tests := []struct {
+		name     string
+		target   string
+		code     int
+		extended bool
+	}{
+		{name: "default", target: "/api/pods?namespace=default", code: http.StatusOK},
+		{name: "extended", target: "/api/pods?namespace=default&detail=extended", code: http.StatusOK, extended: true},
+		{name: "unknown detail", target: "/api/pods?namespace=default&detail=full", code: http.StatusBadRequest},
+		{name: "unknown delta detail", target: "/api/pods/delta?namespace=default&detail=full", code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := serve(router, http.MethodGet, tt.target, nil)
+			require.Equal(t, tt.code, recorder.Code, recorder.Body.String())
+			if tt.code != http.StatusOK {
+				return
+			}
+
+			pods, ok := decodeBody(t, recorder)["pods"].([]interface{})
+			require.True(t, ok)
+			require.Len(t, pods, 1)
+			fields, ok := pods[0].(map[string]interface{})
+			require.True(t, ok)
+			if !tt.extended {
+				assert.NotContains(t, fields, "qosClass")
+				assert.NotContains(t, fields, "priorityClassName")
+				assert.NotContains(t, fields, "serviceAccountName")
+				return
+			}
+			assert.Equal(t, "Burstable", fields["qosClass"])
+			assert.Equal(t, "high", fields["priorityClassName"])
+			assert.Equal(t, "web", fields["serviceAccountName"])
+		})
+	}
+}
+
+func TestCronJobOwnersCached(t *testing.T) {
+	controller := true
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+		Name:            "backup-1",
+		Namespace:       "default",
+		OwnerReferences: []metav1.OwnerReference{{Kind: KindCronJob, Name: "backup", Controller: &controller}},
+	}}
+	client := fake.NewClientset(job)
+	factory := NewStaticClientFactory(map[string]kubernetes.Interface{testCluster: client}, testCluster)
+	service := NewPodService(ServerConfig{}, nil, nil, factory, zap.NewNop())
+
+	for range 3 {
+		pods := []PodInfo{{Namespace: "default", Name: "backup-1-x", OwnerKind: KindJob, OwnerName: "backup-1"}}
+		service.resolveCronJobOwners(t.Context(), client, testCluster, "default", pods)
+		assert.Equal(t, KindCronJob, pods[0].OwnerKind)
+		assert.Equal(t, "backup", pods[0].OwnerName)
+	}
+
+	lists := 0
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "list" && action.GetResource().Resource == "jobs" {
+			lists++
+		}
+	}
+	assert.Equal(t, 1, lists, "jobs are listed once within the cache TTL")
+}
+
+func TestGetPodPage(t *testing.T) {
+	worker := testPod("default", "worker", "node-2")
+	worker.Labels["tier"] = "batch"
+	client := fake.NewClientset(
+		testPod("default", "web-1", "node-1"),
+		testPod("default", "web-2", "node-2"),
+		worker,
+		testPod("kube-system", "coredns", "node-1"),
+	)
+	factory := NewStaticClientFactory(map[string]kubernetes.Interface{testCluster: client}, testCluster)
+	service := NewPodService(ServerConfig{}, nil, nil, factory, zap.NewNop())
+
+	tests := []struct {
+		name          string
+		namespace     string
+		labelSelector string
+		nameFilter    string
+		page          PodPage
+		pods          []string
+		next          string
+		errorContains string
+	}{
+		{name: "namespace", namespace: "default", page: PodPage{SortBy: PodSortName}, pods: []string{"web-1", "web-2", "worker"}},
+		{name: "all namespaces", namespace: "all", page: PodPage{SortBy: PodSortName}, pods: []string{"coredns", "web-1", "web-2", "worker"}},
+		{name: "label selector", namespace: "default", labelSelector: "tier=batch", pods: []string{"worker"}},
+		{name: "regex selector", namespace: "default", labelSelector: "app=~^web-", page: PodPage{SortBy: PodSortName}, pods: []string{"web-1", "web-2"}},
+		{name: "negated regex selector", namespace: "default", labelSelector: "app!~^web-", pods: []string{"worker"}},
+		{name: "name filter", namespace: "all", nameFilter: "^core", pods: []string{"coredns"}},
+		{name: "descending", namespace: "default", page: PodPage{SortBy: PodSortName, Order: SortDescending}, pods: []string{"worker", "web-2", "web-1"}},
+		{name: "first sorted page", namespace: "default", page: PodPage{SortBy: PodSortName, Limit: 2}, pods: []string{"web-1", "web-2"}, next: "offset:2"},
+		{name: "last sorted page", namespace: "default", page: PodPage{SortBy: PodSortName, Limit: 2, Continue: "offset:2"}, pods: []string{"worker"}},
+		{name: "invalid selector", namespace: "default", labelSelector: "app=~(", errorContains: "invalid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := ParsePodFilter(tt.nameFilter, "", "")
+			require.NoError(t, err)
+
+			pods, next, err := service.GetPodPage(t.Context(), testCluster, tt.namespace, tt.labelSelector, filter, tt.page)
+			if tt.errorContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorContains)
+				return
+			}
+			require.NoError(t, err)
+
+			names := make([]string, 0, len(pods))
+			for _, pod := range pods {
+				names = append(names, pod.Name)
+			}
+			if tt.page.SortBy == "" {
+				assert.ElementsMatch(t, tt.pods, names)
+			} else {
+				assert.Equal(t, tt.pods, names)
+			}
+			assert.Equal(t, tt.next, next)
+		})
+	}
+}
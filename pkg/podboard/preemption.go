@@ -0,0 +1,158 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Preemption states of a pod.
+const (
+	// PreemptionPreempted marks a pod the scheduler is evicting to make room for a higher priority pod.
+	PreemptionPreempted = "Preempted"
+	// PreemptionPreempting marks a pending pod the scheduler nominated to a node where it is preempting others.
+	PreemptionPreempting = "Preempting"
+)
+
+// eventReasonPreempted is the reason of the event the scheduler records on each preempted pod.
+const eventReasonPreempted = "Preempted"
+
+// preemptionMessagePattern parses the scheduler's preemption event message. Current schedulers name the preemptor
+// by UID ("Preempted by pod 1d2c... on node node-1"), older ones by namespace and name ("Preempted by ns/pod on node node-1").
+//
+//nolint:gochecknoglobals // Compiled once
+var preemptionMessagePattern = regexp.MustCompile(`^Preempted by (?:pod )?(\S+) on node (\S+)`)
+
+// Preemption is a pod evicted by the scheduler to make room for a higher priority pod.
+type Preemption struct {
+	Time          string `json:"time,omitempty"`
+	Age           string `json:"age,omitempty"`
+	AgeSeconds    int64  `json:"ageSeconds,omitempty"`
+	Namespace     string `json:"namespace"`
+	Pod           string `json:"pod"`
+	Node          string `json:"node,omitempty"`
+	Preemptor     string `json:"preemptor"`
+	PreemptorName string `json:"preemptorName,omitempty"`
+	Message       string `json:"message"`
+}
+
+// podPreemption returns whether a pod is being preempted or is preempting others, or an empty string.
+func podPreemption(pod *corev1.Pod) (preemption string) {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.DisruptionTarget && condition.Status == corev1.ConditionTrue && condition.Reason == corev1.PodReasonPreemptionByScheduler {
+			preemption = PreemptionPreempted
+			return preemption
+		}
+	}
+	if pod.Status.NominatedNodeName != "" {
+		preemption = PreemptionPreempting
+	}
+	return preemption
+}
+
+// GetPreemptions returns the recent preemptions in a namespace ("all" for every namespace), newest first.
+// They are taken from events, so preemptions remain explainable after the preempted pods are gone.
+// Preemptors are named where the preempting pod can still be found in the listed namespace.
+func (ps *PodService) GetPreemptions(ctx context.Context, clusterName, namespace string) (preemptions []Preemption, err error) {
+	err = ps.namespacePolicy.Check(namespace)
+	if err != nil {
+		return preemptions, err
+	}
+
+	var client kubernetes.Interface
+	client, err = ps.getClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return preemptions, err
+	}
+
+	var events []corev1.Event
+	events, err = preemptionEvents(ctx, client, eventQueryNamespace(namespace))
+	if err != nil {
+		ps.logger.Error("Failed to list preemption events", zap.Error(err), zap.String("cluster", clusterName), zap.String("namespace", namespace))
+		return preemptions, err
+	}
+
+	// Best effort: the preemptor may live in another namespace or be named by UID only.
+	preemptorNames := make(map[string]string)
+	pods, podsErr := client.CoreV1().Pods(eventQueryNamespace(namespace)).List(ctx, metav1.ListOptions{ResourceVersion: "0"})
+	if podsErr == nil {
+		for _, pod := range pods.Items {
+			if ps.namespacePolicy.Allows(pod.Namespace) {
+				preemptorNames[string(pod.UID)] = pod.Namespace + "/" + pod.Name
+			}
+		}
+	}
+
+	view := timeViewFromContext(ctx)
+	preemptions = make([]Preemption, 0, len(events))
+	for i := range events {
+		if !ps.namespacePolicy.Allows(events[i].InvolvedObject.Namespace) {
+			continue
+		}
+		info := eventToEventInfo(&events[i], view)
+		preemption := Preemption{
+			Time:       info.LastSeen,
+			Age:        info.Age,
+			AgeSeconds: info.AgeSeconds,
+			Namespace:  events[i].InvolvedObject.Namespace,
+			Pod:        events[i].InvolvedObject.Name,
+			Message:    events[i].Message,
+		}
+		if match := preemptionMessagePattern.FindStringSubmatch(events[i].Message); match != nil {
+			preemption.Preemptor, preemption.Node = match[1], match[2]
+			preemption.PreemptorName = preemptorNames[match[1]]
+		}
+		preemptions = append(preemptions, preemption)
+	}
+
+	return preemptions, err
+}
+
+// preemptionEvents lists the scheduler's preemption events in a namespace ("" for every namespace), newest first.
+func preemptionEvents(ctx context.Context, client kubernetes.Interface, namespace string) (events []corev1.Event, err error) {
+	selector := fields.Set{"involvedObject.kind": KindPod, "reason": eventReasonPreempted}.AsSelector().String()
+
+	var eventList *corev1.EventList
+	eventList, err = client.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		err = fmt.Errorf("failed to list preemption events: %w", err)
+		return events, err
+	}
+
+	events = eventList.Items
+	sort.SliceStable(events, func(i, j int) (less bool) {
+		less = eventLastSeen(&events[i]).After(eventLastSeen(&events[j]))
+		return less
+	})
+	return events, err
+}
@@ -0,0 +1,406 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"go.uber.org/zap"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Preflight check results.
+const (
+	PreflightPass = "PASS"
+	PreflightWarn = "WARN"
+	PreflightFail = "FAIL"
+)
+
+const preflightClusterTimeout = 5 * time.Second
+
+// PreflightCheck is the outcome of a single preflight check.
+type PreflightCheck struct {
+	Name    string `json:"name"`
+	Result  string `json:"result"`
+	Message string `json:"message"`
+}
+
+// PreflightReport collects the outcome of all preflight checks.
+type PreflightReport struct {
+	Checks []PreflightCheck `json:"checks"`
+}
+
+// preflightPermission is an RBAC permission podboard needs. Optional permissions only produce warnings.
+type preflightPermission struct {
+	group       string
+	resource    string
+	subresource string
+	verb        string
+	feature     string
+	optional    bool
+}
+
+// preflightPermissions lists the cluster permissions used by podboard features.
+// With impersonation the listed resource permissions belong to end users, but podboard itself must be
+// allowed to impersonate them.
+func preflightPermissions(impersonate bool) (permissions []preflightPermission) {
+	permissions = []preflightPermission{
+		{group: "", resource: "pods", verb: "list", feature: "pod listing"},
+		{group: "", resource: "namespaces", verb: "list", feature: "namespace discovery"},
+		{group: "", resource: "events", verb: "list", feature: "pod events", optional: true},
+		{group: "", resource: "pods", subresource: "log", verb: "get", feature: "pod logs in tickets", optional: true},
+		{group: "apps", resource: "deployments", verb: "list", feature: "deployment listing", optional: true},
+		{group: "batch", resource: "jobs", verb: "list", feature: "cron job owners", optional: true},
+		{group: "metrics.k8s.io", resource: "pods", verb: "list", feature: "pod resource usage", optional: true},
+		{group: "", resource: "pods", subresource: "proxy", verb: "get", feature: "app metrics", optional: true},
+		{group: "", resource: "pods", verb: "delete", feature: "pod deletion", optional: true},
+		{group: "", resource: "pods", verb: "patch", feature: "label changes", optional: true},
+		{group: "apps", resource: "deployments", subresource: "scale", verb: "update", feature: "deployment scaling", optional: true},
+		{group: "apps", resource: "statefulsets", subresource: "scale", verb: "update", feature: "statefulset scaling", optional: true},
+		{group: "autoscaling", resource: "horizontalpodautoscalers", verb: "update", feature: "quick scaling deployments with autoscalers", optional: true},
+		{group: "", resource: "nodes", verb: "list", feature: "node views and node problems", optional: true},
+		{group: "", resource: "nodes", verb: "patch", feature: "cordoning and node migrations", optional: true},
+		{group: "", resource: "pods", subresource: "eviction", verb: "create", feature: "pod eviction and node migrations", optional: true},
+		{group: "policy", resource: "poddisruptionbudgets", verb: "list", feature: "disruption budgets of blocked evictions", optional: true},
+	}
+	if impersonate {
+		permissions = append(permissions,
+			preflightPermission{group: "", resource: "users", verb: "impersonate", feature: "user impersonation"},
+			preflightPermission{group: "", resource: "groups", verb: "impersonate", feature: "group impersonation"},
+		)
+	}
+	return permissions
+}
+
+// Passed returns true if no check failed. Warnings do not fail the report.
+func (r *PreflightReport) Passed() (passed bool) {
+	for _, check := range r.Checks {
+		if check.Result == PreflightFail {
+			return passed
+		}
+	}
+	passed = true
+	return passed
+}
+
+// Print writes a human-readable pass/fail report.
+func (r *PreflightReport) Print(w io.Writer) {
+	for _, check := range r.Checks {
+		_, _ = fmt.Fprintf(w, "[%s] %s: %s\n", check.Result, check.Name, check.Message)
+	}
+}
+
+// LogWarnings logs every check that did not pass.
+func (r *PreflightReport) LogWarnings(logger *zap.Logger) {
+	for _, check := range r.Checks {
+		if check.Result == PreflightPass {
+			continue
+		}
+		logger.Warn("Preflight check did not pass", zap.String("check", check.Name), zap.String("result", check.Result), zap.String("message", check.Message))
+	}
+}
+
+func (r *PreflightReport) add(name, result, message string) {
+	r.Checks = append(r.Checks, PreflightCheck{Name: name, Result: result, Message: message})
+}
+
+// RunPreflight validates the environment podboard will run in: Kubernetes configuration, reachability
+// and RBAC of every cluster, and availability of the listen address. An empty address skips the port check.
+func RunPreflight(ctx context.Context, config ServerConfig, kubeConfigService *KubeConfigService) (report PreflightReport) {
+	if config.Address != "" {
+		checkListenAddress(&report, config.Address)
+	}
+
+	// podboard serves plain HTTP and expects TLS to be terminated by an ingress or proxy.
+	report.add("tls", PreflightPass, "not applicable, podboard serves plain HTTP")
+
+	checkFIPS(&report)
+
+	if config.Offline {
+		checkOffline(&report, kubeConfigService)
+	}
+
+	if config.ConfigFile != "" {
+		checkConfigFile(&report, config, kubeConfigService)
+	}
+
+	clusterNames, ok := checkKubeConfig(&report, kubeConfigService)
+	if !ok {
+		return report
+	}
+
+	for _, clusterName := range clusterNames {
+		checkCluster(ctx, &report, kubeConfigService, clusterName, config.Impersonate)
+	}
+
+	return report
+}
+
+// checkListenAddress verifies the server can bind its listen address.
+func checkListenAddress(report *PreflightReport, address string) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		report.add("port", PreflightFail, fmt.Sprintf("cannot listen on %s: %s", address, err))
+		return
+	}
+	_ = listener.Close()
+	report.add("port", PreflightPass, fmt.Sprintf("%s is available", address))
+}
+
+// checkFIPS verifies a FIPS build is actually running in FIPS 140-3 mode. GODEBUG can override the mode at runtime.
+func checkFIPS(report *PreflightReport) {
+	switch {
+	case FIPSBuild() && !FIPSEnabled():
+		report.add("fips", PreflightFail, "FIPS build but FIPS 140-3 mode is disabled, check GODEBUG")
+	case FIPSEnabled():
+		report.add("fips", PreflightPass, "FIPS 140-3 mode enabled")
+	default:
+		report.add("fips", PreflightPass, "standard build, FIPS 140-3 mode not enabled")
+	}
+}
+
+// checkOffline warns about kubeconfig credentials that reach out to external identity services.
+func checkOffline(report *PreflightReport, kubeConfigService *KubeConfigService) {
+	users, err := kubeConfigService.OutboundAuthUsers()
+	if err != nil {
+		report.add("offline", PreflightWarn, fmt.Sprintf("could not inspect kubeconfig credentials: %s", err))
+		return
+	}
+
+	if len(users) > 0 {
+		report.add("offline", PreflightWarn, fmt.Sprintf("kubeconfig users %v use exec or auth-provider credentials, which may make outbound calls", users))
+		return
+	}
+
+	report.add("offline", PreflightPass, "no outbound calls beyond the Kubernetes API servers")
+}
+
+// checkConfigFile verifies the config file parses and its actions, tickets, derived statuses,
+// quick scale limits, status page, GitHub reporting, canary hooks, pod webhooks and event bus are valid.
+func checkConfigFile(report *PreflightReport, config ServerConfig, kubeConfigService *KubeConfigService) {
+	fileConfig, err := LoadFileConfig(config.ConfigFile)
+	if err != nil {
+		report.add("config", PreflightFail, err.Error())
+		return
+	}
+
+	err = validateFileConfig(config, fileConfig, kubeConfigService)
+	if err != nil {
+		report.add("config", PreflightFail, err.Error())
+		return
+	}
+
+	message := fmt.Sprintf("loaded %s with %d actions", config.ConfigFile, len(fileConfig.Actions))
+	if fileConfig.Tickets.Backend != "" {
+		message += ", tickets via " + fileConfig.Tickets.Backend
+	}
+	if len(fileConfig.Statuses) > 0 {
+		message += fmt.Sprintf(", %d derived statuses", len(fileConfig.Statuses))
+	}
+	if len(fileConfig.Status.Workloads) > 0 {
+		message += fmt.Sprintf(", %d status page workloads", len(fileConfig.Status.Workloads))
+	}
+	if fileConfig.GitHub.WebhookSecretEnv != "" {
+		message += ", github deployment reporting"
+	}
+	if len(fileConfig.PodWebhooks) > 0 {
+		message += fmt.Sprintf(", %d pod webhooks", len(fileConfig.PodWebhooks))
+	}
+	if fileConfig.EventBus.Protocol != "" {
+		message += ", pod events published over " + fileConfig.EventBus.Protocol
+	}
+	if fileConfig.Alertmanager.URL != "" {
+		message += ", alerts from alertmanager"
+	}
+	if len(fileConfig.Access.Bindings) > 0 {
+		message += fmt.Sprintf(", %d access bindings", len(fileConfig.Access.Bindings))
+	}
+	report.add("config", PreflightPass, message)
+}
+
+// validateFileConfig creates each service configured in the config file, returning the first configuration
+// error.
+func validateFileConfig(config ServerConfig, fileConfig FileConfig, kubeConfigService ClientFactory) (err error) {
+	var actionService *ActionService
+	actionService, err = NewActionService(config, fileConfig.Actions, kubeConfigService, zap.NewNop())
+	if err != nil {
+		return err
+	}
+
+	_, err = NewCanaryService(fileConfig.Canary, nil, actionService, zap.NewNop())
+	if err != nil {
+		return err
+	}
+
+	_, err = NewTicketService(config, fileConfig.Tickets, nil, zap.NewNop())
+	if err != nil {
+		return err
+	}
+
+	_, err = NewDerivedStatuses(fileConfig.Statuses, zap.NewNop())
+	if err != nil {
+		return err
+	}
+
+	_, err = NewAnnotationFilter(fileConfig.Annotations)
+	if err != nil {
+		return err
+	}
+
+	_, err = NewAccessPolicy(fileConfig.Access, configAuthenticator(config) != nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = NewAlertmanagerService(config, fileConfig.Alertmanager, zap.NewNop())
+	if err != nil {
+		return err
+	}
+
+	_, err = NewQuickScaleService(fileConfig.QuickScale, nil, kubeConfigService, zap.NewNop())
+	if err != nil {
+		return err
+	}
+
+	_, err = NewStatusService(fileConfig.Status, nil, zap.NewNop())
+	if err != nil {
+		return err
+	}
+
+	_, err = NewGitHubService(config, fileConfig.GitHub, kubeConfigService, nil, zap.NewNop())
+	if err != nil {
+		return err
+	}
+
+	_, err = NewPodWebhookService(config, fileConfig.PodWebhooks, nil, zap.NewNop())
+	if err != nil {
+		return err
+	}
+
+	_, err = NewEventBusService(config, fileConfig.EventBus, nil, zap.NewNop())
+	if err != nil {
+		return err
+	}
+
+	return err
+}
+
+// checkKubeConfig verifies Kubernetes configuration is present and returns the clusters to check.
+// In cluster the single in-cluster connection is represented by an empty name.
+func checkKubeConfig(report *PreflightReport, kubeConfigService ClientFactory) (clusterNames []string, ok bool) {
+	if kubeConfigService.IsInCluster() {
+		report.add("kubeconfig", PreflightPass, "using in-cluster service account")
+		clusterNames = []string{""}
+		ok = true
+		return clusterNames, ok
+	}
+
+	clusters, err := kubeConfigService.GetClusters()
+	if err != nil {
+		report.add("kubeconfig", PreflightFail, err.Error())
+		return clusterNames, ok
+	}
+
+	if len(clusters) == 0 {
+		report.add("kubeconfig", PreflightFail, "no clusters defined in kubeconfig")
+		return clusterNames, ok
+	}
+
+	report.add("kubeconfig", PreflightPass, fmt.Sprintf("loaded %d clusters", len(clusters)))
+	for _, cluster := range clusters {
+		clusterNames = append(clusterNames, cluster.Name)
+	}
+
+	ok = true
+	return clusterNames, ok
+}
+
+// checkCluster verifies a cluster is reachable and podboard has the permissions its features need.
+func checkCluster(ctx context.Context, report *PreflightReport, kubeConfigService ClientFactory, clusterName string, impersonate bool) {
+	checkName := "cluster " + clusterName
+	if clusterName == "" {
+		checkName = "cluster in-cluster"
+	}
+
+	client, err := kubeConfigService.GetClient(ctx, clusterName)
+	if err != nil {
+		report.add(checkName, PreflightFail, err.Error())
+		return
+	}
+
+	clusterCtx, cancel := context.WithTimeout(ctx, preflightClusterTimeout)
+	defer cancel()
+
+	start := time.Now()
+	_, err = client.CoreV1().Namespaces().List(clusterCtx, metav1.ListOptions{Limit: 1})
+	if err != nil {
+		report.add(checkName, PreflightFail, fmt.Sprintf("unreachable or unauthorized: %s", err))
+		return
+	}
+	report.add(checkName, PreflightPass, fmt.Sprintf("reachable in %s", time.Since(start).Round(time.Millisecond)))
+
+	checkPermissions(clusterCtx, report, client, checkName, impersonate)
+}
+
+// checkPermissions runs a SelfSubjectAccessReview for every permission podboard uses.
+func checkPermissions(ctx context.Context, report *PreflightReport, client kubernetes.Interface, checkName string, impersonate bool) {
+	for _, permission := range preflightPermissions(impersonate) {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Group:       permission.group,
+					Resource:    permission.resource,
+					Subresource: permission.subresource,
+					Verb:        permission.verb,
+				},
+			},
+		}
+
+		resource := permission.resource
+		if permission.subresource != "" {
+			resource += "/" + permission.subresource
+		}
+		name := fmt.Sprintf("%s rbac %s %s", checkName, permission.verb, resource)
+
+		result, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			report.add(name, PreflightWarn, fmt.Sprintf("could not check permission: %s", err))
+			continue
+		}
+
+		switch {
+		case result.Status.Allowed:
+			report.add(name, PreflightPass, fmt.Sprintf("allowed cluster-wide (%s)", permission.feature))
+		case permission.optional:
+			report.add(name, PreflightWarn, fmt.Sprintf("not allowed cluster-wide, %s may be limited", permission.feature))
+		default:
+			report.add(name, PreflightFail, fmt.Sprintf("not allowed cluster-wide, %s will fail", permission.feature))
+		}
+	}
+}
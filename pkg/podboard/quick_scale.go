@@ -0,0 +1,481 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Quick scale states. Once reverted, a quick scale takes the state of its revert.
+const (
+	QuickScaleActive   = "Active"
+	QuickScaleReverted = RevertDone
+	// QuickScaleSkipped means the replica count was changed by someone else, so it was left alone.
+	QuickScaleSkipped = RevertSkipped
+	QuickScaleFailed  = RevertFailed
+)
+
+// Quick scale targets.
+const (
+	QuickScaleTargetDeployment = KindDeployment
+	QuickScaleTargetHPA        = "HorizontalPodAutoscaler"
+)
+
+const (
+	defaultQuickScaleMaxReplicas   = 50
+	defaultQuickScaleMaxPercent    = 100
+	defaultQuickScaleRevertMinutes = 30
+	defaultQuickScaleMaxMinutes    = 240
+	maxRetainedQuickScales         = 100
+)
+
+var (
+	// ErrQuickScaleNotFound is returned when a quick scale ID is unknown.
+	ErrQuickScaleNotFound = errors.New("quick scale not found")
+	// ErrQuickScaleRejected is returned when a quick scale request breaks the configured guardrails.
+	ErrQuickScaleRejected = errors.New("quick scale rejected")
+)
+
+// QuickScaleConfig holds the guardrails for quick scaling.
+type QuickScaleConfig struct {
+	// MaxReplicas is the highest replica count a quick scale may set (default 50).
+	MaxReplicas int32 `yaml:"maxReplicas"`
+	// MaxPercent is the largest increase allowed in one quick scale, as a percentage of the current replicas (default 100).
+	MaxPercent int32 `yaml:"maxPercent"`
+	// DefaultRevertMinutes is used when a request doesn't say when to revert (default 30).
+	DefaultRevertMinutes int `yaml:"defaultRevertMinutes"`
+	// MaxRevertMinutes is the longest a quick scale may stay in place (default 240).
+	MaxRevertMinutes int `yaml:"maxRevertMinutes"`
+}
+
+// QuickScaleRequest bumps a deployment's replicas by a count or a percentage.
+type QuickScaleRequest struct {
+	By                 int32 `json:"by,omitempty"`
+	Percent            int32 `json:"percent,omitempty"`
+	RevertAfterMinutes int   `json:"revertAfterMinutes,omitempty"`
+}
+
+// QuickScale is a temporary scale-up and its revert status.
+type QuickScale struct {
+	ID                  string    `json:"id"`
+	Cluster             string    `json:"cluster,omitempty"`
+	Namespace           string    `json:"namespace"`
+	Deployment          string    `json:"deployment"`
+	Target              string    `json:"target"`
+	TargetName          string    `json:"targetName"`
+	PreviousReplicas    int32     `json:"previousReplicas"`
+	Replicas            int32     `json:"replicas"`
+	PreviousMaxReplicas int32     `json:"previousMaxReplicas,omitempty"`
+	User                string    `json:"user,omitempty"`
+	RequestID           string    `json:"requestId,omitempty"`
+	State               string    `json:"state"`
+	Message             string    `json:"message,omitempty"`
+	CreatedAt           time.Time `json:"createdAt"`
+	RevertAt            time.Time `json:"revertAt"`
+	RevertID            string    `json:"revertId"`
+}
+
+// QuickScaleService temporarily scales deployments up and reverts them automatically.
+// When a HorizontalPodAutoscaler targets the deployment its minReplicas is raised instead, since the
+// autoscaler would otherwise undo a direct change to the replica count.
+type QuickScaleService struct {
+	limits            QuickScaleConfig
+	revertService     *RevertService
+	kubeConfigService ClientFactory
+	logger            *zap.Logger
+
+	mu     sync.Mutex
+	scales map[string]*QuickScale
+	order  []string
+}
+
+// NewQuickScaleService applies default guardrails and creates a new quick scale service.
+func NewQuickScaleService(limits QuickScaleConfig, revertService *RevertService, kubeConfigService ClientFactory, logger *zap.Logger) (service *QuickScaleService, err error) {
+	if limits.MaxReplicas == 0 {
+		limits.MaxReplicas = defaultQuickScaleMaxReplicas
+	}
+	if limits.MaxPercent == 0 {
+		limits.MaxPercent = defaultQuickScaleMaxPercent
+	}
+	if limits.DefaultRevertMinutes == 0 {
+		limits.DefaultRevertMinutes = defaultQuickScaleRevertMinutes
+	}
+	if limits.MaxRevertMinutes == 0 {
+		limits.MaxRevertMinutes = defaultQuickScaleMaxMinutes
+	}
+	if limits.MaxReplicas < 0 || limits.MaxPercent < 0 || limits.DefaultRevertMinutes < 0 || limits.MaxRevertMinutes < 0 {
+		err = errors.New("quickScale: limits must not be negative")
+		return service, err
+	}
+	if limits.DefaultRevertMinutes > limits.MaxRevertMinutes {
+		err = fmt.Errorf("quickScale: defaultRevertMinutes %d exceeds maxRevertMinutes %d", limits.DefaultRevertMinutes, limits.MaxRevertMinutes)
+		return service, err
+	}
+
+	service = &QuickScaleService{
+		limits:            limits,
+		revertService:     revertService,
+		kubeConfigService: kubeConfigService,
+		logger:            logger,
+		scales:            make(map[string]*QuickScale),
+	}
+	return service, err
+}
+
+// Scale bumps a deployment's replicas within the guardrails and schedules the revert.
+func (qs *QuickScaleService) Scale(ctx context.Context, clusterName, namespace, name string, request QuickScaleRequest) (quickScale QuickScale, err error) {
+	revertAfter, err := qs.validate(clusterName, request)
+	if err != nil {
+		return quickScale, err
+	}
+
+	var client kubernetes.Interface
+	client, err = qs.kubeConfigService.GetClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return quickScale, err
+	}
+
+	var scale *autoscalingv1.Scale
+	scale, err = client.AppsV1().Deployments(namespace).GetScale(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		err = fmt.Errorf("failed to get scale for deployment %s/%s: %w", namespace, name, err)
+		return quickScale, err
+	}
+
+	current := scale.Spec.Replicas
+	target, err := qs.targetReplicas(current, request)
+	if err != nil {
+		return quickScale, err
+	}
+
+	quickScale = QuickScale{
+		Cluster:          clusterName,
+		Namespace:        namespace,
+		Deployment:       name,
+		Target:           QuickScaleTargetDeployment,
+		TargetName:       name,
+		PreviousReplicas: current,
+		Replicas:         target,
+		User:             identityUser(ctx),
+		RequestID:        requestID(ctx),
+		State:            QuickScaleActive,
+		CreatedAt:        time.Now().UTC(),
+	}
+
+	var hpa *autoscalingv2.HorizontalPodAutoscaler
+	hpa, err = findDeploymentHPA(ctx, client, namespace, name)
+	if err != nil {
+		return quickScale, err
+	}
+
+	if hpa != nil {
+		err = qs.raiseHPA(ctx, client, hpa, &quickScale)
+	} else {
+		scale.Spec.Replicas = target
+		_, err = client.AppsV1().Deployments(namespace).UpdateScale(ctx, name, scale, metav1.UpdateOptions{})
+		if err != nil {
+			err = fmt.Errorf("failed to scale deployment %s/%s: %w", namespace, name, err)
+		}
+	}
+	if err != nil {
+		return quickScale, err
+	}
+
+	quickScale.ID, err = newID()
+	if err != nil {
+		return quickScale, err
+	}
+
+	// The revert runs after the request, but as the same user.
+	id := quickScale.ID
+	applied := quickScale
+	var revert Revert
+	revert, err = qs.revertService.Schedule(ctx, Revert{
+		Cluster:   clusterName,
+		Kind:      quickScale.Target,
+		Namespace: namespace,
+		Name:      quickScale.TargetName,
+		Change:    fmt.Sprintf("quick scale deployment %s from %d to %d replicas", name, quickScale.PreviousReplicas, quickScale.Replicas),
+	}, revertAfter, func(revertCtx context.Context) (state, message string) {
+		state, message = qs.revert(revertCtx, client, applied)
+		qs.finish(id, state, message)
+		return state, message
+	})
+	if err != nil {
+		return quickScale, err
+	}
+	quickScale.RevertID = revert.ID
+	quickScale.RevertAt = revert.RevertAt
+
+	qs.logger.Info("Quick scale applied", zap.String("id", quickScale.ID), zap.String("user", quickScale.User), zap.String("requestId", quickScale.RequestID), zap.String("cluster", clusterName), zap.String("namespace", namespace), zap.String("deployment", name), zap.String("target", quickScale.Target), zap.Int32("previousReplicas", quickScale.PreviousReplicas), zap.Int32("replicas", quickScale.Replicas), zap.Time("revertAt", quickScale.RevertAt))
+
+	qs.store(quickScale)
+	return quickScale, err
+}
+
+// validate checks a request against the guardrails and returns how long the quick scale stays in place.
+func (qs *QuickScaleService) validate(clusterName string, request QuickScaleRequest) (revertAfter time.Duration, err error) {
+	switch {
+	case clusterName == ClusterAll:
+		err = fmt.Errorf("%w: quick scaling targets a single cluster", ErrQuickScaleRejected)
+	case (request.By == 0) == (request.Percent == 0):
+		err = fmt.Errorf("%w: set exactly one of by or percent", ErrQuickScaleRejected)
+	case request.By < 0 || request.Percent < 0:
+		err = fmt.Errorf("%w: quick scaling only scales up", ErrQuickScaleRejected)
+	case request.Percent > qs.limits.MaxPercent:
+		err = fmt.Errorf("%w: percent %d exceeds the limit of %d", ErrQuickScaleRejected, request.Percent, qs.limits.MaxPercent)
+	case request.RevertAfterMinutes < 0 || request.RevertAfterMinutes > qs.limits.MaxRevertMinutes:
+		err = fmt.Errorf("%w: revertAfterMinutes must be between 1 and %d", ErrQuickScaleRejected, qs.limits.MaxRevertMinutes)
+	}
+	if err != nil {
+		return revertAfter, err
+	}
+
+	minutes := request.RevertAfterMinutes
+	if minutes == 0 {
+		minutes = qs.limits.DefaultRevertMinutes
+	}
+	revertAfter = time.Duration(minutes) * time.Minute
+	return revertAfter, err
+}
+
+// targetReplicas computes the new replica count and enforces the replica guardrails.
+func (qs *QuickScaleService) targetReplicas(current int32, request QuickScaleRequest) (target int32, err error) {
+	if request.Percent > 0 {
+		target = current + int32(math.Ceil(float64(current)*float64(request.Percent)/100))
+	} else {
+		target = current + request.By
+	}
+
+	if target <= current {
+		err = fmt.Errorf("%w: deployment has %d replicas, a percentage of it adds nothing", ErrQuickScaleRejected, current)
+		return target, err
+	}
+	if current > 0 && int64(target-current)*100 > int64(current)*int64(qs.limits.MaxPercent) {
+		err = fmt.Errorf("%w: adding %d replicas to %d exceeds the limit of %d%%", ErrQuickScaleRejected, target-current, current, qs.limits.MaxPercent)
+		return target, err
+	}
+	if target > qs.limits.MaxReplicas {
+		err = fmt.Errorf("%w: %d replicas exceeds the limit of %d", ErrQuickScaleRejected, target, qs.limits.MaxReplicas)
+		return target, err
+	}
+
+	return target, err
+}
+
+// findDeploymentHPA returns the HorizontalPodAutoscaler targeting a deployment, if there is one.
+func findDeploymentHPA(ctx context.Context, client kubernetes.Interface, namespace, name string) (hpa *autoscalingv2.HorizontalPodAutoscaler, err error) {
+	var hpas *autoscalingv2.HorizontalPodAutoscalerList
+	hpas, err = client.AutoscalingV2().HorizontalPodAutoscalers(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		err = fmt.Errorf("failed to list horizontal pod autoscalers: %w", err)
+		return hpa, err
+	}
+
+	for i := range hpas.Items {
+		ref := hpas.Items[i].Spec.ScaleTargetRef
+		if ref.Kind == KindDeployment && ref.Name == name {
+			hpa = &hpas.Items[i]
+			return hpa, err
+		}
+	}
+
+	return hpa, err
+}
+
+// raiseHPA raises an autoscaler's minReplicas to the quick scale target, raising maxReplicas too if needed.
+func (qs *QuickScaleService) raiseHPA(ctx context.Context, client kubernetes.Interface, hpa *autoscalingv2.HorizontalPodAutoscaler, quickScale *QuickScale) (err error) {
+	previousMin := int32(1)
+	if hpa.Spec.MinReplicas != nil {
+		previousMin = *hpa.Spec.MinReplicas
+	}
+
+	quickScale.Target = QuickScaleTargetHPA
+	quickScale.TargetName = hpa.Name
+	quickScale.PreviousReplicas = previousMin
+
+	target := quickScale.Replicas
+	hpa.Spec.MinReplicas = &target
+	if hpa.Spec.MaxReplicas < target {
+		quickScale.PreviousMaxReplicas = hpa.Spec.MaxReplicas
+		hpa.Spec.MaxReplicas = target
+	}
+
+	_, err = client.AutoscalingV2().HorizontalPodAutoscalers(hpa.Namespace).Update(ctx, hpa, metav1.UpdateOptions{})
+	if err != nil {
+		err = fmt.Errorf("failed to update horizontal pod autoscaler %s/%s: %w", hpa.Namespace, hpa.Name, err)
+		return err
+	}
+	return err
+}
+
+// Revert restores the replica count from before a quick scale now, unless it has been changed since.
+func (qs *QuickScaleService) Revert(id string) (quickScale QuickScale, err error) {
+	qs.mu.Lock()
+	stored, exists := qs.scales[id]
+	if exists {
+		quickScale = *stored
+	}
+	qs.mu.Unlock()
+
+	if !exists {
+		err = fmt.Errorf("%w: %s", ErrQuickScaleNotFound, id)
+		return quickScale, err
+	}
+
+	_, err = qs.revertService.RunNow(quickScale.RevertID)
+	if err != nil {
+		return quickScale, err
+	}
+
+	qs.mu.Lock()
+	quickScale = *qs.scales[id]
+	qs.mu.Unlock()
+	return quickScale, err
+}
+
+// finish records the outcome of a quick scale's revert.
+func (qs *QuickScaleService) finish(id, state, message string) {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	if stored, exists := qs.scales[id]; exists {
+		stored.State = state
+		stored.Message = message
+	}
+}
+
+// revert restores a deployment or autoscaler and reports the resulting state.
+func (qs *QuickScaleService) revert(ctx context.Context, client kubernetes.Interface, quickScale QuickScale) (state, message string) {
+	if quickScale.Target == QuickScaleTargetHPA {
+		hpa, err := client.AutoscalingV2().HorizontalPodAutoscalers(quickScale.Namespace).Get(ctx, quickScale.TargetName, metav1.GetOptions{})
+		if err != nil {
+			state = QuickScaleFailed
+			message = err.Error()
+			return state, message
+		}
+		if hpa.Spec.MinReplicas == nil || *hpa.Spec.MinReplicas != quickScale.Replicas {
+			state = QuickScaleSkipped
+			message = "minReplicas was changed after the quick scale"
+			return state, message
+		}
+
+		previous := quickScale.PreviousReplicas
+		hpa.Spec.MinReplicas = &previous
+		if quickScale.PreviousMaxReplicas > 0 && hpa.Spec.MaxReplicas == quickScale.Replicas {
+			hpa.Spec.MaxReplicas = quickScale.PreviousMaxReplicas
+		}
+		_, err = client.AutoscalingV2().HorizontalPodAutoscalers(quickScale.Namespace).Update(ctx, hpa, metav1.UpdateOptions{})
+		if err != nil {
+			state = QuickScaleFailed
+			message = err.Error()
+			return state, message
+		}
+		state = QuickScaleReverted
+		return state, message
+	}
+
+	scale, err := client.AppsV1().Deployments(quickScale.Namespace).GetScale(ctx, quickScale.Deployment, metav1.GetOptions{})
+	if err != nil {
+		state = QuickScaleFailed
+		message = err.Error()
+		return state, message
+	}
+	if scale.Spec.Replicas != quickScale.Replicas {
+		state = QuickScaleSkipped
+		message = "replicas were changed after the quick scale"
+		return state, message
+	}
+
+	scale.Spec.Replicas = quickScale.PreviousReplicas
+	_, err = client.AppsV1().Deployments(quickScale.Namespace).UpdateScale(ctx, quickScale.Deployment, scale, metav1.UpdateOptions{})
+	if err != nil {
+		state = QuickScaleFailed
+		message = err.Error()
+		return state, message
+	}
+	state = QuickScaleReverted
+	return state, message
+}
+
+// Get returns a quick scale.
+func (qs *QuickScaleService) Get(id string) (quickScale QuickScale, err error) {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	stored, exists := qs.scales[id]
+	if !exists {
+		err = fmt.Errorf("%w: %s", ErrQuickScaleNotFound, id)
+		return quickScale, err
+	}
+
+	quickScale = *stored
+	return quickScale, err
+}
+
+// owner returns who applied the quick scale and its namespace.
+func (quickScale QuickScale) owner() (user, namespace string) {
+	user, namespace = quickScale.User, quickScale.Namespace
+	return user, namespace
+}
+
+// List returns the retained quick scales, newest first.
+func (qs *QuickScaleService) List() (quickScales []QuickScale) {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	quickScales = make([]QuickScale, 0, len(qs.order))
+	for i := len(qs.order) - 1; i >= 0; i-- {
+		quickScales = append(quickScales, *qs.scales[qs.order[i]])
+	}
+	return quickScales
+}
+
+// store records a quick scale, dropping the oldest finished quick scales beyond the retention limit.
+func (qs *QuickScaleService) store(quickScale QuickScale) {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	qs.scales[quickScale.ID] = &quickScale
+	qs.order = append(qs.order, quickScale.ID)
+
+	for len(qs.order) > maxRetainedQuickScales {
+		if qs.scales[qs.order[0]].State == QuickScaleActive {
+			break
+		}
+		delete(qs.scales, qs.order[0])
+		qs.order = qs.order[1:]
+	}
+}
@@ -0,0 +1,120 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// quotaExceededPattern matches the ResourceQuota admission error. Each list holds only the exceeded resources
+// as comma separated name=quantity pairs, e.g. "requested: limits.cpu=2, used: limits.cpu=8, limited: limits.cpu=9".
+//
+//nolint:gochecknoglobals // Compiled once
+var quotaExceededPattern = regexp.MustCompile(`exceeded quota: ([^,]+), requested: (\S*), used: (\S*), limited: (\S*)`)
+
+// QuotaExceeded names the ResourceQuota that rejected a pod and the resources it would have exceeded.
+type QuotaExceeded struct {
+	Quota     string         `json:"quota"`
+	Resources []QuotaOverage `json:"resources"`
+}
+
+// QuotaOverage is one exceeded resource of a quota. Exceeded is how far used plus requested goes over the limit.
+type QuotaOverage struct {
+	Resource  string `json:"resource"`
+	Requested string `json:"requested"`
+	Used      string `json:"used"`
+	Limited   string `json:"limited"`
+	Exceeded  string `json:"exceeded,omitempty"`
+}
+
+// parseQuotaExceeded returns the quota and overages in a ResourceQuota admission error, or nil for other errors.
+func parseQuotaExceeded(message string) (exceeded *QuotaExceeded) {
+	match := quotaExceededPattern.FindStringSubmatch(message)
+	if match == nil {
+		return exceeded
+	}
+
+	requested := parseResourcePairs(match[2])
+	used := parseResourcePairs(match[3])
+	limited := parseResourcePairs(match[4])
+
+	exceeded = &QuotaExceeded{Quota: match[1], Resources: make([]QuotaOverage, 0, len(requested))}
+	for _, pair := range requested {
+		overage := QuotaOverage{
+			Resource:  pair[0],
+			Requested: pair[1],
+			Used:      lookupResourcePair(used, pair[0]),
+			Limited:   lookupResourcePair(limited, pair[0]),
+		}
+		overage.Exceeded = quotaOverage(overage)
+		exceeded.Resources = append(exceeded.Resources, overage)
+	}
+	return exceeded
+}
+
+// parseResourcePairs splits "cpu=2,memory=1Gi" into name and quantity pairs, keeping their order.
+func parseResourcePairs(list string) (pairs [][2]string) {
+	for _, item := range strings.Split(list, ",") {
+		name, quantity, found := strings.Cut(item, "=")
+		if found {
+			pairs = append(pairs, [2]string{name, quantity})
+		}
+	}
+	return pairs
+}
+
+// lookupResourcePair returns the quantity of the named resource, or an empty string.
+func lookupResourcePair(pairs [][2]string, name string) (quantity string) {
+	for _, pair := range pairs {
+		if pair[0] == name {
+			quantity = pair[1]
+			return quantity
+		}
+	}
+	return quantity
+}
+
+// quotaOverage computes used + requested - limited, or an empty string if a quantity doesn't parse.
+func quotaOverage(overage QuotaOverage) (exceeded string) {
+	total, err := resource.ParseQuantity(overage.Used)
+	if err != nil {
+		return exceeded
+	}
+	var requested, limited resource.Quantity
+	requested, err = resource.ParseQuantity(overage.Requested)
+	if err != nil {
+		return exceeded
+	}
+	limited, err = resource.ParseQuantity(overage.Limited)
+	if err != nil {
+		return exceeded
+	}
+
+	total.Add(requested)
+	total.Sub(limited)
+	exceeded = total.String()
+	return exceeded
+}
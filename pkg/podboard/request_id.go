@@ -0,0 +1,100 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the ID tying a request to the jobs, log entries and outbound calls it leads to.
+// A valid ID sent by the client or a proxy is kept; otherwise podboard generates one. Responses echo it.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the request IDs accepted from clients.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// withRequestID returns a copy of ctx carrying the request ID.
+func withRequestID(ctx context.Context, id string) (idCtx context.Context) {
+	idCtx = context.WithValue(ctx, requestIDKey{}, id)
+	return idCtx
+}
+
+// requestID returns the ID of the request ctx belongs to, or an empty string outside a request. Jobs, queued
+// actions and reverts keep their request's context values, so they report the request that started them.
+func requestID(ctx context.Context) (id string) {
+	if ctx != nil {
+		id, _ = ctx.Value(requestIDKey{}).(string)
+	}
+	return id
+}
+
+// requestIDMiddleware attaches the request ID to the request context and the response.
+func requestIDMiddleware() (handler gin.HandlerFunc) {
+	handler = func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			var err error
+			id, err = newID()
+			if err != nil {
+				respondError(c, err)
+				return
+			}
+		}
+
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(withRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+	return handler
+}
+
+// validRequestID reports whether a client supplied request ID is safe to log and pass on: short, and only
+// letters, digits, dots, dashes, underscores and colons.
+func validRequestID(id string) (valid bool) {
+	if id == "" || len(id) > maxRequestIDLength {
+		return valid
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_', r == ':':
+		default:
+			return valid
+		}
+	}
+	valid = true
+	return valid
+}
+
+// setRequestIDHeader passes the ID of the request that led to an outbound call on to the service called, so
+// its records can be traced back to the user action.
+func setRequestIDHeader(request *http.Request) {
+	id := requestID(request.Context())
+	if id != "" {
+		request.Header.Set(RequestIDHeader, id)
+	}
+}
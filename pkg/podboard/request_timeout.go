@@ -0,0 +1,101 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+// DefaultKubernetesRequestTimeout bounds each Kubernetes API request when running the podboard command.
+const DefaultKubernetesRequestTimeout = 30 * time.Second
+
+// requestTimeoutTransport bounds each Kubernetes API request, from sending it to reading the whole response, so
+// an unreachable or overloaded API server can't hang a handler or background loop. Requests also end when the
+// caller's context does, such as when the browser goes away.
+type requestTimeoutTransport struct {
+	next    http.RoundTripper
+	timeout time.Duration
+}
+
+// cancelOnClose releases a request's timeout once its response body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and releases the timeout.
+func (body *cancelOnClose) Close() (err error) {
+	err = body.ReadCloser.Close()
+	body.cancel()
+	return err
+}
+
+// withRequestTimeout makes the clients created from restConfig bound each request by timeout. Zero or negative
+// timeouts leave requests unbounded.
+func withRequestTimeout(restConfig *rest.Config, timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+	restConfig.Wrap(func(next http.RoundTripper) (wrapped http.RoundTripper) {
+		wrapped = &requestTimeoutTransport{next: next, timeout: timeout}
+		return wrapped
+	})
+}
+
+// RoundTrip sends the request under the timeout. Long-running requests are sent as they are.
+func (transport *requestTimeoutTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	if longRunningRequest(req) {
+		resp, err = transport.next.RoundTrip(req)
+		return resp, err
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), transport.timeout)
+	resp, err = transport.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return resp, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, err
+}
+
+// longRunningRequest returns true for requests that stay open by design: watches, followed logs and upgraded
+// connections such as exec and port forwarding. The caller's context ends them.
+func longRunningRequest(req *http.Request) (longRunning bool) {
+	query := req.URL.Query()
+	switch {
+	case query.Get("watch") == "true", query.Get("watch") == "1", query.Get("follow") == "true":
+		longRunning = true
+	case req.Header.Get("Upgrade") != "":
+		longRunning = true
+	case strings.HasSuffix(req.URL.Path, "/exec"), strings.HasSuffix(req.URL.Path, "/attach"), strings.HasSuffix(req.URL.Path, "/portforward"):
+		longRunning = true
+	}
+	return longRunning
+}
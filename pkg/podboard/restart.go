@@ -0,0 +1,149 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// restartedAtAnnotation is the pod template annotation kubectl rollout restart sets; changing it rolls out
+// new pods without changing anything else.
+const restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+// rolloutPollInterval is how often a rolling restart checks on its rollout.
+const rolloutPollInterval = 2 * time.Second
+
+// ErrDeploymentNotFound is returned when a deployment doesn't exist.
+var ErrDeploymentNotFound = errors.New("deployment not found")
+
+// RestartResult is the outcome of a rolling restart.
+type RestartResult struct {
+	RestartedAt   string `json:"restartedAt"`
+	RolloutStatus string `json:"rolloutStatus"`
+	Message       string `json:"message,omitempty"`
+}
+
+// RestartDeployment starts a rolling restart of a deployment, like kubectl rollout restart. It returns
+// ErrDeploymentNotFound if the deployment doesn't exist.
+func (ds *DeploymentService) RestartDeployment(ctx context.Context, clusterName, namespace, name string) (result RestartResult, err error) {
+	var client kubernetes.Interface
+	client, err = ds.kubeConfigService.GetClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return result, err
+	}
+
+	result, err = restartDeployment(ctx, client, namespace, name)
+	if err != nil {
+		ds.logger.Error("Failed to restart deployment", zap.Error(err), zap.String("cluster", clusterName), zap.String("namespace", namespace), zap.String("name", name))
+		return result, err
+	}
+
+	ds.logger.Info("Deployment restarted", zap.String("cluster", clusterName), zap.String("namespace", namespace), zap.String("name", name), zap.String("user", identityUser(ctx)), zap.String("requestId", requestID(ctx)))
+	return result, err
+}
+
+// RestartDeploymentJob returns a job function that restarts a deployment and follows the rollout until it
+// completes, reporting updated replicas that are available against desired replicas. The job fails when the
+// rollout exceeds its progress deadline or is paused.
+func (ds *DeploymentService) RestartDeploymentJob(clusterName, namespace, name string) (fn jobFunc) {
+	fn = func(ctx context.Context, report func(progress JobProgress)) (result interface{}, err error) {
+		var restart RestartResult
+		restart, err = ds.RestartDeployment(ctx, clusterName, namespace, name)
+		if err != nil {
+			return result, err
+		}
+
+		var client kubernetes.Interface
+		client, err = ds.kubeConfigService.GetClient(ctx, clusterName)
+		if err != nil {
+			err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+			return restart, err
+		}
+
+		restart.RolloutStatus, restart.Message, err = waitForRollout(ctx, client, namespace, name, report)
+		result = restart
+		return result, err
+	}
+	return fn
+}
+
+// restartDeployment stamps the deployment's pod template with the restart time.
+func restartDeployment(ctx context.Context, client kubernetes.Interface, namespace, name string) (result RestartResult, err error) {
+	result.RestartedAt = time.Now().UTC().Format(time.RFC3339)
+	patch := []byte(fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{%q:%q}}}}}`, restartedAtAnnotation, result.RestartedAt))
+
+	_, err = client.AppsV1().Deployments(namespace).Patch(ctx, name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	if apierrors.IsNotFound(err) {
+		err = fmt.Errorf("%w: %s/%s", ErrDeploymentNotFound, namespace, name)
+		return result, err
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to restart deployment %s/%s: %w", namespace, name, err)
+		return result, err
+	}
+
+	result.RolloutStatus = RolloutProgressing
+	return result, err
+}
+
+// waitForRollout polls a deployment until its rollout completes, fails or the context is cancelled.
+func waitForRollout(ctx context.Context, client kubernetes.Interface, namespace, name string, report func(progress JobProgress)) (status, message string, err error) {
+	for {
+		var deployment *appsv1.Deployment
+		deployment, err = client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			err = fmt.Errorf("failed to get deployment %s/%s: %w", namespace, name, err)
+			return status, message, err
+		}
+
+		desired := int32(1)
+		if deployment.Spec.Replicas != nil {
+			desired = *deployment.Spec.Replicas
+		}
+		status, message = deploymentRolloutStatus(deployment, desired)
+		report(JobProgress{Done: int(min(deployment.Status.UpdatedReplicas, deployment.Status.AvailableReplicas)), Total: int(desired), Message: message})
+
+		switch status {
+		case RolloutComplete:
+			return status, message, err
+		case RolloutFailed, RolloutPaused:
+			err = fmt.Errorf("rollout of deployment %s/%s stopped: %s", namespace, name, message)
+			return status, message, err
+		}
+
+		if !sleepContext(ctx, rolloutPollInterval) {
+			return status, message, err
+		}
+	}
+}
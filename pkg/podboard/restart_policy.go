@@ -0,0 +1,80 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Container types. Sidecars are native sidecars: init containers with restartPolicy Always, which keep running
+// alongside the app containers and restart on their own.
+const (
+	ContainerTypeApp     = "app"
+	ContainerTypeInit    = "init"
+	ContainerTypeSidecar = "sidecar"
+)
+
+// isSidecar returns true for an init container that is a native sidecar.
+func isSidecar(container corev1.Container) (sidecar bool) {
+	sidecar = container.RestartPolicy != nil && *container.RestartPolicy == corev1.ContainerRestartPolicyAlways
+	return sidecar
+}
+
+// containerRestartPolicy returns a container's type and the restart policy the kubelet applies to it: a
+// sidecar's own Always, the pod's policy for app containers, and for other init containers OnFailure, or Never
+// in pods that never restart.
+func containerRestartPolicy(pod *corev1.Pod, container corev1.Container, init bool) (containerType, policy string) {
+	podPolicy := pod.Spec.RestartPolicy
+	if podPolicy == "" {
+		podPolicy = corev1.RestartPolicyAlways
+	}
+
+	switch {
+	case init && isSidecar(container):
+		containerType, policy = ContainerTypeSidecar, string(corev1.ContainerRestartPolicyAlways)
+	case init && podPolicy == corev1.RestartPolicyNever:
+		containerType, policy = ContainerTypeInit, string(corev1.RestartPolicyNever)
+	case init:
+		containerType, policy = ContainerTypeInit, string(corev1.RestartPolicyOnFailure)
+	default:
+		containerType, policy = ContainerTypeApp, string(podPolicy)
+	}
+	return containerType, policy
+}
+
+// sidecarStatuses returns the statuses of a pod's native sidecars.
+func sidecarStatuses(pod *corev1.Pod) (statuses []corev1.ContainerStatus) {
+	sidecars := make(map[string]bool)
+	for _, container := range pod.Spec.InitContainers {
+		if isSidecar(container) {
+			sidecars[container.Name] = true
+		}
+	}
+
+	for _, status := range pod.Status.InitContainerStatuses {
+		if sidecars[status.Name] {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
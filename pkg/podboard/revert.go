@@ -0,0 +1,281 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Revert states.
+const (
+	RevertPending   = "Pending"
+	RevertRunning   = "Reverting"
+	RevertDone      = "Reverted"
+	RevertSkipped   = "Skipped"
+	RevertFailed    = "Failed"
+	RevertCancelled = "Cancelled"
+)
+
+const (
+	revertCheckInterval = 5 * time.Second
+	minRevertAfter      = time.Minute
+	maxRevertAfter      = 24 * time.Hour
+	maxRetainedReverts  = 200
+)
+
+var (
+	// ErrRevertNotFound is returned when a revert ID is unknown.
+	ErrRevertNotFound = errors.New("revert not found")
+	// ErrInvalidRevertAfter is returned when a revertAfter value can't be used.
+	ErrInvalidRevertAfter = errors.New("invalid revertAfter")
+)
+
+// Revert is a scheduled undo of a temporary change.
+type Revert struct {
+	ID         string     `json:"id"`
+	Cluster    string     `json:"cluster,omitempty"`
+	Kind       string     `json:"kind"`
+	Namespace  string     `json:"namespace,omitempty"`
+	Name       string     `json:"name"`
+	Change     string     `json:"change"`
+	User       string     `json:"user,omitempty"`
+	RequestID  string     `json:"requestId,omitempty"`
+	State      string     `json:"state"`
+	Message    string     `json:"message,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	RevertAt   time.Time  `json:"revertAt"`
+	RevertedAt *time.Time `json:"revertedAt,omitempty"`
+}
+
+// revertFunc restores the state from before a change. It returns RevertDone, RevertSkipped when the object
+// was changed again in the meantime, or RevertFailed, with an optional message.
+type revertFunc func(ctx context.Context) (state, message string)
+
+// scheduledRevert is a revert with the context and function used to perform it.
+type scheduledRevert struct {
+	revert Revert
+	ctx    context.Context
+	fn     revertFunc
+}
+
+// RevertService restores temporary changes once their revert time has passed.
+// Scheduled reverts are kept in memory, so reverts still pending when podboard restarts are lost.
+type RevertService struct {
+	logger *zap.Logger
+
+	mu      sync.Mutex
+	reverts map[string]*scheduledRevert
+	order   []string
+}
+
+// NewRevertService creates a new revert service. Call Run to start reverting due changes.
+func NewRevertService(logger *zap.Logger) (service *RevertService) {
+	service = &RevertService{
+		logger:  logger,
+		reverts: make(map[string]*scheduledRevert),
+	}
+	return service
+}
+
+// ParseRevertAfter parses a revertAfter duration such as "30m". An empty value means no revert.
+func ParseRevertAfter(value string) (after time.Duration, err error) {
+	if value == "" {
+		return after, err
+	}
+
+	after, err = time.ParseDuration(value)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ErrInvalidRevertAfter, err)
+		return after, err
+	}
+	if after < minRevertAfter || after > maxRevertAfter {
+		err = fmt.Errorf("%w: must be between %s and %s", ErrInvalidRevertAfter, minRevertAfter, maxRevertAfter)
+		return after, err
+	}
+
+	return after, err
+}
+
+// Schedule registers fn to undo a change after the given duration. The revert runs with ctx's values,
+// including the requesting user's identity, but is not cancelled with it.
+func (rs *RevertService) Schedule(ctx context.Context, revert Revert, after time.Duration, fn revertFunc) (scheduled Revert, err error) {
+	revert.ID, err = newID()
+	if err != nil {
+		return scheduled, err
+	}
+	revert.User = identityUser(ctx)
+	revert.RequestID = requestID(ctx)
+	revert.State = RevertPending
+	revert.CreatedAt = time.Now().UTC()
+	revert.RevertAt = revert.CreatedAt.Add(after)
+
+	rs.mu.Lock()
+	rs.reverts[revert.ID] = &scheduledRevert{revert: revert, ctx: context.WithoutCancel(ctx), fn: fn}
+	rs.order = append(rs.order, revert.ID)
+	rs.prune()
+	rs.mu.Unlock()
+
+	rs.logger.Info("Revert scheduled", zap.String("id", revert.ID), zap.String("user", revert.User), zap.String("requestId", revert.RequestID), zap.String("cluster", revert.Cluster), zap.String("kind", revert.Kind), zap.String("namespace", revert.Namespace), zap.String("name", revert.Name), zap.String("change", revert.Change), zap.Time("revertAt", revert.RevertAt))
+
+	scheduled = revert
+	return scheduled, err
+}
+
+// Run reverts due changes every interval until the context is cancelled.
+func (rs *RevertService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rs.runDue()
+		}
+	}
+}
+
+// runDue performs every pending revert whose time has come.
+func (rs *RevertService) runDue() {
+	now := time.Now()
+
+	rs.mu.Lock()
+	var due []string
+	for _, id := range rs.order {
+		if scheduled := rs.reverts[id]; scheduled.revert.State == RevertPending && !now.Before(scheduled.revert.RevertAt) {
+			due = append(due, id)
+		}
+	}
+	rs.mu.Unlock()
+
+	for _, id := range due {
+		_, _ = rs.RunNow(id)
+	}
+}
+
+// RunNow performs a pending revert immediately. Reverts that already ran are returned unchanged.
+func (rs *RevertService) RunNow(id string) (revert Revert, err error) {
+	rs.mu.Lock()
+	scheduled, exists := rs.reverts[id]
+	if !exists {
+		rs.mu.Unlock()
+		err = fmt.Errorf("%w: %s", ErrRevertNotFound, id)
+		return revert, err
+	}
+	if scheduled.revert.State != RevertPending {
+		revert = scheduled.revert
+		rs.mu.Unlock()
+		return revert, err
+	}
+	scheduled.revert.State = RevertRunning
+	rs.mu.Unlock()
+
+	state, message := scheduled.fn(scheduled.ctx)
+
+	rs.mu.Lock()
+	revertedAt := time.Now().UTC()
+	scheduled.revert.State = state
+	scheduled.revert.Message = message
+	scheduled.revert.RevertedAt = &revertedAt
+	revert = scheduled.revert
+	rs.mu.Unlock()
+
+	rs.logger.Info("Revert finished", zap.String("id", id), zap.String("user", revert.User), zap.String("requestId", revert.RequestID), zap.String("kind", revert.Kind), zap.String("namespace", revert.Namespace), zap.String("name", revert.Name), zap.String("state", state), zap.String("message", message))
+	return revert, err
+}
+
+// Cancel drops a pending revert, keeping the change in place.
+func (rs *RevertService) Cancel(ctx context.Context, id string) (revert Revert, err error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	scheduled, exists := rs.reverts[id]
+	if !exists {
+		err = fmt.Errorf("%w: %s", ErrRevertNotFound, id)
+		return revert, err
+	}
+
+	if scheduled.revert.State == RevertPending {
+		scheduled.revert.State = RevertCancelled
+		rs.logger.Info("Revert cancelled", zap.String("id", id), zap.String("user", identityUser(ctx)), zap.String("requestId", requestID(ctx)))
+	}
+
+	revert = scheduled.revert
+	return revert, err
+}
+
+// Get returns a revert.
+func (rs *RevertService) Get(id string) (revert Revert, err error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	scheduled, exists := rs.reverts[id]
+	if !exists {
+		err = fmt.Errorf("%w: %s", ErrRevertNotFound, id)
+		return revert, err
+	}
+
+	revert = scheduled.revert
+	return revert, err
+}
+
+// owner returns who made the change the revert undoes and its namespace.
+func (revert Revert) owner() (user, namespace string) {
+	user, namespace = revert.User, revert.Namespace
+	return user, namespace
+}
+
+// List returns the retained reverts, newest first. With pendingOnly, only reverts still to run are returned.
+func (rs *RevertService) List(pendingOnly bool) (reverts []Revert) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	reverts = make([]Revert, 0, len(rs.order))
+	for i := len(rs.order) - 1; i >= 0; i-- {
+		revert := rs.reverts[rs.order[i]].revert
+		if pendingOnly && revert.State != RevertPending {
+			continue
+		}
+		reverts = append(reverts, revert)
+	}
+	return reverts
+}
+
+// prune drops the oldest finished reverts beyond the retention limit. The caller must hold the lock.
+func (rs *RevertService) prune() {
+	for len(rs.order) > maxRetainedReverts {
+		oldest := rs.reverts[rs.order[0]]
+		if oldest.revert.State == RevertPending || oldest.revert.State == RevertRunning {
+			return
+		}
+		delete(rs.reverts, rs.order[0])
+		rs.order = rs.order[1:]
+	}
+}
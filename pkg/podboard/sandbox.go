@@ -0,0 +1,102 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// eventReasonSandboxChanged is the reason of the event the kubelet records when it kills and recreates a pod's
+// sandbox, for example after the container runtime restarted or the pod network was lost. Every container of
+// the pod restarts with it, so these restarts point at the node rather than the application.
+const eventReasonSandboxChanged = "SandboxChanged"
+
+// sandboxRestartWindow is how soon after a sandbox recreation a container restart is attributed to it.
+const sandboxRestartWindow = 2 * time.Minute
+
+// sandboxCacheTTL is how long sandbox restart counts are reused, so polling pod listings don't list events
+// every time.
+const sandboxCacheTTL = 15 * time.Second
+
+// sandboxRestarts returns the number of sandbox recreations of each pod in the namespace, keyed by pod UID, as
+// recorded in SandboxChanged events. Events expire, typically after an hour, so older recreations aren't
+// counted. Counts are reused for sandboxCacheTTL. It is best effort: without permission to list events, pods
+// are listed without the count. The returned map is shared and must not be modified.
+func (ps *PodService) sandboxRestarts(ctx context.Context, client kubernetes.Interface, clusterName, namespace string) (restarts map[types.UID]int32) {
+	identity, _ := IdentityFromContext(ctx)
+	key := clientCacheKey(clusterName, KubeContextFromContext(ctx), identity) + "|" + namespace
+	restarts, ok := ps.sandboxCache.Get(key)
+	if ok {
+		return restarts
+	}
+
+	restarts = make(map[types.UID]int32)
+
+	selector := fields.Set{"involvedObject.kind": KindPod, "reason": eventReasonSandboxChanged}.AsSelector().String()
+	events, err := client.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		ps.logger.Debug("Failed to list sandbox events", zap.Error(err), zap.String("cluster", clusterName), zap.String("namespace", namespace))
+		// Without permission the next poll would fail the same way, so the empty counts are cached too.
+		ps.sandboxCache.Store(key, restarts)
+		return restarts
+	}
+
+	for i := range events.Items {
+		restarts[events.Items[i].InvolvedObject.UID] += eventToEventInfo(&events.Items[i], timeViewFromContext(ctx)).Count
+	}
+	ps.sandboxCache.Store(key, restarts)
+	return restarts
+}
+
+// attributeContainerRestarts tells container restarts caused by a sandbox recreation apart from in-place
+// restarts of a single container, which point at the application instead. The timeline must be sorted.
+func attributeContainerRestarts(timeline []TimelineEntry) {
+	var lastSandbox time.Time
+	for i := range timeline {
+		switch {
+		case timeline[i].Type == TimelineSandbox:
+			lastSandbox = timeline[i].at
+		case timeline[i].Reason == "Restarted":
+			if !lastSandbox.IsZero() && timeline[i].at.Sub(lastSandbox) <= sandboxRestartWindow {
+				timeline[i].Message = "restarted with the pod sandbox"
+				continue
+			}
+			timeline[i].Message = "restarted in place"
+		}
+	}
+}
+
+// isSandboxCondition reports whether a pod condition tracks the pod sandbox. PodReadyToStartContainers
+// (PodHasNetwork before Kubernetes 1.29) turns False when the sandbox is lost and True once it is recreated.
+func isSandboxCondition(conditionType corev1.PodConditionType) (sandbox bool) {
+	sandbox = conditionType == corev1.PodReadyToStartContainers || conditionType == "PodHasNetwork"
+	return sandbox
+}
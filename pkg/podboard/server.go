@@ -23,42 +23,246 @@ SOFTWARE.
 package podboard
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
-// RunServer starts the podboard web server.
-func RunServer(address, domain string, logger *zap.Logger) (err error) {
-	gin.SetMode(gin.ReleaseMode)
+const (
+	defaultEventLimit = 100
 
-	domain = getDomainFromEnvOrDefault(domain)
-	fmt.Printf("Domain: %s\n", domain)
+	// maxRequestBody caps the JSON bodies of API requests, which are a few hundred bytes.
+	maxRequestBody = 1 << 20
 
-	// Initialize services
-	kubeConfigService := NewKubeConfigService(logger)
-	podService := NewPodService(kubeConfigService, logger)
+	// clusterHeader lets reverse proxies pin a hostname to a cluster.
+	clusterHeader = "X-Podboard-Cluster"
+	// contextHeader selects a kubeconfig context, which fixes both the cluster and the credentials used.
+	contextHeader     = "X-Podboard-Context"
+	clusterContextKey = "podboard.cluster"
+)
+
+// RunServer starts the podboard web server and serves until it fails.
+func RunServer(config ServerConfig, logger *zap.Logger) (err error) {
+	config.Domain = getDomainFromEnvOrDefault(config.Domain)
+	fmt.Printf("Domain: %s\n", config.Domain)
+
+	server, err := NewServer(config, WithLogger(logger))
+	if err != nil {
+		return err
+	}
+
+	err = server.Start()
+	return err
+}
+
+// newAPIServices creates the services behind the routes from the server settings and the config file, and
+// starts their background loops until ctx is done.
+func newAPIServices(ctx context.Context, config ServerConfig, fileConfig FileConfig, kubeConfigService ClientFactory, authenticate Authenticator, logger *zap.Logger) (services *apiServices, err error) {
+	derivedStatuses, err := NewDerivedStatuses(fileConfig.Statuses, logger)
+	if err != nil {
+		return services, err
+	}
+
+	annotations, err := NewAnnotationFilter(fileConfig.Annotations)
+	if err != nil {
+		return services, err
+	}
+
+	access, err := NewAccessPolicy(fileConfig.Access, authenticate != nil)
+	if err != nil {
+		return services, err
+	}
+
+	cors, err := NewCORSPolicy(config.CORSAllowedOrigins, config.CORSAllowedMethods, config.CORSAllowedHeaders, config.CORSAllowCredentials)
+	if err != nil {
+		return services, err
+	}
+
+	podService := NewPodService(config, derivedStatuses, annotations, kubeConfigService, logger)
+
+	services = &apiServices{
+		config:            config,
+		authenticate:      authenticate,
+		access:            access,
+		cors:              cors,
+		kubeConfigService: kubeConfigService,
+		podService:        podService,
+		deploymentService: NewDeploymentService(config, derivedStatuses, kubeConfigService, logger),
+		migrationService:  NewMigrationService(kubeConfigService, logger),
+		nodeService:       NewNodeService(podService, kubeConfigService, logger),
+		infraService:      NewInfrastructureService(kubeConfigService, logger),
+		certificates:      NewCertificateService(kubeConfigService, logger),
+		onboarding:        NewOnboardingService(kubeConfigService, logger),
+		labelService:      NewLabelService(kubeConfigService, logger),
+		errorBudget:       NewErrorBudget(defaultErrorBudgetWindow, defaultErrorBudgetThreshold, defaultErrorBudgetMinSamples),
+		staleCache:        NewStaleCache(defaultStaleMaxAge, defaultStaleMaxEntries),
+		clusterWarmer:     NewClusterWarmer(kubeConfigService, config.WarmClusters, logger),
+		loadShedder:       NewLoadShedder(config.MaxConcurrentRequests, config.MaxHeapBytes, logger),
+	}
+	if config.ConfirmDestructive {
+		services.confirmations = NewConfirmationStore(config.Clock)
+	}
+	// Clients from a kubeconfig report the health of their clusters.
+	if kcs, ok := kubeConfigService.(*KubeConfigService); ok {
+		services.clusterHealth = kcs.Health()
+		services.clusterRetries = kcs.Retries()
+	}
+
+	err = setupWorkflowServices(ctx, services, fileConfig, logger)
+	if err != nil {
+		return services, err
+	}
+
+	err = setupIntegrationServices(services, fileConfig, logger)
+	if err != nil {
+		return services, err
+	}
+
+	err = startPodPublishers(ctx, config, fileConfig, podService, logger)
+	if err != nil {
+		return services, err
+	}
+
+	services.cachePrimer = NewCachePrimer(config.PrimeCache, podService, kubeConfigService, config.Clock, logger)
+	go services.cachePrimer.Run(ctx)
+	return services, err
+}
+
+// setupWorkflowServices adds the services tracking changes over time: jobs, pending actions, and the quick scales
+// and reverts, whose background loops run until ctx is done.
+func setupWorkflowServices(ctx context.Context, services *apiServices, fileConfig FileConfig, logger *zap.Logger) (err error) {
+	services.jobService = NewJobService(logger)
+
+	services.revertService = NewRevertService(logger)
+	go services.revertService.Run(ctx, revertCheckInterval)
+
+	services.pendingActions = NewPendingActionService(logger)
+	go services.pendingActions.Run(ctx, pendingActionCheckInterval)
+
+	services.quickScaleService, err = NewQuickScaleService(fileConfig.QuickScale, services.revertService, services.kubeConfigService, logger)
+	return err
+}
+
+// setupIntegrationServices adds the optional services configured in the config file: actions, tickets,
+// Alertmanager, GitHub, canaries and the status page. Unconfigured services are created disabled.
+func setupIntegrationServices(services *apiServices, fileConfig FileConfig, logger *zap.Logger) (err error) {
+	config := services.config
+
+	services.actionService, err = NewActionService(config, fileConfig.Actions, services.kubeConfigService, logger)
+	if err != nil {
+		return err
+	}
+
+	services.ticketService, err = NewTicketService(config, fileConfig.Tickets, services.podService, logger)
+	if err != nil {
+		return err
+	}
+
+	services.alertmanager, err = NewAlertmanagerService(config, fileConfig.Alertmanager, logger)
+	if err != nil {
+		return err
+	}
+
+	services.githubService, err = NewGitHubService(config, fileConfig.GitHub, services.kubeConfigService, services.jobService, logger)
+	if err != nil {
+		return err
+	}
+
+	services.canaryService, err = NewCanaryService(fileConfig.Canary, services.podService, services.actionService, logger)
+	if err != nil {
+		return err
+	}
+
+	services.statusService, err = NewStatusService(fileConfig.Status, services.podService, logger)
+	return err
+}
+
+// startPodPublishers starts sending pod changes to the configured webhooks and event bus until ctx is done.
+func startPodPublishers(ctx context.Context, config ServerConfig, fileConfig FileConfig, podService *PodService, logger *zap.Logger) (err error) {
+	podWebhookService, err := NewPodWebhookService(config, fileConfig.PodWebhooks, podService, logger)
+	if err != nil {
+		return err
+	}
+
+	eventBusService, err := NewEventBusService(config, fileConfig.EventBus, podService, logger)
+	if err != nil {
+		return err
+	}
+
+	podWebhookService.Run(ctx)
+	eventBusService.Run(ctx)
+	return err
+}
+
+// runBackgroundPreflight runs the preflight checks and logs their warnings. It runs in the background so
+// unreachable clusters don't delay startup.
+func runBackgroundPreflight(ctx context.Context, config ServerConfig, kubeConfigService *KubeConfigService, logger *zap.Logger) {
+	// The server binds its own address, so skip the port check.
+	config.Address = ""
+	report := RunPreflight(ctx, config, kubeConfigService)
+	report.LogWarnings(logger)
+}
+
+// validateServerConfig checks the settings that would otherwise only fail once in use.
+func validateServerConfig(config ServerConfig) (err error) {
+	if config.UIDir != "" {
+		err = CheckUIDir(config.UIDir)
+		if err != nil {
+			return err
+		}
+	}
 
-	// Set up router
-	router := setupRouter()
+	if config.DeleteUndoWindow < 0 || config.DeleteUndoWindow > maxUndoWindow {
+		err = fmt.Errorf("%w: --delete-undo-window must be between 0s and %s", ErrInvalidUndoWindow, maxUndoWindow)
+		return err
+	}
 
-	// Setup routes
-	setupAPIRoutes(router, podService, kubeConfigService)
-	SetupUIRoutes(router)
+	_, err = NewNamespacePolicy(config.AllowedNamespaces, config.DeniedNamespaces)
+	if err != nil {
+		return err
+	}
 
-	logger.Info("Server starting", zap.String("address", address))
+	err = checkClusterPatterns(config.Clusters)
+	if err != nil {
+		return err
+	}
 
-	runErr := router.Run(address)
-	if runErr != nil {
-		err = fmt.Errorf("failed to start server: %w", runErr)
+	_, err = NewCORSPolicy(config.CORSAllowedOrigins, config.CORSAllowedMethods, config.CORSAllowedHeaders, config.CORSAllowCredentials)
+	if err != nil {
 		return err
 	}
 
+	_, err = LoadTimeZone(config.TimeZone)
 	return err
 }
 
+// newHTTPServer creates the HTTP server with the configured timeouts. Streams lift the write timeout for
+// themselves, so it only limits regular responses.
+func newHTTPServer(config ServerConfig, handler http.Handler) (server *http.Server) {
+	idleTimeout := config.IdleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultIdleTimeout
+	}
+
+	server = &http.Server{
+		Addr:              config.Address,
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+	return server
+}
+
 func getDomainFromEnvOrDefault(domain string) (result string) {
 	result = domain
 	if result == "" {
@@ -71,6 +275,7 @@ func setupRouter() (router *gin.Engine) {
 	router = gin.New()
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
+	router.Use(requestIDMiddleware())
 
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "healthy"})
@@ -79,65 +284,1535 @@ func setupRouter() (router *gin.Engine) {
 	return router
 }
 
-func setupAPIRoutes(router *gin.Engine, podService *PodService, kubeConfigService *KubeConfigService) {
+// clusterMiddleware resolves the cluster a request targets and stores it in the context under clusterContextKey.
+// The X-Podboard-Cluster header takes precedence over the cluster query parameter so reverse proxies can
+// pin a hostname to a cluster. Named clusters must exist in the kubeconfig; in cluster the name is ignored.
+// A kubeconfig context selected with the X-Podboard-Context header or context query parameter determines the
+// cluster and is attached to the request context, so clients use that context's user instead of a guessed one.
+func clusterMiddleware(kubeConfigService ClientFactory) (handler gin.HandlerFunc) {
+	handler = func(c *gin.Context) {
+		clusterName := c.GetHeader(clusterHeader)
+		if clusterName == "" {
+			clusterName = c.Query("cluster")
+		}
+		if clusterName == "" {
+			clusterName = AccessFromContext(c.Request.Context()).Binding.DefaultCluster
+		}
+
+		kubeContext := c.GetHeader(contextHeader)
+		if kubeContext == "" {
+			kubeContext = c.Query("context")
+		}
+		if kubeContext != "" && !kubeConfigService.IsInCluster() {
+			contextCluster, err := kubeConfigService.ContextCluster(kubeContext)
+			if err != nil {
+				respondErrorCode(c, 400, err.Error())
+				return
+			}
+			if clusterName != "" && clusterName != contextCluster {
+				respondErrorCode(c, 400, fmt.Sprintf("context %q belongs to cluster %q, not %q", kubeContext, contextCluster, clusterName))
+				return
+			}
+			clusterName = contextCluster
+			c.Request = c.Request.WithContext(WithKubeContext(c.Request.Context(), kubeContext))
+		}
+
+		if clusterName != "" && clusterName != ClusterAll && !kubeConfigService.IsInCluster() {
+			exists, err := kubeConfigService.HasCluster(clusterName)
+			if err != nil {
+				respondError(c, err)
+				return
+			}
+			if !exists {
+				respondErrorCode(c, 400, fmt.Sprintf("unknown cluster %q", clusterName))
+				return
+			}
+		}
+
+		c.Set(clusterContextKey, clusterName)
+		c.Next()
+	}
+	return handler
+}
+
+// apiServices bundles the services used by the API route handlers.
+type apiServices struct {
+	config            ServerConfig
+	authenticate      Authenticator
+	access            *AccessPolicy
+	cors              *CORSPolicy
+	kubeConfigService ClientFactory
+	clusterHealth     *ClusterHealthTracker
+	clusterRetries    *RetryTracker
+	podService        *PodService
+	deploymentService *DeploymentService
+	actionService     *ActionService
+	ticketService     *TicketService
+	alertmanager      *AlertmanagerService
+	migrationService  *MigrationService
+	jobService        *JobService
+	quickScaleService *QuickScaleService
+	revertService     *RevertService
+	pendingActions    *PendingActionService
+	nodeService       *NodeService
+	infraService      *InfrastructureService
+	certificates      *CertificateService
+	onboarding        *OnboardingService
+	labelService      *LabelService
+	errorBudget       *ErrorBudget
+	staleCache        *StaleCache
+	statusService     *StatusService
+	githubService     *GitHubService
+	canaryService     *CanaryService
+	clusterWarmer     *ClusterWarmer
+	cachePrimer       *CachePrimer
+	loadShedder       *LoadShedder
+	confirmations     *ConfirmationStore
+}
+
+func setupAPIRoutes(router *gin.Engine, services *apiServices) {
+	router.Use(corsMiddleware(services.cors))
+	setupReadinessRoute(router, services)
+
 	api := router.Group("/api")
+	api.Use(loadSheddingMiddleware(services.loadShedder))
+	api.Use(compressionMiddleware(services.config.CompressionMinSize))
+	api.Use(csrfMiddleware(services.config.CSRFProtection, services.cors))
+	api.Use(identityMiddleware(services.config, services.authenticate, services.access))
+	api.Use(accessMiddleware())
+	api.Use(namespacePolicyMiddleware(services.podService.namespacePolicy))
+	api.Use(clusterMiddleware(services.kubeConfigService))
+	api.Use(clusterUsageMiddleware(services.clusterWarmer))
+	api.Use(timeMiddleware(services.config))
+	api.Use(staleFallbackMiddleware(services))
 
-	// Clusters endpoint - only available when not running in cluster
-	api.GET("/clusters", func(c *gin.Context) {
-		if kubeConfigService.IsInCluster() {
-			c.JSON(200, gin.H{
-				"inCluster": true,
-				"clusters":  []ClusterInfo{},
-			})
+	setupClusterRoutes(api, services)
+	setupCacheRoutes(api, services)
+	setupCSRFRoutes(api, services)
+	setupPodRoutes(api, services)
+	setupPodDetailRoutes(api, services)
+	setupEventRoutes(api, services)
+	setupDeploymentRoutes(api, services)
+	setupActionRoutes(api, services)
+	setupMigrationRoutes(api, services)
+	setupNodeRoutes(api, services)
+	setupRevertRoutes(api, services)
+	setupPendingActionRoutes(api, services)
+	setupJobRoutes(api, services)
+	setupCanaryRoutes(api, services)
+	setupOnboardingRoutes(api, services)
+	setupAlertRoutes(api, services)
+}
+
+func setupAlertRoutes(api *gin.RouterGroup, services *apiServices) {
+	// Alerts firing in a namespace and the silences affecting it, from Alertmanager
+	api.GET("/alerts", func(c *gin.Context) {
+		alerts, err := services.alertmanager.NamespaceAlerts(c.Request.Context(), c.GetString(clusterContextKey), c.DefaultQuery("namespace", "default"))
+		if err != nil {
+			respondAlertmanagerError(c, err)
+			return
+		}
+		c.JSON(200, alerts)
+	})
+
+	// Silence a workload's alerts during remediation
+	api.POST("/deployments/:namespace/:name/silence", func(c *gin.Context) {
+		silenceWorkload(c, services, KindDeployment)
+	})
+	api.POST("/statefulsets/:namespace/:name/silence", func(c *gin.Context) {
+		silenceWorkload(c, services, KindStatefulSet)
+	})
+	api.POST("/daemonsets/:namespace/:name/silence", func(c *gin.Context) {
+		silenceWorkload(c, services, KindDaemonSet)
+	})
+}
+
+// silenceWorkload handles a silence request for the given workload kind.
+func silenceWorkload(c *gin.Context, services *apiServices, kind string) {
+	var request SilenceRequest
+	bindErr := bindJSON(c, &request)
+	if bindErr != nil {
+		respondErrorCode(c, 400, "request body must be JSON with a comment field")
+		return
+	}
+
+	silence, err := services.alertmanager.SilenceWorkload(c.Request.Context(), c.GetString(clusterContextKey), kind, c.Param("namespace"), c.Param("name"), request)
+	if err != nil {
+		respondAlertmanagerError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"silence": silence})
+}
+
+// respondAlertmanagerError writes an Alertmanager error: 404 when it isn't configured, 403 offline and 400 for
+// invalid silences.
+func respondAlertmanagerError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrAlertmanagerDisabled):
+		respondErrorCode(c, 404, err.Error())
+	case errors.Is(err, ErrOffline):
+		respondErrorCode(c, 403, err.Error())
+	case errors.Is(err, ErrInvalidSilence):
+		respondErrorCode(c, 400, err.Error())
+	default:
+		respondError(c, err)
+	}
+}
+
+func setupOnboardingRoutes(api *gin.RouterGroup, services *apiServices) {
+	// The Role, RoleBinding, NetworkPolicy and board ConfigMap that onboard a team namespace, as YAML (default) or JSON
+	api.GET("/onboarding/:namespace", func(c *gin.Context) {
+		request, ok := onboardingQuery(c)
+		if !ok {
+			return
+		}
+
+		format := c.DefaultQuery("format", ManifestFormatYAML)
+		manifest, err := services.onboarding.Generate(c.Request.Context(), c.GetString(clusterContextKey), request, format)
+		switch {
+		case errors.Is(err, ErrInvalidManifestFormat), errors.Is(err, ErrInvalidOnboarding):
+			respondErrorCode(c, 400, err.Error())
+		case err != nil:
+			respondError(c, err)
+		case format == ManifestFormatJSON:
+			c.Data(200, "application/json; charset=utf-8", manifest)
+		default:
+			c.Data(200, "application/yaml; charset=utf-8", manifest)
+		}
+	})
+
+	// Create or update the onboarding objects in the namespace
+	api.POST("/onboarding/:namespace/apply", func(c *gin.Context) {
+		request, ok := onboardingQuery(c)
+		if !ok {
+			return
+		}
+
+		applied, err := services.onboarding.Apply(c.Request.Context(), c.GetString(clusterContextKey), request)
+		switch {
+		case errors.Is(err, ErrInvalidOnboarding):
+			respondErrorCode(c, 400, err.Error())
+		case err != nil:
+			respondError(c, err)
+		default:
+			c.JSON(200, gin.H{"namespace": request.Namespace, "objects": applied})
+		}
+	})
+}
+
+// onboardingQuery reads an onboarding request from the namespace path parameter and the query. It responds
+// with 400 and returns false if a metric port isn't a number or the request targets every cluster.
+func onboardingQuery(c *gin.Context) (request OnboardingRequest, ok bool) {
+	if c.GetString(clusterContextKey) == ClusterAll {
+		respondErrorCode(c, 400, "onboarding runs against a single cluster")
+		return request, ok
+	}
+
+	request = OnboardingRequest{
+		Namespace:               c.Param("namespace"),
+		Team:                    c.Query("team"),
+		ServiceAccount:          c.Query("serviceAccount"),
+		ServiceAccountNamespace: c.Query("serviceAccountNamespace"),
+		APIServerCIDRs:          c.QueryArray("apiServerCIDR"),
+	}
+	for _, value := range c.QueryArray("metricPort") {
+		port, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			respondErrorCode(c, 400, fmt.Sprintf("metricPort %q is not a number", value))
+			return request, ok
+		}
+		request.MetricPorts = append(request.MetricPorts, int32(port))
+	}
+	ok = true
+	return request, ok
+}
+
+func setupCanaryRoutes(api *gin.RouterGroup, services *apiServices) {
+	// Canary pods compared with stable pods, told apart by their track label
+	api.GET("/canary", func(c *gin.Context) {
+		namespace, labelSelector, ok := canaryQuery(c)
+		if !ok {
 			return
 		}
 
-		clusters, err := kubeConfigService.GetClusters()
+		analysis, err := services.canaryService.Analyze(c.Request.Context(), c.GetString(clusterContextKey), namespace, labelSelector)
 		if err != nil {
-			c.JSON(500, gin.H{"error": err.Error()})
+			respondError(c, err)
 			return
 		}
+		c.JSON(200, gin.H{"analysis": analysis})
+	})
 
-		c.JSON(200, gin.H{
-			"inCluster": false,
-			"clusters":  clusters,
-		})
+	// Promote or abort the canary with the action configured for the decision
+	api.POST("/canary/:decision", confirmMiddleware(services.confirmations, false), func(c *gin.Context) {
+		decision := c.Param("decision")
+		if decision != CanaryPromote && decision != CanaryAbort {
+			respondErrorCode(c, 400, "decision must be promote or abort")
+			return
+		}
+		namespace, labelSelector, ok := canaryQuery(c)
+		if !ok {
+			return
+		}
+
+		result, err := services.canaryService.Decide(c.Request.Context(), c.GetString(clusterContextKey), namespace, labelSelector, decision)
+		switch {
+		case errors.Is(err, ErrCanaryActionNotConfigured), errors.Is(err, ErrActionNotFound):
+			respondErrorCode(c, 404, err.Error())
+		case errors.Is(err, ErrCanaryTarget):
+			respondErrorCode(c, 409, err.Error())
+		case errors.Is(err, ErrOffline):
+			respondErrorCode(c, 403, err.Error())
+		case err != nil:
+			respondError(c, err)
+		default:
+			c.JSON(200, gin.H{"result": result})
+		}
 	})
+}
 
-	api.GET("/namespaces", func(c *gin.Context) {
-		clusterName := c.Query("cluster")
-		namespaces, err := podService.GetNamespaces(c.Request.Context(), clusterName)
+// canaryQuery reads the namespace and label selector of a canary request, which identify one workload's
+// pods. It responds with 400 and returns false if either is missing.
+func canaryQuery(c *gin.Context) (namespace, labelSelector string, ok bool) {
+	namespace = c.Query("namespace")
+	labelSelector = c.Query("labelSelector")
+	if namespace == "" || namespace == "all" || labelSelector == "" {
+		respondErrorCode(c, 400, "namespace (not all) and labelSelector are required")
+		return namespace, labelSelector, ok
+	}
+	if c.GetString(clusterContextKey) == ClusterAll {
+		respondErrorCode(c, 400, "canary analysis runs against a single cluster")
+		return namespace, labelSelector, ok
+	}
+	_, err := parsePodSelector(labelSelector)
+	if err != nil {
+		respondErrorCode(c, 400, err.Error())
+		return namespace, labelSelector, ok
+	}
+	ok = true
+	return namespace, labelSelector, ok
+}
+
+func setupNodeRoutes(api *gin.RouterGroup, services *apiServices) {
+	// Nodes with their problems, including conditions set by node-problem-detector
+	api.GET("/nodes", func(c *gin.Context) {
+		clusterName := c.GetString(clusterContextKey)
+		nodes, err := services.nodeService.GetNodes(c.Request.Context(), clusterName)
+		respondList(c, services, clusterName, "nodes", c.Request.URL.RawQuery, nodes, nil, err)
+	})
+
+	// Node versions grouped by pool, highlighting version skew and outdated nodes
+	api.GET("/nodes/versions", func(c *gin.Context) {
+		report, err := services.nodeService.GetNodeVersions(c.Request.Context(), c.GetString(clusterContextKey))
 		if err != nil {
-			c.JSON(500, gin.H{"error": err.Error()})
+			respondError(c, err)
 			return
 		}
-		c.JSON(200, gin.H{"namespaces": namespaces})
+		c.JSON(200, report)
 	})
 
-	api.GET("/pods", func(c *gin.Context) {
-		clusterName := c.Query("cluster")
-		namespace := c.DefaultQuery("namespace", "default")
-		labelSelector := c.Query("labelSelector")
-		pods, err := podService.GetPods(c.Request.Context(), clusterName, namespace, labelSelector)
+	// A node with its events and the pods running on it
+	api.GET("/nodes/:name", func(c *gin.Context) {
+		node, err := services.nodeService.GetNode(c.Request.Context(), c.GetString(clusterContextKey), c.Param("name"))
 		if err != nil {
-			c.JSON(500, gin.H{"error": err.Error()})
+			respondError(c, err)
 			return
 		}
-		c.JSON(200, gin.H{"pods": pods})
+		c.JSON(200, gin.H{"node": node})
 	})
 
-	// Delete pod endpoint
-	api.DELETE("/pods/:namespace/:name", func(c *gin.Context) {
-		clusterName := c.Query("cluster")
-		namespace := c.Param("namespace")
-		podName := c.Param("name")
+	// The pods running on a node, in every namespace
+	api.GET("/nodes/:name/pods", func(c *gin.Context) {
+		clusterName := c.GetString(clusterContextKey)
+		pods, err := services.nodeService.GetNodePods(c.Request.Context(), clusterName, c.Param("name"))
+		respondList(c, services, clusterName, "pods", c.Request.URL.RequestURI(), pods, nil, err)
+	})
+
+	api.PUT("/nodes/:name/cordon", confirmMiddleware(services.confirmations, false), func(c *gin.Context) {
+		setNodeUnschedulable(c, services, true)
+	})
+
+	api.PUT("/nodes/:name/uncordon", confirmMiddleware(services.confirmations, false), func(c *gin.Context) {
+		setNodeUnschedulable(c, services, false)
+	})
+
+	// Cordon a node and evict its pods like kubectl drain, run as a job
+	api.POST("/nodes/:name/drain", confirmMiddleware(services.confirmations, false), func(c *gin.Context) {
+		clusterName := c.GetString(clusterContextKey)
+		nodeName := c.Param("name")
+		job := Job{Type: JobDrain, Cluster: clusterName, Name: KindNode + "/" + nodeName}
+		startJob(c, services, job, services.migrationService.DrainJob(clusterName, nodeName))
+	})
+}
+
+func setupJobRoutes(api *gin.RouterGroup, services *apiServices) {
+	// Background jobs, newest first
+	api.GET("/jobs", func(c *gin.Context) {
+		c.JSON(200, gin.H{"jobs": filterRecords(c.Request.Context(), services.jobService.List(), Job.owner)})
+	})
+
+	// A job's progress, or a stream of it until the job finishes when asked for text/event-stream
+	api.GET("/jobs/:id", func(c *gin.Context) {
+		if !recordAccessible(c, services.jobService.Get, Job.owner) {
+			return
+		}
+		job, err := services.jobService.Get(c.Param("id"))
+		if err != nil {
+			respondErrorCode(c, 404, err.Error())
+			return
+		}
+		if strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+			streamJob(c, services, job.ID)
+			return
+		}
+		c.JSON(200, gin.H{"job": job})
+	})
 
-		err := podService.DeletePod(c.Request.Context(), clusterName, namespace, podName)
+	// Stop a running job; work already done is kept
+	api.DELETE("/jobs/:id", func(c *gin.Context) {
+		if !recordAccessible(c, services.jobService.Get, Job.owner) {
+			return
+		}
+		job, err := services.jobService.Cancel(c.Request.Context(), c.Param("id"))
 		if err != nil {
-			c.JSON(500, gin.H{"error": err.Error()})
+			respondErrorCode(c, 404, err.Error())
 			return
 		}
+		c.JSON(200, gin.H{"job": job})
+	})
+}
 
-		c.JSON(200, gin.H{"message": "Pod deleted successfully"})
+// startJob runs a job against a single cluster in the background, responding 202 with the job to follow.
+func startJob(c *gin.Context, services *apiServices, job Job, fn jobFunc) {
+	if job.Cluster == ClusterAll {
+		respondErrorCode(c, 400, "jobs run against a single cluster")
+		return
+	}
+
+	job, err := services.jobService.Start(c.Request.Context(), job, fn)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(202, gin.H{"job": job})
+}
+
+// getPods lists pods, optionally across every cluster, narrowed by label and field selectors and name and
+// namespace patterns, and optionally grouped by owner.
+func getPods(c *gin.Context, services *apiServices) {
+	clusterName := c.GetString(clusterContextKey)
+	namespace := c.DefaultQuery("namespace", "default")
+	labelSelector := c.Query("labelSelector")
+	groupBy := c.Query("groupBy")
+	if groupBy != "" && groupBy != GroupByOwner {
+		respondErrorCode(c, 400, "groupBy must be owner")
+		return
+	}
+	evicted := c.Query("evicted")
+	if evicted != "" && evicted != EvictedGroupBy {
+		respondErrorCode(c, 400, "evicted must be group")
+		return
+	}
+	// A malformed selector or pattern is the caller's mistake, not a cluster failure to serve stale results for.
+	_, err := parsePodSelector(labelSelector)
+	if err != nil {
+		respondErrorCode(c, 400, err.Error())
+		return
+	}
+	filter, err := ParsePodFilter(c.Query("nameFilter"), c.Query("namespacePattern"), c.Query("fieldSelector"))
+	if err != nil {
+		respondErrorCode(c, 400, err.Error())
+		return
+	}
+	page, err := ParsePodPage(c.Query("sortBy"), c.Query("order"), c.Query("limit"), c.Query("continue"))
+	if err != nil {
+		respondErrorCode(c, 400, err.Error())
+		return
+	}
+	if groupBy != "" && page.Paged() {
+		respondErrorCode(c, 400, "groupBy can't be combined with limit")
+		return
+	}
+	fields, err := ParsePodFields(c.Query("fields"))
+	if err != nil {
+		respondErrorCode(c, 400, err.Error())
+		return
+	}
+	if groupBy != "" && fields.Selects() {
+		respondErrorCode(c, 400, "groupBy can't be combined with fields")
+		return
+	}
+	// A namespace pattern picks namespaces by itself, so it is matched against every namespace the user may use.
+	if filter.Namespace != nil && !AccessFromContext(c.Request.Context()).Scoped() {
+		namespace = "all"
+	}
+	if wantsNDJSON(c) {
+		if groupBy != "" || page.Paged() {
+			respondErrorCode(c, 400, "groupBy and limit can't be combined with "+NDJSONContentType)
+			return
+		}
+		streamPodsNDJSON(c, services, clusterName, namespace, labelSelector, filter, page, fields)
+		return
+	}
+
+	extra := gin.H{}
+	pods, next, err := listPodPage(c, services, clusterName, namespace, labelSelector, filter, page, extra)
+
+	// Bad or expired continue tokens are the client's to fix, so they aren't answered from the stale cache.
+	switch {
+	case errors.Is(err, ErrInvalidPodPage):
+		respondErrorCode(c, 400, err.Error())
+		return
+	case errors.Is(err, ErrPodPageExpired):
+		respondErrorCode(c, 410, err.Error())
+		return
+	}
+	if page.Paged() {
+		extra["continue"] = next
+	}
+	if evicted == EvictedGroupBy {
+		var summary EvictionSummary
+		pods, summary = SeparateEvicted(pods)
+		extra["evicted"] = summary
+	}
+
+	// Grouped listings are cached apart from plain ones, since groupBy is part of the query.
+	if groupBy == GroupByOwner {
+		respondList(c, services, clusterName, "groups", c.Request.URL.RawQuery, GroupPodsByOwner(pods), extra, err)
+		return
+	}
+	respondList(c, services, clusterName, "pods", c.Request.URL.RawQuery, fields.Apply(pods), extra, err)
+}
+
+// listPodPage lists a page of pods for getPods. Listings across clusters or several namespaces are merged from
+// their branches, which are reported in extra along with the errors of failed clusters.
+func listPodPage(c *gin.Context, services *apiServices, clusterName, namespace, labelSelector string, filter PodFilter, page PodPage, extra gin.H) (pods []PodInfo, next string, err error) {
+	if clusterName != ClusterAll && !strings.Contains(namespace, ",") {
+		pods, next, err = services.podService.GetPodPage(c.Request.Context(), clusterName, namespace, labelSelector, filter, page)
+		return pods, next, err
+	}
+
+	var result PodQueryResult
+	result, err = services.podService.QueryPods(c.Request.Context(), clusterName, namespace, labelSelector, filter)
+	pods = result.Pods
+	extra["branches"] = result.Branches
+	if clusterName == ClusterAll {
+		extra["clusterErrors"] = result.ClusterErrors()
+	}
+	// Merged listings can't use Kubernetes pagination, so they are always paged in memory.
+	if err == nil {
+		pods, next, err = page.Cut(pods)
+	}
+	return pods, next, err
+}
+
+// deletePods deletes the pods matching a label selector, or runs the deletion as a job.
+func deletePods(c *gin.Context, services *apiServices) {
+	if c.Query("async") == "true" && c.Query("dryRun") != "true" {
+		startBatchDeleteJob(c, services)
+		return
+	}
+
+	result, err := services.podService.DeletePods(c.Request.Context(), c.GetString(clusterContextKey), c.Query("namespace"), c.Query("labelSelector"), c.Query("dryRun") == "true")
+	switch {
+	case errors.Is(err, ErrInvalidBatchDelete), errors.Is(err, ErrInvalidLabelSelector):
+		respondErrorCode(c, 400, err.Error())
+	case err != nil:
+		respondError(c, err)
+	default:
+		c.JSON(200, result)
+	}
+}
+
+// cleanupPods deletes or lists finished pods older than a retention, or runs the cleanup as a job.
+func cleanupPods(c *gin.Context, services *apiServices) {
+	clusterName := c.GetString(clusterContextKey)
+	cleanup, err := ParsePodCleanup(c.DefaultQuery("namespace", "all"), c.Query("olderThan"), c.Query("phase"), c.Query("reason"), c.Query("dryRun") == "true")
+	if err != nil {
+		respondErrorCode(c, 400, err.Error())
+		return
+	}
+
+	if c.Query("async") == "true" && !cleanup.DryRun {
+		job := Job{Type: JobPodCleanup, Cluster: clusterName, Namespace: cleanup.Namespace, Name: "older than " + cleanup.Retention.String()}
+		startJob(c, services, job, services.podService.CleanupPodsJob(clusterName, cleanup))
+		return
+	}
+
+	result, err := services.podService.CleanupPods(c.Request.Context(), clusterName, cleanup)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(200, result)
+}
+
+// startBatchDeleteJob validates a batch delete and runs it as a job.
+func startBatchDeleteJob(c *gin.Context, services *apiServices) {
+	clusterName := c.GetString(clusterContextKey)
+	namespace, labelSelector := c.Query("namespace"), c.Query("labelSelector")
+	err := ValidateBatchDelete(namespace, labelSelector)
+	if err != nil {
+		respondErrorCode(c, 400, err.Error())
+		return
+	}
+
+	job := Job{Type: JobBatchDelete, Cluster: clusterName, Namespace: namespace, Name: labelSelector}
+	startJob(c, services, job, services.podService.DeletePodsJob(clusterName, namespace, labelSelector))
+}
+
+// streamJob writes a job's progress to the client as Server-Sent Events until the job finishes or the client
+// disconnects. The last event carries the finished job.
+func streamJob(c *gin.Context, services *apiServices, id string) {
+	stream := startEventStream(c, services.config)
+	stop := stream.KeepAlive(services.config.StreamHeartbeat)
+	defer stop()
+
+	err := services.jobService.Watch(c.Request.Context(), id, func(job Job) {
+		stream.Event("job", job)
+	})
+	if err != nil {
+		stream.Fail(err)
+	}
+}
+
+func setupPendingActionRoutes(api *gin.RouterGroup, services *apiServices) {
+	// Actions held back for an undo window, newest first; pending=true lists only those still to run
+	api.GET("/pending-actions", func(c *gin.Context) {
+		c.JSON(200, gin.H{"pendingActions": filterRecords(c.Request.Context(), services.pendingActions.List(c.Query("pending") == "true"), PendingAction.owner)})
+	})
+
+	// Perform a pending action now, skipping the rest of its undo window
+	api.POST("/pending-actions/:id/run", confirmMiddleware(services.confirmations, false), func(c *gin.Context) {
+		if !recordAccessible(c, services.pendingActions.Get, PendingAction.owner) {
+			return
+		}
+		action, err := services.pendingActions.RunNow(c.Param("id"))
+		if err != nil {
+			respondErrorCode(c, 404, err.Error())
+			return
+		}
+		c.JSON(200, gin.H{"pendingAction": action})
+	})
+
+	// Undo a pending action by cancelling it before its window passes
+	api.DELETE("/pending-actions/:id", func(c *gin.Context) {
+		if !recordAccessible(c, services.pendingActions.Get, PendingAction.owner) {
+			return
+		}
+		action, err := services.pendingActions.Cancel(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			respondErrorCode(c, 404, err.Error())
+			return
+		}
+		c.JSON(200, gin.H{"pendingAction": action})
 	})
 }
+
+func setupRevertRoutes(api *gin.RouterGroup, services *apiServices) {
+	// Scheduled reverts of temporary changes, newest first; pending=true lists only those still to run
+	api.GET("/reverts", func(c *gin.Context) {
+		c.JSON(200, gin.H{"reverts": filterRecords(c.Request.Context(), services.revertService.List(c.Query("pending") == "true"), Revert.owner)})
+	})
+
+	// Revert a temporary change now
+	api.POST("/reverts/:id/run", confirmMiddleware(services.confirmations, false), func(c *gin.Context) {
+		if !recordAccessible(c, services.revertService.Get, Revert.owner) {
+			return
+		}
+		revert, err := services.revertService.RunNow(c.Param("id"))
+		if err != nil {
+			respondErrorCode(c, 404, err.Error())
+			return
+		}
+		c.JSON(200, gin.H{"revert": revert})
+	})
+
+	// Keep a temporary change by cancelling its revert
+	api.DELETE("/reverts/:id", func(c *gin.Context) {
+		if !recordAccessible(c, services.revertService.Get, Revert.owner) {
+			return
+		}
+		revert, err := services.revertService.Cancel(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			respondErrorCode(c, 404, err.Error())
+			return
+		}
+		c.JSON(200, gin.H{"revert": revert})
+	})
+}
+
+func setupMigrationRoutes(api *gin.RouterGroup, services *apiServices) {
+	// Cordon nodes by label and evict their pods at a controlled pace
+	api.POST("/migrations", confirmMiddleware(services.confirmations, false), func(c *gin.Context) {
+		var request MigrationRequest
+		bindErr := bindJSON(c, &request)
+		if bindErr != nil {
+			respondErrorCode(c, 400, "request body must be JSON with a nodeSelector field")
+			return
+		}
+
+		migration, err := services.migrationService.Start(c.Request.Context(), c.GetString(clusterContextKey), request)
+		switch {
+		case errors.Is(err, ErrInvalidMigration):
+			respondErrorCode(c, 400, err.Error())
+		case err != nil:
+			respondError(c, err)
+		default:
+			c.JSON(202, gin.H{"migration": migration})
+		}
+	})
+
+	api.GET("/migrations", func(c *gin.Context) {
+		c.JSON(200, gin.H{"migrations": services.migrationService.List()})
+	})
+
+	api.GET("/migrations/:id", func(c *gin.Context) {
+		migration, err := services.migrationService.Get(c.Param("id"))
+		if err != nil {
+			respondErrorCode(c, 404, err.Error())
+			return
+		}
+		c.JSON(200, gin.H{"migration": migration})
+	})
+
+	api.DELETE("/migrations/:id", func(c *gin.Context) {
+		migration, err := services.migrationService.Cancel(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			respondErrorCode(c, 404, err.Error())
+			return
+		}
+		c.JSON(200, gin.H{"migration": migration})
+	})
+}
+
+func setupActionRoutes(api *gin.RouterGroup, services *apiServices) {
+	// UI configuration - custom actions and server settings the UI adapts to
+	api.GET("/config", func(c *gin.Context) {
+		c.JSON(200, gin.H{
+			"version":      Version,
+			"offline":      services.config.Offline,
+			"impersonate":  services.config.Impersonate,
+			"actions":      services.actionService.Actions(),
+			"tickets":      services.ticketService.Backend(),
+			"alertmanager": services.alertmanager.Enabled(),
+		})
+	})
+
+	api.POST("/pods/:namespace/:name/actions/:action", func(c *gin.Context) {
+		runAction(c, services.actionService, ActionKindPod)
+	})
+
+	api.POST("/deployments/:namespace/:name/actions/:action", func(c *gin.Context) {
+		runAction(c, services.actionService, ActionKindDeployment)
+	})
+
+	// Create an incident ticket pre-filled with the pod's status, events and recent logs
+	api.POST("/pods/:namespace/:name/ticket", func(c *gin.Context) {
+		clusterName := c.GetString(clusterContextKey)
+
+		var request ticketRequest
+		if c.Request.ContentLength != 0 {
+			bindErr := bindJSON(c, &request)
+			if bindErr != nil {
+				respondErrorCode(c, 400, "request body must be JSON with an optional note field")
+				return
+			}
+		}
+
+		ticket, err := services.ticketService.CreatePodTicket(c.Request.Context(), clusterName, c.Param("namespace"), c.Param("name"), request.Note)
+		switch {
+		case errors.Is(err, ErrTicketsDisabled):
+			respondErrorCode(c, 404, err.Error())
+		case errors.Is(err, ErrOffline):
+			respondErrorCode(c, 403, err.Error())
+		case err != nil:
+			respondError(c, err)
+		default:
+			c.JSON(200, gin.H{"ticket": ticket})
+		}
+	})
+}
+
+// setupStatusRoutes serves the status page outside /api. A public status page skips the identity headers
+// so dependents can reach it without going through the authenticating proxy. Its workloads are fixed by the
+// config file, so access bindings don't apply.
+func setupStatusRoutes(router *gin.Engine, config ServerConfig, authenticate Authenticator, statusService *StatusService) {
+	status := router.Group("/")
+	if !statusService.Public() {
+		status.Use(identityMiddleware(config, authenticate, nil))
+	}
+	status.Use(timeMiddleware(config))
+
+	status.GET("/status.json", func(c *gin.Context) {
+		report, err := statusService.Report(c.Request.Context())
+		if err != nil {
+			respondErrorCode(c, 404, err.Error())
+			return
+		}
+		c.JSON(200, report)
+	})
+
+	status.GET("/status", func(c *gin.Context) {
+		report, err := statusService.Report(c.Request.Context())
+		if err != nil {
+			c.String(404, err.Error())
+			return
+		}
+
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Status(200)
+		renderErr := statusPageTemplate.Execute(c.Writer, report)
+		if renderErr != nil {
+			statusService.logger.Error("Failed to render status page", zap.Error(renderErr))
+		}
+	})
+}
+
+// setupWebhookRoutes serves webhooks outside /api. They come from services rather than users, so they skip
+// the identity headers and are authenticated by their signatures instead.
+func setupWebhookRoutes(router *gin.Engine, githubService *GitHubService) {
+	router.POST("/webhooks/github", func(c *gin.Context) {
+		if !githubService.Enabled() {
+			respondErrorCode(c, 404, ErrGitHubDisabled.Error())
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, gitHubMaxWebhookBody))
+		if err != nil {
+			respondErrorCode(c, 400, "failed to read webhook body")
+			return
+		}
+		err = githubService.VerifyWebhook(body, c.GetHeader(GitHubSignatureHeader))
+		if err != nil {
+			githubService.logger.Warn("Rejected GitHub webhook", zap.Error(err), zap.String("delivery", c.GetHeader("X-GitHub-Delivery")))
+			respondErrorCode(c, 401, err.Error())
+			return
+		}
+
+		// Other events, such as the ping sent when the webhook is created, are acknowledged and ignored.
+		if c.GetHeader("X-GitHub-Event") != "deployment" {
+			c.JSON(200, gin.H{"ignored": c.GetHeader("X-GitHub-Event")})
+			return
+		}
+
+		job, started, err := githubService.HandleDeployment(c.Request.Context(), body)
+		switch {
+		case errors.Is(err, ErrInvalidWebhookPayload):
+			respondErrorCode(c, 400, err.Error())
+		case errors.Is(err, ErrOffline):
+			respondErrorCode(c, 403, err.Error())
+		case err != nil:
+			respondError(c, err)
+		case !started:
+			c.JSON(200, gin.H{"message": "no deployments are linked to this repository and environment"})
+		default:
+			c.JSON(202, gin.H{"job": job})
+		}
+	})
+}
+
+// ticketRequest is the optional body accepted by the ticket endpoint.
+type ticketRequest struct {
+	Note string `json:"note"`
+}
+
+// runAction handles a custom action request for the given object kind.
+func runAction(c *gin.Context, actionService *ActionService, kind string) {
+	clusterName := c.GetString(clusterContextKey)
+
+	result, err := actionService.Run(c.Request.Context(), clusterName, kind, c.Param("action"), c.Param("namespace"), c.Param("name"))
+	switch {
+	case errors.Is(err, ErrActionNotFound):
+		respondErrorCode(c, 404, err.Error())
+	case errors.Is(err, ErrOffline):
+		respondErrorCode(c, 403, err.Error())
+	case err != nil:
+		respondError(c, err)
+	default:
+		c.JSON(200, gin.H{"result": result})
+	}
+}
+
+func setupClusterRoutes(api *gin.RouterGroup, services *apiServices) {
+	// Clusters endpoint - only available when not running in cluster
+	api.GET("/clusters", func(c *gin.Context) {
+		if services.kubeConfigService.IsInCluster() {
+			c.JSON(200, gin.H{
+				"inCluster": true,
+				"clusters":  []ClusterInfo{},
+				"health":    services.clusterHealth.Health(c.Request.Context(), ""),
+				"retries":   services.clusterRetries.Stats(""),
+			})
+			return
+		}
+
+		// A cluster pinned by a reverse proxy is the only one exposed on that hostname.
+		if pinned := c.GetHeader(clusterHeader); pinned != "" {
+			c.JSON(200, gin.H{
+				"inCluster": false,
+				"pinned":    true,
+				"clusters": []ClusterInfo{{
+					Name:    pinned,
+					Current: true,
+					Health:  services.clusterHealth.Health(c.Request.Context(), pinned),
+					Retries: services.clusterRetries.Stats(pinned),
+				}},
+			})
+			return
+		}
+
+		clusters, err := services.kubeConfigService.GetClusters()
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		// The user's default cluster is the one the UI starts on.
+		if defaultCluster := AccessFromContext(c.Request.Context()).Binding.DefaultCluster; defaultCluster != "" {
+			for i := range clusters {
+				clusters[i].Current = clusters[i].Name == defaultCluster
+			}
+		}
+		current := c.GetString(clusterContextKey)
+		for i := range clusters {
+			clusters[i].Health = services.clusterHealth.Health(c.Request.Context(), clusters[i].Name)
+			clusters[i].Retries = services.clusterRetries.Stats(clusters[i].Name)
+			if current == "" && clusters[i].Current {
+				current = clusters[i].Name
+			}
+		}
+
+		contexts, err := services.kubeConfigService.GetContexts()
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		response := gin.H{
+			"inCluster": false,
+			"clusters":  clusters,
+			"contexts":  contexts,
+		}
+		// Switching to a recently used cluster shouldn't wait on a cold client.
+		if warming := services.clusterWarmer.Warm(c.Request.Context(), current); len(warming) > 0 {
+			response["warming"] = warming
+		}
+		c.JSON(200, response)
+	})
+
+	api.GET("/namespaces", func(c *gin.Context) {
+		clusterName := c.GetString(clusterContextKey)
+		namespaces, err := services.podService.GetNamespaces(c.Request.Context(), clusterName)
+		namespaces = AccessFromContext(c.Request.Context()).FilterNamespaces(namespaces)
+		respondList(c, services, clusterName, "namespaces", c.Request.URL.RawQuery, namespaces, nil, err)
+	})
+}
+
+// setupReadinessRoute serves /ready, which with ReadyAfterPriming fails until startup priming is done, so
+// readiness probes keep traffic away until the first page loads are fast.
+func setupReadinessRoute(router *gin.Engine, services *apiServices) {
+	router.GET("/ready", func(c *gin.Context) {
+		if services.config.ReadyAfterPriming && !services.cachePrimer.Ready() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": PrimingRunning})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ready"})
+	})
+}
+
+func setupCacheRoutes(api *gin.RouterGroup, services *apiServices) {
+	api.GET("/cache/status", func(c *gin.Context) {
+		c.JSON(200, services.cachePrimer.Status(c.Request.Context()))
+	})
+}
+
+// setupCSRFRoutes serves the CSRF token for clients that can't read cookies, along with whether it's required.
+func setupCSRFRoutes(api *gin.RouterGroup, services *apiServices) {
+	api.GET("/csrf", func(c *gin.Context) {
+		c.JSON(200, gin.H{"token": c.GetString(csrfContextKey), "enabled": services.config.CSRFProtection, "confirmDestructive": services.confirmations != nil})
+	})
+}
+
+func setupPodRoutes(api *gin.RouterGroup, services *apiServices) {
+	api.GET("/pods", podDetailMiddleware(), func(c *gin.Context) {
+		getPods(c, services)
+	})
+
+	// Live pod changes as Server-Sent Events, resumable from the last event id
+	api.GET("/pods/stream", podDetailMiddleware(), func(c *gin.Context) {
+		clusterName := c.GetString(clusterContextKey)
+		if clusterName == ClusterAll {
+			respondErrorCode(c, 400, "streams watch a single cluster")
+			return
+		}
+		labelSelector := c.Query("labelSelector")
+		_, selectorErr := parsePodSelector(labelSelector)
+		if selectorErr != nil {
+			respondErrorCode(c, 400, selectorErr.Error())
+			return
+		}
+		streamPods(c, services, clusterName, c.DefaultQuery("namespace", "default"), labelSelector)
+	})
+
+	// Pods added, modified or deleted since the listing at a resource version
+	api.GET("/pods/delta", podDetailMiddleware(), func(c *gin.Context) {
+		clusterName := c.GetString(clusterContextKey)
+		if clusterName == ClusterAll {
+			respondErrorCode(c, 400, "deltas list a single cluster")
+			return
+		}
+		delta, err := services.podService.GetPodDelta(c.Request.Context(), clusterName, c.DefaultQuery("namespace", "default"), c.Query("labelSelector"), c.Query("since"))
+		switch {
+		case errors.Is(err, ErrInvalidLabelSelector):
+			respondErrorCode(c, 400, err.Error())
+		case err != nil:
+			respondError(c, err)
+		default:
+			c.JSON(200, delta)
+		}
+	})
+
+	// Recent preemptions, kept in events after the preempted pods are gone
+	api.GET("/preemptions", func(c *gin.Context) {
+		clusterName := c.GetString(clusterContextKey)
+		preemptions, err := services.podService.GetPreemptions(c.Request.Context(), clusterName, c.DefaultQuery("namespace", "all"))
+		respondList(c, services, clusterName, "preemptions", c.Request.URL.RawQuery, preemptions, nil, err)
+	})
+
+	api.PATCH("/pods/:namespace/:name/labels", confirmMiddleware(services.confirmations, false), func(c *gin.Context) {
+		setLabels(c, services, KindPod)
+	})
+
+	// Delete every pod in a namespace matching a label selector; dryRun=true lists them without deleting and
+	// async=true returns a job to follow instead of waiting for the deletions
+	api.DELETE("/pods", confirmMiddleware(services.confirmations, true), func(c *gin.Context) {
+		deletePods(c, services)
+	})
+
+	// Delete Succeeded and Failed pods older than a retention across namespaces; dryRun=true lists them and
+	// async=true returns a job to follow
+	api.POST("/pods/cleanup", confirmMiddleware(services.confirmations, true), func(c *gin.Context) {
+		cleanupPods(c, services)
+	})
+
+	// Evict a pod, respecting PodDisruptionBudgets; a refused eviction returns 429 with the reasons
+	api.POST("/pods/:namespace/:name/evict", confirmMiddleware(services.confirmations, false), func(c *gin.Context) {
+		blocked, err := services.podService.EvictPod(c.Request.Context(), c.GetString(clusterContextKey), c.Param("namespace"), c.Param("name"))
+		switch {
+		case errors.Is(err, ErrPodNotFound):
+			respondErrorCode(c, 404, err.Error())
+		case errors.Is(err, ErrEvictionBlocked):
+			body := errorBody(c, 429, statusReason(429), err.Error())
+			body["blocked"] = blocked
+			c.JSON(429, body)
+		case err != nil:
+			respondError(c, err)
+		default:
+			c.JSON(200, gin.H{"message": "Pod evicted successfully"})
+		}
+	})
+
+	// Delete pod endpoint; force=true&gracePeriod=0&confirm=<namespace>/<name> force deletes a stuck pod
+	api.DELETE("/pods/:namespace/:name", confirmMiddleware(services.confirmations, false), func(c *gin.Context) {
+		deletePod(c, services)
+	})
+}
+
+// deletePod deletes a pod, or queues its deletion when there is an undo window.
+func deletePod(c *gin.Context, services *apiServices) {
+	clusterName := c.GetString(clusterContextKey)
+	namespace := c.Param("namespace")
+	podName := c.Param("name")
+
+	options := PodDeleteOptions{Force: c.Query("force") == "true", Confirm: c.Query("confirm")}
+	if gracePeriod := c.Query("gracePeriod"); gracePeriod != "" {
+		seconds, parseErr := strconv.ParseInt(gracePeriod, 10, 64)
+		if parseErr != nil || seconds < 0 {
+			respondErrorCode(c, 400, "gracePeriod must be a non-negative integer")
+			return
+		}
+		options.GracePeriodSeconds = &seconds
+	}
+
+	undoWindow, parseErr := ParseUndoWindow(c.Query("undoWindow"), services.config.DeleteUndoWindow)
+	if parseErr != nil {
+		respondErrorCode(c, 400, parseErr.Error())
+		return
+	}
+	if undoWindow > 0 {
+		queuePodDelete(c, services, clusterName, namespace, podName, options, undoWindow)
+		return
+	}
+
+	err := services.podService.DeletePod(c.Request.Context(), clusterName, namespace, podName, options)
+	switch {
+	case errors.Is(err, ErrForceDeleteUnconfirmed):
+		respondErrorCode(c, 400, err.Error())
+	case err != nil:
+		respondError(c, err)
+	default:
+		c.JSON(200, gin.H{"message": "Pod deleted successfully"})
+	}
+}
+
+// queuePodDelete holds a pod deletion back for the undo window, responding 202 with the pending action.
+func queuePodDelete(c *gin.Context, services *apiServices, clusterName, namespace, podName string, options PodDeleteOptions, undoWindow time.Duration) {
+	// An unconfirmed force delete is refused now rather than failing once the window has passed.
+	err := options.Validate(namespace, podName)
+	if err != nil {
+		respondErrorCode(c, 400, err.Error())
+		return
+	}
+
+	action := PendingAction{Cluster: clusterName, Kind: KindPod, Namespace: namespace, Name: podName, Action: PendingActionDelete}
+	action, err = services.pendingActions.Queue(c.Request.Context(), action, undoWindow, func(ctx context.Context) (deleteErr error) {
+		deleteErr = services.podService.DeletePod(ctx, clusterName, namespace, podName, options)
+		return deleteErr
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(202, gin.H{"message": fmt.Sprintf("Pod will be deleted in %s unless cancelled", undoWindow), "pendingAction": action})
+}
+
+// setupPodDetailRoutes serves a single pod's details, manifest, timeline and events.
+func setupPodDetailRoutes(api *gin.RouterGroup, services *apiServices) {
+	// Pod describe endpoint
+	api.GET("/pods/:namespace/:name", func(c *gin.Context) {
+		pod, err := services.podService.DescribePod(c.Request.Context(), c.GetString(clusterContextKey), c.Param("namespace"), c.Param("name"))
+		switch {
+		case errors.Is(err, ErrPodNotFound):
+			respondErrorCode(c, 404, err.Error())
+		case err != nil:
+			respondError(c, err)
+		default:
+			c.JSON(200, gin.H{"pod": pod})
+		}
+	})
+
+	// Live pod object as YAML (default) or JSON, managed fields stripped unless managedFields=true
+	api.GET("/pods/:namespace/:name/manifest", func(c *gin.Context) {
+		format := c.DefaultQuery("format", ManifestFormatYAML)
+		manifest, err := services.podService.GetPodManifest(c.Request.Context(), c.GetString(clusterContextKey), c.Param("namespace"), c.Param("name"), format, c.Query("managedFields") == "true")
+		switch {
+		case errors.Is(err, ErrInvalidManifestFormat):
+			respondErrorCode(c, 400, err.Error())
+		case errors.Is(err, ErrPodNotFound):
+			respondErrorCode(c, 404, err.Error())
+		case err != nil:
+			respondError(c, err)
+		case format == ManifestFormatJSON:
+			c.Data(200, "application/json; charset=utf-8", manifest)
+		default:
+			c.Data(200, "application/yaml; charset=utf-8", manifest)
+		}
+	})
+
+	// Pod history, including preemptions of and by the pod
+	api.GET("/pods/:namespace/:name/timeline", func(c *gin.Context) {
+		timeline, err := services.podService.GetPodTimeline(c.Request.Context(), c.GetString(clusterContextKey), c.Param("namespace"), c.Param("name"))
+		switch {
+		case errors.Is(err, ErrPodNotFound):
+			respondErrorCode(c, 404, err.Error())
+		case err != nil:
+			respondError(c, err)
+		default:
+			c.JSON(200, gin.H{"timeline": timeline})
+		}
+	})
+
+	// Pod events endpoint
+	api.GET("/pods/:namespace/:name/events", func(c *gin.Context) {
+		clusterName := c.GetString(clusterContextKey)
+		namespace := c.Param("namespace")
+		podName := c.Param("name")
+
+		events, err := services.podService.GetPodEvents(c.Request.Context(), clusterName, namespace, podName)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		c.JSON(200, gin.H{"events": events})
+	})
+}
+
+func setupEventRoutes(api *gin.RouterGroup, services *apiServices) {
+	// Namespace events feed - Warning events by default, streamed as Server-Sent Events with stream=true
+	api.GET("/events", func(c *gin.Context) {
+		clusterName := c.GetString(clusterContextKey)
+		namespace := c.DefaultQuery("namespace", "default")
+		eventType := c.Query("type")
+
+		if c.Query("stream") == "true" {
+			streamEvents(c, services, clusterName, namespace, eventType)
+			return
+		}
+
+		limit, limitErr := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultEventLimit)))
+		if limitErr != nil || limit < 0 {
+			respondErrorCode(c, 400, "limit must be a non-negative integer")
+			return
+		}
+
+		events, err := services.podService.GetEvents(c.Request.Context(), clusterName, namespace, eventType, limit)
+		respondList(c, services, clusterName, "events", c.Request.URL.RawQuery, events, nil, err)
+	})
+
+	// Kubelet, container runtime, network and storage errors, kept apart from application crashes
+	api.GET("/infrastructure-errors", func(c *gin.Context) {
+		clusterName := c.GetString(clusterContextKey)
+		namespace := c.DefaultQuery("namespace", "all")
+		infraErrors, err := services.infraService.GetErrors(c.Request.Context(), clusterName, namespace, c.Query("category"))
+		respondList(c, services, clusterName, "infrastructureErrors", c.Request.URL.RawQuery, infraErrors, nil, err)
+	})
+
+	// Expiry of the TLS certificates referenced by Ingresses and pods, with a summary per namespace
+	api.GET("/certificates", func(c *gin.Context) {
+		clusterName := c.GetString(clusterContextKey)
+		warnDays, err := strconv.Atoi(c.DefaultQuery("warnDays", strconv.Itoa(DefaultCertificateWarnDays)))
+		if err != nil || warnDays < 0 {
+			respondErrorCode(c, 400, "warnDays must be a non-negative integer")
+			return
+		}
+
+		certificates, err := services.certificates.GetCertificates(c.Request.Context(), clusterName, c.DefaultQuery("namespace", "all"), warnDays)
+		var extra gin.H
+		if err == nil {
+			extra = gin.H{"namespaces": SummarizeCertificates(certificates)}
+		}
+		respondList(c, services, clusterName, "certificates", c.Request.URL.RawQuery, certificates, extra, err)
+	})
+
+	// Pods the namespaces' Pod Security Standards would reject when they are next created
+	api.GET("/pod-security", func(c *gin.Context) {
+		clusterName := c.GetString(clusterContextKey)
+		level := c.Query("level")
+		if level != "" && !validPodSecurityLevel(level) {
+			respondErrorCode(c, 400, "level must be privileged, baseline or restricted")
+			return
+		}
+
+		reports, err := services.podService.GetPodSecurity(c.Request.Context(), clusterName, c.DefaultQuery("namespace", "all"), level)
+		respondList(c, services, clusterName, "podSecurity", c.Request.URL.RawQuery, reports, nil, err)
+	})
+
+	// Dependency graph of a namespace's workloads, with the blast radius of unhealthy objects
+	api.GET("/topology", func(c *gin.Context) {
+		namespace := c.DefaultQuery("namespace", "default")
+		if namespace == "all" {
+			respondErrorCode(c, 400, "topology needs a namespace or a comma-separated list of namespaces")
+			return
+		}
+
+		topology, err := services.podService.GetTopology(c.Request.Context(), c.GetString(clusterContextKey), namespace)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		c.JSON(200, topology)
+	})
+}
+
+func setupDeploymentRoutes(api *gin.RouterGroup, services *apiServices) {
+	api.GET("/deployments", func(c *gin.Context) {
+		clusterName := c.GetString(clusterContextKey)
+		namespace := c.DefaultQuery("namespace", "default")
+		labelSelector := c.Query("labelSelector")
+		deployments, err := services.deploymentService.GetDeployments(c.Request.Context(), clusterName, namespace, labelSelector)
+		respondList(c, services, clusterName, "deployments", c.Request.URL.RawQuery, deployments, nil, err)
+	})
+
+	api.PUT("/deployments/:namespace/:name/scale", confirmMiddleware(services.confirmations, false), func(c *gin.Context) {
+		scaleWorkload(c, services, KindDeployment)
+	})
+
+	// Rolling restart like kubectl rollout restart, run as a job that follows the rollout
+	api.POST("/deployments/:namespace/:name/restart", confirmMiddleware(services.confirmations, false), func(c *gin.Context) {
+		clusterName := c.GetString(clusterContextKey)
+		namespace, name := c.Param("namespace"), c.Param("name")
+		job := Job{Type: JobRollingRestart, Cluster: clusterName, Namespace: namespace, Name: KindDeployment + "/" + name}
+		startJob(c, services, job, services.deploymentService.RestartDeploymentJob(clusterName, namespace, name))
+	})
+
+	api.PUT("/statefulsets/:namespace/:name/scale", confirmMiddleware(services.confirmations, false), func(c *gin.Context) {
+		scaleWorkload(c, services, KindStatefulSet)
+	})
+
+	// Differences between the declared configuration and the live workload, such as manual kubectl edits
+	api.GET("/deployments/:namespace/:name/drift", func(c *gin.Context) {
+		getDrift(c, services, KindDeployment)
+	})
+	api.GET("/statefulsets/:namespace/:name/drift", func(c *gin.Context) {
+		getDrift(c, services, KindStatefulSet)
+	})
+	api.GET("/daemonsets/:namespace/:name/drift", func(c *gin.Context) {
+		getDrift(c, services, KindDaemonSet)
+	})
+
+	// Temporarily scale a deployment up during an incident; reverted automatically
+	api.POST("/deployments/:namespace/:name/quickscale", confirmMiddleware(services.confirmations, false), func(c *gin.Context) {
+		var request QuickScaleRequest
+		bindErr := bindJSON(c, &request)
+		if bindErr != nil {
+			respondErrorCode(c, 400, "request body must be JSON with a by or percent field")
+			return
+		}
+
+		quickScale, err := services.quickScaleService.Scale(c.Request.Context(), c.GetString(clusterContextKey), c.Param("namespace"), c.Param("name"), request)
+		switch {
+		case errors.Is(err, ErrQuickScaleRejected):
+			respondErrorCode(c, 400, err.Error())
+		case err != nil:
+			respondError(c, err)
+		default:
+			c.JSON(200, gin.H{"quickScale": quickScale})
+		}
+	})
+
+	api.PATCH("/deployments/:namespace/:name/labels", confirmMiddleware(services.confirmations, false), func(c *gin.Context) {
+		setLabels(c, services, KindDeployment)
+	})
+
+	api.GET("/quickscales", func(c *gin.Context) {
+		c.JSON(200, gin.H{"quickScales": filterRecords(c.Request.Context(), services.quickScaleService.List(), QuickScale.owner)})
+	})
+
+	// Revert a quick scale now instead of waiting for its timer
+	api.DELETE("/quickscales/:id", func(c *gin.Context) {
+		if !recordAccessible(c, services.quickScaleService.Get, QuickScale.owner) {
+			return
+		}
+		quickScale, err := services.quickScaleService.Revert(c.Param("id"))
+		if err != nil {
+			respondErrorCode(c, 404, err.Error())
+			return
+		}
+		c.JSON(200, gin.H{"quickScale": quickScale})
+	})
+}
+
+// respondList writes a list response under the given field, recording the outcome in the error budget.
+// When the cluster call failed but a recent good result exists for the same query, that result is
+// served with stale=true and its age instead of an error, so dashboards keep their last good picture.
+// Cached results are keyed by the impersonated user so one user's view is never served to another.
+// Extra fields, if any, are added to fresh and stale responses. Fresh responses carry an ETag, and clients
+// that already hold them get 304 Not Modified.
+func respondList(c *gin.Context, services *apiServices, clusterName, field, query string, value interface{}, extra gin.H, err error) {
+	services.errorBudget.Record(clusterName, err)
+	degraded := services.errorBudget.Degraded(clusterName)
+	cacheKey := listCacheKey(c, clusterName, field, query)
+
+	if err == nil {
+		services.staleCache.Store(cacheKey, value)
+		respondWithETag(c, withExtra(gin.H{field: value, "clusterDegraded": degraded, "stale": false}, extra))
+		return
+	}
+
+	cached, storedAt, ok := services.staleCache.Load(cacheKey)
+	if !ok {
+		code, reason := errorStatus(err)
+		body := errorBody(c, code, reason, err.Error())
+		body["clusterDegraded"] = degraded
+		c.JSON(code, withExtra(body, extra))
+		return
+	}
+
+	c.JSON(200, withExtra(gin.H{
+		field:             cached,
+		"clusterDegraded": degraded,
+		"stale":           true,
+		"cachedAt":        storedAt.UTC().Format(time.RFC3339),
+		"cacheAgeSeconds": int64(time.Since(storedAt).Seconds()),
+		"error":           err.Error(),
+	}, extra))
+}
+
+// listCacheKey is the stale cache key of a list response, which is only served back to the same user.
+func listCacheKey(c *gin.Context, clusterName, field, query string) (key string) {
+	key = field + "|" + clusterName + "|" + KubeContextFromContext(c.Request.Context()) + "|" + identityUser(c.Request.Context()) + "|" + query
+	return key
+}
+
+// withExtra adds extra fields to a response without overriding its own fields.
+func withExtra(response, extra gin.H) (merged gin.H) {
+	for key, value := range extra {
+		if _, exists := response[key]; !exists {
+			response[key] = value
+		}
+	}
+	merged = response
+	return merged
+}
+
+// scaleRequest is the body accepted by the scale endpoints.
+type scaleRequest struct {
+	Replicas *int32 `json:"replicas"`
+}
+
+// getDrift compares a workload of the given kind with its declared configuration.
+func getDrift(c *gin.Context, services *apiServices, kind string) {
+	report, err := services.deploymentService.GetDrift(c.Request.Context(), c.GetString(clusterContextKey), kind, c.Param("namespace"), c.Param("name"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(200, report)
+}
+
+// scaleWorkload handles a scale request for the given workload kind.
+func scaleWorkload(c *gin.Context, services *apiServices, kind string) {
+	clusterName := c.GetString(clusterContextKey)
+	namespace := c.Param("namespace")
+	name := c.Param("name")
+
+	var request scaleRequest
+	bindErr := bindJSON(c, &request)
+	if bindErr != nil || request.Replicas == nil {
+		respondErrorCode(c, 400, "request body must be JSON with a replicas field")
+		return
+	}
+	if *request.Replicas < 0 {
+		respondErrorCode(c, 400, "replicas must not be negative")
+		return
+	}
+
+	revertAfter, parseErr := ParseRevertAfter(c.Query("revertAfter"))
+	if parseErr != nil {
+		respondErrorCode(c, 400, parseErr.Error())
+		return
+	}
+
+	info, err := services.deploymentService.ScaleWorkload(c.Request.Context(), clusterName, kind, namespace, name, *request.Replicas)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response := gin.H{"scale": info}
+	if revertAfter > 0 {
+		change := fmt.Sprintf("scale from %d to %d replicas", info.PreviousReplicas, info.Replicas)
+		scheduleRevert(c, services, response, Revert{Cluster: clusterName, Kind: kind, Namespace: namespace, Name: name, Change: change}, revertAfter, services.deploymentService.scaleReverter(clusterName, info))
+	}
+	c.JSON(200, response)
+}
+
+// labelRequest is the body accepted by the label endpoints. A null value removes the label.
+type labelRequest struct {
+	Labels map[string]*string `json:"labels"`
+}
+
+// setLabels handles a label change for the given kind.
+func setLabels(c *gin.Context, services *apiServices, kind string) {
+	clusterName := c.GetString(clusterContextKey)
+	namespace := c.Param("namespace")
+	name := c.Param("name")
+
+	var request labelRequest
+	bindErr := bindJSON(c, &request)
+	if bindErr != nil {
+		respondErrorCode(c, 400, "request body must be JSON with a labels object")
+		return
+	}
+
+	revertAfter, parseErr := ParseRevertAfter(c.Query("revertAfter"))
+	if parseErr != nil {
+		respondErrorCode(c, 400, parseErr.Error())
+		return
+	}
+
+	change, err := services.labelService.SetLabels(c.Request.Context(), clusterName, kind, namespace, name, request.Labels)
+	switch {
+	case errors.Is(err, ErrInvalidLabels):
+		respondErrorCode(c, 400, err.Error())
+		return
+	case err != nil:
+		respondError(c, err)
+		return
+	}
+
+	response := gin.H{"labels": change}
+	if revertAfter > 0 {
+		description := "set labels " + strings.Join(labelKeys(request.Labels), ", ")
+		scheduleRevert(c, services, response, Revert{Cluster: clusterName, Kind: kind, Namespace: namespace, Name: name, Change: description}, revertAfter, services.labelService.labelReverter(clusterName, change))
+	}
+	c.JSON(200, response)
+}
+
+// setNodeUnschedulable handles a cordon or uncordon request.
+func setNodeUnschedulable(c *gin.Context, services *apiServices, unschedulable bool) {
+	clusterName := c.GetString(clusterContextKey)
+	nodeName := c.Param("name")
+
+	revertAfter, parseErr := ParseRevertAfter(c.Query("revertAfter"))
+	if parseErr != nil {
+		respondErrorCode(c, 400, parseErr.Error())
+		return
+	}
+
+	info, err := services.nodeService.SetUnschedulable(c.Request.Context(), clusterName, nodeName, unschedulable)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response := gin.H{"cordon": info}
+	if revertAfter > 0 {
+		change := "uncordon"
+		if unschedulable {
+			change = "cordon"
+		}
+		scheduleRevert(c, services, response, Revert{Cluster: clusterName, Kind: KindNode, Name: nodeName, Change: change}, revertAfter, services.nodeService.cordonReverter(clusterName, info))
+	}
+	c.JSON(200, response)
+}
+
+// scheduleRevert schedules the revert of a change that has already been made and adds it to the response.
+// The change stays in place if scheduling fails, so the failure is reported alongside it.
+func scheduleRevert(c *gin.Context, services *apiServices, response gin.H, revert Revert, revertAfter time.Duration, fn revertFunc) {
+	scheduled, err := services.revertService.Schedule(c.Request.Context(), revert, revertAfter, fn)
+	if err != nil {
+		response["revertError"] = err.Error()
+		return
+	}
+	response["revert"] = scheduled
+}
+
+// streamEvents writes namespace events to the client as Server-Sent Events until the client disconnects.
+// Heartbeats keep the stream open through proxies while no events happen.
+func streamEvents(c *gin.Context, services *apiServices, clusterName, namespace, eventType string) {
+	stream := startEventStream(c, services.config)
+	stop := stream.KeepAlive(services.config.StreamHeartbeat)
+	defer stop()
+
+	err := services.podService.WatchEvents(c.Request.Context(), clusterName, namespace, eventType, func(event EventInfo) {
+		stream.Event("event", event)
+	})
+	if err != nil {
+		stream.Fail(err)
+	}
+}
+
+// streamPods writes pod changes to the client as Server-Sent Events until the client disconnects. Each event's
+// id is a resource version, so reconnecting clients resume where they left off.
+func streamPods(c *gin.Context, services *apiServices, clusterName, namespace, labelSelector string) {
+	resourceVersion := resumeResourceVersion(c.Request)
+	stream := startEventStream(c, services.config)
+	stop := stream.KeepAlive(services.config.StreamHeartbeat)
+	defer stop()
+
+	err := services.podService.WatchPods(c.Request.Context(), clusterName, namespace, labelSelector, resourceVersion, func(event PodStreamEvent) {
+		stream.EventWithID(event.ResourceVersion, event.Type, event)
+	})
+	if err != nil {
+		stream.Fail(err)
+	}
+}
+
+// bindJSON decodes a JSON request body of at most maxRequestBody bytes into request.
+func bindJSON(c *gin.Context, request interface{}) (err error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxRequestBody)
+	err = c.ShouldBindJSON(request)
+	return err
+}
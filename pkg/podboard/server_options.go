@@ -0,0 +1,174 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Server is the podboard web server: the API, status page, webhooks and UI. Applications embedding podboard
+// can mount Handler in their own server instead of calling Start.
+type Server struct {
+	config        ServerConfig
+	logger        *zap.Logger
+	clientFactory ClientFactory
+	authenticate  Authenticator
+	listener      net.Listener
+	router        *gin.Engine
+	httpServer    *http.Server
+	// stop ends the background loops started with the server.
+	stop context.CancelFunc
+}
+
+// ServerOption customizes a Server created by NewServer.
+type ServerOption func(server *Server)
+
+// WithLogger sets the logger, a no-op logger by default.
+func WithLogger(logger *zap.Logger) (option ServerOption) {
+	option = func(server *Server) {
+		server.logger = logger
+	}
+	return option
+}
+
+// WithKubeClientFactory sets the source of Kubernetes clients, by default a KubeConfigService reading the
+// in-cluster configuration or kubeconfig as the server settings say. The startup preflight checks only run
+// for a KubeConfigService.
+func WithKubeClientFactory(factory ClientFactory) (option ServerOption) {
+	option = func(server *Server) {
+		server.clientFactory = factory
+	}
+	return option
+}
+
+// WithAuth identifies the end users of requests with authenticate instead of the identity headers, such as
+// from the session of an application embedding podboard. Requests it rejects get 401. The identity is used
+// for access bindings and, with impersonation, for Kubernetes API calls.
+func WithAuth(authenticate Authenticator) (option ServerOption) {
+	option = func(server *Server) {
+		server.authenticate = authenticate
+	}
+	return option
+}
+
+// WithListener makes Start serve on listener instead of listening on the configured address.
+func WithListener(listener net.Listener) (option ServerOption) {
+	option = func(server *Server) {
+		server.listener = listener
+	}
+	return option
+}
+
+// NewServer validates the settings and config file, creates the services and routes, and starts the
+// background loops, which run until Shutdown.
+func NewServer(config ServerConfig, options ...ServerOption) (server *Server, err error) {
+	gin.SetMode(gin.ReleaseMode)
+
+	server = &Server{config: config, logger: zap.NewNop()}
+	for _, option := range options {
+		option(server)
+	}
+	if server.authenticate == nil {
+		server.authenticate = configAuthenticator(config)
+	}
+
+	err = validateServerConfig(config)
+	if err != nil {
+		return server, err
+	}
+
+	fileConfig, err := LoadFileConfig(config.ConfigFile)
+	if err != nil {
+		return server, err
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	server.stop = stop
+	if server.clientFactory == nil {
+		kubeConfigService := NewKubeConfigService(config, server.logger)
+		go kubeConfigService.WatchKubeConfig(ctx)
+		server.clientFactory = kubeConfigService
+	}
+
+	services, err := newAPIServices(ctx, config, fileConfig, server.clientFactory, server.authenticate, server.logger)
+	if err != nil {
+		stop()
+		return server, err
+	}
+
+	kubeConfigService, ok := server.clientFactory.(*KubeConfigService)
+	if ok {
+		go runBackgroundPreflight(ctx, config, kubeConfigService, server.logger)
+	}
+
+	server.router = setupRouter()
+	setupAPIRoutes(server.router, services)
+	setupStatusRoutes(server.router, config, server.authenticate, services.statusService)
+	setupWebhookRoutes(server.router, services.githubService)
+	SetupUIRoutes(server.router, config.UIDir)
+
+	server.httpServer = newHTTPServer(config, server.router)
+	return server, err
+}
+
+// Handler returns the handler serving every podboard route, for mounting in another server.
+func (server *Server) Handler() (handler http.Handler) {
+	handler = server.router
+	return handler
+}
+
+// Start serves until Shutdown, on the listener if one was given and on the configured address otherwise. It
+// returns nil once shut down.
+func (server *Server) Start() (err error) {
+	server.logger.Info("Server starting", zap.String("address", server.config.Address), zap.Bool("fips", FIPSEnabled()), zap.Bool("offline", server.config.Offline), zap.Bool("impersonate", server.config.Impersonate), zap.String("uiDir", server.config.UIDir))
+
+	if server.listener != nil {
+		err = server.httpServer.Serve(server.listener)
+	} else {
+		err = server.httpServer.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+		return err
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to start server: %w", err)
+		return err
+	}
+	return err
+}
+
+// Shutdown stops the background loops and gracefully shuts down the HTTP server, waiting for active
+// requests until ctx is done. Open event streams keep it waiting until then.
+func (server *Server) Shutdown(ctx context.Context) (err error) {
+	server.stop()
+	err = server.httpServer.Shutdown(ctx)
+	return err
+}
@@ -0,0 +1,123 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// testCluster is the name of the fake cluster the test routers serve.
+const testCluster = "test"
+
+// newTestRouter serves the API routes from a fake clientset holding objects. The services' background loops
+// stop with the test.
+func newTestRouter(t *testing.T, config ServerConfig, fileConfig FileConfig, objects ...runtime.Object) (router *gin.Engine) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	factory := NewStaticClientFactory(map[string]kubernetes.Interface{testCluster: fake.NewClientset(objects...)}, testCluster)
+	services, err := newAPIServices(t.Context(), config, fileConfig, factory, configAuthenticator(config), zap.NewNop())
+	require.NoError(t, err)
+
+	router = setupRouter()
+	setupAPIRoutes(router, services)
+	return router
+}
+
+// serve sends a request with the given headers through handler and returns the recorded response.
+func serve(handler http.Handler, method, target string, headers map[string]string) (recorder *httptest.ResponseRecorder) {
+	request := httptest.NewRequest(method, target, nil)
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// decodeBody decodes a JSON response body.
+func decodeBody(t *testing.T, recorder *httptest.ResponseRecorder) (body map[string]interface{}) {
+	t.Helper()
+	err := json.Unmarshal(recorder.Body.Bytes(), &body)
+	require.NoError(t, err, recorder.Body.String())
+	return body
+}
+
+// testPod returns a running pod on a node.
+func testPod(namespace, name, nodeName string) (pod *corev1.Pod) {
+	pod = &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"app": name}},
+		Spec:       corev1.PodSpec{NodeName: nodeName},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	return pod
+}
+
+// podNames returns the names of the pods in a response's pods field.
+func podNames(t *testing.T, body map[string]interface{}) (names []string) {
+	t.Helper()
+	pods, ok := body["pods"].([]interface{})
+	require.True(t, ok, "response has no pods: %v", body)
+	for _, pod := range pods {
+		fields, isMap := pod.(map[string]interface{})
+		require.True(t, isMap)
+		name, _ := fields["name"].(string)
+		names = append(names, name)
+	}
+	return names
+}
+
+// testClock is a Clock tests move forward by hand.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// Now returns the clock's current time.
+func (clock *testClock) Now() (now time.Time) {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	now = clock.now
+	return now
+}
+
+// Advance moves the clock forward by d.
+func (clock *testClock) Advance(d time.Duration) {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	clock.now = clock.now.Add(d)
+}
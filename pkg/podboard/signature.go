@@ -0,0 +1,172 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	// SignatureSuffix is appended to a release asset name to form the name of its minisign signature.
+	SignatureSuffix = ".minisig"
+
+	minisignKeyIDSize       = 8
+	minisignTrustedComment  = "trusted comment: "
+	minisignUntrustedPrefix = "untrusted comment:"
+)
+
+// ReleasePublicKey is the minisign public key release assets are signed with, set at build time with
+// -ldflags "-X github.com/nikogura/podboard/pkg/podboard.ReleasePublicKey=RWQ...". When empty, podboard update
+// refuses to install unless given a key or --insecure-skip-signature.
+//
+//nolint:gochecknoglobals // Set at build time via -ldflags
+var ReleasePublicKey = ""
+
+// ErrInvalidSignature is returned when a signature does not verify against the public key.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// MinisignPublicKey is a parsed minisign Ed25519 public key.
+type MinisignPublicKey struct {
+	KeyID     [minisignKeyIDSize]byte
+	PublicKey ed25519.PublicKey
+}
+
+// minisignSignature is a parsed minisign signature file.
+type minisignSignature struct {
+	Algorithm       string
+	KeyID           [minisignKeyIDSize]byte
+	Signature       []byte
+	TrustedComment  string
+	GlobalSignature []byte
+}
+
+// ParseMinisignPublicKey parses a minisign public key, either the bare base64 key or the contents of a .pub file.
+func ParseMinisignPublicKey(text string) (key MinisignPublicKey, err error) {
+	encoded := ""
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, minisignUntrustedPrefix) {
+			encoded = line
+			break
+		}
+	}
+
+	var raw []byte
+	raw, err = base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		err = fmt.Errorf("invalid minisign public key: %w", err)
+		return key, err
+	}
+
+	if len(raw) != 2+minisignKeyIDSize+ed25519.PublicKeySize || string(raw[:2]) != "Ed" {
+		err = errors.New("invalid minisign public key: unsupported format")
+		return key, err
+	}
+
+	copy(key.KeyID[:], raw[2:2+minisignKeyIDSize])
+	key.PublicKey = ed25519.PublicKey(raw[2+minisignKeyIDSize:])
+	return key, err
+}
+
+// parseMinisignSignature parses the four line minisign signature format.
+func parseMinisignSignature(text []byte) (signature minisignSignature, err error) {
+	lines := strings.Split(strings.TrimSpace(string(bytes.ReplaceAll(text, []byte("\r\n"), []byte("\n")))), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[2], minisignTrustedComment) {
+		err = errors.New("invalid minisign signature: unsupported format")
+		return signature, err
+	}
+
+	var raw []byte
+	raw, err = base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(raw) != 2+minisignKeyIDSize+ed25519.SignatureSize {
+		err = errors.New("invalid minisign signature: malformed signature line")
+		return signature, err
+	}
+
+	signature.Algorithm = string(raw[:2])
+	copy(signature.KeyID[:], raw[2:2+minisignKeyIDSize])
+	signature.Signature = raw[2+minisignKeyIDSize:]
+	signature.TrustedComment = strings.TrimPrefix(lines[2], minisignTrustedComment)
+
+	signature.GlobalSignature, err = base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(signature.GlobalSignature) != ed25519.SignatureSize {
+		err = errors.New("invalid minisign signature: malformed global signature")
+		return signature, err
+	}
+
+	return signature, err
+}
+
+// VerifyMinisign verifies a minisign signature of message, including its trusted comment,
+// and returns the trusted comment. Both legacy (Ed) and prehashed (ED) signatures are supported.
+func VerifyMinisign(key MinisignPublicKey, message, signatureText []byte) (trustedComment string, err error) {
+	var signature minisignSignature
+	signature, err = parseMinisignSignature(signatureText)
+	if err != nil {
+		return trustedComment, err
+	}
+
+	if signature.KeyID != key.KeyID {
+		err = fmt.Errorf("%w: signed with key %X, expected %X", ErrInvalidSignature, reverseKeyID(signature.KeyID), reverseKeyID(key.KeyID))
+		return trustedComment, err
+	}
+
+	switch signature.Algorithm {
+	case "Ed":
+	case "ED":
+		digest := blake2b.Sum512(message)
+		message = digest[:]
+	default:
+		err = fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidSignature, signature.Algorithm)
+		return trustedComment, err
+	}
+
+	if !ed25519.Verify(key.PublicKey, message, signature.Signature) {
+		err = fmt.Errorf("%w: signature does not match", ErrInvalidSignature)
+		return trustedComment, err
+	}
+
+	global := append(append([]byte{}, signature.Signature...), signature.TrustedComment...)
+	if !ed25519.Verify(key.PublicKey, global, signature.GlobalSignature) {
+		err = fmt.Errorf("%w: trusted comment does not match", ErrInvalidSignature)
+		return trustedComment, err
+	}
+
+	trustedComment = signature.TrustedComment
+	return trustedComment, err
+}
+
+// reverseKeyID returns the key id in the byte order minisign displays it.
+func reverseKeyID(keyID [minisignKeyIDSize]byte) (reversed [minisignKeyIDSize]byte) {
+	for i := range keyID {
+		reversed[minisignKeyIDSize-1-i] = keyID[i]
+	}
+	return reversed
+}
@@ -0,0 +1,149 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DefaultStreamHeartbeat is the interval between heartbeats on streams, well under the 60 second idle
+	// timeouts of AWS load balancers and NGINX's proxy_read_timeout.
+	DefaultStreamHeartbeat = 15 * time.Second
+	// DefaultStreamRetry is the reconnect delay streams advertise to clients.
+	DefaultStreamRetry = 3 * time.Second
+	// DefaultIdleTimeout keeps idle keep-alive connections open longer than the 60 second idle timeout of common
+	// proxies, so the proxy closes them first rather than reusing a connection podboard is closing.
+	DefaultIdleTimeout = 75 * time.Second
+	// streamErrorRetryFactor stretches the advertised reconnect delay after a stream failed, so clients back
+	// off from a failing cluster instead of reconnecting every few seconds.
+	streamErrorRetryFactor = 10
+	// readHeaderTimeout bounds how long a client may take to send request headers.
+	readHeaderTimeout = 10 * time.Second
+)
+
+// eventStream writes Server-Sent Events. Writes are serialized so heartbeats can be sent between events.
+type eventStream struct {
+	c     *gin.Context
+	mu    sync.Mutex
+	retry time.Duration
+}
+
+// startEventStream starts a Server-Sent Events response advertising the configured reconnect delay.
+// Streams are exempt from the server's write timeout, which would otherwise cut them off, and from load shedding's
+// count of requests in flight.
+func startEventStream(c *gin.Context, config ServerConfig) (stream *eventStream) {
+	stream = &eventStream{c: c, retry: config.StreamRetry}
+	if stream.retry <= 0 {
+		stream.retry = DefaultStreamRetry
+	}
+
+	// Fails only if the connection doesn't support deadlines, in which case there is no timeout to lift.
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+	// Open streams don't count towards the requests in flight.
+	releaseLoadSlot(c)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Stops NGINX from buffering the stream.
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	stream.write(fmt.Sprintf("retry: %d\n\n", stream.retry.Milliseconds()))
+	return stream
+}
+
+// Event sends a named event.
+func (es *eventStream) Event(name string, data interface{}) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	es.c.SSEvent(name, data)
+	es.c.Writer.Flush()
+}
+
+// EventWithID sends a named event with an id, which browsers send back in the Last-Event-ID header when they
+// reconnect, so the stream can resume where it left off.
+func (es *eventStream) EventWithID(id, name string, data interface{}) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		es.Fail(fmt.Errorf("failed to encode %s event: %w", name, err))
+		return
+	}
+	es.write(fmt.Sprintf("id: %s\nevent: %s\ndata: %s\n\n", id, name, encoded))
+}
+
+// Fail sends an error event and advertises a longer reconnect delay.
+func (es *eventStream) Fail(err error) {
+	backoff := es.retry * streamErrorRetryFactor
+	es.Event("error", gin.H{"error": err.Error(), "retryAfterMs": backoff.Milliseconds()})
+	es.write(fmt.Sprintf("retry: %d\n\n", backoff.Milliseconds()))
+}
+
+// KeepAlive sends a comment line at every interval so proxies don't close the stream as idle while no
+// events happen. The returned function stops the heartbeats and must be called before the handler returns.
+// A zero interval disables heartbeats.
+func (es *eventStream) KeepAlive(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	stop = func() {
+		close(done)
+		<-stopped
+	}
+	if interval <= 0 {
+		close(stopped)
+		return stop
+	}
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-es.c.Request.Context().Done():
+				return
+			case <-ticker.C:
+				es.write(": heartbeat\n\n")
+			}
+		}
+	}()
+	return stop
+}
+
+// write sends raw stream content and flushes it to the client.
+func (es *eventStream) write(content string) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	_, _ = es.c.Writer.WriteString(content)
+	es.c.Writer.Flush()
+}
@@ -0,0 +1,56 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"time"
+)
+
+const (
+	defaultStaleMaxAge     = 15 * time.Minute
+	defaultStaleMaxEntries = 500
+)
+
+// StaleCache remembers the last successful result of list queries so they can be served,
+// flagged as stale, while the upstream API server is unavailable.
+type StaleCache struct {
+	results *ttlCache[string, interface{}]
+}
+
+// NewStaleCache creates a cache whose entries are served for at most maxAge, holding at most maxEntries queries.
+func NewStaleCache(maxAge time.Duration, maxEntries int) (cache *StaleCache) {
+	cache = &StaleCache{results: newTTLCache[string, interface{}](maxAge, maxEntries)}
+	return cache
+}
+
+// Store records the latest good result for a query.
+func (sc *StaleCache) Store(key string, value interface{}) {
+	sc.results.Store(key, value)
+}
+
+// Load returns the last good result for a query and when it was stored.
+// Entries older than the cache's max age are not returned.
+func (sc *StaleCache) Load(key string) (value interface{}, storedAt time.Time, ok bool) {
+	value, storedAt, ok = sc.results.Load(key)
+	return value, storedAt, ok
+}
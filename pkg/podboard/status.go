@@ -0,0 +1,255 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Workload health levels shown on the status page, from best to worst.
+const (
+	StatusGreen  = "green"
+	StatusYellow = "yellow"
+	StatusRed    = "red"
+)
+
+// ErrStatusDisabled is returned when no status page workloads are configured.
+var ErrStatusDisabled = errors.New("status page is not configured")
+
+// statusCacheTTL limits how often the status page queries the clusters, since it may be polled by many dependents.
+const statusCacheTTL = 15 * time.Second
+
+// StatusConfig configures the status page.
+type StatusConfig struct {
+	// Title is shown at the top of the HTML status page.
+	Title string `yaml:"title"`
+	// Public serves the status page without the authenticating proxy's identity headers.
+	Public bool `yaml:"public"`
+	// Workloads are the workloads summarized on the status page.
+	Workloads []StatusWorkload `yaml:"workloads"`
+}
+
+// StatusWorkload selects the pods that make up a workload on the status page.
+type StatusWorkload struct {
+	Name          string `json:"name"                    yaml:"name"`
+	Cluster       string `json:"cluster,omitempty"       yaml:"cluster"`
+	Namespace     string `json:"namespace"               yaml:"namespace"`
+	LabelSelector string `json:"labelSelector,omitempty" yaml:"labelSelector"`
+}
+
+// WorkloadStatus is the health of a single workload.
+type WorkloadStatus struct {
+	StatusWorkload
+	Status    string `json:"status"`
+	Message   string `json:"message"`
+	ReadyPods int    `json:"readyPods"`
+	TotalPods int    `json:"totalPods"`
+}
+
+// StatusReport summarizes the health of every configured workload.
+type StatusReport struct {
+	Title     string           `json:"title"`
+	Status    string           `json:"status"`
+	UpdatedAt time.Time        `json:"updatedAt"`
+	Workloads []WorkloadStatus `json:"workloads"`
+}
+
+// StatusService builds the status page from pod data.
+type StatusService struct {
+	status     StatusConfig
+	podService *PodService
+	logger     *zap.Logger
+
+	mu       sync.Mutex
+	cached   StatusReport
+	cachedAt time.Time
+}
+
+// NewStatusService validates the status page configuration and creates a new status service.
+func NewStatusService(status StatusConfig, podService *PodService, logger *zap.Logger) (service *StatusService, err error) {
+	if status.Title == "" {
+		status.Title = "Service Status"
+	}
+
+	for i, workload := range status.Workloads {
+		if workload.Name == "" {
+			err = fmt.Errorf("status: workload %d has no name", i+1)
+			return service, err
+		}
+		if workload.Namespace == "" {
+			status.Workloads[i].Namespace = "default"
+		}
+	}
+
+	service = &StatusService{
+		status:     status,
+		podService: podService,
+		logger:     logger,
+	}
+	return service, err
+}
+
+// Enabled returns true if any workloads are configured.
+func (ss *StatusService) Enabled() (enabled bool) {
+	enabled = len(ss.status.Workloads) > 0
+	return enabled
+}
+
+// Public returns true if the status page is served without identity headers.
+func (ss *StatusService) Public() (public bool) {
+	public = ss.status.Public
+	return public
+}
+
+// Report returns the current status of every configured workload, reusing a recent report if there is one.
+// Reports are shared by all viewers, so pods are always listed with podboard's own credentials.
+func (ss *StatusService) Report(ctx context.Context) (report StatusReport, err error) {
+	if !ss.Enabled() {
+		err = ErrStatusDisabled
+		return report, err
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	// The cached report is stored in UTC and rendered in each viewer's time zone.
+	view := timeViewFromContext(ctx)
+	now := view.clock.Now()
+	if !ss.cachedAt.IsZero() && now.Sub(ss.cachedAt) < statusCacheTTL {
+		report = ss.cached
+		report.UpdatedAt = report.UpdatedAt.In(view.location)
+		return report, err
+	}
+
+	// Drop any impersonated identity; the cached report must not depend on who asked first.
+	queryCtx, cancel := context.WithTimeout(WithIdentity(ctx, Identity{}), clusterQueryTimeout)
+	defer cancel()
+
+	report = StatusReport{Title: ss.status.Title, Status: StatusGreen, UpdatedAt: now.UTC()}
+	for _, workload := range ss.status.Workloads {
+		workloadStatus := ss.workloadStatus(queryCtx, workload)
+		report.Workloads = append(report.Workloads, workloadStatus)
+		report.Status = worseStatus(report.Status, workloadStatus.Status)
+	}
+
+	ss.cached = report
+	ss.cachedAt = now
+	report.UpdatedAt = report.UpdatedAt.In(view.location)
+	return report, err
+}
+
+// workloadStatus derives a workload's health from the readiness of its pods.
+func (ss *StatusService) workloadStatus(ctx context.Context, workload StatusWorkload) (status WorkloadStatus) {
+	status = WorkloadStatus{StatusWorkload: workload}
+
+	pods, err := ss.podService.GetPods(ctx, workload.Cluster, workload.Namespace, workload.LabelSelector, PodFilter{})
+	if err != nil {
+		ss.logger.Warn("Failed to get status page workload", zap.Error(err), zap.String("workload", workload.Name))
+		status.Status = StatusRed
+		status.Message = "unable to query pods"
+		return status
+	}
+
+	status.TotalPods = len(pods)
+	for _, pod := range pods {
+		if podIsReady(pod) {
+			status.ReadyPods++
+		}
+	}
+
+	switch {
+	case status.TotalPods == 0:
+		status.Status = StatusRed
+		status.Message = "no pods"
+	case status.ReadyPods == 0:
+		status.Status = StatusRed
+		status.Message = "no pods ready"
+	case status.ReadyPods < status.TotalPods:
+		status.Status = StatusYellow
+		status.Message = fmt.Sprintf("%d of %d pods ready", status.ReadyPods, status.TotalPods)
+	default:
+		status.Status = StatusGreen
+		status.Message = "operational"
+	}
+
+	return status
+}
+
+// podIsReady returns true if a running pod has all of its containers ready.
+func podIsReady(pod PodInfo) (ready bool) {
+	if pod.Status != "Running" {
+		return ready
+	}
+
+	readyContainers, totalContainers := parseReady(pod.Ready)
+	ready = totalContainers > 0 && readyContainers == totalContainers
+	return ready
+}
+
+// worseStatus returns the worse of two health levels.
+func worseStatus(a, b string) (worse string) {
+	rank := map[string]int{StatusGreen: 0, StatusYellow: 1, StatusRed: 2}
+	worse = a
+	if rank[b] > rank[a] {
+		worse = b
+	}
+	return worse
+}
+
+// statusPageTemplate renders the HTML status page. It refreshes itself every 30 seconds.
+//
+//nolint:gochecknoglobals // Parsed once at startup
+var statusPageTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="30">
+<title>{{ .Title }}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; max-width: 48rem; margin: 2rem auto; padding: 0 1rem; color: #333; }
+.banner { padding: 1rem; border-radius: 8px; color: #fff; font-weight: 600; margin-bottom: 1.5rem; }
+.green { background: #28a745; } .yellow { background: #e0a800; } .red { background: #dc3545; }
+.dot { display: inline-block; width: 0.75rem; height: 0.75rem; border-radius: 50%; margin-right: 0.5rem; }
+table { width: 100%; border-collapse: collapse; }
+td { padding: 0.75rem 0; border-top: 1px solid #ddd; }
+td.message { text-align: right; color: #666; }
+footer { margin-top: 1.5rem; font-size: 0.875rem; color: #666; }
+</style>
+</head>
+<body>
+<h1>{{ .Title }}</h1>
+<div class="banner {{ .Status }}">{{ if eq .Status "green" }}All systems operational{{ else if eq .Status "yellow" }}Some systems degraded{{ else }}Some systems down{{ end }}</div>
+<table>
+{{ range .Workloads }}<tr><td><span class="dot {{ .Status }}"></span>{{ .Name }}</td><td class="message">{{ .Message }}</td></tr>
+{{ end }}</table>
+<footer>Updated {{ .UpdatedAt.Format "2006-01-02 15:04:05 MST" }}</footer>
+</body>
+</html>
+`))
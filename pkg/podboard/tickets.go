@@ -0,0 +1,332 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Supported ticket backends.
+const (
+	TicketBackendJira       = "jira"
+	TicketBackendServiceNow = "servicenow"
+)
+
+const (
+	ticketHTTPTimeout    = 30 * time.Second
+	ticketLogLines       = 50
+	ticketMaxEvents      = 20
+	defaultJiraIssueType = "Bug"
+	defaultSNOWTable     = "incident"
+)
+
+// ErrTicketsDisabled is returned when no ticket backend is configured.
+var ErrTicketsDisabled = errors.New("ticket integration is not configured")
+
+// TicketConfig configures the ticket backend incidents are filed in.
+type TicketConfig struct {
+	// Backend is jira or servicenow. Empty disables ticket creation.
+	Backend string `yaml:"backend"`
+	// URL is the base URL of the Jira or ServiceNow instance.
+	URL string `yaml:"url"`
+	// Username authenticates to the backend together with the token.
+	Username string `yaml:"username"`
+	// TokenEnv names the environment variable holding the API token or password, keeping it out of the file.
+	TokenEnv string `yaml:"tokenEnv"`
+	// Project is the Jira project key.
+	Project string `yaml:"project"`
+	// IssueType is the Jira issue type (default Bug).
+	IssueType string `yaml:"issueType"`
+	// Table is the ServiceNow table (default incident).
+	Table string `yaml:"table"`
+}
+
+// TicketInfo identifies a created ticket.
+type TicketInfo struct {
+	Backend string `json:"backend"`
+	ID      string `json:"id"`
+	URL     string `json:"url"`
+}
+
+// TicketService files incident tickets pre-filled with a pod diagnosis.
+type TicketService struct {
+	config     ServerConfig
+	tickets    TicketConfig
+	podService *PodService
+	httpClient *http.Client
+	logger     *zap.Logger
+}
+
+// NewTicketService validates the ticket configuration and creates a new ticket service.
+func NewTicketService(config ServerConfig, tickets TicketConfig, podService *PodService, logger *zap.Logger) (service *TicketService, err error) {
+	service = &TicketService{
+		config:     config,
+		tickets:    tickets,
+		podService: podService,
+		httpClient: &http.Client{Timeout: ticketHTTPTimeout},
+		logger:     logger,
+	}
+
+	switch tickets.Backend {
+	case "":
+		return service, err
+	case TicketBackendJira:
+		if tickets.Project == "" {
+			err = errors.New("tickets: project is required for jira")
+			return service, err
+		}
+	case TicketBackendServiceNow:
+	default:
+		err = fmt.Errorf("tickets: backend must be %q or %q", TicketBackendJira, TicketBackendServiceNow)
+		return service, err
+	}
+
+	if tickets.URL == "" {
+		err = errors.New("tickets: url is required")
+		return service, err
+	}
+	service.tickets.URL = strings.TrimSuffix(tickets.URL, "/")
+
+	return service, err
+}
+
+// Backend returns the configured ticket backend, or "" when tickets are disabled.
+func (ts *TicketService) Backend() (backend string) {
+	backend = ts.tickets.Backend
+	return backend
+}
+
+// CreatePodTicket files a ticket describing the pod's status, recent events and last log lines.
+func (ts *TicketService) CreatePodTicket(ctx context.Context, clusterName, namespace, podName, note string) (ticket TicketInfo, err error) {
+	if ts.tickets.Backend == "" {
+		err = ErrTicketsDisabled
+		return ticket, err
+	}
+
+	err = ts.config.CheckOutbound("ticket")
+	if err != nil {
+		return ticket, err
+	}
+
+	var pod PodInfo
+	pod, err = ts.podService.GetPod(ctx, clusterName, namespace, podName)
+	if err != nil {
+		return ticket, err
+	}
+
+	// Events and logs are best effort, the ticket is still useful without them.
+	events, eventsErr := ts.podService.GetPodEvents(ctx, clusterName, namespace, podName)
+	if eventsErr != nil {
+		ts.logger.Warn("Failed to get events for ticket", zap.Error(eventsErr), zap.String("namespace", namespace), zap.String("pod", podName))
+	}
+	logs, logsErr := ts.podService.GetPodLogs(ctx, clusterName, namespace, podName, ticketLogLines)
+	if logsErr != nil {
+		ts.logger.Warn("Failed to get logs for ticket", zap.Error(logsErr), zap.String("namespace", namespace), zap.String("pod", podName))
+	}
+
+	summary := fmt.Sprintf("Pod %s/%s is %s", namespace, podName, pod.Status)
+	if clusterName != "" {
+		summary += " in cluster " + clusterName
+	}
+	description := ts.describePod(clusterName, identityUser(ctx), requestID(ctx), note, pod, events, logs)
+
+	if ts.tickets.Backend == TicketBackendJira {
+		ticket, err = ts.createJiraIssue(ctx, summary, description)
+	} else {
+		ticket, err = ts.createServiceNowRecord(ctx, summary, description)
+	}
+	if err != nil {
+		return ticket, err
+	}
+
+	ts.logger.Info("Created ticket", zap.String("backend", ticket.Backend), zap.String("ticket", ticket.ID), zap.String("cluster", clusterName), zap.String("namespace", namespace), zap.String("pod", podName), zap.String("requestId", requestID(ctx)))
+	return ticket, err
+}
+
+// describePod renders the pod diagnosis used as the ticket description.
+func (ts *TicketService) describePod(clusterName, user, id, note string, pod PodInfo, events []EventInfo, logs []ContainerLogs) (description string) {
+	var b strings.Builder
+	if note != "" {
+		fmt.Fprintf(&b, "%s\n\n", note)
+	}
+
+	fmt.Fprintf(&b, "Pod: %s/%s\n", pod.Namespace, pod.Name)
+	if clusterName != "" {
+		fmt.Fprintf(&b, "Cluster: %s\n", clusterName)
+	}
+	fmt.Fprintf(&b, "Status: %s\nReady: %s\nRestarts: %d\nAge: %s\nNode: %s\nImage tag: %s\n", pod.Status, pod.Ready, pod.Restarts, pod.Age, pod.Node, pod.ImageTag)
+	if pod.RunbookURL != "" {
+		fmt.Fprintf(&b, "Runbook: %s\n", pod.RunbookURL)
+	}
+	if user != "" {
+		fmt.Fprintf(&b, "Reported by: %s\n", user)
+	}
+	if id != "" {
+		fmt.Fprintf(&b, "Request ID: %s\n", id)
+	}
+
+	if len(events) > 0 {
+		b.WriteString("\nRecent events:\n")
+		for i, event := range events {
+			if i == ticketMaxEvents {
+				break
+			}
+			fmt.Fprintf(&b, "- [%s] %s (x%d, %s ago): %s\n", event.Type, event.Reason, event.Count, event.Age, event.Message)
+		}
+	}
+
+	for _, containerLogs := range logs {
+		fmt.Fprintf(&b, "\nLast %d log lines of container %s:\n", ticketLogLines, containerLogs.Container)
+		if containerLogs.Error != "" {
+			fmt.Fprintf(&b, "(logs unavailable: %s)\n", containerLogs.Error)
+			continue
+		}
+		b.WriteString(ts.preformatted(containerLogs.Logs))
+	}
+
+	description = b.String()
+	return description
+}
+
+// preformatted wraps text so the backend renders it verbatim.
+func (ts *TicketService) preformatted(text string) (wrapped string) {
+	text = strings.TrimRight(text, "\n")
+	if ts.tickets.Backend == TicketBackendJira {
+		wrapped = "{noformat}\n" + text + "\n{noformat}\n"
+		return wrapped
+	}
+	wrapped = text + "\n"
+	return wrapped
+}
+
+// createJiraIssue files an issue through the Jira REST API.
+func (ts *TicketService) createJiraIssue(ctx context.Context, summary, description string) (ticket TicketInfo, err error) {
+	issueType := ts.tickets.IssueType
+	if issueType == "" {
+		issueType = defaultJiraIssueType
+	}
+
+	request := map[string]interface{}{
+		"fields": map[string]interface{}{
+			"project":     map[string]string{"key": ts.tickets.Project},
+			"issuetype":   map[string]string{"name": issueType},
+			"summary":     summary,
+			"description": description,
+		},
+	}
+
+	var response struct {
+		Key string `json:"key"`
+	}
+	err = ts.post(ctx, ts.tickets.URL+"/rest/api/2/issue", request, &response)
+	if err != nil {
+		return ticket, err
+	}
+
+	ticket = TicketInfo{Backend: TicketBackendJira, ID: response.Key, URL: ts.tickets.URL + "/browse/" + response.Key}
+	return ticket, err
+}
+
+// createServiceNowRecord files a record through the ServiceNow Table API.
+func (ts *TicketService) createServiceNowRecord(ctx context.Context, summary, description string) (ticket TicketInfo, err error) {
+	table := ts.tickets.Table
+	if table == "" {
+		table = defaultSNOWTable
+	}
+
+	request := map[string]string{
+		"short_description": summary,
+		"description":       description,
+	}
+
+	var response struct {
+		Result struct {
+			SysID  string `json:"sys_id"`
+			Number string `json:"number"`
+		} `json:"result"`
+	}
+	err = ts.post(ctx, ts.tickets.URL+"/api/now/table/"+table, request, &response)
+	if err != nil {
+		return ticket, err
+	}
+
+	ticket = TicketInfo{
+		Backend: TicketBackendServiceNow,
+		ID:      response.Result.Number,
+		URL:     fmt.Sprintf("%s/nav_to.do?uri=%s.do?sys_id=%s", ts.tickets.URL, table, response.Result.SysID),
+	}
+	return ticket, err
+}
+
+// post sends a JSON request with basic authentication and decodes the JSON response.
+func (ts *TicketService) post(ctx context.Context, url string, body, result interface{}) (err error) {
+	var payload []byte
+	payload, err = json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	var request *http.Request
+	request, err = http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/json")
+	setRequestIDHeader(request)
+	if ts.tickets.Username != "" || ts.tickets.TokenEnv != "" {
+		request.SetBasicAuth(ts.tickets.Username, os.Getenv(ts.tickets.TokenEnv))
+	}
+
+	var response *http.Response
+	response, err = ts.httpClient.Do(request)
+	if err != nil {
+		err = fmt.Errorf("failed to create %s ticket: %w", ts.tickets.Backend, err)
+		return err
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(response.Body, actionMaxResponseBody))
+		err = fmt.Errorf("failed to create %s ticket: %s: %s", ts.tickets.Backend, response.Status, strings.TrimSpace(string(detail)))
+		return err
+	}
+
+	err = json.NewDecoder(response.Body).Decode(result)
+	if err != nil {
+		err = fmt.Errorf("failed to parse %s response: %w", ts.tickets.Backend, err)
+		return err
+	}
+	return err
+}
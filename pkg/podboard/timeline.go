@@ -0,0 +1,217 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Timeline entry types.
+const (
+	TimelineLifecycle  = "lifecycle"
+	TimelineContainer  = "container"
+	TimelineEvent      = "event"
+	TimelinePreemption = "preemption"
+	TimelineSandbox    = "sandbox"
+)
+
+// TimelineEntry is one step in a pod's history.
+type TimelineEntry struct {
+	Time      string `json:"time"`
+	Type      string `json:"type"`
+	Reason    string `json:"reason"`
+	Message   string `json:"message,omitempty"`
+	Container string `json:"container,omitempty"`
+
+	at time.Time
+}
+
+// GetPodTimeline returns the history of a pod, oldest first: its creation and condition changes, container
+// starts and terminations, its events, and preemptions, both of this pod and of pods it preempted.
+// Sandbox recreations are typed sandbox, and container restarts say whether they came with one.
+// It returns ErrPodNotFound if the pod doesn't exist.
+func (ps *PodService) GetPodTimeline(ctx context.Context, clusterName, namespace, podName string) (timeline []TimelineEntry, err error) {
+	err = ps.namespacePolicy.Check(namespace)
+	if err != nil {
+		return timeline, err
+	}
+
+	var client kubernetes.Interface
+	client, err = ps.getClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return timeline, err
+	}
+
+	var pod *corev1.Pod
+	pod, err = client.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			err = fmt.Errorf("%w: %s/%s", ErrPodNotFound, namespace, podName)
+			return timeline, err
+		}
+		err = fmt.Errorf("failed to get pod %s/%s: %w", namespace, podName, err)
+		return timeline, err
+	}
+
+	timeline = podStatusTimeline(pod)
+
+	selector := fields.Set{"involvedObject.kind": KindPod, "involvedObject.name": podName}.AsSelector().String()
+	var eventList *corev1.EventList
+	eventList, err = client.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		err = fmt.Errorf("failed to list events for pod %s/%s: %w", namespace, podName, err)
+		return timeline, err
+	}
+	for i := range eventList.Items {
+		event := &eventList.Items[i]
+		entryType := TimelineEvent
+		switch event.Reason {
+		case eventReasonPreempted:
+			entryType = TimelinePreemption
+		case eventReasonSandboxChanged:
+			entryType = TimelineSandbox
+		}
+		timeline = append(timeline, TimelineEntry{at: eventLastSeen(event), Type: entryType, Reason: event.Reason, Message: event.Message})
+	}
+
+	timeline = append(timeline, ps.preemptedByPod(ctx, client, pod)...)
+
+	sort.SliceStable(timeline, func(i, j int) (less bool) {
+		less = timeline[i].at.Before(timeline[j].at)
+		return less
+	})
+	attributeContainerRestarts(timeline)
+	view := timeViewFromContext(ctx)
+	for i := range timeline {
+		timeline[i].Time = view.format(timeline[i].at)
+	}
+
+	return timeline, err
+}
+
+// podStatusTimeline derives timeline entries from a pod's creation, conditions and container states.
+func podStatusTimeline(pod *corev1.Pod) (timeline []TimelineEntry) {
+	timeline = append(timeline, TimelineEntry{at: pod.CreationTimestamp.Time, Type: TimelineLifecycle, Reason: "Created"})
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.LastTransitionTime.IsZero() {
+			continue
+		}
+		entry := TimelineEntry{at: condition.LastTransitionTime.Time, Type: TimelineLifecycle, Reason: string(condition.Type), Message: condition.Message}
+		if condition.Status != corev1.ConditionTrue {
+			entry.Reason = "Not" + string(condition.Type)
+		}
+		if isSandboxCondition(condition.Type) {
+			entry.Type = TimelineSandbox
+		}
+		if condition.Type == corev1.DisruptionTarget {
+			// Disruptions are named by their cause, e.g. PreemptionByScheduler or EvictionByEvictionAPI.
+			entry.Reason = condition.Reason
+			if condition.Reason == corev1.PodReasonPreemptionByScheduler {
+				entry.Type = TimelinePreemption
+			}
+		}
+		timeline = append(timeline, entry)
+	}
+
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		// The previous run was itself a restart unless the container restarted only once.
+		timeline = append(timeline, containerStateTimeline(status.Name, status.LastTerminationState, status.RestartCount > 1)...)
+		timeline = append(timeline, containerStateTimeline(status.Name, status.State, status.RestartCount > 0)...)
+	}
+
+	if pod.DeletionTimestamp != nil {
+		timeline = append(timeline, TimelineEntry{at: pod.DeletionTimestamp.Time, Type: TimelineLifecycle, Reason: "Terminating"})
+	}
+
+	return timeline
+}
+
+// containerStateTimeline returns the start and, if it ended, the termination of a container state.
+// Starts of restarted containers have the Restarted reason.
+func containerStateTimeline(container string, state corev1.ContainerState, restarted bool) (timeline []TimelineEntry) {
+	started := "Started"
+	if restarted {
+		started = "Restarted"
+	}
+
+	switch {
+	case state.Running != nil && !state.Running.StartedAt.IsZero():
+		timeline = append(timeline, TimelineEntry{at: state.Running.StartedAt.Time, Type: TimelineContainer, Reason: started, Container: container})
+	case state.Terminated != nil:
+		terminated := state.Terminated
+		if !terminated.StartedAt.IsZero() {
+			timeline = append(timeline, TimelineEntry{at: terminated.StartedAt.Time, Type: TimelineContainer, Reason: started, Container: container})
+		}
+		if !terminated.FinishedAt.IsZero() {
+			message := fmt.Sprintf("exit code %d", terminated.ExitCode)
+			if terminated.Message != "" {
+				message += ": " + terminated.Message
+			}
+			reason := terminated.Reason
+			if reason == "" {
+				reason = "Terminated"
+			}
+			timeline = append(timeline, TimelineEntry{at: terminated.FinishedAt.Time, Type: TimelineContainer, Reason: reason, Message: message, Container: container})
+		}
+	}
+	return timeline
+}
+
+// preemptedByPod returns a timeline entry for each pod the given pod preempted. Victims may live in any
+// namespace, so every namespace is searched, falling back to the pod's own if podboard can't list them all.
+func (ps *PodService) preemptedByPod(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod) (timeline []TimelineEntry) {
+	events, err := preemptionEvents(ctx, client, "")
+	if err != nil {
+		events, err = preemptionEvents(ctx, client, pod.Namespace)
+		if err != nil {
+			return timeline
+		}
+	}
+
+	for i := range events {
+		match := preemptionMessagePattern.FindStringSubmatch(events[i].Message)
+		if match == nil || (match[1] != string(pod.UID) && match[1] != pod.Namespace+"/"+pod.Name) {
+			continue
+		}
+		victim := events[i].InvolvedObject.Namespace + "/" + events[i].InvolvedObject.Name
+		timeline = append(timeline, TimelineEntry{
+			at:      eventLastSeen(&events[i]),
+			Type:    TimelinePreemption,
+			Reason:  "PreemptedOther",
+			Message: fmt.Sprintf("preempted pod %s on node %s", victim, match[2]),
+		})
+	}
+	return timeline
+}
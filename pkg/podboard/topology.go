@@ -0,0 +1,451 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Kinds of the objects in a topology besides pods and nodes.
+const (
+	KindService               = "Service"
+	KindConfigMap             = "ConfigMap"
+	KindSecret                = "Secret"
+	KindPersistentVolumeClaim = "PersistentVolumeClaim"
+)
+
+// Topology edge types. Edges point from the dependent object to the object it depends on.
+const (
+	// TopologyEdgeSelects links a Service to a pod it routes traffic to.
+	TopologyEdgeSelects = "selects"
+	// TopologyEdgeMounts links a pod to a ConfigMap, Secret or PersistentVolumeClaim it mounts as a volume.
+	TopologyEdgeMounts = "mounts"
+	// TopologyEdgeReferences links a pod to a ConfigMap or Secret its environment or image pulls read.
+	TopologyEdgeReferences = "references"
+	// TopologyEdgeRunsOn links a pod to its node.
+	TopologyEdgeRunsOn = "runs-on"
+)
+
+// topologyKindOrder orders topology nodes from the entry points down to the nodes everything runs on.
+//
+//nolint:gochecknoglobals // Read-only lookup table
+var topologyKindOrder = map[string]int{
+	KindService:               0,
+	KindPod:                   1,
+	KindConfigMap:             2,
+	KindSecret:                3,
+	KindPersistentVolumeClaim: 4,
+	KindNode:                  5,
+}
+
+// Topology is the dependency graph of the workloads in a namespace: Services, the pods they select, the
+// ConfigMaps, Secrets and PersistentVolumeClaims the pods use, and the nodes they run on.
+type Topology struct {
+	Namespaces []string       `json:"namespaces"`
+	Nodes      []TopologyNode `json:"nodes"`
+	Edges      []TopologyEdge `json:"edges"`
+}
+
+// TopologyNode is an object in a topology. ID is Kind/namespace/name, or Node/name for nodes. Pods carry their
+// owning workload as Owner. Unhealthy objects list the objects depending on them, directly or not, as
+// BlastRadius.
+type TopologyNode struct {
+	ID          string   `json:"id"`
+	Kind        string   `json:"kind"`
+	Namespace   string   `json:"namespace,omitempty"`
+	Name        string   `json:"name"`
+	Status      string   `json:"status,omitempty"`
+	Healthy     bool     `json:"healthy"`
+	Owner       string   `json:"owner,omitempty"`
+	BlastRadius []string `json:"blastRadius,omitempty"`
+}
+
+// TopologyEdge is a dependency of the From object on the To object.
+type TopologyEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Type string `json:"type"`
+}
+
+// podReference is an object a pod depends on. Optional references don't stop the pod starting when the object
+// is missing.
+type podReference struct {
+	kind     string
+	name     string
+	edgeType string
+	optional bool
+}
+
+// topologyBuilder collects the objects and edges of a topology, each once.
+type topologyBuilder struct {
+	nodes map[string]*TopologyNode
+	edges map[TopologyEdge]bool
+	// required marks the objects some pod can't start without.
+	required map[string]bool
+}
+
+// GetTopology returns the dependency graph of the pods in a namespace or comma-separated list of namespaces.
+// Finished pods are left out. Referenced ConfigMaps, Secrets and PersistentVolumeClaims that don't exist are
+// included with the Missing status, unhealthy unless every reference to them is optional. Objects that can't
+// be read, such as Secrets or nodes the user isn't allowed to get, are included without a status.
+func (ps *PodService) GetTopology(ctx context.Context, clusterName, namespace string) (topology Topology, err error) {
+	err = ps.namespacePolicy.Check(namespace)
+	if err != nil {
+		return topology, err
+	}
+
+	var client kubernetes.Interface
+	client, err = ps.getClient(ctx, clusterName)
+	if err != nil {
+		err = fmt.Errorf("failed to get Kubernetes client: %w", err)
+		return topology, err
+	}
+
+	builder := &topologyBuilder{nodes: make(map[string]*TopologyNode), edges: make(map[TopologyEdge]bool), required: make(map[string]bool)}
+	topology.Namespaces = splitNamespaces(namespace)
+	for _, ns := range topology.Namespaces {
+		err = ps.addNamespaceTopology(ctx, client, ns, builder)
+		if err != nil {
+			ps.logger.Error("Failed to build topology", zap.Error(err), zap.String("cluster", clusterName), zap.String("namespace", ns))
+			return topology, err
+		}
+	}
+
+	ps.resolveTopologyObjects(ctx, client, builder)
+	topology.Nodes, topology.Edges = builder.graph()
+	return topology, err
+}
+
+// addNamespaceTopology adds the running pods of a namespace with everything they depend on, and the Services
+// selecting them.
+func (ps *PodService) addNamespaceTopology(ctx context.Context, client kubernetes.Interface, namespace string, builder *topologyBuilder) (err error) {
+	var podList *corev1.PodList
+	podList, err = client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		err = fmt.Errorf("failed to list pods: %w", err)
+		return err
+	}
+
+	var serviceList *corev1.ServiceList
+	serviceList, err = client.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		err = fmt.Errorf("failed to list services: %w", err)
+		return err
+	}
+
+	pods := make([]*corev1.Pod, 0, len(podList.Items))
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		pods = append(pods, pod)
+		ps.addPodTopology(pod, builder)
+	}
+
+	for i := range serviceList.Items {
+		addServiceTopology(&serviceList.Items[i], pods, builder)
+	}
+	return err
+}
+
+// addPodTopology adds a pod, the objects it references and its node.
+func (ps *PodService) addPodTopology(pod *corev1.Pod, builder *topologyBuilder) {
+	node := TopologyNode{
+		Kind:      KindPod,
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+		Status:    ps.getPodStatus(pod),
+		Healthy:   podConditionTrue(pod, corev1.PodReady),
+	}
+	if kind, name := podOwner(pod); kind != "" {
+		node.Owner = kind + "/" + name
+	}
+	podID := builder.add(node)
+
+	for _, reference := range podReferences(pod) {
+		referenceID := builder.add(TopologyNode{Kind: reference.kind, Namespace: pod.Namespace, Name: reference.name, Healthy: true})
+		builder.link(podID, referenceID, reference.edgeType)
+		if !reference.optional {
+			builder.required[referenceID] = true
+		}
+	}
+
+	if pod.Spec.NodeName != "" {
+		nodeID := builder.add(TopologyNode{Kind: KindNode, Name: pod.Spec.NodeName, Healthy: true})
+		builder.link(podID, nodeID, TopologyEdgeRunsOn)
+	}
+}
+
+// addServiceTopology adds a Service with the pods it selects. A Service with a selector is healthy when at
+// least one of its pods is ready; one without, such as an ExternalName Service, is taken as healthy.
+func addServiceTopology(service *corev1.Service, pods []*corev1.Pod, builder *topologyBuilder) {
+	node := TopologyNode{Kind: KindService, Namespace: service.Namespace, Name: service.Name, Healthy: true}
+	if len(service.Spec.Selector) == 0 {
+		builder.add(node)
+		return
+	}
+
+	selector := labels.SelectorFromSet(service.Spec.Selector)
+	var selected []*corev1.Pod
+	ready := 0
+	for _, pod := range pods {
+		if pod.Namespace != service.Namespace || !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		selected = append(selected, pod)
+		if podConditionTrue(pod, corev1.PodReady) {
+			ready++
+		}
+	}
+
+	node.Status = fmt.Sprintf("%d/%d ready", ready, len(selected))
+	if len(selected) == 0 {
+		node.Status = "No pods"
+	}
+	node.Healthy = ready > 0
+	serviceID := builder.add(node)
+
+	for _, pod := range selected {
+		builder.link(serviceID, topologyID(KindPod, pod.Namespace, pod.Name), TopologyEdgeSelects)
+	}
+}
+
+// podReferences returns the ConfigMaps, Secrets and PersistentVolumeClaims a pod's volumes, environment and
+// image pulls use. Image pull secrets are optional, since public images pull without them.
+func podReferences(pod *corev1.Pod) (references []podReference) {
+	for _, volume := range pod.Spec.Volumes {
+		source := volume.VolumeSource
+		switch {
+		case source.ConfigMap != nil:
+			references = append(references, podReference{KindConfigMap, source.ConfigMap.Name, TopologyEdgeMounts, isOptional(source.ConfigMap.Optional)})
+		case source.Secret != nil:
+			references = append(references, podReference{KindSecret, source.Secret.SecretName, TopologyEdgeMounts, isOptional(source.Secret.Optional)})
+		case source.PersistentVolumeClaim != nil:
+			references = append(references, podReference{KindPersistentVolumeClaim, source.PersistentVolumeClaim.ClaimName, TopologyEdgeMounts, false})
+		case source.Projected != nil:
+			for _, projection := range source.Projected.Sources {
+				if projection.ConfigMap != nil {
+					references = append(references, podReference{KindConfigMap, projection.ConfigMap.Name, TopologyEdgeMounts, isOptional(projection.ConfigMap.Optional)})
+				}
+				if projection.Secret != nil {
+					references = append(references, podReference{KindSecret, projection.Secret.Name, TopologyEdgeMounts, isOptional(projection.Secret.Optional)})
+				}
+			}
+		}
+	}
+
+	containers := append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, container := range containers {
+		references = append(references, containerReferences(container)...)
+	}
+
+	for _, pullSecret := range pod.Spec.ImagePullSecrets {
+		references = append(references, podReference{KindSecret, pullSecret.Name, TopologyEdgeReferences, true})
+	}
+	return references
+}
+
+// containerReferences returns the ConfigMaps and Secrets a container's environment reads.
+func containerReferences(container corev1.Container) (references []podReference) {
+	for _, source := range container.EnvFrom {
+		if source.ConfigMapRef != nil {
+			references = append(references, podReference{KindConfigMap, source.ConfigMapRef.Name, TopologyEdgeReferences, isOptional(source.ConfigMapRef.Optional)})
+		}
+		if source.SecretRef != nil {
+			references = append(references, podReference{KindSecret, source.SecretRef.Name, TopologyEdgeReferences, isOptional(source.SecretRef.Optional)})
+		}
+	}
+
+	for _, env := range container.Env {
+		if env.ValueFrom == nil {
+			continue
+		}
+		if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil {
+			references = append(references, podReference{KindConfigMap, ref.Name, TopologyEdgeReferences, isOptional(ref.Optional)})
+		}
+		if ref := env.ValueFrom.SecretKeyRef; ref != nil {
+			references = append(references, podReference{KindSecret, ref.Name, TopologyEdgeReferences, isOptional(ref.Optional)})
+		}
+	}
+	return references
+}
+
+// isOptional dereferences an optional flag, which is false when unset.
+func isOptional(optional *bool) (isSet bool) {
+	isSet = optional != nil && *optional
+	return isSet
+}
+
+// podConditionTrue returns true if the pod has the condition with status True.
+func podConditionTrue(pod *corev1.Pod, conditionType corev1.PodConditionType) (isTrue bool) {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == conditionType {
+			isTrue = condition.Status == corev1.ConditionTrue
+			return isTrue
+		}
+	}
+	return isTrue
+}
+
+// resolveTopologyObjects reads the referenced ConfigMaps, Secrets, PersistentVolumeClaims and nodes to set
+// their status.
+func (ps *PodService) resolveTopologyObjects(ctx context.Context, client kubernetes.Interface, builder *topologyBuilder) {
+	for id, node := range builder.nodes {
+		var err error
+		switch node.Kind {
+		case KindConfigMap:
+			_, err = client.CoreV1().ConfigMaps(node.Namespace).Get(ctx, node.Name, metav1.GetOptions{})
+		case KindSecret:
+			_, err = client.CoreV1().Secrets(node.Namespace).Get(ctx, node.Name, metav1.GetOptions{})
+		case KindPersistentVolumeClaim:
+			var claim *corev1.PersistentVolumeClaim
+			claim, err = client.CoreV1().PersistentVolumeClaims(node.Namespace).Get(ctx, node.Name, metav1.GetOptions{})
+			if err == nil {
+				node.Status = string(claim.Status.Phase)
+				node.Healthy = claim.Status.Phase == corev1.ClaimBound
+			}
+		case KindNode:
+			var k8sNode *corev1.Node
+			k8sNode, err = client.CoreV1().Nodes().Get(ctx, node.Name, metav1.GetOptions{})
+			if err == nil {
+				node.Status, node.Healthy = topologyNodeStatus(k8sNode)
+			}
+		default:
+			continue
+		}
+
+		switch {
+		case apierrors.IsNotFound(err):
+			node.Status = "Missing"
+			node.Healthy = !builder.required[id]
+		case err != nil:
+			ps.logger.Debug("Failed to read topology object", zap.Error(err), zap.String("object", id))
+		}
+	}
+}
+
+// topologyNodeStatus returns Ready or NotReady from a node's Ready condition.
+func topologyNodeStatus(node *corev1.Node) (status string, healthy bool) {
+	status = "NotReady"
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady && condition.Status == corev1.ConditionTrue {
+			status, healthy = "Ready", true
+		}
+	}
+	return status, healthy
+}
+
+// topologyID identifies an object in a topology.
+func topologyID(kind, namespace, name string) (id string) {
+	id = kind + "/" + name
+	if namespace != "" {
+		id = kind + "/" + namespace + "/" + name
+	}
+	return id
+}
+
+// add adds an object unless it is already present, returning its ID.
+func (builder *topologyBuilder) add(node TopologyNode) (id string) {
+	id = topologyID(node.Kind, node.Namespace, node.Name)
+	if _, exists := builder.nodes[id]; !exists {
+		node.ID = id
+		builder.nodes[id] = &node
+	}
+	return id
+}
+
+// link adds an edge unless it is already present.
+func (builder *topologyBuilder) link(from, to, edgeType string) {
+	builder.edges[TopologyEdge{From: from, To: to, Type: edgeType}] = true
+}
+
+// graph returns the sorted objects, with the blast radius of unhealthy ones, and the sorted edges.
+func (builder *topologyBuilder) graph() (nodes []TopologyNode, edges []TopologyEdge) {
+	dependents := make(map[string][]string)
+	edges = make([]TopologyEdge, 0, len(builder.edges))
+	for edge := range builder.edges {
+		edges = append(edges, edge)
+		dependents[edge.To] = append(dependents[edge.To], edge.From)
+	}
+	sort.Slice(edges, func(i, j int) (less bool) {
+		a, b := edges[i], edges[j]
+		if a.From != b.From {
+			less = a.From < b.From
+			return less
+		}
+		if a.To != b.To {
+			less = a.To < b.To
+			return less
+		}
+		less = a.Type < b.Type
+		return less
+	})
+
+	nodes = make([]TopologyNode, 0, len(builder.nodes))
+	for id, node := range builder.nodes {
+		if !node.Healthy {
+			node.BlastRadius = blastRadius(id, dependents)
+		}
+		nodes = append(nodes, *node)
+	}
+	sort.Slice(nodes, func(i, j int) (less bool) {
+		a, b := nodes[i], nodes[j]
+		if a.Kind != b.Kind {
+			less = topologyKindOrder[a.Kind] < topologyKindOrder[b.Kind]
+			return less
+		}
+		less = a.ID < b.ID
+		return less
+	})
+	return nodes, edges
+}
+
+// blastRadius returns the sorted IDs of the objects depending on id, directly or through other objects.
+func blastRadius(id string, dependents map[string][]string) (impacted []string) {
+	seen := map[string]bool{id: true}
+	queue := []string{id}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, dependent := range dependents[current] {
+			if seen[dependent] {
+				continue
+			}
+			seen[dependent] = true
+			impacted = append(impacted, dependent)
+			queue = append(queue, dependent)
+		}
+	}
+	sort.Strings(impacted)
+	return impacted
+}
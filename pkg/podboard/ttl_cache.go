@@ -0,0 +1,110 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"sync"
+	"time"
+)
+
+// ttlCache holds values for a fixed time. Expired entries are dropped as new ones are stored, and when the
+// cache is full the oldest entry makes room.
+type ttlCache[K comparable, V any] struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[K]ttlEntry[V]
+}
+
+// ttlEntry is a cached value and when it was stored.
+type ttlEntry[V any] struct {
+	value    V
+	storedAt time.Time
+}
+
+// newTTLCache creates a cache returning values for ttl after they are stored, holding at most maxEntries, or
+// any number when zero.
+func newTTLCache[K comparable, V any](ttl time.Duration, maxEntries int) (cache *ttlCache[K, V]) {
+	cache = &ttlCache[K, V]{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[K]ttlEntry[V]),
+	}
+	return cache
+}
+
+// Get returns the value stored for key while it is fresh.
+func (cache *ttlCache[K, V]) Get(key K) (value V, ok bool) {
+	value, _, ok = cache.Load(key)
+	return value, ok
+}
+
+// Load returns the value stored for key and when it was stored, while it is fresh.
+func (cache *ttlCache[K, V]) Load(key K) (value V, storedAt time.Time, ok bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	entry, exists := cache.entries[key]
+	if !exists {
+		return value, storedAt, ok
+	}
+	if time.Since(entry.storedAt) >= cache.ttl {
+		delete(cache.entries, key)
+		return value, storedAt, ok
+	}
+
+	value, storedAt, ok = entry.value, entry.storedAt, true
+	return value, storedAt, ok
+}
+
+// Store caches value for key, dropping expired entries and, when the cache is full, the oldest one.
+func (cache *ttlCache[K, V]) Store(key K, value V) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	now := time.Now()
+	var oldestKey K
+	var oldest time.Time
+	for cachedKey, entry := range cache.entries {
+		if now.Sub(entry.storedAt) >= cache.ttl {
+			delete(cache.entries, cachedKey)
+			continue
+		}
+		if oldest.IsZero() || entry.storedAt.Before(oldest) {
+			oldestKey, oldest = cachedKey, entry.storedAt
+		}
+	}
+	if _, exists := cache.entries[key]; !exists && cache.maxEntries > 0 && len(cache.entries) >= cache.maxEntries {
+		delete(cache.entries, oldestKey)
+	}
+
+	cache.entries[key] = ttlEntry[V]{value: value, storedAt: now}
+}
+
+// Flush drops every entry.
+func (cache *ttlCache[K, V]) Flush() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.entries = make(map[K]ttlEntry[V])
+}
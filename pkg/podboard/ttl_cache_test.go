@@ -0,0 +1,81 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTTLCache returns values until they expire, and makes room for new keys by dropping the oldest.
+func TestTTLCache(t *testing.T) {
+	t.Run("expiry", func(t *testing.T) {
+		cache := newTTLCache[string, int](50*time.Millisecond, 0)
+		cache.Store("a", 1)
+
+		value, storedAt, ok := cache.Load("a")
+		assert.True(t, ok)
+		assert.Equal(t, 1, value)
+		assert.WithinDuration(t, time.Now(), storedAt, time.Second)
+
+		time.Sleep(60 * time.Millisecond)
+		_, ok = cache.Get("a")
+		assert.False(t, ok)
+	})
+
+	t.Run("max entries", func(t *testing.T) {
+		cache := newTTLCache[string, int](time.Minute, 2)
+		cache.Store("a", 1)
+		time.Sleep(time.Millisecond)
+		cache.Store("b", 2)
+		time.Sleep(time.Millisecond)
+		cache.Store("a", 3)
+		cache.Store("c", 4)
+
+		_, ok := cache.Get("b")
+		assert.False(t, ok, "the oldest entry should make room")
+		value, ok := cache.Get("a")
+		assert.True(t, ok, "replacing a value doesn't evict")
+		assert.Equal(t, 3, value)
+		_, ok = cache.Get("c")
+		assert.True(t, ok)
+	})
+
+	t.Run("expired entries dropped on store", func(t *testing.T) {
+		cache := newTTLCache[string, int](20*time.Millisecond, 0)
+		cache.Store("a", 1)
+		time.Sleep(30 * time.Millisecond)
+		cache.Store("b", 2)
+		assert.Len(t, cache.entries, 1)
+	})
+
+	t.Run("flush", func(t *testing.T) {
+		cache := newTTLCache[string, int](time.Minute, 0)
+		cache.Store("a", 1)
+		cache.Flush()
+		_, ok := cache.Get("a")
+		assert.False(t, ok)
+	})
+}
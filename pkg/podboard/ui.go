@@ -23,17 +23,52 @@ SOFTWARE.
 package podboard
 
 import (
+	"fmt"
 	"io/fs"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/nikogura/podboard/pkg/ui"
 )
 
-// SetupUIRoutes configures the UI routes for serving the embedded frontend.
-func SetupUIRoutes(router *gin.Engine) {
-	// Get the subdirectory containing the built UI files from the ui package
-	uiFS, err := fs.Sub(ui.Files, "dist")
+const (
+	// uiReloadPath is the server-sent events endpoint that tells browsers to reload when --ui-dir changes.
+	uiReloadPath = "/__podboard/reload"
+	// uiReloadInterval is how often --ui-dir is polled for changes.
+	uiReloadInterval = time.Second
+	// uiReloadScript is injected into index.html when serving from --ui-dir.
+	uiReloadScript = `<script>new EventSource("` + uiReloadPath + `").addEventListener("reload",function(){location.reload()})</script>`
+)
+
+// CheckUIDir verifies a UI override directory exists and contains an index.html.
+func CheckUIDir(uiDir string) (err error) {
+	_, err = os.Stat(filepath.Join(uiDir, "index.html"))
+	if err != nil {
+		err = fmt.Errorf("invalid UI directory %q: %w", uiDir, err)
+		return err
+	}
+	return err
+}
+
+// SetupUIRoutes configures the UI routes for serving the frontend. The embedded UI is served unless uiDir
+// names a directory of built UI files, which are then read from disk on every request, and browsers are
+// told to reload whenever a file in it changes.
+func SetupUIRoutes(router *gin.Engine, uiDir string) {
+	var uiFS fs.FS
+	var err error
+	if uiDir != "" {
+		uiFS = os.DirFS(uiDir)
+		router.GET(uiReloadPath, func(c *gin.Context) {
+			streamUIReload(c, uiDir)
+		})
+	} else {
+		// Get the subdirectory containing the built UI files from the ui package
+		uiFS, err = fs.Sub(ui.Files, "dist")
+	}
 	if err != nil {
 		// If UI files don't exist (development), serve a placeholder
 		router.GET("/", func(c *gin.Context) {
@@ -66,13 +101,13 @@ func SetupUIRoutes(router *gin.Engine) {
 
 		// Skip API routes
 		if len(path) >= 4 && path[:4] == "/api" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "API endpoint not found"})
+			respondErrorCode(c, http.StatusNotFound, "API endpoint not found")
 			return
 		}
 
-		// Skip health check
-		if path == "/health" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		// Skip health and readiness checks
+		if path == "/health" || path == "/ready" {
+			respondErrorCode(c, http.StatusNotFound, "Not found")
 			return
 		}
 
@@ -90,8 +125,68 @@ func SetupUIRoutes(router *gin.Engine) {
 			return
 		}
 
+		html := string(indexContent)
+		if uiDir != "" {
+			html = injectUIReloadScript(html)
+		}
+
 		c.Header("Content-Type", "text/html; charset=utf-8")
 		c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
-		c.String(http.StatusOK, string(indexContent))
+		c.String(http.StatusOK, html)
+	})
+}
+
+// injectUIReloadScript adds the live reload script to an HTML page, before </body> when present.
+func injectUIReloadScript(html string) (injected string) {
+	i := strings.LastIndex(html, "</body>")
+	if i < 0 {
+		injected = html + uiReloadScript
+		return injected
+	}
+	injected = html[:i] + uiReloadScript + html[i:]
+	return injected
+}
+
+// streamUIReload sends a reload event to the browser whenever a file under uiDir changes.
+func streamUIReload(c *gin.Context, uiDir string) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	version := uiDirVersion(uiDir)
+	ticker := time.NewTicker(uiReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-ticker.C:
+			current := uiDirVersion(uiDir)
+			if current.Equal(version) {
+				continue
+			}
+			version = current
+			c.SSEvent("reload", version.UnixMilli())
+			c.Writer.Flush()
+		}
+	}
+}
+
+// uiDirVersion returns the most recent modification time of any file under dir.
+func uiDirVersion(dir string) (latest time.Time) {
+	_ = filepath.WalkDir(dir, func(_ string, entry fs.DirEntry, err error) (walkErr error) {
+		// Files may disappear mid-build, so unreadable entries are skipped rather than failing the walk.
+		if err != nil {
+			return walkErr
+		}
+		info, infoErr := entry.Info()
+		if infoErr == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		return walkErr
 	})
+	return latest
 }
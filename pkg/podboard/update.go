@@ -0,0 +1,443 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultReleasesURL is the GitHub API endpoint for the latest podboard release.
+	DefaultReleasesURL = "https://api.github.com/repos/nikogura/podboard/releases/latest"
+	checksumsAsset     = "checksums.txt"
+	updateHTTPTimeout  = 5 * time.Minute
+)
+
+// gitDescribeSuffix matches what git describe appends to a tag for later commits and uncommitted changes.
+//
+//nolint:gochecknoglobals // Compiled once
+var gitDescribeSuffix = regexp.MustCompile(`^(\d+-g[0-9a-f]+)?(-?dirty)?$`)
+
+// ErrChecksumMismatch is returned when a downloaded release asset does not match its published checksum.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ErrAssetNotFound is returned when a release has no asset for this platform.
+var ErrAssetNotFound = errors.New("release asset not found")
+
+// ErrUnsignedRelease is returned when a release binary's signature can't be checked, for want of a public key
+// or a signature, and unsigned releases weren't explicitly allowed.
+var ErrUnsignedRelease = errors.New("release signature can't be verified")
+
+// ReleaseInfo describes the release asset podboard would update to.
+type ReleaseInfo struct {
+	Version      string `json:"version"`
+	AssetName    string `json:"assetName"`
+	AssetURL     string `json:"assetURL"`
+	ChecksumsURL string `json:"checksumsURL"`
+	SignatureURL string `json:"signatureURL,omitempty"`
+}
+
+// githubRelease mirrors the subset of the GitHub release API used by the updater.
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name               string `json:"name"`
+		BrowserDownloadURL string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// Updater checks GitHub releases for a newer podboard and replaces the running binary.
+type Updater struct {
+	config        ServerConfig
+	publicKey     string
+	skipSignature bool
+	releasesURL   string
+	httpClient    *http.Client
+	logger        *zap.Logger
+}
+
+// NewUpdater creates a new Updater using the public GitHub releases of podboard. Release binaries must carry a
+// valid minisign signature from publicKey. The checksums come from the same release, so they don't show who
+// published it; only skipSignature installs binaries without a key or signature, with a warning.
+func NewUpdater(config ServerConfig, publicKey string, skipSignature bool, logger *zap.Logger) (updater *Updater) {
+	updater = &Updater{
+		config:        config,
+		publicKey:     publicKey,
+		skipSignature: skipSignature,
+		releasesURL:   DefaultReleasesURL,
+		httpClient:    &http.Client{Timeout: updateHTTPTimeout},
+		logger:        logger,
+	}
+	return updater
+}
+
+// ReleaseAssetName returns the release binary name for the running platform, matching install.sh and CI.
+func ReleaseAssetName() (name string) {
+	name = fmt.Sprintf("podboard-%s-%s", runtime.GOOS, runtime.GOARCH)
+	if FIPSBuild() {
+		name += "-fips"
+	}
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// LatestRelease looks up the latest release and the asset for the running platform.
+func (u *Updater) LatestRelease(ctx context.Context) (release ReleaseInfo, err error) {
+	err = u.config.CheckOutbound("update")
+	if err != nil {
+		return release, err
+	}
+
+	var body []byte
+	body, err = u.get(ctx, u.releasesURL)
+	if err != nil {
+		err = fmt.Errorf("failed to query latest release: %w", err)
+		return release, err
+	}
+
+	var latest githubRelease
+	err = json.Unmarshal(body, &latest)
+	if err != nil {
+		err = fmt.Errorf("failed to parse latest release: %w", err)
+		return release, err
+	}
+
+	release = ReleaseInfo{Version: latest.TagName, AssetName: ReleaseAssetName()}
+	for _, asset := range latest.Assets {
+		switch asset.Name {
+		case release.AssetName:
+			release.AssetURL = asset.BrowserDownloadURL
+		case checksumsAsset:
+			release.ChecksumsURL = asset.BrowserDownloadURL
+		case release.AssetName + SignatureSuffix:
+			release.SignatureURL = asset.BrowserDownloadURL
+		}
+	}
+
+	if release.AssetURL == "" {
+		err = fmt.Errorf("%w: %s in release %s", ErrAssetNotFound, release.AssetName, release.Version)
+		return release, err
+	}
+	if release.ChecksumsURL == "" {
+		err = fmt.Errorf("%w: %s in release %s", ErrAssetNotFound, checksumsAsset, release.Version)
+		return release, err
+	}
+
+	return release, err
+}
+
+// Apply downloads the release asset, verifies it against the published checksums and its minisign signature,
+// then replaces the binary at path.
+func (u *Updater) Apply(ctx context.Context, release ReleaseInfo, path string) (err error) {
+	err = u.config.CheckOutbound("update")
+	if err != nil {
+		return err
+	}
+
+	var checksums []byte
+	checksums, err = u.get(ctx, release.ChecksumsURL)
+	if err != nil {
+		err = fmt.Errorf("failed to download checksums: %w", err)
+		return err
+	}
+
+	var binary []byte
+	binary, err = u.get(ctx, release.AssetURL)
+	if err != nil {
+		err = fmt.Errorf("failed to download %s: %w", release.AssetName, err)
+		return err
+	}
+
+	sum := sha256.Sum256(binary)
+	actual := hex.EncodeToString(sum[:])
+	err = VerifyChecksum(checksums, release.AssetName, actual)
+	if err != nil {
+		return err
+	}
+	u.logger.Debug("Verified release checksum", zap.String("asset", release.AssetName), zap.String("sha256", actual))
+
+	err = u.verifySignature(ctx, release, binary)
+	if err != nil {
+		return err
+	}
+
+	err = replaceBinary(path, binary)
+	return err
+}
+
+// verifySignature checks the minisign signature of a release binary. Without a public key or a signature it
+// fails with ErrUnsignedRelease, unless the updater skips signatures, when it only logs a warning.
+func (u *Updater) verifySignature(ctx context.Context, release ReleaseInfo, binary []byte) (err error) {
+	switch {
+	case u.publicKey == "":
+		err = fmt.Errorf("%w: no release public key, use --public-key or PODBOARD_RELEASE_PUBLIC_KEY", ErrUnsignedRelease)
+	case release.SignatureURL == "":
+		err = fmt.Errorf("%w: no %s%s in release %s", ErrUnsignedRelease, release.AssetName, SignatureSuffix, release.Version)
+	}
+	if err != nil && u.skipSignature {
+		u.logger.Warn("Installing release without verifying its signature", zap.String("asset", release.AssetName), zap.Error(err))
+		err = nil
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	var key MinisignPublicKey
+	key, err = ParseMinisignPublicKey(u.publicKey)
+	if err != nil {
+		return err
+	}
+
+	var signature []byte
+	signature, err = u.get(ctx, release.SignatureURL)
+	if err != nil {
+		err = fmt.Errorf("failed to download signature: %w", err)
+		return err
+	}
+
+	var trustedComment string
+	trustedComment, err = VerifyMinisign(key, binary, signature)
+	if err != nil {
+		err = fmt.Errorf("%s: %w", release.AssetName, err)
+		return err
+	}
+
+	u.logger.Debug("Verified release signature", zap.String("asset", release.AssetName), zap.String("trustedComment", trustedComment))
+	return err
+}
+
+// get fetches a URL and returns the response body.
+func (u *Updater) get(ctx context.Context, url string) (body []byte, err error) {
+	var request *http.Request
+	request, err = http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return body, err
+	}
+	request.Header.Set("User-Agent", "podboard/"+Version)
+
+	var response *http.Response
+	response, err = u.httpClient.Do(request)
+	if err != nil {
+		return body, err
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	if response.StatusCode != http.StatusOK {
+		err = fmt.Errorf("GET %s: %s", url, response.Status)
+		return body, err
+	}
+
+	body, err = io.ReadAll(response.Body)
+	return body, err
+}
+
+// VerifyChecksum checks a sha256 hex digest against the entry for name in sha256sum formatted checksums.
+func VerifyChecksum(checksums []byte, name, actual string) (err error) {
+	var expected string
+	expected, err = findChecksum(checksums, name)
+	if err != nil {
+		return err
+	}
+
+	if actual != expected {
+		err = fmt.Errorf("%w for %s: expected %s, got %s", ErrChecksumMismatch, name, expected, actual)
+		return err
+	}
+	return err
+}
+
+// findChecksum returns the sha256 for the named file from sha256sum formatted output.
+func findChecksum(checksums []byte, name string) (checksum string, err error) {
+	scanner := bufio.NewScanner(strings.NewReader(string(checksums)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			checksum = strings.ToLower(fields[0])
+			return checksum, err
+		}
+	}
+
+	err = fmt.Errorf("%w: no checksum for %s", ErrAssetNotFound, name)
+	return checksum, err
+}
+
+// replaceBinary atomically replaces the file at path with the given contents.
+// The running binary is moved aside first, since Windows cannot overwrite an executable in use.
+func replaceBinary(path string, binary []byte) (err error) {
+	dir := filepath.Dir(path)
+
+	var tmp *os.File
+	tmp, err = os.CreateTemp(dir, ".podboard-update-*")
+	if err != nil {
+		err = fmt.Errorf("failed to create temporary file in %s: %w", dir, err)
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer func() { _ = os.Remove(tmpPath) }()
+
+	_, err = tmp.Write(binary)
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to write %s: %w", tmpPath, err)
+		return err
+	}
+
+	err = os.Chmod(tmpPath, 0o755) //nolint:gosec // Executables must be world readable and executable
+	if err != nil {
+		return err
+	}
+
+	oldPath := path + ".old"
+	_ = os.Remove(oldPath)
+	err = os.Rename(path, oldPath)
+	if err != nil {
+		err = fmt.Errorf("failed to move aside %s: %w", path, err)
+		return err
+	}
+
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		_ = os.Rename(oldPath, path)
+		err = fmt.Errorf("failed to install %s: %w", path, err)
+		return err
+	}
+
+	_ = os.Remove(oldPath)
+	return err
+}
+
+// NewerVersion reports whether latest is a newer release than current. A pre-release such as v1.2.0-rc.1 is
+// older than v1.2.0, and pre-releases of the same version are ordered by their identifiers as in semver.
+// Development builds and unparseable versions are always considered out of date.
+func NewerVersion(current, latest string) (newer bool) {
+	currentParts, currentPre, currentOK := parseVersion(current)
+	latestParts, latestPre, latestOK := parseVersion(latest)
+	if !latestOK {
+		return newer
+	}
+	if !currentOK {
+		newer = true
+		return newer
+	}
+
+	for i := range latestParts {
+		if latestParts[i] != currentParts[i] {
+			newer = latestParts[i] > currentParts[i]
+			return newer
+		}
+	}
+	newer = comparePrerelease(latestPre, currentPre) > 0
+	return newer
+}
+
+// parseVersion parses a vMAJOR.MINOR.PATCH version and its pre-release, ignoring any build suffix. The
+// commits-since-tag suffix git describe adds to local builds, such as -4-gabcdef, is a build suffix too.
+func parseVersion(version string) (parts [3]int, prerelease string, ok bool) {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.Index(version, "+"); i >= 0 {
+		version = version[:i]
+	}
+	if i := strings.Index(version, "-"); i >= 0 {
+		version, prerelease = version[:i], version[i+1:]
+	}
+	if gitDescribeSuffix.MatchString(prerelease) {
+		prerelease = ""
+	}
+
+	fields := strings.Split(version, ".")
+	if len(fields) != len(parts) {
+		return parts, prerelease, ok
+	}
+
+	for i, field := range fields {
+		number, err := strconv.Atoi(field)
+		if err != nil {
+			return parts, prerelease, ok
+		}
+		parts[i] = number
+	}
+
+	ok = true
+	return parts, prerelease, ok
+}
+
+// comparePrerelease orders two pre-releases of the same version as semver does, returning a negative number,
+// zero or a positive number when a is older than, the same as or newer than b. No pre-release is the newest.
+// Dot-separated identifiers are compared in turn, numbers numerically and below words, which compare as text.
+func comparePrerelease(a, b string) (order int) {
+	switch {
+	case a == b:
+		return order
+	case a == "":
+		order = 1
+		return order
+	case b == "":
+		order = -1
+		return order
+	}
+
+	aFields := strings.Split(a, ".")
+	bFields := strings.Split(b, ".")
+	for i := range min(len(aFields), len(bFields)) {
+		aNumber, aErr := strconv.Atoi(aFields[i])
+		bNumber, bErr := strconv.Atoi(bFields[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			order = aNumber - bNumber
+		case aErr == nil:
+			order = -1
+		case bErr == nil:
+			order = 1
+		default:
+			order = strings.Compare(aFields[i], bFields[i])
+		}
+		if order != 0 {
+			return order
+		}
+	}
+	order = len(aFields) - len(bFields)
+	return order
+}
@@ -0,0 +1,198 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package podboard
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// TestNewerVersion compares release versions, with or without a v prefix, ordering pre-releases as semver does.
+func TestNewerVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		latest  string
+		newer   bool
+	}{
+		{"same version", "v1.2.3", "v1.2.3", false},
+		{"patch release", "v1.2.3", "v1.2.4", true},
+		{"minor release", "v1.2.3", "v1.3.0", true},
+		{"major release", "v1.9.9", "v2.0.0", true},
+		{"older release", "v1.3.0", "v1.2.9", false},
+		{"numbers not text", "v1.9.0", "v1.10.0", true},
+		{"without v prefix", "1.2.3", "v1.2.4", true},
+		{"latest without v prefix", "v1.2.3", "1.2.3", false},
+		{"release after its pre-release", "v1.2.0-rc.1", "v1.2.0", true},
+		{"pre-release before its release", "v1.2.0", "v1.2.0-rc.1", false},
+		{"pre-release of next version", "v1.2.0", "v1.3.0-rc.1", true},
+		{"later pre-release", "v1.2.0-rc.1", "v1.2.0-rc.2", true},
+		{"numeric pre-release identifiers", "v1.2.0-rc.2", "v1.2.0-rc.10", true},
+		{"beta before rc", "v1.2.0-rc.1", "v1.2.0-beta.2", false},
+		{"longer pre-release", "v1.2.0-alpha", "v1.2.0-alpha.1", true},
+		{"build metadata ignored", "v1.2.3+abc", "v1.2.3+def", false},
+		{"development build", "dev", "v0.0.1", true},
+		{"local build after a release", "v1.2.3-4-gabcdef", "v1.2.3", false},
+		{"local build with changes", "v1.2.3-4-gabcdef-dirty", "v1.2.4", true},
+		{"unparseable latest", "v1.2.3", "nightly", false},
+		{"empty latest", "v1.2.3", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.newer, NewerVersion(tt.current, tt.latest))
+		})
+	}
+}
+
+// TestVerifyChecksum checks digests against sha256sum output, in text and binary mode.
+func TestVerifyChecksum(t *testing.T) {
+	checksums := []byte("ABC123  podboard-linux-amd64\n" +
+		"def456 *podboard-windows-amd64.exe\n" +
+		"malformed line\n")
+
+	tests := []struct {
+		name   string
+		asset  string
+		actual string
+		err    error
+	}{
+		{"match, case insensitive", "podboard-linux-amd64", "abc123", nil},
+		{"binary mode entry", "podboard-windows-amd64.exe", "def456", nil},
+		{"mismatch", "podboard-linux-amd64", "def456", ErrChecksumMismatch},
+		{"missing entry", "podboard-darwin-arm64", "abc123", ErrAssetNotFound},
+		{"prefix of an entry", "podboard-linux", "abc123", ErrAssetNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyChecksum(checksums, tt.asset, tt.actual)
+			if tt.err == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+// testMinisignKey returns a new minisign key pair, the public key encoded as in a .pub file.
+func testMinisignKey(t *testing.T) (publicKey string, privateKey ed25519.PrivateKey) {
+	t.Helper()
+	public, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	keyID := []byte("podboard")
+	publicKey = base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyID...), public...))
+	return publicKey, privateKey
+}
+
+// testMinisign returns a legacy minisign signature of message.
+func testMinisign(privateKey ed25519.PrivateKey, message []byte) (signature []byte) {
+	signed := ed25519.Sign(privateKey, message)
+	trustedComment := "timestamp:0\tfile:podboard"
+	global := ed25519.Sign(privateKey, append(append([]byte{}, signed...), trustedComment...))
+	signature = []byte("untrusted comment: signature from podboard test key\n" +
+		base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), "podboard"...), signed...)) + "\n" +
+		minisignTrustedComment + trustedComment + "\n" +
+		base64.StdEncoding.EncodeToString(global) + "\n")
+	return signature
+}
+
+// TestUpdaterApply installs only releases whose signature verifies, unless signatures are explicitly skipped.
+func TestUpdaterApply(t *testing.T) {
+	publicKey, privateKey := testMinisignKey(t)
+	otherKey, _ := testMinisignKey(t)
+	binary := []byte("new podboard")
+	sum := sha256.Sum256(binary)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/asset":
+			_, _ = w.Write(binary)
+		case "/checksums":
+			_, _ = w.Write([]byte(hex.EncodeToString(sum[:]) + "  " + ReleaseAssetName() + "\n"))
+		case "/asset.minisig":
+			_, _ = w.Write(testMinisign(privateKey, binary))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	signed := ReleaseInfo{
+		Version:      "v9.9.9",
+		AssetName:    ReleaseAssetName(),
+		AssetURL:     server.URL + "/asset",
+		ChecksumsURL: server.URL + "/checksums",
+		SignatureURL: server.URL + "/asset.minisig",
+	}
+	unsigned := signed
+	unsigned.SignatureURL = ""
+
+	tests := []struct {
+		name          string
+		publicKey     string
+		skipSignature bool
+		release       ReleaseInfo
+		err           error
+	}{
+		{"signed with the key", publicKey, false, signed, nil},
+		{"signed with another key", otherKey, false, signed, ErrInvalidSignature},
+		{"signed with another key, skipping signatures", otherKey, true, signed, ErrInvalidSignature},
+		{"no public key", "", false, signed, ErrUnsignedRelease},
+		{"no signature", publicKey, false, unsigned, ErrUnsignedRelease},
+		{"no public key, skipping signatures", "", true, signed, nil},
+		{"no signature, skipping signatures", publicKey, true, unsigned, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "podboard")
+			require.NoError(t, os.WriteFile(path, []byte("old podboard"), 0o600))
+
+			updater := NewUpdater(ServerConfig{}, tt.publicKey, tt.skipSignature, zap.NewNop())
+			err := updater.Apply(t.Context(), tt.release, path)
+
+			installed, readErr := os.ReadFile(path)
+			require.NoError(t, readErr)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				assert.Equal(t, "old podboard", string(installed))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, string(binary), string(installed))
+		})
+	}
+}
@@ -4,20 +4,36 @@ import React, { useState, useEffect, useCallback } from 'react';
 
 import { SimpleLayout } from '@/components/SimpleLayout';
 import { api, ApiError } from '@/lib/api';
-import type { PodInfo, ClusterInfo } from '@/types';
+import type { PodInfo, ClusterInfo, ContextInfo, ErrorResponse, ActionConfig, NodeDetail, EvictionBlocked, PendingAction, EvictionSummary, NamespaceAlerts } from '@/types';
 
 export default function HomePage(): React.ReactElement {
   const [pods, setPods] = useState<PodInfo[]>([]);
   const [namespaces, setNamespaces] = useState<string[]>([]);
   const [clusters, setClusters] = useState<ClusterInfo[]>([]);
   const [selectedCluster, setSelectedCluster] = useState<string>('');
+  const [contexts, setContexts] = useState<ContextInfo[]>([]);
+  // Empty means podboard picks the credentials for the cluster
+  const [selectedContext, setSelectedContext] = useState<string>('');
   const [selectedNamespace, setSelectedNamespace] = useState<string>('default');
   const [selectedLabelFilter, setSelectedLabelFilter] = useState<string>('');
+  const [selectedNameFilter, setSelectedNameFilter] = useState<string>('');
   const [refreshInterval, setRefreshInterval] = useState<number>(2);
   const [lastUpdate, setLastUpdate] = useState<Date | null>(null);
   const [loading, setLoading] = useState(true);
   const [inCluster, setInCluster] = useState<boolean>(false);
   const [error, setError] = useState<string | null>(null);
+  const [clusterDegraded, setClusterDegraded] = useState<boolean>(false);
+  const [podActions, setPodActions] = useState<ActionConfig[]>([]);
+  const [ticketBackend, setTicketBackend] = useState<string>('');
+  const [clusterWarning, setClusterWarning] = useState<string | null>(null);
+  const [selectedNode, setSelectedNode] = useState<NodeDetail | null>(null);
+  // The latest delete held back by the server's undo window, while it can still be undone
+  const [pendingDelete, setPendingDelete] = useState<PendingAction | null>(null);
+  // Evicted and shut down pods, kept out of the table and summarized by reason
+  const [evicted, setEvicted] = useState<EvictionSummary | null>(null);
+  const [alertmanagerEnabled, setAlertmanagerEnabled] = useState<boolean>(false);
+  // Alerts and silences for the selected namespace, when Alertmanager is configured
+  const [namespaceAlerts, setNamespaceAlerts] = useState<NamespaceAlerts | null>(null);
 
   // Fetch clusters and initialize on mount
   useEffect(() => {
@@ -29,6 +45,7 @@ export default function HomePage(): React.ReactElement {
 
         if (!clustersResponse.inCluster) {
           setClusters(clustersResponse.clusters);
+          setContexts(clustersResponse.contexts || []);
           // Set initial cluster to current cluster or first available
           const currentCluster = clustersResponse.clusters.find(c => c.current);
           if (currentCluster) {
@@ -51,13 +68,26 @@ export default function HomePage(): React.ReactElement {
     initializeApp();
   }, []);
 
+  // Fetch custom actions from the server config
+  useEffect(() => {
+    api.getConfig()
+      .then(config => {
+        setPodActions((config.actions || []).filter(action => action.kind === 'pod'));
+        setTicketBackend(config.tickets || '');
+        setAlertmanagerEnabled(config.alertmanager ?? false);
+      })
+      .catch(err => console.error('Failed to fetch config:', err));
+  }, []);
+
   // Fetch namespaces when cluster changes
   useEffect(() => {
     if (loading) {return;}
 
     const fetchNamespaces = async (): Promise<void> => {
       try {
-        const response = await api.getNamespaces(selectedCluster || undefined);
+        // With all clusters selected, namespaces come from the current cluster
+        const namespaceCluster = selectedCluster === 'all' ? undefined : selectedCluster;
+        const response = await api.getNamespaces(namespaceCluster || undefined);
         // Add "all" as the first option
         const namespacesWithAll = ['all', ...response.namespaces];
         setNamespaces(namespacesWithAll);
@@ -96,7 +126,7 @@ export default function HomePage(): React.ReactElement {
     };
 
     fetchNamespaces();
-  }, [selectedCluster, loading, namespaces.length]);
+  }, [selectedCluster, selectedContext, loading, namespaces.length]);
 
   // Fetch pods periodically
   const fetchPods = useCallback(async () => {
@@ -104,16 +134,51 @@ export default function HomePage(): React.ReactElement {
       const response = await api.getPods(
         selectedNamespace,
         selectedLabelFilter || undefined,
-        selectedCluster || undefined
+        selectedCluster || undefined,
+        selectedNameFilter || undefined
       );
       setPods(response.pods);
-      setLastUpdate(new Date());
+      setEvicted(response.evicted && response.evicted.total > 0 ? response.evicted : null);
+      // With all clusters selected, unreachable clusters are reported alongside the merged list
+      const failedClusters = Object.keys(response.clusterErrors || {});
+      setClusterWarning(failedClusters.length > 0 ? `Could not list pods in: ${failedClusters.join(', ')}` : null);
+      // Stale responses carry the time the data was actually fetched
+      setLastUpdate(response.stale && response.cachedAt ? new Date(response.cachedAt) : new Date());
       setError(null);
+      setClusterDegraded((response.clusterDegraded ?? false) || (response.stale ?? false));
     } catch (err) {
       console.error('Failed to fetch pods:', err);
-      setError('Failed to fetch pods');
+      // While the cluster is degraded keep showing the last good data instead of an error banner
+      const degraded = err instanceof ApiError && ((err.response as ErrorResponse | undefined)?.clusterDegraded ?? false);
+      setClusterDegraded(degraded);
+      if (!degraded) {
+        setError('Failed to fetch pods');
+      }
     }
-  }, [selectedNamespace, selectedLabelFilter, selectedCluster]);
+  }, [selectedNamespace, selectedLabelFilter, selectedNameFilter, selectedCluster, selectedContext]);
+
+  // Alerts are best effort: an unreachable Alertmanager only hides the panel
+  const fetchAlerts = useCallback(async () => {
+    if (!alertmanagerEnabled || selectedCluster === 'all') {
+      setNamespaceAlerts(null);
+      return;
+    }
+    try {
+      setNamespaceAlerts(await api.getAlerts(selectedNamespace, selectedCluster || undefined));
+    } catch (err) {
+      console.error('Failed to fetch alerts:', err);
+      setNamespaceAlerts(null);
+    }
+  }, [alertmanagerEnabled, selectedNamespace, selectedCluster, selectedContext]);
+
+  useEffect(() => {
+    if (loading) {return;}
+
+    fetchAlerts();
+    // Alerts change slower than pods, so they are polled at most every 30 seconds
+    const interval = setInterval(fetchAlerts, Math.max(refreshInterval, 30) * 1000);
+    return (): void => clearInterval(interval);
+  }, [loading, refreshInterval, fetchAlerts]);
 
   // Initial pod fetch and interval setup
   useEffect(() => {
@@ -122,7 +187,7 @@ export default function HomePage(): React.ReactElement {
     fetchPods();
     const interval = setInterval(fetchPods, refreshInterval * 1000);
     return (): void => clearInterval(interval);
-  }, [selectedCluster, selectedNamespace, selectedLabelFilter, refreshInterval, loading, fetchPods]);
+  }, [selectedCluster, selectedContext, selectedNamespace, selectedLabelFilter, selectedNameFilter, refreshInterval, loading, fetchPods]);
 
   if (loading) {
     return (
@@ -160,13 +225,34 @@ export default function HomePage(): React.ReactElement {
     return '#6c757d';
   };
 
+  // Only show resource columns when metrics-server is reporting usage
+  const hasUsage = (pods || []).some(pod => pod.usage);
+  const hasAppMetric = (pods || []).some(pod => pod.appMetric);
+
   const handleDeletePod = async (pod: PodInfo): Promise<void> => {
-    if (!confirm(`Are you sure you want to delete pod ${pod.name}?`)) {
+    // Pods stuck in Terminating are force deleted, which needs the pod to be named in full
+    const force = pod.status === 'Terminating';
+    if (force) {
+      const typed = prompt(`Force delete pod ${pod.name}? Its containers may keep running if its node is unreachable. Type ${pod.namespace}/${pod.name} to confirm:`);
+      if (typed !== `${pod.namespace}/${pod.name}`) {
+        return;
+      }
+    } else if (!confirm(`Are you sure you want to delete pod ${pod.name}?`)) {
       return;
     }
 
     try {
-      await api.deletePod(pod.namespace, pod.name, selectedCluster || undefined);
+      const result = await api.deletePod(pod.namespace, pod.name, pod.cluster || selectedCluster || undefined, force);
+      const pendingAction = result.pendingAction;
+      if (pendingAction) {
+        setPendingDelete(pendingAction);
+        // Drop the undo banner once the window has passed and show the deletion
+        setTimeout(() => {
+          setPendingDelete(current => (current?.id === pendingAction.id ? null : current));
+          fetchPods();
+        }, Math.max(new Date(pendingAction.runAt).getTime() - Date.now(), 0) + 1500);
+        return;
+      }
       // Refresh pods list immediately
       fetchPods();
     } catch (err) {
@@ -179,6 +265,171 @@ export default function HomePage(): React.ReactElement {
     }
   };
 
+  const handleUndoDelete = async (action: PendingAction): Promise<void> => {
+    try {
+      const result = await api.cancelPendingAction(action.id);
+      setPendingDelete(null);
+      if (result.pendingAction.state !== 'Cancelled') {
+        alert(`Too late to undo: pod ${action.name} is already ${result.pendingAction.state.toLowerCase()}`);
+        fetchPods();
+      }
+    } catch (err) {
+      console.error('Failed to undo delete:', err);
+      alert(`Failed to undo delete: ${err instanceof ApiError ? err.message : 'unknown error'}`);
+    }
+  };
+
+  const canDeleteMatching = selectedLabelFilter !== '' && selectedNamespace !== 'all' && selectedCluster !== 'all';
+
+  const handleDeleteMatching = async (): Promise<void> => {
+    const cluster = selectedCluster || undefined;
+    try {
+      const preview = await api.deletePods(selectedNamespace, selectedLabelFilter, true, cluster);
+      if (preview.pods.length === 0) {
+        alert(`No pods match ${selectedLabelFilter}`);
+        return;
+      }
+      const names = preview.pods.map(pod => pod.name).join('\n');
+      if (!confirm(`Delete these ${preview.pods.length} pods in ${selectedNamespace}?\n\n${names}`)) {
+        return;
+      }
+
+      const result = await api.deletePods(selectedNamespace, selectedLabelFilter, false, cluster);
+      const failed = Object.entries(result.failed || {});
+      if (failed.length > 0) {
+        alert(`Failed to delete ${failed.length} pods:\n${failed.map(([name, reason]) => `${name}: ${reason}`).join('\n')}`);
+      }
+      fetchPods();
+    } catch (err) {
+      console.error('Failed to delete pods:', err);
+      if (err instanceof ApiError) {
+        alert(`Failed to delete pods: ${err.message}`);
+      } else {
+        alert('Failed to delete pods');
+      }
+    }
+  };
+
+  const handleCleanupEvicted = async (summary: EvictionSummary): Promise<void> => {
+    const reasons = Array.from(new Set(summary.groups.map(group => group.reason)));
+    if (!confirm(`Delete the ${summary.total} evicted pods in ${selectedNamespace}?`)) {
+      return;
+    }
+    try {
+      // The server won't clean up pods that failed less than a minute ago
+      const result = await api.cleanupPods(selectedNamespace, '1m', ['Failed'], reasons, selectedCluster || undefined);
+      const failed = Object.entries(result.failed || {});
+      if (failed.length > 0) {
+        alert(`Failed to delete ${failed.length} pods:\n${failed.map(([name, reason]) => `${name}: ${reason}`).join('\n')}`);
+      }
+      fetchPods();
+    } catch (err) {
+      console.error('Failed to clean up evicted pods:', err);
+      alert(`Failed to clean up evicted pods: ${err instanceof ApiError ? err.message : 'unknown error'}`);
+    }
+  };
+
+  const handleEvictPod = async (pod: PodInfo): Promise<void> => {
+    if (!confirm(`Evict pod ${pod.name}? Disruption budgets are respected.`)) {
+      return;
+    }
+
+    try {
+      await api.evictPod(pod.namespace, pod.name, pod.cluster || selectedCluster || undefined);
+      fetchPods();
+    } catch (err) {
+      console.error('Failed to evict pod:', err);
+      if (err instanceof ApiError && err.status === 429) {
+        const blocked = (err.response as { blocked?: EvictionBlocked } | undefined)?.blocked;
+        const budgets = (blocked?.disruptionBudgets || [])
+          .map(budget => `${budget.name}: ${budget.currentHealthy}/${budget.desiredHealthy} healthy, ${budget.disruptionsAllowed} disruptions allowed`);
+        alert([`Eviction of ${pod.name} blocked: ${blocked?.message || err.message}`, ...(blocked?.causes || []), ...budgets].join('\n'));
+      } else if (err instanceof ApiError) {
+        alert(`Failed to evict pod: ${err.message}`);
+      } else {
+        alert('Failed to evict pod');
+      }
+    }
+  };
+
+  const handlePodAction = async (pod: PodInfo, action: ActionConfig): Promise<void> => {
+    if (action.confirm && !confirm(action.confirm)) {
+      return;
+    }
+
+    try {
+      const { result } = await api.runPodAction(pod.namespace, pod.name, action.name, pod.cluster || selectedCluster || undefined);
+      if (action.method === 'link') {
+        if (result.url) {window.open(result.url, '_blank', 'noopener');}
+        return;
+      }
+      if (result.status && result.status >= 400) {
+        alert(`${action.name} failed with HTTP ${result.status}${result.body ? `: ${result.body}` : ''}`);
+      }
+    } catch (err) {
+      console.error(`Failed to run action ${action.name}:`, err);
+      if (err instanceof ApiError) {
+        alert(`${action.name} failed: ${err.message}`);
+      } else {
+        alert(`${action.name} failed`);
+      }
+    }
+  };
+
+  const handleCreateTicket = async (pod: PodInfo): Promise<void> => {
+    const note = prompt(`Create a ${ticketBackend} ticket for pod ${pod.name}? Optional note:`);
+    if (note === null) {
+      return;
+    }
+
+    try {
+      const { ticket } = await api.createPodTicket(pod.namespace, pod.name, note, pod.cluster || selectedCluster || undefined);
+      window.open(ticket.url, '_blank', 'noopener');
+    } catch (err) {
+      console.error('Failed to create ticket:', err);
+      if (err instanceof ApiError) {
+        alert(`Failed to create ticket: ${err.message}`);
+      } else {
+        alert('Failed to create ticket');
+      }
+    }
+  };
+
+  const silenceableKinds = ['Deployment', 'StatefulSet', 'DaemonSet'];
+
+  const handleSilenceWorkload = async (pod: PodInfo): Promise<void> => {
+    if (!pod.ownerKind || !pod.ownerName) {
+      return;
+    }
+    const comment = prompt(`Silence alerts for ${pod.ownerKind} ${pod.namespace}/${pod.ownerName} for 60 minutes? Reason:`);
+    if (comment === null) {
+      return;
+    }
+
+    try {
+      const { silence } = await api.silenceWorkload(pod.ownerKind, pod.namespace, pod.ownerName, 60, comment, pod.cluster || selectedCluster || undefined);
+      alert(`Silenced until ${new Date(silence.endsAt).toLocaleTimeString()}`);
+      fetchAlerts();
+    } catch (err) {
+      console.error('Failed to create silence:', err);
+      alert(`Failed to create silence: ${err instanceof ApiError ? err.message : 'unknown error'}`);
+    }
+  };
+
+  const handleShowNode = async (pod: PodInfo): Promise<void> => {
+    try {
+      const { node } = await api.getNode(pod.node, pod.cluster || selectedCluster || undefined);
+      setSelectedNode(node);
+    } catch (err) {
+      console.error(`Failed to load node ${pod.node}:`, err);
+      if (err instanceof ApiError) {
+        alert(`Failed to load node ${pod.node}: ${err.message}`);
+      } else {
+        alert(`Failed to load node ${pod.node}`);
+      }
+    }
+  };
+
   return (
     <SimpleLayout
       environment={selectedNamespace}
@@ -197,7 +448,11 @@ export default function HomePage(): React.ReactElement {
             <label style={{ marginRight: "0.5rem", fontSize: "0.875rem" }}>Cluster:</label>
             <select
               value={selectedCluster}
-              onChange={(e) => setSelectedCluster(e.target.value)}
+              onChange={(e) => {
+                api.setKubeContext('');
+                setSelectedContext('');
+                setSelectedCluster(e.target.value);
+              }}
               style={{
                 padding: "0.25rem 0.5rem",
                 border: "1px solid var(--border-color)",
@@ -207,8 +462,40 @@ export default function HomePage(): React.ReactElement {
               }}
             >
               {clusters.map(cluster => (
-                <option key={cluster.name} value={cluster.name}>
+                <option key={cluster.name} value={cluster.name} title={cluster.health?.lastError}>
                   {cluster.name} {cluster.current ? "(current)" : ""}
+                  {cluster.health?.state === 'unreachable' ? " (unreachable)" : cluster.health?.state === 'degraded' ? " (degraded)" : ""}
+                </option>
+              ))}
+              {clusters.length > 1 && (
+                <option value="all">All clusters</option>
+              )}
+            </select>
+          </div>
+        )}
+
+        {/* Context dropdown - only when the cluster is reachable with more than one set of credentials */}
+        {!inCluster && contexts.filter(context => context.cluster === selectedCluster).length > 1 && (
+          <div>
+            <label style={{ marginRight: "0.5rem", fontSize: "0.875rem" }}>Context:</label>
+            <select
+              value={selectedContext}
+              onChange={(e) => {
+                api.setKubeContext(e.target.value);
+                setSelectedContext(e.target.value);
+              }}
+              style={{
+                padding: "0.25rem 0.5rem",
+                border: "1px solid var(--border-color)",
+                borderRadius: "4px",
+                backgroundColor: "var(--bg-color)",
+                color: "var(--text-color)"
+              }}
+            >
+              <option value="">Automatic</option>
+              {contexts.filter(context => context.cluster === selectedCluster).map(context => (
+                <option key={context.name} value={context.name}>
+                  {context.name} ({context.user}) {context.current ? "(current)" : ""}
                 </option>
               ))}
             </select>
@@ -261,7 +548,7 @@ export default function HomePage(): React.ReactElement {
             type="text"
             value={selectedLabelFilter}
             onChange={(e) => setSelectedLabelFilter(e.target.value)}
-            placeholder="e.g., app=nginx, app=~coxex.*, env=~dev|staging"
+            placeholder="e.g., app=nginx, app=~coxex.*, env!~dev|sandbox"
             style={{
               padding: "0.25rem 0.5rem",
               border: "1px solid var(--border-color)",
@@ -272,6 +559,24 @@ export default function HomePage(): React.ReactElement {
             }}
           />
         </div>
+
+        <div>
+          <label style={{ marginRight: "0.5rem", fontSize: "0.875rem" }}>Name Filter:</label>
+          <input
+            type="text"
+            value={selectedNameFilter}
+            onChange={(e) => setSelectedNameFilter(e.target.value)}
+            placeholder="e.g., ^checkout-"
+            style={{
+              padding: "0.25rem 0.5rem",
+              border: "1px solid var(--border-color)",
+              borderRadius: "4px",
+              backgroundColor: "var(--bg-color)",
+              color: "var(--text-color)",
+              minWidth: "150px"
+            }}
+          />
+        </div>
       </div>
 
       {/* Status Info */}
@@ -292,9 +597,144 @@ export default function HomePage(): React.ReactElement {
         </div>
         <div>
           {(pods || []).length} pod{(pods || []).length !== 1 ? 's' : ''}
+          {canDeleteMatching && (
+            <button
+              onClick={handleDeleteMatching}
+              style={{
+                marginLeft: "0.75rem",
+                padding: "0.25rem 0.5rem",
+                backgroundColor: "transparent",
+                color: "#dc3545",
+                border: "1px solid #dc3545",
+                borderRadius: "4px",
+                fontSize: "0.75rem",
+                cursor: "pointer",
+                fontWeight: "500"
+              }}
+              title={`Delete every pod in ${selectedNamespace} matching ${selectedLabelFilter}, after a preview`}
+            >
+              Delete matching
+            </button>
+          )}
         </div>
       </div>
 
+      {clusterDegraded && !error && (
+        <div style={{
+          backgroundColor: "rgba(255, 193, 7, 0.1)",
+          border: "1px solid #ffc107",
+          borderRadius: "8px",
+          padding: "1rem",
+          marginBottom: "1rem",
+          color: "#b38600"
+        }}>
+          The cluster is returning errors; data may be stale or partial.
+        </div>
+      )}
+
+      {clusterWarning && !error && (
+        <div style={{
+          backgroundColor: "rgba(255, 193, 7, 0.1)",
+          border: "1px solid #ffc107",
+          borderRadius: "8px",
+          padding: "1rem",
+          marginBottom: "1rem",
+          color: "#b38600"
+        }}>
+          {clusterWarning}
+        </div>
+      )}
+
+      {namespaceAlerts && (namespaceAlerts.alerts.length > 0 || namespaceAlerts.silences.length > 0) && (
+        <div style={{
+          backgroundColor: "rgba(220, 53, 69, 0.05)",
+          border: "1px solid var(--border-color)",
+          borderRadius: "8px",
+          padding: "1rem",
+          marginBottom: "1rem",
+          color: "var(--text-color)"
+        }}>
+          <strong>{namespaceAlerts.alerts.filter(a => a.state === 'active').length} alerts firing, {namespaceAlerts.silences.length} silences</strong>
+          <ul style={{ margin: "0.5rem 0 0 0", paddingLeft: "1.25rem" }}>
+            {namespaceAlerts.alerts.map(a => (
+              <li key={a.fingerprint} style={{ opacity: a.state === 'active' ? 1 : 0.6 }}>
+                {a.name}{a.severity ? ` (${a.severity})` : ''}{a.summary ? `: ${a.summary}` : ''}{a.state !== 'active' ? ` [${a.state}]` : ''}
+              </li>
+            ))}
+            {namespaceAlerts.silences.map(s => (
+              <li key={s.id} style={{ opacity: 0.6 }}>
+                Silence {s.matchers.map(m => `${m.name}${m.isEqual ? '' : '!'}${m.isRegex ? '=~' : '='}${m.value}`).join(', ')} by {s.createdBy} until {new Date(s.endsAt).toLocaleTimeString()}{s.comment ? `: ${s.comment}` : ''}
+              </li>
+            ))}
+          </ul>
+        </div>
+      )}
+
+      {evicted && (
+        <div style={{
+          backgroundColor: "rgba(255, 193, 7, 0.1)",
+          border: "1px solid #ffc107",
+          borderRadius: "8px",
+          padding: "1rem",
+          marginBottom: "1rem",
+          color: "#b38600",
+          display: "flex",
+          justifyContent: "space-between",
+          alignItems: "center"
+        }}>
+          <span>
+            {evicted.total} evicted pods hidden:{' '}
+            {evicted.groups.map(group => `${group.count} ${group.reason}${group.resource ? ` (${group.resource})` : ''} on ${group.nodes.join(', ') || 'unknown nodes'}`).join('; ')}
+          </span>
+          {selectedCluster !== 'all' && (
+            <button
+              onClick={() => handleCleanupEvicted(evicted)}
+              style={{
+                padding: "0.25rem 0.75rem",
+                backgroundColor: "#6c757d",
+                color: "white",
+                border: "none",
+                borderRadius: "4px",
+                cursor: "pointer",
+                fontWeight: "500"
+              }}
+            >
+              Clean up
+            </button>
+          )}
+        </div>
+      )}
+
+      {pendingDelete && (
+        <div style={{
+          backgroundColor: "rgba(220, 53, 69, 0.1)",
+          border: "1px solid #dc3545",
+          borderRadius: "8px",
+          padding: "1rem",
+          marginBottom: "1rem",
+          color: "#dc3545",
+          display: "flex",
+          justifyContent: "space-between",
+          alignItems: "center"
+        }}>
+          <span>Pod {pendingDelete.namespace}/{pendingDelete.name} will be deleted at {new Date(pendingDelete.runAt).toLocaleTimeString()}.</span>
+          <button
+            onClick={() => handleUndoDelete(pendingDelete)}
+            style={{
+              padding: "0.25rem 0.75rem",
+              backgroundColor: "#6c757d",
+              color: "white",
+              border: "none",
+              borderRadius: "4px",
+              cursor: "pointer",
+              fontWeight: "500"
+            }}
+          >
+            Undo
+          </button>
+        </div>
+      )}
+
       {error && (
         <div style={{
           backgroundColor: "rgba(220, 53, 69, 0.1)",
@@ -308,6 +748,67 @@ export default function HomePage(): React.ReactElement {
         </div>
       )}
 
+      {selectedNode && (
+        <div style={{
+          border: "1px solid var(--border-color)",
+          borderRadius: "8px",
+          padding: "1rem",
+          marginBottom: "1rem",
+          fontSize: "0.875rem"
+        }}>
+          <div style={{ display: "flex", justifyContent: "space-between", alignItems: "center", marginBottom: "0.5rem" }}>
+            <strong>
+              Node {selectedNode.name}: {selectedNode.status}{selectedNode.unschedulable ? ', cordoned' : ''}
+            </strong>
+            <button
+              onClick={() => setSelectedNode(null)}
+              style={{
+                padding: "0.25rem 0.5rem",
+                backgroundColor: "transparent",
+                color: "var(--text-color)",
+                border: "1px solid var(--border-color)",
+                borderRadius: "4px",
+                fontSize: "0.75rem",
+                cursor: "pointer"
+              }}
+            >
+              Close
+            </button>
+          </div>
+          <div style={{ color: "var(--text-muted)", marginBottom: "0.5rem" }}>
+            {(selectedNode.roles || []).join(', ') || 'no roles'} &middot; kubelet {selectedNode.kubeletVersion}
+            {selectedNode.containerRuntime && <> &middot; {selectedNode.containerRuntime}</>}
+            {selectedNode.internalIP && <> &middot; {selectedNode.internalIP}</>}
+            {' '}&middot; {selectedNode.age}
+          </div>
+          {selectedNode.problems && selectedNode.problems.length > 0 && (
+            <div style={{ color: "#dc3545", marginBottom: "0.5rem" }}>
+              {selectedNode.problems.map(problem => (
+                <div key={problem.type}>
+                  {problem.type}{problem.reason ? ` (${problem.reason})` : ''}{problem.message ? `: ${problem.message}` : ''}
+                </div>
+              ))}
+            </div>
+          )}
+          <div style={{ fontFamily: "monospace", marginBottom: "0.5rem" }}>
+            {['cpu', 'memory', 'pods'].map(resource => (
+              <span key={resource} style={{ marginRight: "1rem" }}>
+                {resource} {selectedNode.allocatable?.[resource] || '-'} / {selectedNode.capacity?.[resource] || '-'}
+              </span>
+            ))}
+          </div>
+          {selectedNode.taints && selectedNode.taints.length > 0 && (
+            <div style={{ fontFamily: "monospace", marginBottom: "0.5rem" }}>
+              Taints: {selectedNode.taints.map(taint => `${taint.key}${taint.value ? `=${taint.value}` : ''}:${taint.effect}`).join(', ')}
+            </div>
+          )}
+          <div>
+            {selectedNode.pods.length} pod{selectedNode.pods.length !== 1 ? 's' : ''}:{' '}
+            {selectedNode.pods.map(nodePod => `${nodePod.namespace}/${nodePod.name} (${nodePod.status})`).join(', ')}
+          </div>
+        </div>
+      )}
+
       {/* Pods Table */}
       <div style={{
         backgroundColor: "var(--bg-color)",
@@ -319,6 +820,9 @@ export default function HomePage(): React.ReactElement {
           <thead>
             <tr style={{ backgroundColor: "var(--table-header-bg)" }}>
               <th style={{ padding: "0.75rem", textAlign: "left", fontWeight: "600" }}>Name</th>
+              {selectedCluster === 'all' && (
+                <th style={{ padding: "0.75rem", textAlign: "left", fontWeight: "600" }}>Cluster</th>
+              )}
               {selectedNamespace === 'all' && (
                 <th style={{ padding: "0.75rem", textAlign: "left", fontWeight: "600" }}>Namespace</th>
               )}
@@ -327,17 +831,42 @@ export default function HomePage(): React.ReactElement {
               <th style={{ padding: "0.75rem", textAlign: "left", fontWeight: "600" }}>Ready</th>
               <th style={{ padding: "0.75rem", textAlign: "left", fontWeight: "600" }}>Restarts</th>
               <th style={{ padding: "0.75rem", textAlign: "left", fontWeight: "600" }}>Age</th>
+              {hasUsage && (
+                <>
+                  <th style={{ padding: "0.75rem", textAlign: "left", fontWeight: "600" }}>CPU</th>
+                  <th style={{ padding: "0.75rem", textAlign: "left", fontWeight: "600" }}>Memory</th>
+                </>
+              )}
+              {hasAppMetric && (
+                <th style={{ padding: "0.75rem", textAlign: "left", fontWeight: "600" }}>Metric</th>
+              )}
               <th style={{ padding: "0.75rem", textAlign: "left", fontWeight: "600" }}>Node</th>
               <th style={{ padding: "0.75rem", textAlign: "left", fontWeight: "600" }}>IP</th>
-              <th style={{ padding: "0.75rem", textAlign: "center", fontWeight: "600" }}>Delete</th>
+              <th style={{ padding: "0.75rem", textAlign: "center", fontWeight: "600" }}>Actions</th>
             </tr>
           </thead>
           <tbody>
             {(pods || []).map((pod, index) => (
-              <tr key={`${pod.namespace || 'unknown'}-${pod.name || 'unknown'}`} style={{
+              <tr key={`${pod.cluster || ''}-${pod.namespace || 'unknown'}-${pod.name || 'unknown'}`} style={{
                 borderTop: index > 0 ? "1px solid var(--border-color)" : "none"
               }}>
-                <td style={{ padding: "0.75rem", fontFamily: "monospace" }}>{pod.name || '-'}</td>
+                <td style={{ padding: "0.75rem", fontFamily: "monospace" }}>
+                  {pod.name || '-'}
+                  {pod.runbookUrl && (
+                    <a
+                      href={pod.runbookUrl}
+                      target="_blank"
+                      rel="noopener noreferrer"
+                      style={{ marginLeft: "0.5rem", fontSize: "0.75rem", color: "#0d6efd" }}
+                      title={`Runbook for ${pod.name}`}
+                    >
+                      runbook
+                    </a>
+                  )}
+                </td>
+                {selectedCluster === 'all' && (
+                  <td style={{ padding: "0.75rem", fontSize: "0.875rem" }}>{pod.cluster || '-'}</td>
+                )}
                 {selectedNamespace === 'all' && (
                   <td style={{ padding: "0.75rem", fontFamily: "monospace", fontSize: "0.875rem" }}>{pod.namespace || '-'}</td>
                 )}
@@ -349,13 +878,207 @@ export default function HomePage(): React.ReactElement {
                   }}>
                     {pod.status || 'Unknown'}
                   </span>
+                  {(pod.derivedStatuses || []).map(derivedStatus => (
+                    <span
+                      key={derivedStatus}
+                      style={{
+                        marginLeft: "0.5rem",
+                        padding: "0.125rem 0.375rem",
+                        borderRadius: "4px",
+                        fontSize: "0.75rem",
+                        backgroundColor: "var(--border-color)"
+                      }}
+                    >
+                      {derivedStatus}
+                    </span>
+                  ))}
+                  {pod.lastExitReason && (
+                    <span
+                      title={`Container ${pod.lastExitContainer} last exited with ${pod.lastExitReason} (exit code ${pod.lastExitCode}) at ${pod.lastExitTime}`}
+                      style={{
+                        marginLeft: "0.5rem",
+                        padding: "0.125rem 0.375rem",
+                        borderRadius: "4px",
+                        fontSize: "0.75rem",
+                        color: "#fff",
+                        backgroundColor: pod.lastExitReason === 'OOMKilled' ? "#dc3545" : "#6c757d"
+                      }}
+                    >
+                      last: {pod.lastExitReason}
+                    </span>
+                  )}
+                </td>
+                <td
+                  style={{ padding: "0.75rem", fontFamily: "monospace" }}
+                  title={pod.containers?.map(container => `${container.name}${container.type === 'sidecar' ? ' [sidecar]' : ''} (${container.imageTag}): ${container.ready ? 'ready' : 'not ready'}, ${container.stateReason || container.state}, ${container.restartCount} restart${container.restartCount !== 1 ? 's' : ''}${container.lastTerminationReason ? `, last ${container.lastTerminationReason} (exit ${container.lastTerminationExitCode})` : ''}`).join('\n')}
+                >
+                  {pod.ready || '-'}
+                  {(pod.containers?.length || 0) > 1 && pod.containers?.some(container => !container.ready) && (
+                    <span style={{ marginLeft: "0.5rem", fontSize: "0.75rem", color: "#dc3545" }}>
+                      {pod.containers.filter(container => !container.ready).map(container => container.name).join(', ')}
+                    </span>
+                  )}
+                  {pod.mesh?.problems && pod.mesh.problems.length > 0 && (
+                    <span
+                      title={`${pod.mesh.mesh} sidecar: ${pod.mesh.problems.join(', ')}${pod.mesh.proxyVersion ? ` (proxy ${pod.mesh.proxyVersion}, control plane ${(pod.mesh.controlPlaneVersions || []).join(', ') || 'unknown'})` : ''}`}
+                      style={{
+                        marginLeft: "0.5rem",
+                        padding: "0.125rem 0.375rem",
+                        borderRadius: "4px",
+                        fontSize: "0.75rem",
+                        fontFamily: "inherit",
+                        color: "#fff",
+                        backgroundColor: "#fd7e14"
+                      }}
+                    >
+                      {pod.mesh.problems.join(', ')}
+                    </span>
+                  )}
+                </td>
+                <td
+                  style={{ padding: "0.75rem", textAlign: "center" }}
+                  title={pod.sandboxRestarts ? `Pod sandbox recreated ${pod.sandboxRestarts} time${pod.sandboxRestarts !== 1 ? 's' : ''}: check the node, not the application` : undefined}
+                >
+                  {pod.restarts || 0}
+                  {pod.sandboxRestarts ? <span style={{ color: "#b38600" }}> ({pod.sandboxRestarts} sandbox)</span> : null}
+                  {pod.sidecarRestarts ? <span style={{ color: "#666" }} title="Native sidecar restarts, not counted against the app containers"> (+{pod.sidecarRestarts} sidecar)</span> : null}
                 </td>
-                <td style={{ padding: "0.75rem", fontFamily: "monospace" }}>{pod.ready || '-'}</td>
-                <td style={{ padding: "0.75rem", textAlign: "center" }}>{pod.restarts || 0}</td>
                 <td style={{ padding: "0.75rem" }}>{pod.age || '-'}</td>
-                <td style={{ padding: "0.75rem", fontSize: "0.875rem" }}>{pod.node || '-'}</td>
+                {hasUsage && (
+                  <>
+                    <td
+                      style={{ padding: "0.75rem", fontFamily: "monospace", fontSize: "0.875rem" }}
+                      title={pod.usage?.containers.map(container => `${container.name}: ${container.cpu}`).join('\n')}
+                    >
+                      {pod.usage?.cpu || '-'}
+                    </td>
+                    <td
+                      style={{ padding: "0.75rem", fontFamily: "monospace", fontSize: "0.875rem" }}
+                      title={pod.usage?.containers.map(container => `${container.name}: ${container.memory}`).join('\n')}
+                    >
+                      {pod.usage?.memory || '-'}
+                    </td>
+                  </>
+                )}
+                {hasAppMetric && (
+                  <td
+                    style={{ padding: "0.75rem", fontFamily: "monospace", fontSize: "0.875rem" }}
+                    title={pod.appMetric ? (pod.appMetric.error || pod.appMetric.name) : undefined}
+                  >
+                    {pod.appMetric ? (pod.appMetric.error ? '!' : pod.appMetric.value) : '-'}
+                  </td>
+                )}
+                <td style={{ padding: "0.75rem", fontSize: "0.875rem" }}>
+                  {pod.node ? (
+                    <button
+                      onClick={() => handleShowNode(pod)}
+                      style={{
+                        padding: 0,
+                        backgroundColor: "transparent",
+                        color: "var(--text-color)",
+                        border: "none",
+                        fontSize: "0.875rem",
+                        cursor: "pointer",
+                        textDecoration: "underline"
+                      }}
+                      title={`Show node ${pod.node}`}
+                    >
+                      {pod.node}
+                    </button>
+                  ) : '-'}
+                  {pod.nodeProblems && pod.nodeProblems.length > 0 && (
+                    <span
+                      title={`Node problems: ${pod.nodeProblems.join(', ')}`}
+                      style={{
+                        marginLeft: "0.5rem",
+                        padding: "0.125rem 0.375rem",
+                        borderRadius: "4px",
+                        fontSize: "0.75rem",
+                        color: "#fff",
+                        backgroundColor: "#dc3545"
+                      }}
+                    >
+                      {pod.nodeProblems.join(', ')}
+                    </span>
+                  )}
+                </td>
                 <td style={{ padding: "0.75rem", fontFamily: "monospace", fontSize: "0.875rem" }}>{pod.ip || '-'}</td>
-                <td style={{ padding: "0.75rem", textAlign: "center" }}>
+                <td style={{ padding: "0.75rem", textAlign: "center", whiteSpace: "nowrap" }}>
+                  {podActions.map(action => (
+                    <button
+                      key={action.name}
+                      onClick={() => handlePodAction(pod, action)}
+                      style={{
+                        padding: "0.25rem 0.5rem",
+                        marginRight: "0.25rem",
+                        backgroundColor: "transparent",
+                        color: "var(--text-color)",
+                        border: "1px solid var(--border-color)",
+                        borderRadius: "4px",
+                        fontSize: "0.75rem",
+                        cursor: "pointer",
+                        fontWeight: "500"
+                      }}
+                      title={`${action.name} for pod ${pod.name}`}
+                    >
+                      {action.name}
+                    </button>
+                  ))}
+                  {ticketBackend && (
+                    <button
+                      onClick={() => handleCreateTicket(pod)}
+                      style={{
+                        padding: "0.25rem 0.5rem",
+                        marginRight: "0.25rem",
+                        backgroundColor: "transparent",
+                        color: "var(--text-color)",
+                        border: "1px solid var(--border-color)",
+                        borderRadius: "4px",
+                        fontSize: "0.75rem",
+                        cursor: "pointer",
+                        fontWeight: "500"
+                      }}
+                      title={`Create a ${ticketBackend} ticket for pod ${pod.name}`}
+                    >
+                      Ticket
+                    </button>
+                  )}
+                  {alertmanagerEnabled && pod.ownerKind && silenceableKinds.includes(pod.ownerKind) && (
+                    <button
+                      onClick={() => handleSilenceWorkload(pod)}
+                      style={{
+                        padding: "0.25rem 0.5rem",
+                        marginRight: "0.25rem",
+                        backgroundColor: "transparent",
+                        color: "var(--text-color)",
+                        border: "1px solid var(--border-color)",
+                        borderRadius: "4px",
+                        fontSize: "0.75rem",
+                        cursor: "pointer",
+                        fontWeight: "500"
+                      }}
+                      title={`Silence alerts for ${pod.ownerKind} ${pod.ownerName}`}
+                    >
+                      Silence
+                    </button>
+                  )}
+                  <button
+                    onClick={() => handleEvictPod(pod)}
+                    style={{
+                      padding: "0.25rem 0.5rem",
+                      marginRight: "0.25rem",
+                      backgroundColor: "transparent",
+                      color: "var(--text-color)",
+                      border: "1px solid var(--border-color)",
+                      borderRadius: "4px",
+                      fontSize: "0.75rem",
+                      cursor: "pointer",
+                      fontWeight: "500"
+                    }}
+                    title={`Evict pod ${pod.name}, respecting disruption budgets`}
+                  >
+                    Evict
+                  </button>
                   <button
                     onClick={() => handleDeletePod(pod)}
                     style={{
@@ -374,9 +1097,9 @@ export default function HomePage(): React.ReactElement {
                     onMouseLeave={(e) => {
                       e.currentTarget.style.backgroundColor = "#dc3545";
                     }}
-                    title={`Delete pod ${pod.name}`}
+                    title={pod.status === 'Terminating' ? `Force delete pod ${pod.name}` : `Delete pod ${pod.name}`}
                   >
-                    Delete
+                    {pod.status === 'Terminating' ? 'Force Delete' : 'Delete'}
                   </button>
                 </td>
               </tr>
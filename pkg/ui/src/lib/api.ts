@@ -1,7 +1,10 @@
-import type { PodsResponse, NamespacesResponse, ClustersResponse } from '@/types';
+import type { PodsResponse, NamespacesResponse, ClustersResponse, ConfigResponse, ActionResult, TicketInfo, NodeDetail, BatchDeleteResult, DeletePodResponse, PendingAction, PodCleanupResult, NamespaceAlerts, Silence, Topology, NamespacePodSecurity, CacheStatusResponse } from '@/types';
 
 const API_BASE = '/api';
 
+// Kubeconfig context sent with every request; empty lets the server pick credentials for the cluster
+let kubeContext = '';
+
 class ApiError extends Error {
   constructor(
     message: string,
@@ -13,17 +16,46 @@ class ApiError extends Error {
   }
 }
 
+// CSRF token the server set in the podboard_csrf cookie, echoed on requests that change something
+function csrfToken(): string {
+  const cookie = document.cookie.split('; ').find((entry) => entry.startsWith('podboard_csrf='));
+  return cookie ? decodeURIComponent(cookie.slice('podboard_csrf='.length)) : '';
+}
+
 async function fetchAPI<T>(endpoint: string, options?: RequestInit): Promise<T> {
   const url = `${API_BASE}${endpoint}`;
+  const method = (options?.method || 'GET').toUpperCase();
+  const token = method === 'GET' || method === 'HEAD' ? '' : csrfToken();
 
-  const response = await fetch(url, {
+  let response = await fetch(url, {
     ...options,
     headers: {
       'Content-Type': 'application/json',
+      ...(kubeContext ? { 'X-Podboard-Context': kubeContext } : {}),
+      ...(token ? { 'X-CSRF-Token': token } : {}),
       ...options?.headers,
     },
   });
 
+  // With --confirm-destructive the server asks for destructive requests to be confirmed before repeating them
+  if (response.status === 428) {
+    const confirmation = await response.json().catch(() => ({}));
+    if (confirmation.confirmationToken && window.confirm(`Confirm ${method} ${endpoint}?`)) {
+      response = await fetch(url, {
+        ...options,
+        headers: {
+          'Content-Type': 'application/json',
+          ...(kubeContext ? { 'X-Podboard-Context': kubeContext } : {}),
+          ...(token ? { 'X-CSRF-Token': token } : {}),
+          'X-Podboard-Confirm': confirmation.confirmationToken,
+          ...options?.headers,
+        },
+      });
+    } else {
+      throw new ApiError('Cancelled', 428, confirmation);
+    }
+  }
+
   if (!response.ok) {
     let errorMessage = `HTTP ${response.status}: ${response.statusText}`;
     let errorResponse;
@@ -42,10 +74,23 @@ async function fetchAPI<T>(endpoint: string, options?: RequestInit): Promise<T>
 }
 
 export const api = {
+  // Select the kubeconfig context used for subsequent requests
+  setKubeContext: (name: string): void => {
+    kubeContext = name;
+  },
+
+  // UI configuration and custom actions
+  getConfig: (): Promise<ConfigResponse> =>
+    fetchAPI('/config'),
+
   // Clusters
   getClusters: (): Promise<ClustersResponse> =>
     fetchAPI('/clusters'),
 
+  // Startup priming progress
+  getCacheStatus: (): Promise<CacheStatusResponse> =>
+    fetchAPI('/cache/status'),
+
   // Namespaces
   getNamespaces: (cluster?: string): Promise<NamespacesResponse> => {
     const params = new URLSearchParams();
@@ -56,26 +101,152 @@ export const api = {
   },
 
   // Pods
-  getPods: (namespace?: string, labelSelector?: string, cluster?: string): Promise<PodsResponse> => {
+  getPods: (namespace?: string, labelSelector?: string, cluster?: string, nameFilter?: string, fieldSelector?: string): Promise<PodsResponse> => {
     const params = new URLSearchParams();
     if (namespace) {params.append('namespace', namespace);}
     if (labelSelector) {params.append('labelSelector', labelSelector);}
     if (cluster) {params.append('cluster', cluster);}
+    if (nameFilter) {params.append('nameFilter', nameFilter);}
+    if (fieldSelector) {params.append('fieldSelector', fieldSelector);}
+    // Evicted and shut down pods come back grouped instead of flooding the table
+    params.append('evicted', 'group');
 
     const queryString = params.toString();
     return fetchAPI(`/pods${queryString ? `?${queryString}` : ''}`);
   },
 
   // Delete pod
-  deletePod: (namespace: string, podName: string, cluster?: string): Promise<{message: string}> => {
+  deletePod: (namespace: string, podName: string, cluster?: string, force?: boolean): Promise<DeletePodResponse> => {
     const params = new URLSearchParams();
     if (cluster) {params.append('cluster', cluster);}
+    if (force) {
+      params.append('force', 'true');
+      params.append('gracePeriod', '0');
+      params.append('confirm', `${namespace}/${podName}`);
+    }
 
     const queryString = params.toString();
     return fetchAPI(`/pods/${namespace}/${podName}${queryString ? `?${queryString}` : ''}`, {
       method: 'DELETE'
     });
   },
+
+  // Undo a queued delete before its undo window passes
+  cancelPendingAction: (id: string): Promise<{pendingAction: PendingAction}> => {
+    return fetchAPI(`/pending-actions/${encodeURIComponent(id)}`, {
+      method: 'DELETE'
+    });
+  },
+
+  // Delete every pod in a namespace matching a label selector, or only list them with dryRun
+  deletePods: (namespace: string, labelSelector: string, dryRun: boolean, cluster?: string): Promise<BatchDeleteResult> => {
+    const params = new URLSearchParams();
+    params.append('namespace', namespace);
+    params.append('labelSelector', labelSelector);
+    if (dryRun) {params.append('dryRun', 'true');}
+    if (cluster) {params.append('cluster', cluster);}
+
+    return fetchAPI(`/pods?${params.toString()}`, {
+      method: 'DELETE'
+    });
+  },
+
+  // Delete finished pods older than olderThan, limited to the given phases and status reasons
+  cleanupPods: (namespace: string, olderThan: string, phases: string[], reasons: string[], cluster?: string): Promise<PodCleanupResult> => {
+    const params = new URLSearchParams();
+    params.append('namespace', namespace);
+    params.append('olderThan', olderThan);
+    if (phases.length > 0) {params.append('phase', phases.join(','));}
+    if (reasons.length > 0) {params.append('reason', reasons.join(','));}
+    if (cluster) {params.append('cluster', cluster);}
+
+    return fetchAPI(`/pods/cleanup?${params.toString()}`, {
+      method: 'POST'
+    });
+  },
+
+  // Evict pod, respecting disruption budgets
+  evictPod: (namespace: string, podName: string, cluster?: string): Promise<{message: string}> => {
+    const params = new URLSearchParams();
+    if (cluster) {params.append('cluster', cluster);}
+
+    const queryString = params.toString();
+    return fetchAPI(`/pods/${namespace}/${podName}/evict${queryString ? `?${queryString}` : ''}`, {
+      method: 'POST'
+    });
+  },
+
+  // Node with its conditions, taints, capacity and pods
+  getNode: (name: string, cluster?: string): Promise<{node: NodeDetail}> => {
+    const params = new URLSearchParams();
+    if (cluster) {params.append('cluster', cluster);}
+
+    const queryString = params.toString();
+    return fetchAPI(`/nodes/${encodeURIComponent(name)}${queryString ? `?${queryString}` : ''}`);
+  },
+
+  // Run a custom action on a pod
+  runPodAction: (namespace: string, podName: string, action: string, cluster?: string): Promise<{result: ActionResult}> => {
+    const params = new URLSearchParams();
+    if (cluster) {params.append('cluster', cluster);}
+
+    const queryString = params.toString();
+    return fetchAPI(`/pods/${namespace}/${podName}/actions/${encodeURIComponent(action)}${queryString ? `?${queryString}` : ''}`, {
+      method: 'POST'
+    });
+  },
+
+  // Create an incident ticket for a pod
+  createPodTicket: (namespace: string, podName: string, note?: string, cluster?: string): Promise<{ticket: TicketInfo}> => {
+    const params = new URLSearchParams();
+    if (cluster) {params.append('cluster', cluster);}
+
+    const queryString = params.toString();
+    return fetchAPI(`/pods/${namespace}/${podName}/ticket${queryString ? `?${queryString}` : ''}`, {
+      method: 'POST',
+      body: JSON.stringify({ note: note || '' })
+    });
+  },
+
+  // Alerts firing in a namespace and the silences affecting it, from Alertmanager
+  getAlerts: (namespace: string, cluster?: string): Promise<NamespaceAlerts> => {
+    const params = new URLSearchParams();
+    params.append('namespace', namespace);
+    if (cluster) {params.append('cluster', cluster);}
+
+    return fetchAPI(`/alerts?${params.toString()}`);
+  },
+
+  // Silence the alerts of a Deployment, StatefulSet or DaemonSet for a number of minutes
+  silenceWorkload: (kind: string, namespace: string, name: string, minutes: number, comment: string, cluster?: string): Promise<{silence: Silence}> => {
+    const params = new URLSearchParams();
+    if (cluster) {params.append('cluster', cluster);}
+
+    const queryString = params.toString();
+    return fetchAPI(`/${kind.toLowerCase()}s/${namespace}/${name}/silence${queryString ? `?${queryString}` : ''}`, {
+      method: 'POST',
+      body: JSON.stringify({ minutes, comment })
+    });
+  },
+
+  // Pods violating their namespace's Pod Security Standard, or the given level
+  getPodSecurity: (namespace: string, level?: string, cluster?: string): Promise<{podSecurity: NamespacePodSecurity[]}> => {
+    const params = new URLSearchParams();
+    params.append('namespace', namespace);
+    if (level) {params.append('level', level);}
+    if (cluster) {params.append('cluster', cluster);}
+
+    return fetchAPI(`/pod-security?${params.toString()}`);
+  },
+
+  // Dependency graph of a namespace's Services, pods, the objects they use and their nodes
+  getTopology: (namespace: string, cluster?: string): Promise<Topology> => {
+    const params = new URLSearchParams();
+    params.append('namespace', namespace);
+    if (cluster) {params.append('cluster', cluster);}
+
+    return fetchAPI(`/topology?${params.toString()}`);
+  },
 };
 
 export { ApiError };
\ No newline at end of file
@@ -1,30 +1,406 @@
+export interface ContainerUsage {
+  name: string;
+  cpu: string;
+  memory: string;
+  cpuMillicores: number;
+  memoryBytes: number;
+}
+
+export interface PodUsage {
+  cpu: string;
+  memory: string;
+  cpuMillicores: number;
+  memoryBytes: number;
+  containers: ContainerUsage[];
+}
+
+export interface AppMetric {
+  name: string;
+  value: number;
+  error?: string;
+}
+
+export interface MeshStatus {
+  mesh: string;
+  sidecar: boolean;
+  proxyReady: boolean;
+  proxyVersion?: string;
+  controlPlaneVersions?: string[];
+  problems?: string[];
+}
+
+export interface ContainerInfo {
+  name: string;
+  type: 'app' | 'sidecar';
+  restartPolicy: string;
+  image: string;
+  imageTag: string;
+  ready: boolean;
+  restartCount: number;
+  state: string;
+  stateReason?: string;
+  lastTerminationReason?: string;
+  lastTerminationExitCode?: number;
+}
+
 export interface PodInfo {
+  cluster?: string;
   name: string;
   namespace: string;
   imageTag: string;
   status: string;
+  reason?: string;
+  message?: string;
   ready: string;
   restarts: number;
+  sidecarRestarts?: number;
+  sandboxRestarts?: number;
+  createdAt: string;
   age: string;
+  ageSeconds: number;
   node: string;
   ip: string;
   labels?: Record<string, string>;
+  usage?: PodUsage;
+  runbookUrl?: string;
+  derivedStatuses?: string[];
+  nodeProblems?: string[];
+  ownerKind?: string;
+  ownerName?: string;
+  priority?: number;
+  priorityClassName?: string;
+  qosClass?: string;
+  serviceAccountName?: string;
+  preemption?: string;
+  appMetric?: AppMetric;
+  mesh?: MeshStatus;
+  containers?: ContainerInfo[];
+  lastExitReason?: string;
+  lastExitCode?: number;
+  lastExitTime?: string;
+  lastExitContainer?: string;
+}
+
+export interface PodBranchResult {
+  cluster?: string;
+  namespace: string;
+  pods: number;
+  durationMs: number;
+  error?: string;
+}
+
+export interface EvictionGroup {
+  reason: string;
+  resource?: string;
+  count: number;
+  nodes: string[];
+}
+
+export interface EvictionSummary {
+  total: number;
+  groups: EvictionGroup[];
+  pods: PodInfo[];
+}
+
+export interface PodCleanupResult {
+  dryRun: boolean;
+  retention: string;
+  pods: PodInfo[];
+  deleted?: string[];
+  failed?: Record<string, string>;
 }
 
 export interface PodsResponse {
   pods: PodInfo[];
+  evicted?: EvictionSummary;
+  branches?: PodBranchResult[];
+  clusterDegraded?: boolean;
+  stale?: boolean;
+  cachedAt?: string;
+  cacheAgeSeconds?: number;
+  clusterErrors?: Record<string, string>;
+  continue?: string;
+}
+
+export interface ClusterHealth {
+  state: 'unknown' | 'healthy' | 'degraded' | 'unreachable';
+  consecutiveFailures?: number;
+  lastError?: string;
+  lastErrorAt?: string;
+  lastSuccessAt?: string;
+  latencyMs?: number;
+  retryAt?: string;
+}
+
+export interface RetryStats {
+  retried: number;
+  retries: number;
+  recovered: number;
+  exhausted: number;
+  throttled: number;
 }
 
 export interface ClusterInfo {
   name: string;
   current: boolean;
+  health?: ClusterHealth;
+  retries?: RetryStats;
+}
+
+export interface ContextInfo {
+  name: string;
+  cluster: string;
+  user: string;
+  namespace?: string;
+  current: boolean;
 }
 
 export interface ClustersResponse {
   inCluster: boolean;
   clusters: ClusterInfo[];
+  health?: ClusterHealth;
+  retries?: RetryStats;
+  contexts?: ContextInfo[];
+  warming?: string[];
+}
+
+export interface ClusterPrimingStatus {
+  name: string;
+  state: 'pending' | 'priming' | 'done' | 'failed';
+  namespaces: number;
+  primed: number;
+  failed?: string[];
+  error?: string;
+}
+
+export interface CacheStatusResponse {
+  state: 'disabled' | 'pending' | 'priming' | 'done';
+  ready: boolean;
+  startedAt?: string;
+  finishedAt?: string;
+  clusters: ClusterPrimingStatus[];
 }
 
 export interface NamespacesResponse {
   namespaces: string[];
-}
\ No newline at end of file
+  clusterDegraded?: boolean;
+}
+
+export interface ErrorResponse {
+  error: string;
+  clusterDegraded?: boolean;
+}
+
+export interface ActionConfig {
+  name: string;
+  kind: 'pod' | 'deployment';
+  method: string;
+  confirm?: string;
+}
+
+export interface ConfigResponse {
+  version: string;
+  offline: boolean;
+  impersonate: boolean;
+  actions: ActionConfig[];
+  tickets: string;
+  alertmanager: boolean;
+}
+
+export interface Alert {
+  fingerprint: string;
+  name: string;
+  severity?: string;
+  state: string;
+  summary?: string;
+  startsAt: string;
+  labels: Record<string, string>;
+  silencedBy?: string[];
+}
+
+export interface SilenceMatcher {
+  name: string;
+  value: string;
+  isRegex: boolean;
+  isEqual: boolean;
+}
+
+export interface Silence {
+  id: string;
+  matchers: SilenceMatcher[];
+  startsAt: string;
+  endsAt: string;
+  createdBy: string;
+  comment: string;
+}
+
+export interface NamespaceAlerts {
+  alerts: Alert[];
+  silences: Silence[];
+}
+
+export interface TicketInfo {
+  backend: string;
+  id: string;
+  url: string;
+}
+
+export interface ActionResult {
+  action: string;
+  url?: string;
+  status?: number;
+  body?: string;
+}
+
+export interface EventInfo {
+  type: string;
+  reason: string;
+  message: string;
+  count: number;
+  lastSeen: string;
+  age: string;
+  ageSeconds: number;
+  source?: string;
+  namespace: string;
+  object: string;
+}
+
+export interface NodeTaint {
+  key: string;
+  value?: string;
+  effect: string;
+}
+
+export interface NodeCondition {
+  type: string;
+  status: string;
+  reason?: string;
+  message?: string;
+  lastTransitionTime?: string;
+}
+
+export interface NodeProblem {
+  type: string;
+  reason?: string;
+  message?: string;
+  since?: string;
+}
+
+export interface NodeInfo {
+  name: string;
+  status: string;
+  unschedulable: boolean;
+  roles?: string[];
+  kubeletVersion: string;
+  containerRuntime?: string;
+  osImage?: string;
+  kernelVersion?: string;
+  pool?: string;
+  internalIP?: string;
+  createdAt: string;
+  age: string;
+  ageSeconds: number;
+  capacity?: Record<string, string>;
+  allocatable?: Record<string, string>;
+  taints?: NodeTaint[];
+  conditions: NodeCondition[];
+  problems?: NodeProblem[];
+}
+
+export interface NodeDetail extends NodeInfo {
+  events: EventInfo[];
+  pods: PodInfo[];
+}
+
+export interface DisruptionBudget {
+  name: string;
+  minAvailable?: string;
+  maxUnavailable?: string;
+  currentHealthy: number;
+  desiredHealthy: number;
+  expectedPods: number;
+  disruptionsAllowed: number;
+}
+
+export interface EvictionBlocked {
+  message: string;
+  causes?: string[];
+  retryAfterSeconds?: number;
+  disruptionBudgets?: DisruptionBudget[];
+}
+
+export interface PendingAction {
+  id: string;
+  cluster?: string;
+  kind: string;
+  namespace?: string;
+  name: string;
+  action: string;
+  user?: string;
+  requestId?: string;
+  state: 'Pending' | 'Running' | 'Done' | 'Failed' | 'Cancelled';
+  message?: string;
+  createdAt: string;
+  runAt: string;
+  finishedAt?: string;
+}
+
+// A delete with an undo window returns the pending action instead of deleting right away
+export interface DeletePodResponse {
+  message: string;
+  pendingAction?: PendingAction;
+}
+
+export interface BatchDeleteResult {
+  dryRun: boolean;
+  pods: PodInfo[];
+  deleted?: string[];
+  failed?: Record<string, string>;
+}
+
+export interface PodSecurityViolation {
+  check: string;
+  level: 'baseline' | 'restricted';
+  detail: string;
+}
+
+export interface PodSecurityFinding {
+  name: string;
+  ownerKind?: string;
+  ownerName?: string;
+  violations: PodSecurityViolation[];
+}
+
+// Pods the namespace's Pod Security Standard would reject when they are next created
+export interface NamespacePodSecurity {
+  namespace: string;
+  enforce?: string;
+  enforceVersion?: string;
+  level: 'privileged' | 'baseline' | 'restricted';
+  pods: number;
+  rejected: PodSecurityFinding[];
+}
+
+// Edges point from the dependent object to the object it depends on
+export interface TopologyEdge {
+  from: string;
+  to: string;
+  type: 'selects' | 'mounts' | 'references' | 'runs-on';
+}
+
+export interface TopologyNode {
+  id: string;
+  kind: 'Service' | 'Pod' | 'ConfigMap' | 'Secret' | 'PersistentVolumeClaim' | 'Node';
+  namespace?: string;
+  name: string;
+  status?: string;
+  healthy: boolean;
+  owner?: string;
+  blastRadius?: string[];
+}
+
+export interface Topology {
+  namespaces: string[];
+  nodes: TopologyNode[];
+  edges: TopologyEdge[];
+}
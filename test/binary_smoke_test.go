@@ -60,6 +60,8 @@ func TestBinarySmoke(t *testing.T) {
 		{"podboard-darwin-amd64", "darwin", "amd64"},
 		{"podboard-darwin-arm64", "darwin", "arm64"},
 		{"podboard-windows-amd64.exe", "windows", "amd64"},
+		{"podboard-linux-amd64-fips", "linux", "amd64"},
+		{"podboard-linux-arm64-fips", "linux", "arm64"},
 	}
 
 	for _, binary := range binaries {
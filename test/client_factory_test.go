@@ -0,0 +1,104 @@
+/*
+Copyright (c) 2024 Nik Ogura
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nikogura/podboard/pkg/podboard"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestClientFactory serves the API from fake clientsets injected with WithKubeClientFactory.
+func TestClientFactory(t *testing.T) {
+	staging := fake.NewClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default"},
+		Spec:       corev1.PodSpec{NodeName: "node-1"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+	production := fake.NewClientset()
+
+	factory := podboard.NewStaticClientFactory(map[string]kubernetes.Interface{
+		"staging":    staging,
+		"production": production,
+	}, "staging")
+
+	server, err := podboard.NewServer(podboard.ServerConfig{}, podboard.WithKubeClientFactory(factory))
+	require.NoError(t, err)
+	defer func() { _ = server.Shutdown(context.Background()) }()
+
+	httpServer := httptest.NewServer(server.Handler())
+	defer httpServer.Close()
+
+	get := func(path string) (status int, body map[string]interface{}) {
+		resp, getErr := http.Get(httpServer.URL + path)
+		require.NoError(t, getErr)
+		defer func() { _ = resp.Body.Close() }()
+
+		status = resp.StatusCode
+		decodeErr := json.NewDecoder(resp.Body).Decode(&body)
+		require.NoError(t, decodeErr)
+		return status, body
+	}
+
+	t.Run("Clusters", func(t *testing.T) {
+		status, body := get("/api/clusters")
+		require.Equal(t, http.StatusOK, status)
+
+		clusters, ok := body["clusters"].([]interface{})
+		require.True(t, ok)
+		require.Len(t, clusters, 2)
+		assert.Equal(t, "staging", clusters[0].(map[string]interface{})["name"])
+		assert.Equal(t, true, clusters[0].(map[string]interface{})["current"])
+	})
+
+	t.Run("Pods from the current cluster", func(t *testing.T) {
+		status, body := get("/api/pods?namespace=default")
+		require.Equal(t, http.StatusOK, status)
+
+		pods, ok := body["pods"].([]interface{})
+		require.True(t, ok)
+		require.Len(t, pods, 1)
+		assert.Equal(t, "web-1", pods[0].(map[string]interface{})["name"])
+	})
+
+	t.Run("Pods from another cluster", func(t *testing.T) {
+		status, body := get("/api/pods?namespace=default&cluster=production")
+		require.Equal(t, http.StatusOK, status)
+		assert.Empty(t, body["pods"])
+	})
+
+	t.Run("Unknown cluster", func(t *testing.T) {
+		status, _ := get("/api/pods?namespace=default&cluster=unknown")
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+}